@@ -19,6 +19,19 @@ func init() {
 	// auth-daemon concern (CDS/CSYNC/error reports about a zone).
 	cli.AuthCmd.AddCommand(cli.ReportCmd)
 
+	// From ../../v2/cli/audit_cmds.go: 'auth audit' — the audit journal
+	// lives in the auth daemon's KeyDB.
+	cli.AuthCmd.AddCommand(cli.NewAuditCmd("auth"))
+
+	// From ../../v2/cli/changeset_cmds.go: 'auth changeset' — staged
+	// content changes to the auth daemon's primary zones.
+	cli.AuthCmd.AddCommand(cli.NewChangeSetCmd("auth"))
+
+	// From ../../v2/cli/events_cmds.go: 'events follow' — live state-change
+	// stream; both daemons serve /events.
+	cli.AuthCmd.AddCommand(cli.NewEventsCmd("auth"))
+	cli.AgentCmd.AddCommand(cli.NewEventsCmd("agent"))
+
 	// Note: 'auth daemon' is already wired in v2/cli/auth_cmds.go init().
 	cli.AgentCmd.AddCommand(cli.NewDaemonCmd("agent"))
 
@@ -26,6 +39,9 @@ func init() {
 	// DDNS update protocol + delegation-sync are auth-daemon concerns.
 	cli.AuthCmd.AddCommand(cli.DdnsCmd, cli.DelCmd)
 
+	// From ../../v2/cli/scanner_cmds.go: the parent-side scanner in tdns-auth
+	cli.AuthCmd.AddCommand(cli.NewScannerCmd("auth"))
+
 	// From ../../v2/cli/debug_cmds.go:
 	cli.AuthCmd.AddCommand(cli.NewDebugCmd("auth"))
 	cli.AgentCmd.AddCommand(cli.NewDebugCmd("agent"))
@@ -0,0 +1,216 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ *
+ * OpenAPI 3.0 document for the /api/v2 management API, generated at startup
+ * from the same route table that registers the handlers (apirouters_v2.go),
+ * so the served document cannot drift from what the daemon actually routes.
+ * Request and response schemas are derived from the Go types by reflection.
+ */
+
+package tdns
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPIDoc is a JSON-ready OpenAPI document. Plain maps rather than a typed
+// model: encoding/json sorts map keys, so the output is deterministic.
+type openAPIDoc map[string]interface{}
+
+var openAPIPathParamRe = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// buildOpenAPIDoc renders the routes into an OpenAPI 3.0 document.
+func buildOpenAPIDoc(app *AppDetails, routes []apiV2Route) openAPIDoc {
+	sg := &openAPISchemaGen{components: map[string]interface{}{}}
+	sg.components["Error"] = sg.schemaFor(reflect.TypeOf(APIv2Error{}))
+
+	paths := map[string]interface{}{}
+	for _, rt := range routes {
+		item, ok := paths[apiV2Prefix+rt.Path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[apiV2Prefix+rt.Path] = item
+		}
+		item[strings.ToLower(rt.Method)] = sg.operation(rt)
+	}
+
+	return openAPIDoc{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       app.Name + " management API",
+			"version":     app.Version,
+			"description": "Resource-oriented management API. Mutations of zone content honour If-Match against the ETag, which is the zone's published SOA serial.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": sg.components,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"apiKey": []string{}}},
+	}
+}
+
+func (sg *openAPISchemaGen) operation(rt apiV2Route) map[string]interface{} {
+	var params []interface{}
+	for _, m := range openAPIPathParamRe.FindAllStringSubmatch(rt.Path, -1) {
+		params = append(params, map[string]interface{}{
+			"name": m[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, q := range rt.Query {
+		params = append(params, map[string]interface{}{
+			"name": q.Name, "in": "query", "description": q.Description, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	if rt.ETag && rt.Method != http.MethodGet {
+		params = append(params, map[string]interface{}{
+			"name": "If-Match", "in": "header", "description": "ETag (zone serial) the change is conditional on",
+			"schema": map[string]interface{}{"type": "string"},
+		})
+	}
+
+	status := rt.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	okResp := map[string]interface{}{"description": http.StatusText(status)}
+	if rt.Response != nil {
+		okResp["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": sg.schemaFor(reflect.TypeOf(rt.Response))},
+		}
+	}
+	if rt.ETag {
+		okResp["headers"] = map[string]interface{}{
+			"ETag": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+		}
+	}
+	errResp := map[string]interface{}{
+		"description": "Error",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"}},
+		},
+	}
+	responses := map[string]interface{}{strconv.Itoa(status): okResp, "default": errResp}
+	if rt.ETag && rt.Method != http.MethodGet {
+		responses["412"] = map[string]interface{}{"description": "If-Match does not match the current ETag"}
+	}
+
+	op := map[string]interface{}{
+		"summary":     rt.Summary,
+		"operationId": rt.operationID(),
+		"tags":        []string{rt.Tag},
+		"responses":   responses,
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if rt.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": sg.schemaFor(reflect.TypeOf(rt.Request))},
+			},
+		}
+	}
+	return op
+}
+
+// operationID derives a stable identifier from method and path, e.g.
+// "put_zones_zone_rrsets_owner_rrtype".
+func (rt apiV2Route) operationID() string {
+	id := strings.NewReplacer("/", "_", "{", "", "}", "", "-", "_").Replace(rt.Path)
+	return strings.ToLower(rt.Method) + id
+}
+
+// openAPISchemaGen maps Go types to JSON schemas. Named struct types become
+// components referenced by $ref, which also terminates recursive types.
+type openAPISchemaGen struct {
+	components map[string]interface{}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (sg *openAPISchemaGen) schemaFor(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if t.Kind() == reflect.Int64 && t.Name() == "Duration" {
+			return map[string]interface{}{"type": "integer", "description": "nanoseconds"}
+		}
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": sg.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": sg.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sg.structSchema(t)
+		}
+		if _, seen := sg.components[t.Name()]; !seen {
+			sg.components[t.Name()] = map[string]interface{}{} // placeholder breaks cycles
+			sg.components[t.Name()] = sg.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		// interface{}, funcs, channels: anything goes.
+		return map[string]interface{}{}
+	}
+}
+
+func (sg *openAPISchemaGen) structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if emb, ok := sg.structSchema(ft)["properties"].(map[string]interface{}); ok {
+					for k, v := range emb {
+						props[k] = v
+					}
+				}
+				continue
+			}
+		}
+		props[name] = sg.schemaFor(f.Type)
+	}
+	return map[string]interface{}{"type": "object", "properties": props}
+}
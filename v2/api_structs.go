@@ -50,6 +50,24 @@ type KeystorePost struct {
 	BulkDnssecKeys []BulkDnssecKey `json:"bulkdnsseckeys,omitempty"`
 	BulkSig0Keys   []BulkSig0Key   `json:"bulksig0keys,omitempty"`
 	BulkTsigKeys   []BulkTsigKey   `json:"bulktsigkeys,omitempty"`
+	// Offline KSK (subcommands "ksr-export" / "skr-import", see ksr.go).
+	KsrPeriods int                `json:"ksrperiods,omitempty"` // number of signing periods
+	KsrPeriod  string             `json:"ksrperiod,omitempty"`  // length of a period, e.g. "10d"
+	Skr        *SignedKeyResponse `json:"skr,omitempty"`
+	// EPP registrar credentials (epp-mgmt, see epp_keystore_mgmt.go).
+	EppName     string `json:"eppname,omitempty"`
+	EppClid     string `json:"eppclid,omitempty"`
+	EppPassword string `json:"epppassword,omitempty"`
+	EppComment  string `json:"eppcomment,omitempty"`
+}
+
+// EppCredentialInfo describes a stored EPP login. The password is never
+// part of it.
+type EppCredentialInfo struct {
+	Name    string `json:"name"`
+	ClID    string `json:"clid"`
+	Created string `json:"created"`
+	Comment string `json:"comment,omitempty"`
 }
 
 type TsigKeyInfo struct {
@@ -84,6 +102,8 @@ type KeystoreResponse struct {
 	TsigKeys   []TsigKeyInfo        `json:"tsigkeys,omitempty"`
 	TsigImport []TsigKeyDisposition `json:"tsigimport,omitempty"`
 	TsigExport *TsigKeyExport       `json:"tsigexport,omitempty"`
+	// EppCredentials is populated by "epp-mgmt list".
+	EppCredentials []EppCredentialInfo `json:"eppcredentials,omitempty"`
 	// Bulk export/import. The Bulk*Keys carry an export's payload (key material
 	// included — that is what an export is for); BulkDispositions reports one
 	// outcome per key offered to an import.
@@ -106,6 +126,8 @@ type KeystoreResponse struct {
 	BulkSig0InvalidateZones []string                   `json:"-"`
 	Algorithms              []algorithms.AlgorithmInfo // populated by the "list-algorithms" command
 	Policies                []DnssecPolicyInfo         // populated by the "list-policies" command
+	Ksr                     *KeySigningRequest         `json:"ksr,omitempty"`        // "ksr-export"
+	SkrBundles              []SkrBundleInfo            `json:"skrbundles,omitempty"` // "skr-list"
 	Msg                     string
 	Error                   bool
 	ErrorMsg                string
@@ -114,6 +136,9 @@ type KeystoreResponse struct {
 	// change the zone's active signing-key set. External-tx callers (APIkeystore)
 	// must call republishSigningKeysForZone after their Commit (R1).
 	NeedsSigningKeysRepublish bool `json:"-"`
+	// OldState is the state a "setstate" found the key in, for the audit
+	// entry APIkeystore writes after the command succeeds.
+	OldState string `json:"-"`
 }
 
 // TsigKeyExport carries a TSIG key's secret back to the caller for the explicit
@@ -272,6 +297,7 @@ type ZoneResponse struct {
 	Zone     string
 	Names    []string
 	Zones    map[string]ZoneConf
+	Lint     []LintIssue `json:",omitempty"` // "lint" command
 	Msg      string
 	Error    bool
 	ErrorMsg string
@@ -347,6 +373,7 @@ type DelegationPost struct {
 	Zone    string
 	Force   bool
 	Outfile string `json:"outfile,omitempty"` // for "export": destination file path
+	All     bool   `json:"all,omitempty"`     // for "queue": include delivered items
 }
 
 type DelegationResponse struct {
@@ -354,6 +381,7 @@ type DelegationResponse struct {
 	Time       time.Time
 	Zone       string
 	SyncStatus DelegationSyncStatus
+	Queue      []DelegationOutboxItem `json:"queue,omitempty"` // "queue"
 	Msg        string
 	Error      bool
 	ErrorMsg   string
@@ -514,20 +542,22 @@ type ScanTuple struct {
 
 // ScanTupleResponse contains the result of scanning a single ScanTuple
 type ScanTupleResponse struct {
-	Qname       string              // The qname that was queried
-	ScanType    ScanType            // The type of scan performed
-	Options     []string            // Options that were used (e.g., "all-ns")
-	NewData     CurrentScanDataJSON // The new data retrieved from the scan (JSON-serializable)
-	DataChanged bool                // Whether the new data differs from the old data (from ScanTuple.CurrentData)
-	AllNSInSync bool                // If "all-ns" option was set, whether all NS were in sync (false if not applicable)
-	DSAdds      []dns.RR            // DS records to add to parent (from CDS→DS conversion)
-	DSRemoves   []dns.RR            // DS records to remove from parent
-	NSAdds      []dns.RR            // NS records to add at child apex (from CSYNC)
-	NSRemoves   []dns.RR            // NS records to remove from child apex (from CSYNC)
-	GlueAdds    []dns.RR            // A/AAAA glue records to add (owner in RR header)
-	GlueRemoves []dns.RR            // A/AAAA glue records to remove
-	Error       bool                // Whether an error occurred
-	ErrorMsg    string              // Error message if Error is true
+	Qname          string              // The qname that was queried
+	ScanType       ScanType            // The type of scan performed
+	Options        []string            // Options that were used (e.g., "all-ns")
+	NewData        CurrentScanDataJSON // The new data retrieved from the scan (JSON-serializable)
+	DataChanged    bool                // Whether the new data differs from the old data (from ScanTuple.CurrentData)
+	AllNSInSync    bool                // If "all-ns" option was set, whether all NS were in sync (false if not applicable)
+	DSAdds         []dns.RR            // DS records to add to parent (from CDS→DS conversion)
+	DSRemoves      []dns.RR            // DS records to remove from parent
+	NSAdds         []dns.RR            // NS records to add at child apex (from CSYNC)
+	NSRemoves      []dns.RR            // NS records to remove from child apex (from CSYNC)
+	GlueAdds       []dns.RR            // A/AAAA glue records to add (owner in RR header)
+	GlueRemoves    []dns.RR            // A/AAAA glue records to remove
+	Decision       string              // CDS acceptance policy verdict: accepted | deferred | rejected ("" without a policy)
+	DecisionReason string              // Why the policy decided as it did
+	Error          bool                // Whether an error occurred
+	ErrorMsg       string              // Error message if Error is true
 }
 
 type ScannerPost struct {
@@ -551,7 +581,9 @@ type ScannerResponse struct {
 // ScanJobStatus represents the status of a scan job
 type ScanJobStatus struct {
 	JobID           string              `json:"job_id"`
-	Status          string              `json:"status"` // "queued", "processing", "completed", "failed"
+	Kind            string              `json:"kind,omitempty"`   // "request" | "notify" | "sweep"
+	Parent          string              `json:"parent,omitempty"` // parent zone, for notify and sweep jobs
+	Status          string              `json:"status"`           // "queued", "processing", "completed", "failed"
 	CreatedAt       time.Time           `json:"created_at"`
 	StartedAt       *time.Time          `json:"started_at,omitempty"`
 	CompletedAt     *time.Time          `json:"completed_at,omitempty"`
@@ -564,6 +596,22 @@ type ScanJobStatus struct {
 	ErrorMsg        string              `json:"error_msg,omitempty"`
 }
 
+// ScanChildState is the latest scan outcome for one child of a parent zone.
+// State is "in-sync", "pending" (a change was seen but not yet accepted),
+// "accepted", "rejected" or "error". AcceptDelay is the time from when the
+// last accepted change was first seen until it was accepted.
+type ScanChildState struct {
+	Parent       string        `json:"parent"`
+	Child        string        `json:"child"`
+	ScanType     string        `json:"scan_type"`
+	State        string        `json:"state"`
+	LastScanned  time.Time     `json:"last_scanned"`
+	PendingSince *time.Time    `json:"pending_since,omitempty"`
+	LastAccepted *time.Time    `json:"last_accepted,omitempty"`
+	AcceptDelay  time.Duration `json:"accept_delay,omitempty"`
+	LastError    string        `json:"last_error,omitempty"`
+}
+
 // CatalogPost represents a request to manage catalog zones
 type CatalogPost struct {
 	Command     string   `json:"command"`      // "create" | "zone-add" | "zone-delete" | "zone-list" | "group-add" | "group-delete" | "group-list" | "zone-group-add" | "zone-group-delete" | "notify-add" | "notify-remove" | "notify-list"
@@ -584,3 +632,187 @@ type CatalogResponse struct {
 	Groups          []string               `json:"groups,omitempty"`           // For group-list command
 	NotifyAddresses []string               `json:"notify_addresses,omitempty"` // For notify-list command
 }
+
+// AuditResponse is the reply to GET /api/v1/audit.
+type AuditResponse struct {
+	AppName  string       `json:"appname"`
+	Time     time.Time    `json:"time"`
+	Entries  []AuditEntry `json:"entries,omitempty"`
+	Error    bool         `json:"error,omitempty"`
+	ErrorMsg string       `json:"error_msg,omitempty"`
+}
+
+// ChangeSetPost is the request to POST /api/v1/zone/changeset. Command is
+// one of create, list, show, diff, validate, approve, schedule, apply,
+// rollback or delete. Name selects the change set within Zone.
+type ChangeSetPost struct {
+	Command    string        `json:"command"`
+	Zone       string        `json:"zone"`
+	Name       string        `json:"name,omitempty"`
+	Comment    string        `json:"comment,omitempty"`
+	Ops        []ChangeSetOp `json:"ops,omitempty"`
+	ActivateAt time.Time     `json:"activate_at,omitempty"` // approve, schedule; zero = apply manually
+	By         string        `json:"by,omitempty"`          // free-text identity of the operator
+	Force      bool          `json:"force,omitempty"`       // rollback over later changes
+}
+
+type ChangeSetResponse struct {
+	AppName    string           `json:"appname"`
+	Time       time.Time        `json:"time"`
+	Msg        string           `json:"msg,omitempty"`
+	ChangeSets []ChangeSet      `json:"changesets,omitempty"`
+	Diff       []ChangeSetDiff  `json:"diff,omitempty"`
+	Issues     []ChangeSetIssue `json:"issues,omitempty"`
+	Serial     uint32           `json:"serial,omitempty"`
+	Error      bool             `json:"error,omitempty"`
+	ErrorMsg   string           `json:"error_msg,omitempty"`
+}
+
+// --- /api/v2 resource types (see apirouters_v2.go) ---
+
+// APIv2Error is the body of every non-2xx /api/v2 response.
+type APIv2Error struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// APIv2Result is returned by /api/v2 operations that do not return a
+// resource: zone actions, policy changes, deletions.
+type APIv2Result struct {
+	Message string `json:"message,omitempty"`
+	Serial  uint32 `json:"serial,omitempty"`
+}
+
+// APIv2Peer is an upstream primary: an address plus the TSIG key (or NOKEY).
+type APIv2Peer struct {
+	Addr string `json:"addr"`
+	Key  string `json:"key,omitempty"`
+}
+
+// APIv2Zone is the zone resource. Serial is the published SOA serial and is
+// also the zone's ETag.
+type APIv2Zone struct {
+	Name             string      `json:"name"`
+	Type             string      `json:"type"`
+	Store            string      `json:"store"`
+	Serial           uint32      `json:"serial"`
+	IncomingSerial   uint32      `json:"incoming_serial,omitempty"`
+	Provisioning     string      `json:"provisioning,omitempty"`
+	Options          []string    `json:"options,omitempty"`
+	Frozen           bool        `json:"frozen"`
+	Dirty            bool        `json:"dirty"`
+	ApiManaged       bool        `json:"api_managed"`
+	SourceCatalog    string      `json:"source_catalog,omitempty"`
+	Template         string      `json:"template,omitempty"`
+	Primaries        []APIv2Peer `json:"primaries,omitempty"`
+	DnssecPolicy     string      `json:"dnssec_policy,omitempty"`
+	PolicyOverridden bool        `json:"dnssec_policy_overridden,omitempty"`
+	Error            string      `json:"error,omitempty"`
+}
+
+// APIv2ZoneList is one page of zones.
+type APIv2ZoneList struct {
+	Items         []APIv2Zone `json:"items"`
+	NextPageToken string      `json:"next_page_token,omitempty"`
+}
+
+// APIv2ZoneCreate is the body of POST /api/v2/zones. Type is "secondary"
+// (default) or "primary"; a primary requires Template.
+type APIv2ZoneCreate struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type,omitempty"`
+	Template   string      `json:"template,omitempty"`
+	Primaries  []APIv2Peer `json:"primaries,omitempty"`
+	Options    []string    `json:"options,omitempty"`
+	TsigName   string      `json:"tsig_name,omitempty"`
+	TsigSecret string      `json:"tsig_secret,omitempty"`
+	TsigAlgo   string      `json:"tsig_algo,omitempty"`
+}
+
+// APIv2ZoneModify is the body of PATCH /api/v2/zones/{zone}: the same
+// fields `zone modify` takes for an API-managed secondary.
+type APIv2ZoneModify struct {
+	Primaries  []APIv2Peer `json:"primaries,omitempty"`
+	Options    []string    `json:"options,omitempty"`
+	TsigName   string      `json:"tsig_name,omitempty"`
+	TsigSecret string      `json:"tsig_secret,omitempty"`
+	TsigAlgo   string      `json:"tsig_algo,omitempty"`
+}
+
+// APIv2ZoneAction is the optional body of POST /api/v2/zones/{zone}/actions/{action}.
+type APIv2ZoneAction struct {
+	Force   bool   `json:"force,omitempty"`
+	Wait    bool   `json:"wait,omitempty"`    // reload: wait for the refresh to finish
+	Timeout string `json:"timeout,omitempty"` // reload: how long to wait
+}
+
+// APIv2RRset is one RRset in presentation format.
+type APIv2RRset struct {
+	Owner  string   `json:"owner"`
+	Type   string   `json:"type"`
+	TTL    uint32   `json:"ttl"`
+	RRs    []string `json:"rrs"`
+	RRSIGs []string `json:"rrsigs,omitempty"`
+}
+
+// APIv2RRsetList is one page of RRsets. Pages are cut on owner-name
+// boundaries, so all RRsets of an owner are on the same page.
+type APIv2RRsetList struct {
+	Serial        uint32       `json:"serial"`
+	Items         []APIv2RRset `json:"items"`
+	NextPageToken string       `json:"next_page_token,omitempty"`
+}
+
+// APIv2RRsetPut is the body of PUT .../rrsets/{owner}/{rrtype}: the complete
+// new content of the RRset, one RR per string. TTLs are set by the zone's
+// update-policy, as for DNS UPDATE.
+type APIv2RRsetPut struct {
+	RRs []string `json:"rrs"`
+}
+
+// APIv2Key is a DNSSEC key of a zone (public part only).
+type APIv2Key struct {
+	Zone        string     `json:"zone"`
+	KeyID       uint16     `json:"keyid"`
+	Algorithm   string     `json:"algorithm"`
+	Flags       uint16     `json:"flags"`
+	Role        string     `json:"role"` // KSK | ZSK
+	State       string     `json:"state"`
+	DNSKEY      string     `json:"dnskey"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	ActiveAt    *time.Time `json:"active_at,omitempty"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+}
+
+// APIv2KeyList is the list of a zone's DNSSEC keys.
+type APIv2KeyList struct {
+	Items []APIv2Key `json:"items"`
+}
+
+// APIv2ZonePolicy is the DNSSEC policy binding of a zone. On PUT, Mode is
+// "set" (default; same-algorithm rebind) or "change" (gradual algorithm roll).
+type APIv2ZonePolicy struct {
+	Policy     string `json:"policy"`
+	Overridden bool   `json:"overridden,omitempty"`
+	ConfigBase string `json:"config_base,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+}
+
+// APIv2PolicyList is one page of DNSSEC policies.
+type APIv2PolicyList struct {
+	Items         []DnssecPolicyInfo `json:"items"`
+	NextPageToken string             `json:"next_page_token,omitempty"`
+}
+
+// APIv2CatalogMember is a member zone of a catalog zone.
+type APIv2CatalogMember struct {
+	Zone         string    `json:"zone"`
+	Groups       []string  `json:"groups,omitempty"`
+	DiscoveredAt time.Time `json:"discovered_at,omitempty"`
+}
+
+// APIv2CatalogMemberList is one page of catalog members.
+type APIv2CatalogMemberList struct {
+	Items         []APIv2CatalogMember `json:"items"`
+	NextPageToken string               `json:"next_page_token,omitempty"`
+}
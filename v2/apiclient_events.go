@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package tdns
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// EventDropped is the pseudo event type the server sends when a slow client
+// lost events; Data["count"] says how many.
+const EventDropped = "dropped"
+
+// FollowEvents opens the daemon's /events stream and calls fn for every event
+// until the stream ends, ctx is cancelled or fn returns an error. *lastID is
+// sent as Last-Event-ID and updated as events arrive, so calling FollowEvents
+// again after a disconnect resumes where the previous stream stopped.
+func (api *ApiClient) FollowEvents(ctx context.Context, q url.Values, lastID *uint64, fn func(Event) error) error {
+	if api == nil {
+		return fmt.Errorf("api client is nil")
+	}
+	baseURL, err := url.Parse(api.BaseUrl)
+	if err != nil {
+		return fmt.Errorf("failed to parse base URL: %v", err)
+	}
+	if len(api.Addresses) > 0 {
+		baseURL.Host = api.Addresses[0]
+	}
+	endpoint := baseURL.String() + "/events"
+	if len(q) > 0 {
+		endpoint += "?" + q.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastID != nil && *lastID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(*lastID, 10))
+	}
+	switch api.AuthMethod {
+	case "X-API-Key":
+		req.Header.Add("X-API-Key", api.apiKey)
+	case "Authorization":
+		req.Header.Add("Authorization", fmt.Sprintf("token %s", api.apiKey))
+	}
+	api.UrlReportNG(http.MethodGet, endpoint, nil)
+
+	resp, err := api.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("API GET %s returned HTTP %d: %s", endpoint, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return readSSE(resp.Body, lastID, fn)
+}
+
+// readSSE parses a text/event-stream body. Only the fields the daemon emits
+// (id, event, data) are interpreted; comments are skipped.
+func readSSE(r io.Reader, lastID *uint64, fn func(Event) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var typ string
+	var data strings.Builder
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				typ = ""
+				continue
+			}
+			var ev Event
+			if typ == EventDropped {
+				var d map[string]interface{}
+				if err := decodeEventJSON(data.String(), &d); err != nil {
+					return fmt.Errorf("bad dropped event: %v", err)
+				}
+				ev = Event{Type: EventDropped, Data: d}
+			} else if err := decodeEventJSON(data.String(), &ev); err != nil {
+				return fmt.Errorf("bad event %q: %v", typ, err)
+			}
+			typ = ""
+			data.Reset()
+			if lastID != nil && ev.ID > *lastID {
+				*lastID = ev.ID
+			}
+			if err := fn(ev); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// comment / keepalive
+		case strings.HasPrefix(line, "event:"):
+			typ = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return sc.Err()
+}
+
+// decodeEventJSON keeps payload numbers as json.Number so serials and key
+// ids print as written rather than as float64.
+func decodeEventJSON(s string, v interface{}) error {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package tdns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIaudit handles GET /api/v1/audit?zone=&actor=&since=&until=&limit=.
+// since/until are RFC3339 timestamps; actor is an actor type ("api") or
+// type:name ("tsig:ddns-key."). Read-only; entries are returned newest first.
+func APIaudit(conf *Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		resp := AuditResponse{
+			AppName: Globals.App.Name,
+			Time:    time.Now(),
+		}
+		defer func() {
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				lgApi.Error("json encode failed", "handler", "audit", "err", err)
+			}
+		}()
+
+		f, err := parseAuditFilter(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+			return
+		}
+		kdb := conf.Internal.KeyDB
+		if kdb == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			resp.Error = true
+			resp.ErrorMsg = "keystore not initialized"
+			return
+		}
+		resp.Entries, err = kdb.QueryAuditLog(f)
+		if err != nil {
+			lgApi.Warn("audit: QueryAuditLog failed", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
+	}
+}
+
+func parseAuditFilter(r *http.Request) (AuditFilter, error) {
+	q := r.URL.Query()
+	f := AuditFilter{
+		Zone:  strings.TrimSpace(q.Get("zone")),
+		Actor: strings.TrimSpace(q.Get("actor")),
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		v := strings.TrimSpace(q.Get(p.name))
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, fmt.Errorf("bad %s parameter %q: %v", p.name, v, err)
+		}
+		*p.dst = t
+	}
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, fmt.Errorf("bad limit parameter %q", v)
+		}
+		f.Limit = n
+	}
+	return f, nil
+}
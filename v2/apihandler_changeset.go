@@ -0,0 +1,232 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package tdns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// APIchangeset handles POST /api/v1/zone/changeset (see ChangeSetPost).
+func APIchangeset(conf *Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := ChangeSetResponse{
+			AppName: Globals.App.Name,
+			Time:    time.Now(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		defer func() {
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				lgApi.Error("json encode failed", "handler", "changeset", "err", err)
+			}
+		}()
+
+		var cp ChangeSetPost
+		if err := json.NewDecoder(r.Body).Decode(&cp); err != nil {
+			resp.Error = true
+			resp.ErrorMsg = fmt.Sprintf("error decoding changeset post: %v", err)
+			return
+		}
+		if err := runChangeSetCommand(conf.Internal.KeyDB, r, cp, &resp); err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
+	}
+}
+
+func runChangeSetCommand(kdb *KeyDB, r *http.Request, cp ChangeSetPost, resp *ChangeSetResponse) error {
+	if kdb == nil {
+		return fmt.Errorf("keystore not initialized")
+	}
+	actor := apiAuditActor(r)
+	if cp.By != "" {
+		actor.Name = cp.By + "/" + actor.Name
+	}
+
+	if cp.Command == "list" {
+		sets, err := kdb.ListChangeSets(cp.Zone)
+		if err != nil {
+			return err
+		}
+		resp.ChangeSets = sets
+		resp.Msg = fmt.Sprintf("%d change set(s)", len(sets))
+		return nil
+	}
+
+	if cp.Zone == "" || cp.Name == "" {
+		return fmt.Errorf("zone and change set name are required")
+	}
+	zone := dns.Fqdn(cp.Zone)
+	zd, ok := Zones.Get(zone)
+	if !ok {
+		return fmt.Errorf("zone %s is unknown", zone)
+	}
+
+	if cp.Command == "create" {
+		if strings.ContainsAny(cp.Name, " \t/") {
+			return fmt.Errorf("change set name %q must not contain whitespace or '/'", cp.Name)
+		}
+		ops, err := normalizeChangeSetOps(zone, cp.Ops)
+		if err != nil {
+			return err
+		}
+		if resp.Diff, resp.Issues, err = zd.PreviewChangeSet(ops); err != nil {
+			return err
+		}
+		cs := &ChangeSet{Zone: zone, Name: cp.Name, State: ChangeSetDraft, Comment: cp.Comment, Ops: ops, CreatedBy: actor.String()}
+		if err := kdb.CreateChangeSet(cs); err != nil {
+			return err
+		}
+		resp.ChangeSets = []ChangeSet{*cs}
+		resp.Msg = fmt.Sprintf("change set %q created for zone %s (%d operation(s), %d RRset(s) affected)", cs.Name, zone, len(ops), len(resp.Diff))
+		return nil
+	}
+
+	cs, exists, err := kdb.GetChangeSet(zone, cp.Name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("zone %s has no change set %q", zone, cp.Name)
+	}
+	pending := cs.State == ChangeSetDraft || cs.State == ChangeSetApproved || cs.State == ChangeSetFailed
+
+	preview := func() error {
+		if !pending {
+			resp.Diff = changeSetImageDiffs(cs.Before, cs.After)
+			return nil
+		}
+		var err error
+		resp.Diff, resp.Issues, err = zd.PreviewChangeSet(cs.Ops)
+		return err
+	}
+
+	switch cp.Command {
+	case "show", "diff", "validate":
+		if err := preview(); err != nil {
+			return err
+		}
+		switch cp.Command {
+		case "show":
+			resp.ChangeSets = []ChangeSet{*cs}
+		case "diff":
+			resp.Issues = nil
+		case "validate":
+			resp.Diff = nil
+			if !pending {
+				return fmt.Errorf("change set %q is %s; nothing to validate", cs.Name, cs.State)
+			}
+			if changeSetHasErrors(resp.Issues) {
+				return fmt.Errorf("change set %q does not validate against zone %s", cs.Name, zone)
+			}
+			resp.Msg = fmt.Sprintf("change set %q validates against zone %s serial %d", cs.Name, zone, zd.publishedSerial())
+		}
+		return nil
+
+	case "approve":
+		if cs.State != ChangeSetDraft && cs.State != ChangeSetFailed {
+			return fmt.Errorf("change set %q is %s; only a draft or failed change set can be approved", cs.Name, cs.State)
+		}
+		// The serial is only recorded for the operator; activation checks
+		// the approved diff against the RRsets it touches.
+		serial := zd.publishedSerial()
+		if err := preview(); err != nil {
+			return err
+		}
+		if changeSetHasErrors(resp.Issues) {
+			return fmt.Errorf("change set %q does not validate; not approved", cs.Name)
+		}
+		cs.State = ChangeSetApproved
+		cs.ApprovedBy = actor.String()
+		cs.ApprovedAt = time.Now().UTC()
+		cs.ApprovedSerial = serial
+		cs.ApprovedDiff = resp.Diff
+		cs.ActivateAt = cp.ActivateAt
+		cs.Error = ""
+		if err := kdb.SaveChangeSet(cs); err != nil {
+			return err
+		}
+		resp.ChangeSets = []ChangeSet{*cs}
+		resp.Msg = fmt.Sprintf("change set %q approved against serial %d; %s", cs.Name, cs.ApprovedSerial, changeSetActivationText(cs))
+		return nil
+
+	case "schedule":
+		if cs.State != ChangeSetApproved {
+			return fmt.Errorf("change set %q is %s; only an approved change set can be scheduled", cs.Name, cs.State)
+		}
+		cs.ActivateAt = cp.ActivateAt
+		if err := kdb.SaveChangeSet(cs); err != nil {
+			return err
+		}
+		resp.ChangeSets = []ChangeSet{*cs}
+		resp.Msg = fmt.Sprintf("change set %q: %s", cs.Name, changeSetActivationText(cs))
+		return nil
+
+	case "apply":
+		res, err := kdb.ActivateChangeSet(cs, actor)
+		resp.Issues = res.Issues
+		if err != nil {
+			return err
+		}
+		resp.Serial = res.Serial
+		resp.Diff = changeSetImageDiffs(res.Before, res.After)
+		resp.Msg = fmt.Sprintf("change set %q applied to zone %s; published serial %d", cs.Name, zone, res.Serial)
+		return nil
+
+	case "rollback":
+		res, err := kdb.RevertChangeSet(cs, cp.Force, actor)
+		if err != nil {
+			return err
+		}
+		resp.Serial = res.Serial
+		resp.Diff = changeSetImageDiffs(res.Before, res.After)
+		resp.Msg = fmt.Sprintf("change set %q rolled back in zone %s; published serial %d", cs.Name, zone, res.Serial)
+		return nil
+
+	case "delete":
+		if cs.State == ChangeSetApplied {
+			return fmt.Errorf("change set %q is applied; roll it back before deleting it", cs.Name)
+		}
+		if err := kdb.DeleteChangeSet(cs.ID); err != nil {
+			return err
+		}
+		resp.Msg = fmt.Sprintf("change set %q deleted", cs.Name)
+		return nil
+	}
+	return fmt.Errorf("unknown changeset command: %q", cp.Command)
+}
+
+func changeSetActivationText(cs *ChangeSet) string {
+	if cs.ActivateAt.IsZero() {
+		return "not scheduled (apply manually)"
+	}
+	return "scheduled for " + cs.ActivateAt.UTC().Format(time.RFC3339)
+}
+
+// changeSetImageDiffs renders recorded before/after images as diffs.
+func changeSetImageDiffs(before, after []ChangeSetRRset) []ChangeSetDiff {
+	var out []ChangeSetDiff
+	for i := range after {
+		ch := &csRRsetChange{owner: after[i].Owner, rrtype: dns.StringToType[after[i].Type]}
+		if i < len(before) {
+			for _, s := range before[i].RRs {
+				if rr, err := dns.NewRR(s); err == nil {
+					ch.old = append(ch.old, rr)
+				}
+			}
+		}
+		for _, s := range after[i].RRs {
+			if rr, err := dns.NewRR(s); err == nil {
+				ch.new = append(ch.new, rr)
+			}
+		}
+		out = append(out, ch.diff())
+	}
+	return out
+}
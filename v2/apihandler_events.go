@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package tdns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// eventHeartbeat keeps idle streams (and any proxy in between) from timing
+// out; SSE comment lines are ignored by clients.
+const eventHeartbeat = 15 * time.Second
+
+// APIevents handles GET /api/v1/events?type=&zone= as a server-sent event
+// stream. type and zone may be repeated or comma-separated; a type matches
+// itself and every dotted sub-type ("zone" selects zone.*). A reconnecting
+// client sends Last-Event-ID (or ?last_event_id=) to replay what it missed
+// from the bus backlog. Events a slow client could not keep up with are
+// reported as a "dropped" event carrying the count.
+func APIevents(conf *Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		f, after, err := parseEventRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sub, replay := Events.Subscribe(f, after)
+		defer Events.Unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, ": %s event stream\n\n", Globals.App.Name)
+		for _, ev := range replay {
+			if err := writeSSEEvent(w, ev); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+		lgApi.Debug("events: client subscribed", "remote", r.RemoteAddr, "types", f.Types, "zones", f.Zones, "replayed", len(replay))
+
+		heartbeat := time.NewTicker(eventHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				lgApi.Debug("events: client went away", "remote", r.RemoteAddr)
+				return
+			case ev := <-sub.C:
+				if n := sub.Dropped(); n > 0 {
+					fmt.Fprintf(w, "event: dropped\ndata: {\"count\":%d}\n\n", n)
+				}
+				if err := writeSSEEvent(w, ev); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func writeSSEEvent(w http.ResponseWriter, ev Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		lgApi.Error("events: json encode failed", "type", ev.Type, "err", err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+	return err
+}
+
+func parseEventRequest(r *http.Request) (EventFilter, uint64, error) {
+	q := r.URL.Query()
+	var f EventFilter
+	for _, v := range q["type"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				f.Types = append(f.Types, t)
+			}
+		}
+	}
+	for _, v := range q["zone"] {
+		for _, z := range strings.Split(v, ",") {
+			if z = strings.TrimSpace(z); z != "" {
+				f.Zones = append(f.Zones, dns.Fqdn(z))
+			}
+		}
+	}
+
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = q.Get("last_event_id")
+	}
+	var after uint64
+	if last != "" {
+		n, err := strconv.ParseUint(last, 10, 64)
+		if err != nil {
+			return f, 0, fmt.Errorf("invalid last event id %q", last)
+		}
+		after = n
+	}
+	return f, after, nil
+}
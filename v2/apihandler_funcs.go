@@ -201,7 +201,7 @@ func (kdb *KeyDB) APIkeystore(conf *Config) func(w http.ResponseWriter, r *http.
 			}
 			// Re-sign after state-changing ops — deferred until post-commit
 			// (alongside snapshot republish) so the resigner does not race the tx.
-			if err == nil && (kp.SubCommand == "rollover" || kp.SubCommand == "delete" || kp.SubCommand == "setstate" || kp.SubCommand == "clear" || kp.SubCommand == "policy-cleanup") {
+			if err == nil && (kp.SubCommand == "rollover" || kp.SubCommand == "delete" || kp.SubCommand == "setstate" || kp.SubCommand == "clear" || kp.SubCommand == "policy-cleanup" || kp.SubCommand == "skr-import") {
 				dnssecResignZone = kp.Zone
 			}
 
@@ -218,6 +218,16 @@ func (kdb *KeyDB) APIkeystore(conf *Config) func(w http.ResponseWriter, r *http.
 				tsigCacheDelta = resp.TsigCacheDelta
 			}
 
+		case "epp-mgmt":
+			resp, err = kdb.EppKeyMgmt(tx, kp)
+			if err != nil {
+				if resp == nil {
+					resp = &KeystoreResponse{Time: time.Now()}
+				}
+				resp.Error = true
+				resp.ErrorMsg = err.Error()
+			}
+
 		case "list-algorithms":
 			// Read-only: report the algorithms this server actually
 			// supports, so the CLI can resolve names to codepoints
@@ -249,7 +259,58 @@ func (kdb *KeyDB) APIkeystore(conf *Config) func(w http.ResponseWriter, r *http.
 				ErrorMsg: fmt.Sprintf("Unknown command: %s", kp.Command),
 			}
 		}
+
+		// Journal successful mutations in the same transaction, so the
+		// audit row commits exactly when the keystore change does.
+		if err == nil && resp != nil && !resp.Error && keystorePostMutates(kp) {
+			if aerr := recordAuditTx(tx, AuditEntry{
+				Actor:    apiAuditActor(r),
+				Source:   AuditSourceKeystore,
+				Zone:     kp.Zone,
+				Action:   kp.Command + " " + kp.SubCommand,
+				KeyID:    kp.Keyid,
+				OldState: resp.OldState,
+				NewState: kp.State,
+				Detail:   keystorePostAuditDetail(kp),
+			}); aerr != nil {
+				lgAudit.Error("failed to record keystore mutation", "cmd", kp.Command, "subcmd", kp.SubCommand, "err", aerr)
+			}
+		}
+	}
+}
+
+// keystorePostMutates reports whether a /keystore request changes keystore
+// content and therefore belongs in the audit journal.
+func keystorePostMutates(kp KeystorePost) bool {
+	switch kp.Command {
+	case "sig0-mgmt", "dnssec-mgmt", "tsig-mgmt", "epp-mgmt":
+	default:
+		return false
+	}
+	switch kp.SubCommand {
+	case "list", "export", "bulk-export", "skr-list":
+		return false
+	case "purge":
+		return kp.Force // without --force purge is a dry run
+	}
+	return true
+}
+
+// keystorePostAuditDetail names the key(s) a /keystore mutation touched, for
+// subcommands that do not address a single zone/keyid pair.
+func keystorePostAuditDetail(kp KeystorePost) string {
+	switch {
+	case kp.TsigKeyname != "":
+		return "tsig-key=" + kp.TsigKeyname
+	case kp.EppName != "":
+		return "epp-credential=" + kp.EppName
+	case len(kp.BulkDnssecKeys)+len(kp.BulkSig0Keys)+len(kp.BulkTsigKeys) > 0:
+		return fmt.Sprintf("bulk: %d dnssec, %d sig0, %d tsig keys",
+			len(kp.BulkDnssecKeys), len(kp.BulkSig0Keys), len(kp.BulkTsigKeys))
+	case kp.Keyname != "":
+		return "keyname=" + kp.Keyname
 	}
+	return ""
 }
 
 func (kdb *KeyDB) APItruststore() func(w http.ResponseWriter, r *http.Request) {
@@ -571,6 +632,32 @@ func APIdelegation(delsyncq chan DelegationSyncRequest) func(w http.ResponseWrit
 			}
 			resp.Msg = fmt.Sprintf("Delegation data for %s exported to %s", dp.Zone, dp.Outfile)
 
+		// Outbox of a backend that delivers to a remote system (e.g. epp)
+		case "queue", "retry":
+			qb, ok := zd.DelegationBackend.(QueuedDelegationBackend)
+			if !ok {
+				resp.Error = true
+				resp.ErrorMsg = fmt.Sprintf("zone %s has no queued delegation backend", dp.Zone)
+				return
+			}
+			if dp.Command == "retry" {
+				n, err := qb.Retry(dp.Zone)
+				if err != nil {
+					resp.Error = true
+					resp.ErrorMsg = err.Error()
+					return
+				}
+				resp.Msg = fmt.Sprintf("%d failed item(s) requeued on backend %s", n, qb.Name())
+				return
+			}
+			resp.Queue, err = qb.Queue(dp.Zone, dp.All)
+			if err != nil {
+				resp.Error = true
+				resp.ErrorMsg = err.Error()
+				return
+			}
+			resp.Msg = fmt.Sprintf("%d item(s) in the outbox of backend %s", len(resp.Queue), qb.Name())
+
 		default:
 			resp.ErrorMsg = fmt.Sprintf("Unknown delegation command: %s", dp.Command)
 			resp.Error = true
@@ -829,9 +916,13 @@ func deepCopyScanJobStatus(src *ScanJobStatus) *ScanJobStatus {
 
 	dst := &ScanJobStatus{
 		JobID:           src.JobID,
+		Kind:            src.Kind,
+		Parent:          src.Parent,
 		Status:          src.Status,
 		CreatedAt:       src.CreatedAt,
 		TotalTuples:     src.TotalTuples,
+		IgnoredTuples:   src.IgnoredTuples,
+		ErrorTuples:     src.ErrorTuples,
 		ProcessedTuples: src.ProcessedTuples,
 		Error:           src.Error,
 		ErrorMsg:        src.ErrorMsg,
@@ -861,12 +952,14 @@ func deepCopyScanJobStatus(src *ScanJobStatus) *ScanJobStatus {
 // deepCopyScanTupleResponse creates a deep copy of a ScanTupleResponse
 func deepCopyScanTupleResponse(src ScanTupleResponse) ScanTupleResponse {
 	dst := ScanTupleResponse{
-		Qname:       src.Qname,
-		ScanType:    src.ScanType,
-		DataChanged: src.DataChanged,
-		AllNSInSync: src.AllNSInSync,
-		Error:       src.Error,
-		ErrorMsg:    src.ErrorMsg,
+		Qname:          src.Qname,
+		ScanType:       src.ScanType,
+		DataChanged:    src.DataChanged,
+		AllNSInSync:    src.AllNSInSync,
+		Decision:       src.Decision,
+		DecisionReason: src.DecisionReason,
+		Error:          src.Error,
+		ErrorMsg:       src.ErrorMsg,
 	}
 
 	// Deep copy Options slice
@@ -972,6 +1065,32 @@ func APIscannerStatus(conf *Config) func(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// APIscannerChildren handles GET requests for the per-child scan state of a
+// parent zone (all parent zones without ?parent=).
+func APIscannerChildren(conf *Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if conf.Internal.Scanner == nil || conf.Internal.Scanner.kdb == nil {
+			http.Error(w, "Scanner not initialized or without keystore", http.StatusServiceUnavailable)
+			return
+		}
+		parent := r.URL.Query().Get("parent")
+		if parent != "" {
+			parent = dns.Fqdn(parent)
+		}
+		states, err := listScanChildStates(conf.Internal.Scanner.kdb, parent)
+		if err != nil {
+			lgApi.Error("listing scanner child state failed", "parent", parent, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if states == nil {
+			states = []ScanChildState{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(states)
+	}
+}
+
 // APIscannerDelete handles DELETE requests for scan job deletion
 func APIscannerDelete(conf *Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -995,6 +1114,11 @@ func APIscannerDelete(conf *Config) func(w http.ResponseWriter, r *http.Request)
 			// Delete all jobs
 			count := len(conf.Internal.Scanner.Jobs)
 			conf.Internal.Scanner.Jobs = make(map[string]*ScanJobStatus)
+			if kdb := conf.Internal.Scanner.kdb; kdb != nil {
+				if err := deleteScanJob(kdb, ""); err != nil {
+					lgApi.Error("deleting scanner job history failed", "err", err)
+				}
+			}
 			lgApi.Info("deleted all scanner jobs", "count", count)
 			resp.Msg = fmt.Sprintf("Deleted all %d jobs", count)
 			resp.Status = "success"
@@ -1007,6 +1131,11 @@ func APIscannerDelete(conf *Config) func(w http.ResponseWriter, r *http.Request)
 				return
 			}
 			delete(conf.Internal.Scanner.Jobs, jobID)
+			if kdb := conf.Internal.Scanner.kdb; kdb != nil {
+				if err := deleteScanJob(kdb, jobID); err != nil {
+					lgApi.Error("deleting scanner job failed", "jobID", jobID, "err", err)
+				}
+			}
 			lgApi.Info("deleted scanner job", "jobID", jobID)
 			resp.Msg = fmt.Sprintf("Deleted job %s", jobID)
 			resp.Status = "success"
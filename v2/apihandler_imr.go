@@ -162,6 +162,35 @@ func (conf *Config) APIimr() func(w http.ResponseWriter, r *http.Request) {
 			resp.Data = entry
 			resp.Msg = fmt.Sprintf("Cache entry for %s %s", qname, dns.TypeToString[qtype])
 
+		case "imr-explain":
+			imr := Globals.ImrEngine
+			if imr == nil || imr.Cache == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			qname, _ := amp.Data["qname"].(string)
+			qtypeStr, _ := amp.Data["qtype"].(string)
+			if qname == "" || qtypeStr == "" {
+				resp.Error = true
+				resp.ErrorMsg = "qname and qtype are required"
+				return
+			}
+			qtype, ok := dns.StringToType[strings.ToUpper(qtypeStr)]
+			if !ok {
+				resp.Error = true
+				resp.ErrorMsg = fmt.Sprintf("unknown RR type: %s", qtypeStr)
+				return
+			}
+			report, err := imr.Explain(r.Context(), qname, qtype)
+			if err != nil {
+				resp.Error = true
+				resp.ErrorMsg = err.Error()
+				return
+			}
+			resp.Data = report
+			resp.Msg = fmt.Sprintf("%s %s: %s, %s (%d validation steps)", report.Qname, report.Qtype, report.Rcode, report.State, len(report.Steps))
+
 		case "imr-flush":
 			imr := Globals.ImrEngine
 			if imr == nil || imr.Cache == nil {
@@ -267,12 +296,211 @@ func (conf *Config) APIimr() func(w http.ResponseWriter, r *http.Request) {
 					"retry_after_failure": t.Discovery.RetryAfterFailure.String(),
 					"max_failures":        t.Discovery.MaxFailures,
 				},
+				"prefetch": map[string]interface{}{
+					"disable":   t.Prefetch.Disable,
+					"threshold": t.Prefetch.Threshold,
+					"min_ttl":   t.Prefetch.MinTTL.String(),
+				},
+				"cache": map[string]interface{}{
+					"positive_mb": t.Cache.PositiveMB,
+					"negative_mb": t.Cache.NegativeMB,
+					"infra_mb":    t.Cache.InfraMB,
+				},
 				"query_budget":                t.QueryBudget.String(),
 				"upgrade_indirect_cache_hits": upgradeStr,
 			}
 			resp.Data = data
 			resp.Msg = "IMR tuning snapshot"
 
+		case "imr-cache-stats":
+			imr := Globals.ImrEngine
+			if imr == nil || imr.Cache == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			stats := imr.CacheStats()
+			resp.Data = stats
+			resp.Msg = fmt.Sprintf("%d cached RRsets, %d hits, %d misses; %d queries coalesced, %d prefetched (%d failed)",
+				stats.Entries, stats.Hits, stats.Misses, stats.Coalesced, stats.Prefetched, stats.PrefetchFailed)
+
+		case "imr-show-ta":
+			imr := Globals.ImrEngine
+			if imr == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			tas := imr.TrustAnchors()
+			resp.Data = tas
+			if imr.taTracker != nil {
+				resp.Msg = fmt.Sprintf("%d trust anchor zones, RFC 5011 state in %s", len(tas), imr.taTracker.stateFile)
+			} else {
+				resp.Msg = fmt.Sprintf("%d trust anchor zones, RFC 5011 maintenance off", len(tas))
+			}
+
+		case "imr-nta-add":
+			imr := Globals.ImrEngine
+			if imr == nil || imr.Cache == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			zone, _ := amp.Data["zone"].(string)
+			if zone == "" {
+				resp.Error = true
+				resp.ErrorMsg = "zone is required"
+				return
+			}
+			var lifetime time.Duration
+			if s, _ := amp.Data["lifetime"].(string); s != "" {
+				d, err := time.ParseDuration(s)
+				if err != nil {
+					resp.Error = true
+					resp.ErrorMsg = fmt.Sprintf("invalid lifetime %q: %v", s, err)
+					return
+				}
+				lifetime = d
+			}
+			reason, _ := amp.Data["reason"].(string)
+			nta, err := imr.AddNTA(zone, lifetime, reason)
+			if err != nil {
+				resp.Error = true
+				resp.ErrorMsg = err.Error()
+				return
+			}
+			resp.Data = []cache.NTA{*nta}
+			resp.Msg = fmt.Sprintf("Negative trust anchor for %s added, expires %s", nta.Zone, nta.Expires.Format(time.RFC3339))
+
+		case "imr-nta-remove":
+			imr := Globals.ImrEngine
+			if imr == nil || imr.Cache == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			zone, _ := amp.Data["zone"].(string)
+			if zone == "" {
+				resp.Error = true
+				resp.ErrorMsg = "zone is required"
+				return
+			}
+			if err := imr.RemoveNTA(zone); err != nil {
+				resp.Error = true
+				resp.ErrorMsg = err.Error()
+				return
+			}
+			resp.Msg = fmt.Sprintf("Negative trust anchor for %s removed", dns.Fqdn(zone))
+
+		case "imr-nta-list":
+			imr := Globals.ImrEngine
+			if imr == nil || imr.Cache == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			ntas := imr.NTAs()
+			resp.Data = ntas
+			resp.Msg = fmt.Sprintf("%d negative trust anchors", len(ntas))
+
+		case "imr-local-zone-add":
+			imr := Globals.ImrEngine
+			if imr == nil || imr.Cache == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			zone, _ := amp.Data["zone"].(string)
+			typ, _ := amp.Data["type"].(string)
+			insecure, _ := amp.Data["insecure"].(bool)
+			lz, err := imr.AddLocalZone(zone, typ, insecure)
+			if err != nil {
+				resp.Error = true
+				resp.ErrorMsg = err.Error()
+				return
+			}
+			resp.Data = []LocalZone{*lz}
+			resp.Msg = fmt.Sprintf("Local zone %s (%s) added", lz.Zone, lz.Type)
+
+		case "imr-local-zone-remove":
+			imr := Globals.ImrEngine
+			if imr == nil || imr.Cache == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			zone, _ := amp.Data["zone"].(string)
+			if err := imr.RemoveLocalZone(zone); err != nil {
+				resp.Error = true
+				resp.ErrorMsg = err.Error()
+				return
+			}
+			resp.Msg = fmt.Sprintf("Local zone %s removed", dns.Fqdn(zone))
+
+		case "imr-local-zone-list":
+			imr := Globals.ImrEngine
+			if imr == nil || imr.Cache == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			zones := imr.LocalZones()
+			resp.Data = zones
+			resp.Msg = fmt.Sprintf("%d local zones", len(zones))
+
+		case "imr-local-data-add":
+			imr := Globals.ImrEngine
+			if imr == nil || imr.Cache == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			rrstr, _ := amp.Data["rr"].(string)
+			rr, err := imr.AddLocalData(rrstr)
+			if err != nil {
+				resp.Error = true
+				resp.ErrorMsg = err.Error()
+				return
+			}
+			resp.Msg = fmt.Sprintf("Local data added: %s", rr.String())
+
+		case "imr-local-data-remove":
+			imr := Globals.ImrEngine
+			if imr == nil || imr.Cache == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			owner, _ := amp.Data["owner"].(string)
+			var rrtype uint16
+			if s, _ := amp.Data["rrtype"].(string); s != "" {
+				t, ok := dns.StringToType[strings.ToUpper(s)]
+				if !ok {
+					resp.Error = true
+					resp.ErrorMsg = fmt.Sprintf("unknown RR type %q", s)
+					return
+				}
+				rrtype = t
+			}
+			if err := imr.RemoveLocalData(owner, rrtype); err != nil {
+				resp.Error = true
+				resp.ErrorMsg = err.Error()
+				return
+			}
+			resp.Msg = fmt.Sprintf("Local data for %s removed", dns.Fqdn(owner))
+
+		case "imr-access-stats":
+			imr := Globals.ImrEngine
+			if imr == nil {
+				resp.Error = true
+				resp.ErrorMsg = "IMR engine not available"
+				return
+			}
+			stats := imr.AccessStats()
+			resp.Data = stats
+			resp.Msg = fmt.Sprintf("%d queries refused by access control, %d rate limited; %d netblocks tracked",
+				stats.Denied, stats.RateLimited, stats.Netblocks)
+
 		case "imr-dump-zone-backoffs":
 			imr := Globals.ImrEngine
 			if imr == nil || imr.Cache == nil {
@@ -43,30 +43,7 @@ func APIRolloverStatus(conf *Config) func(w http.ResponseWriter, r *http.Request
 			pol = zd.DnssecPolicy
 		}
 
-		// Surface the kasp.check_interval / attempt-timeout invariant
-		// in every status response. Uses the parsed kasp.check_interval
-		// the same way KeyStateWorker does at startup.
-		var checkInterval time.Duration
-		if conf.Dnssec.Kasp.CheckInterval != "" {
-			if d, err := time.ParseDuration(conf.Dnssec.Kasp.CheckInterval); err == nil && d > 0 {
-				checkInterval = d
-			}
-		}
-		if checkInterval == 0 {
-			checkInterval = time.Minute // defaultCheckInterval
-		}
-		// kasp.propagation_delay drives the ds-published → standby
-		// timing computed in populateNextTransitions.
-		var propagationDelay time.Duration
-		if conf.Dnssec.Kasp.PropagationDelay != "" {
-			if d, err := time.ParseDuration(conf.Dnssec.Kasp.PropagationDelay); err == nil && d > 0 {
-				propagationDelay = d
-			}
-		}
-		if propagationDelay == 0 {
-			propagationDelay = time.Hour // defaultPropagationDelay
-		}
-
+		checkInterval, propagationDelay := rolloverStatusIntervals(conf)
 		out, err := ComputeRolloverStatus(kdb, zone, pol, checkInterval, propagationDelay, time.Now())
 		if err != nil {
 			lgApi.Warn("rollover/status: ComputeRolloverStatus failed", "zone", zone, "err", err)
@@ -77,6 +54,34 @@ func APIRolloverStatus(conf *Config) func(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// rolloverStatusIntervals returns the kasp.check_interval and
+// kasp.propagation_delay that ComputeRolloverStatus needs, with the same
+// defaults KeyStateWorker uses. Shared by the v1 and v2 status endpoints.
+func rolloverStatusIntervals(conf *Config) (checkInterval, propagationDelay time.Duration) {
+	// The check interval is surfaced in every status response (the
+	// kasp.check_interval / attempt-timeout invariant), parsed the same
+	// way KeyStateWorker does at startup.
+	if conf.Dnssec.Kasp.CheckInterval != "" {
+		if d, err := time.ParseDuration(conf.Dnssec.Kasp.CheckInterval); err == nil && d > 0 {
+			checkInterval = d
+		}
+	}
+	if checkInterval == 0 {
+		checkInterval = time.Minute // defaultCheckInterval
+	}
+	// kasp.propagation_delay drives the ds-published → standby
+	// timing computed in populateNextTransitions.
+	if conf.Dnssec.Kasp.PropagationDelay != "" {
+		if d, err := time.ParseDuration(conf.Dnssec.Kasp.PropagationDelay); err == nil && d > 0 {
+			propagationDelay = d
+		}
+	}
+	if propagationDelay == 0 {
+		propagationDelay = time.Hour // defaultPropagationDelay
+	}
+	return checkInterval, propagationDelay
+}
+
 // APIRolloverAsap handles POST /api/v1/rollover/asap. Body:
 // {"zone": "..."}. Computes earliest then persists the manual-
 // rollover request. Held under the per-zone lock so the
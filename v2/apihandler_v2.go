@@ -0,0 +1,794 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ *
+ * Handlers for the /api/v2 routes declared in apirouters_v2.go.
+ */
+
+package tdns
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// apiV2UpdateTimeout bounds how long an RRset write waits for the ZoneUpdater
+// to report back.
+const apiV2UpdateTimeout = 10 * time.Second
+
+// --- zones ---
+
+func apiV2ZoneFromZoneData(zd *ZoneData, kdb *KeyDB) APIv2Zone {
+	zc := buildListZoneConf(zd, zd.ZoneName, kdb)
+	z := APIv2Zone{
+		Name:             zc.Name,
+		Type:             zc.Type,
+		Store:            zc.Store,
+		Serial:           zd.publishedSerial(),
+		IncomingSerial:   zc.IncomingSerial,
+		Provisioning:     zc.Provisioning,
+		Frozen:           zc.Frozen,
+		Dirty:            zc.Dirty,
+		ApiManaged:       zc.ApiManaged,
+		SourceCatalog:    zc.SourceCatalog,
+		Template:         zd.Template,
+		DnssecPolicy:     zc.EffectiveDnssecPolicy,
+		PolicyOverridden: zc.DnssecPolicyOverridden,
+	}
+	for _, opt := range zc.Options {
+		if s, ok := ZoneOptionToString[opt]; ok {
+			z.Options = append(z.Options, s)
+		}
+	}
+	sort.Strings(z.Options)
+	for _, p := range zc.Primaries {
+		z.Primaries = append(z.Primaries, APIv2Peer{Addr: p.Addr, Key: p.Key})
+	}
+	if zc.Error {
+		z.Error = zc.ErrorMsg
+	}
+	return z
+}
+
+func apiV2ListZones(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := parseV2Page(r)
+		if err != nil {
+			writeV2Error(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		names, next := page.apply(Zones.Keys())
+		resp := APIv2ZoneList{Items: []APIv2Zone{}, NextPageToken: next}
+		for _, name := range names {
+			if zd, ok := Zones.Get(name); ok && zd != nil {
+				resp.Items = append(resp.Items, apiV2ZoneFromZoneData(zd, conf.Internal.KeyDB))
+			}
+		}
+		writeV2JSON(w, http.StatusOK, resp)
+	}
+}
+
+func apiV2GetZone(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok {
+			return
+		}
+		z := apiV2ZoneFromZoneData(zd, conf.Internal.KeyDB)
+		writeV2Conditional(w, r, z.Serial, z)
+	}
+}
+
+func apiV2Peers(peers []APIv2Peer) []PeerConf {
+	var out []PeerConf
+	for _, p := range peers {
+		out = append(out, PeerConf{Addr: p.Addr, Key: p.Key})
+	}
+	return out
+}
+
+// runV2ZoneCommand runs a command-style zone operation and maps its response
+// onto an /api/v2 result, writing errStatus when the command failed.
+func runV2ZoneCommand(conf *Config, w http.ResponseWriter, r *http.Request, zp ZonePost, okStatus, errStatus int) {
+	resp := runZoneCommand(&Globals.App, conf.Internal.RefreshZoneCh, conf.Internal.KeyDB, r, zp)
+	if resp.Error {
+		writeV2Error(w, errStatus, "%s", resp.ErrorMsg)
+		return
+	}
+	res := APIv2Result{Message: resp.Msg}
+	if zd, ok := Zones.Get(dns.Fqdn(zp.Zone)); ok && zd != nil {
+		res.Serial = zd.publishedSerial()
+		w.Header().Set("ETag", zoneETag(res.Serial))
+	}
+	writeV2JSON(w, okStatus, res)
+}
+
+func apiV2CreateZone(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req APIv2ZoneCreate
+		if !decodeV2Body(w, r, &req, false) {
+			return
+		}
+		if req.Name == "" {
+			writeV2Error(w, http.StatusBadRequest, "zone name is required")
+			return
+		}
+		if _, exists := Zones.Get(dns.Fqdn(req.Name)); exists {
+			writeV2Error(w, http.StatusConflict, "zone %s already exists", dns.Fqdn(req.Name))
+			return
+		}
+		w.Header().Set("Location", apiV2Prefix+"/zones/"+dns.Fqdn(req.Name))
+		runV2ZoneCommand(conf, w, r, ZonePost{
+			Command:    "add",
+			Zone:       req.Name,
+			ZoneType:   req.Type,
+			Template:   req.Template,
+			Primaries:  apiV2Peers(req.Primaries),
+			Options:    req.Options,
+			TsigName:   req.TsigName,
+			TsigSecret: req.TsigSecret,
+			TsigAlgo:   req.TsigAlgo,
+		}, http.StatusAccepted, http.StatusUnprocessableEntity)
+	}
+}
+
+func apiV2ModifyZone(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok || !checkIfMatch(w, r, zd) {
+			return
+		}
+		var req APIv2ZoneModify
+		if !decodeV2Body(w, r, &req, false) {
+			return
+		}
+		runV2ZoneCommand(conf, w, r, ZonePost{
+			Command:    "modify",
+			Zone:       zd.ZoneName,
+			Primaries:  apiV2Peers(req.Primaries),
+			Options:    req.Options,
+			TsigName:   req.TsigName,
+			TsigSecret: req.TsigSecret,
+			TsigAlgo:   req.TsigAlgo,
+		}, http.StatusAccepted, http.StatusUnprocessableEntity)
+	}
+}
+
+func apiV2DeleteZone(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok || !checkIfMatch(w, r, zd) {
+			return
+		}
+		resp := runZoneCommand(&Globals.App, conf.Internal.RefreshZoneCh, conf.Internal.KeyDB, r,
+			ZonePost{Command: "delete", Zone: zd.ZoneName})
+		if resp.Error {
+			writeV2Error(w, http.StatusConflict, "%s", resp.ErrorMsg)
+			return
+		}
+		writeV2JSON(w, http.StatusOK, APIv2Result{Message: resp.Msg})
+	}
+}
+
+// apiV2ZoneActions maps the {action} path segment to its v1 zone command.
+var apiV2ZoneActions = map[string]string{
+	"bump":          "bump",
+	"sign":          "sign-zone",
+	"resign":        "resign-zone",
+	"reload":        "reload",
+	"write":         "write-zone",
+	"freeze":        "freeze",
+	"thaw":          "thaw",
+	"generate-nsec": "generate-nsec",
+}
+
+func apiV2ZoneActionNames() []string {
+	names := make([]string, 0, len(apiV2ZoneActions))
+	for a := range apiV2ZoneActions {
+		names = append(names, a)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func apiV2ZoneAction(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		action := mux.Vars(r)["action"]
+		cmd, known := apiV2ZoneActions[action]
+		if !known {
+			writeV2Error(w, http.StatusNotFound, "unknown zone action %q (valid: %s)", action, strings.Join(apiV2ZoneActionNames(), ", "))
+			return
+		}
+		zd, ok := v2Zone(w, r)
+		if !ok || !checkIfMatch(w, r, zd) {
+			return
+		}
+		var req APIv2ZoneAction
+		if !decodeV2Body(w, r, &req, true) {
+			return
+		}
+		runV2ZoneCommand(conf, w, r, ZonePost{
+			Command: cmd,
+			Zone:    zd.ZoneName,
+			Force:   req.Force,
+			Wait:    req.Wait,
+			Timeout: req.Timeout,
+		}, http.StatusOK, http.StatusConflict)
+	}
+}
+
+// --- rrsets ---
+
+func apiV2RRsetFrom(owner string, rrtype uint16, rs *core.RRset) APIv2RRset {
+	out := APIv2RRset{Owner: owner, Type: dns.TypeToString[rrtype], RRs: []string{}}
+	for _, rr := range rs.RRs {
+		if out.TTL == 0 {
+			out.TTL = rr.Header().Ttl
+		}
+		out.RRs = append(out.RRs, rr.String())
+	}
+	for _, sig := range rs.RRSIGs {
+		out.RRSIGs = append(out.RRSIGs, sig.String())
+	}
+	return out
+}
+
+// v2MapZone refuses RRset access to zones that do not keep owner data in the
+// map store (xfr-only zones).
+func v2MapZone(w http.ResponseWriter, zd *ZoneData) bool {
+	if zd.ZoneStore != MapZone {
+		writeV2Error(w, http.StatusConflict, "zone %s uses store %s, RRset access needs a map zone", zd.ZoneName, ZoneStoreToString[zd.ZoneStore])
+		return false
+	}
+	if !zd.Ready {
+		writeV2Error(w, http.StatusServiceUnavailable, "zone %s is not loaded yet", zd.ZoneName)
+		return false
+	}
+	return true
+}
+
+func apiV2ListRRsets(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok || !v2MapZone(w, zd) {
+			return
+		}
+		page, err := parseV2Page(r)
+		if err != nil {
+			writeV2Error(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		var typeFilter uint16
+		if t := r.URL.Query().Get("type"); t != "" {
+			tf, known := dns.StringToType[strings.ToUpper(t)]
+			if !known {
+				writeV2Error(w, http.StatusBadRequest, "unknown RR type %q", t)
+				return
+			}
+			typeFilter = tf
+		}
+
+		// One snapshot for the whole page, so every RRset is from one serial.
+		snap := zd.publishedSnapshot()
+		if snap == nil {
+			writeV2Error(w, http.StatusServiceUnavailable, "zone %s has no published data", zd.ZoneName)
+			return
+		}
+		owners := make([]string, 0, len(snap.Data))
+		for name, od := range snap.Data {
+			if typeFilter != 0 {
+				if _, has := od.RRtypes.Get(typeFilter); !has {
+					continue
+				}
+			}
+			owners = append(owners, name)
+		}
+		names, next := page.apply(owners)
+
+		resp := APIv2RRsetList{Serial: snap.Serial, Items: []APIv2RRset{}, NextPageToken: next}
+		for _, name := range names {
+			od := snap.Data[name]
+			types := od.RRtypes.Keys()
+			sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+			for _, t := range types {
+				if typeFilter != 0 && t != typeFilter {
+					continue
+				}
+				rs := od.RRtypes.GetOnlyRRSet(t)
+				resp.Items = append(resp.Items, apiV2RRsetFrom(name, t, &rs))
+			}
+		}
+		writeV2Conditional(w, r, snap.Serial, resp)
+	}
+}
+
+// v2RRsetPath resolves {owner} and {rrtype}.
+func v2RRsetPath(w http.ResponseWriter, r *http.Request, zd *ZoneData) (string, uint16, bool) {
+	vars := mux.Vars(r)
+	owner := v2OwnerName(vars["owner"], zd.ZoneName)
+	if !dns.IsSubDomain(zd.ZoneName, owner) {
+		writeV2Error(w, http.StatusBadRequest, "owner %s is not in zone %s", owner, zd.ZoneName)
+		return "", 0, false
+	}
+	rrtype, known := dns.StringToType[strings.ToUpper(vars["rrtype"])]
+	if !known {
+		writeV2Error(w, http.StatusBadRequest, "unknown RR type %q", vars["rrtype"])
+		return "", 0, false
+	}
+	return owner, rrtype, true
+}
+
+func apiV2GetRRset(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok || !v2MapZone(w, zd) {
+			return
+		}
+		owner, rrtype, ok := v2RRsetPath(w, r, zd)
+		if !ok {
+			return
+		}
+		snap := zd.publishedSnapshot()
+		rs := getRRsetFrom(snap, owner, rrtype)
+		if rs == nil || len(rs.RRs) == 0 {
+			writeV2Error(w, http.StatusNotFound, "no %s RRset at %s", dns.TypeToString[rrtype], owner)
+			return
+		}
+		writeV2Conditional(w, r, snap.Serial, apiV2RRsetFrom(owner, rrtype, rs))
+	}
+}
+
+// apiV2ReadOnlyTypes are maintained by the signer or the serial logic and
+// cannot be written through the RRset endpoints.
+var apiV2ReadOnlyTypes = map[uint16]bool{
+	dns.TypeSOA: true, dns.TypeRRSIG: true, dns.TypeNSEC: true, dns.TypeNSEC3: true, dns.TypeNSEC3PARAM: true,
+}
+
+func apiV2PutRRset(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok || !v2MapZone(w, zd) {
+			return
+		}
+		owner, rrtype, ok := v2RRsetPath(w, r, zd)
+		if !ok {
+			return
+		}
+		if apiV2ReadOnlyTypes[rrtype] {
+			writeV2Error(w, http.StatusForbidden, "%s RRsets are maintained by the server", dns.TypeToString[rrtype])
+			return
+		}
+		var req APIv2RRsetPut
+		if !decodeV2Body(w, r, &req, false) {
+			return
+		}
+		if len(req.RRs) == 0 {
+			writeV2Error(w, http.StatusBadRequest, "empty RRset; use DELETE to remove it")
+			return
+		}
+		actions := []dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: owner, Rrtype: rrtype, Class: dns.ClassANY}}}
+		for _, s := range req.RRs {
+			rr, err := dns.NewRR(s)
+			if err != nil || rr == nil {
+				writeV2Error(w, http.StatusBadRequest, "cannot parse RR %q: %v", s, err)
+				return
+			}
+			h := rr.Header()
+			if !strings.EqualFold(h.Name, owner) || h.Rrtype != rrtype || h.Class != dns.ClassINET {
+				writeV2Error(w, http.StatusBadRequest, "RR %q does not belong to the IN %s RRset at %s", s, dns.TypeToString[rrtype], owner)
+				return
+			}
+			h.Name = owner
+			actions = append(actions, rr)
+		}
+		res, ok := apiV2ApplyUpdate(w, r, zd, actions, fmt.Sprintf("api/v2 replace %s %s", owner, dns.TypeToString[rrtype]))
+		if !ok {
+			return
+		}
+		w.Header().Set("ETag", zoneETag(res.Serial))
+		out := APIv2RRset{Owner: owner, Type: dns.TypeToString[rrtype], RRs: []string{}}
+		if rs := getRRsetFrom(zd.publishedSnapshot(), owner, rrtype); rs != nil {
+			out = apiV2RRsetFrom(owner, rrtype, rs)
+		}
+		writeV2JSON(w, http.StatusOK, out)
+	}
+}
+
+func apiV2DeleteRRset(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok || !v2MapZone(w, zd) {
+			return
+		}
+		owner, rrtype, ok := v2RRsetPath(w, r, zd)
+		if !ok {
+			return
+		}
+		if apiV2ReadOnlyTypes[rrtype] {
+			writeV2Error(w, http.StatusForbidden, "%s RRsets are maintained by the server", dns.TypeToString[rrtype])
+			return
+		}
+		if rs := getRRsetFrom(zd.publishedSnapshot(), owner, rrtype); rs == nil || len(rs.RRs) == 0 {
+			writeV2Error(w, http.StatusNotFound, "no %s RRset at %s", dns.TypeToString[rrtype], owner)
+			return
+		}
+		actions := []dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: owner, Rrtype: rrtype, Class: dns.ClassANY}}}
+		res, ok := apiV2ApplyUpdate(w, r, zd, actions, fmt.Sprintf("api/v2 delete %s %s", owner, dns.TypeToString[rrtype]))
+		if !ok {
+			return
+		}
+		w.Header().Set("ETag", zoneETag(res.Serial))
+		writeV2JSON(w, http.StatusOK, APIv2Result{Message: fmt.Sprintf("deleted %s %s", owner, dns.TypeToString[rrtype]), Serial: res.Serial})
+	}
+}
+
+// apiV2ApplyUpdate hands actions to the ZoneUpdater as a ZONE-UPDATE, the
+// same path a DNS UPDATE takes (update-policy, signing, delegation sync,
+// audit), and waits for the outcome. If-Match is checked here to fail fast
+// and again by the updater as the request's PreCondition, so the check and
+// the apply are atomic with respect to other updates.
+func apiV2ApplyUpdate(w http.ResponseWriter, r *http.Request, zd *ZoneData, actions []dns.RR, desc string) (ZoneUpdateResult, bool) {
+	var res ZoneUpdateResult
+	if zd.Options[OptFrozen] {
+		writeV2Error(w, http.StatusConflict, "zone %s is frozen", zd.ZoneName)
+		return res, false
+	}
+	if !checkIfMatch(w, r, zd) {
+		return res, false
+	}
+	if zd.KeyDB == nil || zd.KeyDB.UpdateQ == nil {
+		writeV2Error(w, http.StatusServiceUnavailable, "zone updater is not running")
+		return res, false
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	respch := make(chan ZoneUpdateResult, 1)
+	ur := UpdateRequest{
+		Cmd:         "ZONE-UPDATE",
+		ZoneName:    zd.ZoneName,
+		Actions:     actions,
+		Validated:   true,
+		Trusted:     true,
+		Actor:       apiAuditActor(r),
+		Description: desc,
+		Response:    respch,
+	}
+	if ifMatch != "" {
+		ur.PreCondition = func() bool {
+			return etagListMatches(ifMatch, zoneETag(zd.publishedSerial()), false)
+		}
+	}
+
+	select {
+	case zd.KeyDB.UpdateQ <- ur:
+	case <-r.Context().Done():
+		writeV2Error(w, http.StatusServiceUnavailable, "request cancelled")
+		return res, false
+	case <-time.After(apiV2UpdateTimeout):
+		writeV2Error(w, http.StatusServiceUnavailable, "zone updater queue is full")
+		return res, false
+	}
+	select {
+	case res = <-respch:
+	case <-r.Context().Done():
+		writeV2Error(w, http.StatusServiceUnavailable, "request cancelled")
+		return res, false
+	case <-time.After(apiV2UpdateTimeout):
+		writeV2Error(w, http.StatusGatewayTimeout, "zone updater did not answer within %v", apiV2UpdateTimeout)
+		return res, false
+	}
+
+	switch {
+	case res.PreconditionFailed:
+		w.Header().Set("ETag", zoneETag(res.Serial))
+		writeV2Error(w, http.StatusPreconditionFailed, "zone %s changed before the update was applied", zd.ZoneName)
+		return res, false
+	case res.Error != "":
+		writeV2Error(w, http.StatusConflict, "%s", res.Error)
+		return res, false
+	case !res.Updated:
+		writeV2Error(w, http.StatusUnprocessableEntity, "update was not applied (RR type denied by the zone's update-policy?)")
+		return res, false
+	}
+	return res, true
+}
+
+// --- keys ---
+
+func apiV2KeyFrom(k DnssecKeyWithTimestamps) APIv2Key {
+	role := "ZSK"
+	if k.Flags&dns.SEP != 0 {
+		role = "KSK"
+	}
+	return APIv2Key{
+		Zone:        k.ZoneName,
+		KeyID:       k.KeyTag,
+		Algorithm:   algName(k.Algorithm),
+		Flags:       k.Flags,
+		Role:        role,
+		State:       k.State,
+		DNSKEY:      k.KeyRR,
+		PublishedAt: k.PublishedAt,
+		ActiveAt:    k.ActiveAt,
+		RetiredAt:   k.RetiredAt,
+	}
+}
+
+func v2ZoneKeys(kdb *KeyDB, zone, state string) ([]APIv2Key, error) {
+	states := dnssecKeyStates
+	if state != "" {
+		states = []string{state}
+	}
+	keys := []APIv2Key{}
+	for _, st := range states {
+		ks, err := GetDnssecKeysByState(kdb, zone, st)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range ks {
+			keys = append(keys, apiV2KeyFrom(k))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].KeyID < keys[j].KeyID })
+	return keys, nil
+}
+
+func apiV2ListKeys(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok {
+			return
+		}
+		kdb := conf.Internal.KeyDB
+		if kdb == nil {
+			writeV2Error(w, http.StatusServiceUnavailable, "keystore not initialized")
+			return
+		}
+		state := r.URL.Query().Get("state")
+		if state != "" {
+			if err := validKeyState(state, dnssecKeyStates); err != nil {
+				writeV2Error(w, http.StatusBadRequest, "%v", err)
+				return
+			}
+		}
+		keys, err := v2ZoneKeys(kdb, zd.ZoneName, state)
+		if err != nil {
+			writeV2Error(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+		writeV2JSON(w, http.StatusOK, APIv2KeyList{Items: keys})
+	}
+}
+
+func apiV2GetKey(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok {
+			return
+		}
+		kdb := conf.Internal.KeyDB
+		if kdb == nil {
+			writeV2Error(w, http.StatusServiceUnavailable, "keystore not initialized")
+			return
+		}
+		keyid, err := strconv.ParseUint(mux.Vars(r)["keyid"], 10, 16)
+		if err != nil {
+			writeV2Error(w, http.StatusBadRequest, "bad keyid %q", mux.Vars(r)["keyid"])
+			return
+		}
+		keys, err := v2ZoneKeys(kdb, zd.ZoneName, "")
+		if err != nil {
+			writeV2Error(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+		for _, k := range keys {
+			if k.KeyID == uint16(keyid) {
+				writeV2JSON(w, http.StatusOK, k)
+				return
+			}
+		}
+		writeV2Error(w, http.StatusNotFound, "zone %s has no DNSSEC key %d", zd.ZoneName, keyid)
+	}
+}
+
+// --- policies ---
+
+func apiV2GetZonePolicy(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok {
+			return
+		}
+		zc := buildListZoneConf(zd, zd.ZoneName, conf.Internal.KeyDB)
+		writeV2Conditional(w, r, zd.publishedSerial(), APIv2ZonePolicy{
+			Policy:     zc.EffectiveDnssecPolicy,
+			Overridden: zc.DnssecPolicyOverridden,
+			ConfigBase: zc.DnssecPolicyConfigBase,
+		})
+	}
+}
+
+func apiV2PutZonePolicy(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok || !checkIfMatch(w, r, zd) {
+			return
+		}
+		var req APIv2ZonePolicy
+		if !decodeV2Body(w, r, &req, false) {
+			return
+		}
+		cmd := "policy-set"
+		switch req.Mode {
+		case "", "set":
+		case "change":
+			cmd = "change-policy"
+		default:
+			writeV2Error(w, http.StatusBadRequest, "unknown mode %q (valid: set, change)", req.Mode)
+			return
+		}
+		runV2ZoneCommand(conf, w, r, ZonePost{Command: cmd, Zone: zd.ZoneName, Policy: req.Policy},
+			http.StatusOK, http.StatusUnprocessableEntity)
+	}
+}
+
+func apiV2DeleteZonePolicy(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok || !checkIfMatch(w, r, zd) {
+			return
+		}
+		// DELETE is the confirmation; policy-reset's dry-run has no v2 form.
+		runV2ZoneCommand(conf, w, r, ZonePost{Command: "policy-reset", Zone: zd.ZoneName, Force: true},
+			http.StatusOK, http.StatusUnprocessableEntity)
+	}
+}
+
+func apiV2ListPolicies(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := parseV2Page(r)
+		if err != nil {
+			writeV2Error(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		names := make([]string, 0, len(conf.Internal.DnssecPolicies))
+		for name := range conf.Internal.DnssecPolicies {
+			names = append(names, name)
+		}
+		names, next := page.apply(names)
+		resp := APIv2PolicyList{Items: []DnssecPolicyInfo{}, NextPageToken: next}
+		for _, name := range names {
+			resp.Items = append(resp.Items, DnssecPolicyToInfo(conf.Internal.DnssecPolicies[name]))
+		}
+		writeV2JSON(w, http.StatusOK, resp)
+	}
+}
+
+func apiV2GetPolicy(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["policy"]
+		p, ok := conf.Internal.DnssecPolicies[name]
+		if !ok {
+			writeV2Error(w, http.StatusNotFound, "no DNSSEC policy %q", name)
+			return
+		}
+		writeV2JSON(w, http.StatusOK, DnssecPolicyToInfo(p))
+	}
+}
+
+// --- rollovers ---
+
+func apiV2GetRollover(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zd, ok := v2Zone(w, r)
+		if !ok {
+			return
+		}
+		kdb := conf.Internal.KeyDB
+		if kdb == nil {
+			writeV2Error(w, http.StatusServiceUnavailable, "keystore not initialized")
+			return
+		}
+		checkInterval, propagationDelay := rolloverStatusIntervals(conf)
+		out, err := ComputeRolloverStatus(kdb, zd.ZoneName, zd.DnssecPolicy, checkInterval, propagationDelay, time.Now())
+		if err != nil {
+			writeV2Error(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+		writeV2JSON(w, http.StatusOK, out)
+	}
+}
+
+// --- catalogs ---
+
+func v2Catalog(w http.ResponseWriter, r *http.Request) (string, bool) {
+	catalog := dns.Fqdn(mux.Vars(r)["catalog"])
+	if zd, ok := Zones.Get(catalog); !ok || zd == nil {
+		writeV2Error(w, http.StatusNotFound, "catalog zone %s is unknown", catalog)
+		return "", false
+	}
+	return catalog, true
+}
+
+func apiV2ListCatalogMembers(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalog, ok := v2Catalog(w, r)
+		if !ok {
+			return
+		}
+		page, err := parseV2Page(r)
+		if err != nil {
+			writeV2Error(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		members := GetOrCreateCatalogMembership(catalog).GetMemberZones()
+		names := make([]string, 0, len(members))
+		for name := range members {
+			names = append(names, name)
+		}
+		names, next := page.apply(names)
+		resp := APIv2CatalogMemberList{Items: []APIv2CatalogMember{}, NextPageToken: next}
+		for _, name := range names {
+			m := members[name]
+			groups := append([]string(nil), m.ServiceGroups...)
+			for _, g := range []string{m.SigningGroup, m.MetaGroup} {
+				if g != "" {
+					groups = append(groups, g)
+				}
+			}
+			resp.Items = append(resp.Items, APIv2CatalogMember{Zone: name, Groups: groups, DiscoveredAt: m.DiscoveredAt})
+		}
+		writeV2JSON(w, http.StatusOK, resp)
+	}
+}
+
+func apiV2PutCatalogMember(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalog, ok := v2Catalog(w, r)
+		if !ok {
+			return
+		}
+		var req APIv2CatalogMember
+		if !decodeV2Body(w, r, &req, true) {
+			return
+		}
+		zone := dns.Fqdn(mux.Vars(r)["zone"])
+		if _, member := GetOrCreateCatalogMembership(catalog).GetMemberZones()[zone]; member {
+			writeV2JSON(w, http.StatusOK, APIv2Result{Message: fmt.Sprintf("zone %s is already a member of catalog %s", zone, catalog)})
+			return
+		}
+		var resp CatalogResponse
+		if err := handleCatalogZoneAdd(catalog, zone, req.Groups, &resp); err != nil {
+			writeV2Error(w, http.StatusUnprocessableEntity, "%v", err)
+			return
+		}
+		writeV2JSON(w, http.StatusCreated, APIv2Result{Message: resp.Msg})
+	}
+}
+
+func apiV2DeleteCatalogMember(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalog, ok := v2Catalog(w, r)
+		if !ok {
+			return
+		}
+		zone := dns.Fqdn(mux.Vars(r)["zone"])
+		if _, member := GetOrCreateCatalogMembership(catalog).GetMemberZones()[zone]; !member {
+			writeV2Error(w, http.StatusNotFound, "zone %s is not a member of catalog %s", zone, catalog)
+			return
+		}
+		var resp CatalogResponse
+		if err := handleCatalogZoneDelete(catalog, zone, &resp); err != nil {
+			writeV2Error(w, http.StatusUnprocessableEntity, "%v", err)
+			return
+		}
+		writeV2JSON(w, http.StatusOK, APIv2Result{Message: resp.Msg})
+	}
+}
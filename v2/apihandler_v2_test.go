@@ -0,0 +1,230 @@
+package tdns
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/miekg/dns"
+)
+
+const apiV2TestKey = "v2-test-key"
+
+const apiV2TestZone = `example.		3600	IN	SOA	ns.example. hostmaster.example. 7 7200 1800 604800 7200
+example.		3600	IN	NS	ns.example.
+ns.example.		3600	IN	A	192.0.2.53
+www.example.		3600	IN	A	192.0.2.1
+`
+
+func newAPIv2TestRouter(t *testing.T, conf *Config) *mux.Router {
+	t.Helper()
+	rtr := mux.NewRouter().StrictSlash(true)
+	conf.setupAPIv2Routes(rtr, apiV2TestKey)
+	return rtr
+}
+
+func apiV2Do(t *testing.T, rtr http.Handler, method, path, body string, hdr map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body == "" {
+		req = httptest.NewRequest(method, path, nil)
+	}
+	req.Header.Set("X-API-Key", apiV2TestKey)
+	for k, v := range hdr {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	rtr.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIv2PageWalk(t *testing.T) {
+	keys := []string{"e.", "a.", "d.", "c.", "b."}
+	var got []string
+	p := v2Page{Limit: 2}
+	for i := 0; i < 10; i++ {
+		page, next := p.apply(append([]string(nil), keys...))
+		got = append(got, page...)
+		if next == "" {
+			break
+		}
+		req := httptest.NewRequest("GET", "/x?limit=2&page_token="+next, nil)
+		var err error
+		if p, err = parseV2Page(req); err != nil {
+			t.Fatalf("parseV2Page: %v", err)
+		}
+	}
+	if strings.Join(got, ",") != "a.,b.,c.,d.,e." {
+		t.Fatalf("page walk = %v", got)
+	}
+
+	if _, err := parseV2Page(httptest.NewRequest("GET", "/x?limit=0", nil)); err == nil {
+		t.Error("limit=0 accepted")
+	}
+	if p, _ := parseV2Page(httptest.NewRequest("GET", "/x?limit=99999", nil)); p.Limit != apiV2MaxPageSize {
+		t.Errorf("limit not clamped: %d", p.Limit)
+	}
+}
+
+func TestEtagListMatches(t *testing.T) {
+	cases := []struct {
+		header string
+		weak   bool
+		want   bool
+	}{
+		{`"7"`, false, true},
+		{`"6", "7"`, false, true},
+		{`"6"`, false, false},
+		{`*`, false, true},
+		{`W/"7"`, false, false},
+		{`W/"7"`, true, true},
+	}
+	for _, tc := range cases {
+		if got := etagListMatches(tc.header, zoneETag(7), tc.weak); got != tc.want {
+			t.Errorf("etagListMatches(%q, weak=%v) = %v, want %v", tc.header, tc.weak, got, tc.want)
+		}
+	}
+}
+
+func TestAPIv2RRsetReadAndConditionals(t *testing.T) {
+	zd := testZone(t, "example.", apiV2TestZone)
+	registerZones(t, zd)
+	rtr := newAPIv2TestRouter(t, &Config{})
+
+	if rec := apiV2Do(t, rtr, "GET", "/api/v2/zones/example./rrsets/www/A", "", map[string]string{"X-API-Key": "wrong"}); rec.Code != http.StatusForbidden {
+		t.Fatalf("bad API key: status %d", rec.Code)
+	}
+
+	rec := apiV2Do(t, rtr, "GET", "/api/v2/zones/example./rrsets/www/A", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET rrset: status %d: %s", rec.Code, rec.Body)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag != zoneETag(zd.publishedSerial()) {
+		t.Fatalf("ETag = %q, want %q", etag, zoneETag(zd.publishedSerial()))
+	}
+	var rs APIv2RRset
+	if err := json.Unmarshal(rec.Body.Bytes(), &rs); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rs.Owner != "www.example." || rs.Type != "A" || len(rs.RRs) != 1 {
+		t.Fatalf("unexpected rrset %+v", rs)
+	}
+
+	if rec := apiV2Do(t, rtr, "GET", "/api/v2/zones/example./rrsets/www/A", "", map[string]string{"If-None-Match": etag}); rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: status %d, want 304", rec.Code)
+	}
+	if rec := apiV2Do(t, rtr, "GET", "/api/v2/zones/example./rrsets/nope/A", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("missing rrset: status %d, want 404", rec.Code)
+	}
+	if rec := apiV2Do(t, rtr, "GET", "/api/v2/zones/nosuch./rrsets/www/A", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown zone: status %d, want 404", rec.Code)
+	}
+
+	// A stale If-Match is refused before anything is queued.
+	rec = apiV2Do(t, rtr, "PUT", "/api/v2/zones/example./rrsets/www/A",
+		`{"rrs":["www.example. 300 IN A 192.0.2.2"]}`, map[string]string{"If-Match": `"1"`})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: status %d, want 412: %s", rec.Code, rec.Body)
+	}
+
+	// The listing pages on owner names and reports the snapshot serial.
+	rec = apiV2Do(t, rtr, "GET", "/api/v2/zones/example./rrsets?limit=2", "", nil)
+	var list APIv2RRsetList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if list.NextPageToken == "" || list.Serial != zd.publishedSerial() {
+		t.Fatalf("unexpected first page: %+v", list)
+	}
+	rec = apiV2Do(t, rtr, "GET", "/api/v2/zones/example./rrsets?type=A", "", nil)
+	list = APIv2RRsetList{}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Items) != 2 {
+		t.Errorf("type=A listing returned %d RRsets, want 2", len(list.Items))
+	}
+}
+
+// A PUT goes through the ZoneUpdater: the RRset is replaced, the serial (and
+// so the ETag) advances, and the old ETag no longer matches.
+func TestAPIv2RRsetPutViaZoneUpdater(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	kdb.UpdateQ = make(chan UpdateRequest, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go kdb.ZoneUpdaterEngine(ctx)
+
+	zd := testZone(t, "example.", apiV2TestZone)
+	zd.KeyDB = kdb
+	zd.ZoneType = Primary
+	zd.Options = map[ZoneOption]bool{OptAllowUpdates: true}
+	zd.UpdatePolicy.Zone = UpdatePolicyDetail{RRtypes: map[uint16]bool{dns.TypeA: true}, TTL: 300}
+	registerZones(t, zd)
+	rtr := newAPIv2TestRouter(t, &Config{})
+
+	oldTag := zoneETag(zd.publishedSerial())
+	rec := apiV2Do(t, rtr, "PUT", "/api/v2/zones/example./rrsets/www/A",
+		`{"rrs":["www.example. 300 IN A 192.0.2.2","www.example. 300 IN A 192.0.2.3"]}`,
+		map[string]string{"If-Match": oldTag})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("ETag") == oldTag {
+		t.Errorf("ETag did not advance after PUT")
+	}
+	var rs APIv2RRset
+	json.Unmarshal(rec.Body.Bytes(), &rs)
+	if len(rs.RRs) != 2 || !strings.Contains(strings.Join(rs.RRs, " "), "192.0.2.3") {
+		t.Fatalf("RRset after PUT: %+v", rs)
+	}
+
+	rec = apiV2Do(t, rtr, "DELETE", "/api/v2/zones/example./rrsets/www/A", "", map[string]string{"If-Match": oldTag})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("DELETE with old ETag: status %d, want 412", rec.Code)
+	}
+
+	// RRs for another owner or type are rejected outright.
+	rec = apiV2Do(t, rtr, "PUT", "/api/v2/zones/example./rrsets/www/A", `{"rrs":["ftp.example. 300 IN A 192.0.2.9"]}`, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("foreign owner: status %d, want 400", rec.Code)
+	}
+}
+
+func TestOpenAPIDocCoversRoutes(t *testing.T) {
+	app := &AppDetails{Name: "tdns-test", Version: "0.0"}
+	routes := apiV2Routes()
+	doc := buildOpenAPIDoc(app, routes)
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var parsed struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	for _, rt := range routes {
+		ops, ok := parsed.Paths[apiV2Prefix+rt.Path]
+		if !ok {
+			t.Errorf("path %s missing", rt.Path)
+			continue
+		}
+		if _, ok := ops[strings.ToLower(rt.Method)]; !ok {
+			t.Errorf("%s %s missing", rt.Method, rt.Path)
+		}
+	}
+	for _, name := range []string{"APIv2Zone", "APIv2RRset", "APIv2Key", "Error", "RolloverStatus"} {
+		if _, ok := parsed.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s missing", name)
+		}
+	}
+}
@@ -14,6 +14,25 @@ import (
 	"github.com/miekg/dns"
 )
 
+// auditedZoneAPICommands are the /zone commands that change what a zone
+// serves, how it is signed, or whether it exists; each successful one is
+// recorded in the audit journal.
+var auditedZoneAPICommands = map[string]bool{
+	"bump":          true,
+	"sign-zone":     true,
+	"resign-zone":   true,
+	"policy-set":    true,
+	"change-policy": true,
+	"policy-reset":  true,
+	"generate-nsec": true,
+	"freeze":        true,
+	"thaw":          true,
+	"reload":        true,
+	"add":           true,
+	"delete":        true,
+	"modify":        true,
+}
+
 func APIzone(app *AppDetails, refreshq chan ZoneRefresher, kdb *KeyDB) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -28,310 +47,347 @@ func APIzone(app *AppDetails, refreshq chan ZoneRefresher, kdb *KeyDB) func(w ht
 
 		lgApi.Debug("received /zone request", "cmd", zp.Command, "from", r.RemoteAddr)
 
-		resp := ZoneResponse{
-			Time:    time.Now(),
-			AppName: app.Name,
+		resp := runZoneCommand(app, refreshq, kdb, r, zp)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			lgApi.Error("json encode failed", "handler", "zone", "err", err)
 		}
+	}
+}
 
-		defer func() {
-			w.Header().Set("Content-Type", "application/json")
-			err := json.NewEncoder(w).Encode(resp)
-			if err != nil {
-				lgApi.Error("json encode failed", "handler", "zone", "err", err)
-			}
-		}()
+// runZoneCommand executes one command-style zone operation and returns the
+// response. It is the core of POST /api/v1/zone and is also what the /api/v2
+// zone action endpoints call, so both API generations share one
+// implementation (origination gate and audit journal included). r supplies
+// the request context and the audit actor.
+func runZoneCommand(app *AppDetails, refreshq chan ZoneRefresher, kdb *KeyDB, r *http.Request, zp ZonePost) (resp ZoneResponse) {
+	resp = ZoneResponse{
+		Time:    time.Now(),
+		AppName: app.Name,
+	}
+	var err error
+
+	// The dynamic-zones management commands handle zone existence
+	// themselves in their cores (add requires absence; delete/modify/
+	// list-dynamic resolve internally), so they bypass this pre-check.
+	zoneLookupExempt := map[string]bool{
+		"list-zones":   true,
+		"add":          true,
+		"delete":       true,
+		"modify":       true,
+		"list-dynamic": true,
+	}
+	zd, exist := Zones.Get(zp.Zone)
+	if !exist && !zoneLookupExempt[zp.Command] {
+		resp.Error = true
+		resp.ErrorMsg = fmt.Sprintf("Zone %s is unknown", zp.Zone)
+		return
+	}
+	if zd == nil && !zoneLookupExempt[zp.Command] {
+		resp.Error = true
+		resp.ErrorMsg = fmt.Sprintf("Zone %s: zone data is nil", zp.Zone)
+		return
+	}
 
-		// The dynamic-zones management commands handle zone existence
-		// themselves in their cores (add requires absence; delete/modify/
-		// list-dynamic resolve internally), so they bypass this pre-check.
-		zoneLookupExempt := map[string]bool{
-			"list-zones":   true,
-			"add":          true,
-			"delete":       true,
-			"modify":       true,
-			"list-dynamic": true,
-		}
-		zd, exist := Zones.Get(zp.Zone)
-		if !exist && !zoneLookupExempt[zp.Command] {
+	// Origination gate (Fix C): refuse the actions that would write into
+	// the zone or advance its serial on a tdns-auth secondary. One check
+	// ahead of the switch rather than a line per case, so a command added
+	// to originationAPICommands is gated automatically.
+	if originationAPICommands[zp.Command] {
+		if msg := zoneOriginationRefusal(zd, zp.Command); msg != "" {
 			resp.Error = true
-			resp.ErrorMsg = fmt.Sprintf("Zone %s is unknown", zp.Zone)
-			return
-		}
-		if zd == nil && !zoneLookupExempt[zp.Command] {
-			resp.Error = true
-			resp.ErrorMsg = fmt.Sprintf("Zone %s: zone data is nil", zp.Zone)
+			resp.ErrorMsg = msg
 			return
 		}
+	}
 
-		// Origination gate (Fix C): refuse the actions that would write into
-		// the zone or advance its serial on a tdns-auth secondary. One check
-		// ahead of the switch rather than a line per case, so a command added
-		// to originationAPICommands is gated automatically.
-		if originationAPICommands[zp.Command] {
-			if msg := zoneOriginationRefusal(zd, zp.Command); msg != "" {
-				resp.Error = true
-				resp.ErrorMsg = msg
+	// Journal successful state-changing commands. resp is a named result,
+	// so this runs after the command below has filled it in and sees the
+	// final outcome; the caller encodes resp only once we have returned.
+	if auditedZoneAPICommands[zp.Command] {
+		defer func() {
+			if resp.Error {
 				return
 			}
-		}
-
-		switch zp.Command {
-		case "bump":
-			// resp.Msg, err = BumpSerial(conf, cp.Zone)
+			azd, _ := Zones.Get(dns.Fqdn(zp.Zone))
+			auditRecord(kdb, AuditEntry{
+				Actor:  apiAuditActor(r),
+				Source: AuditSourceApiZone,
+				Zone:   zp.Zone,
+				Action: zp.Command,
+				Serial: auditZoneSerial(azd),
+				Detail: resp.Msg,
+			})
+		}()
+	}
 
-			br, err := zd.BumpSerial()
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-				return
-			}
-			resp.Msg = fmt.Sprintf("Zone %s: bumped SOA serial from %d to %d", zp.Zone, br.OldSerial, br.NewSerial)
+	switch zp.Command {
+	case "bump":
+		// resp.Msg, err = BumpSerial(conf, cp.Zone)
 
-		case "write-zone":
-			msg, err := zd.WriteZone(false, zp.Force)
-			resp.Msg = msg
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-			}
+		br, err := zd.BumpSerial()
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+			return
+		}
+		resp.Msg = fmt.Sprintf("Zone %s: bumped SOA serial from %d to %d", zp.Zone, br.OldSerial, br.NewSerial)
 
-		case "sign-zone":
-			newrrsigs, err := zd.SignZone(kdb, zp.Force)
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-			}
-			resp.Msg = fmt.Sprintf("Zone %s: signed with %d new RRSIGs", zd.ZoneName, newrrsigs)
+	case "write-zone":
+		msg, err := zd.WriteZone(false, zp.Force)
+		resp.Msg = msg
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
 
-		case "resign-zone":
-			newrrsigs, err := zd.ResignZone(kdb)
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-			}
-			resp.Msg = fmt.Sprintf("Zone %s: resigned, %d RRSIGs written by currently-active keys", zd.ZoneName, newrrsigs)
+	case "sign-zone":
+		newrrsigs, err := zd.SignZone(kdb, zp.Force)
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
+		resp.Msg = fmt.Sprintf("Zone %s: signed with %d new RRSIGs", zd.ZoneName, newrrsigs)
 
-		case "policy-set":
-			resp.Msg, err = setZonePolicy(r.Context(), zd, kdb, zp.Policy)
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-			}
+	case "resign-zone":
+		newrrsigs, err := zd.ResignZone(kdb)
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
+		resp.Msg = fmt.Sprintf("Zone %s: resigned, %d RRSIGs written by currently-active keys", zd.ZoneName, newrrsigs)
 
-		case "change-policy":
-			resp.Msg, err = changeZonePolicy(r.Context(), zd, kdb, zp.Policy)
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-			}
+	case "policy-set":
+		resp.Msg, err = setZonePolicy(r.Context(), zd, kdb, zp.Policy)
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
 
-		case "policy-reset":
-			resp.Msg, err = resetZonePolicy(r.Context(), zd, kdb, zp.Force)
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-			}
+	case "change-policy":
+		resp.Msg, err = changeZonePolicy(r.Context(), zd, kdb, zp.Policy)
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
 
-		case "proxy-key":
-			resp.Msg, err = zd.ProxyKeyStatus(context.Background(), kdb, Globals.ImrEngine)
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-			}
+	case "policy-reset":
+		resp.Msg, err = resetZonePolicy(r.Context(), zd, kdb, zp.Force)
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
 
-		case "generate-nsec":
-			err := zd.GenerateNsecChain(kdb)
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-			}
+	case "proxy-key":
+		resp.Msg, err = zd.ProxyKeyStatus(context.Background(), kdb, Globals.ImrEngine)
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
 
-		case "show-nsec-chain":
-			resp.Names, err = zd.ShowNsecChain()
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-			}
+	case "generate-nsec":
+		err := zd.GenerateNsecChain(kdb)
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
 
-		case "freeze":
-			// Role check FIRST (Fix C). OptFrozen gates DDNS and nothing else
-			// (see updateresponder.go) — it does not pause refresh — so on a
-			// secondary, where allow-updates is always normalized off, it is
-			// functionally inert. Refusing it costs nothing real.
-			//
-			// Order matters: the allow-updates precondition below would
-			// otherwise fire first and tell the operator to enable an option
-			// the normalizer immediately strips again. Name the true reason.
-			if msg := zoneOriginationRefusal(zd, "freeze"); msg != "" {
-				resp.Error = true
-				resp.ErrorMsg = msg
-				return
-			}
-			// If a zone has modifications, freezing implies that the updated
-			// zone data should be written out to disk.
-			if !zd.Options[OptAllowUpdates] && !zd.Options[OptAllowChildUpdates] {
-				resp.Error = true
-				resp.ErrorMsg = fmt.Sprintf("FreezeZone: zone %s does not allow updates. Freeze would be a no-op", zd.ZoneName)
-				return
-			}
+	case "lint":
+		resp.Lint, err = zd.Lint()
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+			return
+		}
+		errs, warns := LintCounts(resp.Lint)
+		resp.Msg = fmt.Sprintf("Zone %s serial %d: %d error(s), %d warning(s)", zd.ZoneName, zd.publishedSerial(), errs, warns)
 
-			if zd.Options[OptFrozen] {
-				resp.Error = true
-				resp.ErrorMsg = fmt.Sprintf("FreezeZone: zone %s is already frozen", zd.ZoneName)
-				return
-			}
+	case "show-nsec-chain":
+		resp.Names, err = zd.ShowNsecChain()
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
 
-			// zd.mu.Lock()
-			zd.SetOption(OptFrozen, true)
-			//zd.mu.Unlock()
-			if zd.Options[OptDirty] {
-				tosource := true
-				zd.WriteZone(tosource, false)
-				resp.Msg = fmt.Sprintf("Zone %s is now frozen, modifications will be written to disk", zd.ZoneName)
-			} else {
-				resp.Msg = fmt.Sprintf("Zone %s is now frozen", zd.ZoneName)
-			}
+	case "freeze":
+		// Role check FIRST (Fix C). OptFrozen gates DDNS and nothing else
+		// (see updateresponder.go) — it does not pause refresh — so on a
+		// secondary, where allow-updates is always normalized off, it is
+		// functionally inert. Refusing it costs nothing real.
+		//
+		// Order matters: the allow-updates precondition below would
+		// otherwise fire first and tell the operator to enable an option
+		// the normalizer immediately strips again. Name the true reason.
+		if msg := zoneOriginationRefusal(zd, "freeze"); msg != "" {
+			resp.Error = true
+			resp.ErrorMsg = msg
+			return
+		}
+		// If a zone has modifications, freezing implies that the updated
+		// zone data should be written out to disk.
+		if !zd.Options[OptAllowUpdates] && !zd.Options[OptAllowChildUpdates] {
+			resp.Error = true
+			resp.ErrorMsg = fmt.Sprintf("FreezeZone: zone %s does not allow updates. Freeze would be a no-op", zd.ZoneName)
+			return
+		}
 
-		case "thaw":
-			// Role check first, same reasoning as freeze above.
-			if msg := zoneOriginationRefusal(zd, "thaw"); msg != "" {
-				resp.Error = true
-				resp.ErrorMsg = msg
-				return
-			}
-			if !zd.Options[OptAllowUpdates] && !zd.Options[OptAllowChildUpdates] {
-				resp.Error = true
-				resp.ErrorMsg = fmt.Sprintf("ThawZone: zone %s does not allow updates. Thaw would be a no-op", zd.ZoneName)
-				return
-			}
-			if !zd.Options[OptFrozen] {
-				resp.Error = true
-				resp.ErrorMsg = fmt.Sprintf("ThawZone: zone %s is not frozen", zd.ZoneName)
-				return
-			}
-			zd.SetOption(OptFrozen, false)
-			resp.Msg = fmt.Sprintf("Zone %s is now thawed", zd.ZoneName)
-
-		case "reload":
-			// XXX: Note: if the zone allows updates and is dirty, then reloading should be denied
-			lgApi.Info("reloading zone, will check for delegation data changes")
-			// resp.Msg, err = ReloadZone(cp.Zone, cp.Force)
-			resp.Msg, err = zd.ReloadZone(refreshq, zp.Force, zp.Wait, zp.Timeout)
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-			}
+		if zd.Options[OptFrozen] {
+			resp.Error = true
+			resp.ErrorMsg = fmt.Sprintf("FreezeZone: zone %s is already frozen", zd.ZoneName)
+			return
+		}
 
-		case "list-zones":
-			zones := map[string]ZoneConf{}
-			// Single-zone describe path (`zone desc`): scope the response to the
-			// named zone and populate the extra detail fields (last-applied policy
-			// record + bound-policy algorithm/lifetime detail). The bulk path below
-			// is left byte-identical so `zone list` (plain and -v) is unchanged.
-			if zp.Zone != "" {
-				zname := dns.Fqdn(zp.Zone)
-				zd, ok := Zones.Get(zname)
-				if !ok || zd == nil {
-					resp.Error = true
-					resp.ErrorMsg = fmt.Sprintf("Zone %s is unknown", zname)
-					return
-				}
-				zconf := buildListZoneConf(zd, zname, kdb)
-				populateZoneDescDetail(r.Context(), &zconf, zd, zname, kdb)
-				zones[zname] = zconf
-				resp.Zones = zones
-				return
-			}
-			lgApi.Debug("listing zones", "count", len(Zones.Keys()))
-			for item := range Zones.IterBuffered() {
-				zones[item.Key] = buildListZoneConf(item.Val, item.Key, kdb)
-			}
-			resp.Zones = zones
+		// zd.mu.Lock()
+		zd.SetOption(OptFrozen, true)
+		//zd.mu.Unlock()
+		if zd.Options[OptDirty] {
+			tosource := true
+			zd.WriteZone(tosource, false)
+			resp.Msg = fmt.Sprintf("Zone %s is now frozen, modifications will be written to disk", zd.ZoneName)
+		} else {
+			resp.Msg = fmt.Sprintf("Zone %s is now frozen", zd.ZoneName)
+		}
 
-		case "add":
-			zoneType := Secondary
-			switch strings.ToLower(zp.ZoneType) {
-			case "", "secondary":
-				// default
-			case "primary":
-				zoneType = Primary
-			default:
-				resp.Error = true
-				resp.ErrorMsg = fmt.Sprintf("unknown zone type %q (valid: primary, secondary)", zp.ZoneType)
-				return
-			}
-			msg, err := Conf.ProvisionDynamicZone(r.Context(), DynamicZoneInput{
-				Name:       zp.Zone,
-				Type:       zoneType,
-				Template:   zp.Template,
-				Primaries:  zp.Primaries,
-				Options:    zoneOptionsFromStrings(zp.Options),
-				TsigName:   zp.TsigName,
-				TsigSecret: zp.TsigSecret,
-				TsigAlgo:   zp.TsigAlgo,
-			}, true)
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-				return
-			}
-			resp.Status = "accepted"
-			resp.Zone = dns.Fqdn(zp.Zone)
-			resp.Msg = msg
+	case "thaw":
+		// Role check first, same reasoning as freeze above.
+		if msg := zoneOriginationRefusal(zd, "thaw"); msg != "" {
+			resp.Error = true
+			resp.ErrorMsg = msg
+			return
+		}
+		if !zd.Options[OptAllowUpdates] && !zd.Options[OptAllowChildUpdates] {
+			resp.Error = true
+			resp.ErrorMsg = fmt.Sprintf("ThawZone: zone %s does not allow updates. Thaw would be a no-op", zd.ZoneName)
+			return
+		}
+		if !zd.Options[OptFrozen] {
+			resp.Error = true
+			resp.ErrorMsg = fmt.Sprintf("ThawZone: zone %s is not frozen", zd.ZoneName)
+			return
+		}
+		zd.SetOption(OptFrozen, false)
+		resp.Msg = fmt.Sprintf("Zone %s is now thawed", zd.ZoneName)
+
+	case "reload":
+		// XXX: Note: if the zone allows updates and is dirty, then reloading should be denied
+		lgApi.Info("reloading zone, will check for delegation data changes")
+		// resp.Msg, err = ReloadZone(cp.Zone, cp.Force)
+		resp.Msg, err = zd.ReloadZone(refreshq, zp.Force, zp.Wait, zp.Timeout)
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+		}
 
-		case "delete":
-			msg, err := Conf.RemoveDynamicZone(zp.Zone)
-			if err != nil {
+	case "list-zones":
+		zones := map[string]ZoneConf{}
+		// Single-zone describe path (`zone desc`): scope the response to the
+		// named zone and populate the extra detail fields (last-applied policy
+		// record + bound-policy algorithm/lifetime detail). The bulk path below
+		// is left byte-identical so `zone list` (plain and -v) is unchanged.
+		if zp.Zone != "" {
+			zname := dns.Fqdn(zp.Zone)
+			zd, ok := Zones.Get(zname)
+			if !ok || zd == nil {
 				resp.Error = true
-				resp.ErrorMsg = err.Error()
+				resp.ErrorMsg = fmt.Sprintf("Zone %s is unknown", zname)
 				return
 			}
-			resp.Msg = msg
-
-		case "modify":
-			msg, err := Conf.ModifyDynamicZone(r.Context(), DynamicZoneInput{
-				Name:       zp.Zone,
-				Type:       Secondary,
-				Primaries:  zp.Primaries,
-				Options:    zoneOptionsFromStrings(zp.Options),
-				TsigName:   zp.TsigName,
-				TsigSecret: zp.TsigSecret,
-				TsigAlgo:   zp.TsigAlgo,
-			})
-			if err != nil {
-				resp.Error = true
-				resp.ErrorMsg = err.Error()
-				return
-			}
-			resp.Status = "accepted"
-			resp.Msg = msg
-
-		case "list-dynamic":
-			// The persistable dynamic subset (catalog members + API-managed),
-			// per ShouldPersistZone — not all zones. Catalog members are listed
-			// (read-only here) but only OptApiManagedZone zones are mutable via
-			// delete/modify.
-			zones := map[string]ZoneConf{}
-			for _, zc := range Conf.getDynamicZonesFromZonesMap() {
-				if zd, ok := Zones.Get(zc.Name); ok {
-					zc.Provisioning = zoneProvisioning(zd)
-					zc.ApiManaged = zd.Options[OptApiManagedZone]
-					// Surface the zone's error/warning state (e.g. ConfigWarning
-					// for a partially-resolved primary set) — zoneDataToZoneConf
-					// deliberately omits runtime error fields.
-					zc.Error = zd.Error
-					zc.ErrorType = zd.ErrorType
-					zc.ErrorMsg = zd.ErrorMsg
-				}
-				zones[zc.Name] = zc
-			}
+			zconf := buildListZoneConf(zd, zname, kdb)
+			populateZoneDescDetail(r.Context(), &zconf, zd, zname, kdb)
+			zones[zname] = zconf
 			resp.Zones = zones
-
+			return
+		}
+		lgApi.Debug("listing zones", "count", len(Zones.Keys()))
+		for item := range Zones.IterBuffered() {
+			zones[item.Key] = buildListZoneConf(item.Val, item.Key, kdb)
+		}
+		resp.Zones = zones
+
+	case "add":
+		zoneType := Secondary
+		switch strings.ToLower(zp.ZoneType) {
+		case "", "secondary":
+			// default
+		case "primary":
+			zoneType = Primary
 		default:
-			resp.ErrorMsg = fmt.Sprintf("Unknown zone command: %s", zp.Command)
 			resp.Error = true
+			resp.ErrorMsg = fmt.Sprintf("unknown zone type %q (valid: primary, secondary)", zp.ZoneType)
+			return
+		}
+		msg, err := Conf.ProvisionDynamicZone(r.Context(), DynamicZoneInput{
+			Name:       zp.Zone,
+			Type:       zoneType,
+			Template:   zp.Template,
+			Primaries:  zp.Primaries,
+			Options:    zoneOptionsFromStrings(zp.Options),
+			TsigName:   zp.TsigName,
+			TsigSecret: zp.TsigSecret,
+			TsigAlgo:   zp.TsigAlgo,
+		}, true)
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+			return
+		}
+		resp.Status = "accepted"
+		resp.Zone = dns.Fqdn(zp.Zone)
+		resp.Msg = msg
+
+	case "delete":
+		msg, err := Conf.RemoveDynamicZone(zp.Zone)
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+			return
+		}
+		resp.Msg = msg
+
+	case "modify":
+		msg, err := Conf.ModifyDynamicZone(r.Context(), DynamicZoneInput{
+			Name:       zp.Zone,
+			Type:       Secondary,
+			Primaries:  zp.Primaries,
+			Options:    zoneOptionsFromStrings(zp.Options),
+			TsigName:   zp.TsigName,
+			TsigSecret: zp.TsigSecret,
+			TsigAlgo:   zp.TsigAlgo,
+		})
+		if err != nil {
+			resp.Error = true
+			resp.ErrorMsg = err.Error()
+			return
 		}
+		resp.Status = "accepted"
+		resp.Msg = msg
+
+	case "list-dynamic":
+		// The persistable dynamic subset (catalog members + API-managed),
+		// per ShouldPersistZone — not all zones. Catalog members are listed
+		// (read-only here) but only OptApiManagedZone zones are mutable via
+		// delete/modify.
+		zones := map[string]ZoneConf{}
+		for _, zc := range Conf.getDynamicZonesFromZonesMap() {
+			if zd, ok := Zones.Get(zc.Name); ok {
+				zc.Provisioning = zoneProvisioning(zd)
+				zc.ApiManaged = zd.Options[OptApiManagedZone]
+				// Surface the zone's error/warning state (e.g. ConfigWarning
+				// for a partially-resolved primary set) — zoneDataToZoneConf
+				// deliberately omits runtime error fields.
+				zc.Error = zd.Error
+				zc.ErrorType = zd.ErrorType
+				zc.ErrorMsg = zd.ErrorMsg
+			}
+			zones[zc.Name] = zc
+		}
+		resp.Zones = zones
+
+	default:
+		resp.ErrorMsg = fmt.Sprintf("Unknown zone command: %s", zp.Command)
+		resp.Error = true
 	}
+	return resp
 }
 
 // zoneProvisioning derives the display-only lifecycle string from ZoneStatus
@@ -501,11 +557,12 @@ func zoneOptionsFromStrings(strs []string) map[ZoneOption]bool {
 // a differently-named but SAME-algorithm policy (a change of sig-validity, TTLs
 // or key lifetimes).
 //
-// An abrupt ALGORITHM change is not applied here: the reconcile backstop in
-// SignZone refuses a KSK algorithm change (always) and a ZSK algorithm change in
-// strict mode, and the transactional core then reverts, persisting nothing. So
-// set-policy either applies a same-algorithm change immediately or refuses; it
-// never performs the legacy synchronous key-swap. (Route a gradual ZSK algorithm
+// An abrupt ALGORITHM change is not applied here: a KSK algorithm change is
+// refused up front (it is a parent-coordinated roll, started by change-policy),
+// and the reconcile backstop in SignZone refuses a ZSK algorithm change in
+// strict mode, after which the transactional core reverts, persisting nothing.
+// So set-policy either applies a same-algorithm change immediately or refuses;
+// it never performs the legacy synchronous key-swap. (Route a gradual algorithm
 // roll via change-policy; force an abrupt switch via policy-reset.)
 func setZonePolicy(ctx context.Context, zd *ZoneData, kdb *KeyDB, policyName string) (string, error) {
 	policyName = strings.TrimSpace(policyName)
@@ -534,13 +591,19 @@ func setZonePolicy(ctx context.Context, zd *ZoneData, kdb *KeyDB, policyName str
 	zd.mu.Lock()
 	oldName := zd.DnssecPolicyName
 	var oldKSKAlg, oldZSKAlg uint8
+	var oldMode string
 	if zd.DnssecPolicy != nil {
 		oldKSKAlg, oldZSKAlg = zd.DnssecPolicy.KSKAlgorithm, zd.DnssecPolicy.ZSKAlgorithm
+		oldMode = zd.DnssecPolicy.Mode
 	}
 	zd.mu.Unlock()
 	// A different algorithm in either role means new keys are introduced
 	// alongside the retired old ones — the zone is transiently double-signed.
 	algChanged := oldKSKAlg != pol.KSKAlgorithm || oldZSKAlg != pol.ZSKAlgorithm
+	if oldKSKAlg != 0 && oldKSKAlg != pol.KSKAlgorithm && oldMode != DnssecPolicyModeCSK && pol.Mode != DnssecPolicyModeCSK {
+		return "", fmt.Errorf("policy-set: policy %q changes the KSK algorithm of zone %s (%s→%s); use change-policy to roll it via the auto-rollover engine",
+			policyName, zd.ZoneName, dns.AlgorithmToString[oldKSKAlg], dns.AlgorithmToString[pol.KSKAlgorithm])
+	}
 
 	// Rebind → re-sign → persist applied + CLI override, transactionally: on a
 	// sign failure the shared core reverts the in-memory binding and persists
@@ -582,7 +645,8 @@ func setZonePolicy(ctx context.Context, zd *ZoneData, kdb *KeyDB, policyName str
 // ZonePolicyOverride target + rebinds zd.DnssecPolicy, then the existing FIFO
 // ZSK pipeline drains in order. `auto-rollover asap -z <zone> --zsk` is the
 // throttle. The relaxed reconcile (sign.go) no-ops the synchronous retire, so
-// reusing set-policy's path is safe (D3).
+// reusing set-policy's path is safe (D3). A KSK ALGORITHM change is bound the
+// same way and then carried by the auto-rollover engine (ksk_alg_rollover.go).
 //
 // Entry-layer safety gates, all validated BEFORE any override write or rebind
 // so the zone is never left half-changed:
@@ -592,8 +656,11 @@ func setZonePolicy(ctx context.Context, zd *ZoneData, kdb *KeyDB, policyName str
 //     a time (§4.1).
 //   - re-entrancy: a ZSK alg roll already in flight (fuller drain-window
 //     predicate): refused.
-//   - KSK-only alg target / strict mode: deferred to the reconcile, which
-//     refuses (defensive backstop) — but we surface a clean error here too.
+//   - KSK-only alg target: handed to the auto-rollover engine, after
+//     kskAlgRollPreflight (engine-capable policy, no roll in flight, parent
+//     not known to refuse) — the reconcile no-ops on it.
+//   - strict mode ZSK alg target: deferred to the reconcile, which refuses
+//     (defensive backstop) — but we surface a clean error here too.
 func changeZonePolicy(ctx context.Context, zd *ZoneData, kdb *KeyDB, policyName string) (string, error) {
 	policyName = strings.TrimSpace(policyName)
 	if policyName == "" {
@@ -639,10 +706,18 @@ func changeZonePolicy(ctx context.Context, zd *ZoneData, kdb *KeyDB, policyName
 			dns.AlgorithmToString[curZSKAlg], dns.AlgorithmToString[pol.ZSKAlgorithm], zd.ZoneName)
 	}
 
-	// KSK-only algorithm change: not implemented (parent-coordinated engine).
+	// KSK-only algorithm change: carried by the auto-rollover engine
+	// (ksk_alg_rollover.go). Refuse anything the engine could not finish.
 	if kskChanged {
-		return "", fmt.Errorf("change-policy: KSK algorithm rollover not implemented for zone %s (%s→%s); route via the auto-rollover engine — not yet built",
-			zd.ZoneName, dns.AlgorithmToString[curKSKAlg], dns.AlgorithmToString[pol.KSKAlgorithm])
+		if err := kskAlgRollPreflight(kdb, zd.ZoneName, &pol); err != nil {
+			return "", fmt.Errorf("change-policy: zone %s (KSK %s→%s): %w",
+				zd.ZoneName, dns.AlgorithmToString[curKSKAlg], dns.AlgorithmToString[pol.KSKAlgorithm], err)
+		}
+	} else if r, err := LoadKskAlgRoll(kdb, zd.ZoneName); err != nil {
+		return "", fmt.Errorf("change-policy: checking KSK algorithm rollover for zone %s: %w", zd.ZoneName, err)
+	} else if r.InFlight() && zskChanged {
+		return "", fmt.Errorf("change-policy: a KSK algorithm rollover is in progress for zone %s (%s→%s); roll one role at a time",
+			zd.ZoneName, dns.AlgorithmToString[r.FromAlg], dns.AlgorithmToString[r.ToAlg])
 	}
 
 	// Re-entrancy: refuse if a ZSK alg roll is already in flight. "In flight" is
@@ -688,7 +763,12 @@ func changeZonePolicy(ctx context.Context, zd *ZoneData, kdb *KeyDB, policyName
 	} else {
 		fmt.Fprintf(&b, "Zone %s: DNSSEC policy bound to %q.\n", zd.ZoneName, policyName)
 	}
-	if zskChanged {
+	if kskChanged {
+		fmt.Fprintf(&b, "KSK algorithm will roll %s → %s via the auto-rollover engine: a %s KSK is added and double-signs the DNSKEY RRset, the parent DS RRset becomes {old, new} and then {new}, and the old KSK is removed once the old DS can no longer be cached.\n",
+			dns.AlgorithmToString[curKSKAlg], dns.AlgorithmToString[pol.KSKAlgorithm], dns.AlgorithmToString[pol.KSKAlgorithm])
+		fmt.Fprintf(&b, "If the parent will not publish a DS for %s the roll is refused and the zone stays on the old KSK. Follow it with \"auto-rollover status -z %s\".\n",
+			dns.AlgorithmToString[pol.KSKAlgorithm], zd.ZoneName)
+	} else if zskChanged {
 		fmt.Fprintf(&b, "ZSK algorithm will roll %s → %s GRADUALLY: future-generated ZSKs carry the new algorithm and the existing keys drain in FIFO order.\n",
 			dns.AlgorithmToString[curZSKAlg], dns.AlgorithmToString[pol.ZSKAlgorithm])
 		fmt.Fprintf(&b, "This command does NOT perform the roll. It advances on the normal ZSK cadence, or run \"auto-rollover asap -z %s --zsk\" to promote the next standby now (repeat to accelerate).\n", zd.ZoneName)
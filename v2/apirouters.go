@@ -95,12 +95,20 @@ func (conf *Config) SetupAPIRouter(ctx context.Context) (*mux.Router, error) {
 	sr.HandleFunc("/zone", APIzone(&Globals.App, conf.Internal.RefreshZoneCh, kdb)).Methods("POST")
 	sr.HandleFunc("/catalog", APICatalog(&Globals.App)).Methods("POST")
 	sr.HandleFunc("/debug", APIdebug(conf)).Methods("POST")
+	sr.HandleFunc("/events", APIevents(conf)).Methods("GET")
 
 	if Globals.App.Type == AppTypeAuth || Globals.App.Type == AppTypeAgent {
 		sr.HandleFunc("/keystore", kdb.APIkeystore(conf)).Methods("POST")
 		sr.HandleFunc("/truststore", kdb.APItruststore()).Methods("POST")
 		sr.HandleFunc("/zone/dsync", APIzoneDsync(ctx, &Globals.App, conf.Internal.RefreshZoneCh, kdb)).Methods("POST")
 		sr.HandleFunc("/delegation", APIdelegation(conf.Internal.DelegationSyncQ)).Methods("POST")
+		sr.HandleFunc("/audit", APIaudit(conf)).Methods("GET")
+	}
+
+	// Change sets stage content changes to primary zones, which only
+	// the auth daemon serves.
+	if Globals.App.Type == AppTypeAuth {
+		sr.HandleFunc("/zone/changeset", APIchangeset(conf)).Methods("POST")
 	}
 
 	// Rollover read + write endpoints (rollover-overhaul phases 9 +
@@ -129,8 +137,13 @@ func (conf *Config) SetupAPIRouter(ctx context.Context) (*mux.Router, error) {
 	}
 	if Globals.App.Type == AppTypeScanner {
 		sr.HandleFunc("/scanner", APIscanner(conf, &Globals.App, conf.Internal.ScannerQ, kdb)).Methods("POST")
+	}
+	// The parent-side scanner (NOTIFY and sweeps) runs in tdns-auth; its job
+	// history and per-child state are inspected the same way.
+	if Globals.App.Type == AppTypeScanner || Globals.App.Type == AppTypeAuth {
 		sr.HandleFunc("/scanner/status", APIscannerStatus(conf)).Methods("GET")
 		sr.HandleFunc("/scanner/delete", APIscannerDelete(conf)).Methods("DELETE")
+		sr.HandleFunc("/scanner/children", APIscannerChildren(conf)).Methods("GET")
 	}
 	// Combiner API routes removed — combiner only exists in tdns-mp now.
 	// Routes are registered by tdns-mp via SetupMPCombinerRoutes.
@@ -154,6 +167,9 @@ func (conf *Config) SetupAPIRouter(ctx context.Context) (*mux.Router, error) {
 		}
 	}
 
+	// Resource-oriented /api/v2 alongside the command-style /api/v1.
+	conf.setupAPIv2Routes(rtr, apikey)
+
 	// sr.HandleFunc("/show/api", tdns.APIshowAPI(r)).Methods("GET")
 
 	return rtr, nil
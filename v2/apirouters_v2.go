@@ -0,0 +1,328 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ *
+ * The /api/v2 management API: resource-oriented routes with HTTP verbs,
+ * ETag / If-Match concurrency on zone serials and token pagination for
+ * listings. It sits beside the command-style /api/v1 and is implemented on
+ * the same internal functions (runZoneCommand, the ZoneUpdater, the keystore
+ * and catalog helpers), so the two generations cannot disagree. The route
+ * table below is also the source of the OpenAPI document served at
+ * /api/v2/openapi.json.
+ *
+ * Peers are not exposed: peer management is MP-only (tdns-mp).
+ */
+
+package tdns
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/miekg/dns"
+)
+
+const apiV2Prefix = "/api/v2"
+
+const (
+	apiV2DefaultPageSize = 100
+	apiV2MaxPageSize     = 1000
+)
+
+type apiV2Param struct {
+	Name        string
+	Description string
+}
+
+// apiV2Route is one /api/v2 endpoint. Request and Response are zero values of
+// the body types (nil for none) and only feed the OpenAPI generator.
+type apiV2Route struct {
+	Method   string
+	Path     string // relative to /api/v2, mux template syntax
+	Tag      string
+	Summary  string
+	Query    []apiV2Param
+	Request  interface{}
+	Response interface{}
+	Status   int       // success status; 0 means 200
+	ETag     bool      // response carries an ETag; mutations honour If-Match
+	Apps     []AppType // nil: every app served by SetupAPIRouter
+	Handler  func(conf *Config) http.HandlerFunc
+}
+
+var apiV2PageParams = []apiV2Param{
+	{"limit", fmt.Sprintf("page size (default %d, max %d)", apiV2DefaultPageSize, apiV2MaxPageSize)},
+	{"page_token", "next_page_token from the previous page"},
+}
+
+var apiV2KeystoreApps = []AppType{AppTypeAuth, AppTypeAgent}
+
+// apiV2Routes returns the /api/v2 route table.
+func apiV2Routes() []apiV2Route {
+	return []apiV2Route{
+		{Method: "GET", Path: "/openapi.json", Tag: "meta", Summary: "This OpenAPI document",
+			Handler: apiV2OpenAPI},
+
+		{Method: "GET", Path: "/zones", Tag: "zones", Summary: "List zones",
+			Query: apiV2PageParams, Response: APIv2ZoneList{}, Handler: apiV2ListZones},
+		{Method: "POST", Path: "/zones", Tag: "zones", Summary: "Provision a dynamic zone",
+			Request: APIv2ZoneCreate{}, Response: APIv2Result{}, Status: http.StatusAccepted, Handler: apiV2CreateZone},
+		{Method: "GET", Path: "/zones/{zone}", Tag: "zones", Summary: "Get a zone",
+			Response: APIv2Zone{}, ETag: true, Handler: apiV2GetZone},
+		{Method: "PATCH", Path: "/zones/{zone}", Tag: "zones", Summary: "Modify an API-managed zone",
+			Request: APIv2ZoneModify{}, Response: APIv2Result{}, Status: http.StatusAccepted, ETag: true, Handler: apiV2ModifyZone},
+		{Method: "DELETE", Path: "/zones/{zone}", Tag: "zones", Summary: "Remove an API-managed zone",
+			Response: APIv2Result{}, ETag: true, Handler: apiV2DeleteZone},
+		{Method: "POST", Path: "/zones/{zone}/actions/{action}", Tag: "zones",
+			Summary: "Run a zone action: " + strings.Join(apiV2ZoneActionNames(), ", "),
+			Request: APIv2ZoneAction{}, Response: APIv2Result{}, ETag: true, Handler: apiV2ZoneAction},
+
+		{Method: "GET", Path: "/zones/{zone}/rrsets", Tag: "rrsets", Summary: "List the RRsets of a zone",
+			Query:    append([]apiV2Param{{"type", "only RRsets of this type"}}, apiV2PageParams...),
+			Response: APIv2RRsetList{}, ETag: true, Handler: apiV2ListRRsets},
+		{Method: "GET", Path: "/zones/{zone}/rrsets/{owner}/{rrtype}", Tag: "rrsets", Summary: "Get an RRset",
+			Response: APIv2RRset{}, ETag: true, Handler: apiV2GetRRset},
+		{Method: "PUT", Path: "/zones/{zone}/rrsets/{owner}/{rrtype}", Tag: "rrsets", Summary: "Replace an RRset",
+			Request: APIv2RRsetPut{}, Response: APIv2RRset{}, ETag: true, Handler: apiV2PutRRset},
+		{Method: "DELETE", Path: "/zones/{zone}/rrsets/{owner}/{rrtype}", Tag: "rrsets", Summary: "Delete an RRset",
+			Response: APIv2Result{}, ETag: true, Handler: apiV2DeleteRRset},
+
+		{Method: "GET", Path: "/zones/{zone}/keys", Tag: "keys", Summary: "List the DNSSEC keys of a zone",
+			Query: []apiV2Param{{"state", "only keys in this state"}}, Response: APIv2KeyList{},
+			Apps: apiV2KeystoreApps, Handler: apiV2ListKeys},
+		{Method: "GET", Path: "/zones/{zone}/keys/{keyid}", Tag: "keys", Summary: "Get a DNSSEC key",
+			Response: APIv2Key{}, Apps: apiV2KeystoreApps, Handler: apiV2GetKey},
+
+		{Method: "GET", Path: "/zones/{zone}/policy", Tag: "policies", Summary: "Get the DNSSEC policy binding of a zone",
+			Response: APIv2ZonePolicy{}, ETag: true, Apps: apiV2KeystoreApps, Handler: apiV2GetZonePolicy},
+		{Method: "PUT", Path: "/zones/{zone}/policy", Tag: "policies", Summary: "Bind a zone to a DNSSEC policy",
+			Request: APIv2ZonePolicy{}, Response: APIv2Result{}, ETag: true, Apps: apiV2KeystoreApps, Handler: apiV2PutZonePolicy},
+		{Method: "DELETE", Path: "/zones/{zone}/policy", Tag: "policies", Summary: "Drop the policy override and re-sign under the config policy",
+			Response: APIv2Result{}, ETag: true, Apps: apiV2KeystoreApps, Handler: apiV2DeleteZonePolicy},
+		{Method: "GET", Path: "/policies", Tag: "policies", Summary: "List DNSSEC policies",
+			Query: apiV2PageParams, Response: APIv2PolicyList{}, Apps: apiV2KeystoreApps, Handler: apiV2ListPolicies},
+		{Method: "GET", Path: "/policies/{policy}", Tag: "policies", Summary: "Get a DNSSEC policy",
+			Response: DnssecPolicyInfo{}, Apps: apiV2KeystoreApps, Handler: apiV2GetPolicy},
+
+		{Method: "GET", Path: "/zones/{zone}/rollover", Tag: "rollovers", Summary: "Key rollover status of a zone",
+			Response: RolloverStatus{}, Apps: []AppType{AppTypeAuth}, Handler: apiV2GetRollover},
+
+		{Method: "GET", Path: "/catalogs/{catalog}/members", Tag: "catalogs", Summary: "List the member zones of a catalog",
+			Query: apiV2PageParams, Response: APIv2CatalogMemberList{}, Handler: apiV2ListCatalogMembers},
+		{Method: "PUT", Path: "/catalogs/{catalog}/members/{zone}", Tag: "catalogs", Summary: "Add a member zone to a catalog",
+			Request: APIv2CatalogMember{}, Response: APIv2Result{}, Status: http.StatusCreated, Handler: apiV2PutCatalogMember},
+		{Method: "DELETE", Path: "/catalogs/{catalog}/members/{zone}", Tag: "catalogs", Summary: "Remove a member zone from a catalog",
+			Response: APIv2Result{}, Handler: apiV2DeleteCatalogMember},
+	}
+}
+
+// apiV2AppRoutes filters the route table down to what the running app serves.
+func (conf *Config) apiV2AppRoutes() []apiV2Route {
+	var out []apiV2Route
+	for _, rt := range apiV2Routes() {
+		if rt.Apps != nil {
+			served := false
+			for _, a := range rt.Apps {
+				if a == Globals.App.Type {
+					served = true
+					break
+				}
+			}
+			if !served {
+				continue
+			}
+		}
+		out = append(out, rt)
+	}
+	return out
+}
+
+// setupAPIv2Routes registers /api/v2 on rtr, behind the same API key as v1.
+func (conf *Config) setupAPIv2Routes(rtr *mux.Router, apikey string) {
+	sr := rtr.PathPrefix(apiV2Prefix).Subrouter()
+	sr.Use(apiKeyAuthMiddleware(apikey))
+	for _, rt := range conf.apiV2AppRoutes() {
+		sr.HandleFunc(rt.Path, rt.Handler(conf)).Methods(rt.Method)
+	}
+}
+
+func apiV2OpenAPI(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeV2JSON(w, http.StatusOK, buildOpenAPIDoc(&Globals.App, conf.apiV2AppRoutes()))
+	}
+}
+
+// writeV2JSON writes v as the JSON response body with the given status.
+func writeV2JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		lgApi.Error("json encode failed", "api", "v2", "err", err)
+	}
+}
+
+func writeV2Error(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeV2JSON(w, status, APIv2Error{Status: status, Error: fmt.Sprintf(format, args...)})
+}
+
+// decodeV2Body decodes a JSON request body into v. An empty body is allowed
+// when optional is set and leaves v at its zero value.
+func decodeV2Body(w http.ResponseWriter, r *http.Request, v interface{}, optional bool) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		if optional {
+			return true
+		}
+		writeV2Error(w, http.StatusBadRequest, "missing request body")
+		return false
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if optional && errors.Is(err, io.EOF) {
+			return true
+		}
+		writeV2Error(w, http.StatusBadRequest, "bad request body: %v", err)
+		return false
+	}
+	return true
+}
+
+// --- ETags ---
+
+// zoneETag is the strong ETag of a zone at the given serial. All resources
+// inside a zone (RRsets, the policy binding) share it: any change to zone
+// content advances the published serial.
+func zoneETag(serial uint32) string {
+	return `"` + strconv.FormatUint(uint64(serial), 10) + `"`
+}
+
+// etagListMatches reports whether an If-Match / If-None-Match header value
+// matches etag. weak enables weak comparison (If-None-Match, RFC 9110 13.1.2).
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch enforces If-Match against the zone's current ETag and writes
+// 412 when it does not match. Absent If-Match means unconditional.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, zd *ZoneData) bool {
+	im := r.Header.Get("If-Match")
+	if im == "" {
+		return true
+	}
+	current := zoneETag(zd.publishedSerial())
+	if !etagListMatches(im, current, false) {
+		w.Header().Set("ETag", current)
+		writeV2Error(w, http.StatusPreconditionFailed, "zone %s has changed: ETag is %s, If-Match was %s", zd.ZoneName, current, im)
+		return false
+	}
+	return true
+}
+
+// writeV2Conditional sets the ETag and answers 304 when If-None-Match
+// matches, otherwise writes v.
+func writeV2Conditional(w http.ResponseWriter, r *http.Request, serial uint32, v interface{}) {
+	etag := zoneETag(serial)
+	w.Header().Set("ETag", etag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagListMatches(inm, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeV2JSON(w, http.StatusOK, v)
+}
+
+// --- pagination ---
+
+// v2Page holds the parsed pagination parameters of a listing request. Tokens
+// are opaque to clients; internally they are the last key of the previous
+// page, so a page stays stable while items before it come and go.
+type v2Page struct {
+	Limit int
+	After string
+}
+
+func parseV2Page(r *http.Request) (v2Page, error) {
+	p := v2Page{Limit: apiV2DefaultPageSize}
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return p, fmt.Errorf("bad limit %q", v)
+		}
+		if n > apiV2MaxPageSize {
+			n = apiV2MaxPageSize
+		}
+		p.Limit = n
+	}
+	if v := q.Get("page_token"); v != "" {
+		after, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return p, fmt.Errorf("bad page_token")
+		}
+		p.After = string(after)
+	}
+	return p, nil
+}
+
+// apply sorts keys and returns the slice of them on this page plus the token
+// for the next page ("" on the last page).
+func (p v2Page) apply(keys []string) ([]string, string) {
+	sort.Strings(keys)
+	start := 0
+	if p.After != "" {
+		start = sort.SearchStrings(keys, p.After)
+		if start < len(keys) && keys[start] == p.After {
+			start++
+		}
+	}
+	end := start + p.Limit
+	if end >= len(keys) {
+		return keys[start:], ""
+	}
+	return keys[start:end], base64.RawURLEncoding.EncodeToString([]byte(keys[end-1]))
+}
+
+// --- path parameters ---
+
+// v2Zone resolves the {zone} path parameter, writing 404 when unknown.
+func v2Zone(w http.ResponseWriter, r *http.Request) (*ZoneData, bool) {
+	zname := dns.Fqdn(mux.Vars(r)["zone"])
+	zd, ok := Zones.Get(zname)
+	if !ok || zd == nil {
+		writeV2Error(w, http.StatusNotFound, "zone %s is unknown", zname)
+		return nil, false
+	}
+	return zd, true
+}
+
+// v2OwnerName turns the {owner} path parameter into an FQDN: "@" is the
+// apex, a name with a trailing dot is absolute and anything else is relative
+// to the zone.
+func v2OwnerName(owner, zone string) string {
+	switch {
+	case owner == "@":
+		return zone
+	case strings.HasSuffix(owner, "."):
+		return owner
+	default:
+		return owner + "." + zone
+	}
+}
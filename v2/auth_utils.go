@@ -34,10 +34,12 @@ func (zd *ZoneData) findDelegationFrom(snap *zoneSnapshot, qname string, dnssec_
 			zd.Logger.Printf("FindDelegation for qname='%s': there are RRs for '%s'", qname, child)
 			if childns, ok := childrrs.RRtypes.Get(dns.TypeNS); ok {
 				childds := childrrs.RRtypes.GetOnlyRRSet(dns.TypeDS)
+				childdeleg := childrrs.RRtypes.GetOnlyRRSet(core.TypeDELEG)
 				cdd := ChildDelegationData{
-					ChildName: child,
-					NS_rrset:  &childns,
-					DS_rrset:  &childds,
+					ChildName:   child,
+					NS_rrset:    &childns,
+					DS_rrset:    &childds,
+					DELEG_rrset: &childdeleg,
 				}
 				zd.Logger.Printf("FindDelegation: cdd=%v", cdd)
 				v4glue, v6glue, v4glue_rrsigs, v6glue_rrsigs := zd.findGlueSimpleFrom(snap, childns, dnssec_ok)
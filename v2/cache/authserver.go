@@ -53,6 +53,12 @@ type AuthServer struct {
 	Alpn             []string // {"do53", "doq", "dot", "doh"}
 	Transports       []core.Transport
 	TransportWeights map[core.Transport]uint8 // independent oots weights [0,100]; Do53 is ultimate fallback
+	// Port overrides the transport's default port (53, 853, 443) for all
+	// transports of this server. Zero means the default. Set from the port
+	// parameter of a DELEG record, and only on the private instance of that
+	// delegation (see AddServers): another zone served by the same name may
+	// use the default ports.
+	Port uint16
 	// Optional config-only field for stubs: colon-separated transport weights, e.g. "doq:30,dot:70"
 	// When provided in config, this overrides Alpn for building Transports/TransportWeights.
 	TransportSignal string   `yaml:"transport" mapstructure:"transport"`
@@ -258,6 +264,16 @@ func (as *AuthServer) GetSrc() string {
 	return as.Src
 }
 
+// GetPort returns the port override, or zero for the transport defaults. Thread-safe.
+func (as *AuthServer) GetPort() uint16 {
+	if as == nil {
+		return 0
+	}
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	return as.Port
+}
+
 // SetSrc sets the source string if it's more specific than the current value. Thread-safe.
 func (as *AuthServer) SetSrc(src string) {
 	if as == nil || src == "" {
@@ -46,13 +46,14 @@ type CachedRRset struct {
 }
 
 type RRsetCacheT struct {
-	RRsets        *core.ConcurrentMap[string, CachedRRset]
+	RRsets        *RRsetStore // sharded, byte-budgeted SLRU; see rrset_store.go
 	Servers       *core.ConcurrentMap[string, []string]
 	ServerMap     *core.ConcurrentMap[string, map[string]*AuthServer] // map[zone]map[nsname]*AuthServer
 	AuthServerMap *core.ConcurrentMap[string, *AuthServer]            // Global map: nsname -> *AuthServer (ensures single instance per nameserver)
 	ZoneMap       *core.ConcurrentMap[string, *Zone]                  // map[zone]*Zone
 	ServerTLSA    *core.ConcurrentMap[string, *ServerTLSARecords]     // nsname -> validated TLSA cache, decoupled from AuthServer instances
 	DnskeyCache   *DnskeyCacheT
+	NTAs          *NTAStore // negative trust anchors; see nta.go
 	DNSClient     map[core.Transport]core.DNSClienter
 	//Options                map[ImrOption]string
 	Primed               bool
@@ -0,0 +1,216 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Negative trust anchors (RFC 7646). A name at or below an NTA is not
+// validated: ValidateRRset and ValidateNegativeResponse return Insecure
+// for it, so a zone with broken DNSSEC resolves (unauthenticated) instead
+// of failing for everybody. An NTA added at runtime expires, and the IMR
+// removes it as soon as the zone validates again. Permanent NTAs are the
+// configured per-domain overrides (domain-insecure).
+
+// DefaultNTALifetime is used when an NTA is added without a lifetime.
+// RFC 7646 section 2 suggests a day or less.
+const DefaultNTALifetime = 24 * time.Hour
+
+// NTA is one negative trust anchor.
+type NTA struct {
+	Zone      string    `json:"zone"`
+	Reason    string    `json:"reason,omitempty"`
+	Added     time.Time `json:"added"`
+	Expires   time.Time `json:"expires,omitempty"`
+	Permanent bool      `json:"permanent,omitempty"` // configured domain-insecure: never expires, never probed
+	LastProbe time.Time `json:"last_probe,omitempty"`
+	Probe     string    `json:"probe,omitempty"` // outcome of the last re-probe
+}
+
+// NTAStore holds the negative trust anchors of one cache.
+type NTAStore struct {
+	mu   sync.RWMutex
+	ntas map[string]*NTA
+}
+
+func NewNTAStore() *NTAStore {
+	return &NTAStore{ntas: map[string]*NTA{}}
+}
+
+// Add installs (or replaces) an NTA for zone, expiring after lifetime.
+func (s *NTAStore) Add(zone string, lifetime time.Duration, reason string, now time.Time) (*NTA, error) {
+	if lifetime < 0 {
+		return nil, fmt.Errorf("negative lifetime %v", lifetime)
+	}
+	if lifetime == 0 {
+		lifetime = DefaultNTALifetime
+	}
+	return s.put(&NTA{Zone: zone, Reason: reason, Added: now, Expires: now.Add(lifetime)})
+}
+
+// AddPermanent installs an NTA for zone that never expires.
+func (s *NTAStore) AddPermanent(zone, reason string, now time.Time) (*NTA, error) {
+	return s.put(&NTA{Zone: zone, Reason: reason, Added: now, Permanent: true})
+}
+
+// AddPermanentIfAbsent is AddPermanent, except that an NTA zone already
+// has (configured or added by an operator) is left as it is. It reports
+// whether the NTA was added.
+func (s *NTAStore) AddPermanentIfAbsent(zone, reason string, now time.Time) (bool, error) {
+	nta, err := s.store(&NTA{Zone: zone, Reason: reason, Added: now, Permanent: true}, false)
+	return nta != nil, err
+}
+
+func (s *NTAStore) put(nta *NTA) (*NTA, error) {
+	return s.store(nta, true)
+}
+
+// store installs nta, replacing an existing NTA for the zone only if
+// replace is set. It returns a copy of nta, or nil if it was not installed.
+func (s *NTAStore) store(nta *NTA, replace bool) (*NTA, error) {
+	nta.Zone = dns.CanonicalName(nta.Zone)
+	if _, ok := dns.IsDomainName(nta.Zone); !ok {
+		return nil, fmt.Errorf("invalid zone name %q", nta.Zone)
+	}
+	if nta.Zone == "." {
+		return nil, fmt.Errorf("a negative trust anchor for the root zone would disable validation entirely")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.ntas[nta.Zone]; exists && !replace {
+		return nil, nil
+	}
+	s.ntas[nta.Zone] = nta
+	c := *nta
+	return &c, nil
+}
+
+// Remove deletes the NTA for zone and reports whether there was one.
+func (s *NTAStore) Remove(zone string) bool {
+	zone = dns.CanonicalName(zone)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.ntas[zone]; !ok {
+		return false
+	}
+	delete(s.ntas, zone)
+	return true
+}
+
+// RemoveWithReason deletes the NTA for zone only if it was added with
+// reason, and reports whether it did.
+func (s *NTAStore) RemoveWithReason(zone, reason string) bool {
+	zone = dns.CanonicalName(zone)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if nta, ok := s.ntas[zone]; !ok || nta.Reason != reason {
+		return false
+	}
+	delete(s.ntas, zone)
+	return true
+}
+
+// Covering returns the zone of the closest unexpired NTA at or above name,
+// or "" when name is not covered.
+func (s *NTAStore) Covering(name string, now time.Time) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.ntas) == 0 {
+		return ""
+	}
+	name = dns.CanonicalName(name)
+	for {
+		if nta, ok := s.ntas[name]; ok && (nta.Permanent || now.Before(nta.Expires)) {
+			return name
+		}
+		if name == "." {
+			return ""
+		}
+		if i := strings.IndexByte(name, '.'); i >= 0 && i < len(name)-1 {
+			name = name[i+1:]
+		} else {
+			name = "."
+		}
+	}
+}
+
+// Expire removes the NTAs that have expired and returns their zones.
+func (s *NTAStore) Expire(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var gone []string
+	for zone, nta := range s.ntas {
+		if !nta.Permanent && !now.Before(nta.Expires) {
+			delete(s.ntas, zone)
+			gone = append(gone, zone)
+		}
+	}
+	return gone
+}
+
+// SetProbe records the outcome of a re-probe of zone.
+func (s *NTAStore) SetProbe(zone, outcome string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if nta, ok := s.ntas[dns.CanonicalName(zone)]; ok {
+		nta.LastProbe, nta.Probe = now, outcome
+	}
+}
+
+// List returns copies of all NTAs, sorted by zone.
+func (s *NTAStore) List() []NTA {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]NTA, 0, len(s.ntas))
+	for _, nta := range s.ntas {
+		out = append(out, *nta)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Zone < out[j].Zone })
+	return out
+}
+
+type ignoreNTAKey struct{}
+
+// IgnoreNTAs returns a context in which validation disregards the NTAs and
+// any validation state cached for names below them. Used to re-probe a
+// zone that is under an NTA.
+func IgnoreNTAs(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ignoreNTAKey{}, true)
+}
+
+// IgnoringNTAs reports whether ctx is an NTA re-probe. Lookups made for a
+// probe must not be shared with other callers, which validate under the
+// NTAs.
+func IgnoringNTAs(ctx context.Context) bool {
+	return ctx != nil && ctx.Value(ignoreNTAKey{}) != nil
+}
+
+// ntaCovers reports whether validation of name is disabled by an NTA.
+func (rrcache *RRsetCacheT) ntaCovers(ctx context.Context, name string) bool {
+	if rrcache.NTAs == nil {
+		return false
+	}
+	if IgnoringNTAs(ctx) {
+		return false
+	}
+	return rrcache.NTAs.Covering(name, time.Now()) != ""
+}
+
+// ntaProbing reports whether ctx is a re-probe and name is below an NTA,
+// in which case cached validation states must not be reused.
+func (rrcache *RRsetCacheT) ntaProbing(ctx context.Context, name string) bool {
+	return rrcache.NTAs != nil && IgnoringNTAs(ctx) && rrcache.NTAs.Covering(name, time.Now()) != ""
+}
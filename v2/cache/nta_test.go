@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+func TestNTAStore(t *testing.T) {
+	s := NewNTAStore()
+	now := time.Now()
+	if _, err := s.Add(".", time.Hour, "", now); err == nil {
+		t.Error("NTA for the root accepted")
+	}
+	if _, err := s.Add("example.", -time.Hour, "", now); err == nil {
+		t.Error("negative lifetime accepted")
+	}
+	nta, err := s.Add("Broken.Example", 0, "bad RRSIGs", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nta.Zone != "broken.example." || !nta.Expires.Equal(now.Add(DefaultNTALifetime)) {
+		t.Errorf("added NTA %+v", nta)
+	}
+	if _, err := s.AddPermanent("lab.internal", "domain-insecure", now); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"broken.example.":         "broken.example.",
+		"www.sub.broken.example.": "broken.example.",
+		"notbroken.example.":      "",
+		"example.":                "",
+		"host.lab.internal.":      "lab.internal.",
+	} {
+		if got := s.Covering(name, now); got != want {
+			t.Errorf("Covering(%s) = %q, want %q", name, got, want)
+		}
+	}
+
+	later := now.Add(DefaultNTALifetime)
+	if got := s.Covering("www.broken.example.", later); got != "" {
+		t.Errorf("expired NTA still covers: %q", got)
+	}
+	if gone := s.Expire(later); len(gone) != 1 || gone[0] != "broken.example." {
+		t.Errorf("Expire = %v, want only broken.example.", gone)
+	}
+	if got := s.Covering("host.lab.internal.", later.Add(365*24*time.Hour)); got != "lab.internal." {
+		t.Error("permanent NTA expired")
+	}
+	if !s.Remove("lab.internal") || s.Remove("lab.internal.") || len(s.List()) != 0 {
+		t.Error("Remove did not remove exactly once")
+	}
+}
+
+func TestValidateUnderNTA(t *testing.T) {
+	rrcache := NewRRsetCache(log.New(os.Stderr, "", log.LstdFlags), false, false)
+	if _, err := rrcache.NTAs.Add("broken.example.", time.Hour, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := dns.NewRR("www.broken.example. 300 IN A 192.0.2.1")
+	rrset := &core.RRset{Name: "www.broken.example.", Class: dns.ClassINET, RRtype: dns.TypeA, RRs: []dns.RR{a}}
+	fetcher := func(ctx context.Context, qname string, qtype uint16, servers map[string]*AuthServer) (*core.RRset, error) {
+		t.Fatalf("validation below an NTA fetched %s %s", qname, dns.TypeToString[qtype])
+		return nil, nil
+	}
+
+	state, err := rrcache.ValidateRRset(context.Background(), rrset, fetcher)
+	if err != nil || state != ValidationStateInsecure {
+		t.Errorf("ValidateRRset below NTA = %s, %v; want insecure", ValidationStateToString[state], err)
+	}
+	soa, _ := dns.NewRR("broken.example. 300 IN SOA ns.broken.example. h.broken.example. 1 2 3 4 5")
+	neg := []*core.RRset{{Name: "broken.example.", Class: dns.ClassINET, RRtype: dns.TypeSOA, RRs: []dns.RR{soa}}}
+	state, rcode, err := rrcache.ValidateNegativeResponse(context.Background(), "nx.broken.example.", dns.TypeA,
+		dns.RcodeNameError, neg, fetcher)
+	if err != nil || state != ValidationStateInsecure || rcode != dns.RcodeNameError {
+		t.Errorf("ValidateNegativeResponse below NTA = %s, %d, %v; want insecure NXDOMAIN", ValidationStateToString[state], rcode, err)
+	}
+
+	// A re-probe ignores the NTA, and the validation states cached under it.
+	if !rrcache.ntaProbing(IgnoreNTAs(context.Background()), rrset.Name) || rrcache.ntaCovers(IgnoreNTAs(context.Background()), rrset.Name) {
+		t.Error("IgnoreNTAs context still honours the NTA")
+	}
+}
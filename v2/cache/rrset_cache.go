@@ -65,13 +65,14 @@ func NewRRsetCache(lg *log.Logger, verbose, debug bool) *RRsetCacheT {
 	client[core.TransportDoQ] = core.NewDNSClient(core.TransportDoQ, "853", nil) // RFC 9250: DoQ uses port 853
 
 	return &RRsetCacheT{
-		RRsets:               core.NewCmap[CachedRRset](),
+		RRsets:               NewRRsetStore(DefaultCacheBudget),
 		Servers:              core.NewCmap[[]string](),               // servers stored as []string{ "1.2.3.4:53", "9.8.7.6:53"}
 		ServerMap:            core.NewCmap[map[string]*AuthServer](), // servers stored as map[nsname]*AuthServer{}
 		AuthServerMap:        core.NewCmap[*AuthServer](),            // Global map: nsname -> *AuthServer (ensures single instance per nameserver)
 		ZoneMap:              core.NewCmap[*Zone](),                  // zone -> *Zone
 		ServerTLSA:           core.NewCmap[*ServerTLSARecords](),     // nsname -> validated TLSA cache
 		DnskeyCache:          DnskeyCache,
+		NTAs:                 NewNTAStore(),
 		Logger:               lg,
 		LineWidth:            130, // default line width for truncating long lines in logging and output
 		Verbose:              verbose,
@@ -106,8 +107,6 @@ func (rrcache *RRsetCacheT) Get(qname string, qtype uint16) *CachedRRset {
 	return &crrset
 }
 
-const rrsetCacheMaxEntries = 50000
-
 func (rrcache *RRsetCacheT) Set(qname string, qtype uint16, crrset *CachedRRset) {
 	lookupKey := fmt.Sprintf("%s::%d", qname, qtype)
 	if rrcache.Debug {
@@ -119,11 +118,6 @@ func (rrcache *RRsetCacheT) Set(qname string, qtype uint16, crrset *CachedRRset)
 		return
 	}
 
-	// Evict oldest entry if cache exceeds max size (and this is a new key)
-	if !rrcache.RRsets.Has(lookupKey) && rrcache.RRsets.Count() >= rrsetCacheMaxEntries {
-		rrcache.evictOldestRRset()
-	}
-
 	// Compute min TTL and set Expiration accordingly when RRset present
 	if crrset.RRset != nil && len(crrset.RRset.RRs) > 0 {
 		minTTL := crrset.RRset.RRs[0].Header().Ttl
@@ -152,26 +146,6 @@ func (rrcache *RRsetCacheT) Set(qname string, qtype uint16, crrset *CachedRRset)
 	rrcache.RRsets.Set(lookupKey, *crrset)
 }
 
-// evictOldestRRset removes the entry with the earliest expiration time from the RRsets cache.
-func (rrcache *RRsetCacheT) evictOldestRRset() {
-	var oldestKey string
-	var oldestTime time.Time
-	first := true
-	for item := range rrcache.RRsets.IterBuffered() {
-		if first || item.Val.Expiration.Before(oldestTime) {
-			oldestKey = item.Key
-			oldestTime = item.Val.Expiration
-			first = false
-		}
-	}
-	if !first {
-		rrcache.RRsets.Remove(oldestKey)
-		if rrcache.Debug {
-			log.Printf("RRsetCache: evicted oldest entry %s (expired %v) to stay within %d limit", oldestKey, oldestTime, rrsetCacheMaxEntries)
-		}
-	}
-}
-
 // FlushDomain removes cached RRsets at or below the provided domain.
 // When keepStructural is true, NS/DS/DNSKEY RRsets and the address
 // records for their nameservers are preserved.
@@ -482,6 +456,13 @@ func (rrcache *RRsetCacheT) AddServers(zone string, sm map[string]*AuthServer) e
 	}
 
 	for name, server := range sm {
+		if server.Port != 0 {
+			// A port from a DELEG record belongs to this delegation only;
+			// the server keeps a private instance in this zone's map, like
+			// a stub server.
+			serverMap[name] = server
+			continue
+		}
 		// Ensure we use a shared AuthServer instance across all zones
 		sharedServer := rrcache.GetOrCreateAuthServer(name)
 
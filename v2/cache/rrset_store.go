@@ -0,0 +1,417 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// RRsetStore is the backing store of the RRset cache: a sharded map with
+// per-shard segmented LRU (SLRU) eviction and byte accounting.
+//
+// Every entry belongs to one of three classes (positive, negative and
+// infrastructure, see ClassifyCachedRRset), each with its own byte budget, so
+// that a flood of NXDOMAINs for random subdomains can only evict other
+// negative entries and never the NS/DS/DNSKEY/glue data needed to reach the
+// zones. Within a class, new entries go to a probationary segment and move to
+// a protected segment on their first hit; eviction takes the least recently
+// used probationary entry first. That keeps a one-off scan from flushing the
+// working set. Insert, lookup and eviction are all O(1).
+//
+// The method set mirrors the subset of core.ConcurrentMap the cache used.
+type RRsetStore struct {
+	shards    []*storeShard
+	count     atomic.Int64
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions [numCacheClasses]atomic.Uint64
+	budget    atomic.Pointer[CacheBudget]
+}
+
+// CacheClass is the eviction class of a cached RRset.
+type CacheClass uint8
+
+const (
+	CacheClassPositive CacheClass = iota
+	CacheClassNegative
+	CacheClassInfra
+	numCacheClasses
+)
+
+var CacheClassToString = map[CacheClass]string{
+	CacheClassPositive: "positive",
+	CacheClassNegative: "negative",
+	CacheClassInfra:    "infrastructure",
+}
+
+// CacheBudget is the memory budget, in bytes, of each cache class.
+type CacheBudget struct {
+	Positive int64
+	Negative int64
+	Infra    int64
+}
+
+var DefaultCacheBudget = CacheBudget{
+	Positive: 256 << 20,
+	Negative: 64 << 20,
+	Infra:    64 << 20,
+}
+
+func (b CacheBudget) of(c CacheClass) int64 {
+	switch c {
+	case CacheClassNegative:
+		return b.Negative
+	case CacheClassInfra:
+		return b.Infra
+	}
+	return b.Positive
+}
+
+const (
+	rrsetStoreShards = 64
+	// storeEntryOverhead approximates the Go memory that an entry costs on
+	// top of its wire-format data (map slot, list links, CachedRRset and
+	// RRset headers).
+	storeEntryOverhead = 256
+	// protectedShare is the part of a class budget the protected segment may
+	// hold; the rest is left for probationary entries.
+	protectedShare = 0.8
+)
+
+// ClassifyCachedRRset returns the eviction class of a cache entry:
+// delegation and key data (NS, DS, DNSKEY, and addresses learned as glue,
+// hints or during priming and referrals) is infrastructure; NXDOMAIN, NODATA
+// and failures are negative; everything else is positive.
+func ClassifyCachedRRset(cr *CachedRRset) CacheClass {
+	switch cr.RRtype {
+	case dns.TypeNS, dns.TypeDS, dns.TypeDNSKEY:
+		return CacheClassInfra
+	case dns.TypeA, dns.TypeAAAA:
+		switch cr.Context {
+		case ContextGlue, ContextHint, ContextPriming, ContextReferral:
+			return CacheClassInfra
+		}
+	}
+	if cr.Rcode != dns.RcodeSuccess || cr.RRset == nil || len(cr.RRset.RRs) == 0 {
+		return CacheClassNegative
+	}
+	return CacheClassPositive
+}
+
+// cachedRRsetSize is the accounted size of an entry: the wire size of its
+// records and signatures plus a fixed overhead.
+func cachedRRsetSize(key string, cr *CachedRRset) int64 {
+	size := int64(storeEntryOverhead + len(key) + len(cr.Name) + len(cr.EDEText))
+	add := func(rs *core.RRset) {
+		if rs == nil {
+			return
+		}
+		for _, rr := range rs.RRs {
+			size += int64(dns.Len(rr))
+		}
+		for _, rr := range rs.RRSIGs {
+			size += int64(dns.Len(rr))
+		}
+	}
+	add(cr.RRset)
+	for _, rs := range cr.NegAuthority {
+		add(rs)
+	}
+	return size
+}
+
+type storeEntry struct {
+	key        string
+	val        CachedRRset
+	size       int64
+	class      CacheClass
+	protected  bool
+	prev, next *storeEntry
+}
+
+// lruList is a circular doubly linked list with a sentinel; root.next is the
+// most recently used entry.
+type lruList struct {
+	root  storeEntry
+	bytes int64
+	n     int
+}
+
+func (l *lruList) init() {
+	l.root.next = &l.root
+	l.root.prev = &l.root
+}
+
+func (l *lruList) pushFront(e *storeEntry) {
+	e.prev = &l.root
+	e.next = l.root.next
+	l.root.next.prev = e
+	l.root.next = e
+	l.bytes += e.size
+	l.n++
+}
+
+func (l *lruList) remove(e *storeEntry) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next = nil, nil
+	l.bytes -= e.size
+	l.n--
+}
+
+func (l *lruList) back() *storeEntry {
+	if l.n == 0 {
+		return nil
+	}
+	return l.root.prev
+}
+
+type storeShard struct {
+	mu        sync.Mutex
+	items     map[string]*storeEntry
+	probation [numCacheClasses]lruList
+	protected [numCacheClasses]lruList
+}
+
+func (s *storeShard) list(e *storeEntry) *lruList {
+	if e.protected {
+		return &s.protected[e.class]
+	}
+	return &s.probation[e.class]
+}
+
+// NewRRsetStore returns an empty store with the given budget.
+func NewRRsetStore(budget CacheBudget) *RRsetStore {
+	st := &RRsetStore{shards: make([]*storeShard, rrsetStoreShards)}
+	for i := range st.shards {
+		s := &storeShard{items: map[string]*storeEntry{}}
+		for c := range s.probation {
+			s.probation[c].init()
+			s.protected[c].init()
+		}
+		st.shards[i] = s
+	}
+	st.budget.Store(&budget)
+	return st
+}
+
+func (st *RRsetStore) shard(key string) *storeShard {
+	// FNV-1a, as in core.ConcurrentMap.
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return st.shards[h%uint32(len(st.shards))]
+}
+
+func (st *RRsetStore) shardBudget(c CacheClass) int64 {
+	return st.budget.Load().of(c) / int64(len(st.shards))
+}
+
+// Get returns the entry for key and marks it as used.
+func (st *RRsetStore) Get(key string) (CachedRRset, bool) {
+	s := st.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		st.misses.Add(1)
+		return CachedRRset{}, false
+	}
+	st.hits.Add(1)
+	s.list(e).remove(e)
+	e.protected = true
+	s.protected[e.class].pushFront(e)
+	st.demoteLocked(s, e.class)
+	return e.val, true
+}
+
+// Has reports whether key is present, without marking it as used.
+func (st *RRsetStore) Has(key string) bool {
+	s := st.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.items[key]
+	return ok
+}
+
+// Set stores val under key, evicting least recently used entries of the same
+// class as needed to stay within budget.
+func (st *RRsetStore) Set(key string, val CachedRRset) {
+	class := ClassifyCachedRRset(&val)
+	size := cachedRRsetSize(key, &val)
+	s := st.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if ok {
+		s.list(e).remove(e)
+		if e.class != class {
+			e.protected = false
+		}
+	} else {
+		e = &storeEntry{key: key}
+		s.items[key] = e
+		st.count.Add(1)
+	}
+	e.val, e.size, e.class = val, size, class
+	s.list(e).pushFront(e)
+	if e.protected {
+		st.demoteLocked(s, class)
+	}
+	st.evictLocked(s, class, e)
+}
+
+// Remove deletes key.
+func (st *RRsetStore) Remove(key string) {
+	s := st.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		st.removeLocked(s, e)
+	}
+}
+
+func (st *RRsetStore) removeLocked(s *storeShard, e *storeEntry) {
+	s.list(e).remove(e)
+	delete(s.items, e.key)
+	st.count.Add(-1)
+}
+
+// demoteLocked moves the least recently used protected entries back to
+// probation while the protected segment is over its share.
+func (st *RRsetStore) demoteLocked(s *storeShard, c CacheClass) {
+	limit := int64(float64(st.shardBudget(c)) * protectedShare)
+	for s.protected[c].bytes > limit && s.protected[c].n > 1 {
+		v := s.protected[c].back()
+		s.protected[c].remove(v)
+		v.protected = false
+		s.probation[c].pushFront(v)
+	}
+}
+
+// evictLocked drops entries of class c until it is within budget. keep is
+// never evicted (a single entry larger than the budget is still cached).
+func (st *RRsetStore) evictLocked(s *storeShard, c CacheClass, keep *storeEntry) {
+	budget := st.shardBudget(c)
+	for s.probation[c].bytes+s.protected[c].bytes > budget {
+		v := s.probation[c].back()
+		if v == keep {
+			v = v.prev
+			if v == &s.probation[c].root {
+				v = nil
+			}
+		}
+		if v == nil {
+			v = s.protected[c].back()
+			if v == keep {
+				v = v.prev
+				if v == &s.protected[c].root {
+					v = nil
+				}
+			}
+		}
+		if v == nil {
+			return
+		}
+		st.removeLocked(s, v)
+		st.evictions[c].Add(1)
+	}
+}
+
+// SetBudget changes the budget, evicting as needed.
+func (st *RRsetStore) SetBudget(b CacheBudget) {
+	st.budget.Store(&b)
+	for _, s := range st.shards {
+		s.mu.Lock()
+		for c := CacheClass(0); c < numCacheClasses; c++ {
+			st.demoteLocked(s, c)
+			st.evictLocked(s, c, nil)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Budget returns the current budget.
+func (st *RRsetStore) Budget() CacheBudget {
+	return *st.budget.Load()
+}
+
+// Count returns the number of entries.
+func (st *RRsetStore) Count() int {
+	return int(st.count.Load())
+}
+
+// Keys returns all keys.
+func (st *RRsetStore) Keys() []string {
+	keys := make([]string, 0, st.Count())
+	for _, s := range st.shards {
+		s.mu.Lock()
+		for k := range s.items {
+			keys = append(keys, k)
+		}
+		s.mu.Unlock()
+	}
+	return keys
+}
+
+// IterBuffered returns a channel with a snapshot of all entries. Iterating
+// does not mark entries as used.
+func (st *RRsetStore) IterBuffered() <-chan core.Tuple[string, CachedRRset] {
+	items := make([]core.Tuple[string, CachedRRset], 0, st.Count())
+	for _, s := range st.shards {
+		s.mu.Lock()
+		for k, e := range s.items {
+			items = append(items, core.Tuple[string, CachedRRset]{Key: k, Val: e.val})
+		}
+		s.mu.Unlock()
+	}
+	ch := make(chan core.Tuple[string, CachedRRset], len(items))
+	for _, it := range items {
+		ch <- it
+	}
+	close(ch)
+	return ch
+}
+
+// CacheClassStats is the usage of one cache class.
+type CacheClassStats struct {
+	Class     string `json:"class"`
+	Entries   int    `json:"entries"`
+	Protected int    `json:"protected"`
+	Bytes     int64  `json:"bytes"`
+	Budget    int64  `json:"budget"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// CacheStats is a snapshot of the store's usage.
+type CacheStats struct {
+	Entries int               `json:"entries"`
+	Hits    uint64            `json:"hits"`
+	Misses  uint64            `json:"misses"`
+	Classes []CacheClassStats `json:"classes"`
+}
+
+// Stats returns current usage per class.
+func (st *RRsetStore) Stats() CacheStats {
+	b := st.Budget()
+	out := CacheStats{Entries: st.Count(), Hits: st.hits.Load(), Misses: st.misses.Load()}
+	for c := CacheClass(0); c < numCacheClasses; c++ {
+		cs := CacheClassStats{Class: CacheClassToString[c], Budget: b.of(c), Evictions: st.evictions[c].Load()}
+		for _, s := range st.shards {
+			s.mu.Lock()
+			cs.Entries += s.probation[c].n + s.protected[c].n
+			cs.Protected += s.protected[c].n
+			cs.Bytes += s.probation[c].bytes + s.protected[c].bytes
+			s.mu.Unlock()
+		}
+		out.Classes = append(out.Classes, cs)
+	}
+	return out
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+func storeTestA(name string) CachedRRset {
+	rr := &dns.A{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.IPv4(192, 0, 2, 1)}
+	return CachedRRset{Name: name, RRtype: dns.TypeA, Context: ContextAnswer, RRset: &core.RRset{Name: name, RRtype: dns.TypeA, RRs: []dns.RR{rr}}}
+}
+
+func storeTestNS(name string) CachedRRset {
+	rr := &dns.NS{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 3600}, Ns: "ns1." + name}
+	return CachedRRset{Name: name, RRtype: dns.TypeNS, Context: ContextReferral, RRset: &core.RRset{Name: name, RRtype: dns.TypeNS, RRs: []dns.RR{rr}}}
+}
+
+func storeTestNX(name string) CachedRRset {
+	return CachedRRset{Name: name, RRtype: dns.TypeA, Rcode: dns.RcodeNameError, Context: ContextNXDOMAIN}
+}
+
+func TestClassifyCachedRRset(t *testing.T) {
+	glue := storeTestA("ns1.example.")
+	glue.Context = ContextGlue
+	nodata := storeTestA("www.example.")
+	nodata.RRset.RRs = nil
+	tests := []struct {
+		name string
+		cr   CachedRRset
+		want CacheClass
+	}{
+		{"answer", storeTestA("www.example."), CacheClassPositive},
+		{"ns", storeTestNS("example."), CacheClassInfra},
+		{"glue", glue, CacheClassInfra},
+		{"nxdomain", storeTestNX("nx.example."), CacheClassNegative},
+		{"nodata", nodata, CacheClassNegative},
+	}
+	for _, tc := range tests {
+		if got := ClassifyCachedRRset(&tc.cr); got != tc.want {
+			t.Errorf("%s: class %s, want %s", tc.name, CacheClassToString[got], CacheClassToString[tc.want])
+		}
+	}
+}
+
+func TestRRsetStoreBudget(t *testing.T) {
+	budget := CacheBudget{Positive: 64 * 4096, Negative: 64 * 4096, Infra: 64 * 4096}
+	st := NewRRsetStore(budget)
+	for i := 0; i < 20000; i++ {
+		name := fmt.Sprintf("h%d.example.", i)
+		st.Set(name+"::1", storeTestA(name))
+	}
+	stats := st.Stats()
+	pos := stats.Classes[CacheClassPositive]
+	if pos.Bytes > budget.Positive {
+		t.Errorf("positive class uses %d bytes, budget %d", pos.Bytes, budget.Positive)
+	}
+	if pos.Evictions == 0 || pos.Entries != st.Count() || len(st.Keys()) != st.Count() {
+		t.Errorf("stats %+v, count %d, keys %d", pos, st.Count(), len(st.Keys()))
+	}
+
+	st.SetBudget(CacheBudget{Positive: budget.Positive / 4, Negative: budget.Negative, Infra: budget.Infra})
+	if b := st.Stats().Classes[CacheClassPositive].Bytes; b > budget.Positive/4 {
+		t.Errorf("after shrinking the budget the positive class uses %d bytes", b)
+	}
+}
+
+// A flood of negative answers only evicts negative entries.
+func TestRRsetStoreClassIsolation(t *testing.T) {
+	st := NewRRsetStore(CacheBudget{Positive: 64 * 8192, Negative: 64 * 2048, Infra: 64 * 8192})
+	var infra []string
+	for i := 0; i < 200; i++ {
+		name := fmt.Sprintf("zone%d.example.", i)
+		key := name + "::2"
+		st.Set(key, storeTestNS(name))
+		infra = append(infra, key)
+	}
+	for i := 0; i < 100000; i++ {
+		name := fmt.Sprintf("r%d.zone1.example.", i)
+		st.Set(name+"::1", storeTestNX(name))
+	}
+	for _, key := range infra {
+		if !st.Has(key) {
+			t.Fatalf("infrastructure entry %s evicted by negative flood", key)
+		}
+	}
+	stats := st.Stats()
+	if stats.Classes[CacheClassNegative].Evictions == 0 || stats.Classes[CacheClassInfra].Evictions != 0 {
+		t.Errorf("evictions: %+v", stats.Classes)
+	}
+}
+
+// Entries that have been hit survive a scan of one-off names.
+func TestRRsetStoreScanResistance(t *testing.T) {
+	st := NewRRsetStore(CacheBudget{Positive: 64 * 16384, Negative: 64 * 4096, Infra: 64 * 4096})
+	var hot []string
+	for i := 0; i < 64; i++ {
+		name := fmt.Sprintf("hot%d.example.", i)
+		key := name + "::1"
+		st.Set(key, storeTestA(name))
+		hot = append(hot, key)
+	}
+	for _, key := range hot {
+		if _, ok := st.Get(key); !ok {
+			t.Fatalf("%s missing", key)
+		}
+	}
+	for i := 0; i < 100000; i++ {
+		name := fmt.Sprintf("cold%d.example.", i)
+		st.Set(name+"::1", storeTestA(name))
+	}
+	for _, key := range hot {
+		if !st.Has(key) {
+			t.Errorf("hot entry %s evicted by a scan", key)
+		}
+	}
+}
+
+func TestRRsetStoreUpdateAndRemove(t *testing.T) {
+	st := NewRRsetStore(DefaultCacheBudget)
+	st.Set("a.example.::1", storeTestA("a.example."))
+	st.Set("a.example.::1", storeTestNX("a.example."))
+	if st.Count() != 1 {
+		t.Fatalf("count %d after update, want 1", st.Count())
+	}
+	stats := st.Stats()
+	if stats.Classes[CacheClassPositive].Entries != 0 || stats.Classes[CacheClassNegative].Entries != 1 {
+		t.Errorf("update did not move the entry between classes: %+v", stats.Classes)
+	}
+	st.Remove("a.example.::1")
+	if st.Count() != 0 || st.Stats().Classes[CacheClassNegative].Bytes != 0 {
+		t.Errorf("remove left %d entries, stats %+v", st.Count(), st.Stats().Classes)
+	}
+	n := 0
+	for range st.IterBuffered() {
+		n++
+	}
+	if n != 0 {
+		t.Errorf("IterBuffered returned %d entries from an empty store", n)
+	}
+}
+
+// BenchmarkRRsetStoreSet inserts new names into a full store. The cost per
+// insert should not grow with the number of cached entries.
+func BenchmarkRRsetStoreSet(b *testing.B) {
+	for _, n := range []int{10000, 100000, 1000000, 4000000} {
+		b.Run(fmt.Sprintf("entries=%d", n), func(b *testing.B) {
+			cr := storeTestA("bench.example.")
+			size := cachedRRsetSize("h0000000.example.::1", &cr)
+			st := NewRRsetStore(CacheBudget{Positive: int64(n) * size, Negative: 1 << 20, Infra: 1 << 20})
+			for i := 0; i < n; i++ {
+				st.Set(fmt.Sprintf("h%07d.example.::1", i), cr)
+			}
+			keys := make([]string, b.N)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("n%07d.example.::1", i)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				st.Set(keys[i], cr)
+			}
+		})
+	}
+}
+
+func BenchmarkRRsetStoreGetParallel(b *testing.B) {
+	const n = 1000000
+	cr := storeTestA("bench.example.")
+	st := NewRRsetStore(DefaultCacheBudget)
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("h%07d.example.::1", i)
+		st.Set(keys[i], cr)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			st.Get(keys[i%n])
+			i++
+		}
+	})
+}
@@ -39,6 +39,7 @@ type ParentZoneFinder func(name string) (string, error)
 func (rrcache *RRsetCacheT) validateRRsetWithRRSIG(ctx context.Context, rrset *core.RRset, sig *dns.RRSIG, dkc *DnskeyCacheT, fetcher RRsetFetcher) (valid bool, shouldReturnEarly bool, returnState ValidationState, err error) {
 	signer := dns.Fqdn(sig.SignerName)
 	keyid := sig.KeyTag
+	trace := traceFrom(ctx)
 	if rrcache.Debug {
 		log.Printf("ValidateRRset: evaluating signature: signer=%q keyid=%d covered=%s inception=%d expiration=%d",
 			signer, keyid, dns.TypeToString[sig.TypeCovered], sig.Inception, sig.Expiration)
@@ -64,6 +65,13 @@ func (rrcache *RRsetCacheT) validateRRsetWithRRSIG(ctx context.Context, rrset *c
 	if rrcache.Debug {
 		log.Printf("ValidateRRset: TA %q::%d in cache: %+v", signer, keyid, dkrr)
 	}
+	// A traced validation does not take cached keys on trust: the signer's
+	// DNSKEY RRset is validated again so the trace covers the whole chain.
+	if dkrr != nil && !dkrr.TrustAnchor && trace.firstChainCheck(signer) {
+		if vstate := rrcache.retraceSignerKeys(ctx, signer, fetcher); vstate != ValidationStateSecure && vstate != ValidationStateNone {
+			return false, vstate == ValidationStateInsecure, vstate, nil
+		}
+	}
 	if dkrr == nil && ctx != nil {
 		// Before attempting to fetch, check again if signer zone is indeterminate or insecure
 		// (it might have been added to ZoneMap since the initial check)
@@ -232,15 +240,43 @@ func (rrcache *RRsetCacheT) validateRRsetWithRRSIG(ctx context.Context, rrset *c
 		// verification failure: we couldn't even attempt the verify. The
 		// outer loop reads this as Indeterminate so a missing chain doesn't
 		// get slandered as Bogus.
+		if trace != nil {
+			detail := fmt.Sprintf("no validated DNSKEY %s::%d", signer, keyid)
+			if dkrr != nil {
+				detail = fmt.Sprintf("DNSKEY %s::%d is %s", signer, keyid, ValidationStateToString[dkrr.State])
+			}
+			traceRRSIG(trace, rrset.Name, rrset.RRtype, sig, false, detail)
+		}
 		return false, false, ValidationStateIndeterminate, nil
 	}
-	if err := sig.Verify(&dkrr.Dnskey, rrset.RRs); err != nil {
+	// An RRSIG from a Merkle-ladder signing pass carries an inclusion
+	// proof instead of a signature: it verifies against a rung of the
+	// signer's LADDER RRset, which is itself ordinarily signed.
+	verify := func() error { return sig.Verify(&dkrr.Dnskey, rrset.RRs) }
+	okDetail := "signature verifies and is within its validity window"
+	if _, isLadder := core.LadderProofFromRRSIG(sig); isLadder && rrset.RRtype != core.TypeLADDER {
+		verify = func() error {
+			rung, err := rrcache.verifyLadderRRSIG(ctx, rrset, sig, fetcher)
+			if err == nil {
+				okDetail = fmt.Sprintf("ladder proof verifies into LADDER tree %d and is within its validity window", rung.TreeID)
+			}
+			return err
+		}
+	}
+	if err := verify(); err != nil {
 		if rrcache.Verbose {
 			log.Printf("ValidateRRset: signature verify FAILED for %s %s using %s::%d: %v",
 				rrset.Name, dns.TypeToString[rrset.RRtype], signer, keyid, err)
 		}
 		// Real verification failure: had the key, sig didn't verify.
 		// Outer loop reads this as Bogus.
+		if trace != nil {
+			detail := fmt.Sprintf("signature does not verify: %v", err)
+			if err == dns.ErrAlg {
+				detail = fmt.Sprintf("algorithm %s is not supported", algorithmString(sig.Algorithm))
+			}
+			traceRRSIG(trace, rrset.Name, rrset.RRtype, sig, false, detail)
+		}
 		return false, false, ValidationStateBogus, nil
 	}
 	// Time validity
@@ -252,6 +288,7 @@ func (rrcache *RRsetCacheT) validateRRsetWithRRSIG(ctx context.Context, rrset *c
 		if rrcache.Debug {
 			log.Printf("ValidateRRset: SUCCESS")
 		}
+		traceRRSIG(trace, rrset.Name, rrset.RRtype, sig, true, okDetail)
 		// If this is a DS RRset we now know that the zone is a secure zone.
 		if rrset.RRtype == dns.TypeDS {
 			zone, ok := rrcache.ZoneMap.Get(rrset.Name)
@@ -282,6 +319,13 @@ func (rrcache *RRsetCacheT) validateRRsetWithRRSIG(ctx context.Context, rrset *c
 	// Signature inception/expiration window is invalid (premature or
 	// expired). The crypto verified, but the sig is not currently
 	// usable — that is a real Bogus condition, not a chain gap.
+	if trace != nil {
+		detail := "signature has expired"
+		if time.Now().UTC().Before(time.Unix(int64(sig.Inception), 0)) {
+			detail = "signature is not yet valid"
+		}
+		traceRRSIG(trace, rrset.Name, rrset.RRtype, sig, false, detail)
+	}
 	return false, false, ValidationStateBogus, nil
 }
 
@@ -306,13 +350,28 @@ func (rrcache *RRsetCacheT) ValidateRRsetWithParentZone(ctx context.Context, rrs
 		return ValidationStateNone, fmt.Errorf("rrset is nil; nothing to validate")
 	}
 
+	trace := traceFrom(ctx)
+
+	// A negative trust anchor turns validation off at and below its zone.
+	if rrcache.ntaCovers(ctx, rrset.Name) {
+		if rrcache.Debug {
+			log.Printf("ValidateRRset: %s %s is below a negative trust anchor; treating as insecure", rrset.Name, dns.TypeToString[rrset.RRtype])
+		}
+		if trace != nil {
+			nta := rrcache.NTAs.Covering(rrset.Name, time.Now())
+			trace.add(TraceStep{Zone: nta, Kind: TraceStepNTA, Owner: rrset.Name, RRtype: dns.TypeToString[rrset.RRtype],
+				State: ValidationStateInsecure, Ok: true, Detail: fmt.Sprintf("validation disabled by the negative trust anchor for %s", nta)})
+		}
+		return ValidationStateInsecure, nil
+	}
+
 	// Check cache first - if we already have this RRset validated and it hasn't changed or expired, reuse the validation state.
 	// Note: the ValidationState enum starts at iota+1, so the zero value
 	// (Go-default-initialised) is 0, NOT ValidationStateNone (=1). A cached
 	// entry written without an explicit State field has State==0 and must be
 	// treated as "not validated yet", not as a usable cached verdict.
 	cached := rrcache.Get(rrset.Name, rrset.RRtype)
-	if cached != nil && cached.State > ValidationStateNone {
+	if cached != nil && cached.State > ValidationStateNone && !rrcache.ntaProbing(ctx, rrset.Name) && trace == nil {
 		// Get() already checks expiration and returns nil if expired, so if cached is not nil, it's not expired
 		// But we double-check expiration to be explicit about the semantics
 		if cached.Expiration.Before(time.Now()) {
@@ -366,6 +425,7 @@ func (rrcache *RRsetCacheT) ValidateRRsetWithParentZone(ctx context.Context, rrs
 		if rrcache.Verbose {
 			log.Printf("ValidateRRset: zone %q is insecure (unsigned); returning insecure state for %s %s", zoneName, rrset.Name, dns.TypeToString[rrset.RRtype])
 		}
+		traceVerdict(trace, zoneName, rrset.Name, rrset.RRtype, ValidationStateInsecure, "zone is insecure (no DS at the parent)")
 		return ValidationStateInsecure, nil
 	}
 
@@ -417,8 +477,11 @@ func (rrcache *RRsetCacheT) ValidateRRsetWithParentZone(ctx context.Context, rrs
 			}
 			switch foundZone.GetState() {
 			case ValidationStateIndeterminate, ValidationStateInsecure:
+				traceVerdict(trace, foundZoneName, rrset.Name, rrset.RRtype, foundZone.GetState(),
+					fmt.Sprintf("no RRSIGs; zone is %s", ValidationStateToString[foundZone.GetState()]))
 				return foundZone.GetState(), nil
 			default:
+				traceVerdict(trace, foundZoneName, rrset.Name, rrset.RRtype, ValidationStateInsecure, "no RRSIGs")
 				return ValidationStateInsecure, nil
 			}
 		}
@@ -427,6 +490,7 @@ func (rrcache *RRsetCacheT) ValidateRRsetWithParentZone(ctx context.Context, rrs
 		if rrcache.Verbose {
 			log.Printf("ValidateRRset: no zone found for %s %s; returning indeterminate", rrset.Name, dns.TypeToString[rrset.RRtype])
 		}
+		traceVerdict(trace, "", rrset.Name, rrset.RRtype, ValidationStateIndeterminate, "no RRSIGs and no known zone")
 		return ValidationStateIndeterminate, nil
 	}
 
@@ -449,12 +513,16 @@ func (rrcache *RRsetCacheT) ValidateRRsetWithParentZone(ctx context.Context, rrs
 		}
 		valid, shouldReturnEarly, returnState, err := rrcache.validateRRsetWithRRSIG(ctx, rrset, sig, dkc, fetcher)
 		if err != nil {
+			traceVerdict(trace, dns.Fqdn(sig.SignerName), rrset.Name, rrset.RRtype, returnState, err.Error())
 			return returnState, err
 		}
 		if shouldReturnEarly {
+			traceVerdict(trace, dns.Fqdn(sig.SignerName), rrset.Name, rrset.RRtype, returnState,
+				fmt.Sprintf("signer zone %s is %s", dns.Fqdn(sig.SignerName), ValidationStateToString[returnState]))
 			return returnState, nil
 		}
 		if valid {
+			traceVerdict(trace, dns.Fqdn(sig.SignerName), rrset.Name, rrset.RRtype, ValidationStateSecure, "")
 			return ValidationStateSecure, nil
 		}
 		switch returnState {
@@ -474,11 +542,14 @@ func (rrcache *RRsetCacheT) ValidateRRsetWithParentZone(ctx context.Context, rrs
 	//   - neither (no sigs at all, or all returned an unexpected state):
 	//     default to Bogus, matching the prior conservative behaviour.
 	if sawSigFail {
+		traceVerdict(trace, "", rrset.Name, rrset.RRtype, ValidationStateBogus, "no signature validated")
 		return ValidationStateBogus, nil
 	}
 	if sawChainGap {
+		traceVerdict(trace, "", rrset.Name, rrset.RRtype, ValidationStateIndeterminate, "no signer key could be obtained")
 		return ValidationStateIndeterminate, nil
 	}
+	traceVerdict(trace, "", rrset.Name, rrset.RRtype, ValidationStateBogus, "no usable RRSIGs")
 	return ValidationStateBogus, nil
 }
 
@@ -625,6 +696,7 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 
 	dkc := rrcache.DnskeyCache
 	name := dns.Fqdn(rrset.Name)
+	trace := traceFrom(ctx)
 	if rrcache.Verbose {
 		log.Printf("ValidateDNSKEYs: start: owner=%q rrs=%d sigs=%d", name, len(rrset.RRs), len(rrset.RRSIGs))
 	}
@@ -639,6 +711,7 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 			if rrcache.Verbose {
 				log.Printf("ValidateDNSKEYs: zone %q is %s; returning %s state", name, ValidationStateToString[zstate], ValidationStateToString[zstate])
 			}
+			traceVerdict(trace, name, name, dns.TypeDNSKEY, zstate, fmt.Sprintf("zone is %s", ValidationStateToString[zstate]))
 			return zstate, nil
 		}
 	} else {
@@ -656,6 +729,7 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 				if rrcache.Verbose {
 					log.Printf("ValidateDNSKEYs: zone %q not in ZoneMap but DS is %s; marking zone as %s and returning", name, ValidationStateToString[dsRRs.State], ValidationStateToString[dsRRs.State])
 				}
+				traceVerdict(trace, name, name, dns.TypeDNSKEY, dsRRs.State, fmt.Sprintf("DS RRset is %s", ValidationStateToString[dsRRs.State]))
 				return dsRRs.State, nil
 			}
 		}
@@ -676,6 +750,15 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 			// as a referral when the same server is authoritative for both
 			// parent and child, so the DS must be fetched explicitly here.
 			dsRRs = rrcache.backfillDS(ctx, name, fetcher)
+		} else if trace != nil && dsRRs.RRset != nil && len(dsRRs.RRset.RRSIGs) > 0 {
+			// A traced validation checks the cached DS against the parent
+			// again rather than trusting its cached state.
+			vstate, err := rrcache.ValidateRRset(ctx, dsRRs.RRset, fetcher)
+			if err == nil {
+				retraced := *dsRRs
+				retraced.State = vstate
+				dsRRs = &retraced
+			}
 		}
 		// If DS exists but is not secure, we cannot validate DNSKEYs
 		if dsRRs != nil && dsRRs.State != ValidationStateSecure {
@@ -694,6 +777,7 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 			if rrcache.Verbose {
 				log.Printf("ValidateDNSKEYs: DS for %q is %s; cannot validate DNSKEYs, returning %s", name, ValidationStateToString[dsRRs.State], ValidationStateToString[dsRRs.State])
 			}
+			traceVerdict(trace, name, name, dns.TypeDNSKEY, dsRRs.State, fmt.Sprintf("DS RRset is %s", ValidationStateToString[dsRRs.State]))
 			return dsRRs.State, nil
 		}
 	}
@@ -706,7 +790,8 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 			if !ok {
 				continue
 			}
-			valid, _ := ValidateDNSKEYRRsetUsingDS(rrset, ds, name, rrcache.Verbose)
+			valid, key := ValidateDNSKEYRRsetUsingDS(rrset, ds, name, rrcache.Verbose)
+			traceDSMatch(trace, rrset, ds, name, valid, key)
 			if !valid {
 				continue
 			}
@@ -728,6 +813,7 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 			if rrcache.Verbose {
 				log.Printf("ValidateDNSKEYs: added %d DNSKEYs to DnskeyCache for %q", len(rrset.RRs), name)
 			}
+			traceVerdict(trace, name, name, dns.TypeDNSKEY, ValidationStateSecure, "")
 			return ValidationStateSecure, nil
 		}
 		if rrcache.Verbose {
@@ -743,6 +829,7 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 			cached.EDEText = edeText
 			rrcache.Set(name, dns.TypeDNSKEY, cached)
 		}
+		traceVerdict(trace, name, name, dns.TypeDNSKEY, ValidationStateBogus, edeText)
 		return ValidationStateBogus, nil
 	}
 
@@ -768,7 +855,12 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 	if len(taKeys) > 0 {
 		for _, taKey := range taKeys {
 			// Validate using direct DNSKEY trust anchor
-			valid, _ := ValidateDNSKEYRRsetSignature(rrset, taKey.Keyid, name, &taKey.Dnskey, rrcache.Verbose)
+			valid, sig := ValidateDNSKEYRRsetSignature(rrset, taKey.Keyid, name, &taKey.Dnskey, rrcache.Verbose)
+			if trace != nil {
+				trace.add(TraceStep{Zone: name, Kind: TraceStepTrustAnchor, Owner: name, RRtype: "DNSKEY",
+					KeyTag: taKey.Keyid, Algorithm: algorithmString(taKey.Dnskey.Algorithm), Ok: valid,
+					Detail: dnskeySigDetail(rrset, sig, &taKey.Dnskey, valid)})
+			}
 			if !valid {
 				continue
 			}
@@ -796,6 +888,7 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 			if rrcache.Verbose {
 				log.Printf("ValidateDNSKEYs: added %d DNSKEYs to DnskeyCache for %q", len(rrset.RRs), name)
 			}
+			traceVerdict(trace, name, name, dns.TypeDNSKEY, ValidationStateSecure, "")
 			return ValidationStateSecure, nil
 		}
 		// none of the TA keys validated, return bogus
@@ -809,6 +902,7 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 			cached.EDEText = edeText
 			rrcache.Set(name, dns.TypeDNSKEY, cached)
 		}
+		traceVerdict(trace, name, name, dns.TypeDNSKEY, ValidationStateBogus, edeText)
 		return ValidationStateBogus, nil
 	}
 
@@ -829,7 +923,8 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 				if !ok {
 					continue
 				}
-				valid, _ := ValidateDNSKEYRRsetUsingDS(rrset, ds, name, rrcache.Verbose)
+				valid, key := ValidateDNSKEYRRsetUsingDS(rrset, ds, name, rrcache.Verbose)
+				traceDSMatch(trace, rrset, ds, name, valid, key)
 				if !valid {
 					continue
 				}
@@ -851,6 +946,7 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 				if rrcache.Verbose {
 					log.Printf("ValidateDNSKEYs: added %d DNSKEYs to DnskeyCache for %q (validated against seeded DS)", len(rrset.RRs), name)
 				}
+				traceVerdict(trace, name, name, dns.TypeDNSKEY, ValidationStateSecure, "validated against a DS trust anchor")
 				return ValidationStateSecure, nil
 			}
 		}
@@ -868,6 +964,7 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 			cached.EDEText = edeText
 			rrcache.Set(name, dns.TypeDNSKEY, cached)
 		}
+		traceVerdict(trace, name, name, dns.TypeDNSKEY, ValidationStateBogus, edeText)
 		return ValidationStateBogus, nil
 	}
 
@@ -879,6 +976,7 @@ func (rrcache *RRsetCacheT) ValidateDNSKEYs(ctx context.Context, rrset *core.RRs
 			log.Printf("ValidateDNSKEYs: no DS RRset for %q and no trust anchors found", name)
 		}
 	}
+	traceVerdict(trace, name, name, dns.TypeDNSKEY, ValidationStateIndeterminate, "no DS RRset and no trust anchor")
 	return ValidationStateIndeterminate, nil
 }
 
@@ -952,17 +1050,90 @@ func (rrcache *RRsetCacheT) backfillDS(ctx context.Context, name string, fetcher
 	return entry
 }
 
+// verifyLadderRRSIG verifies sig, which carries a Merkle-ladder inclusion
+// proof, against the signer's LADDER RRset. The LADDER RRset is taken from
+// the cache when it is Secure and holds the proof's tree; otherwise it is
+// fetched from the signer's servers, validated (its own RRSIGs are
+// ordinary signatures, so this does not recurse) and cached.
+func (rrcache *RRsetCacheT) verifyLadderRRSIG(ctx context.Context, rrset *core.RRset, sig *dns.RRSIG, fetcher RRsetFetcher) (*core.LADDER, error) {
+	proof, _ := core.LadderProofFromRRSIG(sig)
+	signer := dns.Fqdn(sig.SignerName)
+
+	rungsOf := func(lrs *core.RRset) []*core.LADDER {
+		var rungs []*core.LADDER
+		for _, rr := range lrs.RRs {
+			if prr, ok := rr.(*dns.PrivateRR); ok {
+				if rung, ok := prr.Data.(*core.LADDER); ok {
+					rungs = append(rungs, rung)
+				}
+			}
+		}
+		return rungs
+	}
+	hasTree := func(rungs []*core.LADDER) bool {
+		for _, rung := range rungs {
+			if rung.TreeID == proof.TreeID && rung.KeyTag == sig.KeyTag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if cached := rrcache.Get(signer, core.TypeLADDER); cached != nil && cached.State == ValidationStateSecure && cached.RRset != nil {
+		if rungs := rungsOf(cached.RRset); hasTree(rungs) {
+			return core.VerifyLadderRRSIG(sig, rrset.RRs, rungs)
+		}
+	}
+
+	// Not cached, or cached before the pass that built this tree.
+	if fetcher == nil || ctx == nil {
+		return nil, fmt.Errorf("no LADDER RRset for %s with tree %d", signer, proof.TreeID)
+	}
+	_, servers, err := rrcache.FindClosestKnownZone(signer)
+	if err != nil || len(servers) == 0 {
+		return nil, fmt.Errorf("no servers to fetch LADDER RRset for %s: %v", signer, err)
+	}
+	fetched, err := fetcher(ctx, signer, core.TypeLADDER, servers)
+	if err != nil || fetched == nil || len(fetched.RRs) == 0 {
+		return nil, fmt.Errorf("fetching LADDER RRset for %s: %v", signer, err)
+	}
+	vstate, err := rrcache.ValidateRRset(ctx, fetched, fetcher)
+	if err != nil {
+		return nil, fmt.Errorf("validating LADDER RRset for %s: %w", signer, err)
+	}
+	rrcache.Set(signer, core.TypeLADDER, &CachedRRset{
+		Name:       signer,
+		RRtype:     core.TypeLADDER,
+		RRset:      fetched,
+		Context:    ContextAnswer,
+		State:      vstate,
+		Expiration: time.Now().Add(GetMinTTL(fetched.RRs)),
+	})
+	if vstate != ValidationStateSecure {
+		return nil, fmt.Errorf("LADDER RRset for %s is %s", signer, ValidationStateToString[vstate])
+	}
+	if rrcache.Verbose {
+		log.Printf("ValidateRRset: fetched+validated LADDER RRset for %q (%d rungs)", signer, len(fetched.RRs))
+	}
+	return core.VerifyLadderRRSIG(sig, rrset.RRs, rungsOf(fetched))
+}
+
 func (rrcache *RRsetCacheT) ValidateNegativeResponse(ctx context.Context, qname string, qtype uint16, rcode uint8,
 	negAuthority []*core.RRset, fetcher RRsetFetcher) (ValidationState, uint8, error) {
 	if len(negAuthority) == 0 {
 		return ValidationStateNone, rcode, fmt.Errorf("no negative authority RRsets to validate")
 	}
+	if rrcache.ntaCovers(ctx, qname) {
+		return ValidationStateInsecure, rcode, nil
+	}
+	trace := traceFrom(ctx)
 
 	if qtype == dns.TypeDNSKEY {
 		// Cannot validate negative DNSKEY responses without the zone's DNSKEYs; treat as bogus
 		if rrcache.Debug {
 			log.Printf("ValidateNegativeResponse: skipping validation for DNSKEY negative response at %q", qname)
 		}
+		traceDenial(trace, qname, qname, qtype, false, "negative DNSKEY answers cannot be validated")
 		return ValidationStateBogus, rcode, nil // XXX: Cannot validate negative DNSKEY responses without the zone's DNSKEYs
 	}
 	if ctx == nil {
@@ -997,13 +1168,16 @@ func (rrcache *RRsetCacheT) ValidateNegativeResponse(ctx context.Context, qname
 		}
 	}
 	if soarrset == nil || len(soarrset.RRs) == 0 { // XXX: Here we need to know if the zone is insecure or not
+		traceDenial(trace, "", qname, qtype, false, "no SOA in the negative response")
 		return ValidationStateIndeterminate, rcode, fmt.Errorf("no SOA found in negative authority for %s", qname)
 	}
 	zoneName := dns.CanonicalName(soarrset.Name)
 	if !strings.HasSuffix(qnameCanon, zoneName) {
+		traceDenial(trace, zoneName, qname, qtype, false, fmt.Sprintf("SOA owner %s is not an ancestor of the qname", zoneName))
 		return ValidationStateBogus, rcode, nil // XXX: The zone name does not match the qname
 	}
 	if !hasSignatures {
+		traceDenial(trace, zoneName, qname, qtype, true, "negative response is unsigned")
 		return ValidationStateInsecure, rcode, nil // XXX: Need to know if zone is secure, but for now: No signatures, so we are insecure
 	}
 	for _, set := range negAuthority {
@@ -1038,6 +1212,7 @@ func (rrcache *RRsetCacheT) ValidateNegativeResponse(ctx context.Context, qname
 
 				// Check for compact denial NXDOMAIN: bitmap contains exactly RRSIG, NSEC, and NXNAME
 				if isCompactDenialNXDOMAIN(nsec.TypeBitMap) {
+					traceDenial(trace, zoneName, qname, qtype, true, "compact denial NSEC proves NXDOMAIN (RFC 9824)")
 					if rrcache.Debug {
 						log.Printf("ValidateNegativeResponse: compact denial NXDOMAIN (RFC 9824) validated for %s: name does not exist", qname)
 					}
@@ -1048,6 +1223,7 @@ func (rrcache *RRsetCacheT) ValidateNegativeResponse(ctx context.Context, qname
 
 				// Check for compact denial NODATA: qtype is NOT in the type bitmap
 				if !typeInBitmap(qtype, nsec.TypeBitMap) {
+					traceDenial(trace, zoneName, qname, qtype, true, fmt.Sprintf("NSEC at the qname proves there is no %s", dns.TypeToString[qtype]))
 					if rrcache.Debug {
 						log.Printf("ValidateNegativeResponse: compact denial NODATA (RFC 9824) validated for %s %s: name exists but no data for type", qname, dns.TypeToString[qtype])
 					}
@@ -1079,8 +1255,11 @@ func (rrcache *RRsetCacheT) ValidateNegativeResponse(ctx context.Context, qname
 			}
 		}
 		if !coveredQname || !coveredWildcard {
+			traceDenial(trace, zoneName, qname, qtype, false,
+				fmt.Sprintf("NSEC RRs do not cover the qname and the wildcard (qname covered: %v, wildcard %s covered: %v)", coveredQname, wildcard, coveredWildcard))
 			return ValidationStateBogus, rcode, nil // The NSECs do not cover the qname and the wildcard
 		}
+		traceDenial(trace, zoneName, qname, qtype, true, fmt.Sprintf("NSEC RRs cover the qname and the wildcard %s", wildcard))
 		return ValidationStateSecure, rcode, nil // NSECs present, we do not yet verify them, but we assume they are secure so we are secure
 	}
 
@@ -1091,10 +1270,12 @@ func (rrcache *RRsetCacheT) ValidateNegativeResponse(ctx context.Context, qname
 		// - NSEC3 owner (hashed) matches hashed qname
 		// - Type bitmap does NOT include qtype
 		// For now, we accept NSEC3 presence as secure (traditional denial)
+		traceDenial(trace, zoneName, qname, qtype, false, "NSEC3 proofs are not verified")
 		return ValidationStateIndeterminate, rcode, nil // NSEC3 present, we do not yet verify them, but we assume they are secure
 	}
 
 	// No NSEC, no NSEC3, must know if zone is secure or insecure
+	traceDenial(trace, zoneName, qname, qtype, true, "no NSEC or NSEC3 in the negative response")
 	return ValidationStateInsecure, rcode, fmt.Errorf("no NSECs or NSEC3, so we are insecure") // XXX: Need to know if zone is secure, but for now: No NSECs or NSEC3, so we are insecure
 }
 
@@ -6,7 +6,10 @@ package cache
 
 import (
 	"context"
+	"crypto"
+	"fmt"
 	"log"
+	"net"
 	"os"
 	"testing"
 	"time"
@@ -283,3 +286,96 @@ func TestValidateDNSKEYs_BackfillsMissingDS(t *testing.T) {
 		t.Fatalf("ValidateDNSKEYs did not fetch the missing DS for %s on a cache miss — DS-backfill regression", child)
 	}
 }
+
+// TestValidator_LadderProof checks Merkle-ladder RRSIGs: an inclusion proof
+// validates against the signer's ordinarily signed LADDER RRset, fetched
+// once and then taken from the cache, and a proof over altered data is
+// Bogus.
+func TestValidator_LadderProof(t *testing.T) {
+	rrcache := NewRRsetCache(log.New(os.Stderr, "test ", 0), false, false)
+	rrcache.ServerMap.Set(".", map[string]*AuthServer{"a.root.": {}})
+
+	const signer = "ladder.example."
+	zsk := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: signer, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     256,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := zsk.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rrcache.DnskeyCache.Set(signer, zsk.KeyTag(), &CachedDnskeyRRset{
+		Name:       signer,
+		Keyid:      zsk.KeyTag(),
+		State:      ValidationStateSecure,
+		Dnskey:     *zsk,
+		Expiration: time.Now().Add(time.Hour),
+	})
+
+	newSig := func(name string, rrtype uint16) *dns.RRSIG {
+		return &dns.RRSIG{
+			Hdr:         dns.RR_Header{Name: name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 60},
+			TypeCovered: rrtype,
+			Algorithm:   zsk.Algorithm,
+			Labels:      uint8(dns.CountLabel(name)),
+			OrigTtl:     60,
+			Inception:   uint32(time.Now().Add(-time.Hour).Unix()),
+			Expiration:  uint32(time.Now().Add(24 * time.Hour).Unix()),
+			KeyTag:      zsk.KeyTag(),
+			SignerName:  signer,
+		}
+	}
+	var rrsets []*core.RRset
+	var leaves [][]byte
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("host%d.%s", i, signer)
+		a := &dns.A{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(192, 0, 2, byte(i+1))}
+		sig := newSig(name, dns.TypeA)
+		leaf, err := core.LadderLeafHash(sig, []dns.RR{a})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaves = append(leaves, leaf)
+		rrsets = append(rrsets, &core.RRset{Name: name, Class: dns.ClassINET, RRtype: dns.TypeA, RRs: []dns.RR{a}, RRSIGs: []dns.RR{sig}})
+	}
+	tree, err := core.BuildLadderTree(leaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, rrset := range rrsets {
+		rrset.RRSIGs[0].(*dns.RRSIG).Signature = tree.Proof(i).Signature()
+	}
+	rung := core.NewLadderRR(signer, 60, tree.Rung(zsk.KeyTag(), uint32(time.Now().Add(24*time.Hour).Unix())))
+	lsig := newSig(signer, core.TypeLADDER)
+	if err := lsig.Sign(priv.(crypto.Signer), []dns.RR{rung}); err != nil {
+		t.Fatal(err)
+	}
+	ladder := &core.RRset{Name: signer, Class: dns.ClassINET, RRtype: core.TypeLADDER, RRs: []dns.RR{rung}, RRSIGs: []dns.RR{lsig}}
+
+	fetches := 0
+	fetcher := func(ctx context.Context, qname string, qtype uint16, servers map[string]*AuthServer) (*core.RRset, error) {
+		if dns.Fqdn(qname) == signer && qtype == core.TypeLADDER {
+			fetches++
+			return ladder, nil
+		}
+		return nil, nil
+	}
+
+	for _, i := range []int{0, 5} {
+		got, err := rrcache.ValidateRRset(context.Background(), rrsets[i], fetcher)
+		if err != nil || got != ValidationStateSecure {
+			t.Fatalf("host%d: got %s, %v; want %s", i, ValidationStateToString[got], err, ValidationStateToString[ValidationStateSecure])
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("LADDER RRset fetched %d times, want once and then from the cache", fetches)
+	}
+
+	altered := rrsets[3]
+	altered.RRs[0].(*dns.A).A = net.IPv4(198, 51, 100, 1)
+	if got, _ := rrcache.ValidateRRset(context.Background(), altered, fetcher); got != ValidationStateBogus {
+		t.Errorf("altered RRset: got %s, want %s", ValidationStateToString[got], ValidationStateToString[ValidationStateBogus])
+	}
+}
@@ -0,0 +1,590 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package cache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// Cache snapshots let a restarted resolver start warm. A snapshot is a
+// gzip-compressed stream of JSON lines: a header, then one record per
+// cached RRset, validated DNSKEY, nameserver, zone and server TLSA RRset.
+// RRs are kept in presentation format.
+//
+// On restore, entries that expired while the resolver was down are
+// skipped and the TTLs of the others are lowered to the time remaining.
+// A validation state is kept only while every RRSIG of the entry is within
+// its validity period; otherwise the entry is restored as not validated.
+// Bogus entries are not restored at all, and neither are entries that are
+// insecure only because a negative trust anchor covers them: the NTA may
+// be gone after the restart, and the entry must then be validated again.
+
+// snapshotVersion changes whenever the encoding of the records changes.
+// A snapshot with another version is ignored.
+const snapshotVersion = 1
+
+type snapshotHeader struct {
+	Version int       `json:"version"`
+	Written time.Time `json:"written"`
+}
+
+type snapshotRecord struct {
+	RRset  *snapshotRRset  `json:"rrset,omitempty"`
+	Dnskey *snapshotDnskey `json:"dnskey,omitempty"`
+	Server *snapshotServer `json:"server,omitempty"`
+	Zone   *snapshotZone   `json:"zone,omitempty"`
+	TLSA   *snapshotTLSA   `json:"tlsa,omitempty"`
+}
+
+type snapshotRRs struct {
+	Name   string   `json:"name"`
+	RRtype uint16   `json:"rrtype"`
+	RRs    []string `json:"rrs,omitempty"`
+	RRSIGs []string `json:"rrsigs,omitempty"`
+}
+
+type snapshotRRset struct {
+	Name         string          `json:"name"`
+	RRtype       uint16          `json:"rrtype"`
+	Rcode        uint8           `json:"rcode"`
+	RRset        *snapshotRRs    `json:"rrset,omitempty"`
+	NegAuthority []*snapshotRRs  `json:"neg,omitempty"`
+	Ttl          uint32          `json:"ttl,omitempty"`
+	Context      CacheContext    `json:"context"`
+	State        ValidationState `json:"state"`
+	Expiration   time.Time       `json:"expiration"`
+	EDECode      uint16          `json:"ede_code,omitempty"`
+	EDEText      string          `json:"ede_text,omitempty"`
+	Transport    string          `json:"transport,omitempty"`
+}
+
+type snapshotDnskey struct {
+	Name       string          `json:"name"`
+	State      ValidationState `json:"state"`
+	Dnskey     string          `json:"dnskey"`
+	RRset      *snapshotRRs    `json:"rrset,omitempty"`
+	Expiration time.Time       `json:"expiration"`
+}
+
+type snapshotBackoff struct {
+	Addr         string    `json:"addr"`
+	Transport    string    `json:"transport"`
+	NextTry      time.Time `json:"next_try"`
+	FailureCount uint8     `json:"failures"`
+}
+
+type snapshotRTT struct {
+	Addr         string        `json:"addr"`
+	Transport    string        `json:"transport"`
+	EMA          time.Duration `json:"ema"`
+	Samples      uint32        `json:"samples"`
+	LastSample   time.Duration `json:"last_sample"`
+	LastSampleAt time.Time     `json:"last_sample_at"`
+}
+
+type snapshotServer struct {
+	Name             string            `json:"name"`
+	Addrs            []string          `json:"addrs,omitempty"`
+	Alpn             []string          `json:"alpn,omitempty"`
+	Transports       []string          `json:"transports,omitempty"`
+	TransportWeights map[string]uint8  `json:"weights,omitempty"`
+	ConnMode         ConnMode          `json:"connmode,omitempty"`
+	Src              string            `json:"src,omitempty"`
+	Expire           time.Time         `json:"expire,omitempty"`
+	Backoffs         []snapshotBackoff `json:"backoffs,omitempty"`
+	RTTs             []snapshotRTT     `json:"rtts,omitempty"`
+}
+
+type snapshotZone struct {
+	Name    string          `json:"name"`
+	State   ValidationState `json:"state"`
+	Servers []string        `json:"servers,omitempty"`
+}
+
+type snapshotTLSA struct {
+	Server     string          `json:"server"`
+	RRset      *snapshotRRs    `json:"rrset"`
+	State      ValidationState `json:"state"`
+	Expiration time.Time       `json:"expiration"`
+}
+
+// SnapshotStats counts what a snapshot wrote or restored.
+type SnapshotStats struct {
+	RRsets  int `json:"rrsets"`
+	Dnskeys int `json:"dnskeys"`
+	Servers int `json:"servers"`
+	Zones   int `json:"zones"`
+	TLSA    int `json:"tlsa"`
+	Skipped int `json:"skipped"` // cache entries not written or restored: expired, bogus or NTA-covered
+	Demoted int `json:"demoted"` // restored as not validated: an RRSIG is outside its validity period
+}
+
+func snapshotRRsOf(rrset *core.RRset) *snapshotRRs {
+	if rrset == nil {
+		return nil
+	}
+	s := &snapshotRRs{Name: rrset.Name, RRtype: rrset.RRtype}
+	for _, rr := range rrset.RRs {
+		s.RRs = append(s.RRs, rr.String())
+	}
+	for _, rr := range rrset.RRSIGs {
+		s.RRSIGs = append(s.RRSIGs, rr.String())
+	}
+	return s
+}
+
+// rrset parses the RRs back, capping every TTL at maxTTL.
+func (s *snapshotRRs) rrset(maxTTL uint32) (*core.RRset, error) {
+	if s == nil {
+		return nil, nil
+	}
+	rrset := &core.RRset{Name: s.Name, Class: dns.ClassINET, RRtype: s.RRtype}
+	parse := func(in []string) ([]dns.RR, error) {
+		var out []dns.RR
+		for _, str := range in {
+			rr, err := dns.NewRR(str)
+			if err != nil {
+				return nil, err
+			}
+			if rr == nil {
+				continue
+			}
+			if rr.Header().Ttl > maxTTL {
+				rr.Header().Ttl = maxTTL
+			}
+			out = append(out, rr)
+		}
+		return out, nil
+	}
+	var err error
+	if rrset.RRs, err = parse(s.RRs); err != nil {
+		return nil, err
+	}
+	if rrset.RRSIGs, err = parse(s.RRSIGs); err != nil {
+		return nil, err
+	}
+	return rrset, nil
+}
+
+// sigsValid reports whether every RRSIG in rrsets is within its validity
+// period at now.
+func sigsValid(now time.Time, rrsets ...*core.RRset) bool {
+	for _, rrset := range rrsets {
+		if rrset == nil {
+			continue
+		}
+		for _, rr := range rrset.RRSIGs {
+			if sig, ok := rr.(*dns.RRSIG); ok && !sig.ValidityPeriod(now) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ntaInsecure reports whether an entry for name in state is insecure only
+// because of a negative trust anchor.
+func (rrcache *RRsetCacheT) ntaInsecure(name string, state ValidationState, now time.Time) bool {
+	return state == ValidationStateInsecure && rrcache.NTAs.Covering(name, now) != ""
+}
+
+func transportStrings(ts []core.Transport) []string {
+	var out []string
+	for _, t := range ts {
+		out = append(out, core.TransportToString[t])
+	}
+	return out
+}
+
+// WriteSnapshot writes the cache contents to w.
+func (rrcache *RRsetCacheT) WriteSnapshot(w io.Writer, now time.Time) (SnapshotStats, error) {
+	var stats SnapshotStats
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion, Written: now}); err != nil {
+		return stats, err
+	}
+	put := func(rec snapshotRecord) error { return enc.Encode(rec) }
+
+	for item := range rrcache.RRsets.IterBuffered() {
+		cr := item.Val
+		if cr.Name == "" || !now.Before(cr.Expiration) || cr.State == ValidationStateBogus ||
+			rrcache.ntaInsecure(cr.Name, cr.State, now) {
+			stats.Skipped++
+			continue
+		}
+		srr := &snapshotRRset{
+			Name: cr.Name, RRtype: cr.RRtype, Rcode: cr.Rcode, RRset: snapshotRRsOf(cr.RRset),
+			Ttl: cr.Ttl, Context: cr.Context, State: cr.State, Expiration: cr.Expiration,
+			EDECode: cr.EDECode, EDEText: cr.EDEText, Transport: core.TransportToString[cr.Transport],
+		}
+		for _, neg := range cr.NegAuthority {
+			srr.NegAuthority = append(srr.NegAuthority, snapshotRRsOf(neg))
+		}
+		if err := put(snapshotRecord{RRset: srr}); err != nil {
+			return stats, err
+		}
+		stats.RRsets++
+	}
+
+	if rrcache.DnskeyCache != nil {
+		for item := range rrcache.DnskeyCache.Map.IterBuffered() {
+			cdr := item.Val
+			// Trust anchors come from the configuration, not the snapshot.
+			if cdr.TrustAnchor {
+				continue
+			}
+			if cdr.State != ValidationStateSecure || !now.Before(cdr.Expiration) {
+				stats.Skipped++
+				continue
+			}
+			dk := cdr.Dnskey
+			rec := &snapshotDnskey{Name: cdr.Name, State: cdr.State, Dnskey: dk.String(),
+				RRset: snapshotRRsOf(cdr.RRset), Expiration: cdr.Expiration}
+			if err := put(snapshotRecord{Dnskey: rec}); err != nil {
+				return stats, err
+			}
+			stats.Dnskeys++
+		}
+	}
+
+	for item := range rrcache.AuthServerMap.IterBuffered() {
+		as := item.Val
+		as.mu.Lock()
+		srv := &snapshotServer{
+			Name:       as.Name,
+			Addrs:      append([]string(nil), as.Addrs...),
+			Alpn:       append([]string(nil), as.Alpn...),
+			Transports: transportStrings(as.Transports),
+			ConnMode:   as.ConnMode,
+			Src:        as.Src,
+			Expire:     as.Expire,
+		}
+		if len(as.TransportWeights) > 0 {
+			srv.TransportWeights = map[string]uint8{}
+			for t, w := range as.TransportWeights {
+				srv.TransportWeights[core.TransportToString[t]] = w
+			}
+		}
+		for k, b := range as.AddressBackoffs {
+			if b != nil && now.Before(b.NextTry) {
+				srv.Backoffs = append(srv.Backoffs, snapshotBackoff{Addr: k.Addr,
+					Transport: core.TransportToString[k.Transport], NextTry: b.NextTry, FailureCount: b.FailureCount})
+			}
+		}
+		for k, r := range as.RTTEstimates {
+			if r != nil {
+				srv.RTTs = append(srv.RTTs, snapshotRTT{Addr: k.Addr, Transport: core.TransportToString[k.Transport],
+					EMA: r.EMA, Samples: r.Samples, LastSample: r.LastSample, LastSampleAt: r.LastSampleAt})
+			}
+		}
+		as.mu.Unlock()
+		if err := put(snapshotRecord{Server: srv}); err != nil {
+			return stats, err
+		}
+		stats.Servers++
+	}
+
+	for item := range rrcache.ServerMap.IterBuffered() {
+		zone := &snapshotZone{Name: item.Key}
+		private := false
+		for name, as := range item.Val {
+			// Stub zones have private AuthServer instances; they are set
+			// up from the configuration again.
+			if shared, ok := rrcache.AuthServerMap.Get(name); !ok || shared != as {
+				private = true
+				break
+			}
+			zone.Servers = append(zone.Servers, name)
+		}
+		if private {
+			continue
+		}
+		if z, ok := rrcache.ZoneMap.Get(item.Key); ok && !rrcache.ntaInsecure(item.Key, z.GetState(), now) {
+			zone.State = z.GetState()
+		}
+		if err := put(snapshotRecord{Zone: zone}); err != nil {
+			return stats, err
+		}
+		stats.Zones++
+	}
+
+	for item := range rrcache.ServerTLSA.IterBuffered() {
+		for _, rec := range rrcache.SnapshotTLSAForServer(item.Key) {
+			if rec == nil || rec.RRset == nil || !now.Before(rec.Expiration) ||
+				rrcache.ntaInsecure(rec.RRset.Name, rec.State, now) {
+				stats.Skipped++
+				continue
+			}
+			t := &snapshotTLSA{Server: item.Key, RRset: snapshotRRsOf(rec.RRset), State: rec.State, Expiration: rec.Expiration}
+			if err := put(snapshotRecord{TLSA: t}); err != nil {
+				return stats, err
+			}
+			stats.TLSA++
+		}
+	}
+
+	return stats, zw.Close()
+}
+
+// remaining returns the whole seconds left until exp, or 0 when exp has
+// passed.
+func remaining(exp, now time.Time) uint32 {
+	d := exp.Sub(now)
+	if d < time.Second {
+		return 0
+	}
+	return uint32(d / time.Second)
+}
+
+// ReadSnapshot restores cache contents written by WriteSnapshot. Entries
+// already in the cache (from priming or stub configuration) are kept.
+func (rrcache *RRsetCacheT) ReadSnapshot(r io.Reader, now time.Time) (SnapshotStats, error) {
+	var stats SnapshotStats
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return stats, err
+	}
+	defer zr.Close()
+	dec := json.NewDecoder(bufio.NewReader(zr))
+	var hdr snapshotHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return stats, fmt.Errorf("snapshot header: %w", err)
+	}
+	if hdr.Version != snapshotVersion {
+		return stats, fmt.Errorf("snapshot format version %d, want %d", hdr.Version, snapshotVersion)
+	}
+
+	var zones []*snapshotZone
+	for {
+		var rec snapshotRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return stats, fmt.Errorf("snapshot record: %w", err)
+		}
+		switch {
+		case rec.RRset != nil:
+			rrcache.restoreRRset(rec.RRset, now, &stats)
+		case rec.Dnskey != nil:
+			rrcache.restoreDnskey(rec.Dnskey, now, &stats)
+		case rec.Server != nil:
+			rrcache.restoreServer(rec.Server, now)
+			stats.Servers++
+		case rec.Zone != nil:
+			zones = append(zones, rec.Zone)
+		case rec.TLSA != nil:
+			rrcache.restoreTLSA(rec.TLSA, now, &stats)
+		}
+	}
+
+	// Zones go last: a zone is only restored while its NS RRset is.
+	for _, sz := range zones {
+		if _, ok := rrcache.ServerMap.Get(sz.Name); ok {
+			continue
+		}
+		if sz.Name != "." && rrcache.Get(sz.Name, dns.TypeNS) == nil {
+			stats.Skipped++
+			continue
+		}
+		sm := map[string]*AuthServer{}
+		for _, name := range sz.Servers {
+			if as := rrcache.GetOrCreateAuthServer(name); as != nil {
+				sm[name] = as
+			}
+		}
+		rrcache.ServerMap.Set(sz.Name, sm)
+		if sz.State > ValidationStateNone && sz.State != ValidationStateBogus {
+			if _, ok := rrcache.ZoneMap.Get(sz.Name); !ok {
+				rrcache.ZoneMap.Set(sz.Name, &Zone{ZoneName: sz.Name, State: sz.State})
+			}
+		}
+		stats.Zones++
+	}
+	return stats, nil
+}
+
+func (rrcache *RRsetCacheT) restoreRRset(s *snapshotRRset, now time.Time, stats *SnapshotStats) {
+	left := remaining(s.Expiration, now)
+	if left == 0 || s.State == ValidationStateBogus || rrcache.RRsets.Has(fmt.Sprintf("%s::%d", s.Name, s.RRtype)) {
+		stats.Skipped++
+		return
+	}
+	rrset, err := s.RRset.rrset(left)
+	if err != nil {
+		stats.Skipped++
+		return
+	}
+	cr := &CachedRRset{
+		Name: s.Name, RRtype: s.RRtype, Rcode: s.Rcode, RRset: rrset, Ttl: left,
+		Context: s.Context, State: s.State, Expiration: now.Add(time.Duration(left) * time.Second),
+		EDECode: s.EDECode, EDEText: s.EDEText,
+	}
+	if s.Transport != "" {
+		cr.Transport, _ = core.StringToTransport(s.Transport)
+	}
+	for _, sn := range s.NegAuthority {
+		neg, err := sn.rrset(left)
+		if err != nil {
+			stats.Skipped++
+			return
+		}
+		cr.NegAuthority = append(cr.NegAuthority, neg)
+	}
+	if !sigsValid(now, append([]*core.RRset{rrset}, cr.NegAuthority...)...) {
+		cr.State = ValidationStateNone
+		stats.Demoted++
+	}
+	rrcache.Set(s.Name, s.RRtype, cr)
+	stats.RRsets++
+}
+
+func (rrcache *RRsetCacheT) restoreDnskey(s *snapshotDnskey, now time.Time, stats *SnapshotStats) {
+	left := remaining(s.Expiration, now)
+	rr, err := dns.NewRR(s.Dnskey)
+	dk, ok := rr.(*dns.DNSKEY)
+	if left == 0 || err != nil || !ok || rrcache.DnskeyCache == nil {
+		stats.Skipped++
+		return
+	}
+	rrset, err := s.RRset.rrset(left)
+	if err != nil || !sigsValid(now, rrset) {
+		// A DNSKEY cache entry is only useful as a validated key.
+		stats.Skipped++
+		return
+	}
+	if rrcache.DnskeyCache.Get(s.Name, dk.KeyTag()) != nil {
+		return
+	}
+	rrcache.DnskeyCache.Set(s.Name, dk.KeyTag(), &CachedDnskeyRRset{
+		Name: s.Name, Keyid: dk.KeyTag(), State: s.State, Dnskey: *dk, RRset: rrset,
+		Expiration: now.Add(time.Duration(left) * time.Second),
+	})
+	stats.Dnskeys++
+}
+
+func (rrcache *RRsetCacheT) restoreServer(s *snapshotServer, now time.Time) {
+	as := rrcache.GetOrCreateAuthServer(s.Name)
+	if as == nil {
+		return
+	}
+	for _, addr := range s.Addrs {
+		as.AddAddr(addr)
+	}
+	for _, alpn := range s.Alpn {
+		as.AddAlpn(alpn)
+	}
+	for _, ts := range s.Transports {
+		if t, err := core.StringToTransport(ts); err == nil {
+			as.AddTransport(t)
+		}
+	}
+	if len(s.TransportWeights) > 0 {
+		weights := map[core.Transport]uint8{}
+		for ts, w := range s.TransportWeights {
+			if t, err := core.StringToTransport(ts); err == nil {
+				weights[t] = w
+			}
+		}
+		as.MergeTransportWeights(weights)
+	}
+	as.PromoteConnMode(s.ConnMode)
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if as.Src == "" || as.Src == "unknown" {
+		as.Src = s.Src
+	}
+	if as.Expire.IsZero() {
+		as.Expire = s.Expire
+	}
+	for _, b := range s.Backoffs {
+		t, err := core.StringToTransport(b.Transport)
+		if err != nil || !now.Before(b.NextTry) {
+			continue
+		}
+		if as.AddressBackoffs == nil {
+			as.AddressBackoffs = map[AddrXport]*AddressBackoff{}
+		}
+		key := AddrXport{Addr: b.Addr, Transport: t}
+		if _, ok := as.AddressBackoffs[key]; !ok {
+			as.AddressBackoffs[key] = &AddressBackoff{NextTry: b.NextTry, FailureCount: b.FailureCount}
+		}
+	}
+	for _, r := range s.RTTs {
+		t, err := core.StringToTransport(r.Transport)
+		if err != nil {
+			continue
+		}
+		if as.RTTEstimates == nil {
+			as.RTTEstimates = map[AddrXport]*RTTEstimate{}
+		}
+		key := AddrXport{Addr: r.Addr, Transport: t}
+		if _, ok := as.RTTEstimates[key]; !ok {
+			as.RTTEstimates[key] = &RTTEstimate{EMA: r.EMA, Samples: r.Samples, LastSample: r.LastSample, LastSampleAt: r.LastSampleAt}
+		}
+	}
+}
+
+func (rrcache *RRsetCacheT) restoreTLSA(s *snapshotTLSA, now time.Time, stats *SnapshotStats) {
+	left := remaining(s.Expiration, now)
+	if left == 0 || s.State == ValidationStateBogus {
+		stats.Skipped++
+		return
+	}
+	rrset, err := s.RRset.rrset(left)
+	if err != nil || rrset == nil || len(rrset.RRs) == 0 {
+		stats.Skipped++
+		return
+	}
+	state := s.State
+	if !sigsValid(now, rrset) {
+		state = ValidationStateNone
+		stats.Demoted++
+	}
+	rrcache.StoreTLSAForServer(s.Server, rrset.Name, rrset, state)
+	stats.TLSA++
+}
+
+// SaveSnapshot writes a snapshot to path, atomically replacing the file.
+func (rrcache *RRsetCacheT) SaveSnapshot(path string) (SnapshotStats, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return SnapshotStats{}, err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	stats, err := rrcache.WriteSnapshot(w, time.Now())
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return stats, err
+	}
+	return stats, os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot restores the snapshot in path.
+func (rrcache *RRsetCacheT) LoadSnapshot(path string) (SnapshotStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SnapshotStats{}, err
+	}
+	defer f.Close()
+	return rrcache.ReadSnapshot(f, time.Now())
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"bytes"
+	"crypto"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// signedRRset returns an RRset for rr signed with a throwaway key, the
+// signature valid from an hour ago until sigExpires.
+func signedRRset(t *testing.T, rrstr string, sigExpires time.Time) *core.RRset {
+	t.Helper()
+	rr, err := dns.NewRR(rrstr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dk := &dns.DNSKEY{Hdr: dns.RR_Header{Name: "example.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags: dns.ZONE, Protocol: 3, Algorithm: dns.ECDSAP256SHA256}
+	priv, err := dk.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := &dns.RRSIG{Hdr: dns.RR_Header{Name: rr.Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: rr.Header().Ttl},
+		KeyTag: dk.KeyTag(), SignerName: "example.", Algorithm: dk.Algorithm,
+		Inception: uint32(time.Now().Add(-time.Hour).Unix()), Expiration: uint32(sigExpires.Unix())}
+	if err := sig.Sign(priv.(crypto.Signer), []dns.RR{rr}); err != nil {
+		t.Fatal(err)
+	}
+	return &core.RRset{Name: rr.Header().Name, Class: dns.ClassINET, RRtype: rr.Header().Rrtype, RRs: []dns.RR{rr}, RRSIGs: []dns.RR{sig}}
+}
+
+func TestCacheSnapshotRoundTrip(t *testing.T) {
+	now := time.Now()
+	lg := log.New(os.Stderr, "", log.LstdFlags)
+	src := NewRRsetCache(lg, false, false)
+
+	put := func(rrset *core.RRset, state ValidationState) {
+		src.Set(rrset.Name, rrset.RRtype, &CachedRRset{Name: rrset.Name, RRtype: rrset.RRtype, RRset: rrset,
+			Context: ContextAnswer, State: state, Transport: core.TransportDoT})
+	}
+	put(signedRRset(t, "www.example. 3600 IN A 192.0.2.1", now.Add(24*time.Hour)), ValidationStateSecure)
+	put(signedRRset(t, "old.example. 3600 IN A 192.0.2.2", now.Add(10*time.Minute)), ValidationStateSecure)
+	put(signedRRset(t, "short.example. 60 IN A 192.0.2.3", now.Add(24*time.Hour)), ValidationStateSecure)
+	put(signedRRset(t, "bad.example. 3600 IN A 192.0.2.4", now.Add(24*time.Hour)), ValidationStateBogus)
+	ns, _ := dns.NewRR("example. 3600 IN NS ns1.example.")
+	src.Set("example.", dns.TypeNS, &CachedRRset{Name: "example.", RRtype: dns.TypeNS, Context: ContextReferral,
+		RRset: &core.RRset{Name: "example.", Class: dns.ClassINET, RRtype: dns.TypeNS, RRs: []dns.RR{ns}}})
+
+	as := NewAuthServer("ns1.example.")
+	as.Addrs = []string{"192.0.2.53"}
+	as.Alpn = []string{"do53", "dot"}
+	as.Transports = []core.Transport{core.TransportDo53, core.TransportDoT}
+	as.TransportWeights = map[core.Transport]uint8{core.TransportDoT: 80}
+	src.AddServers("example.", map[string]*AuthServer{"ns1.example.": as})
+	shared, _ := src.AuthServerMap.Get("ns1.example.")
+	shared.RecordRTT("192.0.2.53", core.TransportDoT, 20*time.Millisecond)
+	shared.RecordAddressFailure("192.0.2.53", core.TransportDoQ, nil)
+	shared.mu.Lock()
+	shared.AddressBackoffs[AddrXport{Addr: "192.0.2.53", Transport: core.TransportDoQ}].NextTry = now.Add(2 * time.Hour)
+	shared.mu.Unlock()
+	tlsa := signedRRset(t, "_853._tcp.ns1.example. 3600 IN TLSA 3 1 1 0123456789abcdef", now.Add(24*time.Hour))
+	src.StoreTLSAForServer("ns1.example.", tlsa.Name, tlsa, ValidationStateSecure)
+
+	var buf bytes.Buffer
+	wstats, err := src.WriteSnapshot(&buf, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wstats.RRsets != 4 || wstats.Skipped != 1 {
+		t.Errorf("write stats %+v, want 4 RRsets written and the bogus one skipped", wstats)
+	}
+
+	// Restore into a fresh cache half an hour later.
+	dst := NewRRsetCache(lg, false, false)
+	rstats, err := dst.ReadSnapshot(&buf, now.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rstats.RRsets != 3 || rstats.Skipped != 1 || rstats.Demoted != 1 || rstats.Zones != 1 || rstats.TLSA != 1 {
+		t.Errorf("restore stats %+v", rstats)
+	}
+
+	www := dst.Get("www.example.", dns.TypeA)
+	if www == nil || www.State != ValidationStateSecure || www.Transport != core.TransportDoT {
+		t.Fatalf("www.example. restored as %+v", www)
+	}
+	if ttl := www.RRset.RRs[0].Header().Ttl; ttl > 1800 || ttl < 1790 {
+		t.Errorf("www.example. TTL %d after 30 minutes of downtime, want about 1800", ttl)
+	}
+	if old := dst.Get("old.example.", dns.TypeA); old == nil || old.State != ValidationStateNone {
+		t.Errorf("entry with an expired RRSIG restored as %+v, want not validated", old)
+	}
+	if dst.Get("short.example.", dns.TypeA) != nil || dst.Get("bad.example.", dns.TypeA) != nil {
+		t.Error("expired or bogus entry restored")
+	}
+
+	sm, ok := dst.ServerMap.Get("example.")
+	if !ok || sm["ns1.example."] == nil {
+		t.Fatal("server map for example. not restored")
+	}
+	ras := sm["ns1.example."]
+	if rtt, ok := ras.GetRTT("192.0.2.53", core.TransportDoT); !ok || rtt != 20*time.Millisecond {
+		t.Errorf("RTT %v (%v), want 20ms", rtt, ok)
+	}
+	if ras.IsAddrXportAvailable("192.0.2.53", core.TransportDoQ) {
+		t.Error("backoff not restored")
+	}
+	if w := ras.GetTransportWeights()[core.TransportDoT]; w != 80 {
+		t.Errorf("DoT weight %d, want 80", w)
+	}
+	if rec := dst.LookupTLSAForServer("ns1.example.", "_853._tcp.ns1.example."); rec == nil || rec.State != ValidationStateSecure {
+		t.Errorf("TLSA restored as %+v", rec)
+	}
+
+	// Anything that is not a snapshot is refused.
+	if _, err := dst.ReadSnapshot(bytes.NewReader([]byte("not gzip")), now); err == nil {
+		t.Error("garbage accepted as a snapshot")
+	}
+}
+
+func TestCacheSnapshotLeavesOutNTAInsecure(t *testing.T) {
+	now := time.Now()
+	lg := log.New(os.Stderr, "", log.LstdFlags)
+	src := NewRRsetCache(lg, false, false)
+	if _, err := src.NTAs.Add("broken.example.", time.Hour, "test", now); err != nil {
+		t.Fatal(err)
+	}
+	for _, rrset := range []*core.RRset{
+		signedRRset(t, "www.broken.example. 3600 IN A 192.0.2.1", now.Add(24*time.Hour)),
+		signedRRset(t, "www.unsigned.example. 3600 IN A 192.0.2.2", now.Add(24*time.Hour)),
+	} {
+		src.Set(rrset.Name, rrset.RRtype, &CachedRRset{Name: rrset.Name, RRtype: rrset.RRtype, RRset: rrset,
+			Context: ContextAnswer, State: ValidationStateInsecure})
+	}
+
+	var buf bytes.Buffer
+	wstats, err := src.WriteSnapshot(&buf, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wstats.RRsets != 1 || wstats.Skipped != 1 {
+		t.Errorf("write stats %+v, want the NTA-covered entry left out", wstats)
+	}
+
+	// The restarted resolver has no NTA for broken.example.
+	dst := NewRRsetCache(lg, false, false)
+	if _, err := dst.ReadSnapshot(&buf, now); err != nil {
+		t.Fatal(err)
+	}
+	if cr := dst.Get("www.broken.example.", dns.TypeA); cr != nil {
+		t.Errorf("NTA-covered entry restored as %+v", cr)
+	}
+	if cr := dst.Get("www.unsigned.example.", dns.TypeA); cr == nil || cr.State != ValidationStateInsecure {
+		t.Errorf("insecure entry restored as %+v", cr)
+	}
+}
@@ -0,0 +1,265 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// A ValidationTrace records the steps the validator takes while a context
+// carrying it is in use: each zone cut's DS and DNSKEY checks, every RRSIG
+// tried with its key, algorithm and validity window, and the denial proofs
+// checked for negative answers. It is what "imr explain" reports. A traced
+// validation never reuses validation states cached earlier, so the whole
+// chain of trust is walked again.
+
+// Trace step kinds.
+const (
+	TraceStepRRset       = "rrset"        // verdict for an RRset
+	TraceStepRRSIG       = "rrsig"        // one signature checked
+	TraceStepDNSKEY      = "dnskey"       // signer key lookup or DNSKEY RRset check
+	TraceStepDS          = "ds"           // DS digest matched against a DNSKEY
+	TraceStepTrustAnchor = "trust-anchor" // DNSKEY RRset checked against a trust anchor
+	TraceStepNTA         = "nta"          // validation disabled by a negative trust anchor
+	TraceStepDenial      = "denial"       // negative answer proof
+)
+
+// TraceStep is one step of a ValidationTrace.
+type TraceStep struct {
+	Zone       string    // zone cut the step belongs to
+	Kind       string    // one of the TraceStep* kinds
+	Owner      string    // owner of the data checked
+	RRtype     string    // type of the data checked
+	KeyTag     uint16    // key tag of the DNSKEY, RRSIG or DS involved
+	Algorithm  string    // DNSSEC algorithm involved
+	DigestType string    // DS digest type
+	Inception  time.Time // RRSIG validity window
+	Expiration time.Time
+	State      ValidationState // outcome for rrset steps
+	Ok         bool
+	Detail     string
+}
+
+// ValidationTrace collects TraceSteps. It is safe for concurrent use.
+type ValidationTrace struct {
+	mu     sync.Mutex
+	steps  []TraceStep
+	chains map[string]bool // signer zones whose cached keys were re-checked
+}
+
+// NewValidationTrace returns an empty trace.
+func NewValidationTrace() *ValidationTrace {
+	return &ValidationTrace{chains: map[string]bool{}}
+}
+
+type validationTraceKey struct{}
+
+// WithValidationTrace returns a context in which validation records its
+// steps in trace and disregards cached validation states.
+func WithValidationTrace(ctx context.Context, trace *ValidationTrace) context.Context {
+	return context.WithValue(ctx, validationTraceKey{}, trace)
+}
+
+// TracingValidation reports whether ctx carries a validation trace.
+// Lookups made for a traced validation must not be shared with other
+// callers, whose validation would then not be recorded.
+func TracingValidation(ctx context.Context) bool {
+	return traceFrom(ctx) != nil
+}
+
+// traceFrom returns the trace carried by ctx, or nil.
+func traceFrom(ctx context.Context) *ValidationTrace {
+	if ctx == nil {
+		return nil
+	}
+	trace, _ := ctx.Value(validationTraceKey{}).(*ValidationTrace)
+	return trace
+}
+
+// add records a step. A nil trace records nothing.
+func (t *ValidationTrace) add(step TraceStep) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.steps = append(t.steps, step)
+	t.mu.Unlock()
+}
+
+// firstChainCheck reports whether the cached keys of zone have not yet been
+// re-checked during this trace, and marks them as checked.
+func (t *ValidationTrace) firstChainCheck(zone string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.chains[zone] {
+		return false
+	}
+	t.chains[zone] = true
+	return true
+}
+
+// Steps returns the recorded steps in the order they were taken.
+func (t *ValidationTrace) Steps() []TraceStep {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceStep(nil), t.steps...)
+}
+
+// FirstFailure returns the index of the failing step that explains a
+// bogus or indeterminate outcome, or -1 if no step failed. Verdict steps
+// only count when no more specific step failed before them.
+func (t *ValidationTrace) FirstFailure() int {
+	steps := t.Steps()
+	verdict := -1
+	for i, s := range steps {
+		if s.Ok {
+			continue
+		}
+		if s.Kind != TraceStepRRset {
+			return i
+		}
+		if verdict < 0 {
+			verdict = i
+		}
+	}
+	return verdict
+}
+
+// traceRRSIG records the outcome of checking sig over the owner/rrtype RRset.
+func traceRRSIG(trace *ValidationTrace, owner string, rrtype uint16, sig *dns.RRSIG, ok bool, detail string) {
+	if trace == nil {
+		return
+	}
+	trace.add(TraceStep{
+		Zone:       dns.Fqdn(sig.SignerName),
+		Kind:       TraceStepRRSIG,
+		Owner:      owner,
+		RRtype:     dns.TypeToString[rrtype],
+		KeyTag:     sig.KeyTag,
+		Algorithm:  algorithmString(sig.Algorithm),
+		Inception:  time.Unix(int64(sig.Inception), 0).UTC(),
+		Expiration: time.Unix(int64(sig.Expiration), 0).UTC(),
+		Ok:         ok,
+		Detail:     detail,
+	})
+}
+
+// traceVerdict records the validation state reached for an RRset.
+func traceVerdict(trace *ValidationTrace, zone, owner string, rrtype uint16, state ValidationState, detail string) {
+	if trace == nil {
+		return
+	}
+	trace.add(TraceStep{
+		Zone:   zone,
+		Kind:   TraceStepRRset,
+		Owner:  owner,
+		RRtype: dns.TypeToString[rrtype],
+		State:  state,
+		Ok:     state == ValidationStateSecure || state == ValidationStateInsecure,
+		Detail: detail,
+	})
+}
+
+// traceDenial records the outcome of checking a denial of existence proof.
+func traceDenial(trace *ValidationTrace, zone, qname string, qtype uint16, ok bool, detail string) {
+	trace.add(TraceStep{Zone: zone, Kind: TraceStepDenial, Owner: qname, RRtype: dns.TypeToString[qtype], Ok: ok, Detail: detail})
+}
+
+// traceDSMatch records the outcome of anchoring a DNSKEY RRset with ds: the
+// DNSKEY the DS digest matched, if any, and the RRSIG(DNSKEY) made by it.
+func traceDSMatch(trace *ValidationTrace, rrset *core.RRset, ds *dns.DS, zone string, valid bool, key *dns.DNSKEY) {
+	if trace == nil {
+		return
+	}
+	step := TraceStep{
+		Zone:       zone,
+		Kind:       TraceStepDS,
+		Owner:      zone,
+		RRtype:     "DNSKEY",
+		KeyTag:     ds.KeyTag,
+		Algorithm:  algorithmString(ds.Algorithm),
+		DigestType: dns.HashToString[ds.DigestType],
+		Ok:         valid,
+	}
+	if key == nil {
+		step.Detail = fmt.Sprintf("no SEP DNSKEY with key tag %d matches the DS digest", ds.KeyTag)
+		trace.add(step)
+		return
+	}
+	sig := dnskeySigBy(rrset, ds.KeyTag, zone)
+	if sig != nil {
+		step.Inception = time.Unix(int64(sig.Inception), 0).UTC()
+		step.Expiration = time.Unix(int64(sig.Expiration), 0).UTC()
+	}
+	step.Detail = "DS digest matches; " + dnskeySigDetail(rrset, sig, key, valid)
+	trace.add(step)
+}
+
+// dnskeySigBy returns the RRSIG(DNSKEY) in rrset made by key keyid of zone.
+func dnskeySigBy(rrset *core.RRset, keyid uint16, zone string) *dns.RRSIG {
+	for _, rr := range rrset.RRSIGs {
+		if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == dns.TypeDNSKEY &&
+			sig.KeyTag == keyid && dns.Fqdn(sig.SignerName) == zone {
+			return sig
+		}
+	}
+	return nil
+}
+
+// dnskeySigDetail explains the outcome of checking the RRSIG(DNSKEY) sig
+// made by key.
+func dnskeySigDetail(rrset *core.RRset, sig *dns.RRSIG, key *dns.DNSKEY, valid bool) string {
+	switch {
+	case sig == nil:
+		return "no RRSIG(DNSKEY) made by this key"
+	case valid:
+		return "RRSIG(DNSKEY) verifies and is within its validity window"
+	}
+	if err := sig.Verify(key, rrset.RRs); err == dns.ErrAlg {
+		return fmt.Sprintf("algorithm %s is not supported", algorithmString(sig.Algorithm))
+	} else if err != nil {
+		return fmt.Sprintf("RRSIG(DNSKEY) does not verify: %v", err)
+	}
+	if time.Now().UTC().Before(time.Unix(int64(sig.Inception), 0)) {
+		return "RRSIG(DNSKEY) is not yet valid"
+	}
+	return "RRSIG(DNSKEY) has expired"
+}
+
+// retraceSignerKeys validates the cached DNSKEY RRset of signer again for a
+// traced validation. It returns ValidationStateNone when there is nothing
+// to re-check.
+func (rrcache *RRsetCacheT) retraceSignerKeys(ctx context.Context, signer string, fetcher RRsetFetcher) ValidationState {
+	crr := rrcache.Get(signer, dns.TypeDNSKEY)
+	if crr == nil || crr.RRset == nil || len(crr.RRset.RRs) == 0 {
+		traceFrom(ctx).add(TraceStep{Zone: signer, Kind: TraceStepDNSKEY, Owner: signer, RRtype: "DNSKEY", Ok: true,
+			Detail: "keys were validated earlier but the DNSKEY RRset is no longer cached; chain not re-checked"})
+		return ValidationStateNone
+	}
+	vstate, err := rrcache.ValidateDNSKEYs(ctx, crr.RRset, fetcher)
+	if err != nil {
+		return ValidationStateNone
+	}
+	return vstate
+}
+
+func algorithmString(alg uint8) string {
+	if s, ok := dns.AlgorithmToString[alg]; ok {
+		return s
+	}
+	return fmt.Sprintf("ALG%d", alg)
+}
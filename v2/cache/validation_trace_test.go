@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package cache
+
+import (
+	"context"
+	"crypto"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+func TestValidationTrace(t *testing.T) {
+	const zone = "example."
+	rrcache := NewRRsetCache(log.New(os.Stderr, "test ", 0), false, false)
+
+	newKey := func(flags uint16) (*dns.DNSKEY, crypto.Signer) {
+		key := &dns.DNSKEY{
+			Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+			Flags:     flags,
+			Protocol:  3,
+			Algorithm: dns.ED25519,
+		}
+		priv, err := key.Generate(256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, priv.(crypto.Signer)
+	}
+	sign := func(rrs []dns.RR, key *dns.DNSKEY, priv crypto.Signer, inception, expiration time.Time) *dns.RRSIG {
+		sig := &dns.RRSIG{
+			Hdr:        dns.RR_Header{Ttl: rrs[0].Header().Ttl},
+			KeyTag:     key.KeyTag(),
+			SignerName: zone,
+			Algorithm:  key.Algorithm,
+			Inception:  uint32(inception.Unix()),
+			Expiration: uint32(expiration.Unix()),
+		}
+		if err := sig.Sign(priv, rrs); err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	now := time.Now()
+
+	// The KSK is a trust anchor, the ZSK was validated earlier and is in
+	// the DnskeyCache, and the DNSKEY RRset is cached.
+	ksk, kskPriv := newKey(257)
+	zsk, zskPriv := newKey(256)
+	dnskeys := []dns.RR{ksk, zsk}
+	dnskeyRRset := &core.RRset{Name: zone, Class: dns.ClassINET, RRtype: dns.TypeDNSKEY, RRs: dnskeys,
+		RRSIGs: []dns.RR{sign(dnskeys, ksk, kskPriv, now.Add(-time.Hour), now.Add(24*time.Hour))}}
+	rrcache.Set(zone, dns.TypeDNSKEY, &CachedRRset{Name: zone, RRtype: dns.TypeDNSKEY, RRset: dnskeyRRset,
+		Context: ContextAnswer, State: ValidationStateSecure, Expiration: now.Add(time.Hour)})
+	for _, k := range []*dns.DNSKEY{ksk, zsk} {
+		rrcache.DnskeyCache.Set(zone, k.KeyTag(), &CachedDnskeyRRset{Name: zone, Keyid: k.KeyTag(),
+			State: ValidationStateSecure, TrustAnchor: k == ksk, Dnskey: *k, Expiration: now.Add(time.Hour)})
+	}
+
+	// An A RRset whose only signature has expired, cached with a stale
+	// Secure verdict that a traced validation must not reuse.
+	a, _ := dns.NewRR("www.example. 300 IN A 192.0.2.1")
+	rrset := &core.RRset{Name: "www.example.", Class: dns.ClassINET, RRtype: dns.TypeA, RRs: []dns.RR{a},
+		RRSIGs: []dns.RR{sign([]dns.RR{a}, zsk, zskPriv, now.Add(-48*time.Hour), now.Add(-time.Hour))}}
+	rrcache.Set("www.example.", dns.TypeA, &CachedRRset{Name: "www.example.", RRtype: dns.TypeA, RRset: rrset,
+		Context: ContextAnswer, State: ValidationStateSecure, Expiration: now.Add(time.Hour)})
+
+	if state, _ := rrcache.ValidateRRset(context.Background(), rrset, nil); state != ValidationStateSecure {
+		t.Fatalf("untraced validation = %s, want the cached secure verdict", ValidationStateToString[state])
+	}
+
+	trace := NewValidationTrace()
+	state, err := rrcache.ValidateRRset(WithValidationTrace(context.Background(), trace), rrset, nil)
+	if err != nil || state != ValidationStateBogus {
+		t.Fatalf("traced validation = %s, %v; want bogus", ValidationStateToString[state], err)
+	}
+
+	steps := trace.Steps()
+	var kinds []string
+	for _, s := range steps {
+		kinds = append(kinds, s.Kind)
+	}
+	want := []string{TraceStepTrustAnchor, TraceStepRRset, TraceStepRRSIG, TraceStepRRset}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Fatalf("trace steps %v, want %v", kinds, want)
+	}
+	if !steps[0].Ok || steps[0].KeyTag != ksk.KeyTag() || steps[1].State != ValidationStateSecure {
+		t.Errorf("DNSKEY RRset not anchored by the trust anchor: %+v %+v", steps[0], steps[1])
+	}
+	i := trace.FirstFailure()
+	if i != 2 {
+		t.Fatalf("FirstFailure = %d, want the RRSIG step", i)
+	}
+	if f := steps[i]; f.KeyTag != zsk.KeyTag() || f.Algorithm != "ED25519" || f.Detail != "signature has expired" ||
+		!f.Expiration.Before(now) {
+		t.Errorf("failing step %+v", f)
+	}
+}
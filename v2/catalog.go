@@ -463,6 +463,16 @@ func AutoConfigureZonesFromCatalog(ctx context.Context, update *CatalogZoneUpdat
 		}
 
 		lg.Info("CATALOG: zone auto-configured successfully", "zone", zoneName, "meta", member.MetaGroup, "signing", member.SigningGroup, "services", member.ServiceGroups)
+		emitEvent(EventCatalogMemberAdded, zoneName, map[string]interface{}{
+			"catalog": update.CatalogZone, "auto_configured": true,
+		})
+		auditRecord(conf.Internal.KeyDB, AuditEntry{
+			Actor:  AuditActor{Type: AuditActorEngine, Name: "catalog"},
+			Source: AuditSourceCatalog,
+			Zone:   zoneName,
+			Action: "catalog-member-auto-create",
+			Detail: fmt.Sprintf("catalog=%s meta=%s upstream=%s", update.CatalogZone, member.MetaGroup, configGroupConfig.Upstream),
+		})
 		configuredCount++
 		processedCount++
 	}
@@ -576,6 +586,7 @@ func (cm *CatalogMembership) AddMemberZone(zoneName string) error {
 	}
 
 	lg.Info("CATALOG: added zone to catalog", "zone", zoneName, "catalog", cm.CatalogZoneName, "hash", cm.MemberZones[zoneName].Hash)
+	emitEvent(EventCatalogMemberAdded, zoneName, map[string]interface{}{"catalog": cm.CatalogZoneName})
 	return nil
 }
 
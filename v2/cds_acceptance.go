@@ -0,0 +1,691 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package tdns
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+	"github.com/spf13/viper"
+)
+
+// Parent-side CDS acceptance policy (RFC 7344, RFC 8078, RFC 9615).
+//
+// Without a policy the scanner turns a CDS RRset that all child nameservers
+// agree on into a CHILD-UPDATE at once. A parent zone that names a policy
+// (`cdspolicy:` in the zone config, resolved against the top-level
+// `cdspolicies:` list) instead classifies each CDS RRset as
+//
+//	update            a change to an existing secure delegation
+//	delete            the algorithm-0 delete-DS sentinel (RFC 8078 §4)
+//	bootstrap-signal  a first DS authenticated via _dsboot signaling names
+//	                  under the child's nameservers (RFC 9615)
+//	bootstrap-apex    a first DS taken unauthenticated from the child apex
+//	                  (RFC 8078 §3)
+//
+// and applies that kind's rule. A rule either rejects the kind outright or
+// accepts it once the same CDS RRset has been seen, consistent across all
+// child nameservers, in `observations` scans at least `interval` apart, the
+// first of them at least `hold-down` ago. Until then the change is deferred
+// and the scanner re-checks the child itself. With `validate` (the default)
+// the CDS RRset must also chain to the child's DNSKEY RRset — anchored in the
+// current DS RRset when there is one — and must keep a DS for a key that
+// signs the DNSKEY RRset, and `max-ds` caps the size of the resulting DS
+// RRset. Every verdict is recorded in the audit journal (source cds-policy,
+// zone = the child) for later audit and customer support.
+const (
+	CdsKindUpdate          = "update"
+	CdsKindDelete          = "delete"
+	CdsKindBootstrapSignal = "bootstrap-signal"
+	CdsKindBootstrapApex   = "bootstrap-apex"
+
+	CdsVerdictAccepted = "accepted"
+	CdsVerdictDeferred = "deferred"
+	CdsVerdictRejected = "rejected"
+)
+
+var cdsKinds = []string{CdsKindUpdate, CdsKindDelete, CdsKindBootstrapSignal, CdsKindBootstrapApex}
+
+const defaultCdsRecheckInterval = 5 * time.Minute
+
+// CdsRuleConf is the YAML rule for one kind of CDS change.
+type CdsRuleConf struct {
+	Action       string `yaml:"action" mapstructure:"action"` // accept | reject
+	Observations int    `yaml:"observations" mapstructure:"observations"`
+	Interval     string `yaml:"interval" mapstructure:"interval"`
+	HoldDown     string `yaml:"hold-down" mapstructure:"hold-down"`
+}
+
+// CdsPolicyConf is a named policy from the `cdspolicies:` config list.
+type CdsPolicyConf struct {
+	Name            string      `yaml:"name" mapstructure:"name"`
+	Validate        *bool       `yaml:"validate" mapstructure:"validate"` // default true
+	MaxDS           int         `yaml:"max-ds" mapstructure:"max-ds"`     // 0: no cap
+	Update          CdsRuleConf `yaml:"update" mapstructure:"update"`
+	Delete          CdsRuleConf `yaml:"delete" mapstructure:"delete"`
+	BootstrapSignal CdsRuleConf `yaml:"bootstrap-signal" mapstructure:"bootstrap-signal"`
+	BootstrapApex   CdsRuleConf `yaml:"bootstrap-apex" mapstructure:"bootstrap-apex"` // default reject
+}
+
+type CdsRule struct {
+	Accept       bool
+	Observations int
+	Interval     time.Duration
+	HoldDown     time.Duration
+}
+
+// CdsPolicy is a parsed CdsPolicyConf, bound to ZoneData.CdsPolicy.
+type CdsPolicy struct {
+	Name     string
+	Validate bool
+	MaxDS    int
+	Rules    map[string]CdsRule // by kind
+}
+
+// LookupCdsPolicy resolves a policy name against the `cdspolicies:` config list.
+func LookupCdsPolicy(name string) (*CdsPolicy, error) {
+	var confs []CdsPolicyConf
+	if err := viper.UnmarshalKey("cdspolicies", &confs); err != nil {
+		return nil, fmt.Errorf("failed to parse cdspolicies config: %w", err)
+	}
+	for _, c := range confs {
+		if c.Name == name {
+			return NewCdsPolicy(c)
+		}
+	}
+	return nil, fmt.Errorf("cds policy %q not found in cdspolicies config", name)
+}
+
+func NewCdsPolicy(c CdsPolicyConf) (*CdsPolicy, error) {
+	p := &CdsPolicy{
+		Name:     c.Name,
+		Validate: c.Validate == nil || *c.Validate,
+		MaxDS:    c.MaxDS,
+		Rules:    map[string]CdsRule{},
+	}
+	if c.MaxDS < 0 {
+		return nil, fmt.Errorf("cds policy %q: max-ds must not be negative", c.Name)
+	}
+	confs := map[string]CdsRuleConf{
+		CdsKindUpdate:          c.Update,
+		CdsKindDelete:          c.Delete,
+		CdsKindBootstrapSignal: c.BootstrapSignal,
+		CdsKindBootstrapApex:   c.BootstrapApex,
+	}
+	for _, kind := range cdsKinds {
+		rule, err := parseCdsRule(confs[kind], kind != CdsKindBootstrapApex)
+		if err != nil {
+			return nil, fmt.Errorf("cds policy %q: %s: %w", c.Name, kind, err)
+		}
+		p.Rules[kind] = rule
+	}
+	return p, nil
+}
+
+func parseCdsRule(c CdsRuleConf, defaultAccept bool) (CdsRule, error) {
+	rule := CdsRule{Accept: defaultAccept, Observations: 1, Interval: defaultCdsRecheckInterval}
+	switch strings.ToLower(c.Action) {
+	case "":
+	case "accept":
+		rule.Accept = true
+	case "reject":
+		rule.Accept = false
+	default:
+		return rule, fmt.Errorf("action %q: must be accept or reject", c.Action)
+	}
+	if c.Observations < 0 {
+		return rule, fmt.Errorf("observations must not be negative")
+	}
+	if c.Observations > 0 {
+		rule.Observations = c.Observations
+	}
+	for _, d := range []struct {
+		name, val string
+		dst       *time.Duration
+	}{{"interval", c.Interval, &rule.Interval}, {"hold-down", c.HoldDown, &rule.HoldDown}} {
+		if d.val == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.val)
+		if err != nil || v < 0 {
+			return rule, fmt.Errorf("%s %q: not a non-negative duration", d.name, d.val)
+		}
+		*d.dst = v
+	}
+	if rule.Interval == 0 {
+		rule.Interval = defaultCdsRecheckInterval
+	}
+	return rule, nil
+}
+
+// cdsCandidate is everything the scanner learned about one child's CDS.
+type cdsCandidate struct {
+	Parent    string
+	Child     string
+	CDS       *core.RRset
+	DNSKEY    *core.RRset // nil unless the policy validates
+	CurrentDS []dns.RR    // DS RRset the delegation backend holds now
+	// Defer, when set, is why this scan cannot count as an observation
+	// (nameservers disagree, a query failed).
+	Defer string
+	// SignalVerified is true when the RFC 9615 signaling-name check passed;
+	// SignalErr is why it did not, when it was attempted.
+	SignalVerified bool
+	SignalErr      error
+}
+
+// CdsDecision is the policy's verdict on a cdsCandidate. An empty Verdict
+// means there is nothing to decide (the CDS matches the current DS RRset).
+type CdsDecision struct {
+	Kind      string
+	Verdict   string
+	Reason    string
+	DS        []dns.RR
+	Adds      []dns.RR
+	Removes   []dns.RR
+	RecheckAt time.Time // deferred: when the next observation can count
+}
+
+// cdsIsDeleteSentinel reports whether cds is the RFC 8078 §4 delete
+// request: a single CDS with algorithm 0.
+func cdsIsDeleteSentinel(cds *core.RRset) bool {
+	if len(cds.RRs) != 1 {
+		return false
+	}
+	c, ok := cds.RRs[0].(*dns.CDS)
+	return ok && c.Algorithm == 0
+}
+
+// cdsMixesDeleteSentinel reports whether cds holds the delete sentinel next
+// to other records, which RFC 8078 §4 makes a malformed CDS RRset.
+func cdsMixesDeleteSentinel(cds *core.RRset) bool {
+	if len(cds.RRs) < 2 {
+		return false
+	}
+	for _, rr := range cds.RRs {
+		if c, ok := rr.(*dns.CDS); ok && c.Algorithm == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// cdsToDS converts each CDS to its DS form (RFC 7344 §3.1).
+func cdsToDS(rrs []dns.RR) []dns.RR {
+	var out []dns.RR
+	for _, rr := range rrs {
+		if cds, ok := rr.(*dns.CDS); ok {
+			ds := &dns.DS{
+				Hdr: dns.RR_Header{
+					Name:   cds.Hdr.Name,
+					Rrtype: dns.TypeDS,
+					Class:  dns.ClassINET,
+					Ttl:    cds.Hdr.Ttl,
+				},
+				KeyTag:     cds.KeyTag,
+				Algorithm:  cds.Algorithm,
+				DigestType: cds.DigestType,
+				Digest:     cds.Digest,
+			}
+			out = append(out, ds)
+		}
+	}
+	return out
+}
+
+func (c cdsCandidate) kind() string {
+	switch {
+	case cdsIsDeleteSentinel(c.CDS):
+		return CdsKindDelete
+	case len(c.CurrentDS) > 0:
+		return CdsKindUpdate
+	case c.SignalVerified:
+		return CdsKindBootstrapSignal
+	default:
+		return CdsKindBootstrapApex
+	}
+}
+
+// evaluate applies the policy to c, keeping the observation record for the
+// child in kdb up to date.
+func (p *CdsPolicy) evaluate(kdb *KeyDB, c cdsCandidate, now time.Time, lg *log.Logger) (CdsDecision, error) {
+	d := CdsDecision{Kind: c.kind()}
+	rule := p.Rules[d.Kind]
+
+	reject := func(format string, args ...interface{}) (CdsDecision, error) {
+		d.Verdict = CdsVerdictRejected
+		d.Reason = fmt.Sprintf(format, args...)
+		return d, clearCdsObservation(kdb, c.Parent, c.Child)
+	}
+
+	if cdsMixesDeleteSentinel(c.CDS) {
+		return reject("CDS RRset mixes the delete sentinel with other records (RFC 8078 §4)")
+	}
+	if d.Kind == CdsKindDelete && len(c.CurrentDS) == 0 {
+		return d, clearCdsObservation(kdb, c.Parent, c.Child)
+	}
+	if c.Defer != "" {
+		d.Verdict = CdsVerdictDeferred
+		d.Reason = c.Defer
+		d.RecheckAt = now.Add(rule.Interval)
+		return d, clearCdsObservation(kdb, c.Parent, c.Child)
+	}
+	if !rule.Accept {
+		if d.Kind == CdsKindBootstrapApex && c.SignalErr != nil {
+			return reject("policy %s does not accept unauthenticated bootstrap, and the RFC 9615 signaling check failed: %v", p.Name, c.SignalErr)
+		}
+		return reject("policy %s does not accept %s", p.Name, d.Kind)
+	}
+
+	if d.Kind == CdsKindDelete {
+		d.Removes = c.CurrentDS
+	} else {
+		d.DS = cdsToDS(c.CDS.RRs)
+		if p.MaxDS > 0 && len(d.DS) > p.MaxDS {
+			return reject("CDS RRset maps to %d DS records, policy %s allows at most %d", len(d.DS), p.Name, p.MaxDS)
+		}
+	}
+	if p.Validate {
+		var anchors []dns.RR
+		if d.Kind == CdsKindUpdate || d.Kind == CdsKindDelete {
+			anchors = c.CurrentDS
+		}
+		if err := validateCdsChain(c.Child, c.CDS, c.DNSKEY, anchors, d.Kind != CdsKindDelete, now); err != nil {
+			return reject("validation failed: %v", err)
+		}
+	}
+	if d.Kind != CdsKindDelete {
+		changed, adds, removes := core.RRsetDiffer(c.Child, d.DS, c.CurrentDS, dns.TypeDS, lg, false, false)
+		if !changed {
+			return CdsDecision{Kind: d.Kind}, clearCdsObservation(kdb, c.Parent, c.Child)
+		}
+		d.Adds, d.Removes = adds, removes
+	}
+
+	obs, err := noteCdsObservation(kdb, c.Parent, c.Child, d.Kind, cdsFingerprint(c.CDS), rule.Interval, now)
+	if err != nil {
+		return d, err
+	}
+	holdUntil := obs.FirstSeen.Add(rule.HoldDown)
+	if obs.Count >= rule.Observations && !now.Before(holdUntil) {
+		d.Verdict = CdsVerdictAccepted
+		d.Reason = fmt.Sprintf("%s seen in %d observation(s) since %s", d.Kind, obs.Count, obs.FirstSeen.UTC().Format(time.RFC3339))
+		return d, clearCdsObservation(kdb, c.Parent, c.Child)
+	}
+	d.Verdict = CdsVerdictDeferred
+	d.RecheckAt = obs.LastSeen.Add(rule.Interval)
+	if obs.Count >= rule.Observations {
+		d.RecheckAt = holdUntil
+	}
+	d.Reason = fmt.Sprintf("%s: observation %d of %d, hold-down until %s", d.Kind, obs.Count, rule.Observations, holdUntil.UTC().Format(time.RFC3339))
+	return d, nil
+}
+
+// validateCdsChain checks the CDS RRset against the child's DNSKEY RRset:
+// the DNSKEY RRset must be self-signed (by a key in anchors, the current DS
+// RRset, when there is one) and the CDS RRset signed by one of its keys.
+// With requireKeyMatch at least one CDS must match a key that signs the
+// DNSKEY RRset, so that the new DS RRset does not break the delegation.
+func validateCdsChain(child string, cds, dnskey *core.RRset, anchors []dns.RR, requireKeyMatch bool, now time.Time) error {
+	if dnskey == nil || len(dnskey.RRs) == 0 {
+		return fmt.Errorf("no DNSKEY RRset at %s", child)
+	}
+	keys := map[uint16][]*dns.DNSKEY{}
+	for _, rr := range dnskey.RRs {
+		if k, ok := rr.(*dns.DNSKEY); ok {
+			keys[k.KeyTag()] = append(keys[k.KeyTag()], k)
+		}
+	}
+	ksks := rrsetSigners(child, dnskey, keys, now)
+	if len(ksks) == 0 {
+		return fmt.Errorf("DNSKEY RRset carries no valid self-signature")
+	}
+	if len(anchors) > 0 {
+		anchored := slices.ContainsFunc(ksks, func(k *dns.DNSKEY) bool {
+			return slices.ContainsFunc(anchors, func(rr dns.RR) bool {
+				ds, ok := rr.(*dns.DS)
+				return ok && dsMatchesKey(ds, k)
+			})
+		})
+		if !anchored {
+			return fmt.Errorf("DNSKEY RRset is not signed by a key in the current DS RRset")
+		}
+	}
+	if len(rrsetSigners(child, cds, keys, now)) == 0 {
+		return fmt.Errorf("CDS RRset is not signed by a key in the DNSKEY RRset")
+	}
+	if requireKeyMatch {
+		newDS := cdsToDS(cds.RRs)
+		matched := slices.ContainsFunc(ksks, func(k *dns.DNSKEY) bool {
+			return slices.ContainsFunc(newDS, func(rr dns.RR) bool {
+				return dsMatchesKey(rr.(*dns.DS), k)
+			})
+		})
+		if !matched {
+			return fmt.Errorf("no CDS matches a key that signs the DNSKEY RRset; the DS RRset would break the delegation")
+		}
+	}
+	return nil
+}
+
+// rrsetSigners returns the keys with a currently valid RRSIG over rrset.
+func rrsetSigners(child string, rrset *core.RRset, keys map[uint16][]*dns.DNSKEY, now time.Time) []*dns.DNSKEY {
+	var out []*dns.DNSKEY
+	for _, rr := range rrset.RRSIGs {
+		sig, ok := rr.(*dns.RRSIG)
+		if !ok || !strings.EqualFold(sig.SignerName, child) || !sig.ValidityPeriod(now) {
+			continue
+		}
+		for _, k := range keys[sig.KeyTag] {
+			if k.Algorithm == sig.Algorithm && !slices.Contains(out, k) && sig.Verify(k, rrset.RRs) == nil {
+				out = append(out, k)
+			}
+		}
+	}
+	return out
+}
+
+func dsMatchesKey(ds *dns.DS, k *dns.DNSKEY) bool {
+	if ds.KeyTag != k.KeyTag() || ds.Algorithm != k.Algorithm {
+		return false
+	}
+	kds := k.ToDS(ds.DigestType)
+	return kds != nil && strings.EqualFold(kds.Digest, ds.Digest)
+}
+
+// cdsFingerprint identifies a CDS RRset independent of RR order and TTL.
+func cdsFingerprint(cds *core.RRset) string {
+	var rrs []string
+	for _, rr := range cds.RRs {
+		cp := dns.Copy(rr)
+		cp.Header().Ttl = 0
+		rrs = append(rrs, cp.String())
+	}
+	slices.Sort(rrs)
+	sum := sha256.Sum256([]byte(strings.Join(rrs, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// CdsObservation is the persisted run of identical CDS observations for one
+// child of one parent zone.
+type CdsObservation struct {
+	Parent      string
+	Child       string
+	Kind        string
+	Fingerprint string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Count       int
+}
+
+func loadCdsObservation(kdb *KeyDB, parent, child string) (*CdsObservation, error) {
+	o := CdsObservation{Parent: parent, Child: child}
+	var first, last sql.NullString
+	err := kdb.DB.QueryRow(`
+SELECT kind, fingerprint, first_seen, last_seen, count FROM CdsObservation
+WHERE parent = ? AND child = ?`, parent, child).Scan(&o.Kind, &o.Fingerprint, &first, &last, &o.Count)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loadCdsObservation: %w", err)
+	}
+	o.FirstSeen, _ = parseOptionalTime(first)
+	o.LastSeen, _ = parseOptionalTime(last)
+	return &o, nil
+}
+
+// noteCdsObservation records one sighting of the CDS RRset identified by
+// fingerprint. A different RRset (or kind) restarts the run; a repeat within
+// interval of the last counted observation does not count again.
+func noteCdsObservation(kdb *KeyDB, parent, child, kind, fingerprint string, interval time.Duration, now time.Time) (*CdsObservation, error) {
+	o, err := loadCdsObservation(kdb, parent, child)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case o == nil || o.Fingerprint != fingerprint || o.Kind != kind:
+		o = &CdsObservation{Parent: parent, Child: child, Kind: kind, Fingerprint: fingerprint, FirstSeen: now, LastSeen: now, Count: 1}
+	case now.Sub(o.LastSeen) >= interval:
+		o.LastSeen = now
+		o.Count++
+	default:
+		return o, nil
+	}
+	_, err = kdb.DB.Exec(`
+INSERT OR REPLACE INTO CdsObservation (parent, child, kind, fingerprint, first_seen, last_seen, count)
+VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		parent, child, kind, fingerprint, o.FirstSeen.UTC().Format(time.RFC3339), o.LastSeen.UTC().Format(time.RFC3339), o.Count)
+	if err != nil {
+		return nil, fmt.Errorf("noteCdsObservation: %w", err)
+	}
+	return o, nil
+}
+
+func clearCdsObservation(kdb *KeyDB, parent, child string) error {
+	if _, err := kdb.DB.Exec(`DELETE FROM CdsObservation WHERE parent = ? AND child = ?`, parent, child); err != nil {
+		return fmt.Errorf("clearCdsObservation: %w", err)
+	}
+	return nil
+}
+
+// recordCdsDecision journals a verdict against the child zone, with the
+// current DS RRset as the old image and the CDS RRset as the new one. A
+// verdict that repeats the last one journaled for the child (same verdict,
+// reason and CDS RRset) is not journaled again, so that a child stuck in a
+// deferral does not fill the journal one recheck at a time.
+func recordCdsDecision(kdb *KeyDB, c cdsCandidate, d CdsDecision) {
+	var oldImg, newImg []string
+	for _, rr := range c.CurrentDS {
+		oldImg = append(oldImg, rr.String())
+	}
+	for _, rr := range c.CDS.RRs {
+		newImg = append(newImg, rr.String())
+	}
+	action := "cds-" + d.Verdict
+	detail := fmt.Sprintf("parent %s: %s", c.Parent, d.Reason)
+	var lastAction, lastDetail, lastNew string
+	err := kdb.DB.QueryRow(`SELECT action, detail, new_data FROM AuditLog WHERE zone=? AND source=? ORDER BY id DESC LIMIT 1`,
+		dns.Fqdn(c.Child), AuditSourceCdsPolicy).Scan(&lastAction, &lastDetail, &lastNew)
+	if err == nil && lastAction == action && lastDetail == detail && lastNew == strings.Join(newImg, "\n") {
+		return
+	}
+	auditRecord(kdb, AuditEntry{
+		Actor:  AuditActor{Type: AuditActorEngine, Name: "cds-scanner"},
+		Source: AuditSourceCdsPolicy,
+		Zone:   c.Child,
+		Action: action,
+		Old:    oldImg,
+		New:    newImg,
+		Detail: detail,
+	})
+}
+
+// applyCdsPolicy is ProcessCDSNotify's decision step for a parent zone with a
+// CDS acceptance policy. Only an accepted change reaches response.DSAdds /
+// DSRemoves (and so OnDelegationChange); a deferred one is re-checked by the
+// scanner at the decision's RecheckAt. Every child nameserver must answer
+// the CDS (and, when validating, the DNSKEY) query: a scan that some of
+// them did not answer is deferred, as is one on which they disagree.
+func (scanner *Scanner) applyCdsPolicy(ctx context.Context, pol *CdsPolicy, tuple ScanTuple, parentZD *ZoneData, nsRRset *core.RRset, cds *nsAnswers, response *ScanTupleResponse, scanLog *log.Logger) {
+	child := tuple.Zone
+	kdb := parentZD.KeyDB
+	if kdb == nil {
+		response.Error = true
+		response.ErrorMsg = fmt.Sprintf("cds policy %s: parent zone %s has no keystore", pol.Name, parentZD.ZoneName)
+		return
+	}
+	cdsRRset := cds.RRset
+	if cdsRRset == nil {
+		cdsRRset = &core.RRset{Name: child, Class: dns.ClassINET, RRtype: dns.TypeCDS}
+	}
+
+	c := cdsCandidate{Parent: parentZD.ZoneName, Child: child, CDS: cdsRRset}
+	if tuple.CurrentData.DS != nil {
+		c.CurrentDS = tuple.CurrentData.DS.RRs
+	}
+	switch {
+	case cds.Answered < cds.Total:
+		c.Defer = fmt.Sprintf("no CDS answer from child nameserver(s) %s", strings.Join(cds.Missing, ", "))
+	case len(cdsRRset.RRs) == 0:
+		scanLog.Printf("applyCdsPolicy: %s: no CDS records found at child", child)
+		if err := clearCdsObservation(kdb, parentZD.ZoneName, child); err != nil {
+			scanLog.Printf("applyCdsPolicy: %s: %v", child, err)
+		}
+		scanner.endCdsRecheck(parentZD, child)
+		return
+	case !cds.InSync:
+		c.Defer = "child nameservers disagree on the CDS RRset"
+	case pol.Validate:
+		dnskey, err := scanner.queryAllNS(ctx, child, dns.TypeDNSKEY, nsRRset, scanner.ImrEngine, scanLog)
+		switch {
+		case err != nil:
+			c.Defer = fmt.Sprintf("error querying DNSKEY: %v", err)
+		case dnskey.Answered < dnskey.Total:
+			c.Defer = fmt.Sprintf("no DNSKEY answer from child nameserver(s) %s", strings.Join(dnskey.Missing, ", "))
+		case !dnskey.InSync:
+			c.Defer = "child nameservers disagree on the DNSKEY RRset"
+		default:
+			c.DNSKEY = dnskey.RRset
+		}
+	}
+	if c.Defer == "" && len(c.CurrentDS) == 0 && !cdsIsDeleteSentinel(cdsRRset) && pol.Rules[CdsKindBootstrapSignal].Accept {
+		sigCDS, err := scanner.queryCDSAtSignalingNames(ctx, child, nsRRset, cdsRRset, scanLog)
+		switch {
+		case err != nil:
+			c.SignalErr = err
+		case sigCDS == nil:
+			c.SignalErr = fmt.Errorf("no out-of-bailiwick nameserver to signal through")
+		default:
+			c.SignalVerified = true
+		}
+	}
+
+	d, err := pol.evaluate(kdb, c, time.Now(), scanLog)
+	if err != nil {
+		scanLog.Printf("applyCdsPolicy: %s: %v", child, err)
+		response.Error = true
+		response.ErrorMsg = fmt.Sprintf("cds policy %s: %v", pol.Name, err)
+		return
+	}
+	newData := CurrentScanData{CDS: cdsRRset}
+	if d.DS != nil {
+		newData.DS = &core.RRset{Name: child, RRtype: dns.TypeDS, RRs: d.DS}
+	}
+	response.NewData = newData.ToJSON()
+	if d.Verdict == "" {
+		scanLog.Printf("applyCdsPolicy: %s: DS unchanged", child)
+		scanner.endCdsRecheck(parentZD, child)
+		return
+	}
+
+	scanLog.Printf("applyCdsPolicy: %s: %s %s: %s", child, d.Kind, d.Verdict, d.Reason)
+	recordCdsDecision(kdb, c, d)
+	response.Decision = d.Verdict
+	response.DecisionReason = d.Reason
+	switch d.Verdict {
+	case CdsVerdictAccepted:
+		response.DataChanged = true
+		response.DSAdds = d.Adds
+		response.DSRemoves = d.Removes
+		scanner.endCdsRecheck(parentZD, child)
+	case CdsVerdictRejected:
+		scanner.endCdsRecheck(parentZD, child)
+	case CdsVerdictDeferred:
+		rule := pol.Rules[d.Kind]
+		scanner.scheduleCdsRecheck(parentZD, child, d.RecheckAt, cdsRecheckMaxAge(rule))
+	}
+}
+
+// maxCdsRecheckSlack is how much longer than its rule needs a run of
+// rechecks may go on before the scanner gives up on it.
+const maxCdsRecheckSlack = 24 * time.Hour
+
+// cdsRecheckMaxAge is how long the scanner keeps re-checking a child under
+// rule: long enough for all observations and the hold-down, plus slack for
+// nameservers that are briefly out of sync.
+func cdsRecheckMaxAge(rule CdsRule) time.Duration {
+	return rule.HoldDown + time.Duration(rule.Observations+1)*rule.Interval + maxCdsRecheckSlack
+}
+
+// cdsRecheck is a run of scheduled CDS rechecks of one child.
+type cdsRecheck struct {
+	timer *time.Timer // nil while the recheck scan is running
+	since time.Time   // when the run began
+	fired time.Time   // when the last recheck was queued
+}
+
+// scheduleCdsRecheck re-queues a CDS scan of child at the given time, as if
+// the child had sent another NOTIFY(CDS). A later schedule for the same child
+// replaces an earlier one and continues its run. A run that would go on for
+// longer than maxAge is given up: the child is left alone until its next
+// NOTIFY or the next sweep. Rechecks are not persisted: after a restart the
+// run continues with the child's next NOTIFY or the next sweep.
+func (scanner *Scanner) scheduleCdsRecheck(parentZD *ZoneData, child string, at time.Time, maxAge time.Duration) {
+	if scanner.RescanQ == nil {
+		return
+	}
+	key := parentZD.ZoneName + "|" + child
+	scanner.recheckMu.Lock()
+	defer scanner.recheckMu.Unlock()
+	if scanner.rechecks == nil {
+		scanner.rechecks = map[string]*cdsRecheck{}
+	}
+	rc, ok := scanner.rechecks[key]
+	// A run whose last recheck never came back (the scan failed before the
+	// policy step) is stale; start a new one.
+	if ok && rc.timer == nil && !rc.fired.IsZero() && time.Since(rc.fired) > maxCdsRecheckSlack {
+		ok = false
+	}
+	if !ok {
+		rc = &cdsRecheck{since: time.Now()}
+		scanner.rechecks[key] = rc
+	}
+	if rc.timer != nil {
+		rc.timer.Stop()
+		rc.timer = nil
+	}
+	if at.Sub(rc.since) > maxAge {
+		delete(scanner.rechecks, key)
+		lg.Warn("scheduleCdsRecheck: giving up on CDS rechecks, waiting for the next NOTIFY or sweep",
+			"parent", parentZD.ZoneName, "child", child, "since", rc.since, "maxage", maxAge)
+		return
+	}
+	rc.timer = time.AfterFunc(time.Until(at), func() {
+		scanner.recheckMu.Lock()
+		if scanner.rechecks[key] == rc {
+			rc.timer = nil
+			rc.fired = time.Now()
+		}
+		scanner.recheckMu.Unlock()
+		select {
+		case scanner.RescanQ <- ScanRequest{Cmd: "SCAN", ChildZone: child, RRtype: dns.TypeCDS, ZoneData: parentZD}:
+		default:
+			lg.Warn("scheduleCdsRecheck: scanner queue full, dropping CDS recheck", "parent", parentZD.ZoneName, "child", child)
+			scanner.endCdsRecheck(parentZD, child)
+		}
+	})
+}
+
+// endCdsRecheck ends the run of rechecks of child, if there is one.
+func (scanner *Scanner) endCdsRecheck(parentZD *ZoneData, child string) {
+	key := parentZD.ZoneName + "|" + child
+	scanner.recheckMu.Lock()
+	defer scanner.recheckMu.Unlock()
+	if rc, ok := scanner.rechecks[key]; ok {
+		if rc.timer != nil {
+			rc.timer.Stop()
+		}
+		delete(scanner.rechecks, key)
+	}
+}
@@ -0,0 +1,340 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package tdns
+
+import (
+	"crypto"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+const (
+	cdsTestParent = "parent.example."
+	cdsTestChild  = "child.parent.example."
+)
+
+type cdsTestKey struct {
+	key    *dns.DNSKEY
+	signer crypto.Signer
+}
+
+func newCdsTestKey(t *testing.T, flags uint16) cdsTestKey {
+	t.Helper()
+	k := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: cdsTestChild, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := k.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cdsTestKey{key: k, signer: priv.(crypto.Signer)}
+}
+
+func (k cdsTestKey) ds() *dns.DS {
+	return k.key.ToDS(dns.SHA256)
+}
+
+func (k cdsTestKey) cds() *dns.CDS {
+	c := &dns.CDS{DS: *k.ds()}
+	c.Hdr.Rrtype = dns.TypeCDS
+	return c
+}
+
+func (k cdsTestKey) sign(t *testing.T, rrset *core.RRset) {
+	t.Helper()
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrset.Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+		Algorithm:  k.key.Algorithm,
+		SignerName: cdsTestChild,
+		KeyTag:     k.key.KeyTag(),
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(30 * 24 * time.Hour).Unix()),
+	}
+	if err := sig.Sign(k.signer, rrset.RRs); err != nil {
+		t.Fatal(err)
+	}
+	rrset.RRSIGs = append(rrset.RRSIGs, sig)
+}
+
+// cdsTestChildZone returns the child's signed DNSKEY RRset {ksk, zsk} and a
+// CDS RRset for cdsKeys signed by the ZSK.
+func cdsTestChildZone(t *testing.T, ksk, zsk cdsTestKey, cdsKeys ...cdsTestKey) (dnskey, cds *core.RRset) {
+	t.Helper()
+	dnskey = &core.RRset{Name: cdsTestChild, RRtype: dns.TypeDNSKEY, RRs: []dns.RR{ksk.key, zsk.key}}
+	ksk.sign(t, dnskey)
+	cds = &core.RRset{Name: cdsTestChild, RRtype: dns.TypeCDS}
+	for _, k := range cdsKeys {
+		cds.RRs = append(cds.RRs, k.cds())
+	}
+	zsk.sign(t, cds)
+	return dnskey, cds
+}
+
+func cdsTestPolicy(t *testing.T, c CdsPolicyConf) *CdsPolicy {
+	t.Helper()
+	c.Name = "registry"
+	p, err := NewCdsPolicy(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+var cdsTestLog = log.New(io.Discard, "", 0)
+
+func TestCdsPolicyConfig(t *testing.T) {
+	p := cdsTestPolicy(t, CdsPolicyConf{})
+	if !p.Validate || p.MaxDS != 0 {
+		t.Errorf("defaults: validate %v max-ds %d", p.Validate, p.MaxDS)
+	}
+	for _, kind := range []string{CdsKindUpdate, CdsKindDelete, CdsKindBootstrapSignal} {
+		if r := p.Rules[kind]; !r.Accept || r.Observations != 1 || r.HoldDown != 0 {
+			t.Errorf("%s default rule %+v", kind, r)
+		}
+	}
+	if p.Rules[CdsKindBootstrapApex].Accept {
+		t.Errorf("unauthenticated bootstrap accepted by default")
+	}
+
+	p = cdsTestPolicy(t, CdsPolicyConf{BootstrapApex: CdsRuleConf{Action: "accept", Observations: 3, Interval: "1h", HoldDown: "72h"}})
+	if r := p.Rules[CdsKindBootstrapApex]; !r.Accept || r.Observations != 3 || r.Interval != time.Hour || r.HoldDown != 72*time.Hour {
+		t.Errorf("bootstrap-apex rule %+v", r)
+	}
+
+	for _, bad := range []CdsPolicyConf{
+		{Update: CdsRuleConf{Action: "maybe"}},
+		{Delete: CdsRuleConf{HoldDown: "a while"}},
+		{Update: CdsRuleConf{Observations: -1}},
+		{MaxDS: -1},
+	} {
+		if _, err := NewCdsPolicy(bad); err == nil {
+			t.Errorf("config %+v accepted", bad)
+		}
+	}
+}
+
+func TestValidateCdsChain(t *testing.T) {
+	ksk, zsk := newCdsTestKey(t, 257), newCdsTestKey(t, 256)
+	next := newCdsTestKey(t, 257)
+	now := time.Now()
+	dnskey, cds := cdsTestChildZone(t, ksk, zsk, ksk, next)
+
+	if err := validateCdsChain(cdsTestChild, cds, dnskey, []dns.RR{ksk.ds()}, true, now); err != nil {
+		t.Errorf("valid chain refused: %v", err)
+	}
+	if err := validateCdsChain(cdsTestChild, cds, dnskey, []dns.RR{next.ds()}, true, now); err == nil {
+		t.Errorf("DNSKEY RRset not anchored in the current DS accepted")
+	}
+	if err := validateCdsChain(cdsTestChild, cds, nil, nil, true, now); err == nil {
+		t.Errorf("missing DNSKEY RRset accepted")
+	}
+
+	unsigned := &core.RRset{Name: cdsTestChild, RRtype: dns.TypeCDS, RRs: cds.RRs}
+	if err := validateCdsChain(cdsTestChild, unsigned, dnskey, []dns.RR{ksk.ds()}, true, now); err == nil {
+		t.Errorf("unsigned CDS RRset accepted")
+	}
+
+	// A DS RRset without the key that signs the DNSKEY RRset would break
+	// the delegation, unless it is the delete sentinel.
+	_, orphan := cdsTestChildZone(t, ksk, zsk, next)
+	if err := validateCdsChain(cdsTestChild, orphan, dnskey, []dns.RR{ksk.ds()}, true, now); err == nil {
+		t.Errorf("CDS RRset without DS for the signing KSK accepted")
+	}
+	if err := validateCdsChain(cdsTestChild, orphan, dnskey, []dns.RR{ksk.ds()}, false, now); err != nil {
+		t.Errorf("key match enforced when not required: %v", err)
+	}
+}
+
+func TestCdsPolicyObservations(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	p := cdsTestPolicy(t, CdsPolicyConf{Update: CdsRuleConf{Observations: 3, Interval: "1h", HoldDown: "4h"}})
+	ksk, zsk := newCdsTestKey(t, 257), newCdsTestKey(t, 256)
+	next := newCdsTestKey(t, 257)
+	dnskey, cds := cdsTestChildZone(t, ksk, zsk, ksk, next)
+	c := cdsCandidate{Parent: cdsTestParent, Child: cdsTestChild, CDS: cds, DNSKEY: dnskey, CurrentDS: []dns.RR{ksk.ds()}}
+	t0 := time.Now().Truncate(time.Second)
+
+	step := func(at time.Duration, want string) CdsDecision {
+		t.Helper()
+		d, err := p.evaluate(kdb, c, t0.Add(at), cdsTestLog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Verdict != want || d.Kind != CdsKindUpdate {
+			t.Fatalf("at +%v: %s %s (%s), want %s", at, d.Kind, d.Verdict, d.Reason, want)
+		}
+		return d
+	}
+
+	d := step(0, CdsVerdictDeferred)
+	if !d.RecheckAt.Equal(t0.Add(time.Hour)) {
+		t.Errorf("first recheck at %v, want +1h", d.RecheckAt.Sub(t0))
+	}
+	step(10*time.Minute, CdsVerdictDeferred) // too soon: not an observation
+	step(time.Hour, CdsVerdictDeferred)
+	d = step(2*time.Hour, CdsVerdictDeferred)
+	if !strings.Contains(d.Reason, "observation 3 of 3") || !d.RecheckAt.Equal(t0.Add(4*time.Hour)) {
+		t.Errorf("hold-down: %q recheck +%v", d.Reason, d.RecheckAt.Sub(t0))
+	}
+	d = step(4*time.Hour, CdsVerdictAccepted)
+	if len(d.Adds) != 1 || d.Adds[0].(*dns.DS).KeyTag != next.key.KeyTag() || len(d.Removes) != 0 {
+		t.Errorf("accepted change: adds %v removes %v", d.Adds, d.Removes)
+	}
+	if o, _ := loadCdsObservation(kdb, cdsTestParent, cdsTestChild); o != nil {
+		t.Errorf("observation run kept after acceptance: %+v", o)
+	}
+
+	// A different CDS RRset restarts the run, and so does an inconsistent scan.
+	step(5*time.Hour, CdsVerdictDeferred)
+	step(6*time.Hour, CdsVerdictDeferred)
+	_, c.CDS = cdsTestChildZone(t, ksk, zsk, ksk)
+	c.CurrentDS = []dns.RR{ksk.ds(), next.ds()}
+	if d := step(7*time.Hour, CdsVerdictDeferred); !strings.Contains(d.Reason, "observation 1 of 3") {
+		t.Errorf("changed CDS did not restart the run: %s", d.Reason)
+	}
+	c.Defer = "child nameservers disagree on the CDS RRset"
+	step(8*time.Hour, CdsVerdictDeferred)
+	if o, _ := loadCdsObservation(kdb, cdsTestParent, cdsTestChild); o != nil {
+		t.Errorf("inconsistent scan counted: %+v", o)
+	}
+
+	// No change, no decision.
+	c.Defer = ""
+	c.CurrentDS = []dns.RR{ksk.ds()}
+	if d, _ := p.evaluate(kdb, c, t0.Add(9*time.Hour), cdsTestLog); d.Verdict != "" {
+		t.Errorf("unchanged DS RRset: verdict %s", d.Verdict)
+	}
+}
+
+func TestCdsPolicyKinds(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	p := cdsTestPolicy(t, CdsPolicyConf{MaxDS: 2})
+	ksk, zsk := newCdsTestKey(t, 257), newCdsTestKey(t, 256)
+	dnskey, cds := cdsTestChildZone(t, ksk, zsk, ksk)
+	now := time.Now()
+
+	eval := func(c cdsCandidate) CdsDecision {
+		t.Helper()
+		c.Parent, c.Child, c.DNSKEY = cdsTestParent, cdsTestChild, dnskey
+		d, err := p.evaluate(kdb, c, now, cdsTestLog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	// Insecure bootstrap: authenticated via _dsboot, or unauthenticated.
+	if d := eval(cdsCandidate{CDS: cds, SignalVerified: true}); d.Kind != CdsKindBootstrapSignal || d.Verdict != CdsVerdictAccepted {
+		t.Errorf("signaled bootstrap: %s %s (%s)", d.Kind, d.Verdict, d.Reason)
+	}
+	d := eval(cdsCandidate{CDS: cds, SignalErr: errors.New("no CDS at signaling name")})
+	if d.Kind != CdsKindBootstrapApex || d.Verdict != CdsVerdictRejected || !strings.Contains(d.Reason, "signaling") {
+		t.Errorf("apex bootstrap: %s %s (%s)", d.Kind, d.Verdict, d.Reason)
+	}
+
+	// Delete sentinel.
+	sentinel := &core.RRset{Name: cdsTestChild, RRtype: dns.TypeCDS, RRs: []dns.RR{&dns.CDS{DS: dns.DS{
+		Hdr: dns.RR_Header{Name: cdsTestChild, Rrtype: dns.TypeCDS, Class: dns.ClassINET, Ttl: 3600}, DigestType: 0, Digest: "00"}}}}
+	zsk.sign(t, sentinel)
+	d = eval(cdsCandidate{CDS: sentinel, CurrentDS: []dns.RR{ksk.ds()}})
+	if d.Kind != CdsKindDelete || d.Verdict != CdsVerdictAccepted || len(d.Removes) != 1 {
+		t.Errorf("delete sentinel: %s %s (%s) removes %d", d.Kind, d.Verdict, d.Reason, len(d.Removes))
+	}
+	if d := eval(cdsCandidate{CDS: sentinel}); d.Verdict != "" {
+		t.Errorf("delete sentinel without a delegation DS: verdict %s", d.Verdict)
+	}
+	mixed := &core.RRset{Name: cdsTestChild, RRtype: dns.TypeCDS, RRs: append([]dns.RR{sentinel.RRs[0]}, cds.RRs...)}
+	zsk.sign(t, mixed)
+	if d := eval(cdsCandidate{CDS: mixed, CurrentDS: []dns.RR{ksk.ds()}}); d.Verdict != CdsVerdictRejected || len(d.Removes) != 0 {
+		t.Errorf("delete sentinel mixed with a CDS: %s %s (%s)", d.Kind, d.Verdict, d.Reason)
+	}
+
+	// DS cap.
+	_, big := cdsTestChildZone(t, ksk, zsk, ksk, newCdsTestKey(t, 257), newCdsTestKey(t, 257))
+	c := cdsCandidate{Parent: cdsTestParent, Child: cdsTestChild, CDS: big, DNSKEY: dnskey, CurrentDS: []dns.RR{ksk.ds()}}
+	d = eval(c)
+	if d.Verdict != CdsVerdictRejected || !strings.Contains(d.Reason, "at most 2") {
+		t.Errorf("max-ds: %s (%s)", d.Verdict, d.Reason)
+	}
+
+	// Every verdict lands in the audit journal against the child.
+	recordCdsDecision(kdb, c, d)
+	entries, err := kdb.QueryAuditLog(AuditFilter{Zone: cdsTestChild})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Action != "cds-rejected" || entries[0].Source != AuditSourceCdsPolicy ||
+		len(entries[0].New) != 3 || !strings.Contains(entries[0].Detail, cdsTestParent) {
+		t.Errorf("audit entries %+v", entries)
+	}
+}
+
+func TestCdsPolicyNeedsEveryNameserver(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	ksk, zsk := newCdsTestKey(t, 257), newCdsTestKey(t, 256)
+	_, cds := cdsTestChildZone(t, ksk, zsk, ksk, newCdsTestKey(t, 257))
+	parentZD := &ZoneData{ZoneName: cdsTestParent, KeyDB: kdb}
+	tuple := ScanTuple{Zone: cdsTestChild, CurrentData: CurrentScanData{
+		DS: &core.RRset{Name: cdsTestChild, RRtype: dns.TypeDS, RRs: []dns.RR{ksk.ds()}}}}
+	scanner := &Scanner{RescanQ: make(chan ScanRequest, 1)}
+	p := cdsTestPolicy(t, CdsPolicyConf{})
+
+	// Two nameservers agree, the third did not answer.
+	answers := &nsAnswers{RRset: cds, InSync: true, Answered: 2, Total: 3, Missing: []string{"ns3.child.parent.example."}}
+	for range 2 {
+		var resp ScanTupleResponse
+		scanner.applyCdsPolicy(t.Context(), p, tuple, parentZD, nil, answers, &resp, cdsTestLog)
+		if resp.Decision != CdsVerdictDeferred || !strings.Contains(resp.DecisionReason, "ns3.") || resp.DataChanged {
+			t.Fatalf("partial answers: %s (%s)", resp.Decision, resp.DecisionReason)
+		}
+	}
+	entries, err := kdb.QueryAuditLog(AuditFilter{Zone: cdsTestChild})
+	if err != nil || len(entries) != 1 {
+		t.Errorf("repeated deferral journaled %d times (err %v), want once", len(entries), err)
+	}
+	if _, ok := scanner.rechecks[cdsTestParent+"|"+cdsTestChild]; !ok {
+		t.Error("no recheck scheduled for the deferred child")
+	}
+
+	// The child withdraws its CDS everywhere: the run of rechecks ends.
+	var resp ScanTupleResponse
+	scanner.applyCdsPolicy(t.Context(), p, tuple, parentZD, nil, &nsAnswers{
+		RRset: &core.RRset{Name: cdsTestChild}, InSync: true, Answered: 3, Total: 3}, &resp, cdsTestLog)
+	if resp.Decision != "" || resp.Error {
+		t.Errorf("no CDS: %s %s", resp.Decision, resp.ErrorMsg)
+	}
+	if _, ok := scanner.rechecks[cdsTestParent+"|"+cdsTestChild]; ok {
+		t.Error("recheck still scheduled after the CDS went away")
+	}
+}
+
+func TestCdsRecheckGivesUp(t *testing.T) {
+	scanner := &Scanner{RescanQ: make(chan ScanRequest, 1)}
+	parentZD := &ZoneData{ZoneName: cdsTestParent}
+	key := cdsTestParent + "|" + cdsTestChild
+	rule := CdsRule{Accept: true, Observations: 1, Interval: time.Hour}
+	maxAge := cdsRecheckMaxAge(rule)
+
+	scanner.scheduleCdsRecheck(parentZD, cdsTestChild, time.Now().Add(time.Hour), maxAge)
+	rc := scanner.rechecks[key]
+	if rc == nil || rc.timer == nil {
+		t.Fatal("recheck not scheduled")
+	}
+	// The run has been going on for longer than the rule can need.
+	rc.since = time.Now().Add(-maxAge)
+	scanner.scheduleCdsRecheck(parentZD, cdsTestChild, time.Now().Add(time.Hour), maxAge)
+	if _, ok := scanner.rechecks[key]; ok {
+		t.Error("endless run of rechecks not given up")
+	}
+}
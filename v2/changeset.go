@@ -0,0 +1,986 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ *
+ * Change sets: named, reviewable batches of zone content changes. A change
+ * set is created as a draft, previewed as a diff against the published
+ * snapshot, validated, approved, and then applied — immediately or at a
+ * scheduled time — through the working-set/publish machinery, so the whole
+ * set lands in a single serial. The RRsets it replaced are recorded at apply
+ * time, which makes a one-step rollback possible.
+ */
+
+package tdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// Change set lifecycle states.
+const (
+	ChangeSetDraft      = "draft"
+	ChangeSetApproved   = "approved"
+	ChangeSetApplying   = "applying"
+	ChangeSetApplied    = "applied"
+	ChangeSetRolledBack = "rolled-back"
+	ChangeSetFailed     = "failed"
+)
+
+// Change set operations. add merges RRs into the RRset; delete removes the
+// listed RRs, or the whole RRset when no RRs are given; replace makes the
+// RRset exactly the listed RRs.
+const (
+	ChangeSetOpAdd     = "add"
+	ChangeSetOpDelete  = "delete"
+	ChangeSetOpReplace = "replace"
+)
+
+// ChangeSetOp is one staged change. RRs are in presentation format; owner
+// names may be relative to the zone. Owner and Type are only required for a
+// whole-RRset delete; otherwise they are derived from (and checked against)
+// the RRs.
+type ChangeSetOp struct {
+	Op    string   `json:"op" yaml:"op"`
+	Owner string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Type  string   `json:"type,omitempty" yaml:"type,omitempty"`
+	RRs   []string `json:"rrs,omitempty" yaml:"rrs,omitempty"`
+}
+
+// ChangeSetRRset is an RRset image: the RRs of one (owner, type) in
+// presentation format. An empty RRs means the RRset does not exist.
+type ChangeSetRRset struct {
+	Owner string   `json:"owner"`
+	Type  string   `json:"type"`
+	RRs   []string `json:"rrs"`
+}
+
+// ChangeSet is a named batch of changes to one zone.
+type ChangeSet struct {
+	ID             int64            `json:"id"`
+	Zone           string           `json:"zone"`
+	Name           string           `json:"name"`
+	State          string           `json:"state"`
+	Comment        string           `json:"comment,omitempty"`
+	Ops            []ChangeSetOp    `json:"ops"`
+	CreatedBy      string           `json:"created_by,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	ApprovedBy     string           `json:"approved_by,omitempty"`
+	ApprovedAt     time.Time        `json:"approved_at,omitempty"`
+	ApprovedSerial uint32           `json:"approved_serial,omitempty"`
+	ApprovedDiff   []ChangeSetDiff  `json:"approved_diff,omitempty"`
+	ActivateAt     time.Time        `json:"activate_at,omitempty"`
+	AppliedAt      time.Time        `json:"applied_at,omitempty"`
+	AppliedSerial  uint32           `json:"applied_serial,omitempty"`
+	RolledBackAt   time.Time        `json:"rolled_back_at,omitempty"`
+	Before         []ChangeSetRRset `json:"before,omitempty"`
+	After          []ChangeSetRRset `json:"after,omitempty"`
+	Error          string           `json:"error,omitempty"`
+}
+
+// ChangeSetDiff is the effect of a change set on one RRset.
+type ChangeSetDiff struct {
+	Owner   string   `json:"owner"`
+	Type    string   `json:"type"`
+	Old     []string `json:"old"`
+	New     []string `json:"new"`
+	Removed []string `json:"removed,omitempty"`
+	Added   []string `json:"added,omitempty"`
+}
+
+// Validation issue severities. Errors block approval and application.
+const (
+	ChangeSetIssueError   = "error"
+	ChangeSetIssueWarning = "warning"
+	ChangeSetIssueInfo    = "info"
+)
+
+type ChangeSetIssue struct {
+	Severity string `json:"severity"`
+	Owner    string `json:"owner,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Msg      string `json:"msg"`
+}
+
+func changeSetHasErrors(issues []ChangeSetIssue) bool {
+	for _, is := range issues {
+		if is.Severity == ChangeSetIssueError {
+			return true
+		}
+	}
+	return false
+}
+
+// csRRsetChange is the computed effect of the ops on one RRset.
+type csRRsetChange struct {
+	owner    string
+	rrtype   uint16
+	old, new []dns.RR
+}
+
+// csOwnerLookup returns the (published or staged) owner data for a name, or nil.
+type csOwnerLookup func(owner string) *OwnerData
+
+// parseChangeSetRR parses one presentation-format RR relative to the zone.
+// An RR without a TTL gets defttl.
+func parseChangeSetRR(zone, s string, defttl uint32) (dns.RR, error) {
+	zp := dns.NewZoneParser(strings.NewReader(fmt.Sprintf("$TTL %d\n%s", defttl, s)), zone, "")
+	rr, ok := zp.Next()
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("bad RR %q: %v", s, err)
+	}
+	if !ok || rr == nil {
+		return nil, fmt.Errorf("bad RR %q: empty", s)
+	}
+	if rr.Header().Class != dns.ClassINET {
+		return nil, fmt.Errorf("bad RR %q: class must be IN", s)
+	}
+	return rr, nil
+}
+
+// normalizeChangeSetOps checks the ops for syntax and internal consistency
+// and returns them with owners and types in canonical (absolute, upper-case
+// mnemonic) form.
+func normalizeChangeSetOps(zone string, ops []ChangeSetOp) ([]ChangeSetOp, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("change set has no operations")
+	}
+	out := make([]ChangeSetOp, 0, len(ops))
+	for i, op := range ops {
+		op.Op = strings.ToLower(strings.TrimSpace(op.Op))
+		switch op.Op {
+		case ChangeSetOpAdd, ChangeSetOpDelete, ChangeSetOpReplace:
+		default:
+			return nil, fmt.Errorf("op %d: unknown operation %q (want add, delete or replace)", i+1, op.Op)
+		}
+		if op.Owner != "" {
+			op.Owner = dns.CanonicalName(v2OwnerName(op.Owner, zone))
+		}
+		var rrtype uint16
+		if op.Type != "" {
+			t, ok := dns.StringToType[strings.ToUpper(op.Type)]
+			if !ok {
+				return nil, fmt.Errorf("op %d: unknown RR type %q", i+1, op.Type)
+			}
+			rrtype = t
+		}
+		op.RRs = append([]string(nil), op.RRs...)
+		for j, s := range op.RRs {
+			rr, err := parseChangeSetRR(zone, s, 0)
+			if err != nil {
+				return nil, fmt.Errorf("op %d: %v", i+1, err)
+			}
+			name := dns.CanonicalName(rr.Header().Name)
+			switch {
+			case op.Owner == "":
+				op.Owner = name
+			case op.Owner != name:
+				return nil, fmt.Errorf("op %d: RR %q does not belong to owner %s", i+1, s, op.Owner)
+			}
+			switch {
+			case rrtype == 0:
+				rrtype = rr.Header().Rrtype
+			case rrtype != rr.Header().Rrtype:
+				return nil, fmt.Errorf("op %d: RR %q is not of type %s", i+1, s, dns.TypeToString[rrtype])
+			}
+			op.RRs[j] = rr.String()
+			if probe, _ := parseChangeSetRR(zone, s, 1); probe != nil && probe.Header().Ttl != rr.Header().Ttl {
+				// No TTL given: keep it that way, so it is resolved against
+				// the zone when the change set is applied.
+				if f := strings.SplitN(op.RRs[j], "\t", 3); len(f) == 3 {
+					op.RRs[j] = f[0] + "\t" + f[2]
+				}
+			}
+		}
+		if op.Owner == "" || rrtype == 0 {
+			return nil, fmt.Errorf("op %d: owner and type are required when no RRs are given", i+1)
+		}
+		if len(op.RRs) == 0 && op.Op != ChangeSetOpDelete {
+			return nil, fmt.Errorf("op %d: %s requires at least one RR", i+1, op.Op)
+		}
+		op.Type = dns.TypeToString[rrtype]
+		out = append(out, op)
+	}
+	return out, nil
+}
+
+// computeChangeSet applies the (normalized) ops, in order, to the RRsets
+// returned by lookup and returns the resulting change per touched RRset, in
+// order of first touch. RRSIGs are not part of the images.
+func computeChangeSet(zone string, ops []ChangeSetOp, lookup csOwnerLookup) ([]*csRRsetChange, error) {
+	type key struct {
+		owner  string
+		rrtype uint16
+	}
+	var order []*csRRsetChange
+	idx := map[key]*csRRsetChange{}
+
+	// RRs given without a TTL take the TTL of the RRset they change or, for
+	// a new RRset, that of the apex SOA.
+	zonettl := uint32(3600)
+	if od := lookup(zone); od != nil {
+		if soa := od.RRtypes.GetOnlyRRSet(dns.TypeSOA).RRs; len(soa) > 0 {
+			zonettl = soa[0].Header().Ttl
+		}
+	}
+
+	for i, op := range ops {
+		rrtype := dns.StringToType[op.Type]
+		k := key{op.Owner, rrtype}
+		ch := idx[k]
+		if ch == nil {
+			ch = &csRRsetChange{owner: op.Owner, rrtype: rrtype}
+			if od := lookup(op.Owner); od != nil {
+				if rs, ok := od.RRtypes.Get(rrtype); ok {
+					ch.old = append([]dns.RR(nil), rs.RRs...)
+				}
+			}
+			ch.new = append([]dns.RR(nil), ch.old...)
+			idx[k] = ch
+			order = append(order, ch)
+		}
+
+		defttl := zonettl
+		if len(ch.old) > 0 {
+			defttl = ch.old[0].Header().Ttl
+		}
+		var rrs []dns.RR
+		for _, s := range op.RRs {
+			rr, err := parseChangeSetRR(zone, s, defttl)
+			if err != nil {
+				return nil, fmt.Errorf("op %d: %v", i+1, err)
+			}
+			rrs = append(rrs, rr)
+		}
+
+		switch op.Op {
+		case ChangeSetOpReplace:
+			ch.new = nil
+			fallthrough
+		case ChangeSetOpAdd:
+			for _, rr := range rrs {
+				if j := csIndexOf(ch.new, rr); j >= 0 {
+					ch.new[j] = rr // same data, possibly a new TTL
+				} else {
+					ch.new = append(ch.new, rr)
+				}
+			}
+		case ChangeSetOpDelete:
+			if len(rrs) == 0 {
+				ch.new = nil
+				continue
+			}
+			for _, rr := range rrs {
+				if j := csIndexOf(ch.new, rr); j >= 0 {
+					ch.new = append(ch.new[:j:j], ch.new[j+1:]...)
+				}
+			}
+		}
+	}
+	// An RRset has a single TTL (RFC 2181 §5.2): the last one given wins.
+	for _, ch := range order {
+		if len(ch.new) > 1 {
+			ttl := ch.new[len(ch.new)-1].Header().Ttl
+			for j, rr := range ch.new {
+				if rr.Header().Ttl != ttl {
+					c := dns.Copy(rr)
+					c.Header().Ttl = ttl
+					ch.new[j] = c
+				}
+			}
+		}
+	}
+	return order, nil
+}
+
+func csIndexOf(rrs []dns.RR, rr dns.RR) int {
+	for i, r := range rrs {
+		if dns.IsDuplicate(r, rr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func csStrings(rrs []dns.RR) []string {
+	out := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		out = append(out, rr.String())
+	}
+	return out
+}
+
+func (ch *csRRsetChange) noop() bool {
+	if len(ch.old) != len(ch.new) {
+		return false
+	}
+	old := csStrings(ch.old)
+	nu := csStrings(ch.new)
+	sort.Strings(old)
+	sort.Strings(nu)
+	for i := range old {
+		if old[i] != nu[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (ch *csRRsetChange) diff() ChangeSetDiff {
+	d := ChangeSetDiff{Owner: ch.owner, Type: dns.TypeToString[ch.rrtype], Old: csStrings(ch.old), New: csStrings(ch.new)}
+	oldSet := map[string]bool{}
+	for _, s := range d.Old {
+		oldSet[s] = true
+	}
+	newSet := map[string]bool{}
+	for _, s := range d.New {
+		newSet[s] = true
+		if !oldSet[s] {
+			d.Added = append(d.Added, s)
+		}
+	}
+	for _, s := range d.Old {
+		if !newSet[s] {
+			d.Removed = append(d.Removed, s)
+		}
+	}
+	return d
+}
+
+func changeSetDiffs(changes []*csRRsetChange) []ChangeSetDiff {
+	out := make([]ChangeSetDiff, 0, len(changes))
+	for _, ch := range changes {
+		out = append(out, ch.diff())
+	}
+	return out
+}
+
+// csView is the zone as it would look after the change set.
+type csView struct {
+	lookup  csOwnerLookup
+	changes map[string]map[uint16]*csRRsetChange
+}
+
+func newCSView(lookup csOwnerLookup, changes []*csRRsetChange) *csView {
+	v := &csView{lookup: lookup, changes: map[string]map[uint16]*csRRsetChange{}}
+	for _, ch := range changes {
+		if v.changes[ch.owner] == nil {
+			v.changes[ch.owner] = map[uint16]*csRRsetChange{}
+		}
+		v.changes[ch.owner][ch.rrtype] = ch
+	}
+	return v
+}
+
+func (v *csView) rrset(owner string, rrtype uint16) []dns.RR {
+	if ch, ok := v.changes[owner][rrtype]; ok {
+		return ch.new
+	}
+	if od := v.lookup(owner); od != nil {
+		return od.RRtypes.GetOnlyRRSet(rrtype).RRs
+	}
+	return nil
+}
+
+// types returns the RR types present at owner after the change.
+func (v *csView) types(owner string) []uint16 {
+	seen := map[uint16]bool{}
+	if od := v.lookup(owner); od != nil {
+		for _, t := range od.RRtypes.Keys() {
+			if len(od.RRtypes.GetOnlyRRSet(t).RRs) > 0 {
+				seen[t] = true
+			}
+		}
+	}
+	for t, ch := range v.changes[owner] {
+		seen[t] = len(ch.new) > 0
+	}
+	var out []uint16
+	for t, present := range seen {
+		if present {
+			out = append(out, t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// cut returns the nearest delegation point strictly above owner (and below
+// the apex), or "" if owner is not below a delegation.
+func (v *csView) cut(zone, owner string) string {
+	labels := dns.SplitDomainName(owner)
+	for i := 1; i < len(labels); i++ {
+		anc := dns.Fqdn(strings.Join(labels[i:], "."))
+		if anc == zone || !dns.IsSubDomain(zone, anc) {
+			break
+		}
+		if len(v.rrset(anc, dns.TypeNS)) > 0 {
+			return anc
+		}
+	}
+	return ""
+}
+
+// csSignerManaged are the types the signer and key engines own.
+var csSignerManaged = map[uint16]bool{
+	dns.TypeDNSKEY: true, dns.TypeCDS: true, dns.TypeCDNSKEY: true,
+	dns.TypeNSEC3PARAM: true,
+}
+
+// csAlwaysManaged may never be edited: RRSIG and NSEC(3) are generated, the
+// SOA serial is owned by the publish machinery.
+var csAlwaysManaged = map[uint16]bool{
+	dns.TypeRRSIG: true, dns.TypeNSEC: true, dns.TypeNSEC3: true, dns.TypeSOA: true,
+}
+
+func zoneIsSigned(zd *ZoneData) bool {
+	return zd.Options[OptOnlineSigning] || zd.Options[OptInlineSigning]
+}
+
+// validateChangeSet checks the computed changes against the zone as it
+// would look afterwards.
+func validateChangeSet(zd *ZoneData, changes []*csRRsetChange, lookup csOwnerLookup) []ChangeSetIssue {
+	zone := zd.ZoneName
+	signed := zoneIsSigned(zd)
+	v := newCSView(lookup, changes)
+	var issues []ChangeSetIssue
+	add := func(sev, owner string, rrtype uint16, format string, args ...interface{}) {
+		is := ChangeSetIssue{Severity: sev, Owner: owner, Msg: fmt.Sprintf(format, args...)}
+		if rrtype != 0 {
+			is.Type = dns.TypeToString[rrtype]
+		}
+		issues = append(issues, is)
+	}
+
+	resign := 0
+	ownersChanged := false
+	checkedOwners := map[string]bool{}
+	for _, ch := range changes {
+		owner, t := ch.owner, ch.rrtype
+		if !dns.IsSubDomain(zone, owner) {
+			add(ChangeSetIssueError, owner, t, "owner is outside zone %s", zone)
+			continue
+		}
+		if csAlwaysManaged[t] {
+			add(ChangeSetIssueError, owner, t, "%s records are managed by the server and cannot be changed", dns.TypeToString[t])
+			continue
+		}
+		if signed && csSignerManaged[t] {
+			add(ChangeSetIssueError, owner, t, "%s records in a signed zone are managed by the signer", dns.TypeToString[t])
+			continue
+		}
+		if ch.noop() {
+			add(ChangeSetIssueWarning, owner, t, "change has no effect on the published RRset")
+			continue
+		}
+		if len(ch.new) > 0 {
+			resign++
+		}
+		if (len(ch.old) == 0) != (len(ch.new) == 0) {
+			ownersChanged = true
+		}
+
+		if t == dns.TypeCNAME && len(ch.new) > 1 {
+			add(ChangeSetIssueError, owner, t, "an owner can have only one CNAME record (has %d)", len(ch.new))
+		}
+		if owner == zone {
+			if t == dns.TypeCNAME && len(ch.new) > 0 {
+				add(ChangeSetIssueError, owner, t, "CNAME not allowed at the zone apex")
+			}
+			if t == dns.TypeNS && len(ch.new) == 0 {
+				add(ChangeSetIssueError, owner, t, "change set removes the apex NS RRset")
+			}
+		}
+		if t == dns.TypeDS && len(ch.new) > 0 && len(v.rrset(owner, dns.TypeNS)) == 0 {
+			add(ChangeSetIssueError, owner, t, "DS records belong only at a delegation point")
+		}
+
+		if !checkedOwners[owner] {
+			checkedOwners[owner] = true
+			types := v.types(owner)
+			hasCNAME := false
+			var others []string
+			for _, tt := range types {
+				switch tt {
+				case dns.TypeCNAME:
+					hasCNAME = true
+				case dns.TypeRRSIG, dns.TypeNSEC:
+				default:
+					others = append(others, dns.TypeToString[tt])
+				}
+			}
+			if hasCNAME && len(others) > 0 {
+				add(ChangeSetIssueError, owner, dns.TypeCNAME, "CNAME cannot coexist with other data (%s)", strings.Join(others, ", "))
+			}
+		}
+
+		// Delegations and glue.
+		if cut := v.cut(zone, owner); cut != "" && len(ch.new) > 0 {
+			if t != dns.TypeA && t != dns.TypeAAAA {
+				add(ChangeSetIssueError, owner, t, "data is occluded by the delegation at %s", cut)
+			} else if !csNSTargets(v.rrset(cut, dns.TypeNS))[owner] {
+				add(ChangeSetIssueWarning, owner, t, "address record below delegation %s is not referenced by its NS RRset (orphaned glue)", cut)
+			}
+		}
+		if t == dns.TypeNS && len(ch.new) > 0 {
+			for target := range csNSTargets(ch.new) {
+				if !dns.IsSubDomain(zone, target) {
+					continue
+				}
+				if len(v.rrset(target, dns.TypeA)) == 0 && len(v.rrset(target, dns.TypeAAAA)) == 0 {
+					add(ChangeSetIssueWarning, owner, t, "in-bailiwick NS target %s has no address records (missing glue)", target)
+				}
+			}
+			if owner != zone {
+				for _, tt := range v.types(owner) {
+					switch tt {
+					case dns.TypeNS, dns.TypeDS, dns.TypeRRSIG, dns.TypeNSEC:
+					default:
+						add(ChangeSetIssueWarning, owner, t, "%s data at %s will be occluded by the new delegation", dns.TypeToString[tt], owner)
+					}
+				}
+			}
+		}
+	}
+
+	if signed {
+		if resign > 0 {
+			add(ChangeSetIssueInfo, "", 0, "zone is signed: %d RRset(s) will be signed with the active keys", resign)
+		}
+		if ownersChanged && len(v.rrset(zone, dns.TypeNSEC)) > 0 {
+			add(ChangeSetIssueInfo, "", 0, "zone is signed: the NSEC chain will be regenerated")
+		}
+	}
+	return issues
+}
+
+func csNSTargets(rrs []dns.RR) map[string]bool {
+	out := map[string]bool{}
+	for _, rr := range rrs {
+		if ns, ok := rr.(*dns.NS); ok {
+			out[dns.CanonicalName(ns.Ns)] = true
+		}
+	}
+	return out
+}
+
+// publishedLookup reads owners from the zone's published snapshot.
+func (zd *ZoneData) publishedLookup() csOwnerLookup {
+	snap := zd.publishedSnapshot()
+	return func(owner string) *OwnerData {
+		if snap == nil {
+			return nil
+		}
+		return snap.Data[owner]
+	}
+}
+
+// PreviewChangeSet computes the diff and validation result of ops against
+// the published snapshot without changing anything.
+func (zd *ZoneData) PreviewChangeSet(ops []ChangeSetOp) ([]ChangeSetDiff, []ChangeSetIssue, error) {
+	lookup := zd.publishedLookup()
+	changes, err := computeChangeSet(zd.ZoneName, ops, lookup)
+	if err != nil {
+		return nil, nil, err
+	}
+	return changeSetDiffs(changes), validateChangeSet(zd, changes, lookup), nil
+}
+
+// changeSetApplyResult is what an apply or rollback did: the images of the
+// touched RRsets before and after, and the serial it was published in.
+type changeSetApplyResult struct {
+	Before, After []ChangeSetRRset
+	Serial        uint32
+	Issues        []ChangeSetIssue
+	delegation    bool
+}
+
+func csImage(owner string, rrtype uint16, rrs []dns.RR) ChangeSetRRset {
+	return ChangeSetRRset{Owner: owner, Type: dns.TypeToString[rrtype], RRs: csStrings(rrs)}
+}
+
+// ApplyChangeSet applies ops to the zone in one publish. The changes are
+// recomputed and revalidated against the working set under zd.mu, so the
+// result is exactly what was checked; any validation error aborts without
+// touching the zone.
+func (zd *ZoneData) ApplyChangeSet(ops []ChangeSetOp) (changeSetApplyResult, error) {
+	return zd.applyChangeSetOps(func(lookup csOwnerLookup) ([]*csRRsetChange, error) {
+		return computeChangeSet(zd.ZoneName, ops, lookup)
+	}, true)
+}
+
+// applyApprovedChangeSet applies cs like ApplyChangeSet, but only if the
+// recomputed diff is the one that was reviewed. The diff carries the old
+// contents of every RRset cs touches, so a change to any of them since the
+// approval fails with errChangeSetStale and the change set has to be
+// approved again. Serial bumps elsewhere in the zone (re-signing, key
+// rollovers, unrelated updates) do not matter.
+func (zd *ZoneData) applyApprovedChangeSet(cs *ChangeSet) (changeSetApplyResult, error) {
+	return zd.applyChangeSetOps(func(lookup csOwnerLookup) ([]*csRRsetChange, error) {
+		changes, err := computeChangeSet(zd.ZoneName, cs.Ops, lookup)
+		if err != nil {
+			return nil, err
+		}
+		if !csSameDiffs(changeSetDiffs(changes), cs.ApprovedDiff) {
+			return nil, fmt.Errorf("%w: the changes to zone %s are no longer the ones approved for change set %q",
+				errChangeSetStale, zd.ZoneName, cs.Name)
+		}
+		return changes, nil
+	}, true)
+}
+
+var errChangeSetStale = errors.New("change set needs a new approval")
+
+// csSameDiffs reports whether two diffs change the same RRsets from the same
+// old to the same new contents, ignoring order.
+func csSameDiffs(a, b []ChangeSetDiff) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	idx := map[string]ChangeSetDiff{}
+	for _, d := range b {
+		idx[d.Owner+" "+d.Type] = d
+	}
+	for _, d := range a {
+		o, ok := idx[d.Owner+" "+d.Type]
+		if !ok || !csSameStrings(d.Old, o.Old) || !csSameStrings(d.New, o.New) {
+			return false
+		}
+	}
+	return true
+}
+
+// RollbackChangeSet restores the RRsets recorded in cs.Before. Unless force
+// is set it refuses when any of them has changed since the change set was
+// applied (cs.After), since the rollback would silently undo that later
+// change too.
+func (zd *ZoneData) RollbackChangeSet(cs *ChangeSet, force bool) (changeSetApplyResult, error) {
+	return zd.applyChangeSetOps(func(lookup csOwnerLookup) ([]*csRRsetChange, error) {
+		after := map[string][]string{}
+		for _, img := range cs.After {
+			after[img.Owner+" "+img.Type] = img.RRs
+		}
+		var changes []*csRRsetChange
+		for _, img := range cs.Before {
+			rrtype := dns.StringToType[img.Type]
+			ch := &csRRsetChange{owner: img.Owner, rrtype: rrtype}
+			if od := lookup(img.Owner); od != nil {
+				ch.old = append([]dns.RR(nil), od.RRtypes.GetOnlyRRSet(rrtype).RRs...)
+			}
+			if !force && !csSameStrings(csStrings(ch.old), after[img.Owner+" "+img.Type]) {
+				return nil, fmt.Errorf("%s %s has changed since change set %q was applied; use force to roll back anyway",
+					img.Owner, img.Type, cs.Name)
+			}
+			for _, s := range img.RRs {
+				rr, err := dns.NewRR(s)
+				if err != nil {
+					return nil, fmt.Errorf("bad recorded RR %q: %v", s, err)
+				}
+				ch.new = append(ch.new, rr)
+			}
+			changes = append(changes, ch)
+		}
+		return changes, nil
+	}, false)
+}
+
+func csSameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (zd *ZoneData) applyChangeSetOps(compute func(csOwnerLookup) ([]*csRRsetChange, error), validate bool) (changeSetApplyResult, error) {
+	var res changeSetApplyResult
+	if zd.ZoneType != Primary || !zoneMayOriginateContent(zd) {
+		return res, fmt.Errorf("zone %s is not a primary zone originating its own content", zd.ZoneName)
+	}
+	if zd.Options[OptFrozen] {
+		return res, fmt.Errorf("zone %s is frozen", zd.ZoneName)
+	}
+	if zd.publishedSnapshot() == nil {
+		return res, fmt.Errorf("zone %s is not loaded", zd.ZoneName)
+	}
+
+	signed := zoneIsSigned(zd)
+	var dak *DnssecKeys
+	if signed {
+		// Resolve keys before taking zd.mu: EnsureActiveDnssecKeys may publish
+		// DNSKEYs, which re-locks zd.mu (see ApplyZoneUpdateToZoneData).
+		var err error
+		if dak, err = zd.EnsureActiveDnssecKeys(zd.KeyDB, false); err != nil {
+			return res, fmt.Errorf("zone %s: no active DNSSEC keys: %v", zd.ZoneName, err)
+		}
+	}
+
+	zd.mu.Lock()
+	defer zd.mu.Unlock()
+	rollback := zd.stagedCheckpointLocked()
+	abort := func(err error) (changeSetApplyResult, error) {
+		rollback()
+		return res, err
+	}
+	lookup := func(owner string) *OwnerData { return zd.stagedOwner(owner) }
+
+	changes, err := compute(lookup)
+	if err != nil {
+		return abort(err)
+	}
+	if validate {
+		res.Issues = validateChangeSet(zd, changes, lookup)
+		if changeSetHasErrors(res.Issues) {
+			return abort(fmt.Errorf("change set does not validate against the current zone"))
+		}
+	}
+
+	ownersChanged := false
+	for _, ch := range changes {
+		res.Before = append(res.Before, csImage(ch.owner, ch.rrtype, ch.old))
+		res.After = append(res.After, csImage(ch.owner, ch.rrtype, ch.new))
+		if ch.noop() {
+			continue
+		}
+		switch ch.rrtype {
+		case dns.TypeNS:
+			res.delegation = res.delegation || ch.owner == zd.ZoneName
+		case dns.TypeA, dns.TypeAAAA:
+			if apex := zd.stagedOwner(zd.ZoneName); apex != nil &&
+				csNSTargets(apex.RRtypes.GetOnlyRRSet(dns.TypeNS).RRs)[ch.owner] {
+				res.delegation = true
+			}
+		}
+		if len(ch.new) == 0 {
+			zd.stageDeleteLocked(ch.owner, ch.rrtype)
+			if od := zd.stagedOwner(ch.owner); od != nil && od.RRtypes.Count() == 0 && ch.owner != zd.ZoneName {
+				zd.stageOwnerDeleteLocked(ch.owner)
+				ownersChanged = true
+			}
+			continue
+		}
+		if zd.stagedOwner(ch.owner) == nil {
+			ownersChanged = true
+		}
+		rs := core.RRset{Name: ch.owner, RRtype: ch.rrtype, RRs: ch.new}
+		if signed {
+			// Never publish an unsigned or stale-signed RRset in a signed
+			// zone: the whole change set fails instead.
+			if _, err := zd.SignRRset(&rs, ch.owner, dak, true, nil); err != nil {
+				lgSigner.Error("changeset: signing failed", "zone", zd.ZoneName, "owner", ch.owner,
+					"rrtype", dns.TypeToString[ch.rrtype], "err", err)
+				return abort(fmt.Errorf("signing %s %s failed: %v", ch.owner, dns.TypeToString[ch.rrtype], err))
+			}
+		}
+		zd.stageRRsetLocked(ch.owner, rs)
+	}
+
+	if signed && ownersChanged {
+		if apex := zd.stagedOwner(zd.ZoneName); apex != nil && len(apex.RRtypes.GetOnlyRRSet(dns.TypeNSEC).RRs) > 0 {
+			if err := zd.regenerateNsecChainLocked(dak); err != nil {
+				return abort(err)
+			}
+		}
+	}
+
+	touched := make([]string, 0, len(changes))
+	for _, ch := range changes {
+		touched = append(touched, ch.owner)
+	}
+	if err := zd.lintStagedLocked("change set", touched); err != nil {
+		return abort(err)
+	}
+
+	zd.publishLocked(zd.generation.Load())
+	res.Serial = zd.CurrentSerial
+	return res, nil
+}
+
+// regenerateNsecChainLocked rebuilds the NSEC chain and re-signs the NSEC
+// RRsets whose content changed. Caller holds zd.mu and discards the working
+// set on error.
+func (zd *ZoneData) regenerateNsecChainLocked(dak *DnssecKeys) error {
+	before := map[string]string{}
+	for _, name := range zd.workingOwnerNamesLocked() {
+		if od := zd.stagedOwner(name); od != nil {
+			if rs := od.RRtypes.GetOnlyRRSet(dns.TypeNSEC); len(rs.RRs) > 0 {
+				before[name] = rs.RRs[0].String()
+			}
+		}
+	}
+	if err := zd.GenerateNsecChainWithDak(dak); err != nil {
+		lgSigner.Error("changeset: NSEC chain regeneration failed", "zone", zd.ZoneName, "err", err)
+		return fmt.Errorf("NSEC chain regeneration failed: %v", err)
+	}
+	for _, name := range zd.workingOwnerNamesLocked() {
+		od := zd.stagedOwner(name)
+		if od == nil {
+			continue
+		}
+		rs := od.RRtypes.GetOnlyRRSet(dns.TypeNSEC)
+		if len(rs.RRs) == 0 || before[name] == rs.RRs[0].String() {
+			continue
+		}
+		rs = cloneRRset(rs)
+		rs.RRSIGs = nil
+		if _, err := zd.SignRRset(&rs, name, dak, true, nil); err != nil {
+			lgSigner.Error("changeset: signing NSEC failed", "zone", zd.ZoneName, "owner", name, "err", err)
+			return fmt.Errorf("signing %s NSEC failed: %v", name, err)
+		}
+		zd.stageRRsetLocked(name, rs)
+	}
+	return nil
+}
+
+// ActivateChangeSet applies a stored, approved change set and records the
+// outcome (state, images, serial or error) in the keystore and audit log.
+// The change set is first moved from approved to applying in the keystore,
+// so that of several callers (the API and the scheduler) only one applies
+// it. If the RRsets it touches have changed since the approval the change
+// set fails and must be approved again.
+func (kdb *KeyDB) ActivateChangeSet(cs *ChangeSet, actor AuditActor) (changeSetApplyResult, error) {
+	if cs.State != ChangeSetApproved {
+		return changeSetApplyResult{}, fmt.Errorf("change set %q is %s; only an approved change set can be applied", cs.Name, cs.State)
+	}
+	zd, ok := Zones.Get(cs.Zone)
+	if !ok {
+		return changeSetApplyResult{}, fmt.Errorf("zone %s is unknown", cs.Zone)
+	}
+	if err := kdb.ClaimChangeSet(cs, ChangeSetApproved); err != nil {
+		return changeSetApplyResult{}, err
+	}
+	res, err := zd.applyApprovedChangeSet(cs)
+	if err != nil {
+		cs.State = ChangeSetFailed
+		cs.Error = err.Error()
+		if serr := kdb.SaveChangeSet(cs); serr != nil {
+			lg.Error("changeset: failed to record failure", "zone", cs.Zone, "changeset", cs.Name, "err", serr)
+		}
+		return res, err
+	}
+	cs.State = ChangeSetApplied
+	cs.Error = ""
+	cs.AppliedAt = time.Now().UTC()
+	cs.AppliedSerial = res.Serial
+	cs.Before, cs.After = res.Before, res.After
+	if err := kdb.SaveChangeSet(cs); err != nil {
+		lg.Error("changeset: applied but failed to record state", "zone", cs.Zone, "changeset", cs.Name, "err", err)
+	}
+	zd.changeSetPublished(kdb, cs, actor, "changeset-apply", res)
+	lg.Info("changeset: applied", "zone", cs.Zone, "changeset", cs.Name, "serial", res.Serial, "rrsets", len(res.After))
+	return res, nil
+}
+
+// RevertChangeSet rolls back an applied change set. Like ActivateChangeSet
+// it first claims the change set, so a rollback cannot race an apply or
+// another rollback of the same change set. A refused rollback leaves it
+// applied.
+func (kdb *KeyDB) RevertChangeSet(cs *ChangeSet, force bool, actor AuditActor) (changeSetApplyResult, error) {
+	if cs.State != ChangeSetApplied {
+		return changeSetApplyResult{}, fmt.Errorf("change set %q is %s; only an applied change set can be rolled back", cs.Name, cs.State)
+	}
+	zd, ok := Zones.Get(cs.Zone)
+	if !ok {
+		return changeSetApplyResult{}, fmt.Errorf("zone %s is unknown", cs.Zone)
+	}
+	if err := kdb.ClaimChangeSet(cs, ChangeSetApplied); err != nil {
+		return changeSetApplyResult{}, err
+	}
+	res, err := zd.RollbackChangeSet(cs, force)
+	if err != nil {
+		cs.State = ChangeSetApplied
+		if serr := kdb.SaveChangeSet(cs); serr != nil {
+			lg.Error("changeset: failed to release refused rollback", "zone", cs.Zone, "changeset", cs.Name, "err", serr)
+		}
+		return res, err
+	}
+	cs.State = ChangeSetRolledBack
+	cs.RolledBackAt = time.Now().UTC()
+	if err := kdb.SaveChangeSet(cs); err != nil {
+		lg.Error("changeset: rolled back but failed to record state", "zone", cs.Zone, "changeset", cs.Name, "err", err)
+	}
+	zd.changeSetPublished(kdb, cs, actor, "changeset-rollback", res)
+	lg.Info("changeset: rolled back", "zone", cs.Zone, "changeset", cs.Name, "serial", res.Serial)
+	return res, nil
+}
+
+// changeSetPublished is the post-publish bookkeeping shared by apply and
+// rollback: dirty flag, persistence, delegation sync and the audit record.
+func (zd *ZoneData) changeSetPublished(kdb *KeyDB, cs *ChangeSet, actor AuditActor, action string, res changeSetApplyResult) {
+	zd.SetOption(OptDirty, true)
+	zd.persistApiManagedPrimary(false)
+	if res.delegation && zd.Options[OptDelSyncChild] && zd.DelegationSyncQ != nil {
+		select {
+		case zd.DelegationSyncQ <- DelegationSyncRequest{Command: "EXPLICIT-SYNC-DELEGATION", ZoneName: zd.ZoneName, ZoneData: zd}:
+		default:
+			lg.Warn("changeset: delegation sync queue full, not requesting sync", "zone", zd.ZoneName)
+		}
+	}
+	var before, after []string
+	for _, img := range res.Before {
+		before = append(before, img.RRs...)
+	}
+	for _, img := range res.After {
+		after = append(after, img.RRs...)
+	}
+	auditRecord(kdb, AuditEntry{
+		Actor:  actor,
+		Source: AuditSourceChangeSet,
+		Zone:   zd.ZoneName,
+		Action: action,
+		Old:    before,
+		New:    after,
+		Serial: res.Serial,
+		Detail: cs.Name,
+	})
+}
+
+const changeSetSchedulerInterval = 15 * time.Second
+
+// ChangeSetScheduler applies approved change sets when their activation time
+// has come. A change set that fails to apply is marked failed and not
+// retried; it needs a fresh approval.
+func ChangeSetScheduler(ctx context.Context, conf *Config) error {
+	kdb := conf.Internal.KeyDB
+	if kdb == nil {
+		return fmt.Errorf("ChangeSetScheduler: keystore not initialized")
+	}
+	ticker := time.NewTicker(changeSetSchedulerInterval)
+	defer ticker.Stop()
+	actor := AuditActor{Type: AuditActorEngine, Name: "changeset-scheduler"}
+	for {
+		select {
+		case <-ctx.Done():
+			lg.Info("ChangeSetScheduler: terminating")
+			return nil
+		case <-ticker.C:
+		}
+		due, err := kdb.DueChangeSets(time.Now())
+		if err != nil {
+			lg.Error("ChangeSetScheduler: failed to list due change sets", "err", err)
+			continue
+		}
+		for i := range due {
+			cs := &due[i]
+			if _, err := kdb.ActivateChangeSet(cs, actor); errors.Is(err, errChangeSetClaimed) {
+				lg.Debug("ChangeSetScheduler: change set already being applied", "zone", cs.Zone, "changeset", cs.Name)
+			} else if err != nil {
+				lg.Error("ChangeSetScheduler: scheduled change set failed", "zone", cs.Zone, "changeset", cs.Name, "err", err)
+			}
+		}
+	}
+}
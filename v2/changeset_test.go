@@ -0,0 +1,463 @@
+package tdns
+
+import (
+	"crypto"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+const changeSetTestZone = `cs.example.		3600	IN	SOA	ns1.cs.example. hostmaster.cs.example. 100 7200 1800 604800 7200
+cs.example.		3600	IN	NS	ns1.cs.example.
+ns1.cs.example.		3600	IN	A	192.0.2.53
+www.cs.example.		3600	IN	A	192.0.2.1
+mail.cs.example.	3600	IN	MX	10 mx.cs.example.
+`
+
+func changeSetTestZoneData(t *testing.T) *ZoneData {
+	t.Helper()
+	zd := testZone(t, "cs.example.", changeSetTestZone)
+	zd.ZoneType = Primary
+	zd.Options = map[ZoneOption]bool{}
+	registerZones(t, zd)
+	return zd
+}
+
+func csFindIssue(issues []ChangeSetIssue, sev, substr string) bool {
+	for _, is := range issues {
+		if is.Severity == sev && strings.Contains(is.Msg, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestChangeSetPreviewAndValidate(t *testing.T) {
+	zd := changeSetTestZoneData(t)
+
+	ops, err := normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{
+		{Op: ChangeSetOpReplace, RRs: []string{"www 600 IN A 192.0.2.2", "www 600 IN A 192.0.2.3"}},
+		{Op: ChangeSetOpAdd, RRs: []string{"api IN CNAME www"}},
+		{Op: ChangeSetOpDelete, Owner: "mail", Type: "MX"},
+	})
+	if err != nil {
+		t.Fatalf("normalizeChangeSetOps: %v", err)
+	}
+	diff, issues, err := zd.PreviewChangeSet(ops)
+	if err != nil {
+		t.Fatalf("PreviewChangeSet: %v", err)
+	}
+	if changeSetHasErrors(issues) {
+		t.Fatalf("unexpected errors: %+v", issues)
+	}
+	if len(diff) != 3 {
+		t.Fatalf("diff = %+v, want 3 RRsets", diff)
+	}
+	www := diff[0]
+	if www.Owner != "www.cs.example." || len(www.Removed) != 1 || len(www.Added) != 2 {
+		t.Errorf("www diff = %+v", www)
+	}
+	if !strings.Contains(www.Added[0], "\t600\t") {
+		t.Errorf("replace did not keep the given TTL: %q", www.Added[0])
+	}
+
+	// Preview does not touch the zone.
+	if od, _ := zd.GetOwner("mail.cs.example."); od == nil || len(od.RRtypes.GetOnlyRRSet(dns.TypeMX).RRs) != 1 {
+		t.Error("preview modified the published zone")
+	}
+
+	tests := []struct {
+		name string
+		ops  []ChangeSetOp
+		sev  string
+		msg  string
+	}{
+		{"cname conflict", []ChangeSetOp{{Op: ChangeSetOpAdd, RRs: []string{"www IN CNAME mail"}}}, ChangeSetIssueError, "cannot coexist"},
+		{"apex cname", []ChangeSetOp{{Op: ChangeSetOpAdd, RRs: []string{"@ IN CNAME www"}}}, ChangeSetIssueError, "apex"},
+		{"out of zone", []ChangeSetOp{{Op: ChangeSetOpAdd, RRs: []string{"www.other.example. IN A 192.0.2.9"}}}, ChangeSetIssueError, "outside zone"},
+		{"soa", []ChangeSetOp{{Op: ChangeSetOpReplace, RRs: []string{"@ IN SOA ns1 hostmaster 200 7200 1800 604800 7200"}}}, ChangeSetIssueError, "managed by the server"},
+		{"apex ns removed", []ChangeSetOp{{Op: ChangeSetOpDelete, Owner: "@", Type: "NS"}}, ChangeSetIssueError, "apex NS"},
+		{"missing glue", []ChangeSetOp{{Op: ChangeSetOpAdd, RRs: []string{"sub IN NS ns.sub"}}}, ChangeSetIssueWarning, "missing glue"},
+		{"occluded", []ChangeSetOp{{Op: ChangeSetOpAdd, RRs: []string{"sub IN NS ns.other.example."}}, {Op: ChangeSetOpAdd, RRs: []string{"x.sub IN TXT hello"}}}, ChangeSetIssueError, "occluded"},
+		{"noop", []ChangeSetOp{{Op: ChangeSetOpAdd, RRs: []string{"www IN A 192.0.2.1"}}}, ChangeSetIssueWarning, "no effect"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ops, err := normalizeChangeSetOps(zd.ZoneName, tc.ops)
+			if err != nil {
+				t.Fatalf("normalizeChangeSetOps: %v", err)
+			}
+			_, issues, err := zd.PreviewChangeSet(ops)
+			if err != nil {
+				t.Fatalf("PreviewChangeSet: %v", err)
+			}
+			if !csFindIssue(issues, tc.sev, tc.msg) {
+				t.Errorf("issues = %+v, want %s containing %q", issues, tc.sev, tc.msg)
+			}
+		})
+	}
+}
+
+func TestChangeSetApplyAndRollback(t *testing.T) {
+	zd := changeSetTestZoneData(t)
+	serial := zd.publishedSerial()
+
+	ops, err := normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{
+		{Op: ChangeSetOpReplace, RRs: []string{"www 300 IN A 192.0.2.2"}},
+		{Op: ChangeSetOpAdd, RRs: []string{"new 120 IN TXT \"hello\""}},
+	})
+	if err != nil {
+		t.Fatalf("normalizeChangeSetOps: %v", err)
+	}
+	res, err := zd.ApplyChangeSet(ops)
+	if err != nil {
+		t.Fatalf("ApplyChangeSet: %v", err)
+	}
+	if res.Serial == serial || zd.publishedSerial() != res.Serial {
+		t.Fatalf("serial %d -> %d, published %d: want one bump", serial, res.Serial, zd.publishedSerial())
+	}
+	od, _ := zd.GetOwner("www.cs.example.")
+	if od == nil {
+		t.Fatal("www missing after apply")
+	}
+	a := od.RRtypes.GetOnlyRRSet(dns.TypeA).RRs
+	if len(a) != 1 || a[0].(*dns.A).A.String() != "192.0.2.2" || a[0].Header().Ttl != 300 {
+		t.Errorf("www A after apply = %v", a)
+	}
+	if od, _ := zd.GetOwner("new.cs.example."); od == nil {
+		t.Error("new owner missing after apply")
+	}
+
+	cs := &ChangeSet{Name: "t1", Before: res.Before, After: res.After}
+	rb, err := zd.RollbackChangeSet(cs, false)
+	if err != nil {
+		t.Fatalf("RollbackChangeSet: %v", err)
+	}
+	if rb.Serial == res.Serial {
+		t.Error("rollback did not publish a new serial")
+	}
+	od, _ = zd.GetOwner("www.cs.example.")
+	a = od.RRtypes.GetOnlyRRSet(dns.TypeA).RRs
+	if len(a) != 1 || a[0].(*dns.A).A.String() != "192.0.2.1" || a[0].Header().Ttl != 3600 {
+		t.Errorf("www A after rollback = %v, want original RR with TTL 3600", a)
+	}
+	if od, _ := zd.GetOwner("new.cs.example."); od != nil {
+		t.Error("added owner survived the rollback")
+	}
+}
+
+func TestChangeSetRollbackRefusedAfterLaterChange(t *testing.T) {
+	zd := changeSetTestZoneData(t)
+	ops, _ := normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{{Op: ChangeSetOpReplace, RRs: []string{"www IN A 192.0.2.2"}}})
+	res, err := zd.ApplyChangeSet(ops)
+	if err != nil {
+		t.Fatalf("ApplyChangeSet: %v", err)
+	}
+	later, _ := normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{{Op: ChangeSetOpAdd, RRs: []string{"www IN A 192.0.2.7"}}})
+	if _, err := zd.ApplyChangeSet(later); err != nil {
+		t.Fatalf("ApplyChangeSet (later): %v", err)
+	}
+
+	cs := &ChangeSet{Name: "t1", Before: res.Before, After: res.After}
+	serial := zd.publishedSerial()
+	if _, err := zd.RollbackChangeSet(cs, false); err == nil || !strings.Contains(err.Error(), "has changed") {
+		t.Fatalf("RollbackChangeSet = %v, want refusal", err)
+	}
+	if zd.publishedSerial() != serial {
+		t.Error("refused rollback published anyway")
+	}
+	if _, err := zd.RollbackChangeSet(cs, true); err != nil {
+		t.Fatalf("forced RollbackChangeSet: %v", err)
+	}
+	od, _ := zd.GetOwner("www.cs.example.")
+	if a := od.RRtypes.GetOnlyRRSet(dns.TypeA).RRs; len(a) != 1 || a[0].(*dns.A).A.String() != "192.0.2.1" {
+		t.Errorf("www A after forced rollback = %v", a)
+	}
+}
+
+func TestChangeSetStoreAndActivate(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	zd := changeSetTestZoneData(t)
+
+	ops, _ := normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{{Op: ChangeSetOpAdd, RRs: []string{"ftp IN A 192.0.2.21"}}})
+	cs := &ChangeSet{Zone: zd.ZoneName, Name: "add-ftp", State: ChangeSetDraft, Ops: ops, CreatedBy: "test"}
+	if err := kdb.CreateChangeSet(cs); err != nil {
+		t.Fatalf("CreateChangeSet: %v", err)
+	}
+	if err := kdb.CreateChangeSet(&ChangeSet{Zone: zd.ZoneName, Name: "add-ftp", State: ChangeSetDraft}); err == nil {
+		t.Error("duplicate change set name accepted")
+	}
+
+	now := time.Now()
+	csApprove(t, zd, cs)
+	cs.ActivateAt = now.Add(time.Minute)
+	if err := kdb.SaveChangeSet(cs); err != nil {
+		t.Fatalf("SaveChangeSet: %v", err)
+	}
+	if due, err := kdb.DueChangeSets(now); err != nil || len(due) != 0 {
+		t.Fatalf("DueChangeSets(now) = %v, %v; want none", due, err)
+	}
+	due, err := kdb.DueChangeSets(now.Add(2 * time.Minute))
+	if err != nil || len(due) != 1 {
+		t.Fatalf("DueChangeSets(later) = %v, %v; want one", due, err)
+	}
+	if len(due[0].Ops) != 1 || due[0].Ops[0].RRs[0] != ops[0].RRs[0] {
+		t.Errorf("ops did not round-trip: %+v", due[0].Ops)
+	}
+
+	actor := AuditActor{Type: AuditActorEngine, Name: "test"}
+	if _, err := kdb.ActivateChangeSet(&due[0], actor); err != nil {
+		t.Fatalf("ActivateChangeSet: %v", err)
+	}
+	got, ok, err := kdb.GetChangeSet(zd.ZoneName, "add-ftp")
+	if err != nil || !ok {
+		t.Fatalf("GetChangeSet: %v, %v", ok, err)
+	}
+	if got.State != ChangeSetApplied || got.AppliedSerial != zd.publishedSerial() || len(got.After) != 1 {
+		t.Errorf("stored change set after apply = %+v", got)
+	}
+	if due, _ := kdb.DueChangeSets(now.Add(time.Hour)); len(due) != 0 {
+		t.Error("applied change set still due")
+	}
+
+	if _, err := kdb.RevertChangeSet(got, false, actor); err != nil {
+		t.Fatalf("RevertChangeSet: %v", err)
+	}
+	got, _, _ = kdb.GetChangeSet(zd.ZoneName, "add-ftp")
+	if got.State != ChangeSetRolledBack || got.RolledBackAt.IsZero() {
+		t.Errorf("stored change set after rollback = %+v", got)
+	}
+	if od, _ := zd.GetOwner("ftp.cs.example."); od != nil {
+		t.Error("ftp owner survived the rollback")
+	}
+}
+
+// csApprove approves cs against the published zone like the API does.
+func csApprove(t *testing.T, zd *ZoneData, cs *ChangeSet) {
+	t.Helper()
+	serial := zd.publishedSerial()
+	diff, issues, err := zd.PreviewChangeSet(cs.Ops)
+	if err != nil || changeSetHasErrors(issues) {
+		t.Fatalf("PreviewChangeSet: %v %+v", err, issues)
+	}
+	cs.State = ChangeSetApproved
+	cs.ApprovedSerial = serial
+	cs.ApprovedDiff = diff
+}
+
+func TestChangeSetActivateRequiresCurrentApproval(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	zd := changeSetTestZoneData(t)
+	actor := AuditActor{Type: AuditActorEngine, Name: "test"}
+
+	ops, _ := normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{{Op: ChangeSetOpReplace, RRs: []string{"www IN A 192.0.2.2"}}})
+	cs := &ChangeSet{Zone: zd.ZoneName, Name: "move-www", State: ChangeSetDraft, Ops: ops}
+	if err := kdb.CreateChangeSet(cs); err != nil {
+		t.Fatalf("CreateChangeSet: %v", err)
+	}
+	csApprove(t, zd, cs)
+	if err := kdb.SaveChangeSet(cs); err != nil {
+		t.Fatalf("SaveChangeSet: %v", err)
+	}
+
+	// The zone moves on after the approval.
+	later, _ := normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{{Op: ChangeSetOpAdd, RRs: []string{"www IN A 192.0.2.3"}}})
+	if _, err := zd.ApplyChangeSet(later); err != nil {
+		t.Fatalf("ApplyChangeSet: %v", err)
+	}
+	stored, _, _ := kdb.GetChangeSet(zd.ZoneName, "move-www")
+	if _, err := kdb.ActivateChangeSet(stored, actor); !errors.Is(err, errChangeSetStale) {
+		t.Fatalf("ActivateChangeSet = %v, want a stale approval", err)
+	}
+	stored, _, _ = kdb.GetChangeSet(zd.ZoneName, "move-www")
+	if stored.State != ChangeSetFailed {
+		t.Errorf("state after stale activation = %s, want %s", stored.State, ChangeSetFailed)
+	}
+	rrs := zd.publishedSnapshot().Data["www.cs.example."].RRtypes.GetOnlyRRSet(dns.TypeA).RRs
+	if len(rrs) != 2 {
+		t.Errorf("stale change set touched the zone: %v", rrs)
+	}
+
+	// Approved again it applies, but only once.
+	csApprove(t, zd, stored)
+	if err := kdb.SaveChangeSet(stored); err != nil {
+		t.Fatalf("SaveChangeSet: %v", err)
+	}
+	first, _, _ := kdb.GetChangeSet(zd.ZoneName, "move-www")
+	second, _, _ := kdb.GetChangeSet(zd.ZoneName, "move-www")
+	if _, err := kdb.ActivateChangeSet(first, actor); err != nil {
+		t.Fatalf("ActivateChangeSet: %v", err)
+	}
+	if _, err := kdb.ActivateChangeSet(second, actor); !errors.Is(err, errChangeSetClaimed) {
+		t.Errorf("second ActivateChangeSet = %v, want it refused", err)
+	}
+}
+
+// Re-signing bumps the serial of a signed zone without touching the RRsets
+// a change set changes, so it does not invalidate the approval.
+func TestChangeSetAppliesAfterResign(t *testing.T) {
+	zd, kdb := signingTestZone(t, 3)
+	zd.ZoneType = Primary
+	if _, err := zd.SignZone(kdb, true); err != nil {
+		t.Fatalf("SignZone: %v", err)
+	}
+	actor := AuditActor{Type: AuditActorEngine, Name: "test"}
+
+	ops, _ := normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{{Op: ChangeSetOpReplace, RRs: []string{"host1 3600 IN A 192.0.2.99"}}})
+	cs := &ChangeSet{Zone: zd.ZoneName, Name: "move-host1", State: ChangeSetDraft, Ops: ops}
+	if err := kdb.CreateChangeSet(cs); err != nil {
+		t.Fatalf("CreateChangeSet: %v", err)
+	}
+	csApprove(t, zd, cs)
+	if err := kdb.SaveChangeSet(cs); err != nil {
+		t.Fatalf("SaveChangeSet: %v", err)
+	}
+
+	// host2's signature is about to expire and gets re-signed.
+	const due = "host2.zsk-alg.example."
+	zd.mu.Lock()
+	rrset := cloneRRset(zd.snapshot.Load().Data[due].RRtypes.GetOnlyRRSet(dns.TypeA))
+	rrset.RRtype = dns.TypeA
+	rrset.RRSIGs[0].(*dns.RRSIG).Expiration = uint32(time.Now().Add(10 * time.Minute).Unix())
+	zd.stageRRsetLocked(due, rrset)
+	zd.publishLocked(zd.generation.Load())
+	zd.mu.Unlock()
+	if n, err := zd.ResignDue(kdb, time.Now()); err != nil || n != 1 {
+		t.Fatalf("ResignDue = %d, %v; want 1 RRset re-signed", n, err)
+	}
+	if zd.publishedSerial() == cs.ApprovedSerial {
+		t.Fatal("re-signing did not bump the serial")
+	}
+
+	stored, _, _ := kdb.GetChangeSet(zd.ZoneName, "move-host1")
+	if _, err := kdb.ActivateChangeSet(stored, actor); err != nil {
+		t.Fatalf("ActivateChangeSet after a re-sign: %v", err)
+	}
+	a := zd.publishedSnapshot().Data["host1.zsk-alg.example."].RRtypes.GetOnlyRRSet(dns.TypeA).RRs
+	if len(a) != 1 || a[0].(*dns.A).A.String() != "192.0.2.99" {
+		t.Errorf("host1 A after apply = %v", a)
+	}
+}
+
+// A rollback claims the change set like an apply does, and a claim left by
+// a crash is released at startup.
+func TestChangeSetClaims(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	zd := changeSetTestZoneData(t)
+	actor := AuditActor{Type: AuditActorEngine, Name: "test"}
+
+	ops, _ := normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{{Op: ChangeSetOpAdd, RRs: []string{"ftp IN A 192.0.2.21"}}})
+	applied := &ChangeSet{Zone: zd.ZoneName, Name: "add-ftp", State: ChangeSetDraft, Ops: ops}
+	if err := kdb.CreateChangeSet(applied); err != nil {
+		t.Fatalf("CreateChangeSet: %v", err)
+	}
+	csApprove(t, zd, applied)
+	if err := kdb.SaveChangeSet(applied); err != nil {
+		t.Fatalf("SaveChangeSet: %v", err)
+	}
+	if _, err := kdb.ActivateChangeSet(applied, actor); err != nil {
+		t.Fatalf("ActivateChangeSet: %v", err)
+	}
+
+	first, _, _ := kdb.GetChangeSet(zd.ZoneName, "add-ftp")
+	second, _, _ := kdb.GetChangeSet(zd.ZoneName, "add-ftp")
+	if err := kdb.ClaimChangeSet(first, ChangeSetApplied); err != nil {
+		t.Fatalf("ClaimChangeSet: %v", err)
+	}
+	if _, err := kdb.RevertChangeSet(second, false, actor); !errors.Is(err, errChangeSetClaimed) {
+		t.Fatalf("RevertChangeSet of a claimed change set = %v, want it refused", err)
+	}
+	if od, _ := zd.GetOwner("ftp.cs.example."); od == nil {
+		t.Fatal("a refused rollback touched the zone")
+	}
+
+	// An approved change set claimed for an apply, then the process dies.
+	ops, _ = normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{{Op: ChangeSetOpAdd, RRs: []string{"www IN A 192.0.2.2"}}})
+	pending := &ChangeSet{Zone: zd.ZoneName, Name: "add-www", State: ChangeSetDraft, Ops: ops}
+	if err := kdb.CreateChangeSet(pending); err != nil {
+		t.Fatalf("CreateChangeSet: %v", err)
+	}
+	csApprove(t, zd, pending)
+	if err := kdb.SaveChangeSet(pending); err != nil {
+		t.Fatalf("SaveChangeSet: %v", err)
+	}
+	if err := kdb.ClaimChangeSet(pending, ChangeSetApproved); err != nil {
+		t.Fatalf("ClaimChangeSet: %v", err)
+	}
+
+	if n, err := kdb.RecoverChangeSets(); err != nil || n != 2 {
+		t.Fatalf("RecoverChangeSets = %d, %v; want 2", n, err)
+	}
+	for name, want := range map[string]string{"add-ftp": ChangeSetApplied, "add-www": ChangeSetApproved} {
+		if cs, _, _ := kdb.GetChangeSet(zd.ZoneName, name); cs.State != want {
+			t.Errorf("%s after recovery is %s, want %s", name, cs.State, want)
+		}
+	}
+
+	// Released, both go through.
+	stored, _, _ := kdb.GetChangeSet(zd.ZoneName, "add-www")
+	if _, err := kdb.ActivateChangeSet(stored, actor); err != nil {
+		t.Errorf("ActivateChangeSet after recovery: %v", err)
+	}
+	stored, _, _ = kdb.GetChangeSet(zd.ZoneName, "add-ftp")
+	if _, err := kdb.RevertChangeSet(stored, false, actor); err != nil {
+		t.Errorf("RevertChangeSet after recovery: %v", err)
+	}
+}
+
+type failingSigner struct{ crypto.Signer }
+
+func (failingSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, errors.New("hsm unavailable")
+}
+
+// A signing failure aborts the change set: nothing is published and an
+// already staged working set is put back as it was.
+func TestChangeSetSigningFailureAborts(t *testing.T) {
+	zd, kdb := signingTestZone(t, 3)
+	zd.ZoneType = Primary
+	if _, err := zd.SignZone(kdb, true); err != nil {
+		t.Fatalf("SignZone: %v", err)
+	}
+	serial := zd.publishedSerial()
+
+	zd.mu.Lock()
+	pending := core.RRset{Name: "pending.zsk-alg.example.", RRtype: dns.TypeTXT,
+		RRs: []dns.RR{mustRR(t, "pending.zsk-alg.example. 300 IN TXT \"staged\"")}}
+	zd.stageRRsetLocked(pending.Name, pending)
+	zd.mu.Unlock()
+
+	zsk := zd.ActiveDnssecKeys().ZSKs[0]
+	cs := zsk.CS
+	zsk.CS = failingSigner{cs}
+	defer func() { zsk.CS = cs }()
+
+	ops, err := normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{
+		{Op: ChangeSetOpReplace, RRs: []string{"host1 300 IN A 192.0.2.99"}},
+	})
+	if err != nil {
+		t.Fatalf("normalizeChangeSetOps: %v", err)
+	}
+	if _, err := zd.ApplyChangeSet(ops); err == nil || !strings.Contains(err.Error(), "signing") {
+		t.Fatalf("ApplyChangeSet = %v, want a signing error", err)
+	}
+	if zd.publishedSerial() != serial {
+		t.Error("a failed change set was published")
+	}
+
+	zd.mu.Lock()
+	defer zd.mu.Unlock()
+	if od := zd.workingSet["host1.zsk-alg.example."]; od != nil {
+		if a := od.RRtypes.GetOnlyRRSet(dns.TypeA).RRs; len(a) != 1 || a[0].(*dns.A).A.String() != "192.0.2.2" {
+			t.Errorf("host1 A left staged after the abort: %v", a)
+		}
+	}
+	if od := zd.workingSet[pending.Name]; od == nil || len(od.RRtypes.GetOnlyRRSet(dns.TypeTXT).RRs) != 1 {
+		t.Error("the abort discarded unrelated staged changes")
+	}
+}
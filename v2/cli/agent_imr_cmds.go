@@ -10,9 +10,12 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	tdns "github.com/johanix/tdns/v2"
+	cache "github.com/johanix/tdns/v2/cache"
 	"github.com/miekg/dns"
+	"github.com/ryanuber/columnize"
 	"github.com/spf13/cobra"
 )
 
@@ -217,6 +220,8 @@ func newImrDumpTuningCmd(role string) *cobra.Command {
 			printSection("Backoff policy (effective):", "backoff")
 			printSection("Address-family tracker (config; activates with W8):", "address_family")
 			printSection("Discovery state machine (config; activates with W9):", "discovery")
+			printSection("Prefetch:", "prefetch")
+			printSection("RRset cache budget:", "cache")
 			fmt.Printf("Per-query budget          : %v\n", data["query_budget"])
 			fmt.Printf("Upgrade indirect cache hits: %v\n", data["upgrade_indirect_cache_hits"])
 		},
@@ -267,6 +272,416 @@ func newImrDumpZoneBackoffsCmd(role string) *cobra.Command {
 	}
 }
 
+func newImrCacheStatsCmd(role string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "cache-stats",
+		Short: "Show RRset cache memory use per class, and coalescing and prefetch counters",
+		Run: func(cmd *cobra.Command, args []string) {
+			amr, err := SendImrMgmtCmd(role, &tdns.ImrMgmtPost{Command: "imr-cache-stats"})
+			if err != nil {
+				log.Fatalf("Request failed: %v", err)
+			}
+			if amr.Error {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", amr.ErrorMsg)
+				os.Exit(1)
+			}
+			var stats tdns.ImrCacheStats
+			buf, err := json.Marshal(amr.Data)
+			if err == nil {
+				err = json.Unmarshal(buf, &stats)
+			}
+			if err != nil {
+				log.Fatalf("Error decoding cache stats: %v", err)
+			}
+			fmt.Println(amr.Msg)
+			out := []string{"Class|Entries|Protected|Used|Budget|Use|Evictions"}
+			for _, c := range stats.Classes {
+				pct := 0.0
+				if c.Budget > 0 {
+					pct = 100 * float64(c.Bytes) / float64(c.Budget)
+				}
+				out = append(out, fmt.Sprintf("%s|%d|%d|%.1f MB|%d MB|%.0f%%|%d",
+					c.Class, c.Entries, c.Protected, float64(c.Bytes)/(1<<20), c.Budget>>20, pct, c.Evictions))
+			}
+			fmt.Println(columnize.SimpleFormat(out))
+		},
+	}
+}
+
+func newImrAccessStatsCmd(role string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "access-stats",
+		Short: "Show how many client queries were refused or rate limited",
+		Run: func(cmd *cobra.Command, args []string) {
+			amr, err := SendImrMgmtCmd(role, &tdns.ImrMgmtPost{Command: "imr-access-stats"})
+			if err != nil {
+				log.Fatalf("Request failed: %v", err)
+			}
+			if amr.Error {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", amr.ErrorMsg)
+				os.Exit(1)
+			}
+			var stats tdns.ImrAccessStats
+			buf, err := json.Marshal(amr.Data)
+			if err == nil {
+				err = json.Unmarshal(buf, &stats)
+			}
+			if err != nil {
+				log.Fatalf("Error decoding access stats: %v", err)
+			}
+			fmt.Println(amr.Msg)
+			if len(stats.Groups) > 0 {
+				fmt.Printf("Client groups: %s\n", strings.Join(stats.Groups, ", "))
+			}
+		},
+	}
+}
+
+func newImrShowTaCmd(role string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show-ta",
+		Short: "Show the trust anchors and their RFC 5011 key states",
+		Run: func(cmd *cobra.Command, args []string) {
+			amr, err := SendImrMgmtCmd(role, &tdns.ImrMgmtPost{Command: "imr-show-ta"})
+			if err != nil {
+				log.Fatalf("Request failed: %v", err)
+			}
+			if amr.Error {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", amr.ErrorMsg)
+				os.Exit(1)
+			}
+			var tas []tdns.TrustAnchorStatus
+			buf, err := json.Marshal(amr.Data)
+			if err == nil {
+				err = json.Unmarshal(buf, &tas)
+			}
+			if err != nil {
+				log.Fatalf("Error decoding trust anchors: %v", err)
+			}
+			fmt.Println(amr.Msg)
+			printTrustAnchors(tas)
+		},
+	}
+}
+
+// printTrustAnchors prints one table per trust anchor zone.
+func printTrustAnchors(tas []tdns.TrustAnchorStatus) {
+	when := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+	for _, ta := range tas {
+		fmt.Printf("\nZone %s", ta.Zone)
+		if ta.Rfc5011 {
+			fmt.Printf(" (RFC 5011: last probe %s, last success %s, next probe %s)",
+				when(ta.LastQueried), when(ta.LastSuccess), when(ta.NextProbe))
+		}
+		fmt.Println()
+		if ta.LastError != "" {
+			fmt.Printf("Last error: %s\n", ta.LastError)
+		}
+		out := []string{"KeyTag|Algorithm|State|First seen|Last change|Hold-down until"}
+		for _, k := range ta.Keys {
+			holdDown := "-"
+			if k.State == "ADDPEND" || k.State == "REVOKED" {
+				holdDown = when(k.HoldDown)
+			}
+			out = append(out, fmt.Sprintf("%d|%s|%s|%s|%s|%s",
+				k.KeyTag, k.Algorithm, k.State, when(k.FirstSeen), when(k.LastChange), holdDown))
+		}
+		fmt.Println(columnize.SimpleFormat(out))
+	}
+}
+
+func newImrNtaCmd(role string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "nta",
+		Short: "Manage negative trust anchors (RFC 7646): zones exempt from DNSSEC validation",
+	}
+
+	var lifetime, reason string
+	addCmd := &cobra.Command{
+		Use:   "add <zone>",
+		Short: "Stop validating zone until the NTA expires or the zone validates again",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sendImrNtaCmd(role, "imr-nta-add", map[string]interface{}{
+				"zone":     dns.Fqdn(args[0]),
+				"lifetime": lifetime,
+				"reason":   reason,
+			})
+		},
+	}
+	addCmd.Flags().StringVarP(&lifetime, "lifetime", "l", "", "NTA lifetime, e.g. 6h (default: nta.default-lifetime, 24h)")
+	addCmd.Flags().StringVarP(&reason, "reason", "r", "", "why the NTA was added")
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <zone>",
+		Short: "Remove the negative trust anchor for zone",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sendImrNtaCmd(role, "imr-nta-remove", map[string]interface{}{"zone": dns.Fqdn(args[0])})
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the negative trust anchors",
+		Run: func(cmd *cobra.Command, args []string) {
+			sendImrNtaCmd(role, "imr-nta-list", nil)
+		},
+	}
+
+	cmd.AddCommand(addCmd, removeCmd, listCmd)
+	return cmd
+}
+
+func sendImrNtaCmd(role, command string, data map[string]interface{}) {
+	amr, err := SendImrMgmtCmd(role, &tdns.ImrMgmtPost{Command: command, Data: data})
+	if err != nil {
+		log.Fatalf("Request failed: %v", err)
+	}
+	if amr.Error {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", amr.ErrorMsg)
+		os.Exit(1)
+	}
+	fmt.Println(amr.Msg)
+	if amr.Data == nil {
+		return
+	}
+	var ntas []cache.NTA
+	buf, err := json.Marshal(amr.Data)
+	if err == nil {
+		err = json.Unmarshal(buf, &ntas)
+	}
+	if err != nil {
+		log.Fatalf("Error decoding negative trust anchors: %v", err)
+	}
+	if len(ntas) == 0 {
+		return
+	}
+	when := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+	out := []string{"Zone|Added|Expires|Last probe|Probe result|Reason"}
+	for _, nta := range ntas {
+		expires := when(nta.Expires)
+		if nta.Permanent {
+			expires = "never"
+		}
+		out = append(out, fmt.Sprintf("%s|%s|%s|%s|%s|%s", nta.Zone, when(nta.Added), expires,
+			when(nta.LastProbe), nta.Probe, nta.Reason))
+	}
+	fmt.Println(columnize.SimpleFormat(out))
+}
+
+func newImrLocalZoneCmd(role string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "local-zone",
+		Short: "Manage local zones: names answered by the resolver from local data",
+	}
+
+	var zonetype string
+	var insecure bool
+	addCmd := &cobra.Command{
+		Use:   "add <zone>",
+		Short: "Add a local zone, or change the type of an existing one",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sendImrLocalZoneCmd(role, "imr-local-zone-add", map[string]interface{}{
+				"zone":     dns.Fqdn(args[0]),
+				"type":     zonetype,
+				"insecure": insecure,
+			})
+		},
+	}
+	addCmd.Flags().StringVarP(&zonetype, "type", "t", "static", "static, transparent, redirect, refuse or always-nxdomain")
+	addCmd.Flags().BoolVarP(&insecure, "insecure", "", false, "do not validate names in the zone that are resolved")
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <zone>",
+		Short: "Remove a local zone and its data",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sendImrLocalZoneCmd(role, "imr-local-zone-remove", map[string]interface{}{"zone": dns.Fqdn(args[0])})
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the local zones and their data",
+		Run: func(cmd *cobra.Command, args []string) {
+			sendImrLocalZoneCmd(role, "imr-local-zone-list", nil)
+		},
+	}
+
+	cmd.AddCommand(addCmd, removeCmd, listCmd)
+	return cmd
+}
+
+func newImrLocalDataCmd(role string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "local-data",
+		Short: "Manage the local data served from the local zones",
+	}
+
+	addCmd := &cobra.Command{
+		Use:   "add <rr>",
+		Short: "Add an RR, in zone file format, e.g. 'printer.lan. 300 IN A 192.0.2.10'",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sendImrLocalZoneCmd(role, "imr-local-data-add", map[string]interface{}{"rr": strings.Join(args, " ")})
+		},
+	}
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <owner> [rrtype]",
+		Short: "Remove the local data for owner, or only that of rrtype",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			data := map[string]interface{}{"owner": dns.Fqdn(args[0])}
+			if len(args) == 2 {
+				data["rrtype"] = args[1]
+			}
+			sendImrLocalZoneCmd(role, "imr-local-data-remove", data)
+		},
+	}
+
+	cmd.AddCommand(addCmd, removeCmd)
+	return cmd
+}
+
+func sendImrLocalZoneCmd(role, command string, data map[string]interface{}) {
+	amr, err := SendImrMgmtCmd(role, &tdns.ImrMgmtPost{Command: command, Data: data})
+	if err != nil {
+		log.Fatalf("Request failed: %v", err)
+	}
+	if amr.Error {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", amr.ErrorMsg)
+		os.Exit(1)
+	}
+	fmt.Println(amr.Msg)
+	if amr.Data == nil {
+		return
+	}
+	var zones []tdns.LocalZone
+	buf, err := json.Marshal(amr.Data)
+	if err == nil {
+		err = json.Unmarshal(buf, &zones)
+	}
+	if err != nil {
+		log.Fatalf("Error decoding local zones: %v", err)
+	}
+	PrintLocalZones(zones)
+}
+
+// PrintLocalZones prints local zones and their data.
+func PrintLocalZones(zones []tdns.LocalZone) {
+	for _, lz := range zones {
+		var flags []string
+		if lz.Insecure {
+			flags = append(flags, "insecure")
+		}
+		if lz.Implicit {
+			flags = append(flags, "implicit")
+		}
+		extra := ""
+		if len(flags) > 0 {
+			extra = " (" + strings.Join(flags, ", ") + ")"
+		}
+		fmt.Printf("%s %s%s\n", lz.Zone, lz.Type, extra)
+		for _, rr := range lz.Data {
+			fmt.Printf("    %s\n", rr)
+		}
+	}
+}
+
+func newImrExplainCmd(role string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain <qname> <qtype>",
+		Short: "Resolve and validate a name again and show the chain of trust, marking the failing link",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			amr, err := SendImrMgmtCmd(role, &tdns.ImrMgmtPost{
+				Command: "imr-explain",
+				Data:    map[string]interface{}{"qname": dns.Fqdn(args[0]), "qtype": strings.ToUpper(args[1])},
+			})
+			if err != nil {
+				log.Fatalf("Request failed: %v", err)
+			}
+			if amr.Error {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", amr.ErrorMsg)
+				os.Exit(1)
+			}
+			fmt.Println(amr.Msg)
+			var report tdns.ExplainReport
+			buf, err := json.Marshal(amr.Data)
+			if err == nil {
+				err = json.Unmarshal(buf, &report)
+			}
+			if err != nil {
+				log.Fatalf("Error decoding explain report: %v", err)
+			}
+			PrintExplainReport(&report)
+		},
+	}
+}
+
+// PrintExplainReport prints the validation steps of an explain report, one
+// per line, with the failing link marked.
+func PrintExplainReport(report *tdns.ExplainReport) {
+	for _, rr := range report.Answer {
+		fmt.Printf("    %s\n", rr)
+	}
+	if len(report.Steps) == 0 {
+		fmt.Println("No validation steps recorded (answer not signed, or served locally)")
+		return
+	}
+	out := []string{" |Zone|Step|Data|Key|Validity|Result"}
+	for i, step := range report.Steps {
+		mark := ""
+		if i == report.Failure {
+			mark = "==>"
+		}
+		key := "-"
+		if step.KeyTag != 0 || step.Algorithm != "" {
+			key = fmt.Sprintf("%d %s", step.KeyTag, step.Algorithm)
+			if step.DigestType != "" {
+				key += " " + step.DigestType
+			}
+		}
+		validity := "-"
+		if !step.Inception.IsZero() {
+			validity = fmt.Sprintf("%s - %s", step.Inception.Format("2006-01-02 15:04"), step.Expiration.Format("2006-01-02 15:04"))
+		}
+		result := "ok"
+		if !step.Ok {
+			result = "FAIL"
+		}
+		if step.Kind == cache.TraceStepRRset {
+			result = cache.ValidationStateToString[step.State]
+		}
+		if step.Detail != "" {
+			result += ": " + step.Detail
+		}
+		zone := step.Zone
+		if zone == "" {
+			zone = "-"
+		}
+		out = append(out, fmt.Sprintf("%s|%s|%s|%s %s|%s|%s|%s", mark, zone, step.Kind, step.Owner, step.RRtype, key, validity, result))
+	}
+	fmt.Println(columnize.SimpleFormat(out))
+	if report.Failure >= 0 {
+		step := report.Steps[report.Failure]
+		fmt.Printf("Failing link: %s %s at %s: %s\n", step.Owner, step.RRtype, step.Zone, step.Detail)
+	}
+}
+
 func addImrLeafCmds(parent *cobra.Command, role string) {
 	parent.AddCommand(
 		newImrQueryCmd(role),
@@ -275,6 +690,13 @@ func addImrLeafCmds(parent *cobra.Command, role string) {
 		newImrShowCmd(role),
 		newImrDumpTuningCmd(role),
 		newImrDumpZoneBackoffsCmd(role),
+		newImrCacheStatsCmd(role),
+		newImrAccessStatsCmd(role),
+		newImrShowTaCmd(role),
+		newImrNtaCmd(role),
+		newImrLocalZoneCmd(role),
+		newImrLocalDataCmd(role),
+		newImrExplainCmd(role),
 	)
 }
 
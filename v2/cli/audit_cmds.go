@@ -0,0 +1,170 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tdns "github.com/johanix/tdns/v2"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+// NewAuditCmd returns the 'audit' subtree (list, export) for the given daemon
+// role. Both subcommands read the daemon's audit journal via GET /audit and
+// share the same filter flags.
+func NewAuditCmd(role string) *cobra.Command {
+	var zone, actor, since, until string
+	var limit int
+
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: fmt.Sprintf("Query the %s daemon's audit journal of zone and key mutations", role),
+	}
+
+	addFilterFlags := func(c *cobra.Command) {
+		c.Flags().StringVarP(&zone, "zone", "z", "", "only entries for this zone")
+		c.Flags().StringVar(&actor, "actor", "", "only entries by this actor: a type (sig0, tsig, api, engine) or type:name")
+		c.Flags().StringVar(&since, "since", "", "start of time window: RFC3339 time or duration ago (e.g. 24h)")
+		c.Flags().StringVar(&until, "until", "", "end of time window: RFC3339 time or duration ago")
+		c.Flags().IntVar(&limit, "limit", 0, "max number of entries (server default 1000)")
+	}
+
+	fetch := func() []tdns.AuditEntry {
+		q := url.Values{}
+		if zone != "" {
+			q.Set("zone", zone)
+		}
+		if actor != "" {
+			q.Set("actor", actor)
+		}
+		for _, p := range []struct{ name, val string }{{"since", since}, {"until", until}} {
+			if p.val == "" {
+				continue
+			}
+			t, err := parseAuditTime(p.val)
+			if err != nil {
+				cliFatalf("bad --%s value %q: %v", p.name, p.val, err)
+			}
+			q.Set(p.name, t.UTC().Format(time.RFC3339))
+		}
+		if limit > 0 {
+			q.Set("limit", strconv.Itoa(limit))
+		}
+		return fetchAuditEntries(role, q)
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List audit entries, newest first",
+		Run: func(cmd *cobra.Command, args []string) {
+			entries := fetch()
+			if len(entries) == 0 {
+				fmt.Println("No audit entries match.")
+				return
+			}
+			out := []string{"Time|Actor|Source|Zone|Action|Serial|Change"}
+			for _, e := range entries {
+				serial := "-"
+				if e.Serial != 0 {
+					serial = strconv.FormatUint(uint64(e.Serial), 10)
+				}
+				out = append(out, fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s",
+					e.Time.Local().Format("2006-01-02 15:04:05"), e.Actor.String(), e.Source,
+					e.Zone, e.Action, serial, auditChangeSummary(e)))
+			}
+			fmt.Println(columnize.SimpleFormat(out))
+		},
+	}
+	addFilterFlags(listCmd)
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export audit entries as JSON lines (one entry per line) on stdout",
+		Run: func(cmd *cobra.Command, args []string) {
+			enc := json.NewEncoder(os.Stdout)
+			for _, e := range fetch() {
+				if err := enc.Encode(e); err != nil {
+					cliFatalf("error encoding audit entry %d: %v", e.ID, err)
+				}
+			}
+		},
+	}
+	addFilterFlags(exportCmd)
+
+	auditCmd.AddCommand(listCmd, exportCmd)
+	return auditCmd
+}
+
+// parseAuditTime accepts either an RFC3339 timestamp or a duration, which
+// is interpreted as that long before now.
+func parseAuditTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("neither an RFC3339 time nor a duration")
+	}
+	return time.Now().Add(-d), nil
+}
+
+func fetchAuditEntries(role string, q url.Values) []tdns.AuditEntry {
+	api, err := GetApiClient(role, true)
+	if err != nil {
+		cliFatalf("error getting API client: %v", err)
+	}
+	endpoint := "/audit"
+	if len(q) > 0 {
+		endpoint += "?" + q.Encode()
+	}
+	status, body, err := api.RequestNG("GET", endpoint, nil, true)
+	if err != nil {
+		cliFatalf("error calling audit: %v", err)
+	}
+	var resp tdns.AuditResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		cliFatalf("error decoding audit response (status %d): %v", status, err)
+	}
+	if resp.Error {
+		cliFatalf("audit: %s", resp.ErrorMsg)
+	}
+	if status != http.StatusOK {
+		cliFatalf("unexpected status %d from audit: %s", status, strings.TrimSpace(string(body)))
+	}
+	return resp.Entries
+}
+
+// auditChangeSummary is the one-column rendering of what changed: the key
+// state transition for key mutations, else RR counts before/after, plus detail.
+func auditChangeSummary(e tdns.AuditEntry) string {
+	var parts []string
+	switch {
+	case e.KeyID != 0:
+		parts = append(parts, fmt.Sprintf("key %d: %s -> %s", e.KeyID, dashIfEmpty(e.OldState), dashIfEmpty(e.NewState)))
+	case len(e.Old) > 0 || len(e.New) > 0:
+		parts = append(parts, fmt.Sprintf("%d RR -> %d RR", len(e.Old), len(e.New)))
+	}
+	if e.Detail != "" {
+		parts = append(parts, e.Detail)
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, "; ")
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
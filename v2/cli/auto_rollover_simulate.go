@@ -0,0 +1,247 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	tdns "github.com/johanix/tdns/v2"
+)
+
+// newAutoRolloverSimulateCmd returns the `auto-rollover simulate`
+// subcommand. Runs the daemon's own KeyStateWorker pass (KSK and ZSK
+// rollover engines, standby maintenance, retire/remove) for one zone
+// against a virtual clock, a scratch keystore and a model parent, and
+// prints the resulting timeline, per-key safe windows and findings.
+//
+// Config resolution is the same as `validate`: online asks the daemon
+// for its config path and the zone's policy name; --serverconfig reads
+// a YAML file directly.
+func newAutoRolloverSimulateCmd() *cobra.Command {
+	var (
+		serverConfig string
+		policyName   string
+		horizon      string
+		start        string
+		step         string
+		idleStep     string
+		dsDelay      string
+		parentDSTTL  string
+		maxTTL       string
+		scheme       string
+		summary      bool
+		verbose      bool
+	)
+	c := &cobra.Command{
+		Use:   "simulate",
+		Short: "Simulate a zone's rollovers under its policy on a virtual clock",
+		Long: `Drives the real rollover engines for the zone against a virtual clock
+and a simulated parent, starting from a signed and delegated zone (one
+active KSK with its DS at the parent, one active ZSK), and prints:
+
+  - a timeline of key state changes, DNSKEY RRset changes, DS/CDS
+    publications, parent DS changes, rollover phases and TTL clamps
+  - per key: published, DS visible, validator-safe activation, active,
+    retired, safe removal and removed
+  - findings: UNSAFE (a validator with cached data could fail), STALL
+    (a due roll did not happen, or the push entered softfail) and WARN
+
+The model parent accepts every push and serves the new DS RRset
+--ds-delay later (default: rollover.ds-publish-delay) with TTL
+--parent-ds-ttl (default: ttls.parent-ds, else 24h).
+
+Nothing touches the daemon's keystore or the real parent. Exit status
+is 1 when the simulation reports UNSAFE or STALL.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			PrepArgs(cmd, "zonename")
+			tdns.Globals.App.Type = tdns.AppTypeCli
+			z := dns.Fqdn(tdns.Globals.Zonename)
+
+			cfgPath := serverConfig
+			polName := policyName
+			if cfgPath == "" {
+				api, err := GetApiClient("auth", true)
+				if err != nil {
+					cliFatalf("error getting API client: %v", err)
+				}
+				_, body, err := api.RequestNG("GET", "/config/paths?zone="+z, nil, true)
+				if err != nil {
+					cliFatalf("error contacting daemon: %v", err)
+				}
+				var resp tdns.ConfigPathsResponse
+				if err := json.Unmarshal(body, &resp); err != nil {
+					cliFatalf("error parsing /config/paths: %v", err)
+				}
+				cfgPath = resp.ConfigFile
+				if cfgPath == "" {
+					cliFatalf("daemon did not report a config-file path")
+				}
+				if polName == "" {
+					polName = resp.PolicyName
+				}
+			}
+
+			pol, resolvedPol, err := loadPolicyFromYAMLFile(cfgPath, z, polName)
+			if err != nil {
+				cliFatalf("%v", err)
+			}
+			kasp, err := loadKaspFromYAMLFile(cfgPath)
+			if err != nil {
+				cliFatalf("%v", err)
+			}
+
+			dur := func(flag, val string) time.Duration {
+				if val == "" {
+					return 0
+				}
+				d, err := tdns.ParseExtendedDuration(val)
+				if err != nil || d <= 0 {
+					cliFatalf("invalid --%s %q: must be a positive duration (e.g. 1h, 30d)", flag, val)
+				}
+				return d
+			}
+			cfg := tdns.RolloverSimConfig{
+				Zone:        z,
+				Policy:      pol,
+				Kasp:        kasp,
+				Horizon:     dur("horizon", horizon),
+				Step:        dur("step", step),
+				IdleStep:    dur("idle-step", idleStep),
+				DSDelay:     dur("ds-delay", dsDelay),
+				ParentDSTTL: dur("parent-ds-ttl", parentDSTTL),
+				MaxZoneTTL:  dur("max-ttl", maxTTL),
+				Scheme:      scheme,
+			}
+			if start != "" {
+				t, err := time.Parse(time.RFC3339, start)
+				if err != nil {
+					cliFatalf("invalid --start %q: want RFC3339 (e.g. 2026-01-01T00:00:00Z)", start)
+				}
+				cfg.Start = t
+			}
+
+			if !verbose {
+				// The engines log every transition on the virtual clock;
+				// the report below carries the same information.
+				for _, sub := range []string{"signer", "rollover", "dns", "zones", "config"} {
+					tdns.SetSubsystemLevel(sub, slog.LevelError+4)
+				}
+			}
+
+			res, err := tdns.SimulateRollover(context.Background(), cfg)
+			if err != nil {
+				cliFatalf("simulation failed: %v", err)
+			}
+			renderSimulateReport(cfgPath, resolvedPol, pol, res, summary)
+		},
+	}
+	c.Flags().StringVarP(&tdns.Globals.Zonename, "zone", "z", "", "Zone")
+	c.Flags().StringVar(&serverConfig, "serverconfig", "", "Read this YAML file instead of asking the daemon (offline)")
+	c.Flags().StringVar(&policyName, "policy", "", "Simulate this dnssecpolicy instead of the zone's own")
+	c.Flags().StringVar(&horizon, "horizon", "400d", "How far ahead to simulate (e.g. 90d, 52w)")
+	c.Flags().StringVar(&start, "start", "", "Virtual start time, RFC3339 (default: now)")
+	c.Flags().StringVar(&step, "step", "", "Tick while a DS push is in flight (default: kasp.check_interval)")
+	c.Flags().StringVar(&idleStep, "idle-step", "", "Tick otherwise (default: 1h)")
+	c.Flags().StringVar(&dsDelay, "ds-delay", "", "Parent's push-to-publication delay (default: rollover.ds-publish-delay)")
+	c.Flags().StringVar(&parentDSTTL, "parent-ds-ttl", "", "Parent DS RRset TTL (default: ttls.parent-ds, else 24h)")
+	c.Flags().StringVar(&maxTTL, "max-ttl", "", "Largest TTL in the zone before clamping (default: the DNSKEY TTL)")
+	c.Flags().StringVar(&scheme, "scheme", "UPDATE", "Parent's DS update scheme: UPDATE or NOTIFY")
+	c.Flags().BoolVar(&summary, "summary", false, "Omit the timeline; print keys and findings only")
+	c.Flags().BoolVar(&verbose, "verbose", false, "Also show the engines' own log output")
+	_ = c.MarkFlagRequired("zone")
+	return c
+}
+
+// loadKaspFromYAMLFile returns dnssec.kasp from path: the KeyStateWorker
+// timings (propagation delay, check interval, standby counts) that the
+// simulation runs with.
+func loadKaspFromYAMLFile(path string) (tdns.KaspConf, error) {
+	var raw struct {
+		Dnssec struct {
+			Kasp tdns.KaspConf `yaml:"kasp"`
+		} `yaml:"dnssec"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tdns.KaspConf{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return tdns.KaspConf{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return raw.Dnssec.Kasp, nil
+}
+
+func renderSimulateReport(cfgPath, policyName string, pol *tdns.DnssecPolicy, res *tdns.RolloverSimResult, summary bool) {
+	const tf = "2006-01-02 15:04"
+	at := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format(tf)
+	}
+
+	fmt.Printf("Zone:    %s\n", res.Zone)
+	fmt.Printf("Policy:  %s  (from %s)\n", policyName, cfgPath)
+	fmt.Printf("Method:  %s, num-ds: %d, ksk.lifetime: %s, zsk.lifetime: %s\n",
+		rolloverMethodString(pol.Rollover.Method), pol.Rollover.NumDS,
+		time.Duration(pol.KSK.Lifetime)*time.Second, time.Duration(pol.ZSK.Lifetime)*time.Second)
+	fmt.Printf("Window:  %s → %s (%d ticks)\n", at(res.Start), at(res.End), res.Ticks)
+	fmt.Printf("Parent:  scheme=%s ds-delay=%s ds-ttl=%s; propagation-delay=%s\n",
+		res.Scheme, res.DSDelay, res.ParentDSTTL, res.PropagationDelay)
+	fmt.Println()
+
+	if !summary {
+		fmt.Println("Timeline:")
+		for _, e := range res.Events {
+			fmt.Printf("  %s  %-6s  %s\n", at(e.At), e.Kind, e.Detail)
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("Keys:")
+	fmt.Printf("  %-5s %-3s %-16s %-16s %-16s %-16s %-16s %-16s %-16s\n",
+		"keyid", "", "published", "ds-visible", "safe-from", "active", "retired", "safe-removal", "removed")
+	for _, k := range res.Keys {
+		note := ""
+		if k.Bootstrap {
+			note = "  (initial)"
+		}
+		fmt.Printf("  %-5d %-3s %-16s %-16s %-16s %-16s %-16s %-16s %-16s%s\n",
+			k.KeyID, k.Role, at(k.Published), at(k.DSVisible), at(k.SafeFrom), at(k.Active),
+			at(k.Retired), at(k.SafeRemoval), at(k.Removed), note)
+	}
+	fmt.Println()
+
+	bad := 0
+	if len(res.Findings) > 0 {
+		fmt.Println("Findings:")
+		for _, f := range res.Findings {
+			fmt.Printf("  %-6s  %s  %s\n", f.Severity, at(f.At), f.Message)
+			if f.Severity != tdns.RolloverSimWarn {
+				bad++
+			}
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Result: %d KSK roll(s), %d ZSK roll(s) in %s", res.KSKRolls, res.ZSKRolls, res.End.Sub(res.Start))
+	switch {
+	case bad > 0:
+		fmt.Printf("; %d finding(s) need attention.\n", bad)
+		os.Exit(1)
+	case len(res.Findings) > 0:
+		fmt.Printf("; %d warning(s).\n", len(res.Findings))
+	default:
+		fmt.Println("; no findings.")
+	}
+}
@@ -0,0 +1,341 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tdns "github.com/johanix/tdns/v2"
+	"github.com/miekg/dns"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// NewChangeSetCmd returns the 'changeset' subtree for the given daemon role:
+// staged, reviewable batches of zone content changes that are applied in one
+// publish, now or at a scheduled time, and can be rolled back in one step.
+func NewChangeSetCmd(role string) *cobra.Command {
+	var zone, name, comment, file, at, by string
+	var adds, deletes, deleteRRsets, replaces []string
+	var clear, force bool
+
+	csCmd := &cobra.Command{
+		Use:     "changeset",
+		Aliases: []string{"cs"},
+		Short:   "Stage, review, schedule and roll back batches of zone content changes",
+	}
+
+	zoneAndName := func(c *cobra.Command) {
+		c.Flags().StringVarP(&zone, "zone", "z", "", "zone name")
+		c.Flags().StringVarP(&name, "name", "n", "", "change set name")
+		c.MarkFlagRequired("zone")
+		c.MarkFlagRequired("name")
+	}
+	run := func(cp tdns.ChangeSetPost) tdns.ChangeSetResponse {
+		cp.Zone = dns.Fqdn(zone)
+		cp.Name = name
+		cp.By = by
+		return sendChangeSetCmd(role, cp)
+	}
+
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a draft change set and show its diff and validation",
+		Long: `Create a draft change set. Operations come from --file (a YAML or JSON list of
+{op, owner, type, rrs}) and/or the flags, which are applied in the order
+--delete, --delete-rrset, --replace, --add. RRs are in zone-file format and
+may use names relative to the zone. Consecutive --replace RRs for the same
+owner and type form one replacement RRset.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var ops []tdns.ChangeSetOp
+			if file != "" {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					cliFatalf("error reading %s: %v", file, err)
+				}
+				if err := yaml.Unmarshal(data, &ops); err != nil {
+					cliFatalf("error parsing %s: %v", file, err)
+				}
+			}
+			for _, rr := range deletes {
+				ops = append(ops, tdns.ChangeSetOp{Op: tdns.ChangeSetOpDelete, RRs: []string{rr}})
+			}
+			for _, spec := range deleteRRsets {
+				owner, rrtype, ok := strings.Cut(spec, "/")
+				if !ok {
+					cliFatalf("bad --delete-rrset %q: want owner/TYPE", spec)
+				}
+				ops = append(ops, tdns.ChangeSetOp{Op: tdns.ChangeSetOpDelete, Owner: owner, Type: rrtype})
+			}
+			ops = append(ops, groupReplaceOps(zone, replaces)...)
+			for _, rr := range adds {
+				ops = append(ops, tdns.ChangeSetOp{Op: tdns.ChangeSetOpAdd, RRs: []string{rr}})
+			}
+			if len(ops) == 0 {
+				cliFatalf("no changes given (use --file, --add, --delete, --delete-rrset or --replace)")
+			}
+			resp := run(tdns.ChangeSetPost{Command: "create", Comment: comment, Ops: ops})
+			fmt.Println(resp.Msg)
+			printChangeSetDiff(resp.Diff)
+			printChangeSetIssues(resp.Issues)
+		},
+	}
+	zoneAndName(createCmd)
+	createCmd.Flags().StringVar(&comment, "comment", "", "free-text description")
+	createCmd.Flags().StringVarP(&file, "file", "f", "", "YAML or JSON file with a list of operations")
+	createCmd.Flags().StringArrayVar(&adds, "add", nil, "add an RR (repeatable)")
+	createCmd.Flags().StringArrayVar(&deletes, "delete", nil, "delete an RR (repeatable)")
+	createCmd.Flags().StringArrayVar(&deleteRRsets, "delete-rrset", nil, "delete a whole RRset, as owner/TYPE (repeatable)")
+	createCmd.Flags().StringArrayVar(&replaces, "replace", nil, "replace an RRset with the given RRs (repeatable)")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List change sets, newest first",
+		Run: func(cmd *cobra.Command, args []string) {
+			cp := tdns.ChangeSetPost{Command: "list"}
+			if zone != "" {
+				cp.Zone = dns.Fqdn(zone)
+			}
+			resp := sendChangeSetCmd(role, cp)
+			if len(resp.ChangeSets) == 0 {
+				fmt.Println("No change sets.")
+				return
+			}
+			out := []string{"Zone|Name|State|Created|Activate at|Serial|Comment"}
+			for _, cs := range resp.ChangeSets {
+				serial := "-"
+				if cs.AppliedSerial != 0 {
+					serial = strconv.FormatUint(uint64(cs.AppliedSerial), 10)
+				}
+				out = append(out, fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s", cs.Zone, cs.Name, cs.State,
+					csTime(cs.CreatedAt), csTime(cs.ActivateAt), serial, dashIfEmpty(cs.Comment)))
+			}
+			fmt.Println(columnize.SimpleFormat(out))
+		},
+	}
+	listCmd.Flags().StringVarP(&zone, "zone", "z", "", "only change sets for this zone")
+
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show a change set with its diff and (if pending) validation against the current zone",
+		Run: func(cmd *cobra.Command, args []string) {
+			resp := run(tdns.ChangeSetPost{Command: "show"})
+			if len(resp.ChangeSets) == 1 {
+				printChangeSet(resp.ChangeSets[0])
+			}
+			printChangeSetDiff(resp.Diff)
+			printChangeSetIssues(resp.Issues)
+		},
+	}
+	zoneAndName(showCmd)
+
+	diffCmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show the change set as a diff against the published zone",
+		Run: func(cmd *cobra.Command, args []string) {
+			printChangeSetDiff(run(tdns.ChangeSetPost{Command: "diff"}).Diff)
+		},
+	}
+	zoneAndName(diffCmd)
+
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the change set against the published zone",
+		Run: func(cmd *cobra.Command, args []string) {
+			resp := run(tdns.ChangeSetPost{Command: "validate"})
+			fmt.Println(resp.Msg)
+			printChangeSetIssues(resp.Issues)
+		},
+	}
+	zoneAndName(validateCmd)
+
+	approveCmd := &cobra.Command{
+		Use:   "approve",
+		Short: "Approve a change set, optionally scheduling it with --at",
+		Run: func(cmd *cobra.Command, args []string) {
+			resp := run(tdns.ChangeSetPost{Command: "approve", ActivateAt: parseChangeSetTime(at)})
+			fmt.Println(resp.Msg)
+			printChangeSetIssues(resp.Issues)
+		},
+	}
+	zoneAndName(approveCmd)
+	approveCmd.Flags().StringVar(&at, "at", "", "activation time: RFC3339 time or duration from now (e.g. 2h)")
+
+	scheduleCmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Set (--at) or clear (--clear) the activation time of an approved change set",
+		Run: func(cmd *cobra.Command, args []string) {
+			if (at == "") == !clear {
+				cliFatalf("exactly one of --at and --clear is required")
+			}
+			fmt.Println(run(tdns.ChangeSetPost{Command: "schedule", ActivateAt: parseChangeSetTime(at)}).Msg)
+		},
+	}
+	zoneAndName(scheduleCmd)
+	scheduleCmd.Flags().StringVar(&at, "at", "", "activation time: RFC3339 time or duration from now (e.g. 2h)")
+	scheduleCmd.Flags().BoolVar(&clear, "clear", false, "unschedule; the change set must then be applied manually")
+
+	applyCmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Apply an approved change set now",
+		Run: func(cmd *cobra.Command, args []string) {
+			resp := run(tdns.ChangeSetPost{Command: "apply"})
+			fmt.Println(resp.Msg)
+			printChangeSetDiff(resp.Diff)
+		},
+	}
+	zoneAndName(applyCmd)
+
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Restore the RRsets an applied change set replaced",
+		Run: func(cmd *cobra.Command, args []string) {
+			resp := run(tdns.ChangeSetPost{Command: "rollback", Force: force})
+			fmt.Println(resp.Msg)
+			printChangeSetDiff(resp.Diff)
+		},
+	}
+	zoneAndName(rollbackCmd)
+	rollbackCmd.Flags().BoolVar(&force, "force", false, "roll back even RRsets that changed after the change set was applied")
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete a change set that is not currently applied",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println(run(tdns.ChangeSetPost{Command: "delete"}).Msg)
+		},
+	}
+	zoneAndName(deleteCmd)
+
+	for _, c := range []*cobra.Command{createCmd, approveCmd, scheduleCmd, applyCmd, rollbackCmd, deleteCmd} {
+		c.Flags().StringVar(&by, "by", "", "operator identity to record alongside the API client")
+	}
+	csCmd.AddCommand(createCmd, listCmd, showCmd, diffCmd, validateCmd, approveCmd, scheduleCmd, applyCmd, rollbackCmd, deleteCmd)
+	return csCmd
+}
+
+func sendChangeSetCmd(role string, cp tdns.ChangeSetPost) tdns.ChangeSetResponse {
+	api, err := GetApiClient(role, true)
+	if err != nil {
+		cliFatalf("error getting API client: %v", err)
+	}
+	status, body, err := api.RequestNG("POST", "/zone/changeset", cp, true)
+	if err != nil {
+		cliFatalf("error calling zone/changeset: %v", err)
+	}
+	var resp tdns.ChangeSetResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		cliFatalf("error decoding changeset response (status %d): %v", status, err)
+	}
+	if resp.Error {
+		printChangeSetIssues(resp.Issues)
+		cliFatalf("changeset %s: %s", cp.Command, resp.ErrorMsg)
+	}
+	return resp
+}
+
+// groupReplaceOps turns --replace RRs into replace ops, one per run of
+// consecutive RRs with the same owner and type.
+func groupReplaceOps(zone string, rrs []string) []tdns.ChangeSetOp {
+	var ops []tdns.ChangeSetOp
+	var lastKey string
+	for _, s := range rrs {
+		zp := dns.NewZoneParser(strings.NewReader(s), dns.Fqdn(zone), "")
+		rr, ok := zp.Next()
+		if !ok || zp.Err() != nil {
+			cliFatalf("bad --replace RR %q: %v", s, zp.Err())
+		}
+		key := strings.ToLower(rr.Header().Name) + "/" + dns.TypeToString[rr.Header().Rrtype]
+		if key == lastKey {
+			ops[len(ops)-1].RRs = append(ops[len(ops)-1].RRs, s)
+			continue
+		}
+		ops = append(ops, tdns.ChangeSetOp{Op: tdns.ChangeSetOpReplace, RRs: []string{s}})
+		lastKey = key
+	}
+	return ops
+}
+
+// parseChangeSetTime accepts an RFC3339 timestamp or a duration, which is
+// interpreted as that long from now. "" is the zero time.
+func parseChangeSetTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	d, err := time.ParseDuration(strings.TrimPrefix(s, "+"))
+	if err != nil {
+		cliFatalf("bad time %q: neither an RFC3339 time nor a duration", s)
+	}
+	return time.Now().Add(d)
+}
+
+func csTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func printChangeSet(cs tdns.ChangeSet) {
+	out := []string{
+		"Zone:|" + cs.Zone,
+		"Name:|" + cs.Name,
+		"State:|" + cs.State,
+		"Comment:|" + dashIfEmpty(cs.Comment),
+		"Created:|" + csTime(cs.CreatedAt) + " by " + dashIfEmpty(cs.CreatedBy),
+	}
+	if !cs.ApprovedAt.IsZero() {
+		out = append(out, fmt.Sprintf("Approved:|%s by %s (serial %d)", csTime(cs.ApprovedAt), cs.ApprovedBy, cs.ApprovedSerial))
+	}
+	if !cs.ActivateAt.IsZero() {
+		out = append(out, "Activate at:|"+csTime(cs.ActivateAt))
+	}
+	if !cs.AppliedAt.IsZero() {
+		out = append(out, fmt.Sprintf("Applied:|%s (serial %d)", csTime(cs.AppliedAt), cs.AppliedSerial))
+	}
+	if !cs.RolledBackAt.IsZero() {
+		out = append(out, "Rolled back:|"+csTime(cs.RolledBackAt))
+	}
+	if cs.Error != "" {
+		out = append(out, "Error:|"+cs.Error)
+	}
+	fmt.Println(columnize.SimpleFormat(out))
+}
+
+func printChangeSetDiff(diff []tdns.ChangeSetDiff) {
+	for _, d := range diff {
+		fmt.Printf("\n%s %s\n", d.Owner, d.Type)
+		if len(d.Removed) == 0 && len(d.Added) == 0 {
+			fmt.Println("  (no change)")
+			continue
+		}
+		for _, rr := range d.Removed {
+			fmt.Printf("- %s\n", rr)
+		}
+		for _, rr := range d.Added {
+			fmt.Printf("+ %s\n", rr)
+		}
+	}
+}
+
+func printChangeSetIssues(issues []tdns.ChangeSetIssue) {
+	if len(issues) == 0 {
+		return
+	}
+	fmt.Println()
+	out := []string{"Severity|Owner|Type|Issue"}
+	for _, is := range issues {
+		out = append(out, fmt.Sprintf("%s|%s|%s|%s", is.Severity, dashIfEmpty(is.Owner), dashIfEmpty(is.Type), is.Msg))
+	}
+	fmt.Println(columnize.SimpleFormat(out))
+}
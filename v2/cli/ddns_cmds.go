@@ -11,6 +11,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	tdns "github.com/johanix/tdns/v2"
 	"github.com/ryanuber/columnize"
@@ -215,8 +216,65 @@ var delExportCmd = &cobra.Command{
 	},
 }
 
+var delQueueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Show the outbox of a delegation backend that pushes to a registry (e.g. epp)",
+	Run: func(cmd *cobra.Command, args []string) {
+		PrepArgs("zonename")
+		all, _ := cmd.Flags().GetBool("all")
+
+		api, err := GetApiClient("auth", true)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		dr, err := SendDelegationCmd(api, tdns.DelegationPost{
+			Command: "queue",
+			Zone:    tdns.Globals.Zonename,
+			All:     all,
+		})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(dr.Msg)
+		if len(dr.Queue) == 0 {
+			return
+		}
+		out := []string{"ID|Child|State|Attempts|Queued|Updated|Last error"}
+		for _, it := range dr.Queue {
+			out = append(out, fmt.Sprintf("%d|%s|%s|%d|%s|%s|%s", it.ID, it.Child, it.State, it.Attempts,
+				it.Created.Format(time.RFC3339), it.Updated.Format(time.RFC3339), it.LastError))
+		}
+		fmt.Printf("%s\n", columnize.SimpleFormat(out))
+	},
+}
+
+var delRetryCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "Requeue the failed outbox items of a delegation backend that pushes to a registry",
+	Run: func(cmd *cobra.Command, args []string) {
+		PrepArgs("zonename")
+
+		api, err := GetApiClient("auth", true)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		dr, err := SendDelegationCmd(api, tdns.DelegationPost{
+			Command: "retry",
+			Zone:    tdns.Globals.Zonename,
+		})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(dr.Msg)
+	},
+}
+
 func init() {
-	DelCmd.AddCommand(delStatusCmd, delSyncCmd, delExportCmd)
+	DelCmd.AddCommand(delStatusCmd, delSyncCmd, delExportCmd, delQueueCmd, delRetryCmd)
+	delQueueCmd.Flags().Bool("all", false, "Include delivered items")
 	delSyncCmd.Flags().StringVarP(&schemestr, "scheme", "S", "", "Scheme to use for synchronization of delegation")
 
 	delSyncCmd.MarkFlagRequired("zone")
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	tdns "github.com/johanix/tdns/v2"
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+)
+
+// NewEventsCmd returns the 'events' subtree for the given daemon role.
+// 'follow' subscribes to the daemon's server-sent event stream and renders
+// zone, key, rollover, delegation-sync and catalog state changes as they
+// happen, reconnecting (and resuming via Last-Event-ID) if the stream drops.
+func NewEventsCmd(role string) *cobra.Command {
+	var types, zones []string
+	var asJSON, noReconnect bool
+	var fromID uint64
+
+	eventsCmd := &cobra.Command{
+		Use:   "events",
+		Short: fmt.Sprintf("Stream state-change events from the %s daemon", role),
+	}
+
+	followCmd := &cobra.Command{
+		Use:   "follow",
+		Short: "Follow the event stream live (Ctrl-C to stop)",
+		Long: "Follow the event stream live. Event types: " + strings.Join(tdns.EventTypes, ", ") +
+			". A --type also matches its dotted sub-types, so --type zone selects every zone.* event.",
+		Run: func(cmd *cobra.Command, args []string) {
+			api, err := GetApiClient(role, true)
+			if err != nil {
+				cliFatalf("error getting API client: %v", err)
+			}
+			q := url.Values{}
+			for _, t := range types {
+				q.Add("type", t)
+			}
+			for _, z := range zones {
+				q.Add("zone", dns.Fqdn(z))
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			enc := json.NewEncoder(os.Stdout)
+			render := func(ev tdns.Event) error {
+				if asJSON {
+					return enc.Encode(ev)
+				}
+				fmt.Println(formatEvent(ev))
+				return nil
+			}
+
+			lastID := fromID
+			for {
+				err := api.FollowEvents(ctx, q, &lastID, render)
+				if ctx.Err() != nil {
+					return
+				}
+				if noReconnect {
+					if err != nil {
+						cliFatalf("event stream: %v", err)
+					}
+					return
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "event stream interrupted: %v (reconnecting)\n", err)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(2 * time.Second):
+				}
+			}
+		},
+	}
+	followCmd.Flags().StringSliceVarP(&types, "type", "t", nil, "only these event types (repeatable or comma-separated)")
+	followCmd.Flags().StringSliceVarP(&zones, "zone", "z", nil, "only events for these zones (repeatable or comma-separated)")
+	followCmd.Flags().BoolVar(&asJSON, "json", false, "print events as JSON lines")
+	followCmd.Flags().BoolVar(&noReconnect, "no-reconnect", false, "exit when the stream ends instead of reconnecting")
+	followCmd.Flags().Uint64Var(&fromID, "from-id", 0, "replay retained events after this event id before following")
+
+	eventsCmd.AddCommand(followCmd)
+	return eventsCmd
+}
+
+// formatEvent renders one event on one line: time, type, zone, then the
+// payload as sorted key=value pairs.
+func formatEvent(ev tdns.Event) string {
+	if ev.Type == tdns.EventDropped {
+		return fmt.Sprintf("%s  -- %v events dropped (client too slow) --", time.Now().Format("15:04:05"), ev.Data["count"])
+	}
+	keys := make([]string, 0, len(ev.Data))
+	for k := range ev.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var kv []string
+	for _, k := range keys {
+		v := ev.Data[k]
+		if s, ok := v.(string); ok && s == "" {
+			continue
+		}
+		kv = append(kv, fmt.Sprintf("%s=%v", k, v))
+	}
+	return fmt.Sprintf("%s  %-28s %-24s %s", ev.Time.Local().Format("15:04:05"), ev.Type, dashIfEmpty(ev.Zone), strings.Join(kv, " "))
+}
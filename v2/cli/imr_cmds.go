@@ -325,6 +325,18 @@ func newFlushRunner(keepStructural bool) func(cmd *cobra.Command, args []string)
 	}
 }
 
+var imrShowTaCmd = &cobra.Command{
+	Use:   "ta",
+	Short: "Show the trust anchors and their RFC 5011 key states",
+	Run: func(cmd *cobra.Command, args []string) {
+		if Conf.Internal.ImrEngine == nil {
+			fmt.Println("IMR engine is not initialized")
+			return
+		}
+		printTrustAnchors(Conf.Internal.ImrEngine.TrustAnchors())
+	},
+}
+
 var imrShowConfigCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Show running IMR configuration summary",
@@ -375,6 +387,27 @@ var imrShowConfigCmd = &cobra.Command{
 				fmt.Printf("    %s -> %s\n", stub.Zone, strings.Join(servers, "; "))
 			}
 		}
+
+		if len(Conf.Imr.Forwards) == 0 {
+			fmt.Println("  Forward zones: (none)")
+		} else {
+			fmt.Println("  Forward zones:")
+			for _, fwd := range Conf.Imr.Forwards {
+				var servers []string
+				for _, f := range fwd.Forwarders {
+					transports := f.Transports
+					if len(transports) == 0 {
+						transports = []string{"do53"}
+					}
+					servers = append(servers, fmt.Sprintf("%s [%s]", strings.Join(f.Addrs, ", "), strings.Join(transports, ",")))
+				}
+				mode := "only"
+				if fwd.First {
+					mode = "first"
+				}
+				fmt.Printf("    %s (forward %s) -> %s\n", fwd.Zone, mode, strings.Join(servers, "; "))
+			}
+		}
 	},
 }
 
@@ -521,12 +554,16 @@ func init() {
 	ImrStatsCmd.AddCommand(imrStatsAuthServersCmd)
 	ImrShowCmd.AddCommand(imrShowOptionsCmd)
 	ImrShowCmd.AddCommand(imrShowConfigCmd)
+	ImrShowCmd.AddCommand(imrShowTaCmd)
 	ImrFlushCmd.AddCommand(imrFlushCommonCmd, imrFlushAllCmd)
 	ImrSetCmd.AddCommand(imrSetLineWidthCmd)
 
 	// Add all IMR subcommands to ImrCmd
 	ImrCmd.AddCommand(ImrQueryCmd, ImrZoneCmd, ImrStatsCmd, ImrShowCmd, ImrFlushCmd, ImrSetCmd)
 
+	// NTA management goes to the daemon's /imr API, like the agent/auth variants
+	ImrCmd.AddCommand(newImrNtaCmd("imr"), newImrLocalZoneCmd("imr"), newImrLocalDataCmd("imr"), newImrExplainCmd("imr"))
+
 	// Add ping and daemon commands to ImrCmd (NewPingCmd/NewDaemonCmd are defined elsewhere)
 	ImrCmd.AddCommand(NewPingCmd("imr"))
 	ImrCmd.AddCommand(NewDaemonCmd("imr"))
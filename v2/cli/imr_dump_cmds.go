@@ -447,6 +447,24 @@ repeated failures.`,
 	},
 }
 
+var dumpLocalZonesCmd = &cobra.Command{
+	Use:   "local-zones",
+	Short: "List the local zones and their local data",
+	Run: func(cmd *cobra.Command, args []string) {
+		imr := tdns.Globals.ImrEngine
+		if imr == nil {
+			fmt.Println("IMR engine not initialised")
+			return
+		}
+		zones := imr.LocalZones()
+		if len(zones) == 0 {
+			fmt.Println("No local zones")
+			return
+		}
+		PrintLocalZones(zones)
+	},
+}
+
 var dumpZoneServersCmd = &cobra.Command{
 	Use:   "servers [zone]",
 	Short: "List auth servers for a specific zone (verbose)",
@@ -940,7 +958,7 @@ func formatDuration(d time.Duration) string {
 // It attaches dumpSuffixCmd, dumpServersCmd, dumpAuthServersCmd, dumpKeysCmd, dumpDnskeysCmd, dumpZoneCmd, and dumpZonesCmd to ImrDumpCmd, adds the keys/servers/errors subcommands under auth-servers, and attaches the zone servers subcommand under zone.
 func init() {
 	// rootCmd.AddCommand(ImrDumpCmd)
-	ImrDumpCmd.AddCommand(dumpSuffixCmd, dumpServersCmd, dumpAuthServersCmd, dumpKeysCmd, dumpDnskeysCmd, dumpZoneCmd, dumpZonesCmd, dumpTuningCmd, dumpDiscoveryCmd)
+	ImrDumpCmd.AddCommand(dumpSuffixCmd, dumpServersCmd, dumpAuthServersCmd, dumpKeysCmd, dumpDnskeysCmd, dumpZoneCmd, dumpZonesCmd, dumpTuningCmd, dumpDiscoveryCmd, dumpLocalZonesCmd)
 	dumpAuthServersCmd.AddCommand(newDumpKeysCmd(), newDumpServersCmd(), dumpAuthServersErrorsCmd)
 	dumpZoneCmd.AddCommand(dumpZoneServersCmd, dumpZoneBackoffsCmd)
 }
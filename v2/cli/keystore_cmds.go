@@ -31,7 +31,8 @@ func NewKeystoreCmd(role string) *cobra.Command {
 	c := &cobra.Command{
 		Use:   "keystore",
 		Short: "Prefix command to access different features of the keystore",
-		Long: `The keystore holds SIG(0), DNSSEC, and global TSIG keys.
+		Long: `The keystore holds SIG(0), DNSSEC, and global TSIG keys, plus
+(auth only) the EPP registrar credentials of delegation backends.
 The CLI contains functions for listing, adding, deleting, and
 changing the state of keys.`,
 		Run: func(cmd *cobra.Command, args []string) {
@@ -39,7 +40,11 @@ changing the state of keys.`,
 		},
 	}
 
-	c.AddCommand(newKeystoreSig0Cmd(role), newKeystoreDnssecCmd(role), newKeystoreTsigCmd(role))
+	c.AddCommand(newKeystoreSig0Cmd(role), newKeystoreDnssecCmd(role), newKeystoreTsigCmd(role), newKeystoreKskGenerateCmd(), newKeystoreKskSignCmd())
+	if role == "auth" {
+		// EPP credentials are only used by the auth server's delegation backends.
+		c.AddCommand(newKeystoreEppCmd(role))
+	}
 	return c
 }
 
@@ -798,7 +803,8 @@ without modifying anything. Pass --force to actually delete.`,
 
 	// auto-rollover moved to `zone dnssec auto-rollover` (auth only; agents never
 	// sign, so it was vestigial under `agent keystore dnssec`).
-	c.AddCommand(add, importCmd, generate, algorithms, policies, list, export, delete, setstate, genDS, rollover, clear, policyCleanup, purge, newKeystoreDnssecPolicyCmd(role), newKeystoreDnssecDsPushCmd(role), newKeystoreDnssecQueryParentCmd(role))
+	c.AddCommand(add, importCmd, generate, algorithms, policies, list, export, delete, setstate, genDS, rollover, clear, policyCleanup, purge, newKeystoreDnssecPolicyCmd(role), newKeystoreDnssecDsPushCmd(role), newKeystoreDnssecQueryParentCmd(role),
+		newKeystoreDnssecKsrExportCmd(role), newKeystoreDnssecSkrImportCmd(role), newKeystoreDnssecSkrListCmd(role))
 	addBulkCommands(c, role, "dnssec")
 	return c
 }
@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johani@johani.org
+ */
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	tdns "github.com/johanix/tdns/v2"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+// newKeystoreEppCmd manages the registrar logins used by "epp" delegation
+// backends (see delegationbackends in the server config).
+func newKeystoreEppCmd(role string) *cobra.Command {
+	var name, clid, password, passwordFile, comment string
+	var yes bool
+
+	c := &cobra.Command{
+		Use:   "epp",
+		Short: "Manage EPP registrar credentials in the keystore",
+		Long: `EPP credentials are the registrar client id and password an "epp"
+delegation backend logs in with. A backend uses the credential named by its
+"credentials" setting, or the one with the backend's own name. Passwords are
+never shown; a changed password is used from the next EPP session on.`,
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List EPP credentials (no passwords)",
+		Run: func(cmd *cobra.Command, args []string) {
+			eppKeyMgmt(role, tdns.KeystorePost{SubCommand: "list"})
+		},
+	}
+
+	add := &cobra.Command{
+		Use:   "add",
+		Short: "Add or replace an EPP credential",
+		Run: func(cmd *cobra.Command, args []string) {
+			if password != "" && passwordFile != "" {
+				fmt.Println("Error: set only one of --password or --password-file")
+				os.Exit(1)
+			}
+			pw, err := resolveTsigSecret(password, passwordFile)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if pw == "" {
+				fmt.Println("Error: set exactly one of --password or --password-file")
+				os.Exit(1)
+			}
+			eppKeyMgmt(role, tdns.KeystorePost{SubCommand: "add", EppName: name, EppClid: clid, EppPassword: pw, EppComment: comment})
+		},
+	}
+	add.Flags().StringVar(&name, "name", "", "Credential name (normally the delegation backend name)")
+	add.Flags().StringVar(&clid, "clid", "", "EPP client id (registrar login)")
+	add.Flags().StringVar(&passwordFile, "password-file", "", "File containing the EPP password; preferred over --password")
+	add.Flags().StringVar(&password, "password", "", "Inline EPP password. WARNING: visible in shell history / process list; prefer --password-file")
+	add.Flags().StringVar(&comment, "comment", "", "Free-text comment")
+	add.MarkFlagRequired("name")
+	add.MarkFlagRequired("clid")
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete an EPP credential",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !yes {
+				fmt.Printf("Delete EPP credential %q? [y/N] ", name)
+				var ans string
+				fmt.Scanln(&ans)
+				if ans != "y" && ans != "Y" && ans != "yes" {
+					fmt.Println("Aborted.")
+					return
+				}
+			}
+			eppKeyMgmt(role, tdns.KeystorePost{SubCommand: "delete", EppName: name})
+		},
+	}
+	deleteCmd.Flags().StringVar(&name, "name", "", "Credential name")
+	deleteCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation prompt")
+	deleteCmd.MarkFlagRequired("name")
+
+	c.AddCommand(list, add, deleteCmd)
+	return c
+}
+
+func eppKeyMgmt(role string, data tdns.KeystorePost) {
+	data.Command = "epp-mgmt"
+	data.Creator = "tdns-cli"
+
+	api, err := GetApiClient(role, true)
+	if err != nil {
+		fmt.Printf("Error creating API client: %v\n", err)
+		os.Exit(1)
+	}
+	tr, err := SendKeystoreCmd(api, data)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if tr.Error {
+		fmt.Printf("Error from server: %s\n", tr.ErrorMsg)
+		os.Exit(1)
+	}
+
+	if data.SubCommand == "list" {
+		var out []string
+		if tdns.Globals.ShowHeaders {
+			out = append(out, "Name|Client ID|Created|Comment")
+		}
+		for _, e := range tr.EppCredentials {
+			out = append(out, fmt.Sprintf("%s|%s|%s|%s", e.Name, e.ClID, e.Created, e.Comment))
+		}
+		fmt.Println(columnize.SimpleFormat(out))
+		return
+	}
+	if tr.Msg != "" {
+		fmt.Println(tr.Msg)
+	}
+}
@@ -958,6 +958,12 @@ func printZoneGlobalHeader(s *tdns.RolloverStatus, verbose bool) {
 		// ASCII "->" (not the Unicode arrow); spell out "algorithm"; describe
 		// the count as published ZSKs (the live keys in the DNSKEY RRset).
 		line := fmt.Sprintf("Algorithm rollover: %s %s -> %s  (in progress)", t.Role, t.FromAlg, t.ToAlg)
+		switch {
+		case t.Stage == "refused":
+			line = fmt.Sprintf("Algorithm rollover: %s %s -> %s  REFUSED by parent (%s); staying on %s", t.Role, t.FromAlg, t.ToAlg, t.Detail, t.FromAlg)
+		case t.Stage != "":
+			line = fmt.Sprintf("Algorithm rollover: %s %s -> %s  (in progress, stage %s)", t.Role, t.FromAlg, t.ToAlg, t.Stage)
+		}
 		if t.Total > 0 {
 			line += fmt.Sprintf(", %d of %d published ZSKs on new algorithm", t.Done, t.Total)
 		}
@@ -1743,7 +1749,8 @@ func newAutoRolloverCmd(_ string) *cobra.Command {
   status    — print phase + per-key state for the zone
   reset     — clear last_rollover_error on one key after operator action
   unstick   — skip the softfail-delay and probe the parent on the next tick
-  validate  — re-parse policy from YAML and report which §4 invariants pass/fail`,
+  validate  — re-parse policy from YAML and report which §4 invariants pass/fail
+  simulate  — run the rollover engines on a virtual clock and report the timeline`,
 	}
 	// Persistent --ksk / --zsk filter flags inherited by every
 	// subcommand. Most subcommands ignore them; status and when use
@@ -1762,6 +1769,7 @@ func newAutoRolloverCmd(_ string) *cobra.Command {
 		newAutoRolloverResetCmd(),
 		newAutoRolloverUnstickCmd(),
 		newAutoRolloverValidateCmd(),
+		newAutoRolloverSimulateCmd(),
 	)
 	return c
 }
@@ -1773,7 +1781,7 @@ func newAutoRolloverCmd(_ string) *cobra.Command {
 func newAutoRolloverPolicyChangeCmd() *cobra.Command {
 	c := &cobra.Command{
 		Use:   "policy-change",
-		Short: "Bind a zone to a new DNSSEC policy for a gradual ZSK algorithm rollover",
+		Short: "Bind a zone to a new DNSSEC policy for a ZSK or KSK algorithm rollover",
 		Long: `Bind a zone toward a new DNSSEC policy so its ZSK algorithm rolls over
 GRADUALLY. Unlike "zone dnssec policy-set" (which retires the old key
 synchronously — unsafe for an algorithm change), this only sets the
@@ -1788,8 +1796,14 @@ rolls on the normal ZSK cadence — OR run
 to promote the next standby now (repeat to accelerate through the
 already-propagated old-alg standbys to the new algorithm).
 
-Requires dnssec.completeness: relaxed. A KSK / CSK / both-role algorithm
-change, or a second policy-change while a roll is in flight, is refused.`,
+Requires dnssec.completeness: relaxed. A CSK / both-role algorithm change,
+or a second policy-change while a roll is in flight, is refused.
+
+A KSK algorithm change is run by the auto-rollover engine instead: the new
+KSK double-signs the DNSKEY RRset, the parent DS moves to {old, new} and
+then {new}, and the old KSK is removed. It requires a multi-ds policy with
+a parent-agent, and is refused at runtime if the parent will not publish a
+DS for the new algorithm.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			PrepArgs(cmd, "zonename")
 			tdns.Globals.App.Type = tdns.AppTypeCli
@@ -0,0 +1,295 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/johanix/tdns/v2"
+	"github.com/miekg/dns"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+// Offline KSK (policy ksk.offline). The daemon side is ksr-export,
+// skr-import and skr-list under "keystore dnssec"; the air-gapped side is
+// "keystore ksk-generate" and "keystore ksk-sign", which work directly on a
+// separate keystore file and never talk to a daemon.
+
+var ksrPeriods int
+var ksrPeriod, ksrOutfile, offlineKeystore, ksrInfile string
+var ksrCds bool
+
+func newKeystoreDnssecKsrExportCmd(role string) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "ksr-export",
+		Short: "Export a Key Signing Request for a zone with an offline KSK",
+		Long: `Export a Key Signing Request (KSR) for a zone whose policy has ksk.offline.
+The KSR lists, for each of --periods signing periods of --period, the ZSKs
+the zone will publish; it starts where the imported SKR bundles end (or
+now). ZSKs needed for rolls within the request are generated. Sign the KSR
+with "keystore ksk-sign" against the offline keystore and import the result
+with "keystore dnssec skr-import".`,
+		Run: func(cmd *cobra.Command, args []string) {
+			PrepArgs("zonename")
+			if ksrOutfile == "" {
+				log.Fatalf("Error: --out is required")
+			}
+			tr := ksrKeystorePost(role, tdns.KeystorePost{
+				Command:    "dnssec-mgmt",
+				SubCommand: "ksr-export",
+				Zone:       tdns.Globals.Zonename,
+				KsrPeriods: ksrPeriods,
+				KsrPeriod:  ksrPeriod,
+			})
+			if tr.Ksr == nil {
+				log.Fatalf("Error: the daemon returned no KSR")
+			}
+			data, err := json.MarshalIndent(tr.Ksr, "", "  ")
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			if err := writeNewFile(ksrOutfile, append(data, '\n'), 0644); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			fmt.Println(tr.Msg)
+		},
+	}
+	c.Flags().StringVarP(&tdns.Globals.Zonename, "zone", "z", "", "Zone to export a KSR for")
+	c.Flags().IntVarP(&ksrPeriods, "periods", "", 0, "Number of signing periods (default 9)")
+	c.Flags().StringVarP(&ksrPeriod, "period", "", "", "Length of a signing period (default 10d)")
+	c.Flags().StringVarP(&ksrOutfile, "out", "o", "", "File to write the KSR to")
+	c.MarkFlagRequired("zone")
+	return c
+}
+
+func newKeystoreDnssecSkrImportCmd(role string) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "skr-import",
+		Short: "Import a Signed Key Response for a zone with an offline KSK",
+		Run: func(cmd *cobra.Command, args []string) {
+			PrepArgs("zonename")
+			skr, err := readSkrFile(ksrInfile)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			tr := ksrKeystorePost(role, tdns.KeystorePost{
+				Command:    "dnssec-mgmt",
+				SubCommand: "skr-import",
+				Zone:       tdns.Globals.Zonename,
+				Skr:        skr,
+			})
+			fmt.Println(tr.Msg)
+		},
+	}
+	c.Flags().StringVarP(&tdns.Globals.Zonename, "zone", "z", "", "Zone to import the SKR for")
+	c.Flags().StringVarP(&ksrInfile, "file", "f", "", "File containing the SKR")
+	c.MarkFlagRequired("zone")
+	c.MarkFlagRequired("file")
+	return c
+}
+
+func newKeystoreDnssecSkrListCmd(role string) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "skr-list",
+		Short: "List the imported SKR bundles of a zone with an offline KSK",
+		Run: func(cmd *cobra.Command, args []string) {
+			PrepArgs("zonename")
+			tr := ksrKeystorePost(role, tdns.KeystorePost{
+				Command:    "dnssec-mgmt",
+				SubCommand: "skr-list",
+				Zone:       tdns.Globals.Zonename,
+			})
+			if len(tr.SkrBundles) == 0 {
+				fmt.Printf("No SKR bundles imported for zone %s\n", dns.Fqdn(tdns.Globals.Zonename))
+				return
+			}
+			out := []string{"Inception|Expiration|Request|KSKs|ZSKs|CDS|Current"}
+			for _, b := range tr.SkrBundles {
+				current := ""
+				if b.Current {
+					current = "*"
+				}
+				out = append(out, fmt.Sprintf("%s|%s|%s|%s|%s|%v|%s",
+					b.Inception.Format(time.RFC3339), b.Expiration.Format(time.RFC3339), b.RequestId,
+					keytagList(b.KSKs), keytagList(b.ZSKs), b.CDS, current))
+			}
+			fmt.Println(columnize.SimpleFormat(out))
+		},
+	}
+	c.Flags().StringVarP(&tdns.Globals.Zonename, "zone", "z", "", "Zone to list SKR bundles for")
+	c.MarkFlagRequired("zone")
+	return c
+}
+
+func newKeystoreKskGenerateCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "ksk-generate",
+		Short: "Generate an active KSK in an offline keystore and print its DS",
+		Long: `Generate a KSK directly in the offline keystore file given by --keystore
+(created if missing), for use with "keystore ksk-sign". No daemon is involved.
+The DS records printed go to the parent.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			PrepArgs("zonename")
+			alg, ok := dns.StringToAlgorithm[strings.ToUpper(tdns.Globals.Algorithm)]
+			if !ok {
+				log.Fatalf("Error: unknown algorithm %q", tdns.Globals.Algorithm)
+			}
+			kdb := openOfflineKeystore(true)
+			defer kdb.Close()
+			pkc, _, err := kdb.GenerateKeypair(tdns.Globals.Zonename, "ksk-generate", tdns.DnskeyStateActive, dns.TypeDNSKEY, alg, "KSK", nil)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			fmt.Printf("Generated KSK %d for zone %s:\n%s\n", pkc.KeyId, tdns.Globals.Zonename, pkc.DnskeyRR.String())
+			for _, digest := range []uint8{dns.SHA256, dns.SHA384} {
+				if ds := pkc.DnskeyRR.ToDS(digest); ds != nil {
+					fmt.Println(ds.String())
+				}
+			}
+		},
+	}
+	c.Flags().StringVarP(&tdns.Globals.Zonename, "zone", "z", "", "Zone to generate a KSK for")
+	c.Flags().StringVarP(&tdns.Globals.Algorithm, "algorithm", "a", "ECDSAP256SHA256", "KSK algorithm")
+	c.Flags().StringVarP(&offlineKeystore, "keystore", "", "", "Offline keystore (sqlite file)")
+	c.MarkFlagRequired("zone")
+	c.MarkFlagRequired("keystore")
+	return c
+}
+
+func newKeystoreKskSignCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "ksk-sign",
+		Short: "Sign a Key Signing Request with the KSKs of an offline keystore",
+		Long: `Sign a Key Signing Request exported by "keystore dnssec ksr-export" with the
+zone's active KSKs in the offline keystore given by --keystore, producing a
+Signed Key Response for "keystore dnssec skr-import". Published KSKs in the
+offline keystore are included in the DNSKEY RRset unsigned (pre-publication
+of the next KSK). With --cds the bundles also carry signed CDS and CDNSKEY
+RRsets for the active KSKs.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if ksrOutfile == "" {
+				log.Fatalf("Error: --out is required")
+			}
+			data, err := os.ReadFile(ksrInfile)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			var ksr tdns.KeySigningRequest
+			if err := json.Unmarshal(data, &ksr); err != nil {
+				log.Fatalf("Error: %s is not a KSR: %v", ksrInfile, err)
+			}
+			zone := dns.Fqdn(ksr.Zone)
+
+			kdb := openOfflineKeystore(false)
+			defer kdb.Close()
+			active, err := kdb.GetDnssecKeys(zone, tdns.DnskeyStateActive)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			published, err := kdb.GetDnssecKeys(zone, tdns.DnskeyStatePublished)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			for _, ksk := range active.KSKs {
+				if alg := dns.AlgorithmToString[ksk.Algorithm]; ksr.Algorithm != "" && alg != ksr.Algorithm {
+					fmt.Printf("Warning: KSK %d uses %s, the zone's policy asks for %s\n", ksk.KeyId, alg, ksr.Algorithm)
+				}
+			}
+			var extra []dns.DNSKEY
+			for _, ksk := range published.KSKs {
+				extra = append(extra, ksk.DnskeyRR)
+			}
+
+			skr, err := tdns.SignKSR(&ksr, active.KSKs, extra, ksrCds)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			out, err := json.MarshalIndent(skr, "", "  ")
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			if err := writeNewFile(ksrOutfile, append(out, '\n'), 0644); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			first, last := skr.Bundles[0], skr.Bundles[len(skr.Bundles)-1]
+			fmt.Printf("SKR %s for %s: %d bundles, %s to %s, signed by KSK(s) %s\n", skr.RequestId, zone, len(skr.Bundles),
+				first.Inception.Format(time.RFC3339), last.Expiration.Format(time.RFC3339), keytagList(pkcKeyids(active.KSKs)))
+		},
+	}
+	c.Flags().StringVarP(&offlineKeystore, "keystore", "", "", "Offline keystore (sqlite file)")
+	c.Flags().StringVarP(&ksrInfile, "ksr", "", "", "File containing the KSR")
+	c.Flags().StringVarP(&ksrOutfile, "out", "o", "", "File to write the SKR to")
+	c.Flags().BoolVarP(&ksrCds, "cds", "", false, "Include signed CDS and CDNSKEY RRsets")
+	c.MarkFlagRequired("keystore")
+	c.MarkFlagRequired("ksr")
+	return c
+}
+
+// openOfflineKeystore opens the keystore given by --keystore, creating the
+// file first if create is set.
+func openOfflineKeystore(create bool) *tdns.KeyDB {
+	if create {
+		f, err := os.OpenFile(offlineKeystore, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		f.Close()
+	} else if _, err := os.Stat(offlineKeystore); err != nil {
+		log.Fatalf("Error: offline keystore: %v", err)
+	}
+	kdb, err := tdns.NewKeyDB(offlineKeystore, false, nil)
+	if err != nil {
+		log.Fatalf("Error: offline keystore: %v", err)
+	}
+	return kdb
+}
+
+func readSkrFile(path string) (*tdns.SignedKeyResponse, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var skr tdns.SignedKeyResponse
+	if err := json.Unmarshal(data, &skr); err != nil {
+		return nil, fmt.Errorf("%s is not an SKR: %v", path, err)
+	}
+	return &skr, nil
+}
+
+func ksrKeystorePost(role string, post tdns.KeystorePost) tdns.KeystoreResponse {
+	api, err := GetApiClient(role, true)
+	if err != nil {
+		log.Fatalf("Error creating API client: %v", err)
+	}
+	tr, err := SendKeystoreCmd(api, post)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if tr.Error {
+		log.Fatalf("Error from the daemon: %s", tr.ErrorMsg)
+	}
+	return tr
+}
+
+func pkcKeyids(keys []*tdns.PrivateKeyCache) []uint16 {
+	var out []uint16
+	for _, k := range keys {
+		out = append(out, k.KeyId)
+	}
+	return out
+}
+
+func keytagList(tags []uint16) string {
+	var s []string
+	for _, t := range tags {
+		s = append(s, fmt.Sprintf("%d", t))
+	}
+	return strings.Join(s, ",")
+}
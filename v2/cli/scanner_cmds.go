@@ -9,8 +9,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/johanix/tdns/v2"
 	"github.com/miekg/dns"
@@ -119,285 +121,380 @@ var ScanCdsCmd = &cobra.Command{
 	},
 }
 
-var StatusCmd = &cobra.Command{
-	Use:   "status [job-id]",
-	Short: "Get status of scan job(s)",
-	Long:  `Get status of a specific scan job by job ID, or list all jobs if no job ID is provided`,
-	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		// Get API client for scanner
-		api, err := GetApiClient("scanner", true)
-		if err != nil {
-			log.Fatalf("Error getting API client for scanner: %v", err)
-		}
+var StatusCmd = newScannerStatusCmd("scanner")
+
+func newScannerStatusCmd(role string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status [job-id]",
+		Short: "Get status of scan job(s)",
+		Long:  `Get status of a specific scan job by job ID, or list all jobs if no job ID is provided`,
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Get API client for scanner
+			api, err := GetApiClient(role, true)
+			if err != nil {
+				log.Fatalf("Error getting API client for scanner: %v", err)
+			}
 
-		var endpoint string
-		if len(args) > 0 {
-			// Specific job
-			endpoint = fmt.Sprintf("/scanner/status?job_id=%s", args[0])
-		} else {
-			// All jobs
-			endpoint = "/scanner/status"
-		}
+			var endpoint string
+			if len(args) > 0 {
+				// Specific job
+				endpoint = fmt.Sprintf("/scanner/status?job_id=%s", args[0])
+			} else {
+				// All jobs
+				endpoint = "/scanner/status"
+			}
 
-		// Send GET request
-		status, buf, err := api.RequestNG("GET", endpoint, nil, true)
-		if err != nil {
-			log.Fatalf("Error from scanner API: %v", err)
-		}
+			// Send GET request
+			status, buf, err := api.RequestNG("GET", endpoint, nil, true)
+			if err != nil {
+				log.Fatalf("Error from scanner API: %v", err)
+			}
 
-		if status == http.StatusNotFound {
-			log.Fatalf("Job not found")
-		}
-		if status == http.StatusBadRequest {
-			log.Fatalf("Bad request: %s", string(buf))
-		}
-		if status != http.StatusOK {
-			log.Fatalf("Unexpected status code: %d", status)
-		}
+			if status == http.StatusNotFound {
+				log.Fatalf("Job not found")
+			}
+			if status == http.StatusBadRequest {
+				log.Fatalf("Bad request: %s", string(buf))
+			}
+			if status != http.StatusOK {
+				log.Fatalf("Unexpected status code: %d", status)
+			}
+
+			if len(args) > 0 {
+				// Single job - show detailed status
+				var job tdns.ScanJobStatus
+				err = json.Unmarshal(buf, &job)
+				if err != nil {
+					log.Fatalf("Error unmarshaling job status: %v", err)
+				}
+
+				fmt.Printf("Job ID: %s\n", job.JobID)
+				if job.Kind != "" {
+					fmt.Printf("Kind: %s\n", job.Kind)
+				}
+				if job.Parent != "" {
+					fmt.Printf("Parent zone: %s\n", job.Parent)
+				}
+				fmt.Printf("Status: %s\n", job.Status)
+				fmt.Printf("Created: %s\n", job.CreatedAt.Format("2006-01-02 15:04:05"))
+				if job.StartedAt != nil {
+					fmt.Printf("Started: %s\n", job.StartedAt.Format("2006-01-02 15:04:05"))
+				}
+				if job.CompletedAt != nil {
+					fmt.Printf("Completed: %s\n", job.CompletedAt.Format("2006-01-02 15:04:05"))
+				}
+				fmt.Printf("Progress: %d/%d tuples processed\n", job.ProcessedTuples, job.TotalTuples)
+
+				if job.Error {
+					fmt.Printf("Error: %s\n", job.ErrorMsg)
+				}
+
+				if len(job.Responses) > 0 {
+					fmt.Printf("\nResults (%d responses):\n", len(job.Responses))
+					for i, resp := range job.Responses {
+						fmt.Printf("\n  Response %d:\n", i+1)
+						fmt.Printf("    Qname: %s\n", resp.Qname)
+						fmt.Printf("    Scan Type: %s\n", tdns.ScanTypeToString[resp.ScanType])
+						fmt.Printf("    Data Changed: %t\n", resp.DataChanged)
+						if resp.AllNSInSync {
+							fmt.Printf("    All NS In Sync: true\n")
+						} else if len(resp.Options) > 0 {
+							for _, opt := range resp.Options {
+								if opt == "all-ns" {
+									fmt.Printf("    All NS In Sync: false\n")
+									break
+								}
+							}
+						}
+						if resp.Decision != "" {
+							fmt.Printf("    CDS Policy: %s (%s)\n", resp.Decision, resp.DecisionReason)
+						}
+						if resp.Error {
+							fmt.Printf("    Error: %s\n", resp.ErrorMsg)
+						}
+					}
+				}
+			} else {
+				// All jobs - show summary table
+				var jobs []*tdns.ScanJobStatus
+				err = json.Unmarshal(buf, &jobs)
+				if err != nil {
+					log.Fatalf("Error unmarshaling job list: %v", err)
+				}
+
+				if len(jobs) == 0 {
+					fmt.Println("No jobs found")
+					return
+				}
+
+				// Sort jobs by creation timestamp (newest first)
+				sort.Slice(jobs, func(i, j int) bool {
+					return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+				})
+
+				t := acidtab.New("JOB ID", "KIND", "PARENT", "STATUS", "CREATED", "PROGRESS", "ERROR")
+				for _, job := range jobs {
+					progress := fmt.Sprintf("%d/%d", job.ProcessedTuples, job.TotalTuples)
+					errorStr := ""
+					if job.Error {
+						errorStr = job.ErrorMsg
+						if len(errorStr) > 30 {
+							errorStr = errorStr[:27] + "..."
+						}
+					}
+					created := job.CreatedAt.Format("2006-01-02 15:04:05")
+					t.Row(job.JobID, job.Kind, job.Parent, job.Status, created, progress, errorStr)
+				}
+				fmt.Println(t.String())
+			}
+		},
+	}
+}
+
+var ResultsCmd = newScannerResultsCmd("scanner")
+
+func newScannerResultsCmd(role string) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "results [job-id]",
+		Short: "Get results of a completed scan job",
+		Long:  `Get detailed results of a completed scan job by job ID. Use --delete to delete the job after retrieving results.`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Get API client for scanner
+			api, err := GetApiClient(role, true)
+			if err != nil {
+				log.Fatalf("Error getting API client for scanner: %v", err)
+			}
+
+			jobID := args[0]
+			endpoint := fmt.Sprintf("/scanner/status?job_id=%s", jobID)
+
+			// Send GET request
+			status, buf, err := api.RequestNG("GET", endpoint, nil, true)
+			if err != nil {
+				log.Fatalf("Error from scanner API: %v", err)
+			}
+
+			if status == http.StatusNotFound {
+				log.Fatalf("Job not found: %s", jobID)
+			}
+			if status != http.StatusOK {
+				log.Fatalf("Unexpected status code: %d", status)
+			}
 
-		if len(args) > 0 {
-			// Single job - show detailed status
 			var job tdns.ScanJobStatus
 			err = json.Unmarshal(buf, &job)
 			if err != nil {
 				log.Fatalf("Error unmarshaling job status: %v", err)
 			}
 
-			fmt.Printf("Job ID: %s\n", job.JobID)
-			fmt.Printf("Status: %s\n", job.Status)
-			fmt.Printf("Created: %s\n", job.CreatedAt.Format("2006-01-02 15:04:05"))
-			if job.StartedAt != nil {
-				fmt.Printf("Started: %s\n", job.StartedAt.Format("2006-01-02 15:04:05"))
-			}
-			if job.CompletedAt != nil {
-				fmt.Printf("Completed: %s\n", job.CompletedAt.Format("2006-01-02 15:04:05"))
+			if job.Status != "completed" {
+				fmt.Printf("Job %s is not completed yet. Status: %s\n", jobID, job.Status)
+				fmt.Printf("Progress: %d/%d tuples processed\n", job.ProcessedTuples, job.TotalTuples)
+				return
 			}
-			fmt.Printf("Progress: %d/%d tuples processed\n", job.ProcessedTuples, job.TotalTuples)
 
 			if job.Error {
-				fmt.Printf("Error: %s\n", job.ErrorMsg)
+				fmt.Printf("Job %s completed with error: %s\n", jobID, job.ErrorMsg)
+				return
+			}
+
+			// Check if --delete flag is set
+			deleteFlag, _ := cmd.Flags().GetBool("delete")
+			if deleteFlag {
+				// Delete the job after retrieving results
+				deleteEndpoint := fmt.Sprintf("/scanner/delete?job_id=%s", jobID)
+				delStatus, delBuf, err := api.RequestNG("DELETE", deleteEndpoint, nil, true)
+				if err != nil {
+					log.Printf("Warning: Error deleting job %s: %v", jobID, err)
+				} else if delStatus == http.StatusOK {
+					fmt.Printf("Job %s deleted successfully\n", jobID)
+				} else {
+					log.Printf("Warning: Failed to delete job %s: status %d, response: %s", jobID, delStatus, string(delBuf))
+				}
 			}
 
-			if len(job.Responses) > 0 {
-				fmt.Printf("\nResults (%d responses):\n", len(job.Responses))
+			// Output results as JSON for easy parsing
+			if tdns.Globals.Verbose {
+				// Pretty print JSON
+				var prettyJSON bytes.Buffer
+				json.Indent(&prettyJSON, buf, "", "  ")
+				fmt.Println(prettyJSON.String())
+			} else {
+				// Show summary
+				fmt.Printf("Job ID: %s\n", job.JobID)
+				fmt.Printf("Status: %s\n", job.Status)
+				fmt.Printf("Total Responses: %d\n\n", len(job.Responses))
+
 				for i, resp := range job.Responses {
-					fmt.Printf("\n  Response %d:\n", i+1)
-					fmt.Printf("    Qname: %s\n", resp.Qname)
-					fmt.Printf("    Scan Type: %s\n", tdns.ScanTypeToString[resp.ScanType])
-					fmt.Printf("    Data Changed: %t\n", resp.DataChanged)
+					fmt.Printf("Response %d: %s (%s)\n", i+1, resp.Qname, tdns.ScanTypeToString[resp.ScanType])
+					if resp.DataChanged {
+						fmt.Printf("  Data changed: Yes\n")
+					} else {
+						fmt.Printf("  Data changed: No\n")
+					}
 					if resp.AllNSInSync {
-						fmt.Printf("    All NS In Sync: true\n")
+						fmt.Printf("  All NS in sync: Yes\n")
 					} else if len(resp.Options) > 0 {
 						for _, opt := range resp.Options {
 							if opt == "all-ns" {
-								fmt.Printf("    All NS In Sync: false\n")
+								fmt.Printf("  All NS in sync: No\n")
 								break
 							}
 						}
 					}
+					if resp.Decision != "" {
+						fmt.Printf("  CDS policy: %s (%s)\n", resp.Decision, resp.DecisionReason)
+					}
 					if resp.Error {
-						fmt.Printf("    Error: %s\n", resp.ErrorMsg)
+						fmt.Printf("  Error: %s\n", resp.ErrorMsg)
 					}
+					fmt.Println()
 				}
 			}
-		} else {
-			// All jobs - show summary table
-			var jobs []*tdns.ScanJobStatus
-			err = json.Unmarshal(buf, &jobs)
-			if err != nil {
-				log.Fatalf("Error unmarshaling job list: %v", err)
-			}
+		},
+	}
+	c.Flags().Bool("delete", false, "Delete the job after retrieving results")
+	return c
+}
 
-			if len(jobs) == 0 {
-				fmt.Println("No jobs found")
-				return
+var DeleteCmd = newScannerDeleteCmd("scanner")
+
+func newScannerDeleteCmd(role string) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "delete [job-id]",
+		Short: "Delete scan job(s)",
+		Long:  `Delete a specific scan job by job ID, or all jobs if --all is used`,
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Get API client for scanner
+			api, err := GetApiClient(role, true)
+			if err != nil {
+				log.Fatalf("Error getting API client for scanner: %v", err)
 			}
 
-			// Sort jobs by creation timestamp (newest first)
-			sort.Slice(jobs, func(i, j int) bool {
-				return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
-			})
+			deleteAll, _ := cmd.Flags().GetBool("all")
 
-			t := acidtab.New("JOB ID", "STATUS", "CREATED", "PROGRESS", "ERROR")
-			for _, job := range jobs {
-				progress := fmt.Sprintf("%d/%d", job.ProcessedTuples, job.TotalTuples)
-				errorStr := ""
-				if job.Error {
-					errorStr = job.ErrorMsg
-					if len(errorStr) > 30 {
-						errorStr = errorStr[:27] + "..."
-					}
-				}
-				created := job.CreatedAt.Format("2006-01-02 15:04:05")
-				t.Row(job.JobID, job.Status, created, progress, errorStr)
+			var endpoint string
+			if deleteAll {
+				endpoint = "/scanner/delete?all=true"
+			} else if len(args) > 0 {
+				endpoint = fmt.Sprintf("/scanner/delete?job_id=%s", args[0])
+			} else {
+				log.Fatal("Error: either specify a job ID or use --all flag")
 			}
-			fmt.Println(t.String())
-		}
-	},
-}
-
-var ResultsCmd = &cobra.Command{
-	Use:   "results [job-id]",
-	Short: "Get results of a completed scan job",
-	Long:  `Get detailed results of a completed scan job by job ID. Use --delete to delete the job after retrieving results.`,
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		// Get API client for scanner
-		api, err := GetApiClient("scanner", true)
-		if err != nil {
-			log.Fatalf("Error getting API client for scanner: %v", err)
-		}
-
-		jobID := args[0]
-		endpoint := fmt.Sprintf("/scanner/status?job_id=%s", jobID)
 
-		// Send GET request
-		status, buf, err := api.RequestNG("GET", endpoint, nil, true)
-		if err != nil {
-			log.Fatalf("Error from scanner API: %v", err)
-		}
+			// Send DELETE request
+			status, buf, err := api.RequestNG("DELETE", endpoint, nil, true)
+			if err != nil {
+				log.Fatalf("Error from scanner API: %v", err)
+			}
 
-		if status == http.StatusNotFound {
-			log.Fatalf("Job not found: %s", jobID)
-		}
-		if status != http.StatusOK {
-			log.Fatalf("Unexpected status code: %d", status)
-		}
+			if status == http.StatusNotFound {
+				log.Fatalf("Job not found")
+			}
+			if status == http.StatusBadRequest {
+				log.Fatalf("Bad request: %s", string(buf))
+			}
+			if status != http.StatusOK {
+				log.Fatalf("Unexpected status code: %d", status)
+			}
 
-		var job tdns.ScanJobStatus
-		err = json.Unmarshal(buf, &job)
-		if err != nil {
-			log.Fatalf("Error unmarshaling job status: %v", err)
-		}
+			var resp tdns.ScannerResponse
+			err = json.Unmarshal(buf, &resp)
+			if err != nil {
+				log.Fatalf("Error unmarshaling response: %v", err)
+			}
 
-		if job.Status != "completed" {
-			fmt.Printf("Job %s is not completed yet. Status: %s\n", jobID, job.Status)
-			fmt.Printf("Progress: %d/%d tuples processed\n", job.ProcessedTuples, job.TotalTuples)
-			return
-		}
+			if resp.Error {
+				log.Fatalf("Error: %s", resp.ErrorMsg)
+			}
 
-		if job.Error {
-			fmt.Printf("Job %s completed with error: %s\n", jobID, job.ErrorMsg)
-			return
-		}
+			fmt.Printf("%s\n", resp.Msg)
+		},
+	}
+	c.Flags().Bool("all", false, "Delete all jobs")
+	return c
+}
 
-		// Check if --delete flag is set
-		deleteFlag, _ := cmd.Flags().GetBool("delete")
-		if deleteFlag {
-			// Delete the job after retrieving results
-			deleteEndpoint := fmt.Sprintf("/scanner/delete?job_id=%s", jobID)
-			delStatus, delBuf, err := api.RequestNG("DELETE", deleteEndpoint, nil, true)
+var ChildrenCmd = newScannerChildrenCmd("scanner")
+
+func newScannerChildrenCmd(role string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "children",
+		Short: "Show the latest scan outcome per child of a parent zone",
+		Long: `Show, per child and scan type, the outcome of the latest scan (NOTIFY or
+sweep), since when a change seen at the child has been waiting for acceptance,
+and how long the last accepted change took. Use --zone for one parent zone.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			api, err := GetApiClient(role, true)
 			if err != nil {
-				log.Printf("Warning: Error deleting job %s: %v", jobID, err)
-			} else if delStatus == http.StatusOK {
-				fmt.Printf("Job %s deleted successfully\n", jobID)
-			} else {
-				log.Printf("Warning: Failed to delete job %s: status %d, response: %s", jobID, delStatus, string(delBuf))
+				log.Fatalf("Error getting API client for %s: %v", role, err)
+			}
+			endpoint := "/scanner/children"
+			if tdns.Globals.Zonename != "" {
+				endpoint += "?parent=" + url.QueryEscape(dns.Fqdn(tdns.Globals.Zonename))
+			}
+			status, buf, err := api.RequestNG("GET", endpoint, nil, true)
+			if err != nil {
+				log.Fatalf("Error from scanner API: %v", err)
+			}
+			if status != http.StatusOK {
+				log.Fatalf("Unexpected status code %d: %s", status, strings.TrimSpace(string(buf)))
+			}
+			var states []tdns.ScanChildState
+			if err := json.Unmarshal(buf, &states); err != nil {
+				log.Fatalf("Error unmarshaling child state: %v", err)
+			}
+			if len(states) == 0 {
+				fmt.Println("No children scanned yet")
+				return
 			}
-		}
 
-		// Output results as JSON for easy parsing
-		if tdns.Globals.Verbose {
-			// Pretty print JSON
-			var prettyJSON bytes.Buffer
-			json.Indent(&prettyJSON, buf, "", "  ")
-			fmt.Println(prettyJSON.String())
-		} else {
-			// Show summary
-			fmt.Printf("Job ID: %s\n", job.JobID)
-			fmt.Printf("Status: %s\n", job.Status)
-			fmt.Printf("Total Responses: %d\n\n", len(job.Responses))
-
-			for i, resp := range job.Responses {
-				fmt.Printf("Response %d: %s (%s)\n", i+1, resp.Qname, tdns.ScanTypeToString[resp.ScanType])
-				if resp.DataChanged {
-					fmt.Printf("  Data changed: Yes\n")
-				} else {
-					fmt.Printf("  Data changed: No\n")
+			when := func(t *time.Time) string {
+				if t == nil {
+					return "-"
 				}
-				if resp.AllNSInSync {
-					fmt.Printf("  All NS in sync: Yes\n")
-				} else if len(resp.Options) > 0 {
-					for _, opt := range resp.Options {
-						if opt == "all-ns" {
-							fmt.Printf("  All NS in sync: No\n")
-							break
-						}
-					}
+				return t.Format("2006-01-02 15:04:05")
+			}
+			t := acidtab.New("PARENT", "CHILD", "TYPE", "STATE", "LAST SCANNED", "PENDING SINCE", "LAST ACCEPTED", "TIME TO ACCEPT")
+			for _, st := range states {
+				delay := "-"
+				if st.LastAccepted != nil {
+					delay = st.AcceptDelay.String()
 				}
-				if resp.Error {
-					fmt.Printf("  Error: %s\n", resp.ErrorMsg)
+				state := st.State
+				if st.LastError != "" {
+					state += ": " + st.LastError
+					if len(state) > 40 {
+						state = state[:37] + "..."
+					}
 				}
-				fmt.Println()
+				t.Row(st.Parent, st.Child, st.ScanType, state, st.LastScanned.Format("2006-01-02 15:04:05"),
+					when(st.PendingSince), when(st.LastAccepted), delay)
 			}
-		}
-	},
+			fmt.Println(t.String())
+		},
+	}
 }
 
-var DeleteCmd = &cobra.Command{
-	Use:   "delete [job-id]",
-	Short: "Delete scan job(s)",
-	Long:  `Delete a specific scan job by job ID, or all jobs if --all is used`,
-	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		// Get API client for scanner
-		api, err := GetApiClient("scanner", true)
-		if err != nil {
-			log.Fatalf("Error getting API client for scanner: %v", err)
-		}
-
-		deleteAll, _ := cmd.Flags().GetBool("all")
-
-		var endpoint string
-		if deleteAll {
-			endpoint = "/scanner/delete?all=true"
-		} else if len(args) > 0 {
-			endpoint = fmt.Sprintf("/scanner/delete?job_id=%s", args[0])
-		} else {
-			log.Fatal("Error: either specify a job ID or use --all flag")
-		}
-
-		// Send DELETE request
-		status, buf, err := api.RequestNG("DELETE", endpoint, nil, true)
-		if err != nil {
-			log.Fatalf("Error from scanner API: %v", err)
-		}
-
-		if status == http.StatusNotFound {
-			log.Fatalf("Job not found")
-		}
-		if status == http.StatusBadRequest {
-			log.Fatalf("Bad request: %s", string(buf))
-		}
-		if status != http.StatusOK {
-			log.Fatalf("Unexpected status code: %d", status)
-		}
-
-		var resp tdns.ScannerResponse
-		err = json.Unmarshal(buf, &resp)
-		if err != nil {
-			log.Fatalf("Error unmarshaling response: %v", err)
-		}
-
-		if resp.Error {
-			log.Fatalf("Error: %s", resp.ErrorMsg)
-		}
-
-		fmt.Printf("%s\n", resp.Msg)
-	},
+// NewScannerCmd returns the scanner inspection commands for a daemon that
+// runs the parent-side scanner itself (tdns-auth).
+func NewScannerCmd(role string) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "scanner",
+		Short: "Inspect the parent-side scanner: jobs, sweeps and per-child state",
+	}
+	c.AddCommand(newScannerStatusCmd(role), newScannerResultsCmd(role), newScannerDeleteCmd(role), newScannerChildrenCmd(role))
+	return c
 }
 
 func init() {
 	// Add scan subcommands
 	ScanCmd.AddCommand(ScanCdsCmd)
 
-	// Add --delete flag to ResultsCmd
-	ResultsCmd.Flags().Bool("delete", false, "Delete the job after retrieving results")
-
-	// Add --all flag to DeleteCmd
-	DeleteCmd.Flags().Bool("all", false, "Delete all jobs")
-
 	// Add scan to scanner
 	ScannerCmd.AddCommand(ScanCmd)
 
@@ -410,6 +507,9 @@ func init() {
 	// Add delete command to scanner
 	ScannerCmd.AddCommand(DeleteCmd)
 
+	// Add per-child state to scanner
+	ScannerCmd.AddCommand(ChildrenCmd)
+
 	// Add ping to scanner (NewPingCmd is defined in ping.go)
 	ScannerCmd.AddCommand(NewPingCmd("scanner"))
 
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	tdns "github.com/johanix/tdns/v2"
+	"github.com/miekg/dns"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+// newZoneLintCmd returns a fresh "lint" subcommand, attached to every zone
+// tree via NewZoneCmd.
+func newZoneLintCmd(role string) *cobra.Command {
+	var zone string
+	var strict bool
+	c := &cobra.Command{
+		Use:   "lint [zonefile]",
+		Short: "Check zone content for semantic problems",
+		Long: `Run the zone linter: CNAME and other data, missing or inconsistent glue, NS
+targets that are aliases, out-of-zone and occluded data, TTL differences
+within RRsets, SOA timers, MX/SRV targets without addresses, DS records that
+match no DNSKEY of a child zone served by the same server, and SVCB/HTTPS
+parameters.
+
+With a file argument the file is parsed and checked locally; the zone name is
+taken from --zone or from the first SOA in the file. Without one, the zone
+given by --zone is checked as currently published by the daemon.
+
+Exits 1 if there are errors (or, with --strict, warnings).`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var issues []tdns.LintIssue
+			if len(args) == 1 {
+				name, li, err := tdns.LintZoneFile(zone, args[0])
+				if err != nil {
+					cliFatalf("%v", err)
+				}
+				issues = li
+				errs, warns := tdns.LintCounts(issues)
+				fmt.Printf("Zone %s (file %s): %d error(s), %d warning(s)\n", name, args[0], errs, warns)
+			} else {
+				if zone == "" {
+					cliFatalf("either a zone file or --zone is required")
+				}
+				api, err := GetApiClient(role, true)
+				if err != nil {
+					cliFatalf("error getting API client: %v", err)
+				}
+				cr, err := SendZoneCommand(api, tdns.ZonePost{Command: "lint", Zone: dns.Fqdn(zone)})
+				if err != nil {
+					cliFatalf("error from %q: %v", cr.AppName, err)
+				}
+				issues = cr.Lint
+				fmt.Println(cr.Msg)
+			}
+			if len(issues) > 0 {
+				out := []string{"Severity|Check|Owner|Type|Issue"}
+				for _, li := range issues {
+					out = append(out, fmt.Sprintf("%s|%s|%s|%s|%s", li.Severity, li.Check, li.Owner, dashIfEmpty(li.Type), li.Msg))
+				}
+				fmt.Println(columnize.SimpleFormat(out))
+			}
+			if errs, warns := tdns.LintCounts(issues); errs > 0 || (strict && warns > 0) {
+				os.Exit(1)
+			}
+		},
+	}
+	c.Flags().StringVarP(&zone, "zone", "z", "", "zone name")
+	c.Flags().BoolVar(&strict, "strict", false, "exit non-zero on warnings too")
+	return c
+}
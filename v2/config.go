@@ -223,6 +223,13 @@ type DnsEngineConf struct {
 	//              the serial stays put — secondaries don't see a regression
 	//              and don't trigger an unnecessary AXFR.
 	OutboundSoaSerial string `yaml:"outbound_soa_serial,omitempty" mapstructure:"outbound_soa_serial" validate:"omitempty,oneof=keep unixtime persist"`
+	// ZoneLint controls the semantic checks run on a zone before a newly
+	// loaded or transferred version is published (see zone_lint.go). One of:
+	//   off    — no checks.
+	//   warn   — log findings and flag the zone (default).
+	//   refuse — lint errors fail the load; the previous version stays
+	//            published.
+	ZoneLint string `yaml:"zone-lint,omitempty" mapstructure:"zone-lint" validate:"omitempty,oneof=off warn refuse"`
 }
 
 type ImrEngineConf struct {
@@ -233,6 +240,8 @@ type ImrEngineConf struct {
 	KeyFile     string               `yaml:"keyfile" mapstructure:"keyfile"`
 	Transports  []string             `yaml:"transports" mapstructure:"transports" validate:"required"` // "do53", "dot", "doh", "doq"
 	Stubs       []ImrStubConf        `yaml:"stubs"`
+	Forwards    []ImrForwardConf     `yaml:"forwards" mapstructure:"forwards"`
+	Access      ImrAccessConf        `yaml:"access" mapstructure:"access"`
 	OptionsStrs []string             `yaml:"options" mapstructure:"options"`
 	Options     map[ImrOption]string `yaml:"-" mapstructure:"-"`
 	// Trust anchors for recursive validation. Provide either DS or DNSKEY as
@@ -241,9 +250,18 @@ type ImrEngineConf struct {
 	TrustAnchorDNSKEY string `yaml:"trust_anchor_dnskey"`
 	// Unbound-style file with one RR per line (DNSKEY and/or DS). Absolute path.
 	TrustAnchorFile string `yaml:"trust-anchor-file"`
-	Verbose         bool
-	Debug           bool
-	Logging         ImrLoggingConf `yaml:"logging" mapstructure:"logging"`
+	// Rfc5011 enables automated trust anchor maintenance (RFC 5011).
+	Rfc5011 ImrRfc5011Conf `yaml:"rfc5011" mapstructure:"rfc5011"`
+	// Nta configures negative trust anchors (RFC 7646).
+	Nta ImrNtaConf `yaml:"nta" mapstructure:"nta"`
+	// CacheSnapshot keeps the cache across restarts.
+	CacheSnapshot ImrCacheSnapshotConf `yaml:"cache-snapshot" mapstructure:"cache-snapshot"`
+	// LocalZones and LocalData are answered by the resolver itself.
+	LocalZones []ImrLocalZoneConf `yaml:"local-zones" mapstructure:"local-zones"`
+	LocalData  []string           `yaml:"local-data" mapstructure:"local-data"`
+	Verbose    bool
+	Debug      bool
+	Logging    ImrLoggingConf `yaml:"logging" mapstructure:"logging"`
 	// RequireDnssecValidation: when true (default), TLSA and other security-sensitive
 	// records must have a secure DNSSEC validation state. Set to false to allow
 	// indeterminate/insecure records during lab/development when the full DNSSEC
@@ -264,6 +282,8 @@ type ImrTuningConf struct {
 	AddressFamily AddressFamilyConf `yaml:"address_family" mapstructure:"address_family"`
 	Discovery     DiscoveryConf     `yaml:"discovery" mapstructure:"discovery"`
 	QueryBudget   time.Duration     `yaml:"query_budget" mapstructure:"query_budget"`
+	Cache         CacheConf         `yaml:"cache" mapstructure:"cache"`
+	Prefetch      PrefetchConf      `yaml:"prefetch" mapstructure:"prefetch"`
 	// UpgradeIndirectCacheHits controls whether cache hits with an
 	// indirect context (Glue, Referral, Hint) trigger a fresh query
 	// to "upgrade" the data quality. nil = legacy behaviour (true).
@@ -302,6 +322,34 @@ type DiscoveryConf struct {
 	MaxFailures       int           `yaml:"max_failures" mapstructure:"max_failures"`
 }
 
+// CacheConf sets the memory budget of the RRset cache, in megabytes of
+// accounted (wire format plus per-entry overhead) size, separately for
+// positive answers, negative answers and infrastructure data (NS, DS,
+// DNSKEY and glue) so that one class cannot push out the others.
+type CacheConf struct {
+	PositiveMB int `yaml:"positive_mb" mapstructure:"positive_mb"`
+	NegativeMB int `yaml:"negative_mb" mapstructure:"negative_mb"`
+	InfraMB    int `yaml:"infra_mb" mapstructure:"infra_mb"`
+}
+
+// Budget converts the configured sizes to a cache.CacheBudget.
+func (c CacheConf) Budget() cache.CacheBudget {
+	return cache.CacheBudget{
+		Positive: int64(c.PositiveMB) << 20,
+		Negative: int64(c.NegativeMB) << 20,
+		Infra:    int64(c.InfraMB) << 20,
+	}
+}
+
+// PrefetchConf tunes prefetch of popular cache entries: a hit on a
+// positive answer with at most Threshold percent of its TTL left starts a
+// background refresh. Entries with a TTL below MinTTL are never prefetched.
+type PrefetchConf struct {
+	Disable   bool          `yaml:"disable" mapstructure:"disable"`
+	Threshold int           `yaml:"threshold" mapstructure:"threshold"`
+	MinTTL    time.Duration `yaml:"min_ttl" mapstructure:"min_ttl"`
+}
+
 // LoadImrTuningDefaults fills missing or invalid fields with sensible
 // defaults. Any non-positive duration, zero/negative integer count,
 // or out-of-range Multiplier / JitterFraction is treated as "unset"
@@ -361,6 +409,23 @@ func LoadImrTuningDefaults(t *ImrTuningConf) {
 	if t.QueryBudget <= 0 {
 		t.QueryBudget = 8 * time.Second
 	}
+	// Prefetch
+	if t.Prefetch.Threshold <= 0 || t.Prefetch.Threshold >= 100 {
+		t.Prefetch.Threshold = 10
+	}
+	if t.Prefetch.MinTTL <= 0 {
+		t.Prefetch.MinTTL = 10 * time.Second
+	}
+	// Cache
+	if t.Cache.PositiveMB <= 0 {
+		t.Cache.PositiveMB = int(cache.DefaultCacheBudget.Positive >> 20)
+	}
+	if t.Cache.NegativeMB <= 0 {
+		t.Cache.NegativeMB = int(cache.DefaultCacheBudget.Negative >> 20)
+	}
+	if t.Cache.InfraMB <= 0 {
+		t.Cache.InfraMB = int(cache.DefaultCacheBudget.Infra >> 20)
+	}
 }
 
 type ImrLoggingConf struct {
@@ -373,6 +438,109 @@ type ImrStubConf struct {
 	Servers []cache.AuthServer `validate:"required"`
 }
 
+// ImrForwardConf is a forward zone: queries at or below Zone are sent with
+// RD=1 to the listed recursive resolvers instead of being iterated. With
+// First set, iteration is tried when no forwarder answers.
+type ImrForwardConf struct {
+	Zone       string             `yaml:"zone" mapstructure:"zone" validate:"required"`
+	First      bool               `yaml:"first" mapstructure:"first"`
+	Forwarders []ImrForwarderConf `yaml:"forwarders" mapstructure:"forwarders" validate:"required"`
+}
+
+// ImrForwarderConf is one upstream resolver of a forward zone. Transports
+// are tried in the given order ("do53" if empty) on their standard ports.
+type ImrForwarderConf struct {
+	Name       string   `yaml:"name" mapstructure:"name"`
+	Addrs      []string `yaml:"addrs" mapstructure:"addrs" validate:"required"`
+	Transports []string `yaml:"transports" mapstructure:"transports"`
+}
+
+// ImrRfc5011Conf configures RFC 5011 tracking of the trust anchors. The
+// state is kept in StateFile, which defaults to
+// <trust-anchor-file>.5011-state and may not be the trust-anchor-file
+// itself; the hold-down times default to the RFC's 30 days.
+type ImrRfc5011Conf struct {
+	Enabled        bool          `yaml:"enabled" mapstructure:"enabled"`
+	StateFile      string        `yaml:"state-file" mapstructure:"state-file"`
+	AddHoldDown    time.Duration `yaml:"add-hold-down" mapstructure:"add-hold-down"`
+	RemoveHoldDown time.Duration `yaml:"remove-hold-down" mapstructure:"remove-hold-down"`
+}
+
+// ImrNtaConf configures negative trust anchors. NTAs added at runtime
+// live for DefaultLifetime (24h) unless given a lifetime, and are re-probed
+// every ProbeInterval (5m) so they go away once the zone validates again.
+// DomainInsecure lists zones that are never validated; these are permanent
+// NTAs, neither probed nor expired.
+type ImrNtaConf struct {
+	DefaultLifetime time.Duration `yaml:"default-lifetime" mapstructure:"default-lifetime"`
+	ProbeInterval   time.Duration `yaml:"probe-interval" mapstructure:"probe-interval"`
+	DomainInsecure  []string      `yaml:"domain-insecure" mapstructure:"domain-insecure"`
+}
+
+// ImrLocalZoneConf is a zone answered by the resolver from local-data
+// instead of being resolved. Type is one of static (default), transparent,
+// redirect, refuse and always-nxdomain, with the Unbound semantics. With
+// Insecure set, names in the zone that are resolved are not validated.
+type ImrLocalZoneConf struct {
+	Zone     string `yaml:"zone" mapstructure:"zone" validate:"required"`
+	Type     string `yaml:"type" mapstructure:"type"`
+	Insecure bool   `yaml:"insecure" mapstructure:"insecure"`
+}
+
+// ImrCacheSnapshotConf enables the on-disk cache snapshot. The snapshot is
+// written to File at shutdown and every Interval (default 10m; negative
+// means only at shutdown), and restored at startup.
+type ImrCacheSnapshotConf struct {
+	File     string        `yaml:"file" mapstructure:"file"`
+	Interval time.Duration `yaml:"interval" mapstructure:"interval"`
+}
+
+// ImrAccessConf controls which clients may use the IMR listeners and how
+// they are treated. AllowQuery is the global ACL (localhost and private
+// networks when unset); a matching Listeners rule replaces it for queries
+// arriving on that address and transport. ACL keys are NOKEY or BLOCKED,
+// as the IMR does not verify TSIG.
+type ImrAccessConf struct {
+	AllowQuery   []AclEntry           `yaml:"allow-query" mapstructure:"allow-query"`
+	Listeners    []ImrListenerAclConf `yaml:"listeners" mapstructure:"listeners"`
+	RateLimit    ImrRateLimitConf     `yaml:"rate-limit" mapstructure:"rate-limit"`
+	ClientGroups []ImrClientGroupConf `yaml:"client-groups" mapstructure:"client-groups"`
+}
+
+// ImrListenerAclConf is the ACL for one listener address (without port;
+// empty matches any) and a set of transports (empty matches all).
+type ImrListenerAclConf struct {
+	Address    string     `yaml:"address" mapstructure:"address"`
+	Transports []string   `yaml:"transports" mapstructure:"transports"`
+	AllowQuery []AclEntry `yaml:"allow-query" mapstructure:"allow-query" validate:"required"`
+}
+
+// ImrRateLimitConf limits the query rate per client netblock (a /24 or
+// /56 by default). QPS 0 disables rate limiting. Action is "drop"
+// (default) or "refuse".
+type ImrRateLimitConf struct {
+	QPS           int    `yaml:"qps" mapstructure:"qps"`
+	Burst         int    `yaml:"burst" mapstructure:"burst"`
+	IPv4PrefixLen int    `yaml:"ipv4-prefix-len" mapstructure:"ipv4-prefix-len"`
+	IPv6PrefixLen int    `yaml:"ipv6-prefix-len" mapstructure:"ipv6-prefix-len"`
+	Action        string `yaml:"action" mapstructure:"action"`
+}
+
+// ImrClientGroupConf is a named set of clients with their own options. A
+// client belongs to the first group whose Clients ACL allows it.
+// RequireDnssec makes the IMR ignore the CD bit and answer SERVFAIL unless
+// the answer validated as Secure. Blocking (default true) runs the blocking
+// client query hooks (RPZ and similar filtering) and answers from the
+// redirect, refuse and always-nxdomain local zones; the other hooks and
+// local zones always apply. NoRateLimit exempts the group from rate limiting.
+type ImrClientGroupConf struct {
+	Name          string     `yaml:"name" mapstructure:"name" validate:"required"`
+	Clients       []AclEntry `yaml:"clients" mapstructure:"clients" validate:"required"`
+	RequireDnssec bool       `yaml:"require-dnssec" mapstructure:"require-dnssec"`
+	Blocking      *bool      `yaml:"blocking" mapstructure:"blocking"`
+	NoRateLimit   bool       `yaml:"no-rate-limit" mapstructure:"no-rate-limit"`
+}
+
 // type StubServerConf struct {
 // 	Name  string   `validate:"required"`
 // 	Addrs []string `validate:"required"`
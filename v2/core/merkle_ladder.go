@@ -0,0 +1,382 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Merkle tree signature ladders (experimental).
+//
+// With a large (post-quantum) signing algorithm every RRSIG carries a
+// multi-kilobyte signature. In ladder mode a signing pass instead makes
+// every RRSIG a leaf of a Merkle tree, signs only the tree root with the
+// large algorithm, and puts a compact inclusion proof where the signature
+// would have been:
+//
+//   - a leaf is SHA-256(0x00 || RRSIG rdata without the signature ||
+//     canonical RRset), i.e. exactly the data RFC 4034 §3.1.8.1 would
+//     have signed;
+//   - an interior node is SHA-256(0x01 || left || right); a node without
+//     a sibling is promoted to the next level unchanged;
+//   - the root is published as a LADDER rung at the zone apex, and the
+//     LADDER RRset is signed normally, so one large signature covers
+//     every tree of the pass;
+//   - the RRSIG's Signature field holds a LadderProof: magic, tree id,
+//     leaf count, leaf index and the sibling hashes from leaf to root.
+//
+// A validator recomputes the root from the proof and the RRset, and
+// accepts it when the zone's validated LADDER RRset holds a rung with the
+// same tree id, key tag, leaf count and root. The RRSIG keeps the key's
+// own algorithm and key tag; ladder-unaware validators see it as bogus,
+// which is why this is a lab-only mode.
+
+// ladderProofMagic starts every encoded LadderProof.
+var ladderProofMagic = []byte{'L', 'D', 'R', '1'}
+
+const (
+	ladderLeafPrefix = 0x00
+	ladderNodePrefix = 0x01
+	ladderHashLen    = sha256.Size
+)
+
+// LadderProof is a leaf's inclusion proof in a ladder tree.
+type LadderProof struct {
+	TreeID uint32
+	Leaves uint32
+	Index  uint32
+	Path   [][]byte // sibling hashes, leaf level first
+}
+
+// LadderTree is a Merkle tree over the leaf hashes of one signing pass.
+type LadderTree struct {
+	levels [][][]byte // levels[0] is the leaves, the last level the root
+}
+
+// ladderNode returns the interior node over left and right.
+func ladderNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{ladderNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// BuildLadderTree builds the tree over leaves (leaf hashes, see
+// LadderLeafHash). leaves must not be empty.
+func BuildLadderTree(leaves [][]byte) (*LadderTree, error) {
+	if len(leaves) == 0 {
+		return nil, errors.New("ladder tree needs at least one leaf")
+	}
+	t := &LadderTree{levels: [][][]byte{leaves}}
+	for level := leaves; len(level) > 1; {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, ladderNode(level[i], level[i+1]))
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t, nil
+}
+
+// Root returns the tree root.
+func (t *LadderTree) Root() []byte {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// Leaves returns the number of leaves.
+func (t *LadderTree) Leaves() int {
+	return len(t.levels[0])
+}
+
+// TreeID derives the tree id from the root: the first four bytes.
+func (t *LadderTree) TreeID() uint32 {
+	return binary.BigEndian.Uint32(t.Root())
+}
+
+// Proof returns the inclusion proof for leaf idx.
+func (t *LadderTree) Proof(idx int) *LadderProof {
+	p := &LadderProof{TreeID: t.TreeID(), Leaves: uint32(t.Leaves()), Index: uint32(idx)}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		if sib := idx ^ 1; sib < len(level) {
+			p.Path = append(p.Path, level[sib])
+		}
+		idx >>= 1
+	}
+	return p
+}
+
+// Rung returns the LADDER rdata publishing the tree for the key with
+// keytag; expiration is the latest expiration of the RRSIGs it proves.
+func (t *LadderTree) Rung(keytag uint16, expiration uint32) *LADDER {
+	return &LADDER{
+		KeyTag:     keytag,
+		TreeID:     t.TreeID(),
+		Hash:       LadderHashSHA256,
+		Leaves:     uint32(t.Leaves()),
+		Expiration: expiration,
+		Root:       t.Root(),
+	}
+}
+
+// ladderPathLen returns how many sibling hashes the proof for leaf idx in a
+// tree of n leaves has, or -1 if idx is out of range.
+func ladderPathLen(idx, n uint32) int {
+	if n == 0 || idx >= n {
+		return -1
+	}
+	l := 0
+	for ; n > 1; n = (n + 1) / 2 {
+		if idx^1 < n {
+			l++
+		}
+		idx >>= 1
+	}
+	return l
+}
+
+// Pack returns the wire form of the proof, as carried in RRSIG.Signature.
+func (p *LadderProof) Pack() []byte {
+	buf := make([]byte, 0, len(ladderProofMagic)+12+len(p.Path)*ladderHashLen)
+	buf = append(buf, ladderProofMagic...)
+	buf = binary.BigEndian.AppendUint32(buf, p.TreeID)
+	buf = binary.BigEndian.AppendUint32(buf, p.Leaves)
+	buf = binary.BigEndian.AppendUint32(buf, p.Index)
+	for _, h := range p.Path {
+		buf = append(buf, h...)
+	}
+	return buf
+}
+
+// Signature returns the proof in the presentation form of RRSIG.Signature.
+func (p *LadderProof) Signature() string {
+	return base64.StdEncoding.EncodeToString(p.Pack())
+}
+
+// UnpackLadderProof parses a proof. It is strict about the length, so that
+// an ordinary signature is not mistaken for a proof.
+func UnpackLadderProof(buf []byte) (*LadderProof, error) {
+	hdr := len(ladderProofMagic) + 12
+	if len(buf) < hdr || !bytes.Equal(buf[:len(ladderProofMagic)], ladderProofMagic) {
+		return nil, errors.New("not a ladder proof")
+	}
+	off := len(ladderProofMagic)
+	p := &LadderProof{
+		TreeID: binary.BigEndian.Uint32(buf[off:]),
+		Leaves: binary.BigEndian.Uint32(buf[off+4:]),
+		Index:  binary.BigEndian.Uint32(buf[off+8:]),
+	}
+	n := ladderPathLen(p.Index, p.Leaves)
+	if n < 0 || len(buf) != hdr+n*ladderHashLen {
+		return nil, errors.New("malformed ladder proof")
+	}
+	for i := 0; i < n; i++ {
+		p.Path = append(p.Path, buf[hdr+i*ladderHashLen:hdr+(i+1)*ladderHashLen])
+	}
+	return p, nil
+}
+
+// LadderProofFromRRSIG returns the ladder proof in sig, and false when sig
+// carries an ordinary signature.
+func LadderProofFromRRSIG(sig *dns.RRSIG) (*LadderProof, bool) {
+	// An encoded proof is at most 16 + 32*32 bytes; anything longer is a
+	// real signature and not worth decoding.
+	if sig == nil || len(sig.Signature) > base64.StdEncoding.EncodedLen(16+32*ladderHashLen) {
+		return nil, false
+	}
+	buf, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return nil, false
+	}
+	p, err := UnpackLadderProof(buf)
+	if err != nil {
+		return nil, false
+	}
+	return p, true
+}
+
+// ComputeRoot returns the root that the proof and the leaf hash lead to.
+func (p *LadderProof) ComputeRoot(leaf []byte) []byte {
+	h := leaf
+	idx, n, i := p.Index, p.Leaves, 0
+	for ; n > 1; n = (n + 1) / 2 {
+		if idx^1 < n {
+			if idx&1 == 0 {
+				h = ladderNode(h, p.Path[i])
+			} else {
+				h = ladderNode(p.Path[i], h)
+			}
+			i++
+		}
+		idx >>= 1
+	}
+	return h
+}
+
+// LadderLeafHash returns the leaf hash for sig over rrset. All RRSIG fields
+// except Signature must be set (as RRSIG.Sign sets them).
+func LadderLeafHash(sig *dns.RRSIG, rrset []dns.RR) ([]byte, error) {
+	if len(rrset) == 0 {
+		return nil, errors.New("empty RRset")
+	}
+	sigwire := make([]byte, 18, 18+len(sig.SignerName)+1)
+	binary.BigEndian.PutUint16(sigwire[0:], sig.TypeCovered)
+	sigwire[2] = sig.Algorithm
+	sigwire[3] = sig.Labels
+	binary.BigEndian.PutUint32(sigwire[4:], sig.OrigTtl)
+	binary.BigEndian.PutUint32(sigwire[8:], sig.Expiration)
+	binary.BigEndian.PutUint32(sigwire[12:], sig.Inception)
+	binary.BigEndian.PutUint16(sigwire[16:], sig.KeyTag)
+	name := make([]byte, 256)
+	n, err := dns.PackDomainName(dns.CanonicalName(sig.SignerName), name, 0, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("pack signer name: %w", err)
+	}
+	sigwire = append(sigwire, name[:n]...)
+
+	data, err := canonicalRRsetWire(rrset, sig)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	h.Write([]byte{ladderLeafPrefix})
+	h.Write(sigwire)
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// canonicalRRsetWire returns rrset in canonical form and order (RFC 4034
+// §6), as covered by sig. Mirrors the signature data miekg/dns signs.
+func canonicalRRsetWire(rrset []dns.RR, sig *dns.RRSIG) ([]byte, error) {
+	wires := make([][]byte, 0, len(rrset))
+	for _, r := range rrset {
+		r1 := dns.Copy(r)
+		h := r1.Header()
+		h.Ttl = sig.OrigTtl
+		labels := dns.SplitDomainName(h.Name)
+		if len(labels) > int(sig.Labels) {
+			h.Name = "*." + strings.Join(labels[len(labels)-int(sig.Labels):], ".") + "."
+		}
+		h.Name = dns.CanonicalName(h.Name)
+		switch x := r1.(type) {
+		case *dns.NS:
+			x.Ns = dns.CanonicalName(x.Ns)
+		case *dns.MD:
+			x.Md = dns.CanonicalName(x.Md)
+		case *dns.MF:
+			x.Mf = dns.CanonicalName(x.Mf)
+		case *dns.CNAME:
+			x.Target = dns.CanonicalName(x.Target)
+		case *dns.SOA:
+			x.Ns = dns.CanonicalName(x.Ns)
+			x.Mbox = dns.CanonicalName(x.Mbox)
+		case *dns.MB:
+			x.Mb = dns.CanonicalName(x.Mb)
+		case *dns.MG:
+			x.Mg = dns.CanonicalName(x.Mg)
+		case *dns.MR:
+			x.Mr = dns.CanonicalName(x.Mr)
+		case *dns.PTR:
+			x.Ptr = dns.CanonicalName(x.Ptr)
+		case *dns.MINFO:
+			x.Rmail = dns.CanonicalName(x.Rmail)
+			x.Email = dns.CanonicalName(x.Email)
+		case *dns.MX:
+			x.Mx = dns.CanonicalName(x.Mx)
+		case *dns.RP:
+			x.Mbox = dns.CanonicalName(x.Mbox)
+			x.Txt = dns.CanonicalName(x.Txt)
+		case *dns.AFSDB:
+			x.Hostname = dns.CanonicalName(x.Hostname)
+		case *dns.RT:
+			x.Host = dns.CanonicalName(x.Host)
+		case *dns.SIG:
+			x.SignerName = dns.CanonicalName(x.SignerName)
+		case *dns.PX:
+			x.Map822 = dns.CanonicalName(x.Map822)
+			x.Mapx400 = dns.CanonicalName(x.Mapx400)
+		case *dns.NAPTR:
+			x.Replacement = dns.CanonicalName(x.Replacement)
+		case *dns.KX:
+			x.Exchanger = dns.CanonicalName(x.Exchanger)
+		case *dns.SRV:
+			x.Target = dns.CanonicalName(x.Target)
+		case *dns.DNAME:
+			x.Target = dns.CanonicalName(x.Target)
+		}
+		wire := make([]byte, dns.Len(r1)+1)
+		off, err := dns.PackRR(r1, wire, 0, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		wires = append(wires, wire[:off])
+	}
+	rdata := func(w []byte) []byte {
+		_, off, _ := dns.UnpackDomainName(w, 0)
+		return w[off+10:]
+	}
+	sort.Slice(wires, func(i, j int) bool { return bytes.Compare(rdata(wires[i]), rdata(wires[j])) < 0 })
+	var buf []byte
+	for i, w := range wires {
+		if i > 0 && bytes.Equal(w, wires[i-1]) {
+			continue
+		}
+		buf = append(buf, w...)
+	}
+	return buf, nil
+}
+
+// VerifyLadderRRSIG checks that sig, carrying a ladder proof, covers rrset
+// and proves into one of rungs (the rdata of the signer's validated LADDER
+// RRset). It returns the rung on success. Validity period and key checks
+// are the caller's, as with RRSIG.Verify.
+func VerifyLadderRRSIG(sig *dns.RRSIG, rrset []dns.RR, rungs []*LADDER) (*LADDER, error) {
+	proof, ok := LadderProofFromRRSIG(sig)
+	if !ok {
+		return nil, errors.New("RRSIG does not carry a ladder proof")
+	}
+	if !dns.IsRRset(rrset) {
+		return nil, dns.ErrRRset
+	}
+	if h0 := rrset[0].Header(); h0.Class != sig.Hdr.Class ||
+		h0.Rrtype != sig.TypeCovered ||
+		uint8(dns.CountLabel(h0.Name)) < sig.Labels ||
+		!strings.EqualFold(h0.Name, sig.Hdr.Name) ||
+		!dns.IsSubDomain(sig.SignerName, h0.Name) {
+		return nil, dns.ErrRRset
+	}
+	leaf, err := LadderLeafHash(sig, rrset)
+	if err != nil {
+		return nil, err
+	}
+	root := proof.ComputeRoot(leaf)
+	for _, rung := range rungs {
+		if rung.TreeID != proof.TreeID || rung.KeyTag != sig.KeyTag {
+			continue
+		}
+		if rung.Hash != LadderHashSHA256 || rung.Leaves != proof.Leaves || !bytes.Equal(rung.Root, root) {
+			return nil, fmt.Errorf("ladder proof does not match rung %d (key %d)", rung.TreeID, rung.KeyTag)
+		}
+		if sig.Expiration > rung.Expiration {
+			return nil, fmt.Errorf("RRSIG expires after its rung %d (key %d)", rung.TreeID, rung.KeyTag)
+		}
+		return rung, nil
+	}
+	return nil, fmt.Errorf("no LADDER rung %d for key %d", proof.TreeID, sig.KeyTag)
+}
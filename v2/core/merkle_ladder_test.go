@@ -0,0 +1,144 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestLadderTreeProofs(t *testing.T) {
+	for n := 1; n <= 33; n++ {
+		leaves := make([][]byte, n)
+		for i := range leaves {
+			h := sha256.Sum256([]byte(fmt.Sprintf("leaf %d", i)))
+			leaves[i] = h[:]
+		}
+		tree, err := BuildLadderTree(leaves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range leaves {
+			buf := tree.Proof(i).Pack()
+			p, err := UnpackLadderProof(buf)
+			if err != nil {
+				t.Fatalf("n=%d leaf %d: unpack: %v", n, i, err)
+			}
+			if !bytes.Equal(p.ComputeRoot(leaves[i]), tree.Root()) {
+				t.Errorf("n=%d leaf %d: proof does not lead to the root", n, i)
+			}
+			if n > 1 && bytes.Equal(p.ComputeRoot(leaves[(i+1)%n]), tree.Root()) {
+				t.Errorf("n=%d leaf %d: proof accepts another leaf", n, i)
+			}
+			if _, err := UnpackLadderProof(buf[:len(buf)-1]); err == nil {
+				t.Errorf("n=%d leaf %d: truncated proof accepted", n, i)
+			}
+		}
+	}
+}
+
+func TestLADDERParseRoundTrip(t *testing.T) {
+	root := sha256.Sum256([]byte("root"))
+	original := &LADDER{KeyTag: 40312, TreeID: 2948861231, Hash: LadderHashSHA256, Leaves: 1873, Expiration: 1793534400, Root: root[:]}
+
+	rr, err := dns.NewRR("example.com. 3600 IN LADDER " + original.String())
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	parsed := rr.(*dns.PrivateRR).Data.(*LADDER)
+	if parsed.String() != original.String() {
+		t.Errorf("parse round trip: %q != %q", parsed.String(), original.String())
+	}
+
+	buf := make([]byte, original.Len())
+	n, err := original.Pack(buf)
+	if err != nil || n != original.Len() {
+		t.Fatalf("pack: n=%d len=%d err=%v", n, original.Len(), err)
+	}
+	unpacked := new(LADDER)
+	if _, err := unpacked.Unpack(buf[:n]); err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	if unpacked.String() != original.String() {
+		t.Errorf("pack round trip: %q != %q", unpacked.String(), original.String())
+	}
+}
+
+func TestVerifyLadderRRSIG(t *testing.T) {
+	mk := func(s string) dns.RR {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rr
+	}
+	rrsets := [][]dns.RR{
+		{mk("www.example.com. 300 IN A 192.0.2.1"), mk("www.example.com. 300 IN A 192.0.2.2")},
+		{mk("example.com. 300 IN MX 10 Mail.Example.com.")},
+		{mk("*.example.com. 300 IN TXT \"wild\"")},
+	}
+	var sigs []*dns.RRSIG
+	var leaves [][]byte
+	for _, rrset := range rrsets {
+		h := rrset[0].Header()
+		sig := &dns.RRSIG{
+			Hdr:         dns.RR_Header{Name: h.Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: h.Ttl},
+			TypeCovered: h.Rrtype, Algorithm: dns.ECDSAP256SHA256, Labels: uint8(dns.CountLabel(h.Name)),
+			OrigTtl: h.Ttl, Expiration: 2000000000, Inception: 1700000000, KeyTag: 4711, SignerName: "example.com.",
+		}
+		if h.Name[0] == '*' {
+			sig.Labels--
+		}
+		leaf, err := LadderLeafHash(sig, rrset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs = append(sigs, sig)
+		leaves = append(leaves, leaf)
+	}
+	tree, err := BuildLadderTree(leaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rungs := []*LADDER{tree.Rung(4711, 2000000000)}
+	for i, sig := range sigs {
+		sig.Signature = tree.Proof(i).Signature()
+	}
+
+	for i, sig := range sigs {
+		if _, err := VerifyLadderRRSIG(sig, rrsets[i], rungs); err != nil {
+			t.Errorf("rrset %d: %v", i, err)
+		}
+	}
+
+	// Canonical form: RR order, TTL and rdata name case do not change the
+	// leaf.
+	reordered := []dns.RR{mk("www.example.com. 10 IN A 192.0.2.2"), mk("www.example.com. 10 IN A 192.0.2.1")}
+	if _, err := VerifyLadderRRSIG(sigs[0], reordered, rungs); err != nil {
+		t.Errorf("canonical variant rejected: %v", err)
+	}
+	if _, err := VerifyLadderRRSIG(sigs[1], []dns.RR{mk("example.com. 300 IN MX 10 mail.example.COM.")}, rungs); err != nil {
+		t.Errorf("canonical variant rejected: %v", err)
+	}
+	// A wildcard expansion proves with the wildcard's signature.
+	expanded := []dns.RR{mk("foo.example.com. 300 IN TXT \"wild\"")}
+	sigs[2].Hdr.Name = "foo.example.com."
+	if _, err := VerifyLadderRRSIG(sigs[2], expanded, rungs); err != nil {
+		t.Errorf("wildcard expansion rejected: %v", err)
+	}
+
+	tampered := []dns.RR{mk("www.example.com. 300 IN A 192.0.2.1"), mk("www.example.com. 300 IN A 192.0.2.3")}
+	if _, err := VerifyLadderRRSIG(sigs[0], tampered, rungs); err == nil {
+		t.Errorf("tampered RRset accepted")
+	}
+	other := *rungs[0]
+	other.Root = bytes.Repeat([]byte{1}, 32)
+	if _, err := VerifyLadderRRSIG(sigs[1], rrsets[1], []*LADDER{&other}); err == nil {
+		t.Errorf("proof accepted against a different root")
+	}
+	if _, err := VerifyLadderRRSIG(sigs[1], rrsets[1], nil); err == nil {
+		t.Errorf("proof accepted without a rung")
+	}
+}
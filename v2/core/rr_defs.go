@@ -23,6 +23,7 @@ const (
 	TypeCHUNK        = 65288 // Unified Chunk/Manifest
 	TypeJSONMANIFEST = 65289 // Older version of CHUNK
 	TypeJSONCHUNK    = 65289 // Older version of CHUNK
+	TypeLADDER       = 65290 // Merkle tree signature ladder (experimental)
 )
 
 // Format constants for CHUNK RR type
@@ -83,3 +84,18 @@ func packUint16(i uint16, msg []byte, off int) (off1 int, err error) {
 	binary.BigEndian.PutUint16(msg[off:], i)
 	return off + 2, nil
 }
+
+func unpackUint32(msg []byte, off int) (i uint32, off1 int, err error) {
+	if off+4 > len(msg) {
+		return 0, len(msg), errors.New("overflow unpacking uint32")
+	}
+	return binary.BigEndian.Uint32(msg[off:]), off + 4, nil
+}
+
+func packUint32(i uint32, msg []byte, off int) (off1 int, err error) {
+	if off+4 > len(msg) {
+		return len(msg), errors.New("overflow packing uint32")
+	}
+	binary.BigEndian.PutUint32(msg[off:], i)
+	return off + 4, nil
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+func init() {
+	RegisterLadderRR()
+}
+
+// Zone file syntax:
+//   owner TTL CLASS LADDER keytag treeid hash leaves expiration root
+//
+// Example:
+//   example.com. 3600 IN LADDER 40312 2948861231 1 1873 20261101120000 8f4c...e1
+//
+// Fields:
+//   keytag     - key tag of the zone key that built (and signed) the tree
+//   treeid     - tree identifier, carried in every inclusion proof
+//   hash       - tree hash algorithm (1 = SHA-256)
+//   leaves     - number of leaves (signed RRsets) in the tree
+//   expiration - latest expiration of any RRSIG proven by the tree
+//   root       - the tree root, in hex
+//
+// A LADDER RRset lives at the zone apex and holds one rung per live tree.
+// It is itself signed with ordinary RRSIGs; see merkle_ladder.go.
+
+type LADDER struct {
+	KeyTag     uint16
+	TreeID     uint32
+	Hash       uint8
+	Leaves     uint32
+	Expiration uint32
+	Root       []byte
+}
+
+const (
+	LadderHashSHA256 = 1
+)
+
+func NewLADDER() dns.PrivateRdata { return new(LADDER) }
+
+func (rd LADDER) String() string {
+	return fmt.Sprintf("%d %d %d %d %s %s", rd.KeyTag, rd.TreeID, rd.Hash, rd.Leaves,
+		dns.TimeToString(rd.Expiration), hex.EncodeToString(rd.Root))
+}
+
+func (rd *LADDER) Parse(txt []string) error {
+	if len(txt) != 6 {
+		return errors.New("LADDER requires a keytag, a tree id, a hash algorithm, a leaf count, an expiration and a root")
+	}
+	keytag, err := strconv.ParseUint(txt[0], 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid LADDER keytag: %s", txt[0])
+	}
+	treeid, err := strconv.ParseUint(txt[1], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid LADDER tree id: %s", txt[1])
+	}
+	hash, err := strconv.ParseUint(txt[2], 10, 8)
+	if err != nil {
+		return fmt.Errorf("invalid LADDER hash algorithm: %s", txt[2])
+	}
+	leaves, err := strconv.ParseUint(txt[3], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid LADDER leaf count: %s", txt[3])
+	}
+	expiration, err := dns.StringToTime(txt[4])
+	if err != nil {
+		return fmt.Errorf("invalid LADDER expiration: %s", txt[4])
+	}
+	root, err := hex.DecodeString(txt[5])
+	if err != nil || len(root) == 0 {
+		return fmt.Errorf("invalid LADDER root: %s", txt[5])
+	}
+
+	rd.KeyTag = uint16(keytag)
+	rd.TreeID = uint32(treeid)
+	rd.Hash = uint8(hash)
+	rd.Leaves = uint32(leaves)
+	rd.Expiration = expiration
+	rd.Root = root
+	return nil
+}
+
+func (rd *LADDER) Pack(buf []byte) (int, error) {
+	var off int
+	off, err := packUint16(rd.KeyTag, buf, off)
+	if err != nil {
+		return off, err
+	}
+	off, err = packUint32(rd.TreeID, buf, off)
+	if err != nil {
+		return off, err
+	}
+	off, err = packUint8(rd.Hash, buf, off)
+	if err != nil {
+		return off, err
+	}
+	off, err = packUint32(rd.Leaves, buf, off)
+	if err != nil {
+		return off, err
+	}
+	off, err = packUint32(rd.Expiration, buf, off)
+	if err != nil {
+		return off, err
+	}
+	if off+len(rd.Root) > len(buf) {
+		return len(buf), errors.New("overflow packing LADDER root")
+	}
+	copy(buf[off:], rd.Root)
+	return off + len(rd.Root), nil
+}
+
+func (rd *LADDER) Unpack(buf []byte) (int, error) {
+	var off = 0
+	var err error
+
+	rd.KeyTag, off, err = unpackUint16(buf, off)
+	if err != nil {
+		return off, err
+	}
+	rd.TreeID, off, err = unpackUint32(buf, off)
+	if err != nil {
+		return off, err
+	}
+	rd.Hash, off, err = unpackUint8(buf, off)
+	if err != nil {
+		return off, err
+	}
+	rd.Leaves, off, err = unpackUint32(buf, off)
+	if err != nil {
+		return off, err
+	}
+	rd.Expiration, off, err = unpackUint32(buf, off)
+	if err != nil {
+		return off, err
+	}
+	rd.Root = make([]byte, len(buf)-off)
+	copy(rd.Root, buf[off:])
+	return len(buf), nil
+}
+
+func (rd *LADDER) Copy(dest dns.PrivateRdata) error {
+	d := dest.(*LADDER)
+	d.KeyTag = rd.KeyTag
+	d.TreeID = rd.TreeID
+	d.Hash = rd.Hash
+	d.Leaves = rd.Leaves
+	d.Expiration = rd.Expiration
+	d.Root = make([]byte, len(rd.Root))
+	copy(d.Root, rd.Root)
+	return nil
+}
+
+func (rd *LADDER) Len() int {
+	return 2 + 4 + 1 + 4 + 4 + len(rd.Root)
+}
+
+// NewLadderRR returns a LADDER RR for rung. It is made via dns.TypeToRR so
+// that dns.Copy works on it.
+func NewLadderRR(owner string, ttl uint32, rung *LADDER) *dns.PrivateRR {
+	rr := dns.TypeToRR[TypeLADDER]().(*dns.PrivateRR)
+	rr.Hdr = dns.RR_Header{Name: owner, Rrtype: TypeLADDER, Class: dns.ClassINET, Ttl: ttl}
+	rr.Data = rung
+	return rr
+}
+
+func RegisterLadderRR() error {
+	dns.PrivateHandle("LADDER", TypeLADDER, NewLADDER)
+	// Explicitly set TypeToString to use "LADDER" for printing
+	dns.TypeToString[TypeLADDER] = "LADDER"
+	return nil
+}
@@ -21,8 +21,19 @@ func (tx *Tx) Commit() error {
 	tx.KeyDB.mu.Unlock()
 	if err != nil {
 		lgConfig.Error("error committing KeyDB transaction", "context", tx.context, "err", err)
+		return err
 	}
-	return err
+	for _, f := range tx.onCommit {
+		f()
+	}
+	return nil
+}
+
+// afterCommit registers f to run once the transaction has committed. It is
+// dropped on rollback, so side effects that must not outlive a rolled-back
+// change (events, notifications) belong here rather than inline.
+func (tx *Tx) afterCommit(f func()) {
+	tx.onCommit = append(tx.onCommit, f)
 }
 
 func (tx *Tx) Rollback() error {
@@ -268,6 +279,8 @@ func dbMigrateSchema(db *sql.DB) {
 		{"ZonePolicyOverride", "applied_policy", "ALTER TABLE ZonePolicyOverride ADD COLUMN applied_policy TEXT"},
 		{"ZonePolicyOverride", "applied_source", "ALTER TABLE ZonePolicyOverride ADD COLUMN applied_source TEXT"},
 		{"ZonePolicyOverride", "applied_at", "ALTER TABLE ZonePolicyOverride ADD COLUMN applied_at TEXT"},
+		// Change sets: the diff a change set was approved with.
+		{"ZoneChangeSet", "approved_diff", "ALTER TABLE ZoneChangeSet ADD COLUMN approved_diff TEXT NOT NULL DEFAULT ''"},
 	}
 
 	for _, m := range migrations {
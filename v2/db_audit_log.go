@@ -0,0 +1,320 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ *
+ * Append-only audit journal for zone content and keystore mutations. Every
+ * path that changes what a zone serves or which keys sign it (DNS UPDATE via
+ * the ZoneUpdater, /zone and /keystore API commands, key-state transitions
+ * driven by the KeyStateWorker and the rollover engines, catalog member
+ * auto-configuration) records one AuditEntry in the AuditLog table. Rows are
+ * never updated or deleted by the daemon; the table is read via the /audit
+ * API endpoint and `tdns-cli auth audit`.
+ */
+
+package tdns
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var lgAudit = Logger("audit")
+
+// Actor types recorded in AuditEntry.Actor.Type.
+const (
+	AuditActorSig0   = "sig0"   // DNS UPDATE validated by a SIG(0) key
+	AuditActorTsig   = "tsig"   // DNS UPDATE authenticated by a TSIG key
+	AuditActorAPI    = "api"    // management API request
+	AuditActorEngine = "engine" // internal engine (rollover, key-state worker, catalog, ...)
+	AuditActorNone   = "unauthenticated"
+)
+
+// Audit sources, i.e. which subsystem performed the mutation.
+const (
+	AuditSourceZoneUpdater = "zone-updater"
+	AuditSourceApiZone     = "api-zone"
+	AuditSourceKeystore    = "keystore"
+	AuditSourceKeyState    = "key-state"
+	AuditSourceCatalog     = "catalog"
+	AuditSourceChangeSet   = "changeset"
+	AuditSourceCdsPolicy   = "cds-policy"
+	AuditSourceMultiSigner = "multi-signer"
+
+	AuditSourceDelegationBackend = "delegation-backend"
+)
+
+// AuditActor identifies who caused a mutation.
+type AuditActor struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+func (a AuditActor) String() string {
+	if a.Name == "" {
+		return a.Type
+	}
+	return a.Type + ":" + a.Name
+}
+
+// AuditEntry is one row in the audit journal. Old and New carry the
+// presentation-format RRs of each affected RRset before and after the change
+// (zone mutations) or are empty (key mutations, which use OldState/NewState).
+type AuditEntry struct {
+	ID       int64      `json:"id"`
+	Time     time.Time  `json:"time"`
+	Actor    AuditActor `json:"actor"`
+	Source   string     `json:"source"`
+	Zone     string     `json:"zone"`
+	Action   string     `json:"action"`
+	Old      []string   `json:"old,omitempty"`
+	New      []string   `json:"new,omitempty"`
+	KeyID    uint16     `json:"keyid,omitempty"`
+	OldState string     `json:"oldstate,omitempty"`
+	NewState string     `json:"newstate,omitempty"`
+	Serial   uint32     `json:"serial,omitempty"`
+	Detail   string     `json:"detail,omitempty"`
+}
+
+// AuditFilter selects entries for QueryAuditLog. Zero values do not filter.
+// Actor matches either the actor type ("api") or type:name ("sig0:child.").
+type AuditFilter struct {
+	Zone  string
+	Actor string
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+const defaultAuditQueryLimit = 1000
+
+// auditTimeFormat is fixed-width (unlike RFC3339Nano, which trims trailing
+// zeros) so that lexical order of the ts column is time order.
+const auditTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+const insertAuditSql = `
+INSERT INTO AuditLog (ts, actor_type, actor, source, zone, action, old_data, new_data, keyid, old_state, new_state, serial, detail)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+func auditArgs(e *AuditEntry) []interface{} {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Zone != "" {
+		e.Zone = dns.Fqdn(e.Zone)
+	}
+	return []interface{}{
+		e.Time.UTC().Format(auditTimeFormat), e.Actor.Type, e.Actor.Name,
+		e.Source, e.Zone, e.Action,
+		strings.Join(e.Old, "\n"), strings.Join(e.New, "\n"),
+		e.KeyID, e.OldState, e.NewState, e.Serial, e.Detail,
+	}
+}
+
+// RecordAudit appends an entry to the audit journal. It uses its own
+// statement, not a KeyDB transaction, so it must NOT be called while the
+// calling goroutine holds an open Tx; use recordAuditTx for that case.
+func (kdb *KeyDB) RecordAudit(e AuditEntry) error {
+	if kdb == nil || kdb.DB == nil {
+		return fmt.Errorf("RecordAudit: nil keystore")
+	}
+	if _, err := kdb.DB.Exec(insertAuditSql, auditArgs(&e)...); err != nil {
+		return fmt.Errorf("RecordAudit: %w", err)
+	}
+	return nil
+}
+
+// recordAuditTx appends an entry inside an existing transaction, so the
+// audit row commits (or rolls back) together with the change it describes.
+func recordAuditTx(tx *Tx, e AuditEntry) error {
+	if _, err := tx.Exec(insertAuditSql, auditArgs(&e)...); err != nil {
+		return fmt.Errorf("recordAuditTx: %w", err)
+	}
+	return nil
+}
+
+// auditRecord is the fire-and-forget wrapper used at mutation sites: an audit
+// write failure is logged loudly but never undoes or blocks the mutation.
+func auditRecord(kdb *KeyDB, e AuditEntry) {
+	if kdb == nil {
+		return
+	}
+	if err := kdb.RecordAudit(e); err != nil {
+		lgAudit.Error("failed to record audit entry", "zone", e.Zone, "action", e.Action, "actor", e.Actor.String(), "err", err)
+	}
+}
+
+// QueryAuditLog returns journal entries matching f, newest first.
+func (kdb *KeyDB) QueryAuditLog(f AuditFilter) ([]AuditEntry, error) {
+	if kdb == nil || kdb.DB == nil {
+		return nil, fmt.Errorf("QueryAuditLog: nil keystore")
+	}
+	q := `SELECT id, ts, actor_type, actor, source, zone, action, old_data, new_data, keyid, old_state, new_state, serial, detail FROM AuditLog WHERE 1=1`
+	var args []interface{}
+	if f.Zone != "" {
+		q += " AND zone = ?"
+		args = append(args, dns.Fqdn(f.Zone))
+	}
+	if f.Actor != "" {
+		if atype, name, found := strings.Cut(f.Actor, ":"); found {
+			q += " AND actor_type = ? AND actor = ?"
+			args = append(args, atype, name)
+		} else {
+			q += " AND actor_type = ?"
+			args = append(args, f.Actor)
+		}
+	}
+	if !f.Since.IsZero() {
+		q += " AND ts >= ?"
+		args = append(args, f.Since.UTC().Format(auditTimeFormat))
+	}
+	if !f.Until.IsZero() {
+		q += " AND ts <= ?"
+		args = append(args, f.Until.UTC().Format(auditTimeFormat))
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultAuditQueryLimit
+	}
+	q += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := kdb.DB.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("QueryAuditLog: %w", err)
+	}
+	defer rows.Close()
+
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var ts, oldData, newData string
+		var keyid sql.NullInt64
+		var serial sql.NullInt64
+		if err := rows.Scan(&e.ID, &ts, &e.Actor.Type, &e.Actor.Name, &e.Source, &e.Zone, &e.Action,
+			&oldData, &newData, &keyid, &e.OldState, &e.NewState, &serial, &e.Detail); err != nil {
+			return nil, fmt.Errorf("QueryAuditLog: scan: %w", err)
+		}
+		e.Time, _ = time.Parse(auditTimeFormat, ts)
+		if oldData != "" {
+			e.Old = strings.Split(oldData, "\n")
+		}
+		if newData != "" {
+			e.New = strings.Split(newData, "\n")
+		}
+		e.KeyID = uint16(keyid.Int64)
+		e.Serial = uint32(serial.Int64)
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("QueryAuditLog: rows: %w", err)
+	}
+	return out, nil
+}
+
+// updateRequestActor derives the audit actor for a ZoneUpdater request.
+// Requests from the DNS UPDATE responder carry an explicit Actor; internal
+// updates (ops_* publishers, rollover CDS publication, ...) are attributed
+// to the engine, named by the request's Description.
+func updateRequestActor(ur UpdateRequest) AuditActor {
+	if ur.Actor.Type != "" {
+		return ur.Actor
+	}
+	if ur.InternalUpdate {
+		name := ur.Description
+		if name == "" {
+			name = "internal"
+		}
+		return AuditActor{Type: AuditActorEngine, Name: name}
+	}
+	return AuditActor{Type: AuditActorNone}
+}
+
+// dnsUpdateActor identifies the principal behind a received DNS UPDATE: the
+// SIG(0) key that validated it, else a verified TSIG key, else nobody.
+func dnsUpdateActor(w dns.ResponseWriter, r *dns.Msg, us *UpdateStatus) AuditActor {
+	if us != nil && us.Validated {
+		if us.ValidatorKey != nil {
+			return AuditActor{Type: AuditActorSig0, Name: fmt.Sprintf("%s/%d", us.ValidatorKey.Name, us.ValidatorKey.Keyid)}
+		}
+		for _, s := range us.Signers {
+			if s.Validated {
+				return AuditActor{Type: AuditActorSig0, Name: fmt.Sprintf("%s/%d", s.Name, s.KeyId)}
+			}
+		}
+	}
+	if ts := r.IsTsig(); ts != nil && w != nil && w.TsigStatus() == nil {
+		return AuditActor{Type: AuditActorTsig, Name: ts.Hdr.Name}
+	}
+	return AuditActor{Type: AuditActorNone}
+}
+
+// apiAuditActor identifies the caller of a management API request. The API
+// has a single shared key, so the principal is the key plus the client host.
+func apiAuditActor(r *http.Request) AuditActor {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return AuditActor{Type: AuditActorAPI, Name: "apikey@" + host}
+}
+
+// auditRRsetImage returns the presentation-format RRs currently published for
+// every (owner, type) touched by actions, in action order. Used to capture
+// the before and after images of a ZONE-UPDATE.
+func (zd *ZoneData) auditRRsetImage(actions []dns.RR) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, rr := range actions {
+		owner := rr.Header().Name
+		rrtype := rr.Header().Rrtype
+		key := owner + "|" + dns.TypeToString[rrtype]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		rs, err := zd.GetRRset(owner, rrtype)
+		if err != nil || rs == nil {
+			continue
+		}
+		for _, r := range rs.RRs {
+			out = append(out, r.String())
+		}
+	}
+	return out
+}
+
+// auditUpdateActions renders the actions of an UPDATE for the journal when
+// no before/after image is available (child delegation data and truststore
+// updates are applied outside the zone's own RRsets).
+func auditUpdateActions(actions []dns.RR) []string {
+	out := make([]string, 0, len(actions))
+	for _, rr := range actions {
+		rrcopy := dns.Copy(rr)
+		rrcopy.Header().Class = dns.ClassINET
+		switch rr.Header().Class {
+		case dns.ClassINET:
+			out = append(out, "ADD "+rrcopy.String())
+		case dns.ClassNONE:
+			out = append(out, "DEL "+rrcopy.String())
+		case dns.ClassANY:
+			out = append(out, fmt.Sprintf("DEL-RRSET %s %s", rr.Header().Name, dns.TypeToString[rr.Header().Rrtype]))
+		}
+	}
+	return out
+}
+
+// auditZoneSerial returns the zone's current published serial, or 0 for a
+// nil zone.
+func auditZoneSerial(zd *ZoneData) uint32 {
+	if zd == nil {
+		return 0
+	}
+	zd.mu.Lock()
+	defer zd.mu.Unlock()
+	return zd.CurrentSerial
+}
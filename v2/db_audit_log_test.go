@@ -0,0 +1,139 @@
+package tdns
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAuditLogRecordAndQuery(t *testing.T) {
+	kdb := newTestKeyDB(t)
+
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []AuditEntry{
+		{Time: base, Actor: AuditActor{Type: AuditActorSig0, Name: "child.example./4711"}, Source: AuditSourceZoneUpdater,
+			Zone: "example", Action: "zone-update", Old: []string{"a.example.\t300\tIN\tA\t192.0.2.1"},
+			New: []string{"a.example.\t300\tIN\tA\t192.0.2.2"}, Serial: 2026030101},
+		{Time: base.Add(time.Hour), Actor: AuditActor{Type: AuditActorAPI, Name: "apikey@127.0.0.1"}, Source: AuditSourceApiZone,
+			Zone: "example.", Action: "bump", Serial: 2026030102},
+		{Time: base.Add(2 * time.Hour), Actor: AuditActor{Type: AuditActorEngine, Name: "key-state"}, Source: AuditSourceKeyState,
+			Zone: "other.", Action: "dnskey-state", KeyID: 12345, OldState: DnskeyStatePublished, NewState: DnskeyStateStandby},
+	}
+	for _, e := range entries {
+		if err := kdb.RecordAudit(e); err != nil {
+			t.Fatalf("RecordAudit: %v", err)
+		}
+	}
+
+	all, err := kdb.QueryAuditLog(AuditFilter{})
+	if err != nil {
+		t.Fatalf("QueryAuditLog: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("got %d entries, want 3", len(all))
+	}
+	if all[0].Action != "dnskey-state" || all[2].Action != "zone-update" {
+		t.Errorf("entries not newest first: %q .. %q", all[0].Action, all[2].Action)
+	}
+	first := all[2]
+	if first.Zone != "example." || len(first.Old) != 1 || len(first.New) != 1 || first.Serial != 2026030101 {
+		t.Errorf("round-trip mismatch: %+v", first)
+	}
+	if !first.Time.Equal(base) {
+		t.Errorf("time round-trip: got %v want %v", first.Time, base)
+	}
+	if all[0].KeyID != 12345 || all[0].NewState != DnskeyStateStandby {
+		t.Errorf("key fields round-trip: %+v", all[0])
+	}
+
+	cases := []struct {
+		name string
+		f    AuditFilter
+		want int
+	}{
+		{"zone", AuditFilter{Zone: "example"}, 2},
+		{"actor type", AuditFilter{Actor: AuditActorAPI}, 1},
+		{"actor type:name", AuditFilter{Actor: "sig0:child.example./4711"}, 1},
+		{"actor name mismatch", AuditFilter{Actor: "sig0:other./1"}, 0},
+		{"since", AuditFilter{Since: base.Add(30 * time.Minute)}, 2},
+		{"until", AuditFilter{Until: base.Add(time.Hour)}, 2},
+		{"window", AuditFilter{Since: base.Add(30 * time.Minute), Until: base.Add(90 * time.Minute)}, 1},
+		{"limit", AuditFilter{Limit: 1}, 1},
+	}
+	for _, tc := range cases {
+		got, err := kdb.QueryAuditLog(tc.f)
+		if err != nil {
+			t.Fatalf("%s: QueryAuditLog: %v", tc.name, err)
+		}
+		if len(got) != tc.want {
+			t.Errorf("%s: got %d entries, want %d", tc.name, len(got), tc.want)
+		}
+	}
+}
+
+// A DNSKEY state transition is journalled in the same transaction, so a
+// rolled-back transition leaves no audit row behind.
+func TestAuditLogKeyStateTransition(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	if _, err := kdb.DB.Exec(`INSERT INTO DnssecKeyStore (zonename, state, keyid, flags, algorithm, creator, privatekey, keyrr)
+VALUES ('example.', 'published', 4711, 257, 'ED25519', 'test', '', '')`); err != nil {
+		t.Fatalf("insert key: %v", err)
+	}
+
+	tx, err := kdb.Begin("test")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if err := UpdateDnssecKeyStateTx(tx, kdb, "example.", 4711, DnskeyStateStandby); err != nil {
+		t.Fatalf("UpdateDnssecKeyStateTx: %v", err)
+	}
+	tx.Rollback()
+	if got, _ := kdb.QueryAuditLog(AuditFilter{}); len(got) != 0 {
+		t.Fatalf("rolled-back transition left %d audit rows", len(got))
+	}
+
+	tx, err = kdb.Begin("test")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if err := UpdateDnssecKeyStateTx(tx, kdb, "example.", 4711, DnskeyStateStandby); err != nil {
+		t.Fatalf("UpdateDnssecKeyStateTx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	got, err := kdb.QueryAuditLog(AuditFilter{Zone: "example.", Actor: AuditActorEngine})
+	if err != nil {
+		t.Fatalf("QueryAuditLog: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d audit rows, want 1", len(got))
+	}
+	if got[0].KeyID != 4711 || got[0].OldState != DnskeyStatePublished || got[0].NewState != DnskeyStateStandby {
+		t.Errorf("unexpected audit row: %+v", got[0])
+	}
+}
+
+// A "setstate" reports the state it replaced, so the API audit entry shows
+// the transition and not just its end point.
+func TestDnssecSetStateReportsOldState(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	if _, err := kdb.DB.Exec(`INSERT INTO DnssecKeyStore (zonename, state, keyid, flags, algorithm, creator, privatekey, keyrr)
+VALUES ('example.', 'standby', 4711, 256, 'ED25519', 'test', '', '')`); err != nil {
+		t.Fatalf("insert key: %v", err)
+	}
+	tx, err := kdb.Begin("test")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	defer tx.Rollback()
+	resp, err := kdb.DnssecKeyMgmt(context.Background(), tx, KeystorePost{
+		Command: "dnssec-mgmt", SubCommand: "setstate", Keyname: "example.", Keyid: 4711, State: DnskeyStateRetired,
+	})
+	if err != nil {
+		t.Fatalf("DnssecKeyMgmt: %v", err)
+	}
+	if resp.OldState != DnskeyStateStandby {
+		t.Errorf("OldState = %q, want %q", resp.OldState, DnskeyStateStandby)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const changeSetTimeFormat = time.RFC3339
+
+func csTimeString(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(changeSetTimeFormat)
+}
+
+func csParseTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	t, _ := time.Parse(changeSetTimeFormat, s)
+	return t
+}
+
+const changeSetColumns = `id, zone, name, state, comment, ops, created_by, created_at, approved_by, approved_at,
+approved_serial, approved_diff, activate_at, applied_at, applied_serial, rolled_back_at, before_data, after_data, error`
+
+// CreateChangeSet stores a new change set; names are unique per zone.
+func (kdb *KeyDB) CreateChangeSet(cs *ChangeSet) error {
+	ops, err := json.Marshal(cs.Ops)
+	if err != nil {
+		return err
+	}
+	if cs.CreatedAt.IsZero() {
+		cs.CreatedAt = time.Now().UTC()
+	}
+	res, err := kdb.DB.Exec(`INSERT INTO ZoneChangeSet (zone, name, state, comment, ops, created_by, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`, cs.Zone, cs.Name, cs.State, cs.Comment, string(ops), cs.CreatedBy, csTimeString(cs.CreatedAt))
+	if err != nil {
+		if _, exists, _ := kdb.GetChangeSet(cs.Zone, cs.Name); exists {
+			return fmt.Errorf("change set %q already exists for zone %s", cs.Name, cs.Zone)
+		}
+		return fmt.Errorf("CreateChangeSet: %w", err)
+	}
+	cs.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// SaveChangeSet writes back every mutable field of an existing change set.
+func (kdb *KeyDB) SaveChangeSet(cs *ChangeSet) error {
+	ops, err := json.Marshal(cs.Ops)
+	if err != nil {
+		return err
+	}
+	var approvedDiff, before, after []byte
+	if len(cs.ApprovedDiff) > 0 {
+		approvedDiff, _ = json.Marshal(cs.ApprovedDiff)
+	}
+	if len(cs.Before) > 0 {
+		before, _ = json.Marshal(cs.Before)
+	}
+	if len(cs.After) > 0 {
+		after, _ = json.Marshal(cs.After)
+	}
+	_, err = kdb.DB.Exec(`UPDATE ZoneChangeSet SET state=?, comment=?, ops=?, approved_by=?, approved_at=?, approved_serial=?,
+approved_diff=?, activate_at=?, applied_at=?, applied_serial=?, rolled_back_at=?, before_data=?, after_data=?, error=? WHERE id=?`,
+		cs.State, cs.Comment, string(ops), cs.ApprovedBy, csTimeString(cs.ApprovedAt), cs.ApprovedSerial,
+		string(approvedDiff), csTimeString(cs.ActivateAt), csTimeString(cs.AppliedAt), cs.AppliedSerial, csTimeString(cs.RolledBackAt),
+		string(before), string(after), cs.Error, cs.ID)
+	if err != nil {
+		return fmt.Errorf("SaveChangeSet: %w", err)
+	}
+	return nil
+}
+
+// ClaimChangeSet moves a change set from state from (approved for an
+// apply, applied for a rollback) to applying. The update is conditional on
+// the stored state, so when several callers race to apply or roll back the
+// same change set only one gets it; the others get errChangeSetClaimed.
+func (kdb *KeyDB) ClaimChangeSet(cs *ChangeSet, from string) error {
+	res, err := kdb.DB.Exec(`UPDATE ZoneChangeSet SET state=? WHERE id=? AND state=?`,
+		ChangeSetApplying, cs.ID, from)
+	if err != nil {
+		return fmt.Errorf("ClaimChangeSet: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("ClaimChangeSet: %w", err)
+	} else if n != 1 {
+		return fmt.Errorf("%w: change set %q is no longer %s", errChangeSetClaimed, cs.Name, from)
+	}
+	cs.State = ChangeSetApplying
+	return nil
+}
+
+// RecoverChangeSets releases the claims a previous run left behind when it
+// stopped between claiming a change set and recording the outcome. A change
+// set that had been applied goes back to applied (an interrupted rollback),
+// any other to approved (an interrupted apply). If the interrupted publish
+// did happen, the approved diff or the recorded after image no longer
+// matches the zone, so the change set is not applied or rolled back twice.
+// It must run before anything can claim a change set.
+func (kdb *KeyDB) RecoverChangeSets() (int64, error) {
+	res, err := kdb.DB.Exec(`UPDATE ZoneChangeSet SET state=CASE WHEN applied_at != '' THEN ? ELSE ? END WHERE state=?`,
+		ChangeSetApplied, ChangeSetApproved, ChangeSetApplying)
+	if err != nil {
+		return 0, fmt.Errorf("RecoverChangeSets: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("RecoverChangeSets: %w", err)
+	}
+	return n, nil
+}
+
+var errChangeSetClaimed = errors.New("change set already claimed")
+
+// GetChangeSet returns the named change set of a zone.
+func (kdb *KeyDB) GetChangeSet(zone, name string) (*ChangeSet, bool, error) {
+	rows, err := kdb.DB.Query(`SELECT `+changeSetColumns+` FROM ZoneChangeSet WHERE zone=? AND name=?`, dns.Fqdn(zone), name)
+	if err != nil {
+		return nil, false, fmt.Errorf("GetChangeSet: %w", err)
+	}
+	defer rows.Close()
+	sets, err := scanChangeSets(rows)
+	if err != nil || len(sets) == 0 {
+		return nil, false, err
+	}
+	return &sets[0], true, nil
+}
+
+// ListChangeSets returns the change sets of a zone (all zones if zone is
+// empty), newest first.
+func (kdb *KeyDB) ListChangeSets(zone string) ([]ChangeSet, error) {
+	q := `SELECT ` + changeSetColumns + ` FROM ZoneChangeSet`
+	var args []interface{}
+	if zone != "" {
+		q += ` WHERE zone=?`
+		args = append(args, dns.Fqdn(zone))
+	}
+	rows, err := kdb.DB.Query(q+` ORDER BY id DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ListChangeSets: %w", err)
+	}
+	defer rows.Close()
+	return scanChangeSets(rows)
+}
+
+// DueChangeSets returns the approved change sets whose activation time is
+// at or before now, oldest activation first.
+func (kdb *KeyDB) DueChangeSets(now time.Time) ([]ChangeSet, error) {
+	rows, err := kdb.DB.Query(`SELECT `+changeSetColumns+` FROM ZoneChangeSet
+WHERE state=? AND activate_at != '' AND activate_at <= ? ORDER BY activate_at, id`,
+		ChangeSetApproved, csTimeString(now))
+	if err != nil {
+		return nil, fmt.Errorf("DueChangeSets: %w", err)
+	}
+	defer rows.Close()
+	return scanChangeSets(rows)
+}
+
+// DeleteChangeSet removes a change set.
+func (kdb *KeyDB) DeleteChangeSet(id int64) error {
+	_, err := kdb.DB.Exec(`DELETE FROM ZoneChangeSet WHERE id=?`, id)
+	return err
+}
+
+func scanChangeSets(rows *sql.Rows) ([]ChangeSet, error) {
+	var out []ChangeSet
+	for rows.Next() {
+		var cs ChangeSet
+		var ops, createdAt, approvedAt, approvedDiff, activateAt, appliedAt, rolledBackAt, before, after string
+		if err := rows.Scan(&cs.ID, &cs.Zone, &cs.Name, &cs.State, &cs.Comment, &ops, &cs.CreatedBy, &createdAt,
+			&cs.ApprovedBy, &approvedAt, &cs.ApprovedSerial, &approvedDiff, &activateAt, &appliedAt, &cs.AppliedSerial,
+			&rolledBackAt, &before, &after, &cs.Error); err != nil {
+			return nil, fmt.Errorf("scan change set: %w", err)
+		}
+		if err := json.Unmarshal([]byte(ops), &cs.Ops); err != nil {
+			return nil, fmt.Errorf("change set %d: bad ops: %w", cs.ID, err)
+		}
+		if approvedDiff != "" {
+			if err := json.Unmarshal([]byte(approvedDiff), &cs.ApprovedDiff); err != nil {
+				return nil, fmt.Errorf("change set %d: bad approved diff: %w", cs.ID, err)
+			}
+		}
+		if before != "" {
+			if err := json.Unmarshal([]byte(before), &cs.Before); err != nil {
+				return nil, fmt.Errorf("change set %d: bad before image: %w", cs.ID, err)
+			}
+		}
+		if after != "" {
+			if err := json.Unmarshal([]byte(after), &cs.After); err != nil {
+				return nil, fmt.Errorf("change set %d: bad after image: %w", cs.ID, err)
+			}
+		}
+		cs.CreatedAt = csParseTime(createdAt)
+		cs.ApprovedAt = csParseTime(approvedAt)
+		cs.ActivateAt = csParseTime(activateAt)
+		cs.AppliedAt = csParseTime(appliedAt)
+		cs.RolledBackAt = csParseTime(rolledBackAt)
+		out = append(out, cs)
+	}
+	return out, rows.Err()
+}
@@ -64,7 +64,8 @@ UNIQUE (zonename, keyid)
 
 	// The DnssecKeyStore should contain both the private and public DNSSEC keys for
 	// each zone that we're managing signing for.
-	// State: created, published, ds-published, standby, active, retired, removed.
+	// State: created, published, ds-published, standby, active, retired, removed,
+	// or foreign for the ZSKs of other multi-signer providers (no private key).
 	"DnssecKeyStore": `CREATE TABLE IF NOT EXISTS 'DnssecKeyStore' (
 id		  INTEGER PRIMARY KEY,
 zonename	  TEXT,
@@ -98,6 +99,34 @@ comment     TEXT DEFAULT '',
 UNIQUE (keyname)
 )`,
 
+	// EppCredentials holds registrar logins (EPP clID + password) for the
+	// epp delegation backend, one row per credential name. Managed via
+	// `tdns-cli auth keystore epp`; the password is never returned by the API.
+	"EppCredentials": `CREATE TABLE IF NOT EXISTS 'EppCredentials' (
+name        TEXT NOT NULL PRIMARY KEY,
+clid        TEXT NOT NULL,
+password    TEXT NOT NULL,
+created_at  TEXT DEFAULT '',
+comment     TEXT DEFAULT ''
+)`,
+
+	// DelegationOutbox is the persistent delivery queue of the delegation
+	// backends that push to a remote system (delegation_outbox.go). payload
+	// describes the change, progress is backend-owned resume state.
+	"DelegationOutbox": `CREATE TABLE IF NOT EXISTS 'DelegationOutbox' (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		backend     TEXT NOT NULL,
+		parent      TEXT NOT NULL,
+		child       TEXT NOT NULL,
+		payload     TEXT NOT NULL,
+		progress    TEXT NOT NULL DEFAULT '',
+		state       TEXT NOT NULL,
+		attempts    INTEGER NOT NULL DEFAULT 0,
+		created_at  TEXT NOT NULL,
+		updated_at  TEXT NOT NULL,
+		last_error  TEXT NOT NULL DEFAULT ''
+	)`,
+
 	// OutgoingSerials persists the outgoing SOA serial per zone.
 	// Prevents serial regression on restart (which causes signers to ignore NOTIFYs).
 	"OutgoingSerials": `CREATE TABLE IF NOT EXISTS 'OutgoingSerials' (
@@ -133,6 +162,66 @@ UNIQUE (keyname)
 		manual_rollover_earliest      TEXT
 	)`,
 
+	// KskAlgRollState tracks a KSK algorithm rollover driven by the
+	// auto-rollover engine (ksk_alg_rollover.go). One row per zone while a
+	// roll is in flight, or after the parent refused the new algorithm
+	// (stage 'refused', kept so the engine does not retry the same target).
+	// old_keyid/new_keyid are the outgoing and incoming active KSKs; in the
+	// ds-replace and withdraw stages old_keyid is excluded from the target
+	// DS RRset while it still signs the DNSKEY RRset.
+	"KskAlgRollState": `CREATE TABLE IF NOT EXISTS 'KskAlgRollState' (
+		zone       TEXT NOT NULL PRIMARY KEY,
+		stage      TEXT NOT NULL,
+		stage_at   TEXT,
+		from_alg   INTEGER NOT NULL,
+		to_alg     INTEGER NOT NULL,
+		old_keyid  INTEGER NOT NULL,
+		new_keyid  INTEGER NOT NULL,
+		detail     TEXT
+	)`,
+
+	// CdsObservation holds the current run of identical CDS observations
+	// per (parent, child) for the CDS acceptance policy (cds_acceptance.go).
+	// A different CDS RRset restarts the run; the row is deleted once the
+	// change is accepted or rejected.
+	"CdsObservation": `CREATE TABLE IF NOT EXISTS 'CdsObservation' (
+		parent       TEXT NOT NULL,
+		child        TEXT NOT NULL,
+		kind         TEXT NOT NULL,
+		fingerprint  TEXT NOT NULL,
+		first_seen   TEXT,
+		last_seen    TEXT,
+		count        INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (parent, child)
+	)`,
+
+	// ScanJob is the scanner's job history (scanner_history.go), so that
+	// 'scanner status' survives a restart. job is the JSON ScanJobStatus.
+	"ScanJob": `CREATE TABLE IF NOT EXISTS 'ScanJob' (
+		job_id      TEXT NOT NULL PRIMARY KEY,
+		kind        TEXT NOT NULL DEFAULT '',
+		parent      TEXT NOT NULL DEFAULT '',
+		status      TEXT NOT NULL,
+		created_at  TEXT NOT NULL,
+		job         TEXT NOT NULL
+	)`,
+
+	// ScanChildState is the latest scan outcome per (parent, child, scan
+	// type). pending_since is set while a change seen at the child has not
+	// been accepted; accept_delay is how long the last accepted change took.
+	"ScanChildState": `CREATE TABLE IF NOT EXISTS 'ScanChildState' (
+		parent         TEXT NOT NULL,
+		child          TEXT NOT NULL,
+		scan_type      TEXT NOT NULL,
+		state          TEXT NOT NULL,
+		last_scanned   TEXT NOT NULL,
+		pending_since  TEXT,
+		last_accepted  TEXT,
+		accept_delay   INTEGER NOT NULL DEFAULT 0,
+		last_error     TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (parent, child, scan_type)
+	)`,
+
 	"RolloverZoneState": `CREATE TABLE IF NOT EXISTS 'RolloverZoneState' (
 		zone                           TEXT NOT NULL PRIMARY KEY,
 		last_ds_submitted_index_low    INTEGER,
@@ -224,6 +313,71 @@ UNIQUE (keyname)
 		published_at   TEXT NOT NULL
 	)`,
 
+	// SkrBundles holds the imported Signed Key Response bundles of zones
+	// with an offline KSK (see ksr.go): one row per signing period, with
+	// the pre-signed DNSKEY/CDS/CDNSKEY RRsets as JSON. inception and
+	// expiration are unix seconds.
+	"SkrBundles": `CREATE TABLE IF NOT EXISTS 'SkrBundles' (
+		zone         TEXT NOT NULL,
+		inception    INTEGER NOT NULL,
+		expiration   INTEGER NOT NULL,
+		request_id   TEXT NOT NULL,
+		bundle       TEXT NOT NULL,
+		imported_at  TEXT NOT NULL,
+		PRIMARY KEY (zone, inception)
+	)`,
+
+	// AuditLog is the append-only journal of zone content and keystore
+	// mutations (see db_audit_log.go). old_data / new_data hold the
+	// affected RRsets in presentation format, one RR per line; key
+	// mutations use keyid + old_state / new_state instead. ts is a
+	// fixed-width UTC timestamp so lexical order is time order for the
+	// window filters.
+	"AuditLog": `CREATE TABLE IF NOT EXISTS 'AuditLog' (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		ts          TEXT NOT NULL,
+		actor_type  TEXT NOT NULL,
+		actor       TEXT NOT NULL DEFAULT '',
+		source      TEXT NOT NULL DEFAULT '',
+		zone        TEXT NOT NULL DEFAULT '',
+		action      TEXT NOT NULL,
+		old_data    TEXT NOT NULL DEFAULT '',
+		new_data    TEXT NOT NULL DEFAULT '',
+		keyid       INTEGER,
+		old_state   TEXT NOT NULL DEFAULT '',
+		new_state   TEXT NOT NULL DEFAULT '',
+		serial      INTEGER,
+		detail      TEXT NOT NULL DEFAULT ''
+	)`,
+
+	// ZoneChangeSet holds named, staged batches of zone content changes
+	// (see changeset.go). ops is the JSON list of operations; before_data
+	// and after_data are JSON RRset images recorded when the set is
+	// applied, used for rollback. approved_diff is the JSON diff that was
+	// approved; the set is only applied while it still computes the same. Timestamps are RFC3339 UTC, '' if unset.
+	"ZoneChangeSet": `CREATE TABLE IF NOT EXISTS 'ZoneChangeSet' (
+		id              INTEGER PRIMARY KEY AUTOINCREMENT,
+		zone            TEXT NOT NULL,
+		name            TEXT NOT NULL,
+		state           TEXT NOT NULL,
+		comment         TEXT NOT NULL DEFAULT '',
+		ops             TEXT NOT NULL,
+		created_by      TEXT NOT NULL DEFAULT '',
+		created_at      TEXT NOT NULL,
+		approved_by     TEXT NOT NULL DEFAULT '',
+		approved_at     TEXT NOT NULL DEFAULT '',
+		approved_serial INTEGER NOT NULL DEFAULT 0,
+		approved_diff   TEXT NOT NULL DEFAULT '',
+		activate_at     TEXT NOT NULL DEFAULT '',
+		applied_at      TEXT NOT NULL DEFAULT '',
+		applied_serial  INTEGER NOT NULL DEFAULT 0,
+		rolled_back_at  TEXT NOT NULL DEFAULT '',
+		before_data     TEXT NOT NULL DEFAULT '',
+		after_data      TEXT NOT NULL DEFAULT '',
+		error           TEXT NOT NULL DEFAULT '',
+		UNIQUE (zone, name)
+	)`,
+
 	// RolloverDaemonSentinel is a single-row table written by the auth
 	// daemon on startup with its PID and start time. CLI --offline
 	// writers (rollover-overhaul phase 12b) read this and refuse to
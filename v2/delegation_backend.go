@@ -4,6 +4,8 @@
 package tdns
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"os"
 	"sort"
@@ -35,12 +37,24 @@ type DelegationBackend interface {
 
 // DelegationBackendConf is a named backend definition from the config file.
 // Predefined backends ("db", "direct") need no config entry. Named backends
-// are only needed for types that require parameters (e.g. "zonefile").
+// are only needed for types that require parameters (e.g. "zonefile", "epp").
 type DelegationBackendConf struct {
-	Name          string `yaml:"name"`
-	Type          string `yaml:"type"`
-	Directory     string `yaml:"directory"`      // zonefile backend
-	NotifyCommand string `yaml:"notify-command"` // zonefile backend
+	Name          string `yaml:"name" mapstructure:"name"`
+	Type          string `yaml:"type" mapstructure:"type"`
+	Directory     string `yaml:"directory" mapstructure:"directory"`           // zonefile backend
+	NotifyCommand string `yaml:"notify-command" mapstructure:"notify-command"` // zonefile backend
+	Server        string `yaml:"server" mapstructure:"server"`                 // epp backend: host:port
+	Credentials   string `yaml:"credentials" mapstructure:"credentials"`       // epp backend: keystore entry, default the backend name
+	TLSCAFile     string `yaml:"tls-ca-file" mapstructure:"tls-ca-file"`       // epp and http backends
+	TLSCertFile   string `yaml:"tls-cert-file" mapstructure:"tls-cert-file"`   // epp and http backends: client certificate
+	TLSKeyFile    string `yaml:"tls-key-file" mapstructure:"tls-key-file"`     // epp and http backends
+	RetryInterval string `yaml:"retry-interval" mapstructure:"retry-interval"` // epp and http backends: initial retry backoff
+	URL           string `yaml:"url" mapstructure:"url"`                       // http backend: POST endpoint
+	ChildrenURL   string `yaml:"children-url" mapstructure:"children-url"`     // http backend: GET, {PARENT}
+	DataURL       string `yaml:"data-url" mapstructure:"data-url"`             // http backend: GET, {PARENT} and {CHILD}
+	Signing       string `yaml:"signing" mapstructure:"signing"`               // http backend: hmac | jws
+	SigningKey    string `yaml:"signing-key" mapstructure:"signing-key"`       // http backend: TSIG key (hmac) or SIG(0) key owner (jws)
+	MaxAttempts   int    `yaml:"max-attempts" mapstructure:"max-attempts"`     // http backend: 0 means retry forever
 }
 
 // LookupDelegationBackend resolves a backend name to a DelegationBackend.
@@ -50,6 +64,9 @@ type DelegationBackendConf struct {
 //   - "direct" → DirectDelegationBackend (modifies in-memory zone data)
 //
 // Any other name is looked up in the "delegationbackends" config list.
+// Backends that push to a remote system ("epp", "http") are long-lived (they own an
+// outbox worker and possibly a session) and are shared by all zones that
+// name them.
 func LookupDelegationBackend(name string, kdb *KeyDB, zd *ZoneData) (DelegationBackend, error) {
 	switch name {
 	case "db":
@@ -83,6 +100,14 @@ func LookupDelegationBackend(name string, kdb *KeyDB, zd *ZoneData) (DelegationB
 				notifyCommand: bc.NotifyCommand,
 				kdb:           kdb,
 			}, nil
+		case "epp":
+			return lookupOutboxBackend(bc, kdb, func() (outboxBackend, error) {
+				return NewEppDelegationBackend(bc, kdb)
+			})
+		case "http":
+			return lookupOutboxBackend(bc, kdb, func() (outboxBackend, error) {
+				return NewHttpDelegationBackend(bc, kdb)
+			})
 		default:
 			return nil, fmt.Errorf("delegation backend %q: unknown type %q", name, bc.Type)
 		}
@@ -162,3 +187,29 @@ func ExportDelegationData(backend DelegationBackend, parentZone, outfile string,
 	lg.Info("ExportDelegationData: wrote file", "zone", parentZone, "file", outfile, "children", len(children))
 	return nil
 }
+
+// backendTLSConfig builds the client TLS configuration of a backend that
+// talks to a remote system: an optional private CA and an optional client
+// certificate (most registries require one in addition to their login).
+func backendTLSConfig(bc DelegationBackendConf) (*tls.Config, error) {
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if bc.TLSCAFile != "" {
+		pem, err := os.ReadFile(bc.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls-ca-file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls-ca-file %s: no certificates found", bc.TLSCAFile)
+		}
+		conf.RootCAs = pool
+	}
+	if bc.TLSCertFile != "" || bc.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(bc.TLSCertFile, bc.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls-cert-file/tls-key-file: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
@@ -0,0 +1,634 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johani@johani.org
+ *
+ * EppDelegationBackend pushes approved child updates to a registry over EPP.
+ * The DB backend stays the local source of truth (GetDelegationData and
+ * ListChildren read from it); every ApplyChildUpdate additionally computes
+ * the per-child difference and queues it in the DelegationOutbox. A worker
+ * per backend drains the outbox over a single EPP session:
+ *
+ *	host:check           every host that must exist afterwards
+ *	host:create/update   new hosts, added glue addresses
+ *	domain:update        NS add/rem, secDNS-1.1 DS add/rem
+ *	host:update/delete   removed glue addresses, orphaned glue hosts
+ *
+ * The command list is fixed (and persisted) on the first delivery attempt,
+ * so a retry after a transient failure resumes at the command that failed
+ * instead of replaying creates the registry has already accepted. A resumed
+ * domain:update is first compared with domain:info, since the registry may
+ * have executed it without us seeing the answer.
+ *
+ * The mirror is written before delivery. When a change fails permanently,
+ * or is delivered only after earlier attempts failed, the child's NS, DS
+ * and glue in the mirror are reconciled from domain:info and host:info so
+ * that the local view matches what the registry actually has.
+ */
+package tdns
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultEppRetryInterval = 30 * time.Second
+	maxEppRetryInterval     = 30 * time.Minute
+	defaultEppIdleTimeout   = 5 * time.Minute
+)
+
+type EppDelegationBackend struct {
+	backendName string
+	bconf       DelegationBackendConf
+	kdb         *KeyDB
+	mirror      *DBDelegationBackend
+	client      *EppClient
+	worker      *outboxWorker
+}
+
+// eppDSData is one secDNS:dsData element.
+type eppDSData struct {
+	KeyTag     uint16 `json:"keytag"`
+	Algorithm  uint8  `json:"alg"`
+	DigestType uint8  `json:"digesttype"`
+	Digest     string `json:"digest"`
+}
+
+// eppChildUpdate is the outbox payload: the change to one registry domain,
+// derived from the before/after delegation data of the child.
+type eppChildUpdate struct {
+	Domain     string              `json:"domain"`
+	NSAdd      []string            `json:"nsadd,omitempty"`
+	NSRem      []string            `json:"nsrem,omitempty"`
+	DSAdd      []eppDSData         `json:"dsadd,omitempty"`
+	DSRem      []eppDSData         `json:"dsrem,omitempty"`
+	DSRemAll   bool                `json:"dsremall,omitempty"`
+	HostAdd    map[string][]string `json:"hostadd,omitempty"` // host → addresses to add
+	HostRem    map[string][]string `json:"hostrem,omitempty"` // host → addresses to remove
+	HostDelete []string            `json:"hostdelete,omitempty"`
+}
+
+func (u *eppChildUpdate) empty() bool {
+	return len(u.NSAdd)+len(u.NSRem)+len(u.DSAdd)+len(u.DSRem)+len(u.HostAdd)+len(u.HostRem)+len(u.HostDelete) == 0 && !u.DSRemAll
+}
+
+// eppCommand is one step of a delivery; eppProgress is the outbox progress.
+type eppCommand struct {
+	Name string          `json:"name"`
+	Body string          `json:"body"`
+	Plan *eppChildUpdate `json:"plan,omitempty"` // domain:update: the NS/DS part of the plan
+}
+
+type eppProgress struct {
+	Commands []eppCommand `json:"commands"`
+	Done     int          `json:"done"`
+}
+
+// NewEppDelegationBackend validates bc and returns a stopped backend.
+func NewEppDelegationBackend(bc DelegationBackendConf, kdb *KeyDB) (*EppDelegationBackend, error) {
+	if bc.Server == "" {
+		return nil, fmt.Errorf("delegation backend %q (type epp): server is required", bc.Name)
+	}
+	tlsconf, err := backendTLSConfig(bc)
+	if err != nil {
+		return nil, fmt.Errorf("delegation backend %q (type epp): %w", bc.Name, err)
+	}
+	retry := defaultEppRetryInterval
+	if bc.RetryInterval != "" {
+		retry, err = time.ParseDuration(bc.RetryInterval)
+		if err != nil || retry <= 0 {
+			return nil, fmt.Errorf("delegation backend %q (type epp): bad retry-interval %q", bc.Name, bc.RetryInterval)
+		}
+	}
+	credname := bc.Credentials
+	if credname == "" {
+		credname = bc.Name
+	}
+	b := &EppDelegationBackend{
+		backendName: bc.Name,
+		bconf:       bc,
+		kdb:         kdb,
+		mirror:      &DBDelegationBackend{kdb: kdb},
+	}
+	b.client = &EppClient{
+		Server:    bc.Server,
+		TLSConfig: tlsconf,
+		Credentials: func() (string, string, error) {
+			return kdb.GetEppCredential(credname)
+		},
+	}
+	b.worker = &outboxWorker{
+		backend:       bc.Name,
+		kdb:           kdb,
+		deliver:       b.deliver,
+		transient:     eppTransient,
+		idle:          b.closeIdleSession,
+		idleTimeout:   defaultEppIdleTimeout,
+		retryInterval: retry,
+		maxRetry:      maxEppRetryInterval,
+	}
+	return b, nil
+}
+
+func (b *EppDelegationBackend) Name() string { return b.backendName }
+
+// ApplyChildUpdate persists the update to the DB mirror and queues the
+// resulting change of each affected child for delivery to the registry.
+// It does not wait for the registry: delivery failures surface in the
+// outbox (`tdns-cli auth del queue`) and in the audit log.
+func (b *EppDelegationBackend) ApplyChildUpdate(parentZone string, ur UpdateRequest) error {
+	_, err := applyAndQueue(b.kdb, b.backendName, parentZone, ur,
+		func(child string, before, after map[string]map[uint16][]dns.RR) any {
+			if plan := eppPlanFromDiff(child, before, after); !plan.empty() {
+				return plan
+			}
+			return nil
+		})
+	b.worker.kick()
+	return err
+}
+
+func (b *EppDelegationBackend) GetDelegationData(parentZone, childZone string) (map[string]map[uint16][]dns.RR, error) {
+	return b.mirror.GetDelegationData(parentZone, childZone)
+}
+
+func (b *EppDelegationBackend) ListChildren(parentZone string) ([]string, error) {
+	return b.mirror.ListChildren(parentZone)
+}
+
+func (b *EppDelegationBackend) Queue(parentZone string, all bool) ([]DelegationOutboxItem, error) {
+	return listDelegationOutbox(b.kdb, b.backendName, parentZone, all)
+}
+
+func (b *EppDelegationBackend) Retry(parentZone string) (int, error) {
+	n, err := retryDelegationOutbox(b.kdb, b.backendName, parentZone)
+	if n > 0 {
+		b.worker.kick()
+	}
+	return n, err
+}
+
+func (b *EppDelegationBackend) Start()                      { b.worker.Start() }
+func (b *EppDelegationBackend) Stop()                       { b.worker.Stop() }
+func (b *EppDelegationBackend) conf() DelegationBackendConf { return b.bconf }
+func (b *EppDelegationBackend) keydb() *KeyDB               { return b.kdb }
+
+// eppPlanFromDiff computes the registry change that turns the delegation
+// data before into after. Only NS, DS and in-bailiwick A/AAAA glue are
+// represented in EPP; other types in the child's data are ignored.
+func eppPlanFromDiff(child string, before, after map[string]map[uint16][]dns.RR) eppChildUpdate {
+	plan := eppChildUpdate{Domain: eppName(child)}
+
+	nsBefore, nsAfter := eppNSSet(before, child), eppNSSet(after, child)
+	plan.NSAdd = setMinus(nsAfter, nsBefore)
+	plan.NSRem = setMinus(nsBefore, nsAfter)
+
+	dsBefore, dsAfter := eppDSSet(before, child), eppDSSet(after, child)
+	if len(dsAfter) == 0 && len(dsBefore) > 0 {
+		// Going insecure: remove everything the registry has, not just
+		// what we believe it has.
+		plan.DSRemAll = true
+	} else {
+		for _, k := range setMinus(dsAfter, dsBefore) {
+			plan.DSAdd = append(plan.DSAdd, dsAfter[k])
+		}
+		for _, k := range setMinus(dsBefore, dsAfter) {
+			plan.DSRem = append(plan.DSRem, dsBefore[k])
+		}
+	}
+
+	hosts := map[string]bool{}
+	for owner := range before {
+		hosts[owner] = true
+	}
+	for owner := range after {
+		hosts[owner] = true
+	}
+	for owner := range hosts {
+		if owner == child {
+			continue
+		}
+		ab, aa := eppAddrSet(before, owner), eppAddrSet(after, owner)
+		host := eppName(owner)
+		if add := setMinus(aa, ab); len(add) > 0 {
+			if plan.HostAdd == nil {
+				plan.HostAdd = map[string][]string{}
+			}
+			plan.HostAdd[host] = add
+		}
+		if rem := setMinus(ab, aa); len(rem) > 0 {
+			if len(aa) == 0 && !nsAfter[host] {
+				plan.HostDelete = append(plan.HostDelete, host)
+				continue
+			}
+			if plan.HostRem == nil {
+				plan.HostRem = map[string][]string{}
+			}
+			plan.HostRem[host] = rem
+		}
+	}
+	sort.Strings(plan.HostDelete)
+	return plan
+}
+
+func eppNSSet(data map[string]map[uint16][]dns.RR, child string) map[string]bool {
+	set := map[string]bool{}
+	for _, rr := range data[child][dns.TypeNS] {
+		if ns, ok := rr.(*dns.NS); ok {
+			set[eppName(ns.Ns)] = true
+		}
+	}
+	return set
+}
+
+func eppDSSet(data map[string]map[uint16][]dns.RR, child string) map[string]eppDSData {
+	set := map[string]eppDSData{}
+	for _, rr := range data[child][dns.TypeDS] {
+		if ds, ok := rr.(*dns.DS); ok {
+			d := eppDSData{KeyTag: ds.KeyTag, Algorithm: ds.Algorithm, DigestType: ds.DigestType, Digest: strings.ToUpper(ds.Digest)}
+			set[eppDSKey(d)] = d
+		}
+	}
+	return set
+}
+
+func eppAddrSet(data map[string]map[uint16][]dns.RR, owner string) map[string]bool {
+	set := map[string]bool{}
+	for _, rr := range data[owner][dns.TypeA] {
+		set[rr.(*dns.A).A.String()] = true
+	}
+	for _, rr := range data[owner][dns.TypeAAAA] {
+		set[rr.(*dns.AAAA).AAAA.String()] = true
+	}
+	return set
+}
+
+// setMinus returns the sorted keys of a that are not in b.
+func setMinus[V any](a, b map[string]V) []string {
+	var out []string
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// closeIdleSession logs out once the outbox has been empty for the idle
+// timeout, and on shutdown. The next delivery logs in again.
+func (b *EppDelegationBackend) closeIdleSession() {
+	if b.client.Connected() {
+		lg.Debug("EppDelegationBackend: closing idle session", "backend", b.backendName)
+		b.client.Close()
+	}
+}
+
+// deliver runs (or resumes) the EPP commands of one outbox item and records
+// the outcome. A transient error leaves the item pending; any other error
+// marks it failed so that the queue moves on.
+func (b *EppDelegationBackend) deliver(item *DelegationOutboxItem) error {
+	var prog eppProgress
+	resumed := item.Progress != ""
+	if resumed {
+		if err := json.Unmarshal([]byte(item.Progress), &prog); err != nil {
+			return b.finish(item, fmt.Errorf("corrupt progress: %w", err))
+		}
+	}
+	if prog.Commands == nil {
+		var plan eppChildUpdate
+		if err := json.Unmarshal([]byte(item.Payload), &plan); err != nil {
+			return b.finish(item, fmt.Errorf("corrupt payload: %w", err))
+		}
+		cmds, err := b.buildCommands(plan)
+		if err != nil {
+			return b.finish(item, err)
+		}
+		prog.Commands = cmds
+		if err := b.saveProgress(item, prog); err != nil {
+			return err
+		}
+	}
+
+	for prog.Done < len(prog.Commands) {
+		cmd := prog.Commands[prog.Done]
+		body := cmd.Body
+		if resumed && cmd.Name == "domain:update" && cmd.Plan != nil {
+			rest, err := b.remainingDomainUpdate(*cmd.Plan)
+			if err != nil {
+				return b.finish(item, err)
+			}
+			body = ""
+			if !rest.empty() {
+				body = eppDomainUpdate(rest)
+			}
+		}
+		if body != "" {
+			if _, err := b.client.Exec(cmd.Name, body); err != nil && !eppAlreadyDone(cmd.Name, err) {
+				return b.finish(item, err)
+			}
+		}
+		prog.Done++
+		if err := b.saveProgress(item, prog); err != nil {
+			return err
+		}
+	}
+	return b.finish(item, nil)
+}
+
+func (b *EppDelegationBackend) saveProgress(item *DelegationOutboxItem, prog eppProgress) error {
+	buf, err := json.Marshal(prog)
+	if err != nil {
+		return err
+	}
+	item.Progress = string(buf)
+	return saveDelegationOutbox(b.kdb, item)
+}
+
+func (b *EppDelegationBackend) finish(item *DelegationOutboxItem, err error) error {
+	// Attempts is the number of failed attempts before this one.
+	failedBefore := item.Attempts > 0
+	finishOutboxItem(b.kdb, item, err, eppTransient(err), 0, "epp")
+	if item.State == OutboxFailed || (item.State == OutboxDone && failedBefore) {
+		b.reconcileMirror(item)
+	}
+	return err
+}
+
+// remainingDomainUpdate returns the part of plan that domain:info shows is
+// not yet in effect at the registry.
+func (b *EppDelegationBackend) remainingDomainUpdate(plan eppChildUpdate) (eppChildUpdate, error) {
+	res, err := b.client.Exec("domain:info", eppDomainInfo(plan.Domain))
+	if err != nil {
+		return plan, err
+	}
+	ns := map[string]bool{}
+	for _, h := range res.NS {
+		ns[h] = true
+	}
+	ds := map[string]bool{}
+	for _, d := range res.DS {
+		ds[eppDSKey(d)] = true
+	}
+
+	rest := eppChildUpdate{Domain: plan.Domain, DSRemAll: plan.DSRemAll && len(res.DS) > 0}
+	for _, h := range plan.NSAdd {
+		if !ns[h] {
+			rest.NSAdd = append(rest.NSAdd, h)
+		}
+	}
+	for _, h := range plan.NSRem {
+		if ns[h] {
+			rest.NSRem = append(rest.NSRem, h)
+		}
+	}
+	for _, d := range plan.DSAdd {
+		if !ds[eppDSKey(d)] {
+			rest.DSAdd = append(rest.DSAdd, d)
+		}
+	}
+	if !rest.DSRemAll {
+		for _, d := range plan.DSRem {
+			if ds[eppDSKey(d)] {
+				rest.DSRem = append(rest.DSRem, d)
+			}
+		}
+	}
+	if len(rest.NSAdd)+len(rest.NSRem)+len(rest.DSAdd)+len(rest.DSRem) < len(plan.NSAdd)+len(plan.NSRem)+len(plan.DSAdd)+len(plan.DSRem) {
+		lg.Info("EppDelegationBackend: domain:update partly in effect already", "backend", b.backendName,
+			"domain", plan.Domain, "remaining", rest)
+	}
+	return rest, nil
+}
+
+// reconcileMirror replaces the NS, DS and glue of item's child in the DB
+// mirror with what the registry has. A registry that does not know the
+// domain has no delegation for it. Errors are logged: the mirror then keeps
+// the data of the failed change until the next reconciliation.
+func (b *EppDelegationBackend) reconcileMirror(item *DelegationOutboxItem) {
+	if later, err := laterDelegationOutbox(b.kdb, item); err != nil || later {
+		// A queued change for the same child was planned on top of
+		// this one; it reconciles when it completes.
+		return
+	}
+	child := dns.Fqdn(item.Child)
+	var nsNames []string
+	var dsSet []eppDSData
+	res, err := b.client.Exec("domain:info", eppDomainInfo(eppName(child)))
+	switch {
+	case err == nil:
+		nsNames, dsSet = res.NS, res.DS
+	case !isEppCode(err, 2303):
+		lg.Error("EppDelegationBackend: cannot reconcile mirror", "backend", b.backendName, "child", child, "err", err)
+		return
+	}
+
+	var actions []dns.RR
+	current, _ := b.mirror.GetDelegationData(item.Parent, child)
+	for owner, rrsets := range current {
+		types := []uint16{dns.TypeA, dns.TypeAAAA}
+		if owner == child {
+			types = []uint16{dns.TypeNS, dns.TypeDS}
+		}
+		for _, t := range types {
+			if len(rrsets[t]) > 0 {
+				actions = append(actions, &dns.ANY{Hdr: dns.RR_Header{Name: owner, Rrtype: t, Class: dns.ClassANY}})
+			}
+		}
+	}
+	for _, h := range nsNames {
+		host := dns.Fqdn(h)
+		actions = append(actions, &dns.NS{Hdr: dns.RR_Header{Name: child, Rrtype: dns.TypeNS, Class: dns.ClassINET}, Ns: host})
+		if !dns.IsSubDomain(child, host) {
+			continue
+		}
+		hres, err := b.client.Exec("host:info", `<info><host:info xmlns:host="`+eppHostNS+`"><host:name>`+eppEsc(h)+`</host:name></host:info></info>`)
+		if err != nil {
+			lg.Error("EppDelegationBackend: cannot reconcile mirror", "backend", b.backendName, "child", child, "host", h, "err", err)
+			return
+		}
+		for _, a := range hres.Addrs {
+			rrtype := "A"
+			if strings.Contains(a, ":") {
+				rrtype = "AAAA"
+			}
+			if rr, err := dns.NewRR(host + " 0 IN " + rrtype + " " + a); err == nil {
+				actions = append(actions, rr)
+			}
+		}
+	}
+	for _, d := range dsSet {
+		actions = append(actions, &dns.DS{Hdr: dns.RR_Header{Name: child, Rrtype: dns.TypeDS, Class: dns.ClassINET},
+			KeyTag: d.KeyTag, Algorithm: d.Algorithm, DigestType: d.DigestType, Digest: d.Digest})
+	}
+	if err := b.mirror.ApplyChildUpdate(item.Parent, UpdateRequest{Actions: actions}); err != nil {
+		lg.Error("EppDelegationBackend: cannot reconcile mirror", "backend", b.backendName, "child", child, "err", err)
+		return
+	}
+	lg.Info("EppDelegationBackend: mirror reconciled from registry", "backend", b.backendName,
+		"child", child, "id", item.ID, "ns", nsNames, "ds", len(dsSet))
+}
+
+func isEppCode(err error, code int) bool {
+	ee, ok := err.(*EppError)
+	return ok && ee.Code == code
+}
+
+func eppDSKey(d eppDSData) string {
+	return fmt.Sprintf("%d %d %d %s", d.KeyTag, d.Algorithm, d.DigestType, strings.ToUpper(d.Digest))
+}
+
+func eppDomainInfo(domain string) string {
+	return `<info><domain:info xmlns:domain="` + eppDomainNS + `"><domain:name>` + eppEsc(domain) + `</domain:name></domain:info></info>`
+}
+
+// eppAlreadyDone reports results that mean the command's goal is already
+// met: the host to create exists, or the host to delete is gone or still
+// used by another domain (which then keeps it).
+func eppAlreadyDone(name string, err error) bool {
+	ee, ok := err.(*EppError)
+	if !ok {
+		return false
+	}
+	switch name {
+	case "host:create":
+		return ee.Code == 2302
+	case "host:delete":
+		return ee.Code == 2303 || ee.Code == 2305
+	}
+	return false
+}
+
+// buildCommands turns a plan into the ordered EPP command list. It queries
+// the registry (host:check) for the hosts the domain will need, which is why
+// the result is computed once and persisted with the outbox item.
+func (b *EppDelegationBackend) buildCommands(plan eppChildUpdate) ([]eppCommand, error) {
+	need := map[string]bool{}
+	for _, h := range plan.NSAdd {
+		need[h] = true
+	}
+	for h := range plan.HostAdd {
+		need[h] = true
+	}
+	hosts := make([]string, 0, len(need))
+	for h := range need {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+
+	cmds := []eppCommand{}
+	if len(hosts) > 0 {
+		var names strings.Builder
+		for _, h := range hosts {
+			names.WriteString("<host:name>" + eppEsc(h) + "</host:name>")
+		}
+		res, err := b.client.Exec("host:check", `<check><host:check xmlns:host="`+eppHostNS+`">`+names.String()+`</host:check></check>`)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hosts {
+			if res.HostChk[h] {
+				cmds = append(cmds, eppCommand{Name: "host:create", Body: eppHostCreate(h, plan.HostAdd[h])})
+			} else if len(plan.HostAdd[h]) > 0 {
+				cmds = append(cmds, eppCommand{Name: "host:update", Body: eppHostUpdate(h, plan.HostAdd[h], nil)})
+			}
+		}
+	}
+
+	if len(plan.NSAdd)+len(plan.NSRem)+len(plan.DSAdd)+len(plan.DSRem) > 0 || plan.DSRemAll {
+		dplan := eppChildUpdate{Domain: plan.Domain, NSAdd: plan.NSAdd, NSRem: plan.NSRem,
+			DSAdd: plan.DSAdd, DSRem: plan.DSRem, DSRemAll: plan.DSRemAll}
+		cmds = append(cmds, eppCommand{Name: "domain:update", Body: eppDomainUpdate(dplan), Plan: &dplan})
+	}
+
+	remHosts := make([]string, 0, len(plan.HostRem))
+	for h := range plan.HostRem {
+		remHosts = append(remHosts, h)
+	}
+	sort.Strings(remHosts)
+	for _, h := range remHosts {
+		cmds = append(cmds, eppCommand{Name: "host:update", Body: eppHostUpdate(h, nil, plan.HostRem[h])})
+	}
+	for _, h := range plan.HostDelete {
+		cmds = append(cmds, eppCommand{Name: "host:delete",
+			Body: `<delete><host:delete xmlns:host="` + eppHostNS + `"><host:name>` + eppEsc(h) + `</host:name></host:delete></delete>`})
+	}
+	return cmds, nil
+}
+
+func eppHostAddrs(addrs []string) string {
+	var sb strings.Builder
+	for _, a := range addrs {
+		ver := "v4"
+		if strings.Contains(a, ":") {
+			ver = "v6"
+		}
+		sb.WriteString(`<host:addr ip="` + ver + `">` + eppEsc(a) + `</host:addr>`)
+	}
+	return sb.String()
+}
+
+func eppHostCreate(host string, addrs []string) string {
+	return `<create><host:create xmlns:host="` + eppHostNS + `"><host:name>` + eppEsc(host) + `</host:name>` +
+		eppHostAddrs(addrs) + `</host:create></create>`
+}
+
+func eppHostUpdate(host string, add, rem []string) string {
+	body := `<update><host:update xmlns:host="` + eppHostNS + `"><host:name>` + eppEsc(host) + `</host:name>`
+	if len(add) > 0 {
+		body += `<host:add>` + eppHostAddrs(add) + `</host:add>`
+	}
+	if len(rem) > 0 {
+		body += `<host:rem>` + eppHostAddrs(rem) + `</host:rem>`
+	}
+	return body + `</host:update></update>`
+}
+
+func eppDSXML(ds []eppDSData) string {
+	var sb strings.Builder
+	for _, d := range ds {
+		fmt.Fprintf(&sb, `<secDNS:dsData><secDNS:keyTag>%d</secDNS:keyTag><secDNS:alg>%d</secDNS:alg>`+
+			`<secDNS:digestType>%d</secDNS:digestType><secDNS:digest>%s</secDNS:digest></secDNS:dsData>`,
+			d.KeyTag, d.Algorithm, d.DigestType, eppEsc(d.Digest))
+	}
+	return sb.String()
+}
+
+// eppDomainUpdate renders domain:update with NS changes as hostObj and DS
+// changes in the secDNS-1.1 update extension (RFC 5910 section 5.2.5,
+// rem before add).
+func eppDomainUpdate(plan eppChildUpdate) string {
+	hostObjs := func(hs []string) string {
+		var sb strings.Builder
+		for _, h := range hs {
+			sb.WriteString(`<domain:hostObj>` + eppEsc(h) + `</domain:hostObj>`)
+		}
+		return `<domain:ns>` + sb.String() + `</domain:ns>`
+	}
+	body := `<update><domain:update xmlns:domain="` + eppDomainNS + `"><domain:name>` + eppEsc(plan.Domain) + `</domain:name>`
+	if len(plan.NSAdd) > 0 {
+		body += `<domain:add>` + hostObjs(plan.NSAdd) + `</domain:add>`
+	}
+	if len(plan.NSRem) > 0 {
+		body += `<domain:rem>` + hostObjs(plan.NSRem) + `</domain:rem>`
+	}
+	body += `</domain:update></update>`
+
+	if len(plan.DSAdd)+len(plan.DSRem) == 0 && !plan.DSRemAll {
+		return body
+	}
+	ext := `<extension><secDNS:update xmlns:secDNS="` + eppSecDNSNS + `">`
+	if plan.DSRemAll {
+		ext += `<secDNS:rem><secDNS:all>true</secDNS:all></secDNS:rem>`
+	} else if len(plan.DSRem) > 0 {
+		ext += `<secDNS:rem>` + eppDSXML(plan.DSRem) + `</secDNS:rem>`
+	}
+	if len(plan.DSAdd) > 0 {
+		ext += `<secDNS:add>` + eppDSXML(plan.DSAdd) + `</secDNS:add>`
+	}
+	return body + ext + `</secDNS:update></extension>`
+}
@@ -0,0 +1,333 @@
+package tdns
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johanix/tdns/v2/eppmock"
+	"github.com/miekg/dns"
+)
+
+func eppTestRRs(t *testing.T, class uint16, rrs ...string) []dns.RR {
+	t.Helper()
+	var out []dns.RR
+	for _, s := range rrs {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatalf("NewRR(%q): %v", s, err)
+		}
+		rr.Header().Class = class
+		out = append(out, rr)
+	}
+	return out
+}
+
+// newTestEppBackend returns a started epp backend talking to a fresh mock
+// registry that knows the domain child.example and the login in the keystore.
+func newTestEppBackend(t *testing.T) (*EppDelegationBackend, *eppmock.Server, *KeyDB) {
+	t.Helper()
+	srv, err := eppmock.NewServer()
+	if err != nil {
+		t.Fatalf("eppmock.NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	srv.AddUser("registrar-1", "s3cret")
+	srv.AddDomain("child.example")
+
+	kdb := newTestKeyDB(t)
+	if _, err := kdb.EppKeyMgmt(nil, KeystorePost{SubCommand: "add", EppName: "registry", EppClid: "registrar-1", EppPassword: "s3cret"}); err != nil {
+		t.Fatalf("EppKeyMgmt add: %v", err)
+	}
+	b, err := NewEppDelegationBackend(DelegationBackendConf{Name: "registry", Type: "epp", Server: srv.Addr(), RetryInterval: "20ms"}, kdb)
+	if err != nil {
+		t.Fatalf("NewEppDelegationBackend: %v", err)
+	}
+	b.client.TLSConfig = &tls.Config{RootCAs: srv.CertPool()}
+	b.Start()
+	t.Cleanup(b.Stop)
+	return b, srv, kdb
+}
+
+func waitEppQueue(t *testing.T, b *EppDelegationBackend, parent string, cond func([]DelegationOutboxItem) bool) []DelegationOutboxItem {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		items, err := b.Queue(parent, true)
+		if err != nil {
+			t.Fatalf("Queue: %v", err)
+		}
+		if cond(items) {
+			return items
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for outbox, items: %+v", items)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func allOutbox(state string) func([]DelegationOutboxItem) bool {
+	return func(items []DelegationOutboxItem) bool {
+		for _, it := range items {
+			if it.State != state {
+				return false
+			}
+		}
+		return len(items) > 0
+	}
+}
+
+const eppTestDS = "child.example. 3600 IN DS 12345 13 2 0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF"
+
+func TestEppPlanFromDiff(t *testing.T) {
+	data := func(rrs ...string) map[string]map[uint16][]dns.RR {
+		m := map[string]map[uint16][]dns.RR{}
+		for _, rr := range eppTestRRs(t, dns.ClassINET, rrs...) {
+			h := rr.Header()
+			if m[h.Name] == nil {
+				m[h.Name] = map[uint16][]dns.RR{}
+			}
+			m[h.Name][h.Rrtype] = append(m[h.Name][h.Rrtype], rr)
+		}
+		return m
+	}
+	before := data(
+		"child.example. 3600 IN NS ns1.child.example.",
+		"child.example. 3600 IN NS ns.other.net.",
+		"ns1.child.example. 3600 IN A 192.0.2.1",
+		eppTestDS,
+	)
+	after := data(
+		"child.example. 3600 IN NS ns2.child.example.",
+		"child.example. 3600 IN NS ns.other.net.",
+		"ns2.child.example. 3600 IN AAAA 2001:db8::2",
+	)
+	plan := eppPlanFromDiff("child.example.", before, after)
+	if plan.Domain != "child.example" {
+		t.Errorf("Domain = %q", plan.Domain)
+	}
+	if strings.Join(plan.NSAdd, ",") != "ns2.child.example" || strings.Join(plan.NSRem, ",") != "ns1.child.example" {
+		t.Errorf("NS add/rem = %v / %v", plan.NSAdd, plan.NSRem)
+	}
+	if !plan.DSRemAll || len(plan.DSAdd) != 0 {
+		t.Errorf("expected DS rem all, got %+v", plan)
+	}
+	if got := plan.HostAdd["ns2.child.example"]; len(got) != 1 || got[0] != "2001:db8::2" {
+		t.Errorf("HostAdd = %v", plan.HostAdd)
+	}
+	if strings.Join(plan.HostDelete, ",") != "ns1.child.example" || len(plan.HostRem) != 0 {
+		t.Errorf("HostDelete = %v, HostRem = %v", plan.HostDelete, plan.HostRem)
+	}
+	if p := eppPlanFromDiff("child.example.", after, after); !p.empty() {
+		t.Errorf("identical data gave non-empty plan %+v", p)
+	}
+}
+
+func TestEppBackendDelivers(t *testing.T) {
+	b, srv, _ := newTestEppBackend(t)
+
+	err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"child.example. 3600 IN NS ns1.child.example.",
+		"child.example. 3600 IN NS ns.other.net.",
+		"ns1.child.example. 3600 IN A 192.0.2.1",
+		eppTestDS,
+	)})
+	if err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	waitEppQueue(t, b, "example.", allOutbox(OutboxDone))
+
+	ns, ds, _ := srv.Domain("child.example")
+	if strings.Join(ns, ",") != "ns.other.net,ns1.child.example" {
+		t.Errorf("registry NS = %v", ns)
+	}
+	if len(ds) != 1 || ds[0].KeyTag != 12345 || ds[0].Alg != 13 {
+		t.Errorf("registry DS = %+v", ds)
+	}
+	if addrs, _ := srv.Host("ns1.child.example"); strings.Join(addrs, ",") != "192.0.2.1" {
+		t.Errorf("glue = %v", addrs)
+	}
+	if data, err := b.GetDelegationData("example.", "child.example."); err != nil || len(data["child.example."][dns.TypeNS]) != 2 {
+		t.Errorf("mirror: %v %v", data, err)
+	}
+
+	// Replace the in-bailiwick server and go insecure. The old glue host
+	// must only be deleted after the domain no longer uses it.
+	actions := eppTestRRs(t, dns.ClassNONE, "child.example. 0 IN NS ns1.child.example.", "ns1.child.example. 0 IN A 192.0.2.1")
+	actions = append(actions, eppTestRRs(t, dns.ClassANY, "child.example. 0 IN DS 0 0 0 00")...)
+	actions = append(actions, eppTestRRs(t, dns.ClassINET, "child.example. 3600 IN NS ns2.child.example.", "ns2.child.example. 3600 IN A 192.0.2.2")...)
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: actions}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	waitEppQueue(t, b, "example.", allOutbox(OutboxDone))
+
+	ns, ds, _ = srv.Domain("child.example")
+	if strings.Join(ns, ",") != "ns.other.net,ns2.child.example" || len(ds) != 0 {
+		t.Errorf("registry after update: NS %v DS %v", ns, ds)
+	}
+	if _, ok := srv.Host("ns1.child.example"); ok {
+		t.Errorf("orphaned glue host ns1.child.example not deleted")
+	}
+}
+
+func TestEppBackendRetriesWhenUnavailable(t *testing.T) {
+	b, srv, _ := newTestEppBackend(t)
+	srv.SetUnavailable(true)
+
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"child.example. 3600 IN NS ns1.child.example.",
+		"ns1.child.example. 3600 IN A 192.0.2.1",
+	)}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	waitEppQueue(t, b, "example.", func(items []DelegationOutboxItem) bool {
+		return len(items) == 1 && items[0].State == OutboxPending && items[0].Attempts >= 2
+	})
+
+	// Back up, but the domain:update fails transiently once the host has
+	// been created: the retry must resume rather than recreate the host.
+	srv.FailNext("domain:update", 2400)
+	srv.SetUnavailable(false)
+	waitEppQueue(t, b, "example.", allOutbox(OutboxDone))
+
+	creates, updates := 0, 0
+	for _, c := range srv.Commands() {
+		switch c {
+		case "host:create":
+			creates++
+		case "domain:update":
+			updates++
+		}
+	}
+	if creates != 1 || updates != 2 {
+		t.Errorf("commands: %d host:create, %d domain:update; want 1 and 2 (%v)", creates, updates, srv.Commands())
+	}
+	if ns, _, _ := srv.Domain("child.example"); strings.Join(ns, ",") != "ns1.child.example" {
+		t.Errorf("registry NS = %v", ns)
+	}
+}
+
+func TestEppBackendPermanentFailure(t *testing.T) {
+	b, srv, kdb := newTestEppBackend(t)
+
+	// Not a domain this registrar has at the registry.
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"other.example. 3600 IN NS ns.other.net.",
+	)}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	items := waitEppQueue(t, b, "example.", allOutbox(OutboxFailed))
+	if !strings.Contains(items[0].LastError, "2303") {
+		t.Errorf("LastError = %q", items[0].LastError)
+	}
+	entries, err := kdb.QueryAuditLog(AuditFilter{Zone: "other.example."})
+	if err != nil || len(entries) != 1 || entries[0].Action != "epp-failed" {
+		t.Errorf("audit: %+v %v", entries, err)
+	}
+	// The registry has no delegation, so neither may the mirror.
+	if data, err := b.GetDelegationData("example.", "other.example."); err == nil {
+		t.Errorf("mirror kept the failed change: %v", data)
+	}
+
+	srv.AddDomain("other.example")
+	if n, err := b.Retry("example."); err != nil || n != 1 {
+		t.Fatalf("Retry = %d, %v", n, err)
+	}
+	waitEppQueue(t, b, "example.", allOutbox(OutboxDone))
+	if ns, _, _ := srv.Domain("other.example"); strings.Join(ns, ",") != "ns.other.net" {
+		t.Errorf("registry NS = %v", ns)
+	}
+	if data, err := b.GetDelegationData("example.", "other.example."); err != nil || len(data["other.example."][dns.TypeNS]) != 1 {
+		t.Errorf("mirror after retry: %v %v", data, err)
+	}
+}
+
+func TestEppBackendRollsBackMirror(t *testing.T) {
+	b, srv, _ := newTestEppBackend(t)
+
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"child.example. 3600 IN NS ns1.child.example.",
+		"ns1.child.example. 3600 IN A 192.0.2.1",
+		eppTestDS,
+	)}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	waitEppQueue(t, b, "example.", allOutbox(OutboxDone))
+
+	// The registry refuses the next change: the mirror must go back to
+	// what the registry still has.
+	srv.FailNext("domain:update", 2306)
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"child.example. 3600 IN NS ns.other.net.",
+	)}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	waitEppQueue(t, b, "example.", func(items []DelegationOutboxItem) bool {
+		return len(items) == 2 && items[1].State == OutboxFailed
+	})
+	data, err := b.GetDelegationData("example.", "child.example.")
+	if err != nil {
+		t.Fatalf("GetDelegationData: %v", err)
+	}
+	if ns := data["child.example."][dns.TypeNS]; len(ns) != 1 || ns[0].(*dns.NS).Ns != "ns1.child.example." {
+		t.Errorf("mirror NS = %v", ns)
+	}
+	if len(data["child.example."][dns.TypeDS]) != 1 || len(data["ns1.child.example."][dns.TypeA]) != 1 {
+		t.Errorf("mirror lost DS or glue: %v", data)
+	}
+}
+
+func TestEppBackendReplaysDomainUpdate(t *testing.T) {
+	b, srv, _ := newTestEppBackend(t)
+
+	// The registry executes the domain:update but the answer is lost. The
+	// retry must see that the change is in effect instead of sending it
+	// again (which the registry would reject with 2302).
+	srv.LoseReply("domain:update")
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"child.example. 3600 IN NS ns.other.net.",
+		eppTestDS,
+	)}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	waitEppQueue(t, b, "example.", allOutbox(OutboxDone))
+
+	updates := 0
+	for _, c := range srv.Commands() {
+		if c == "domain:update" {
+			updates++
+		}
+	}
+	if updates != 1 {
+		t.Errorf("domain:update sent %d times, want 1 (%v)", updates, srv.Commands())
+	}
+	ns, ds, _ := srv.Domain("child.example")
+	if strings.Join(ns, ",") != "ns.other.net" || len(ds) != 1 {
+		t.Errorf("registry: NS %v DS %v", ns, ds)
+	}
+}
+
+func TestEppKeyMgmt(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	if _, err := kdb.EppKeyMgmt(nil, KeystorePost{SubCommand: "add", EppName: "reg", EppClid: "c1"}); err == nil {
+		t.Errorf("add without password accepted")
+	}
+	if _, err := kdb.EppKeyMgmt(nil, KeystorePost{SubCommand: "add", EppName: "reg", EppClid: "c1", EppPassword: "pw"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	resp, err := kdb.EppKeyMgmt(nil, KeystorePost{SubCommand: "list"})
+	if err != nil || len(resp.EppCredentials) != 1 || resp.EppCredentials[0].ClID != "c1" {
+		t.Fatalf("list: %+v %v", resp, err)
+	}
+	if clid, pw, err := kdb.GetEppCredential("reg"); err != nil || clid != "c1" || pw != "pw" {
+		t.Errorf("GetEppCredential = %q %q %v", clid, pw, err)
+	}
+	if _, err := kdb.EppKeyMgmt(nil, KeystorePost{SubCommand: "delete", EppName: "reg"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, _, err := kdb.GetEppCredential("reg"); err == nil {
+		t.Errorf("credential still present after delete")
+	}
+}
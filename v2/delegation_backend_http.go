@@ -0,0 +1,448 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johani@johani.org
+ *
+ * HttpDelegationBackend pushes approved child updates to a REST endpoint
+ * (registrar API, IPAM, provisioning webhook). Like the epp backend it keeps
+ * the DB backend as a local mirror and queues the per-child difference in the
+ * DelegationOutbox; the worker POSTs each change as a WebhookDelegationChange
+ * and retries anything but a 2xx answer with backoff, until max-attempts
+ * (if set) is reached.
+ *
+ * Every request (the POSTs and the optional GETs) is signed, either
+ *
+ *	hmac: X-Tdns-Signature: <alg>=base64(HMAC(secret, ts \n method \n uri \n body))
+ *	      with the secret of the TSIG keystore entry named by signing-key, or
+ *	jws:  X-Tdns-Jws: a detached compact JWS (RFC 7515 appendix F) over the
+ *	      body, signed by the active SIG(0) key of signing-key
+ *	      (ES256/ES384/EdDSA). The protected header carries kid
+ *	      "<owner>/<keyid>", iat, htm, htu.
+ *
+ * and carries X-Tdns-Timestamp (unix seconds) so that the receiver can
+ * reject replays. POSTs also carry X-Tdns-Delivery, which is stable across
+ * retries of the same change and lets the receiver deduplicate.
+ */
+package tdns
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultHttpRetryInterval = 30 * time.Second
+	maxHttpRetryInterval     = 30 * time.Minute
+	httpBackendTimeout       = 30 * time.Second
+)
+
+// WebhookDelegationChange is the JSON body POSTed for each changed child.
+// All RRs are in presentation format. TTLs are those stored by the parent
+// (usually 0: the parent zone applies its own).
+type WebhookDelegationChange struct {
+	Parent string          `json:"parent"`
+	Child  string          `json:"child"`
+	Time   time.Time       `json:"time"`
+	NS     WebhookRRChange `json:"ns"`
+	DS     WebhookRRChange `json:"ds"`
+	Glue   WebhookRRChange `json:"glue"`
+}
+
+type WebhookRRChange struct {
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+// WebhookDelegationData is the answer expected from data-url.
+type WebhookDelegationData struct {
+	Child string   `json:"child"`
+	RRs   []string `json:"rrs"`
+}
+
+// WebhookChildren is the answer expected from children-url.
+type WebhookChildren struct {
+	Children []string `json:"children"`
+}
+
+type HttpDelegationBackend struct {
+	backendName string
+	bconf       DelegationBackendConf
+	kdb         *KeyDB
+	mirror      *DBDelegationBackend
+	client      *http.Client
+	worker      *outboxWorker
+}
+
+// webhookStatusError is a non-2xx answer from the endpoint.
+type webhookStatusError struct {
+	Status int
+	Body   string
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Status, e.Body)
+}
+
+// NewHttpDelegationBackend validates bc and returns a stopped backend.
+func NewHttpDelegationBackend(bc DelegationBackendConf, kdb *KeyDB) (*HttpDelegationBackend, error) {
+	fail := func(format string, args ...any) (*HttpDelegationBackend, error) {
+		return nil, fmt.Errorf("delegation backend %q (type http): %s", bc.Name, fmt.Sprintf(format, args...))
+	}
+	if bc.URL == "" {
+		return fail("url is required")
+	}
+	for _, u := range []string{bc.URL, bc.ChildrenURL, bc.DataURL} {
+		if u == "" {
+			continue
+		}
+		if pu, err := url.Parse(u); err != nil || (pu.Scheme != "https" && pu.Scheme != "http") {
+			return fail("bad url %q", u)
+		}
+	}
+	switch bc.Signing {
+	case "hmac", "jws":
+	default:
+		return fail("signing must be hmac or jws, not %q", bc.Signing)
+	}
+	if bc.SigningKey == "" {
+		return fail("signing-key is required")
+	}
+	if bc.MaxAttempts < 0 {
+		return fail("max-attempts must not be negative")
+	}
+	tlsconf, err := backendTLSConfig(bc)
+	if err != nil {
+		return fail("%v", err)
+	}
+	retry := defaultHttpRetryInterval
+	if bc.RetryInterval != "" {
+		retry, err = time.ParseDuration(bc.RetryInterval)
+		if err != nil || retry <= 0 {
+			return fail("bad retry-interval %q", bc.RetryInterval)
+		}
+	}
+
+	b := &HttpDelegationBackend{
+		backendName: bc.Name,
+		bconf:       bc,
+		kdb:         kdb,
+		mirror:      &DBDelegationBackend{kdb: kdb},
+		client: &http.Client{
+			Timeout:   httpBackendTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsconf, Proxy: http.ProxyFromEnvironment},
+		},
+	}
+	b.worker = &outboxWorker{
+		backend:       bc.Name,
+		kdb:           kdb,
+		deliver:       b.deliver,
+		transient:     func(err error) bool { return err != nil },
+		retryInterval: retry,
+		maxRetry:      maxHttpRetryInterval,
+	}
+	return b, nil
+}
+
+func (b *HttpDelegationBackend) Name() string { return b.backendName }
+
+// ApplyChildUpdate persists the update to the DB mirror and queues the
+// resulting change of each affected child for delivery to the endpoint.
+func (b *HttpDelegationBackend) ApplyChildUpdate(parentZone string, ur UpdateRequest) error {
+	_, err := applyAndQueue(b.kdb, b.backendName, parentZone, ur,
+		func(child string, before, after map[string]map[uint16][]dns.RR) any {
+			if c := webhookChangeFromDiff(parentZone, child, before, after); c != nil {
+				return c
+			}
+			return nil
+		})
+	b.worker.kick()
+	return err
+}
+
+// GetDelegationData asks data-url when configured, so that the scanner
+// compares against what the remote system actually holds; otherwise it
+// answers from the local mirror.
+func (b *HttpDelegationBackend) GetDelegationData(parentZone, childZone string) (map[string]map[uint16][]dns.RR, error) {
+	if b.bconf.DataURL == "" {
+		return b.mirror.GetDelegationData(parentZone, childZone)
+	}
+	var data WebhookDelegationData
+	if err := b.getJSON(expandWebhookURL(b.bconf.DataURL, parentZone, childZone), &data); err != nil {
+		return nil, err
+	}
+	result := map[string]map[uint16][]dns.RR{}
+	for _, s := range data.RRs {
+		rr, err := dns.NewRR(s)
+		if err != nil || rr == nil {
+			return nil, fmt.Errorf("%s: bad RR %q in delegation data: %v", b.backendName, s, err)
+		}
+		owner, rrtype := rr.Header().Name, rr.Header().Rrtype
+		if result[owner] == nil {
+			result[owner] = map[uint16][]dns.RR{}
+		}
+		result[owner][rrtype] = append(result[owner][rrtype], rr)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no delegation data for %s in zone %s", childZone, parentZone)
+	}
+	return result, nil
+}
+
+// ListChildren asks children-url when configured, otherwise the mirror.
+func (b *HttpDelegationBackend) ListChildren(parentZone string) ([]string, error) {
+	if b.bconf.ChildrenURL == "" {
+		return b.mirror.ListChildren(parentZone)
+	}
+	var ch WebhookChildren
+	if err := b.getJSON(expandWebhookURL(b.bconf.ChildrenURL, parentZone, ""), &ch); err != nil {
+		return nil, err
+	}
+	children := make([]string, 0, len(ch.Children))
+	for _, c := range ch.Children {
+		children = append(children, dns.Fqdn(c))
+	}
+	return children, nil
+}
+
+func (b *HttpDelegationBackend) Queue(parentZone string, all bool) ([]DelegationOutboxItem, error) {
+	return listDelegationOutbox(b.kdb, b.backendName, parentZone, all)
+}
+
+func (b *HttpDelegationBackend) Retry(parentZone string) (int, error) {
+	n, err := retryDelegationOutbox(b.kdb, b.backendName, parentZone)
+	if n > 0 {
+		b.worker.kick()
+	}
+	return n, err
+}
+
+func (b *HttpDelegationBackend) Start()                      { b.worker.Start() }
+func (b *HttpDelegationBackend) Stop()                       { b.worker.Stop() }
+func (b *HttpDelegationBackend) conf() DelegationBackendConf { return b.bconf }
+func (b *HttpDelegationBackend) keydb() *KeyDB               { return b.kdb }
+
+// expandWebhookURL substitutes {PARENT} and {CHILD} (without trailing dot).
+func expandWebhookURL(tmpl, parent, child string) string {
+	r := strings.NewReplacer(
+		"{PARENT}", url.PathEscape(strings.TrimSuffix(parent, ".")),
+		"{CHILD}", url.PathEscape(strings.TrimSuffix(child, ".")))
+	return r.Replace(tmpl)
+}
+
+// webhookChangeFromDiff describes the change from before to after, or
+// returns nil if nothing the endpoint cares about changed.
+func webhookChangeFromDiff(parent, child string, before, after map[string]map[uint16][]dns.RR) *WebhookDelegationChange {
+	c := &WebhookDelegationChange{Parent: parent, Child: child, Time: time.Now().UTC()}
+	c.NS = webhookRRDiff(before, after, func(owner string, t uint16) bool { return owner == child && t == dns.TypeNS })
+	c.DS = webhookRRDiff(before, after, func(owner string, t uint16) bool { return owner == child && t == dns.TypeDS })
+	c.Glue = webhookRRDiff(before, after, func(owner string, t uint16) bool {
+		return owner != child && (t == dns.TypeA || t == dns.TypeAAAA)
+	})
+	if len(c.NS.Add)+len(c.NS.Remove)+len(c.DS.Add)+len(c.DS.Remove)+len(c.Glue.Add)+len(c.Glue.Remove) == 0 {
+		return nil
+	}
+	return c
+}
+
+func webhookRRDiff(before, after map[string]map[uint16][]dns.RR, match func(string, uint16) bool) WebhookRRChange {
+	collect := func(data map[string]map[uint16][]dns.RR) map[string]bool {
+		set := map[string]bool{}
+		for owner, types := range data {
+			for t, rrs := range types {
+				if !match(owner, t) {
+					continue
+				}
+				for _, rr := range rrs {
+					set[rr.String()] = true
+				}
+			}
+		}
+		return set
+	}
+	b, a := collect(before), collect(after)
+	return WebhookRRChange{Add: setMinus(a, b), Remove: setMinus(b, a)}
+}
+
+// deliver POSTs one outbox item. Every failure, including any non-2xx
+// answer, is retried until max-attempts (if set) is reached.
+func (b *HttpDelegationBackend) deliver(item *DelegationOutboxItem) error {
+	req, err := http.NewRequest(http.MethodPost, b.bconf.URL, strings.NewReader(item.Payload))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tdns-Delivery", fmt.Sprintf("%s/%d", b.backendName, item.ID))
+		err = b.do(req, []byte(item.Payload), nil)
+	}
+	finishOutboxItem(b.kdb, item, err, true, b.bconf.MaxAttempts, "http")
+	return err
+}
+
+func (b *HttpDelegationBackend) getJSON(u string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := b.do(req, nil, out); err != nil {
+		return fmt.Errorf("%s: GET %s: %w", b.backendName, u, err)
+	}
+	return nil
+}
+
+// do signs and sends req; a 2xx answer is decoded into out when non-nil.
+func (b *HttpDelegationBackend) do(req *http.Request, body []byte, out any) error {
+	if err := b.sign(req, body, time.Now()); err != nil {
+		return err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	buf, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookStatusError{Status: resp.StatusCode, Body: strings.TrimSpace(string(buf))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(buf, out)
+}
+
+// sign adds the timestamp and signature headers. The key is read from the
+// keystore for every request, so a rotated key is used immediately.
+func (b *HttpDelegationBackend) sign(req *http.Request, body []byte, now time.Time) error {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("X-Tdns-Timestamp", ts)
+
+	switch b.bconf.Signing {
+	case "hmac":
+		row, err := getTsigKeystoreByName(b.kdb.DB, b.bconf.SigningKey)
+		if err != nil {
+			return fmt.Errorf("signing key %q: %w", b.bconf.SigningKey, err)
+		}
+		alg, mac, err := webhookHMAC(row.Algorithm, row.Secret)
+		if err != nil {
+			return fmt.Errorf("signing key %q: %w", b.bconf.SigningKey, err)
+		}
+		mac.Write([]byte(webhookSigningString(ts, req.Method, req.URL.RequestURI(), body)))
+		req.Header.Set("X-Tdns-Key", row.Keyname)
+		req.Header.Set("X-Tdns-Signature", alg+"="+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	case "jws":
+		owner := dns.Fqdn(b.bconf.SigningKey)
+		sak, err := b.kdb.GetSig0Keys(owner, Sig0StateActive)
+		if err != nil {
+			return fmt.Errorf("signing key %q: %w", owner, err)
+		}
+		if sak == nil || len(sak.Keys) == 0 {
+			return fmt.Errorf("signing key %q: no active SIG(0) key", owner)
+		}
+		jws, err := webhookJWS(sak.Keys[0], owner, now, req.Method, req.URL.String(), body)
+		if err != nil {
+			return fmt.Errorf("signing key %q: %w", owner, err)
+		}
+		req.Header.Set("X-Tdns-Jws", jws)
+	}
+	return nil
+}
+
+// webhookSigningString is the HMAC input: timestamp, method, request URI
+// and body, newline separated.
+func webhookSigningString(ts, method, uri string, body []byte) string {
+	return ts + "\n" + method + "\n" + uri + "\n" + string(body)
+}
+
+func webhookHMAC(tsigAlg, secret string) (string, hash.Hash, error) {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", nil, fmt.Errorf("bad secret: %w", err)
+	}
+	var h func() hash.Hash
+	alg := strings.TrimSuffix(strings.ToLower(tsigAlg), ".")
+	switch alg {
+	case "hmac-sha256":
+		h = sha256.New
+	case "hmac-sha384":
+		h = sha512.New384
+	case "hmac-sha512":
+		h = sha512.New
+	default:
+		return "", nil, fmt.Errorf("algorithm %s not usable for webhook signing", tsigAlg)
+	}
+	return alg, hmac.New(h, key), nil
+}
+
+// webhookJWS returns a detached compact JWS ("<protected>..<signature>")
+// over body.
+func webhookJWS(pkc *PrivateKeyCache, owner string, now time.Time, method, target string, body []byte) (string, error) {
+	var alg string
+	var size int // ECDSA: bytes per signature half
+	switch k := pkc.K.(type) {
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			alg, size = "ES256", 32
+		case elliptic.P384():
+			alg, size = "ES384", 48
+		default:
+			return "", fmt.Errorf("ECDSA curve %s not usable for JWS (need P-256 or P-384)", k.Curve.Params().Name)
+		}
+	case ed25519.PrivateKey, *ed25519.PrivateKey:
+		alg = "EdDSA"
+	default:
+		return "", fmt.Errorf("SIG(0) algorithm %s not usable for JWS (need ECDSAP256SHA256, ECDSAP384SHA384 or ED25519)", dns.AlgorithmToString[pkc.Algorithm])
+	}
+	hdr, err := json.Marshal(map[string]any{
+		"alg": alg,
+		"kid": fmt.Sprintf("%s/%d", owner, pkc.KeyId),
+		"iat": now.Unix(),
+		"htm": method,
+		"htu": target,
+	})
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(hdr)
+	input := []byte(protected + "." + base64.RawURLEncoding.EncodeToString(body))
+
+	var sig []byte
+	switch k := pkc.K.(type) {
+	case *ecdsa.PrivateKey:
+		var digest []byte
+		if alg == "ES384" {
+			d := sha512.Sum384(input)
+			digest = d[:]
+		} else {
+			d := sha256.Sum256(input)
+			digest = d[:]
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return "", err
+		}
+		// JWS wants the fixed-width r||s form, not ASN.1.
+		sig = make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, input)
+	case *ed25519.PrivateKey:
+		sig = ed25519.Sign(*k, input)
+	}
+	return protected + ".." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
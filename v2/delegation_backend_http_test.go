@@ -0,0 +1,333 @@
+package tdns
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const httpTestSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0LXNlY3JldA=="
+
+// webhookRecorder is a minimal endpoint that records what it is sent and
+// answers with the configured status codes in turn (200 once they run out).
+type webhookRecorder struct {
+	mu       sync.Mutex
+	statuses []int
+	reqs     []*http.Request
+	bodies   [][]byte
+}
+
+func (w *webhookRecorder) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.mu.Lock()
+	w.reqs = append(w.reqs, r)
+	w.bodies = append(w.bodies, body)
+	status := http.StatusOK
+	if len(w.statuses) > 0 {
+		status, w.statuses = w.statuses[0], w.statuses[1:]
+	}
+	w.mu.Unlock()
+
+	switch {
+	case status != http.StatusOK:
+		http.Error(rw, "try later", status)
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/children"):
+		json.NewEncoder(rw).Encode(WebhookChildren{Children: []string{"a.example", "b.example."}})
+	case r.Method == http.MethodGet:
+		json.NewEncoder(rw).Encode(WebhookDelegationData{Child: "a.example.", RRs: []string{
+			"a.example. 3600 IN NS ns1.a.example.",
+			"ns1.a.example. 3600 IN A 192.0.2.1",
+		}})
+	}
+}
+
+func (w *webhookRecorder) posts() ([]*http.Request, [][]byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var reqs []*http.Request
+	var bodies [][]byte
+	for i, r := range w.reqs {
+		if r.Method == http.MethodPost {
+			reqs = append(reqs, r)
+			bodies = append(bodies, w.bodies[i])
+		}
+	}
+	return reqs, bodies
+}
+
+func waitOutbox(t *testing.T, b QueuedDelegationBackend, parent string, cond func([]DelegationOutboxItem) bool) []DelegationOutboxItem {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		items, err := b.Queue(parent, true)
+		if err != nil {
+			t.Fatalf("Queue: %v", err)
+		}
+		if cond(items) {
+			return items
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for outbox, items: %+v", items)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestHttpBackend(t *testing.T, bc DelegationBackendConf, statuses ...int) (*HttpDelegationBackend, *webhookRecorder, *KeyDB) {
+	t.Helper()
+	rec := &webhookRecorder{statuses: statuses}
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+
+	kdb := newTestKeyDB(t)
+	if _, err := kdb.TsigKeyMgmt(nil, nil, KeystorePost{SubCommand: "add", TsigKeyname: "hook.", TsigAlgorithm: "hmac-sha256", TsigSecret: httpTestSecret}); err != nil {
+		t.Fatalf("TsigKeyMgmt add: %v", err)
+	}
+	bc.Name, bc.Type, bc.URL, bc.RetryInterval = "hook", "http", srv.URL+"/delegations", "20ms"
+	if bc.Signing == "" {
+		bc.Signing, bc.SigningKey = "hmac", "hook."
+	}
+	bc.ChildrenURL = strings.ReplaceAll(bc.ChildrenURL, "SRV", srv.URL)
+	bc.DataURL = strings.ReplaceAll(bc.DataURL, "SRV", srv.URL)
+	b, err := NewHttpDelegationBackend(bc, kdb)
+	if err != nil {
+		t.Fatalf("NewHttpDelegationBackend: %v", err)
+	}
+	b.Start()
+	t.Cleanup(b.Stop)
+	return b, rec, kdb
+}
+
+func TestHttpBackendDeliversSignedChange(t *testing.T) {
+	b, rec, _ := newTestHttpBackend(t, DelegationBackendConf{})
+
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"child.example. 3600 IN NS ns1.child.example.",
+		"ns1.child.example. 3600 IN A 192.0.2.1",
+		eppTestDS,
+	)}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	waitOutbox(t, b, "example.", allOutbox(OutboxDone))
+
+	reqs, bodies := rec.posts()
+	if len(reqs) != 1 {
+		t.Fatalf("got %d POSTs, want 1", len(reqs))
+	}
+	r, body := reqs[0], bodies[0]
+	var c WebhookDelegationChange
+	if err := json.Unmarshal(body, &c); err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	if c.Child != "child.example." || len(c.NS.Add) != 1 || len(c.DS.Add) != 1 || len(c.Glue.Add) != 1 || len(c.NS.Remove) != 0 {
+		t.Errorf("change = %+v", c)
+	}
+	if got := r.Header.Get("X-Tdns-Delivery"); !strings.HasPrefix(got, "hook/") {
+		t.Errorf("X-Tdns-Delivery = %q", got)
+	}
+
+	key, _ := base64.StdEncoding.DecodeString(httpTestSecret)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(webhookSigningString(r.Header.Get("X-Tdns-Timestamp"), r.Method, r.URL.RequestURI(), body)))
+	want := "hmac-sha256=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if got := r.Header.Get("X-Tdns-Signature"); got != want || r.Header.Get("X-Tdns-Key") != "hook." {
+		t.Errorf("signature = %q (key %q), want %q", got, r.Header.Get("X-Tdns-Key"), want)
+	}
+
+	// Removing the glue and DS shows up as removals only.
+	actions := eppTestRRs(t, dns.ClassNONE, "ns1.child.example. 0 IN A 192.0.2.1")
+	actions = append(actions, eppTestRRs(t, dns.ClassANY, "child.example. 0 IN DS 0 0 0 00")...)
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: actions}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	waitOutbox(t, b, "example.", func(items []DelegationOutboxItem) bool {
+		return len(items) == 2 && allOutbox(OutboxDone)(items)
+	})
+	_, bodies = rec.posts()
+	c = WebhookDelegationChange{}
+	json.Unmarshal(bodies[1], &c)
+	if len(c.DS.Remove) != 1 || len(c.Glue.Remove) != 1 || len(c.NS.Add)+len(c.NS.Remove)+len(c.DS.Add)+len(c.Glue.Add) != 0 {
+		t.Errorf("second change = %+v", c)
+	}
+}
+
+func TestHttpBackendJWS(t *testing.T) {
+	kdbOwner := "signer.example."
+	b, rec, kdb := newTestHttpBackend(t, DelegationBackendConf{Signing: "jws", SigningKey: kdbOwner})
+	if _, _, err := kdb.GenerateKeypair(kdbOwner, "test", Sig0StateActive, dns.TypeKEY, dns.ECDSAP256SHA256, "", nil); err != nil {
+		t.Fatalf("GenerateKeypair: %v", err)
+	}
+	sak, err := kdb.GetSig0Keys(kdbOwner, Sig0StateActive)
+	if err != nil || len(sak.Keys) != 1 {
+		t.Fatalf("GetSig0Keys: %v", err)
+	}
+	pub := &sak.Keys[0].K.(*ecdsa.PrivateKey).PublicKey
+
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"child.example. 3600 IN NS ns.other.net.",
+	)}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	waitOutbox(t, b, "example.", allOutbox(OutboxDone))
+
+	reqs, bodies := rec.posts()
+	parts := strings.Split(reqs[0].Header.Get("X-Tdns-Jws"), ".")
+	if len(parts) != 3 || parts[1] != "" {
+		t.Fatalf("X-Tdns-Jws = %q, want detached compact JWS", reqs[0].Header.Get("X-Tdns-Jws"))
+	}
+	hdrJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	var hdr map[string]any
+	if err := json.Unmarshal(hdrJSON, &hdr); err != nil {
+		t.Fatalf("protected header: %v", err)
+	}
+	if hdr["alg"] != "ES256" || hdr["htm"] != "POST" || !strings.HasPrefix(hdr["kid"].(string), kdbOwner+"/") {
+		t.Errorf("protected header = %v", hdr)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + base64.RawURLEncoding.EncodeToString(bodies[0])))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if len(sig) != 64 || !ecdsa.Verify(pub, digest[:], r, s) {
+		t.Errorf("JWS signature does not verify")
+	}
+}
+
+func TestHttpBackendJWSP384(t *testing.T) {
+	kdbOwner := "signer.example."
+	b, rec, kdb := newTestHttpBackend(t, DelegationBackendConf{Signing: "jws", SigningKey: kdbOwner})
+	if _, _, err := kdb.GenerateKeypair(kdbOwner, "test", Sig0StateActive, dns.TypeKEY, dns.ECDSAP384SHA384, "", nil); err != nil {
+		t.Fatalf("GenerateKeypair: %v", err)
+	}
+	sak, err := kdb.GetSig0Keys(kdbOwner, Sig0StateActive)
+	if err != nil || len(sak.Keys) != 1 {
+		t.Fatalf("GetSig0Keys: %v", err)
+	}
+	pub := &sak.Keys[0].K.(*ecdsa.PrivateKey).PublicKey
+
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"child.example. 3600 IN NS ns.other.net.",
+	)}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	waitOutbox(t, b, "example.", allOutbox(OutboxDone))
+
+	reqs, bodies := rec.posts()
+	parts := strings.Split(reqs[0].Header.Get("X-Tdns-Jws"), ".")
+	if len(parts) != 3 {
+		t.Fatalf("X-Tdns-Jws = %q", reqs[0].Header.Get("X-Tdns-Jws"))
+	}
+	hdrJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	var hdr map[string]any
+	if err := json.Unmarshal(hdrJSON, &hdr); err != nil || hdr["alg"] != "ES384" {
+		t.Errorf("protected header = %v (%v)", hdr, err)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if len(sig) != 96 {
+		t.Fatalf("signature is %d bytes, want 96", len(sig))
+	}
+	digest := sha512.Sum384([]byte(parts[0] + "." + base64.RawURLEncoding.EncodeToString(bodies[0])))
+	r, s := new(big.Int).SetBytes(sig[:48]), new(big.Int).SetBytes(sig[48:])
+	if !ecdsa.Verify(pub, digest[:], r, s) {
+		t.Errorf("JWS signature does not verify")
+	}
+}
+
+func TestHttpBackendRetries4xx(t *testing.T) {
+	// A 4xx answer is retried like any other non-2xx answer.
+	b, rec, kdb := newTestHttpBackend(t, DelegationBackendConf{}, 422, 429)
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"child.example. 3600 IN NS ns.other.net.",
+	)}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	items := waitOutbox(t, b, "example.", allOutbox(OutboxDone))
+	if items[0].Attempts != 2 {
+		t.Errorf("Attempts = %d, want 2", items[0].Attempts)
+	}
+	if reqs, _ := rec.posts(); len(reqs) != 3 {
+		t.Errorf("got %d POSTs, want 3", len(reqs))
+	}
+	entries, err := kdb.QueryAuditLog(AuditFilter{Zone: "child.example."})
+	if err != nil || len(entries) != 1 || entries[0].Action != "http-delivered" {
+		t.Errorf("audit: %+v %v", entries, err)
+	}
+}
+
+func TestHttpBackendRetriesThenFails(t *testing.T) {
+	// Two 503s, then success.
+	b, rec, _ := newTestHttpBackend(t, DelegationBackendConf{}, 503, 503)
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"child.example. 3600 IN NS ns.other.net.",
+	)}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	items := waitOutbox(t, b, "example.", allOutbox(OutboxDone))
+	if items[0].Attempts != 2 {
+		t.Errorf("Attempts = %d, want 2", items[0].Attempts)
+	}
+	if reqs, _ := rec.posts(); len(reqs) != 3 || reqs[0].Header.Get("X-Tdns-Delivery") != reqs[2].Header.Get("X-Tdns-Delivery") {
+		t.Errorf("got %d POSTs; delivery ids must be stable across retries", len(reqs))
+	}
+
+	// With max-attempts the item gives up and is audited.
+	b, _, kdb := newTestHttpBackend(t, DelegationBackendConf{MaxAttempts: 2}, 500, 500, 500)
+	if err := b.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"child.example. 3600 IN NS ns.other.net.",
+	)}); err != nil {
+		t.Fatalf("ApplyChildUpdate: %v", err)
+	}
+	items = waitOutbox(t, b, "example.", allOutbox(OutboxFailed))
+	if items[0].Attempts != 2 || !strings.Contains(items[0].LastError, "HTTP 500") {
+		t.Errorf("item = %+v", items[0])
+	}
+	entries, err := kdb.QueryAuditLog(AuditFilter{Zone: "child.example."})
+	if err != nil || len(entries) != 1 || entries[0].Action != "http-failed" {
+		t.Errorf("audit: %+v %v", entries, err)
+	}
+}
+
+func TestHttpBackendGetEndpoints(t *testing.T) {
+	b, rec, _ := newTestHttpBackend(t, DelegationBackendConf{
+		ChildrenURL: "SRV/zones/{PARENT}/children",
+		DataURL:     "SRV/zones/{PARENT}/children/{CHILD}",
+	})
+	children, err := b.ListChildren("example.")
+	if err != nil || strings.Join(children, ",") != "a.example.,b.example." {
+		t.Errorf("ListChildren = %v, %v", children, err)
+	}
+	data, err := b.GetDelegationData("example.", "a.example.")
+	if err != nil || len(data["a.example."][dns.TypeNS]) != 1 || len(data["ns1.a.example."][dns.TypeA]) != 1 {
+		t.Errorf("GetDelegationData = %v, %v", data, err)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.reqs) != 2 || rec.reqs[1].URL.Path != "/zones/example/children/a.example" || rec.reqs[1].Header.Get("X-Tdns-Signature") == "" {
+		t.Errorf("GET requests: %d, last %s", len(rec.reqs), rec.reqs[len(rec.reqs)-1].URL)
+	}
+}
+
+func TestNewHttpDelegationBackendValidates(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	for _, bc := range []DelegationBackendConf{
+		{Name: "x", Signing: "hmac", SigningKey: "k."},
+		{Name: "x", URL: "ftp://h/", Signing: "hmac", SigningKey: "k."},
+		{Name: "x", URL: "https://h/", Signing: "basic", SigningKey: "k."},
+		{Name: "x", URL: "https://h/", Signing: "jws"},
+	} {
+		if _, err := NewHttpDelegationBackend(bc, kdb); err == nil {
+			t.Errorf("accepted %+v", bc)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johani@johani.org
+ *
+ * DelegationOutbox is the persistent queue used by delegation backends that
+ * push approved child updates to a remote system (a registry via EPP, ...).
+ * ApplyChildUpdate only records the change locally and enqueues it; a
+ * per-backend worker delivers the queue in order and survives restarts and
+ * remote outages.
+ */
+package tdns
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Outbox item states.
+const (
+	OutboxPending = "pending"
+	OutboxDone    = "done"
+	OutboxFailed  = "failed"
+)
+
+// DelegationOutboxItem is one queued change for one child zone. Payload is
+// the backend-specific description of the change; Progress is backend-owned
+// scratch state that lets a retried delivery resume where it stopped.
+type DelegationOutboxItem struct {
+	ID        int64     `json:"id"`
+	Backend   string    `json:"backend"`
+	Parent    string    `json:"parent"`
+	Child     string    `json:"child"`
+	Payload   string    `json:"payload,omitempty"`
+	Progress  string    `json:"-"`
+	State     string    `json:"state"`
+	Attempts  int       `json:"attempts"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	LastError string    `json:"lasterror,omitempty"`
+}
+
+// QueuedDelegationBackend is implemented by backends that deliver changes
+// asynchronously through the DelegationOutbox. The /delegation API uses it
+// for the "queue" and "retry" commands.
+type QueuedDelegationBackend interface {
+	DelegationBackend
+
+	// Queue returns the outbox items for parentZone: pending and failed
+	// items, plus delivered ones when all is true.
+	Queue(parentZone string, all bool) ([]DelegationOutboxItem, error)
+
+	// Retry moves failed items for parentZone back to pending and returns
+	// how many were requeued.
+	Retry(parentZone string) (int, error)
+}
+
+// outboxBackend is a QueuedDelegationBackend with a delivery worker.
+type outboxBackend interface {
+	QueuedDelegationBackend
+	Start()
+	Stop()
+	conf() DelegationBackendConf
+	keydb() *KeyDB
+}
+
+// outboxBackends keeps one running instance per backend name, so that a
+// config reload reuses the worker (and any session it holds) instead of
+// starting another one.
+var outboxBackends = struct {
+	mu sync.Mutex
+	m  map[string]outboxBackend
+}{m: map[string]outboxBackend{}}
+
+// lookupOutboxBackend returns the running backend for bc, creating and
+// starting it if it does not exist yet or its configuration changed.
+func lookupOutboxBackend(bc DelegationBackendConf, kdb *KeyDB, create func() (outboxBackend, error)) (DelegationBackend, error) {
+	outboxBackends.mu.Lock()
+	defer outboxBackends.mu.Unlock()
+	if b := outboxBackends.m[bc.Name]; b != nil {
+		if b.conf() == bc && b.keydb() == kdb {
+			return b, nil
+		}
+		lg.Info("delegation backend configuration changed, restarting", "backend", bc.Name, "type", bc.Type)
+		b.Stop()
+		delete(outboxBackends.m, bc.Name)
+	}
+	b, err := create()
+	if err != nil {
+		return nil, err
+	}
+	b.Start()
+	outboxBackends.m[bc.Name] = b
+	return b, nil
+}
+
+// applyAndQueue persists ur to the DB mirror and queues one outbox item per
+// affected child whose delegation data changed. payload turns the child's
+// data before and after the update into the backend's description of the
+// change, or returns nil if there is nothing to deliver.
+func applyAndQueue(kdb *KeyDB, backend, parentZone string, ur UpdateRequest,
+	payload func(child string, before, after map[string]map[uint16][]dns.RR) any) (int, error) {
+	mirror := &DBDelegationBackend{kdb: kdb}
+
+	affected := map[string]bool{}
+	for _, rr := range ur.Actions {
+		affected[childZoneFromOwner(rr.Header().Name, parentZone)] = true
+	}
+	children := make([]string, 0, len(affected))
+	before := map[string]map[string]map[uint16][]dns.RR{}
+	for child := range affected {
+		children = append(children, child)
+		// An error here only means there is no data yet.
+		before[child], _ = mirror.GetDelegationData(parentZone, child)
+	}
+	sort.Strings(children)
+
+	if err := mirror.ApplyChildUpdate(parentZone, ur); err != nil {
+		return 0, fmt.Errorf("db persist failed: %w", err)
+	}
+
+	queued := 0
+	for _, child := range children {
+		after, _ := mirror.GetDelegationData(parentZone, child)
+		p := payload(child, before[child], after)
+		if p == nil {
+			continue
+		}
+		buf, err := json.Marshal(p)
+		if err != nil {
+			return queued, err
+		}
+		id, err := enqueueDelegationOutbox(kdb, backend, parentZone, child, string(buf))
+		if err != nil {
+			return queued, fmt.Errorf("queue update for %s: %w", child, err)
+		}
+		lg.Info("delegation backend: queued update", "backend", backend, "child", child, "id", id)
+		queued++
+	}
+	return queued, nil
+}
+
+// outboxWorker drains the outbox of one backend in order. deliver runs one
+// item and records its outcome; when it returns an error that transient
+// classifies as retryable the worker backs off (doubling up to maxRetry)
+// before trying the same item again. idle, if set, is called when the queue
+// has been empty for idleTimeout.
+type outboxWorker struct {
+	backend       string
+	kdb           *KeyDB
+	deliver       func(*DelegationOutboxItem) error
+	transient     func(error) bool
+	idle          func()
+	idleTimeout   time.Duration
+	retryInterval time.Duration
+	maxRetry      time.Duration
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start launches the worker. Items left pending by a previous run are
+// delivered first.
+func (w *outboxWorker) Start() {
+	w.wake = make(chan struct{}, 1)
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.run()
+}
+
+// Stop ends the worker. Undelivered items stay queued.
+func (w *outboxWorker) Stop() {
+	if w.stop == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+}
+
+func (w *outboxWorker) kick() {
+	if w.wake == nil {
+		return
+	}
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *outboxWorker) run() {
+	defer close(w.done)
+	if w.idle != nil {
+		defer w.idle()
+	}
+	idleTimeout := w.idleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = time.Hour
+	}
+
+	backoff := w.retryInterval
+	for {
+		item, err := nextDelegationOutbox(w.kdb, w.backend)
+		if err != nil {
+			lg.Error("delegation outbox: reading outbox failed", "backend", w.backend, "err", err)
+		}
+		if item == nil {
+			select {
+			case <-w.stop:
+				return
+			case <-w.wake:
+			case <-time.After(idleTimeout):
+				if w.idle != nil {
+					w.idle()
+				}
+			}
+			continue
+		}
+
+		err = w.deliver(item)
+		if err == nil || !w.transient(err) || item.State != OutboxPending {
+			backoff = w.retryInterval
+			continue
+		}
+		lg.Warn("delegation outbox: delivery failed, will retry", "backend", w.backend,
+			"child", item.Child, "id", item.ID, "retry", backoff, "err", err)
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, w.maxRetry)
+	}
+}
+
+// finishOutboxItem records the outcome of a delivery attempt. A transient
+// error leaves the item pending unless maxAttempts (0: unlimited) has been
+// reached; a permanent error marks it failed. Final outcomes are journaled
+// as "<kind>-delivered" or "<kind>-failed", kind being the backend type.
+func finishOutboxItem(kdb *KeyDB, it *DelegationOutboxItem, err error, transient bool, maxAttempts int, kind string) {
+	switch {
+	case err == nil:
+		it.State = OutboxDone
+		it.LastError = ""
+	default:
+		it.Attempts++
+		it.LastError = err.Error()
+		if !transient || (maxAttempts > 0 && it.Attempts >= maxAttempts) {
+			it.State = OutboxFailed
+		}
+	}
+	if serr := saveDelegationOutbox(kdb, it); serr != nil {
+		lg.Error("delegation outbox: saving item failed", "id", it.ID, "err", serr)
+	}
+	if it.State == OutboxPending {
+		return
+	}
+
+	action, detail := kind+"-delivered", fmt.Sprintf("backend %s, parent %s, outbox id %d", it.Backend, it.Parent, it.ID)
+	if err != nil {
+		action = kind + "-failed"
+		detail += ": " + err.Error()
+		lg.Error("delegation outbox: update not delivered", "backend", it.Backend, "child", it.Child, "id", it.ID, "attempts", it.Attempts, "err", err)
+	} else {
+		lg.Info("delegation outbox: update delivered", "backend", it.Backend, "child", it.Child, "id", it.ID)
+	}
+	auditRecord(kdb, AuditEntry{
+		Actor:  AuditActor{Type: AuditActorEngine, Name: kind + "-" + it.Backend},
+		Source: AuditSourceDelegationBackend,
+		Zone:   it.Child,
+		Action: action,
+		New:    []string{it.Payload},
+		Detail: detail,
+	})
+}
+
+func enqueueDelegationOutbox(kdb *KeyDB, backend, parent, child, payload string) (int64, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := kdb.DB.Exec(`
+INSERT INTO DelegationOutbox (backend, parent, child, payload, state, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`, backend, parent, child, payload, OutboxPending, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("enqueueDelegationOutbox: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+const selectOutboxSql = `
+SELECT id, backend, parent, child, payload, progress, state, attempts, created_at, updated_at, last_error
+FROM DelegationOutbox`
+
+func scanOutboxItem(sc interface{ Scan(...any) error }) (*DelegationOutboxItem, error) {
+	var it DelegationOutboxItem
+	var created, updated string
+	if err := sc.Scan(&it.ID, &it.Backend, &it.Parent, &it.Child, &it.Payload, &it.Progress,
+		&it.State, &it.Attempts, &created, &updated, &it.LastError); err != nil {
+		return nil, err
+	}
+	it.Created, _ = time.Parse(time.RFC3339, created)
+	it.Updated, _ = time.Parse(time.RFC3339, updated)
+	return &it, nil
+}
+
+// nextDelegationOutbox returns the oldest pending item for backend, or nil.
+func nextDelegationOutbox(kdb *KeyDB, backend string) (*DelegationOutboxItem, error) {
+	row := kdb.DB.QueryRow(selectOutboxSql+` WHERE backend = ? AND state = ? ORDER BY id LIMIT 1`, backend, OutboxPending)
+	it, err := scanOutboxItem(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("nextDelegationOutbox: %w", err)
+	}
+	return it, nil
+}
+
+// laterDelegationOutbox reports whether a newer change for the same child is
+// still queued behind it.
+func laterDelegationOutbox(kdb *KeyDB, it *DelegationOutboxItem) (bool, error) {
+	var n int
+	err := kdb.DB.QueryRow(`
+SELECT COUNT(*) FROM DelegationOutbox WHERE backend = ? AND child = ? AND id > ? AND state = ?`,
+		it.Backend, it.Child, it.ID, OutboxPending).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("laterDelegationOutbox: %w", err)
+	}
+	return n > 0, nil
+}
+
+// saveDelegationOutbox writes back the mutable fields of it.
+func saveDelegationOutbox(kdb *KeyDB, it *DelegationOutboxItem) error {
+	it.Updated = time.Now()
+	_, err := kdb.DB.Exec(`
+UPDATE DelegationOutbox SET progress = ?, state = ?, attempts = ?, updated_at = ?, last_error = ?
+WHERE id = ?`, it.Progress, it.State, it.Attempts, it.Updated.UTC().Format(time.RFC3339), it.LastError, it.ID)
+	if err != nil {
+		return fmt.Errorf("saveDelegationOutbox: %w", err)
+	}
+	return nil
+}
+
+func listDelegationOutbox(kdb *KeyDB, backend, parent string, all bool) ([]DelegationOutboxItem, error) {
+	q := selectOutboxSql + ` WHERE backend = ? AND parent = ?`
+	if !all {
+		q += ` AND state != '` + OutboxDone + `'`
+	}
+	rows, err := kdb.DB.Query(q+` ORDER BY id`, backend, parent)
+	if err != nil {
+		return nil, fmt.Errorf("listDelegationOutbox: %w", err)
+	}
+	defer rows.Close()
+	var items []DelegationOutboxItem
+	for rows.Next() {
+		it, err := scanOutboxItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listDelegationOutbox: %w", err)
+		}
+		items = append(items, *it)
+	}
+	return items, rows.Err()
+}
+
+// retryDelegationOutbox requeues failed items. Their progress is kept, so a
+// delivery that failed halfway resumes at the command that failed.
+func retryDelegationOutbox(kdb *KeyDB, backend, parent string) (int, error) {
+	res, err := kdb.DB.Exec(`
+UPDATE DelegationOutbox SET state = ?, updated_at = ?, last_error = ''
+WHERE backend = ? AND parent = ? AND state = ?`,
+		OutboxPending, time.Now().UTC().Format(time.RFC3339), backend, parent, OutboxFailed)
+	if err != nil {
+		return 0, fmt.Errorf("retryDelegationOutbox: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
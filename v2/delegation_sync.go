@@ -414,6 +414,21 @@ func (zd *ZoneData) SyncZoneDelegation(ctx context.Context, kdb *KeyDB, notifyq
 		msg, rcode, err = zd.SyncZoneDelegationViaNotify(kdb, notifyq, syncstate, dsynctarget)
 	}
 
+	if err == nil && scheme != "" {
+		ev := map[string]interface{}{"parent": zd.Parent, "scheme": scheme}
+		if dsynctarget != nil {
+			ev["target"] = dsynctarget.Name
+		}
+		emitEvent(EventDelegationSyncSent, zd.ZoneName, ev)
+		// Only an UPDATE gets a verdict from the parent; a NOTIFY merely
+		// prompts it to come and look.
+		if scheme == "UPDATE" && rcode == dns.RcodeSuccess {
+			emitEvent(EventDelegationSyncAcked, zd.ZoneName, map[string]interface{}{
+				"parent": zd.Parent, "scheme": scheme, "rcode": dns.RcodeToString[int(rcode)],
+			})
+		}
+	}
+
 	return msg, rcode, ur, err
 }
 
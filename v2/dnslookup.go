@@ -12,6 +12,7 @@ import (
 	"net"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -1119,11 +1120,20 @@ func (imr *Imr) IterativeDNSQueryWithLoopDetection(ctx context.Context, qname st
 			lg.Printf("IterativeDNSQuery: forcing re-query of <%s, %s>, bypassing cache", qname, dns.TypeToString[qtype])
 		}
 	}
+	if fz := imr.forwardZoneFor(qname); fz != nil {
+		rrset, rcode, cacheCtx, transport, err := imr.forwardQuery(ctx, fz, qname, qtype, force, requireEncrypted)
+		if err == nil || !fz.First {
+			return rrset, rcode, cacheCtx, transport, err
+		}
+		lgDns.Debug("IterativeDNSQuery: forwarders failed, falling back to iteration (forward-first)",
+			"zone", fz.Zone, "qname", qname, "qtype", dns.TypeToString[qtype], "err", err)
+	}
+
 	var rrset core.RRset
 	var rcode int
 
 	withOOTS := imr.Options[ImrOptUseTransportSignals] != "false"
-	m, err := buildQuery(qname, qtype, withOOTS)
+	m, err := buildQuery(qname, qtype, withOOTS, imr.Options[ImrOptUseDeleg] == "true")
 	if err != nil {
 		lg.Printf("IterativeDNSQuery: Error building query: %v", err)
 		return nil, 0, cache.ContextFailure, core.TransportDo53, err
@@ -1317,7 +1327,7 @@ func (imr *Imr) IterativeDNSQueryWithLoopDetection(ctx context.Context, qname st
 
 				switch kind {
 				case responseKindNegativeNoData, responseKindNegativeNXDOMAIN:
-					if ctxNeg, rcodeNeg, handled := imr.handleNegative(qname, qtype, r, wireTransport); handled {
+					if ctxNeg, rcodeNeg, handled := imr.handleNegative(ctx, qname, qtype, r, wireTransport); handled {
 						return nil, rcodeNeg, ctxNeg, wireTransport, nil
 					}
 					// If not handled, fall through to try next server
@@ -1874,7 +1884,7 @@ func RecursiveDNSQuery(server, qname string, qtype uint16, timeout time.Duration
 }
 
 // Helpers
-func buildQuery(qname string, qtype uint16, withOOTS bool) (*dns.Msg, error) {
+func buildQuery(qname string, qtype uint16, withOOTS, withDE bool) (*dns.Msg, error) {
 	m := new(dns.Msg)
 	m.SetQuestion(qname, qtype)
 	m.SetEdns0(4096, true)
@@ -1883,6 +1893,11 @@ func buildQuery(qname string, qtype uint16, withOOTS bool) (*dns.Msg, error) {
 			return nil, err
 		}
 	}
+	if withDE {
+		if err := edns0.SetDEFlagInMessage(m); err != nil {
+			return nil, err
+		}
+	}
 	return m, nil
 }
 
@@ -1914,6 +1929,14 @@ func (imr *Imr) tryServer(ctx context.Context, server *cache.AuthServer, addr st
 	if !exist {
 		return nil, 0, eff, fmt.Errorf("no DNS client for transport %d exists", eff)
 	}
+	if port := server.GetPort(); port != 0 {
+		// A DELEG port parameter: dial this server on its own port.
+		if dc, ok := c.(*core.DNSClient); ok {
+			pc := *dc
+			pc.Port = strconv.Itoa(int(port))
+			c = &pc
+		}
+	}
 	server.IncrementTransportCounter(eff)
 	if Globals.Debug {
 		lgDns.Debug("*** tryServer: calling c.Exchange",
@@ -2578,11 +2601,23 @@ func (imr *Imr) handleReferral(ctx context.Context, qname string, qtype uint16,
 		return nil, r.MsgHdr.Rcode, cache.ContextFailure, transport, err
 	}
 
+	// A DELEG RRset in the referral (see imr_deleg.go) takes precedence over
+	// the NS RRset and its glue.
+	usingDeleg := false
+	if imr.Options[ImrOptUseDeleg] == "true" && zonename != "" {
+		if delegRRset := extractDeleg(r, zonename); delegRRset != nil {
+			if dm := imr.handleDelegReferral(ctx, zonename, delegRRset, imr.referralFromSignedParent(r, zonename), r.MsgHdr.Rcode, transport); len(dm) > 0 {
+				serverMap = dm
+				usingDeleg = true
+			}
+		}
+	}
+
 	// For out-of-bailiwick nameservers, we still need their addresses if they
 	// are not already present in cache. In-bailiwick NS should primarily use
 	// glue (and, when ImrOptRevalidateNS is enabled, will later be
 	// revalidated by scheduleReferralNSRevalidation / revalidateInBailiwickGlue).
-	if nsRRset != nil && zonename != "" && len(nsRRset.RRs) > 0 {
+	if !usingDeleg && nsRRset != nil && zonename != "" && len(nsRRset.RRs) > 0 {
 		inBailiwick := func(host, zone string) bool {
 			h := strings.ToLower(dns.Fqdn(host))
 			z := strings.ToLower(dns.Fqdn(zone))
@@ -2710,7 +2745,9 @@ func (imr *Imr) handleReferral(ctx context.Context, qname string, qtype uint16,
 		return nil, r.MsgHdr.Rcode, cache.ContextReferral, transport, nil
 	}
 	// rrcache.Logger.Printf("*** handleReferral: calling revalidateReferralNS for zone %s, serverMap: %+v", zonename, serverMap)
-	imr.scheduleReferralNSRevalidation(ctx, zonename, serverMap)
+	if !usingDeleg {
+		imr.scheduleReferralNSRevalidation(ctx, zonename, serverMap)
+	}
 	//rrcache.Logger.Printf("*** handleReferral: revalidateReferralNS returned, calling IterativeDNSQuery for zone %s, serverMap: %+v", zonename, serverMap)
 	rrset, rcode, cacheCtx, transport, err := imr.IterativeDNSQueryWithLoopDetection(ctx, qname, qtype, serverMap, force, visitedZones, requireEncrypted)
 	return rrset, rcode, cacheCtx, transport, err
@@ -3048,7 +3085,10 @@ func classifyResponse(qname string, qtype uint16, r *dns.Msg) responseKind {
 	}
 }
 
-func (imr *Imr) handleNegative(qname string, qtype uint16, r *dns.Msg, transport core.Transport) (cache.CacheContext, int, bool) {
+func (imr *Imr) handleNegative(ctx context.Context, qname string, qtype uint16, r *dns.Msg, transport core.Transport) (cache.CacheContext, int, bool) {
+	// Validation must not be cut short by the query's deadline, but keeps
+	// the context's values (NTA probing, validation trace).
+	vctx := context.WithoutCancel(ctx)
 	if r == nil {
 		return cache.ContextFailure, dns.RcodeServerFailure, false
 	}
@@ -3151,7 +3191,7 @@ func (imr *Imr) handleNegative(qname string, qtype uint16, r *dns.Msg, transport
 	soaVstate := cache.ValidationStateNone
 	var err error
 	if !skipDNSKEYValidation && len(soarrset.RRSIGs) > 0 {
-		soaVstate, err = imr.Cache.ValidateRRset(context.Background(), soarrset, imr.IterativeDNSQueryFetcher())
+		soaVstate, err = imr.Cache.ValidateRRset(vctx, soarrset, imr.IterativeDNSQueryFetcher())
 		if err != nil {
 			lgDns.Error("handleNegative: failed to validate SOA RRset", "rrset", err)
 			return cache.ContextFailure, r.MsgHdr.Rcode, false
@@ -3184,7 +3224,7 @@ func (imr *Imr) handleNegative(qname string, qtype uint16, r *dns.Msg, transport
 	vstate := cache.ValidationStateNone
 	negRcode := uint8(r.MsgHdr.Rcode)
 	if !skipDNSKEYValidation && len(negAuthority) > 0 {
-		vstate, negRcode, err = imr.Cache.ValidateNegativeResponse(context.Background(), qname, qtype, negRcode, negAuthority, imr.IterativeDNSQueryFetcher())
+		vstate, negRcode, err = imr.Cache.ValidateNegativeResponse(vctx, qname, qtype, negRcode, negAuthority, imr.IterativeDNSQueryFetcher())
 		if err != nil {
 			// If validation returns ValidationStateIndeterminate (e.g., no trust anchors),
 			// we should still cache and return the response, not treat it as a failure.
@@ -3331,7 +3371,7 @@ func (imr *Imr) DefaultDNSKEYFetcher(ctx context.Context, name string) (*core.RR
 	if len(servers) == 0 {
 		return nil, fmt.Errorf("no servers for %s", name)
 	}
-	rr, _, _, _, err := imr.IterativeDNSQuery(ctx, name, dns.TypeDNSKEY, servers, false, false) // PR not required for DNSKEY fetches
+	rr, _, _, _, err := imr.sharedIterativeDNSQuery(ctx, name, dns.TypeDNSKEY, servers, false, true, false, false) // PR not required for DNSKEY fetches
 	if err != nil || rr == nil || len(rr.RRs) == 0 {
 		return nil, fmt.Errorf("dnskey fetch failed for %s: %v", name, err)
 	}
@@ -3353,7 +3393,7 @@ func (imr *Imr) DefaultRRsetFetcher(ctx context.Context, qname string, qtype uin
 	if len(servers) == 0 {
 		return nil, fmt.Errorf("no servers for %s", qname)
 	}
-	rr, _, _, _, err := imr.IterativeDNSQuery(ctx, qname, qtype, servers, false, false) // PR not required for fetcher
+	rr, _, _, _, err := imr.sharedIterativeDNSQuery(ctx, qname, qtype, servers, false, true, false, false) // PR not required for fetcher
 	if err != nil || rr == nil || len(rr.RRs) == 0 {
 		return nil, fmt.Errorf("fetch failed for %s %s: %v", qname, dns.TypeToString[qtype], err)
 	}
@@ -13,6 +13,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/netip"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -62,12 +63,23 @@ func DnsDoHEngine(ctx context.Context, conf *Config, dohaddrs []string, certFile
 
 		// Create a response writer abstraction for DoH
 		var buf bytes.Buffer
-		rw := &dohResponseWriter{&buf}
+		rw := &dohResponseWriter{buf: &buf, remote: dummyAddr{}, local: dummyAddr{}}
+		if ap, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+			rw.remote = net.TCPAddrFromAddrPort(ap)
+		}
+		if la, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+			rw.local = la
+		}
 
 		lgDns.Debug("DoH: received message", "opcode", dns.OpcodeToString[msg.Opcode], "qname", msg.Question[0].Name, "rrtype", dns.TypeToString[msg.Question[0].Qtype])
 
 		// Call your internal handler to process DNS query
 		ourDNSHandler(rw, msg)
+		if rw.rateLimited {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "query rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
 
 		// raw, _ := resp.Pack()
 		w.Header().Set("Content-Type", "application/dns-message")
@@ -121,10 +133,20 @@ func DnsDoHEngine(ctx context.Context, conf *Config, dohaddrs []string, certFile
 	return nil
 }
 
+// dohResponseWriter collects the response for the HTTP reply. remote and
+// local are the addresses of the HTTP connection, so handlers can apply
+// address ACLs to DoH clients as to any other.
 type dohResponseWriter struct {
-	buf *bytes.Buffer
+	buf         *bytes.Buffer
+	remote      net.Addr
+	local       net.Addr
+	rateLimited bool
 }
 
+// RateLimited marks the query as dropped by a rate limit; the client gets
+// HTTP 429 rather than an empty 200.
+func (w *dohResponseWriter) RateLimited() { w.rateLimited = true }
+
 func (w *dohResponseWriter) WriteMsg(m *dns.Msg) error {
 	raw, err := m.Pack()
 	if err != nil {
@@ -145,8 +167,8 @@ func (w *dohResponseWriter) Close() error { return nil }
 func (w *dohResponseWriter) TsigStatus() error         { return nil }
 func (w *dohResponseWriter) TsigTimersOnly(bool)       {}
 func (w *dohResponseWriter) Hijack()                   {}
-func (w *dohResponseWriter) LocalAddr() net.Addr       { return dummyAddr{} }
-func (w *dohResponseWriter) RemoteAddr() net.Addr      { return dummyAddr{} }
+func (w *dohResponseWriter) LocalAddr() net.Addr       { return w.local }
+func (w *dohResponseWriter) RemoteAddr() net.Addr      { return w.remote }
 func (w *dohResponseWriter) Write([]byte) (int, error) { return 0, nil }
 func (w *dohResponseWriter) WriteMsgWithTsig(*dns.Msg, string, bool) error {
 	return errors.New("not implemented")
@@ -14,6 +14,7 @@ type MsgOptions struct {
 	DO            bool
 	CO            bool            // RFC 9824: Compact Ok bit (bit 14 in OPT header TTL)
 	PR            bool            // Privacy Requested bit (bit 12 in OPT header TTL) - requires encrypted transport
+	DE            bool            // Delegation Extension bit (bit 13 in OPT header TTL): client understands DELEG
 	OotsOptIn     bool            // OOTS EDNS option present (opt-in by presence; -03)
 	HasEROption   bool            // True if ER option is present
 	ErAgentDomain string          // RFC9567: DNS Error Reporting agent domain
@@ -48,6 +49,9 @@ func ExtractFlagsAndEDNS0Options(r *dns.Msg) (*MsgOptions, error) {
 	// Extract PR bit (Privacy Requested) - bit 12 (requires encrypted transport)
 	msgoptions.PR = (opt.Hdr.Ttl & (1 << EDNS0_PR_FLAG_BIT)) != 0
 
+	// Extract DE bit (Delegation Extension) - bit 13
+	msgoptions.DE = (opt.Hdr.Ttl & (1 << EDNS0_DE_FLAG_BIT)) != 0
+
 	// Loop once through all EDNS0 options and extract them based on their code
 	for _, option := range opt.Option {
 		if localOpt, ok := option.(*dns.EDNS0_LOCAL); ok {
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package edns0
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// DE (Delegation Extension) flag bit - bit 13 in OPT header TTL. A resolver
+// sets it to signal that it understands DELEG; an authoritative server only
+// includes DELEG records in referrals when it is set.
+const (
+	EDNS0_DE_FLAG_BIT = 13 // Delegation Extension flag bit position
+)
+
+// SetDEFlag sets the DE (Delegation Extension) flag in an OPT RR
+func SetDEFlag(opt *dns.OPT) error {
+	if opt == nil {
+		return fmt.Errorf("OPT RR is nil")
+	}
+	opt.Hdr.Ttl |= (1 << EDNS0_DE_FLAG_BIT)
+	return nil
+}
+
+// ClearDEFlag clears the DE (Delegation Extension) flag in an OPT RR
+func ClearDEFlag(opt *dns.OPT) {
+	if opt == nil {
+		return
+	}
+	opt.Hdr.Ttl &^= (1 << EDNS0_DE_FLAG_BIT)
+}
+
+// HasDEFlag checks if the DE (Delegation Extension) flag is set in an OPT RR
+func HasDEFlag(opt *dns.OPT) bool {
+	if opt == nil {
+		return false
+	}
+	return (opt.Hdr.Ttl & (1 << EDNS0_DE_FLAG_BIT)) != 0
+}
+
+// SetDEFlagInMessage sets the DE flag in a DNS message's OPT RR (creates OPT if needed)
+func SetDEFlagInMessage(msg *dns.Msg) error {
+	if msg == nil {
+		return fmt.Errorf("message is nil")
+	}
+	opt := msg.IsEdns0()
+	if opt == nil {
+		msg.SetEdns0(4096, false)
+		opt = msg.IsEdns0()
+	}
+	return SetDEFlag(opt)
+}
@@ -65,10 +65,25 @@ const (
 	EDENotifyZoneInErrorState         // target zone in error state
 	EDENotifyUnknownType              // unsupported NOTIFY RRtype
 	EDENotifyNotPermitted             // source not permitted by the zone's allow-notify ACL
+
+	// EDENegativeTrustAnchor marks answers that were not validated because
+	// the name is covered by a negative trust anchor (RFC 7646). RFC 8914
+	// has no code for this.
+	EDENegativeTrustAnchor
 )
 
+// EDEProhibited (RFC 8914) is attached to REFUSED responses to clients
+// that an access-control list does not allow. Kept out of the block above,
+// where a new line would shift the iota-numbered private codes.
+const EDEProhibited uint16 = 18
+
+// EDEDNSSECIndeterminate (RFC 8914) is attached to the SERVFAIL given to a
+// client that requires DNSSEC when the answer did not validate as secure.
+const EDEDNSSECIndeterminate uint16 = 5
+
 var EDECodeToString = map[uint16]string{
-	EDEDNSSECBogus:                 "DNSSEC Bogus", // RFC 8914
+	EDEDNSSECBogus:                 "DNSSEC Bogus",         // RFC 8914
+	EDEDNSSECIndeterminate:         "DNSSEC Indeterminate", // RFC 8914
 	EDESig0KeyNotKnown:             "SIG(0) key not known",
 	EDESig0KeyKnownButNotTrusted:   "SIG(0) key known, but not yet trusted",
 	EDEDelegationSyncNotSupported:  "Delegation sync via DNS UPDATE is not supported",
@@ -98,6 +113,8 @@ var EDECodeToString = map[uint16]string{
 	EDENotifyZoneInErrorState:         "target zone is in error state",
 	EDENotifyUnknownType:              "unsupported NOTIFY RRtype",
 	EDENotifyNotPermitted:             "source not permitted by allow-notify ACL",
+
+	EDENegativeTrustAnchor: "DNSSEC validation disabled by a negative trust anchor",
 }
 
 // AttachEDEToResponse attaches an Extended DNS Error (EDE) option to the DNS response
@@ -116,6 +116,7 @@ const (
 	ImrOptTransportSignalType
 	ImrOptQueryForTransportTLSA
 	ImrOptUseTransportSignals
+	ImrOptUseDeleg
 )
 
 var ImrOptionToString = map[ImrOption]string{
@@ -125,6 +126,7 @@ var ImrOptionToString = map[ImrOption]string{
 	ImrOptTransportSignalType:     "transport-signal-type",
 	ImrOptQueryForTransportTLSA:   "query-for-transport-tlsa",
 	ImrOptUseTransportSignals:     "use-transport-signals",
+	ImrOptUseDeleg:                "use-deleg",
 }
 
 var StringToImrOption = map[string]ImrOption{
@@ -134,6 +136,7 @@ var StringToImrOption = map[string]ImrOption{
 	"transport-signal-type":      ImrOptTransportSignalType,
 	"query-for-transport-tlsa":   ImrOptQueryForTransportTLSA,
 	"use-transport-signals":      ImrOptUseTransportSignals,
+	"use-deleg":                  ImrOptUseDeleg,
 }
 
 type AuthOption uint8
@@ -287,6 +290,10 @@ const (
 	// (e.g. some primaries failed to resolve while others succeeded).
 	// Visibility-only.
 	ConfigWarning
+	// ZoneLintWarning: the zone content as last loaded or transferred has
+	// lint findings (see zone_lint.go). Visibility-only; with
+	// dnsengine.zone-lint: refuse, lint errors fail the load instead.
+	ZoneLintWarning
 )
 
 var ErrorTypeToString = map[ErrorType]string{
@@ -300,6 +307,7 @@ var ErrorTypeToString = map[ErrorType]string{
 	RolloverParentBlocker:   "rollover-parent-blocker",
 	DelegationSyncWarning:   "delegation-sync-warning",
 	ConfigWarning:           "config-warning",
+	ZoneLintWarning:         "zone-lint",
 }
 
 // errorTypeReportOrder defines the deterministic order in which the
@@ -318,6 +326,7 @@ var errorTypeReportOrder = []ErrorType{
 	RolloverPolicyWarning,
 	DelegationSyncWarning,
 	ConfigWarning,
+	ZoneLintWarning,
 }
 
 // rolloverGatingErrors are categories that the auto-rollover CLI
@@ -394,12 +403,21 @@ func (zd *ZoneData) SetError(errtype ErrorType, errmsg string, args ...interface
 // record an error without re-locking — Go mutexes are not reentrant.
 func (zd *ZoneData) setErrorLocked(errtype ErrorType, errmsg string, args ...interface{}) {
 	if errtype == NoError {
+		if len(zd.Errors) > 0 {
+			emitEvent(EventZoneErrorCleared, zd.ZoneName, map[string]interface{}{"error_type": "all"})
+		}
 		zd.Errors = nil
 	} else {
 		if zd.Errors == nil {
 			zd.Errors = map[ErrorType]ZoneError{}
 		}
-		zd.Errors[errtype] = ZoneError{Type: errtype, Msg: fmt.Sprintf(errmsg, args...)}
+		msg := fmt.Sprintf(errmsg, args...)
+		if cur, ok := zd.Errors[errtype]; !ok || cur.Msg != msg {
+			emitEvent(EventZoneErrorSet, zd.ZoneName, map[string]interface{}{
+				"error_type": ErrorTypeToString[errtype], "msg": msg,
+			})
+		}
+		zd.Errors[errtype] = ZoneError{Type: errtype, Msg: msg}
 	}
 	zd.recomputeDerivedErrorFieldsLocked()
 	Zones.Set(zd.ZoneName, zd)
@@ -417,8 +435,14 @@ func (zd *ZoneData) ClearError(errtype ErrorType) {
 // See setErrorLocked for why this exists.
 func (zd *ZoneData) clearErrorLocked(errtype ErrorType) {
 	if errtype == NoError {
+		if len(zd.Errors) > 0 {
+			emitEvent(EventZoneErrorCleared, zd.ZoneName, map[string]interface{}{"error_type": "all"})
+		}
 		zd.Errors = nil
 	} else if zd.Errors != nil {
+		if _, ok := zd.Errors[errtype]; ok {
+			emitEvent(EventZoneErrorCleared, zd.ZoneName, map[string]interface{}{"error_type": ErrorTypeToString[errtype]})
+		}
 		delete(zd.Errors, errtype)
 		if len(zd.Errors) == 0 {
 			zd.Errors = nil
@@ -0,0 +1,352 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johani@johani.org
+ *
+ * Minimal EPP client (RFC 5730 over TLS, RFC 5734) for the epp delegation
+ * backend. Only the commands needed to maintain delegations are supported:
+ * login/logout/hello, host:check/info/create/update/delete (RFC 5732) and
+ * domain:info/update with the secDNS-1.1 extension (RFC 5731, RFC 5910).
+ */
+package tdns
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	eppNS       = "urn:ietf:params:xml:ns:epp-1.0"
+	eppDomainNS = "urn:ietf:params:xml:ns:domain-1.0"
+	eppHostNS   = "urn:ietf:params:xml:ns:host-1.0"
+	eppSecDNSNS = "urn:ietf:params:xml:ns:secDNS-1.1"
+
+	// eppMaxFrame bounds the size of a single EPP frame we are willing to
+	// read. Delegation responses are tiny; anything larger is a broken peer.
+	eppMaxFrame = 1 << 20
+
+	// eppHelloAfter is how long a session may sit idle before it is probed
+	// with <hello> prior to reuse. Registries silently drop idle sessions.
+	eppHelloAfter = time.Minute
+)
+
+// EppError is a non-success EPP result (code >= 2000).
+type EppError struct {
+	Command string
+	Code    int
+	Msg     string
+}
+
+func (e *EppError) Error() string {
+	return fmt.Sprintf("EPP %s: %d %s", e.Command, e.Code, e.Msg)
+}
+
+// Transient reports whether retrying the same command later may succeed:
+// 2400 (command failed, a server-side problem) and the 25xx session
+// codes. A failed login is also transient: it is not the fault of the
+// queued change, which must wait until the credentials are fixed.
+// Everything else is a policy or data error that retrying won't fix.
+func (e *EppError) Transient() bool {
+	return e.Command == "login" || e.Code == 2400 || e.Code >= 2500
+}
+
+// eppTransient classifies an error returned by EppClient: transport errors
+// and transient result codes are retried, anything else is permanent.
+func eppTransient(err error) bool {
+	var ee *EppError
+	if errors.As(err, &ee) {
+		return ee.Transient()
+	}
+	return err != nil
+}
+
+// EppClient is a single EPP session. It connects and logs in lazily, and
+// transparently reconnects on the next command after a transport failure.
+// Credentials are fetched via Credentials at every login, so a password
+// rotated in the keystore takes effect on the next session.
+type EppClient struct {
+	Server      string // host:port
+	TLSConfig   *tls.Config
+	Timeout     time.Duration
+	Credentials func() (clid, password string, err error)
+
+	mu       sync.Mutex
+	conn     net.Conn
+	lastUsed time.Time
+	trid     uint64
+	svID     string
+}
+
+// eppResult is the parsed form of an EPP <response> (or <greeting>).
+type eppResult struct {
+	Code    int
+	Msg     string
+	SvTRID  string
+	HostChk map[string]bool // host:check: name → available
+	NS      []string        // domain:info: hostObj names
+	DS      []eppDSData     // domain:info: secDNS:dsData
+	Addrs   []string        // host:info: addresses
+}
+
+type eppFrameXML struct {
+	XMLName  xml.Name `xml:"epp"`
+	Greeting *struct {
+		SvID string `xml:"svID"`
+	} `xml:"greeting"`
+	Response *struct {
+		Results []struct {
+			Code int    `xml:"code,attr"`
+			Msg  string `xml:"msg"`
+		} `xml:"result"`
+		HostCd []struct {
+			Name struct {
+				Avail string `xml:"avail,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"name"`
+		} `xml:"resData>chkData>cd"`
+		InfData *struct {
+			NS    []string `xml:"ns>hostObj"`
+			Addrs []string `xml:"addr"`
+		} `xml:"resData>infData"`
+		DSData []struct {
+			KeyTag     uint16 `xml:"keyTag"`
+			Alg        uint8  `xml:"alg"`
+			DigestType uint8  `xml:"digestType"`
+			Digest     string `xml:"digest"`
+		} `xml:"extension>infData>dsData"`
+		SvTRID string `xml:"trID>svTRID"`
+	} `xml:"response"`
+}
+
+func (c *EppClient) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 30 * time.Second
+}
+
+// Connected reports whether the client currently holds a logged-in session.
+func (c *EppClient) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+// IdleSince returns the time of the last successful exchange on the session.
+func (c *EppClient) IdleSince() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastUsed
+}
+
+// Close logs out (best effort) and closes the session.
+func (c *EppClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return
+	}
+	if _, err := c.roundtrip("logout", "<logout/>"); err != nil {
+		lg.Debug("EppClient: logout failed", "server", c.Server, "err", err)
+	}
+	c.dropLocked()
+}
+
+func (c *EppClient) dropLocked() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// Exec runs one EPP command. body is the content of <command> before
+// <clTRID>, i.e. the command element plus an optional <extension>. Result
+// codes >= 2000 are returned as *EppError; a 25xx result also ends the
+// session so that the next command reconnects.
+func (c *EppClient) Exec(name, body string) (*eppResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil && time.Since(c.lastUsed) > eppHelloAfter {
+		if err := c.helloLocked(); err != nil {
+			lg.Info("EppClient: idle session is gone, reconnecting", "server", c.Server, "err", err)
+			c.dropLocked()
+		}
+	}
+	if c.conn == nil {
+		if err := c.connectLocked(); err != nil {
+			return nil, err
+		}
+	}
+	res, err := c.roundtrip(name, body)
+	if err != nil {
+		return nil, err
+	}
+	if res.Code >= 2000 {
+		if res.Code >= 2500 {
+			c.dropLocked()
+		}
+		return res, &EppError{Command: name, Code: res.Code, Msg: res.Msg}
+	}
+	return res, nil
+}
+
+func (c *EppClient) connectLocked() error {
+	if c.Credentials == nil {
+		return fmt.Errorf("EPP %s: no credentials configured", c.Server)
+	}
+	clid, pw, err := c.Credentials()
+	if err != nil {
+		return fmt.Errorf("EPP %s: credentials: %w", c.Server, err)
+	}
+
+	dialer := &net.Dialer{Timeout: c.timeout()}
+	conn, err := tls.DialWithDialer(dialer, "tcp", c.Server, c.TLSConfig)
+	if err != nil {
+		return fmt.Errorf("EPP %s: connect: %w", c.Server, err)
+	}
+	c.conn = conn
+
+	greeting, err := c.readFrame()
+	if err != nil {
+		c.dropLocked()
+		return fmt.Errorf("EPP %s: greeting: %w", c.Server, err)
+	}
+	var f eppFrameXML
+	if err := xml.Unmarshal(greeting, &f); err != nil || f.Greeting == nil {
+		c.dropLocked()
+		return fmt.Errorf("EPP %s: expected <greeting>", c.Server)
+	}
+	c.svID = f.Greeting.SvID
+
+	login := fmt.Sprintf(`<login><clID>%s</clID><pw>%s</pw><options><version>1.0</version><lang>en</lang></options>`+
+		`<svcs><objURI>%s</objURI><objURI>%s</objURI><svcExtension><extURI>%s</extURI></svcExtension></svcs></login>`,
+		eppEsc(clid), eppEsc(pw), eppDomainNS, eppHostNS, eppSecDNSNS)
+	res, err := c.roundtrip("login", login)
+	if err != nil {
+		return err
+	}
+	if res.Code >= 2000 {
+		c.dropLocked()
+		return &EppError{Command: "login", Code: res.Code, Msg: res.Msg}
+	}
+	lg.Info("EppClient: logged in", "server", c.Server, "svid", c.svID, "clid", clid)
+	return nil
+}
+
+func (c *EppClient) helloLocked() error {
+	if err := c.writeFrame([]byte(`<?xml version="1.0" encoding="UTF-8"?><epp xmlns="` + eppNS + `"><hello/></epp>`)); err != nil {
+		return err
+	}
+	buf, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	var f eppFrameXML
+	if err := xml.Unmarshal(buf, &f); err != nil || f.Greeting == nil {
+		return fmt.Errorf("expected <greeting> in reply to <hello>")
+	}
+	c.lastUsed = time.Now()
+	return nil
+}
+
+// roundtrip sends one command and parses the response. Transport errors
+// drop the session.
+func (c *EppClient) roundtrip(name, body string) (*eppResult, error) {
+	c.trid++
+	cltrid := fmt.Sprintf("tdns-%d-%d", time.Now().Unix(), c.trid)
+	msg := `<?xml version="1.0" encoding="UTF-8"?><epp xmlns="` + eppNS + `"><command>` + body +
+		`<clTRID>` + cltrid + `</clTRID></command></epp>`
+	if err := c.writeFrame([]byte(msg)); err != nil {
+		c.dropLocked()
+		return nil, fmt.Errorf("EPP %s: %s: %w", c.Server, name, err)
+	}
+	buf, err := c.readFrame()
+	if err != nil {
+		c.dropLocked()
+		return nil, fmt.Errorf("EPP %s: %s: %w", c.Server, name, err)
+	}
+	var f eppFrameXML
+	if err := xml.Unmarshal(buf, &f); err != nil || f.Response == nil || len(f.Response.Results) == 0 {
+		c.dropLocked()
+		return nil, fmt.Errorf("EPP %s: %s: malformed response", c.Server, name)
+	}
+	c.lastUsed = time.Now()
+	res := &eppResult{
+		Code:   f.Response.Results[0].Code,
+		Msg:    strings.TrimSpace(f.Response.Results[0].Msg),
+		SvTRID: f.Response.SvTRID,
+	}
+	if len(f.Response.HostCd) > 0 {
+		res.HostChk = map[string]bool{}
+		for _, cd := range f.Response.HostCd {
+			res.HostChk[strings.ToLower(strings.TrimSpace(cd.Name.Value))] = cd.Name.Avail == "1" || cd.Name.Avail == "true"
+		}
+	}
+	if inf := f.Response.InfData; inf != nil {
+		for _, h := range inf.NS {
+			res.NS = append(res.NS, eppName(strings.TrimSpace(h)))
+		}
+		for _, a := range inf.Addrs {
+			res.Addrs = append(res.Addrs, strings.TrimSpace(a))
+		}
+	}
+	for _, d := range f.Response.DSData {
+		res.DS = append(res.DS, eppDSData{KeyTag: d.KeyTag, Algorithm: d.Alg, DigestType: d.DigestType,
+			Digest: strings.ToUpper(strings.TrimSpace(d.Digest))})
+	}
+	return res, nil
+}
+
+// writeFrame and readFrame implement the RFC 5734 data unit: a 32-bit
+// total length (header included) followed by the XML instance.
+func (c *EppClient) writeFrame(payload []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout()))
+	return eppWriteFrame(c.conn, payload)
+}
+
+func (c *EppClient) readFrame() ([]byte, error) {
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout()))
+	return eppReadFrame(c.conn)
+}
+
+func eppWriteFrame(w io.Writer, payload []byte) error {
+	hdr := make([]byte, 4)
+	binary.BigEndian.PutUint32(hdr, uint32(len(payload)+4))
+	_, err := w.Write(append(hdr, payload...))
+	return err
+}
+
+func eppReadFrame(r io.Reader) ([]byte, error) {
+	hdr := make([]byte, 4)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr)
+	if n < 4 || n > eppMaxFrame {
+		return nil, fmt.Errorf("bad EPP frame length %d", n)
+	}
+	buf := make([]byte, n-4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func eppEsc(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// eppName converts a DNS name to the form EPP expects (no trailing dot).
+func eppName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
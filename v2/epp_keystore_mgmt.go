@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johani@johani.org
+ *
+ * Keystore management of EPP registrar credentials (KeystorePost command
+ * "epp-mgmt"). The epp delegation backend looks its login up here by name
+ * at every session setup.
+ */
+package tdns
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// EppKeyMgmt handles the epp-mgmt subcommands list, add and delete. Like
+// TsigKeyMgmt it runs in the caller's transaction when tx is non-nil.
+func (kdb *KeyDB) EppKeyMgmt(tx *Tx, kp KeystorePost) (resp *KeystoreResponse, retErr error) {
+	resp = &KeystoreResponse{Time: time.Now()}
+
+	localtx := false
+	var err error
+	if tx == nil {
+		tx, err = kdb.Begin("EppKeyMgmt")
+		if err != nil {
+			return nil, err
+		}
+		localtx = true
+	}
+	defer func() {
+		if !localtx {
+			return
+		}
+		if retErr != nil {
+			tx.Rollback()
+			return
+		}
+		if cerr := tx.Commit(); cerr != nil {
+			retErr = cerr
+			resp.Error = true
+			resp.ErrorMsg = cerr.Error()
+		}
+	}()
+
+	switch kp.SubCommand {
+	case "list":
+		rows, err := tx.Query(`SELECT name, clid, created_at, comment FROM EppCredentials ORDER BY name`)
+		if err != nil {
+			return resp, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var info EppCredentialInfo
+			var created, comment sql.NullString
+			if err := rows.Scan(&info.Name, &info.ClID, &created, &comment); err != nil {
+				return resp, err
+			}
+			info.Created, info.Comment = created.String, comment.String
+			resp.EppCredentials = append(resp.EppCredentials, info)
+		}
+		if err := rows.Err(); err != nil {
+			return resp, err
+		}
+		resp.Msg = fmt.Sprintf("%d EPP credential(s)", len(resp.EppCredentials))
+
+	case "add":
+		if kp.EppName == "" || kp.EppClid == "" || kp.EppPassword == "" {
+			return resp, fmt.Errorf("add requires a name, a client id and a password")
+		}
+		_, err := tx.Exec(`INSERT OR REPLACE INTO EppCredentials (name, clid, password, created_at, comment) VALUES (?, ?, ?, ?, ?)`,
+			kp.EppName, kp.EppClid, kp.EppPassword, time.Now().UTC().Format(time.RFC3339), kp.EppComment)
+		if err != nil {
+			return resp, err
+		}
+		resp.Msg = fmt.Sprintf("EPP credential %q stored (clID %s)", kp.EppName, kp.EppClid)
+
+	case "delete":
+		res, err := tx.Exec(`DELETE FROM EppCredentials WHERE name = ?`, kp.EppName)
+		if err != nil {
+			return resp, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return resp, fmt.Errorf("EPP credential %q not found", kp.EppName)
+		}
+		resp.Msg = fmt.Sprintf("EPP credential %q deleted", kp.EppName)
+
+	default:
+		return resp, fmt.Errorf("unknown epp-mgmt subcommand %q", kp.SubCommand)
+	}
+	return resp, nil
+}
+
+// GetEppCredential returns the clID and password stored under name.
+func (kdb *KeyDB) GetEppCredential(name string) (clid, password string, err error) {
+	err = kdb.DB.QueryRow(`SELECT clid, password FROM EppCredentials WHERE name = ?`, name).Scan(&clid, &password)
+	if err == sql.ErrNoRows {
+		return "", "", fmt.Errorf("EPP credential %q not found in keystore", name)
+	}
+	return clid, password, err
+}
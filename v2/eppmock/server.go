@@ -0,0 +1,626 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johani@johani.org
+ *
+ * Package eppmock is a small in-memory EPP server (RFC 5730/5734) for
+ * testing the epp delegation backend without a registry. It speaks TLS with
+ * a throw-away self-signed certificate and implements the subset of RFC 5731,
+ * RFC 5732 and RFC 5910 that a DNS operator's delegation updates need:
+ * login/logout/hello, host:check/info/create/update/delete and
+ * domain:info/update with secDNS-1.1, enforcing the registry rules that
+ * matter for ordering (a hostObj must exist before it is delegated to, a
+ * host in use cannot be deleted, subordinate hosts need addresses).
+ */
+package eppmock
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	domainNS = "urn:ietf:params:xml:ns:domain-1.0"
+	hostNS   = "urn:ietf:params:xml:ns:host-1.0"
+	secDNSNS = "urn:ietf:params:xml:ns:secDNS-1.1"
+)
+
+// DSData is a secDNS:dsData element as stored by the mock.
+type DSData struct {
+	KeyTag     uint16
+	Alg        uint8
+	DigestType uint8
+	Digest     string
+}
+
+type domain struct {
+	ns []string
+	ds []DSData
+}
+
+// Server is a running mock registry.
+type Server struct {
+	ln   net.Listener
+	pool *x509.CertPool
+
+	mu          sync.Mutex
+	users       map[string]string
+	domains     map[string]*domain
+	hosts       map[string][]string
+	unavailable bool
+	failNext    map[string]int
+	loseReply   map[string]bool
+	commands    []string
+	conns       map[net.Conn]bool
+	wg          sync.WaitGroup
+}
+
+// NewServer starts a mock EPP server on a random loopback port.
+func NewServer() (*Server, error) {
+	cert, pool, err := selfSigned()
+	if err != nil {
+		return nil, err
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ln:        ln,
+		pool:      pool,
+		users:     map[string]string{},
+		domains:   map[string]*domain{},
+		hosts:     map[string][]string{},
+		failNext:  map[string]int{},
+		loseReply: map[string]bool{},
+		conns:     map[net.Conn]bool{},
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr is the host:port to connect to.
+func (s *Server) Addr() string { return s.ln.Addr().String() }
+
+// CertPool trusts the server's certificate.
+func (s *Server) CertPool() *x509.CertPool { return s.pool }
+
+// Close stops the server and drops all sessions.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.dropSessions()
+	s.wg.Wait()
+}
+
+// AddUser registers a client login.
+func (s *Server) AddUser(clid, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[clid] = password
+}
+
+// AddDomain registers a domain (without NS or DS) in the registry.
+func (s *Server) AddDomain(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.domains[strings.ToLower(name)] = &domain{}
+}
+
+// Domain returns the sorted NS hostObjs and the DS set of a domain.
+func (s *Server) Domain(name string) (ns []string, ds []DSData, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.domains[strings.ToLower(name)]
+	if !ok {
+		return nil, nil, false
+	}
+	ns = append(ns, d.ns...)
+	sort.Strings(ns)
+	return ns, append(ds, d.ds...), true
+}
+
+// Host returns the sorted addresses of a host object.
+func (s *Server) Host(name string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs, ok := s.hosts[strings.ToLower(name)]
+	out := append([]string(nil), addrs...)
+	sort.Strings(out)
+	return out, ok
+}
+
+// SetUnavailable makes the server drop all sessions and refuse new ones
+// (true) or accept them again (false).
+func (s *Server) SetUnavailable(down bool) {
+	s.mu.Lock()
+	s.unavailable = down
+	s.mu.Unlock()
+	if down {
+		s.dropSessions()
+	}
+}
+
+// FailNext makes the next command named cmd (e.g. "domain:update") fail
+// with the given result code without being executed.
+func (s *Server) FailNext(cmd string, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext[cmd] = code
+}
+
+// LoseReply makes the next command named cmd execute normally, after which
+// the server drops the session instead of answering, as when a connection
+// breaks in flight.
+func (s *Server) LoseReply(cmd string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loseReply[cmd] = true
+}
+
+// Commands returns the names of all commands received so far.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *Server) dropSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		down := s.unavailable
+		if !down {
+			s.conns[conn] = true
+		}
+		s.mu.Unlock()
+		if down {
+			conn.Close()
+			continue
+		}
+		s.wg.Add(1)
+		go s.session(conn)
+	}
+}
+
+func (s *Server) session(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	if err := writeFrame(conn, []byte(greeting())); err != nil {
+		return
+	}
+	loggedIn := false
+	for {
+		buf, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		var in eppIn
+		if err := xml.Unmarshal(buf, &in); err != nil {
+			writeFrame(conn, []byte(response(2001, "Command syntax error", "", "")))
+			continue
+		}
+		if in.Hello != nil {
+			if writeFrame(conn, []byte(greeting())) != nil {
+				return
+			}
+			continue
+		}
+		if in.Command == nil {
+			writeFrame(conn, []byte(response(2001, "Command syntax error", "", "")))
+			continue
+		}
+		name := in.Command.name()
+		s.mu.Lock()
+		s.commands = append(s.commands, name)
+		var code int
+		var msg, resData string
+		if fail, ok := s.failNext[name]; ok {
+			delete(s.failNext, name)
+			code, msg = fail, "Injected failure"
+		} else {
+			code, msg, resData = s.execLocked(in.Command, &loggedIn)
+		}
+		lose := s.loseReply[name]
+		delete(s.loseReply, name)
+		s.mu.Unlock()
+		if lose {
+			return
+		}
+		if writeFrame(conn, []byte(response(code, msg, resData, in.Command.ClTRID))) != nil {
+			return
+		}
+		if name == "logout" || code >= 2500 {
+			return
+		}
+	}
+}
+
+type hostAddr struct {
+	IP   string `xml:"ip,attr"`
+	Addr string `xml:",chardata"`
+}
+
+type dsData struct {
+	KeyTag     uint16 `xml:"keyTag"`
+	Alg        uint8  `xml:"alg"`
+	DigestType uint8  `xml:"digestType"`
+	Digest     string `xml:"digest"`
+}
+
+type eppIn struct {
+	XMLName xml.Name  `xml:"epp"`
+	Hello   *struct{} `xml:"hello"`
+	Command *command  `xml:"command"`
+}
+
+type command struct {
+	Login *struct {
+		ClID string `xml:"clID"`
+		Pw   string `xml:"pw"`
+	} `xml:"login"`
+	Logout *struct{} `xml:"logout"`
+	Check  *struct {
+		Names []string `xml:"urn:ietf:params:xml:ns:host-1.0 check>name"`
+	} `xml:"check"`
+	Create *struct {
+		Name  string     `xml:"urn:ietf:params:xml:ns:host-1.0 create>name"`
+		Addrs []hostAddr `xml:"urn:ietf:params:xml:ns:host-1.0 create>addr"`
+	} `xml:"create"`
+	Delete *struct {
+		Name string `xml:"urn:ietf:params:xml:ns:host-1.0 delete>name"`
+	} `xml:"delete"`
+	Info *struct {
+		Host *struct {
+			Name string `xml:"name"`
+		} `xml:"urn:ietf:params:xml:ns:host-1.0 info"`
+		Domain *struct {
+			Name string `xml:"name"`
+		} `xml:"urn:ietf:params:xml:ns:domain-1.0 info"`
+	} `xml:"info"`
+	Update *struct {
+		Host *struct {
+			Name string     `xml:"name"`
+			Add  []hostAddr `xml:"add>addr"`
+			Rem  []hostAddr `xml:"rem>addr"`
+		} `xml:"urn:ietf:params:xml:ns:host-1.0 update"`
+		Domain *struct {
+			Name  string   `xml:"name"`
+			NSAdd []string `xml:"add>ns>hostObj"`
+			NSRem []string `xml:"rem>ns>hostObj"`
+		} `xml:"urn:ietf:params:xml:ns:domain-1.0 update"`
+	} `xml:"update"`
+	SecDNS *struct {
+		RemAll string   `xml:"rem>all"`
+		Rem    []dsData `xml:"rem>dsData"`
+		Add    []dsData `xml:"add>dsData"`
+	} `xml:"extension>update"`
+	ClTRID string `xml:"clTRID"`
+}
+
+func (c *command) name() string {
+	switch {
+	case c.Login != nil:
+		return "login"
+	case c.Logout != nil:
+		return "logout"
+	case c.Check != nil:
+		return "host:check"
+	case c.Create != nil:
+		return "host:create"
+	case c.Delete != nil:
+		return "host:delete"
+	case c.Info != nil && c.Info.Host != nil:
+		return "host:info"
+	case c.Info != nil && c.Info.Domain != nil:
+		return "domain:info"
+	case c.Update != nil && c.Update.Host != nil:
+		return "host:update"
+	case c.Update != nil && c.Update.Domain != nil:
+		return "domain:update"
+	}
+	return "unknown"
+}
+
+// execLocked applies one command to the registry state.
+func (s *Server) execLocked(c *command, loggedIn *bool) (int, string, string) {
+	name := c.name()
+	switch {
+	case name == "login":
+		if pw, ok := s.users[c.Login.ClID]; !ok || pw != c.Login.Pw {
+			return 2200, "Authentication error", ""
+		}
+		*loggedIn = true
+		return 1000, "Command completed successfully", ""
+	case name == "logout":
+		return 1500, "Command completed successfully; ending session", ""
+	case !*loggedIn:
+		return 2002, "Command use error", ""
+	}
+
+	switch name {
+	case "host:check":
+		var sb strings.Builder
+		for _, h := range c.Check.Names {
+			h = strings.ToLower(strings.TrimSpace(h))
+			avail := "1"
+			if _, ok := s.hosts[h]; ok {
+				avail = "0"
+			}
+			fmt.Fprintf(&sb, `<host:cd><host:name avail="%s">%s</host:name></host:cd>`, avail, h)
+		}
+		return 1000, "Command completed successfully",
+			`<resData><host:chkData xmlns:host="` + hostNS + `">` + sb.String() + `</host:chkData></resData>`
+
+	case "host:create":
+		h := strings.ToLower(c.Create.Name)
+		if _, ok := s.hosts[h]; ok {
+			return 2302, "Object exists", ""
+		}
+		if s.superordinate(h) != "" && len(c.Create.Addrs) == 0 {
+			return 2003, "Required parameter missing", ""
+		}
+		var addrs []string
+		for _, a := range c.Create.Addrs {
+			addrs = append(addrs, a.Addr)
+		}
+		s.hosts[h] = addrs
+		return 1000, "Command completed successfully", ""
+
+	case "host:update":
+		h := strings.ToLower(c.Update.Host.Name)
+		addrs, ok := s.hosts[h]
+		if !ok {
+			return 2303, "Object does not exist", ""
+		}
+		for _, a := range c.Update.Host.Add {
+			if contains(addrs, a.Addr) {
+				return 2302, "Object exists", ""
+			}
+			addrs = append(addrs, a.Addr)
+		}
+		for _, a := range c.Update.Host.Rem {
+			if !contains(addrs, a.Addr) {
+				return 2303, "Object does not exist", ""
+			}
+			addrs = remove(addrs, a.Addr)
+		}
+		s.hosts[h] = addrs
+		return 1000, "Command completed successfully", ""
+
+	case "host:delete":
+		h := strings.ToLower(c.Delete.Name)
+		if _, ok := s.hosts[h]; !ok {
+			return 2303, "Object does not exist", ""
+		}
+		for _, d := range s.domains {
+			if contains(d.ns, h) {
+				return 2305, "Object association prohibits operation", ""
+			}
+		}
+		delete(s.hosts, h)
+		return 1000, "Command completed successfully", ""
+
+	case "host:info":
+		h := strings.ToLower(c.Info.Host.Name)
+		addrs, ok := s.hosts[h]
+		if !ok {
+			return 2303, "Object does not exist", ""
+		}
+		var sb strings.Builder
+		for _, a := range addrs {
+			ver := "v4"
+			if strings.Contains(a, ":") {
+				ver = "v6"
+			}
+			fmt.Fprintf(&sb, `<host:addr ip="%s">%s</host:addr>`, ver, a)
+		}
+		return 1000, "Command completed successfully",
+			`<resData><host:infData xmlns:host="` + hostNS + `"><host:name>` + h + `</host:name>` + sb.String() + `</host:infData></resData>`
+
+	case "domain:info":
+		name := strings.ToLower(c.Info.Domain.Name)
+		d, ok := s.domains[name]
+		if !ok {
+			return 2303, "Object does not exist", ""
+		}
+		var ns, ds strings.Builder
+		for _, h := range d.ns {
+			ns.WriteString(`<domain:hostObj>` + h + `</domain:hostObj>`)
+		}
+		for _, x := range d.ds {
+			fmt.Fprintf(&ds, `<secDNS:dsData><secDNS:keyTag>%d</secDNS:keyTag><secDNS:alg>%d</secDNS:alg>`+
+				`<secDNS:digestType>%d</secDNS:digestType><secDNS:digest>%s</secDNS:digest></secDNS:dsData>`,
+				x.KeyTag, x.Alg, x.DigestType, x.Digest)
+		}
+		resData := `<resData><domain:infData xmlns:domain="` + domainNS + `"><domain:name>` + name + `</domain:name>` +
+			`<domain:ns>` + ns.String() + `</domain:ns></domain:infData></resData>`
+		if len(d.ds) > 0 {
+			resData += `<extension><secDNS:infData xmlns:secDNS="` + secDNSNS + `">` + ds.String() + `</secDNS:infData></extension>`
+		}
+		return 1000, "Command completed successfully", resData
+
+	case "domain:update":
+		d, ok := s.domains[strings.ToLower(c.Update.Domain.Name)]
+		if !ok {
+			return 2303, "Object does not exist", ""
+		}
+		ns := append([]string(nil), d.ns...)
+		for _, h := range c.Update.Domain.NSAdd {
+			h = strings.ToLower(h)
+			if _, ok := s.hosts[h]; !ok {
+				return 2303, "Object does not exist", ""
+			}
+			if contains(ns, h) {
+				return 2302, "Object exists", ""
+			}
+			ns = append(ns, h)
+		}
+		for _, h := range c.Update.Domain.NSRem {
+			h = strings.ToLower(h)
+			if !contains(ns, h) {
+				return 2303, "Object does not exist", ""
+			}
+			ns = remove(ns, h)
+		}
+		ds := append([]DSData(nil), d.ds...)
+		if sd := c.SecDNS; sd != nil {
+			if sd.RemAll == "true" || sd.RemAll == "1" {
+				ds = nil
+			}
+			for _, r := range sd.Rem {
+				i := indexDS(ds, r)
+				if i < 0 {
+					return 2303, "Object does not exist", ""
+				}
+				ds = append(ds[:i], ds[i+1:]...)
+			}
+			for _, a := range sd.Add {
+				if indexDS(ds, a) >= 0 {
+					return 2302, "Object exists", ""
+				}
+				ds = append(ds, DSData(a))
+			}
+		}
+		d.ns, d.ds = ns, ds
+		return 1000, "Command completed successfully", ""
+	}
+	return 2000, "Unknown command", ""
+}
+
+// superordinate returns the registered domain a host name is subordinate
+// to, or "".
+func (s *Server) superordinate(host string) string {
+	for d := range s.domains {
+		if strings.HasSuffix(host, "."+d) {
+			return d
+		}
+	}
+	return ""
+}
+
+func indexDS(ds []DSData, d dsData) int {
+	for i, x := range ds {
+		if x.KeyTag == d.KeyTag && x.Alg == d.Alg && x.DigestType == d.DigestType && strings.EqualFold(x.Digest, d.Digest) {
+			return i
+		}
+	}
+	return -1
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, s string) []string {
+	out := list[:0]
+	for _, x := range list {
+		if x != s {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func greeting() string {
+	return `<?xml version="1.0" encoding="UTF-8"?><epp xmlns="urn:ietf:params:xml:ns:epp-1.0"><greeting>` +
+		`<svID>eppmock</svID><svDate>` + time.Now().UTC().Format(time.RFC3339) + `</svDate>` +
+		`<svcMenu><version>1.0</version><lang>en</lang><objURI>` + domainNS + `</objURI><objURI>` + hostNS + `</objURI>` +
+		`<svcExtension><extURI>urn:ietf:params:xml:ns:secDNS-1.1</extURI></svcExtension></svcMenu></greeting></epp>`
+}
+
+var svtrid atomic.Int64
+
+func response(code int, msg, resData, cltrid string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><epp xmlns="urn:ietf:params:xml:ns:epp-1.0"><response>`+
+		`<result code="%d"><msg>%s</msg></result>%s<trID><clTRID>%s</clTRID><svTRID>mock-%d</svTRID></trID></response></epp>`,
+		code, msg, resData, cltrid, svtrid.Add(1))
+}
+
+func writeFrame(w io.Writer, payload []byte) error {
+	hdr := make([]byte, 4)
+	binary.BigEndian.PutUint32(hdr, uint32(len(payload)+4))
+	_, err := w.Write(append(hdr, payload...))
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	hdr := make([]byte, 4)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr)
+	if n < 4 || n > 1<<20 {
+		return nil, fmt.Errorf("bad frame length %d", n)
+	}
+	buf := make([]byte, n-4)
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
+
+func selfSigned() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "eppmock"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool, nil
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ *
+ * In-process event bus for zone, key and rollover state changes. Producers
+ * call emitEvent at the point where the state actually changes; the API
+ * server streams the events to clients as server-sent events (GET
+ * /api/v1/events), so dashboards no longer have to poll rollover/status,
+ * zone list-zones and friends to notice a change.
+ */
+
+package tdns
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types. Filters match on the full type or on a dotted prefix, so
+// "zone" selects every zone.* event.
+const (
+	EventZonePublished       = "zone.published"
+	EventZoneErrorSet        = "zone.error.set"
+	EventZoneErrorCleared    = "zone.error.cleared"
+	EventKeyState            = "key.state"
+	EventRolloverPhase       = "rollover.phase"
+	EventDelegationSyncSent  = "delegation.sync.sent"
+	EventDelegationSyncAcked = "delegation.sync.acknowledged"
+	EventCatalogMemberAdded  = "catalog.member.added"
+)
+
+// EventTypes lists every event type, for documentation and CLI completion.
+var EventTypes = []string{
+	EventZonePublished, EventZoneErrorSet, EventZoneErrorCleared, EventKeyState,
+	EventRolloverPhase, EventDelegationSyncSent, EventDelegationSyncAcked,
+	EventCatalogMemberAdded,
+}
+
+// Event is one state change. ID is assigned by the bus and increases
+// monotonically for the life of the process; it is the SSE event id, so a
+// reconnecting client can resume with Last-Event-ID.
+type Event struct {
+	ID   uint64                 `json:"id"`
+	Time time.Time              `json:"time"`
+	Type string                 `json:"type"`
+	Zone string                 `json:"zone,omitempty"`
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// EventFilter selects events by type (or type prefix) and zone. Empty
+// fields match everything.
+type EventFilter struct {
+	Types []string
+	Zones []string
+}
+
+func (f EventFilter) matches(ev Event) bool {
+	if len(f.Zones) > 0 {
+		ok := false
+		for _, z := range f.Zones {
+			if z == ev.Zone {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == ev.Type || strings.HasPrefix(ev.Type, t+".") {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	eventBacklogSize   = 512
+	eventSubscriberBuf = 128
+)
+
+// EventBus fans events out to subscribers. Publish never blocks: it is called
+// from hot paths (some under zd.mu), so a subscriber that does not keep up
+// loses events and is told so via Dropped. The last eventBacklogSize events
+// are retained for replay.
+type EventBus struct {
+	mu      sync.Mutex
+	nextID  uint64
+	backlog []Event
+	subs    map[*EventSubscription]struct{}
+}
+
+// EventSubscription is a live subscriber. Events are delivered on C.
+type EventSubscription struct {
+	C       chan Event
+	filter  EventFilter
+	mu      sync.Mutex
+	dropped uint64
+}
+
+// Dropped returns and resets the number of events lost since the last call.
+func (s *EventSubscription) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.dropped
+	s.dropped = 0
+	return n
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: map[*EventSubscription]struct{}{}}
+}
+
+// Events is the process-wide event bus.
+var Events = NewEventBus()
+
+// Publish stamps ev with an ID (and a time, if unset) and delivers it.
+func (b *EventBus) Publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	ev.ID = b.nextID
+	if len(b.backlog) == eventBacklogSize {
+		copy(b.backlog, b.backlog[1:])
+		b.backlog = b.backlog[:eventBacklogSize-1]
+	}
+	b.backlog = append(b.backlog, ev)
+	for s := range b.subs {
+		if !s.filter.matches(ev) {
+			continue
+		}
+		select {
+		case s.C <- ev:
+		default:
+			s.mu.Lock()
+			s.dropped++
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Subscribe registers a subscriber. Retained events with an ID greater than
+// after that match the filter are returned for replay; they are not also
+// delivered on C. Pass after == 0 for no replay.
+func (b *EventBus) Subscribe(f EventFilter, after uint64) (*EventSubscription, []Event) {
+	s := &EventSubscription{C: make(chan Event, eventSubscriberBuf), filter: f}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var replay []Event
+	if after > 0 {
+		for _, ev := range b.backlog {
+			if ev.ID > after && f.matches(ev) {
+				replay = append(replay, ev)
+			}
+		}
+	}
+	b.subs[s] = struct{}{}
+	return s, replay
+}
+
+// Unsubscribe removes the subscriber. C is not closed; the caller simply
+// stops reading.
+func (b *EventBus) Unsubscribe(s *EventSubscription) {
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+}
+
+// emitEvent publishes on the process-wide bus.
+func emitEvent(typ, zone string, data map[string]interface{}) {
+	Events.Publish(Event{Type: typ, Zone: zone, Data: data})
+}
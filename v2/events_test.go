@@ -0,0 +1,132 @@
+package tdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestEventBusFilterReplayAndDrop(t *testing.T) {
+	b := NewEventBus()
+	b.Publish(Event{Type: EventZonePublished, Zone: "a."})
+	b.Publish(Event{Type: EventKeyState, Zone: "a."})
+	b.Publish(Event{Type: EventZoneErrorSet, Zone: "b."})
+
+	sub, replay := b.Subscribe(EventFilter{Types: []string{"zone"}}, 1)
+	defer b.Unsubscribe(sub)
+	if len(replay) != 1 || replay[0].Type != EventZoneErrorSet || replay[0].ID != 3 {
+		t.Fatalf("replay = %+v, want only event 3 (zone.error.set)", replay)
+	}
+
+	// "zone" matches zone.* but not a type that merely starts with "zone".
+	b.Publish(Event{Type: "zonefoo", Zone: "a."})
+	b.Publish(Event{Type: EventZonePublished, Zone: "a."})
+	select {
+	case ev := <-sub.C:
+		if ev.Type != EventZonePublished || ev.ID != 5 {
+			t.Fatalf("got %+v, want zone.published id 5", ev)
+		}
+	default:
+		t.Fatal("no event delivered")
+	}
+
+	zsub, _ := b.Subscribe(EventFilter{Zones: []string{"b."}}, 0)
+	defer b.Unsubscribe(zsub)
+	for i := 0; i < eventSubscriberBuf+3; i++ {
+		b.Publish(Event{Type: EventZonePublished, Zone: "b."})
+	}
+	if n := zsub.Dropped(); n != 3 {
+		t.Errorf("Dropped() = %d, want 3", n)
+	}
+	if n := zsub.Dropped(); n != 0 {
+		t.Errorf("Dropped() not reset: %d", n)
+	}
+}
+
+// End to end over HTTP: the handler streams matching events and the client
+// resumes from the last id it saw.
+func TestEventStreamFollow(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(APIevents(&Config{})))
+	defer srv.Close()
+	api := &ApiClient{Client: srv.Client(), BaseUrl: srv.URL, AuthMethod: "none"}
+
+	const zone = "stream-test.example."
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errDone := errors.New("done")
+	var got []Event
+	var lastID uint64
+	go func() {
+		// Publish until the subscriber is attached and has seen both events.
+		for ctx.Err() == nil {
+			emitEvent(EventZoneErrorSet, zone, map[string]interface{}{"error_type": "refresh"})
+			emitEvent(EventZonePublished, "other.example.", nil)
+			emitEvent(EventZonePublished, zone, map[string]interface{}{"serial": uint32(2026030101)})
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+	q := url.Values{"type": {EventZonePublished}, "zone": {"stream-test.example"}}
+	err := api.FollowEvents(ctx, q, &lastID, func(ev Event) error {
+		got = append(got, ev)
+		if len(got) == 2 {
+			return errDone
+		}
+		return nil
+	})
+	if !errors.Is(err, errDone) {
+		t.Fatalf("FollowEvents: %v", err)
+	}
+	for _, ev := range got {
+		if ev.Type != EventZonePublished || ev.Zone != zone {
+			t.Errorf("unfiltered event delivered: %+v", ev)
+		}
+	}
+	if got[0].Data["serial"].(interface{ String() string }).String() != "2026030101" {
+		t.Errorf("serial = %v, want 2026030101 verbatim", got[0].Data["serial"])
+	}
+	if lastID != got[1].ID {
+		t.Errorf("lastID = %d, want %d", lastID, got[1].ID)
+	}
+}
+
+// Key state events are emitted only once the transaction commits.
+func TestKeyStateEventAfterCommit(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	if _, err := kdb.DB.Exec(`INSERT INTO DnssecKeyStore (zonename, state, keyid, flags, algorithm, creator, privatekey, keyrr)
+VALUES ('keyevent.example.', 'published', 4711, 257, 'ED25519', 'test', '', '')`); err != nil {
+		t.Fatalf("insert key: %v", err)
+	}
+	sub, _ := Events.Subscribe(EventFilter{Types: []string{EventKeyState}, Zones: []string{"keyevent.example."}}, 0)
+	defer Events.Unsubscribe(sub)
+
+	tx, err := kdb.Begin("test")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if err := UpdateDnssecKeyStateTx(tx, kdb, "keyevent.example.", 4711, DnskeyStateStandby); err != nil {
+		t.Fatalf("UpdateDnssecKeyStateTx: %v", err)
+	}
+	tx.Rollback()
+	select {
+	case ev := <-sub.C:
+		t.Fatalf("event for rolled-back transition: %+v", ev)
+	default:
+	}
+
+	if err := UpdateDnssecKeyState(kdb, "keyevent.example.", 4711, DnskeyStateStandby); err != nil {
+		t.Logf("UpdateDnssecKeyState: %v (republish without a loaded zone)", err)
+	}
+	select {
+	case ev := <-sub.C:
+		if ev.Data["keyid"] != uint16(4711) || ev.Data["old_state"] != DnskeyStatePublished || ev.Data["new_state"] != DnskeyStateStandby {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	default:
+		t.Fatal("no key.state event after commit")
+	}
+}
@@ -0,0 +1,384 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	edns0 "github.com/johanix/tdns/v2/edns0"
+	"github.com/miekg/dns"
+)
+
+// Client access control for the IMR listeners.
+//
+// Every query is first matched against an address ACL: the rule of the
+// first Listeners entry matching the local address and transport it arrived
+// on, otherwise the global AllowQuery list. A client that is not allowed
+// gets REFUSED with EDE 18 (Prohibited). Without any configuration only
+// localhost and private networks are allowed, so a resolver bound to a
+// public address is not an open resolver by accident.
+//
+// Allowed clients are then rate limited per netblock (token bucket), and
+// matched against the client groups, whose options adjust how the query is
+// handled: require-dnssec turns every answer that is not Secure into
+// SERVFAIL, blocking: false skips the blocking (filtering) query hooks and
+// local zones, no-rate-limit exempts the group from rate limiting.
+
+// defaultImrAllowQuery is used when imrengine.access.allow-query is unset.
+var defaultImrAllowQuery = []AclEntry{
+	{Prefix: "127.0.0.0/8", Key: NOKEY},
+	{Prefix: "::1/128", Key: NOKEY},
+	{Prefix: "10.0.0.0/8", Key: NOKEY},
+	{Prefix: "172.16.0.0/12", Key: NOKEY},
+	{Prefix: "192.168.0.0/16", Key: NOKEY},
+	{Prefix: "100.64.0.0/10", Key: NOKEY},
+	{Prefix: "169.254.0.0/16", Key: NOKEY},
+	{Prefix: "fc00::/7", Key: NOKEY},
+	{Prefix: "fe80::/10", Key: NOKEY},
+}
+
+// ImrClientGroup is a configured client group.
+type ImrClientGroup struct {
+	Name          string
+	Clients       []AclEntry
+	RequireDnssec bool // SERVFAIL unless the answer validated as Secure
+	Blocking      bool // run the blocking query hooks and local zones
+	NoRateLimit   bool
+}
+
+type imrListenerAcl struct {
+	addr       netip.Addr // invalid = any address
+	port       uint16     // 0 = any port
+	transports []string   // empty = all transports
+	allow      []AclEntry
+}
+
+// imrAccess is the compiled access configuration.
+type imrAccess struct {
+	allow     []AclEntry
+	listeners []imrListenerAcl
+	groups    []*ImrClientGroup
+	limiter   *rateLimiter // nil when rate limiting is off
+	refuse    bool         // answer rate-limited queries with REFUSED instead of dropping them
+
+	denied      atomic.Uint64
+	rateLimited atomic.Uint64
+}
+
+// ImrAccessStats is the response to "imr-access-stats".
+type ImrAccessStats struct {
+	Denied      uint64   `json:"denied"`
+	RateLimited uint64   `json:"rate_limited"`
+	Netblocks   int      `json:"netblocks"`
+	Groups      []string `json:"groups"`
+}
+
+// imrAclKey accepts the only keys meaningful in an IMR ACL; the IMR does
+// not verify TSIG on queries.
+func imrAclKey(k string) bool { return k == NOKEY }
+
+// buildImrAccess validates and compiles the access configuration.
+func buildImrAccess(conf ImrAccessConf) (*imrAccess, error) {
+	a := &imrAccess{allow: conf.AllowQuery}
+	if len(a.allow) == 0 {
+		a.allow = defaultImrAllowQuery
+	}
+	if err := ValidateACL(a.allow, imrAclKey); err != nil {
+		return nil, fmt.Errorf("access.allow-query: %w", err)
+	}
+
+	for i, lc := range conf.Listeners {
+		l := imrListenerAcl{allow: lc.AllowQuery}
+		if lc.Address != "" {
+			if ap, err := netip.ParseAddrPort(lc.Address); err == nil {
+				l.addr, l.port = ap.Addr().Unmap(), ap.Port()
+			} else if addr, err := netip.ParseAddr(lc.Address); err == nil {
+				l.addr = addr.Unmap()
+			} else {
+				return nil, fmt.Errorf("access.listeners[%d]: invalid address %q", i, lc.Address)
+			}
+		}
+		for _, t := range lc.Transports {
+			t = strings.ToLower(t)
+			switch t {
+			case "do53", "dot", "doh", "doq":
+				l.transports = append(l.transports, t)
+			default:
+				return nil, fmt.Errorf("access.listeners[%d]: unknown transport %q", i, t)
+			}
+		}
+		if len(l.allow) == 0 {
+			return nil, fmt.Errorf("access.listeners[%d]: empty allow-query", i)
+		}
+		if err := ValidateACL(l.allow, imrAclKey); err != nil {
+			return nil, fmt.Errorf("access.listeners[%d]: %w", i, err)
+		}
+		a.listeners = append(a.listeners, l)
+	}
+
+	seen := map[string]bool{}
+	for i, gc := range conf.ClientGroups {
+		if gc.Name == "" {
+			return nil, fmt.Errorf("access.client-groups[%d]: missing name", i)
+		}
+		if seen[gc.Name] {
+			return nil, fmt.Errorf("access.client-groups: group %q configured more than once", gc.Name)
+		}
+		seen[gc.Name] = true
+		if len(gc.Clients) == 0 {
+			return nil, fmt.Errorf("access.client-groups %q: no clients", gc.Name)
+		}
+		if err := ValidateACL(gc.Clients, imrAclKey); err != nil {
+			return nil, fmt.Errorf("access.client-groups %q: %w", gc.Name, err)
+		}
+		g := &ImrClientGroup{
+			Name:          gc.Name,
+			Clients:       gc.Clients,
+			RequireDnssec: gc.RequireDnssec,
+			Blocking:      gc.Blocking == nil || *gc.Blocking,
+			NoRateLimit:   gc.NoRateLimit,
+		}
+		a.groups = append(a.groups, g)
+	}
+
+	rl := conf.RateLimit
+	switch strings.ToLower(rl.Action) {
+	case "", "drop":
+	case "refuse":
+		a.refuse = true
+	default:
+		return nil, fmt.Errorf("access.rate-limit: unknown action %q (use drop or refuse)", rl.Action)
+	}
+	if rl.QPS < 0 || rl.Burst < 0 {
+		return nil, fmt.Errorf("access.rate-limit: qps and burst must not be negative")
+	}
+	if rl.QPS > 0 {
+		v4, v6 := rl.IPv4PrefixLen, rl.IPv6PrefixLen
+		if v4 == 0 {
+			v4 = 24
+		}
+		if v6 == 0 {
+			v6 = 56
+		}
+		if v4 < 0 || v4 > 32 || v6 < 0 || v6 > 128 {
+			return nil, fmt.Errorf("access.rate-limit: invalid prefix length %d/%d", v4, v6)
+		}
+		burst := rl.Burst
+		if burst == 0 {
+			burst = 2 * rl.QPS
+		}
+		a.limiter = newRateLimiter(float64(rl.QPS), float64(burst), v4, v6)
+	}
+	return a, nil
+}
+
+// allowed reports whether a query from src arriving on local over transport
+// passes the address ACL.
+func (a *imrAccess) allowed(src netip.Addr, local netip.AddrPort, transport string) bool {
+	for _, l := range a.listeners {
+		if l.addr.IsValid() && l.addr != local.Addr() {
+			continue
+		}
+		if l.port != 0 && l.port != local.Port() {
+			continue
+		}
+		if len(l.transports) > 0 && !CaseFoldContains(l.transports, transport) {
+			continue
+		}
+		ok, _ := matchACL(l.allow, src)
+		return ok
+	}
+	ok, _ := matchACL(a.allow, src)
+	return ok
+}
+
+// group returns the first client group containing src, or nil.
+func (a *imrAccess) group(src netip.Addr) *ImrClientGroup {
+	for _, g := range a.groups {
+		if ok, _ := matchACL(g.Clients, src); ok {
+			return g
+		}
+	}
+	return nil
+}
+
+func (a *imrAccess) stats() ImrAccessStats {
+	s := ImrAccessStats{Denied: a.denied.Load(), RateLimited: a.rateLimited.Load()}
+	if a.limiter != nil {
+		s.Netblocks = a.limiter.size()
+	}
+	for _, g := range a.groups {
+		s.Groups = append(s.Groups, g.Name)
+	}
+	return s
+}
+
+// addrPortOf extracts the address and port of a listener or client address.
+func addrPortOf(a net.Addr) netip.AddrPort {
+	if a == nil {
+		return netip.AddrPort{}
+	}
+	var ap netip.AddrPort
+	switch v := a.(type) {
+	case *net.UDPAddr:
+		ap = v.AddrPort()
+	case *net.TCPAddr:
+		ap = v.AddrPort()
+	default:
+		ap, _ = netip.ParseAddrPort(a.String())
+	}
+	return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())
+}
+
+// checkImrClientAccess applies the access configuration to an incoming
+// message. When the client may not be served it writes the response (or
+// drops the query) and returns ok=false. Otherwise it returns the client's
+// group, which is nil for clients outside all groups.
+func (imr *Imr) checkImrClientAccess(w dns.ResponseWriter, r *dns.Msg, transport string) (group *ImrClientGroup, ok bool) {
+	a := imr.access
+	if a == nil {
+		return nil, true
+	}
+	src := addrPortOf(w.RemoteAddr()).Addr()
+	if !a.allowed(src, addrPortOf(w.LocalAddr()), transport) {
+		a.denied.Add(1)
+		lgImr.Debug("query refused by access control", "from", w.RemoteAddr(), "transport", transport)
+		refuseImrClient(w, r, "recursion not permitted for this client")
+		return nil, false
+	}
+	group = a.group(src)
+	if a.limiter != nil && (group == nil || !group.NoRateLimit) && !a.limiter.allow(src, time.Now()) {
+		a.rateLimited.Add(1)
+		if a.refuse {
+			refuseImrClient(w, r, "query rate limit exceeded")
+		} else if rw, ok := w.(rateLimitedWriter); ok {
+			rw.RateLimited()
+		}
+		return nil, false
+	}
+	return group, true
+}
+
+// refuseImrClient answers REFUSED, with an EDE when the query used EDNS0.
+func refuseImrClient(w dns.ResponseWriter, r *dns.Msg, text string) {
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeRefused)
+	if opt := r.IsEdns0(); opt != nil {
+		edns0.AttachEDEToResponseWithText(m, edns0.EDEProhibited, text, opt.Do())
+	}
+	w.WriteMsg(m)
+}
+
+// rateLimitedWriter is implemented by transports that must answer even a
+// dropped query (DoH answers HTTP 429 instead of an empty 200).
+type rateLimitedWriter interface {
+	RateLimited()
+}
+
+// requireDnssecWriter enforces require-dnssec for a client group: answers
+// and negative answers that did not validate as Secure become SERVFAIL.
+type requireDnssecWriter struct {
+	dns.ResponseWriter
+	dnssecOK bool
+}
+
+func (w *requireDnssecWriter) WriteMsg(m *dns.Msg) error {
+	if (m.Rcode == dns.RcodeSuccess || m.Rcode == dns.RcodeNameError) && !m.AuthenticatedData {
+		sf := new(dns.Msg)
+		sf.SetRcode(m, dns.RcodeServerFailure)
+		sf.RecursionAvailable = m.RecursionAvailable
+		if m.IsEdns0() != nil {
+			sf.SetEdns0(dns.DefaultMsgSize, w.dnssecOK)
+			edns0.AttachEDEToResponseWithText(sf, edns0.EDEDNSSECIndeterminate,
+				"answer is not DNSSEC secure and this client requires validation", w.dnssecOK)
+		}
+		return w.ResponseWriter.WriteMsg(sf)
+	}
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+// AccessStats returns the access-control counters.
+func (imr *Imr) AccessStats() ImrAccessStats {
+	if imr.access == nil {
+		return ImrAccessStats{}
+	}
+	return imr.access.stats()
+}
+
+// rateLimiter is a token bucket per client netblock. Buckets that have
+// refilled completely carry no state and are swept periodically.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	v4, v6    int
+	buckets   map[netip.Prefix]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate, burst float64, v4, v6 int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: burst, v4: v4, v6: v6, buckets: map[netip.Prefix]*tokenBucket{}}
+}
+
+func (rl *rateLimiter) netblock(src netip.Addr) netip.Prefix {
+	bits := rl.v6
+	if src.Is4() {
+		bits = rl.v4
+	}
+	p, _ := src.Prefix(bits)
+	return p
+}
+
+// allow takes one token from the bucket of src's netblock.
+func (rl *rateLimiter) allow(src netip.Addr, now time.Time) bool {
+	key := rl.netblock(src)
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if now.Sub(rl.lastSweep) > time.Minute {
+		rl.sweep(now)
+	}
+	b := rl.buckets[key]
+	if b == nil {
+		b = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = b
+	} else {
+		b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep drops the buckets that would be full by now. Caller holds rl.mu.
+func (rl *rateLimiter) sweep(now time.Time) {
+	for k, b := range rl.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rl.rate >= rl.burst {
+			delete(rl.buckets, k)
+		}
+	}
+	rl.lastSweep = now
+}
+
+func (rl *rateLimiter) size() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return len(rl.buckets)
+}
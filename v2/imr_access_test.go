@@ -0,0 +1,372 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	edns0 "github.com/johanix/tdns/v2/edns0"
+	"github.com/miekg/dns"
+)
+
+// accessRW is a ResponseWriter with fixed addresses that keeps the reply.
+type accessRW struct {
+	dns.ResponseWriter
+	local, remote net.Addr
+	reply         *dns.Msg
+}
+
+func (w *accessRW) LocalAddr() net.Addr       { return w.local }
+func (w *accessRW) RemoteAddr() net.Addr      { return w.remote }
+func (w *accessRW) WriteMsg(m *dns.Msg) error { w.reply = m; return nil }
+
+func newAccessRW(local, remote string) *accessRW {
+	return &accessRW{
+		local:  net.UDPAddrFromAddrPort(netip.MustParseAddrPort(local)),
+		remote: net.UDPAddrFromAddrPort(netip.MustParseAddrPort(remote)),
+	}
+}
+
+func TestBuildImrAccessDefaultsAndErrors(t *testing.T) {
+	a, err := buildImrAccess(ImrAccessConf{})
+	if err != nil {
+		t.Fatalf("empty config: %v", err)
+	}
+	local := netip.MustParseAddrPort("192.0.2.1:53")
+	for src, want := range map[string]bool{
+		"127.0.0.1":    true,
+		"::1":          true,
+		"10.1.2.3":     true,
+		"192.168.0.9":  true,
+		"fd00::1":      true,
+		"198.51.100.7": false,
+		"2001:db8::1":  false,
+	} {
+		if got := a.allowed(netip.MustParseAddr(src), local, "do53"); got != want {
+			t.Errorf("default ACL: %s allowed = %v, want %v", src, got, want)
+		}
+	}
+	if a.limiter != nil {
+		t.Error("rate limiter enabled without qps")
+	}
+
+	bad := []ImrAccessConf{
+		{AllowQuery: []AclEntry{{Prefix: "10.0.0.1", Key: NOKEY}}},
+		{AllowQuery: []AclEntry{{Prefix: "10.0.0.0/8", Key: "sometsigkey"}}},
+		{Listeners: []ImrListenerAclConf{{Address: "not-an-address", AllowQuery: []AclEntry{{Prefix: "::/0", Key: NOKEY}}}}},
+		{Listeners: []ImrListenerAclConf{{Transports: []string{"smtp"}, AllowQuery: []AclEntry{{Prefix: "::/0", Key: NOKEY}}}}},
+		{Listeners: []ImrListenerAclConf{{Address: "192.0.2.1"}}},
+		{ClientGroups: []ImrClientGroupConf{{Name: "a", Clients: []AclEntry{{Prefix: "10.0.0.0/8", Key: NOKEY}}}, {Name: "a", Clients: []AclEntry{{Prefix: "10.0.0.0/8", Key: NOKEY}}}}},
+		{ClientGroups: []ImrClientGroupConf{{Name: "a"}}},
+		{RateLimit: ImrRateLimitConf{QPS: 10, Action: "tarpit"}},
+		{RateLimit: ImrRateLimitConf{QPS: 10, IPv4PrefixLen: 33}},
+	}
+	for i, c := range bad {
+		if _, err := buildImrAccess(c); err == nil {
+			t.Errorf("bad config %d accepted", i)
+		}
+	}
+}
+
+func TestImrAccessListenerRules(t *testing.T) {
+	a, err := buildImrAccess(ImrAccessConf{
+		AllowQuery: []AclEntry{{Prefix: "10.0.0.0/8", Key: NOKEY}, {Prefix: "10.9.0.0/16", Key: BLOCKED}},
+		Listeners: []ImrListenerAclConf{
+			// The public address serves DoH and DoT to anyone.
+			{Address: "192.0.2.1", Transports: []string{"doh", "DoT"}, AllowQuery: []AclEntry{{Prefix: "0.0.0.0/0", Key: NOKEY}, {Prefix: "::/0", Key: NOKEY}}},
+			// Do53 on the public address only for a partner network.
+			{Address: "192.0.2.1:53", AllowQuery: []AclEntry{{Prefix: "198.51.100.0/24", Key: NOKEY}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub53 := netip.MustParseAddrPort("192.0.2.1:53")
+	pub443 := netip.MustParseAddrPort("192.0.2.1:443")
+	internal := netip.MustParseAddrPort("10.0.0.1:53")
+	cases := []struct {
+		src       string
+		local     netip.AddrPort
+		transport string
+		want      bool
+	}{
+		{"203.0.113.5", pub443, "doh", true},
+		{"203.0.113.5", pub53, "do53", false},
+		{"198.51.100.5", pub53, "do53", true},
+		{"10.1.1.1", pub53, "do53", false}, // the listener rule replaces the global ACL
+		{"10.1.1.1", internal, "do53", true},
+		{"10.9.1.1", internal, "do53", false}, // BLOCKED supersedes
+		{"203.0.113.5", internal, "dot", false},
+	}
+	for _, c := range cases {
+		if got := a.allowed(netip.MustParseAddr(c.src), c.local, c.transport); got != c.want {
+			t.Errorf("%s -> %s over %s: allowed = %v, want %v", c.src, c.local, c.transport, got, c.want)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	rl := newRateLimiter(10, 5, 24, 56)
+	now := time.Now()
+	a := netip.MustParseAddr("192.0.2.1")
+	b := netip.MustParseAddr("192.0.2.200") // same /24
+	c := netip.MustParseAddr("192.0.3.1")
+	for i := 0; i < 5; i++ {
+		if !rl.allow(a, now) {
+			t.Fatalf("query %d within burst refused", i)
+		}
+	}
+	if rl.allow(b, now) {
+		t.Error("same netblock not limited once the burst is spent")
+	}
+	if !rl.allow(c, now) {
+		t.Error("other netblock limited")
+	}
+	// 10 qps refills one token per 100ms.
+	if !rl.allow(a, now.Add(150*time.Millisecond)) || rl.allow(a, now.Add(150*time.Millisecond)) {
+		t.Error("refill is not one token per 100ms")
+	}
+	// Idle buckets are swept.
+	rl.allow(c, now.Add(2*time.Minute))
+	if n := rl.size(); n != 1 {
+		t.Errorf("%d buckets after sweep, want 1", n)
+	}
+}
+
+func TestCheckImrClientAccess(t *testing.T) {
+	nob := false
+	access, err := buildImrAccess(ImrAccessConf{
+		AllowQuery: []AclEntry{{Prefix: "0.0.0.0/0", Key: NOKEY}, {Prefix: "203.0.113.0/24", Key: BLOCKED}},
+		RateLimit:  ImrRateLimitConf{QPS: 1, Burst: 1, Action: "refuse"},
+		ClientGroups: []ImrClientGroupConf{
+			{Name: "lab", Clients: []AclEntry{{Prefix: "10.0.0.0/8", Key: NOKEY}}, Blocking: &nob, NoRateLimit: true},
+			{Name: "strict", Clients: []AclEntry{{Prefix: "192.0.2.0/24", Key: NOKEY}}, RequireDnssec: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imr := &Imr{access: access}
+	q := new(dns.Msg)
+	q.SetQuestion("www.example.", dns.TypeA)
+	q.SetEdns0(1232, true)
+
+	w := newAccessRW("192.0.2.1:53", "203.0.113.9:4000")
+	if _, ok := imr.checkImrClientAccess(w, q, "do53"); ok {
+		t.Fatal("blocked client accepted")
+	}
+	if w.reply == nil || w.reply.Rcode != dns.RcodeRefused {
+		t.Fatalf("blocked client reply = %v, want REFUSED", w.reply)
+	}
+	if found, code, _ := edns0.ExtractEDEFromMsg(w.reply); !found || code != edns0.EDEProhibited {
+		t.Errorf("REFUSED without EDE Prohibited (found %v, code %d)", found, code)
+	}
+
+	// Without EDNS0 in the query there is no OPT in the reply.
+	plain := new(dns.Msg)
+	plain.SetQuestion("www.example.", dns.TypeA)
+	w = newAccessRW("192.0.2.1:53", "203.0.113.9:4000")
+	imr.checkImrClientAccess(w, plain, "do53")
+	if w.reply == nil || w.reply.IsEdns0() != nil {
+		t.Errorf("reply to non-EDNS query carries OPT: %v", w.reply)
+	}
+
+	w = newAccessRW("192.0.2.1:53", "10.1.2.3:4000")
+	for i := 0; i < 3; i++ {
+		g, ok := imr.checkImrClientAccess(w, q, "do53")
+		if !ok || g == nil || g.Name != "lab" || g.Blocking {
+			t.Fatalf("lab client query %d: group %+v, ok %v", i, g, ok)
+		}
+	}
+
+	w = newAccessRW("192.0.2.1:53", "192.0.2.77:4000")
+	if g, ok := imr.checkImrClientAccess(w, q, "do53"); !ok || g == nil || !g.RequireDnssec || !g.Blocking {
+		t.Fatalf("strict client: group %+v, ok %v", g, ok)
+	}
+	if _, ok := imr.checkImrClientAccess(w, q, "do53"); ok {
+		t.Fatal("second query inside one second not rate limited")
+	}
+	if w.reply == nil || w.reply.Rcode != dns.RcodeRefused {
+		t.Errorf("rate-limited reply = %v, want REFUSED with action refuse", w.reply)
+	}
+	if s := imr.AccessStats(); s.Denied != 2 || s.RateLimited != 1 || len(s.Groups) != 2 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestRequireDnssecWriter(t *testing.T) {
+	q := new(dns.Msg)
+	q.SetQuestion("www.example.", dns.TypeA)
+	q.SetEdns0(1232, true)
+	answer := func(ad bool, rcode int) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetRcode(q, rcode)
+		m.AuthenticatedData = ad
+		m.SetEdns0(1232, true)
+		return m
+	}
+
+	inner := newAccessRW("192.0.2.1:53", "192.0.2.77:4000")
+	w := &requireDnssecWriter{ResponseWriter: inner, dnssecOK: true}
+	for _, tc := range []struct {
+		ad    bool
+		rcode int
+		want  int
+	}{
+		{true, dns.RcodeSuccess, dns.RcodeSuccess},
+		{false, dns.RcodeSuccess, dns.RcodeServerFailure},
+		{false, dns.RcodeNameError, dns.RcodeServerFailure},
+		{true, dns.RcodeNameError, dns.RcodeNameError},
+		{false, dns.RcodeServerFailure, dns.RcodeServerFailure},
+	} {
+		w.WriteMsg(answer(tc.ad, tc.rcode))
+		if inner.reply.Rcode != tc.want {
+			t.Errorf("AD=%v %s: client got %s, want %s", tc.ad, dns.RcodeToString[tc.rcode],
+				dns.RcodeToString[inner.reply.Rcode], dns.RcodeToString[tc.want])
+		}
+	}
+	w.WriteMsg(answer(false, dns.RcodeSuccess))
+	if found, code, _ := edns0.ExtractEDEFromMsg(inner.reply); !found || code != edns0.EDEDNSSECIndeterminate {
+		t.Errorf("SERVFAIL without EDE DNSSEC Indeterminate (found %v, code %d)", found, code)
+	}
+}
+
+// A rate-limited DoH query is reported to the transport, which answers
+// HTTP 429 instead of an empty 200.
+func TestRateLimitedDoH(t *testing.T) {
+	access, err := buildImrAccess(ImrAccessConf{
+		AllowQuery: []AclEntry{{Prefix: "0.0.0.0/0", Key: NOKEY}},
+		RateLimit:  ImrRateLimitConf{QPS: 1, Burst: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imr := &Imr{access: access}
+	q := new(dns.Msg)
+	q.SetQuestion("www.example.", dns.TypeA)
+	rw := &dohResponseWriter{remote: net.TCPAddrFromAddrPort(netip.MustParseAddrPort("192.0.2.9:40000")), local: dummyAddr{}}
+	if _, ok := imr.checkImrClientAccess(rw, q, "doh"); !ok || rw.rateLimited {
+		t.Fatalf("first query: ok %v, rate limited %v", ok, rw.rateLimited)
+	}
+	if _, ok := imr.checkImrClientAccess(rw, q, "doh"); ok || !rw.rateLimited {
+		t.Fatalf("second query: ok %v, rate limited %v; want it dropped and flagged", ok, rw.rateLimited)
+	}
+}
+
+// A client group with blocking turned off skips the blocking hooks and
+// sees the blocking local zones as transparent; a group with blocking on
+// gets both.
+func TestImrClientGroupBlocking(t *testing.T) {
+	globalImrClientQueryHooksMutex.Lock()
+	saved := globalImrClientQueryHooks
+	globalImrClientQueryHooks = nil
+	globalImrClientQueryHooksMutex.Unlock()
+	t.Cleanup(func() {
+		globalImrClientQueryHooksMutex.Lock()
+		globalImrClientQueryHooks = saved
+		globalImrClientQueryHooksMutex.Unlock()
+	})
+
+	var ran []string
+	hook := func(name string) ImrClientQueryHookFunc {
+		return func(context.Context, dns.ResponseWriter, *dns.Msg, string, uint16, *edns0.MsgOptions) (context.Context, *dns.Msg) {
+			ran = append(ran, name)
+			return nil, nil
+		}
+	}
+	RegisterImrClientQueryHook(hook("log"))
+	RegisterImrBlockingQueryHook(hook("rpz"))
+	RegisterImrClientQueryHook(hook("ratelimit"))
+
+	nob := false
+	access, err := buildImrAccess(ImrAccessConf{
+		AllowQuery: []AclEntry{{Prefix: "0.0.0.0/0", Key: NOKEY}},
+		ClientGroups: []ImrClientGroupConf{
+			{Name: "filtered", Clients: []AclEntry{{Prefix: "192.0.2.0/24", Key: NOKEY}}},
+			{Name: "open", Clients: []AclEntry{{Prefix: "10.0.0.0/8", Key: NOKEY}}, Blocking: &nob},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imr := &Imr{access: access, Cache: cache.NewRRsetCache(log.Default(), false, false)}
+	err = imr.initLocalZones([]ImrLocalZoneConf{
+		{Zone: "ads.example.", Type: "always-nxdomain"},
+		{Zone: "blocked.example.", Type: "refuse"},
+		{Zone: "portal.example.", Type: "redirect"},
+		{Zone: "lan.", Type: "static"},
+	}, []string{
+		"portal.example. IN A 192.0.2.80",
+		"printer.lan. IN A 192.0.2.10",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type answer struct {
+		local   bool
+		rcode   int
+		answers int
+	}
+	for _, tc := range []struct {
+		client string
+		group  string
+		hooks  string
+		want   map[string]answer
+	}{
+		{"192.0.2.7:4000", "filtered", "log rpz ratelimit", map[string]answer{
+			"www.ads.example.":     {true, dns.RcodeNameError, 0},
+			"www.blocked.example.": {true, dns.RcodeRefused, 0},
+			"www.portal.example.":  {true, dns.RcodeSuccess, 1},
+			"portal.example.":      {true, dns.RcodeSuccess, 1},
+			"nothere.lan.":         {true, dns.RcodeNameError, 0},
+			"printer.lan.":         {true, dns.RcodeSuccess, 1},
+		}},
+		{"10.1.2.3:4000", "open", "log ratelimit", map[string]answer{
+			"www.ads.example.":     {false, 0, 0},
+			"www.blocked.example.": {false, 0, 0},
+			"www.portal.example.":  {false, 0, 0},
+			"portal.example.":      {true, dns.RcodeSuccess, 1}, // local data is still served
+			"nothere.lan.":         {true, dns.RcodeNameError, 0},
+			"printer.lan.":         {true, dns.RcodeSuccess, 1},
+		}},
+	} {
+		q := new(dns.Msg)
+		q.SetQuestion("www.example.", dns.TypeA)
+		g, ok := imr.checkImrClientAccess(newAccessRW("192.0.2.1:53", tc.client), q, "do53")
+		if !ok || g == nil || g.Name != tc.group {
+			t.Fatalf("%s: group %+v, ok %v; want %s", tc.client, g, ok, tc.group)
+		}
+
+		ran = nil
+		for _, h := range getImrClientQueryHooks(g.Blocking) {
+			h(context.Background(), nil, nil, "", 0, nil)
+		}
+		if got := strings.Join(ran, " "); got != tc.hooks {
+			t.Errorf("%s: ran %q, want %q", tc.group, got, tc.hooks)
+		}
+
+		for qname, want := range tc.want {
+			q := new(dns.Msg)
+			q.SetQuestion(qname, dns.TypeA)
+			m := imr.localResponse(q, qname, dns.TypeA, g.Blocking)
+			if (m != nil) != want.local {
+				t.Errorf("%s %s: local answer %v, want %v", tc.group, qname, m != nil, want.local)
+				continue
+			}
+			if m != nil && (m.Rcode != want.rcode || len(m.Answer) != want.answers) {
+				t.Errorf("%s %s: %s with %d answers, want %s with %d", tc.group, qname,
+					dns.RcodeToString[m.Rcode], len(m.Answer), dns.RcodeToString[want.rcode], want.answers)
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// In-flight query coalescing and prefetch for the IMR.
+//
+// Concurrent identical lookups share one resolution: the first caller for a
+// key (the leader) starts the walk, later callers wait for its result. The
+// walk does not end when the leader gives up, so it can still serve the
+// others. Keys
+// include the DO and CD bits and the PR requirement, so requests that may
+// be answered differently never share a walk.
+//
+// A cache hit on a positive answer in the last Tuning.Prefetch.Threshold
+// percent of its TTL starts a background refresh, so popular names are
+// renewed before they expire instead of costing the next client a full
+// resolution.
+
+// flightCall is one in-flight resolution. leader is the resolution chain
+// that runs it.
+type flightCall[T any] struct {
+	done   chan struct{}
+	val    T
+	err    error
+	leader *flightWalker
+}
+
+// flightGroup deduplicates concurrent calls with the same key. The zero
+// value is ready to use.
+type flightGroup[T any] struct {
+	mu        sync.Mutex
+	calls     map[string]*flightCall[T]
+	coalesced atomic.Uint64
+	cycles    atomic.Uint64
+}
+
+// defaultFlightBudget bounds a shared call when the caller gives no budget;
+// it matches the default Tuning.QueryBudget.
+const defaultFlightBudget = 8 * time.Second
+
+// flightChain lists the keys led by the current call chain. A resolution
+// may recursively need the very name it is resolving (e.g. a glueless NS
+// under the zone itself); waiting for ourselves would only end when the
+// query budget runs out, so such nested calls bypass the group.
+type flightChain struct {
+	key    string
+	parent *flightChain
+	walker *flightWalker
+}
+
+type flightChainKey struct{}
+
+func (c *flightChain) has(key string) bool {
+	for ; c != nil; c = c.parent {
+		if c.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// flightWalker is one resolution chain: the outermost call and everything it
+// leads, in every flightGroup. Two chains that each wait for a call the
+// other leads (A needs B's NS address while B needs A's) would only be
+// released by their budgets, so a chain about to wait first checks the
+// wait-for graph and runs the call itself if waiting would close a cycle.
+type flightWalker struct {
+	waiting map[*flightWalker]int // leaders of the calls this chain waits for
+}
+
+// flightWaitMu guards flightWalker.waiting across all groups.
+var flightWaitMu sync.Mutex
+
+// startWait records that w waits for a call led by leader, unless that
+// would close a cycle in the wait-for graph; then it returns false.
+func (w *flightWalker) startWait(leader *flightWalker) bool {
+	if w == nil || leader == nil {
+		return true
+	}
+	flightWaitMu.Lock()
+	defer flightWaitMu.Unlock()
+	seen := map[*flightWalker]bool{}
+	for queue := []*flightWalker{leader}; len(queue) > 0; queue = queue[1:] {
+		x := queue[0]
+		if x == w {
+			return false
+		}
+		if seen[x] {
+			continue
+		}
+		seen[x] = true
+		for next := range x.waiting {
+			queue = append(queue, next)
+		}
+	}
+	if w.waiting == nil {
+		w.waiting = map[*flightWalker]int{}
+	}
+	w.waiting[leader]++
+	return true
+}
+
+func (w *flightWalker) stopWait(leader *flightWalker) {
+	if w == nil || leader == nil {
+		return
+	}
+	flightWaitMu.Lock()
+	defer flightWaitMu.Unlock()
+	if w.waiting[leader]--; w.waiting[leader] <= 0 {
+		delete(w.waiting, leader)
+	}
+}
+
+// do runs fn once for all concurrent callers with the same key. shared is
+// true when the result came from another caller's call. fn runs detached
+// from the leader's cancellation, bounded by budget (defaultFlightBudget
+// if zero), so a leader that gives up does not fail the callers that joined
+// it. Every caller, the leader included, gets ctx.Err() if its own ctx ends
+// first.
+func (g *flightGroup[T]) do(ctx context.Context, key string, budget time.Duration, fn func(context.Context) (T, error)) (val T, shared bool, err error) {
+	chain, _ := ctx.Value(flightChainKey{}).(*flightChain)
+	if chain.has(key) {
+		val, err = fn(ctx)
+		return val, false, err
+	}
+	walker := &flightWalker{}
+	if chain != nil {
+		walker = chain.walker
+	}
+
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		if !walker.startWait(c.leader) {
+			// Waiting would deadlock two chains; fetch directly.
+			g.cycles.Add(1)
+			val, err = fn(ctx)
+			return val, false, err
+		}
+		defer walker.stopWait(c.leader)
+		g.coalesced.Add(1)
+		select {
+		case <-c.done:
+			return c.val, true, c.err
+		case <-ctx.Done():
+			return val, true, ctx.Err()
+		}
+	}
+	c := &flightCall[T]{done: make(chan struct{}), leader: walker}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	if budget <= 0 {
+		budget = defaultFlightBudget
+	}
+	go func() {
+		fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), budget)
+		defer cancel()
+		v, err := fn(context.WithValue(fctx, flightChainKey{}, &flightChain{key: key, parent: chain, walker: walker}))
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.val, c.err = v, err
+		close(c.done)
+	}()
+	select {
+	case <-c.done:
+		return c.val, false, c.err
+	case <-ctx.Done():
+		return val, false, ctx.Err()
+	}
+}
+
+func flightKey(qname string, qtype uint16, do, cd, pr bool) string {
+	return fmt.Sprintf("%s/%s/do=%t/cd=%t/pr=%t", qname, dns.TypeToString[qtype], do, cd, pr)
+}
+
+// iterativeResult carries the return values of IterativeDNSQuery through a
+// flightGroup.
+type iterativeResult struct {
+	rrset     *core.RRset
+	rcode     int
+	context   cache.CacheContext
+	transport core.Transport
+}
+
+// sharedIterativeDNSQuery is IterativeDNSQuery with coalescing of
+// concurrent identical walks. Traced validations and NTA probes validate
+// differently from everyone else, so they are never shared.
+func (imr *Imr) sharedIterativeDNSQuery(ctx context.Context, qname string, qtype uint16, servers map[string]*cache.AuthServer,
+	force, do, cd, requireEncrypted bool) (*core.RRset, int, cache.CacheContext, core.Transport, error) {
+	if cache.TracingValidation(ctx) || cache.IgnoringNTAs(ctx) {
+		return imr.IterativeDNSQuery(ctx, qname, qtype, servers, force, requireEncrypted)
+	}
+	key := flightKey(qname, qtype, do, cd, requireEncrypted)
+	if force {
+		key += "/force"
+	}
+	res, _, err := imr.iterFlights.do(ctx, key, imr.Tuning.QueryBudget, func(ctx context.Context) (iterativeResult, error) {
+		rrset, rcode, cctx, transport, err := imr.IterativeDNSQuery(ctx, qname, qtype, servers, force, requireEncrypted)
+		return iterativeResult{rrset: rrset, rcode: rcode, context: cctx, transport: transport}, err
+	})
+	return res.rrset, res.rcode, res.context, res.transport, err
+}
+
+// maybePrefetch starts a background refresh of a positive cache entry when
+// a hit arrives in the last part of its TTL. At most one refresh per
+// name and type runs at a time.
+func (imr *Imr) maybePrefetch(qname string, qtype uint16, crrset *cache.CachedRRset) {
+	p := imr.Tuning.Prefetch
+	if p.Disable || crrset == nil || crrset.Context != cache.ContextAnswer || crrset.Ttl == 0 {
+		return
+	}
+	ttl := time.Duration(crrset.Ttl) * time.Second
+	if ttl < p.MinTTL {
+		return
+	}
+	remaining := time.Until(crrset.Expiration)
+	if remaining <= 0 || remaining > ttl*time.Duration(p.Threshold)/100 {
+		return
+	}
+	key := flightKey(qname, qtype, true, false, false)
+	if _, busy := imr.prefetching.LoadOrStore(key, struct{}{}); busy {
+		return
+	}
+	imr.prefetched.Add(1)
+	go func() {
+		defer imr.prefetching.Delete(key)
+		_, servers, err := imr.Cache.FindClosestKnownZone(qname)
+		if err == nil && len(servers) == 0 {
+			err = fmt.Errorf("no servers for %s", qname)
+		}
+		if err == nil {
+			_, _, _, _, err = imr.sharedIterativeDNSQuery(context.Background(), qname, qtype, servers, true, true, false, false)
+		}
+		if err != nil {
+			imr.prefetchFailed.Add(1)
+			lgImr.Debug("prefetch failed", "qname", qname, "qtype", dns.TypeToString[qtype], "err", err)
+			return
+		}
+		lgImr.Debug("prefetched", "qname", qname, "qtype", dns.TypeToString[qtype], "remaining", remaining.Truncate(time.Second))
+	}()
+}
+
+// ImrCacheStats is the response to "imr-cache-stats": the RRset cache
+// usage plus the coalescing and prefetch counters.
+type ImrCacheStats struct {
+	cache.CacheStats
+	Coalesced      uint64 `json:"coalesced"`
+	FlightCycles   uint64 `json:"flight_cycles"` // waits avoided because they would deadlock
+	Prefetched     uint64 `json:"prefetched"`
+	PrefetchFailed uint64 `json:"prefetch_failed"`
+}
+
+// CacheStats returns the current ImrCacheStats.
+func (imr *Imr) CacheStats() ImrCacheStats {
+	return ImrCacheStats{
+		CacheStats:     imr.Cache.RRsets.Stats(),
+		Coalesced:      imr.queryFlights.coalesced.Load() + imr.iterFlights.coalesced.Load(),
+		FlightCycles:   imr.queryFlights.cycles.Load() + imr.iterFlights.cycles.Load(),
+		Prefetched:     imr.prefetched.Load(),
+		PrefetchFailed: imr.prefetchFailed.Load(),
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	"github.com/miekg/dns"
+)
+
+func TestFlightGroupCoalesces(t *testing.T) {
+	var g flightGroup[int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	const n = 20
+	var wg sync.WaitGroup
+	results := make([]int, n)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _, _ = g.do(context.Background(), "k", 0, func(context.Context) (int, error) {
+			close(started)
+			calls.Add(1)
+			<-release
+			return 42, nil
+		})
+	}()
+	<-started
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, _ = g.do(context.Background(), "k", 0, func(context.Context) (int, error) {
+				calls.Add(1)
+				return -1, nil
+			})
+		}(i)
+	}
+	for g.coalesced.Load() < n-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("fn ran %d times, want 1", calls.Load())
+	}
+	for i, r := range results {
+		if r != 42 {
+			t.Errorf("caller %d got %d, want the leader's 42", i, r)
+		}
+	}
+	// Once finished, the key is free again.
+	if v, shared, _ := g.do(context.Background(), "k", 0, func(context.Context) (int, error) { return 7, nil }); v != 7 || shared {
+		t.Errorf("after completion: got %d (shared %v), want a fresh call", v, shared)
+	}
+}
+
+// A resolution that needs its own key again runs the nested call itself
+// instead of waiting for itself.
+func TestFlightGroupReentrant(t *testing.T) {
+	var g flightGroup[int]
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	v, _, err := g.do(ctx, "k", 0, func(ctx context.Context) (int, error) {
+		inner, _, err := g.do(ctx, "k", 0, func(context.Context) (int, error) { return 1, nil })
+		return inner + 1, err
+	})
+	if err != nil || v != 2 {
+		t.Fatalf("got %d, %v; want 2, nil", v, err)
+	}
+}
+
+// A waiter whose own context ends stops waiting.
+func TestFlightGroupWaiterContext(t *testing.T) {
+	var g flightGroup[int]
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go g.do(context.Background(), "k", 0, func(context.Context) (int, error) {
+		close(started)
+		<-release
+		return 1, nil
+	})
+	<-started
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, shared, err := g.do(ctx, "k", 0, func(context.Context) (int, error) { return 2, nil }); !shared || err != context.DeadlineExceeded {
+		t.Errorf("got shared %v, err %v; want a shared call ending with the waiter's deadline", shared, err)
+	}
+}
+
+// The leader giving up does not fail the callers that joined its call.
+func TestFlightGroupLeaderCancel(t *testing.T) {
+	var g flightGroup[int]
+	release := make(chan struct{})
+	started := make(chan struct{})
+	lctx, lcancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error)
+	go func() {
+		_, _, err := g.do(lctx, "k", 0, func(ctx context.Context) (int, error) {
+			close(started)
+			select {
+			case <-release:
+				return 42, nil
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		})
+		leaderErr <- err
+	}()
+	<-started
+	follower := make(chan int)
+	go func() {
+		v, _, _ := g.do(context.Background(), "k", 0, func(context.Context) (int, error) { return -1, nil })
+		follower <- v
+	}()
+	for g.coalesced.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	lcancel()
+	if err := <-leaderErr; err != context.Canceled {
+		t.Errorf("leader got %v, want its own cancellation", err)
+	}
+	close(release)
+	if v := <-follower; v != 42 {
+		t.Errorf("follower got %d, want the shared result 42", v)
+	}
+}
+
+// Two chains that each need the key the other leads do not wait for each
+// other until the budget runs out.
+func TestFlightGroupCrossKeyCycle(t *testing.T) {
+	var g flightGroup[int]
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var both sync.WaitGroup
+	both.Add(2)
+	lead := func(key, other string) (int, error) {
+		v, _, err := g.do(ctx, key, 0, func(ctx context.Context) (int, error) {
+			both.Done()
+			both.Wait()
+			inner, _, err := g.do(ctx, other, 0, func(context.Context) (int, error) { return 1, nil })
+			return inner + 1, err
+		})
+		return v, err
+	}
+	errs := make(chan error, 2)
+	for _, k := range [][2]string{{"a", "b"}, {"b", "a"}} {
+		go func(key, other string) {
+			v, err := lead(key, other)
+			if err == nil && v < 2 {
+				err = fmt.Errorf("%s: got %d", key, v)
+			}
+			errs <- err
+		}(k[0], k[1])
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("cycle not broken: %v", err)
+		}
+	}
+	if g.cycles.Load() == 0 {
+		t.Error("no cycle detected")
+	}
+}
+
+func TestMaybePrefetchThreshold(t *testing.T) {
+	imr := &Imr{Cache: cache.NewRRsetCache(log.Default(), false, false)}
+	LoadImrTuningDefaults(&imr.Tuning)
+
+	entry := func(ttl uint32, left time.Duration, ctx cache.CacheContext) *cache.CachedRRset {
+		return &cache.CachedRRset{Name: "www.example.", RRtype: dns.TypeA, Context: ctx, Ttl: ttl, Expiration: time.Now().Add(left)}
+	}
+	imr.maybePrefetch("www.example.", dns.TypeA, entry(300, 200*time.Second, cache.ContextAnswer))
+	imr.maybePrefetch("www.example.", dns.TypeA, entry(5, 100*time.Millisecond, cache.ContextAnswer))
+	imr.maybePrefetch("www.example.", dns.TypeA, entry(300, 10*time.Second, cache.ContextNXDOMAIN))
+	if n := imr.prefetched.Load(); n != 0 {
+		t.Fatalf("prefetched %d entries outside the threshold, below min TTL or negative", n)
+	}
+
+	imr.maybePrefetch("www.example.", dns.TypeA, entry(300, 10*time.Second, cache.ContextAnswer))
+	if n := imr.prefetched.Load(); n != 1 {
+		t.Fatalf("prefetched %d, want 1 for a hit in the last 10%% of the TTL", n)
+	}
+	// The refresh fails (the cache knows no servers) and is counted.
+	deadline := time.Now().Add(2 * time.Second)
+	for imr.prefetchFailed.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if imr.prefetchFailed.Load() != 1 {
+		t.Errorf("prefetch failures = %d, want 1", imr.prefetchFailed.Load())
+	}
+}
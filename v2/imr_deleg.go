@@ -0,0 +1,264 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// DELEG-based delegation following (imrengine.options: use-deleg). The IMR
+// sets the DE bit on its queries; a parent that understands it includes the
+// signed DELEG RRset in referrals, and when one is present the IMR prefers it
+// over the NS RRset: server names, addresses, ALPN and port come from the
+// DELEG parameters, and AliasMode records are followed to the DELEG RRset at
+// their target. Below a signed parent only a secure DELEG RRset is used, and
+// a port parameter applies to that delegation only. The NS RRset and glue
+// are still processed as before and are used whenever DELEG yields no
+// reachable server.
+
+const (
+	maxDelegAliasDepth  = 3 // AliasMode hops followed from one referral
+	maxDelegAddrLookups = 2 // targets without hints resolved per referral
+)
+
+// delegAlpnToTransport maps the ALPN ids used in DELEG records to the
+// transport names AuthServer uses. DNS over HTTPS is announced as h2 or h3.
+var delegAlpnToTransport = map[string]string{
+	"do53": "do53",
+	"dot":  "dot",
+	"doq":  "doq",
+	"doh":  "doh",
+	"h2":   "doh",
+	"h3":   "doh",
+}
+
+// extractDeleg returns the DELEG RRset for zonename, with its RRSIGs, from
+// the authority section of a referral, or nil if there is none.
+func extractDeleg(r *dns.Msg, zonename string) *core.RRset {
+	rrset := &core.RRset{Name: zonename, Class: dns.ClassINET, RRtype: core.TypeDELEG}
+	for _, rr := range r.Ns {
+		if !strings.EqualFold(rr.Header().Name, zonename) {
+			continue
+		}
+		switch rr.Header().Rrtype {
+		case core.TypeDELEG:
+			rrset.RRs = append(rrset.RRs, rr)
+		case dns.TypeRRSIG:
+			if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == core.TypeDELEG {
+				rrset.RRSIGs = append(rrset.RRSIGs, rr)
+			}
+		}
+	}
+	if len(rrset.RRs) == 0 {
+		return nil
+	}
+	return rrset
+}
+
+// referralFromSignedParent reports whether the parent that sent a referral
+// to zonename is signed: the referral carries signed DS or NSEC(3) records,
+// or the closest enclosing zone in the cache validated as secure.
+func (imr *Imr) referralFromSignedParent(r *dns.Msg, zonename string) bool {
+	for _, rr := range r.Ns {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			switch sig.TypeCovered {
+			case dns.TypeDS, dns.TypeNSEC, dns.TypeNSEC3:
+				return true
+			}
+		}
+	}
+	for name := zonename; name != "."; {
+		if i := strings.IndexByte(name, '.'); i >= 0 && i < len(name)-1 {
+			name = name[i+1:]
+		} else {
+			name = "."
+		}
+		if z, ok := imr.Cache.ZoneMap.Get(name); ok {
+			return z.GetState() == cache.ValidationStateSecure
+		}
+	}
+	return false
+}
+
+// handleDelegReferral validates and caches the DELEG RRset of a referral and
+// turns it into a server map for zonename. It returns nil, and the caller
+// follows the NS RRset, when the DELEG RRset is bogus, is not secure although
+// the parent is signed, or yields no server with an address.
+func (imr *Imr) handleDelegReferral(ctx context.Context, zonename string, rrset *core.RRset, signedParent bool, rcode int, transport core.Transport) map[string]*cache.AuthServer {
+	vstate := cache.ValidationStateNone
+	if len(rrset.RRSIGs) > 0 {
+		var err error
+		vstate, err = imr.Cache.ValidateRRsetWithParentZone(ctx, rrset, imr.IterativeDNSQueryFetcher(), imr.ParentZone)
+		if err != nil {
+			lgDns.Warn("DELEG RRset not validated, following NS instead", "zone", zonename, "err", err)
+			return nil
+		}
+	}
+	if vstate == cache.ValidationStateBogus {
+		lgDns.Warn("DELEG RRset is bogus, following NS instead", "zone", zonename)
+		return nil
+	}
+	// A signed parent signs its DELEG RRsets. Anything less than secure
+	// could have been injected on the path and must not redirect the query.
+	if signedParent && vstate != cache.ValidationStateSecure {
+		lgDns.Warn("DELEG RRset from a signed parent is not secure, following NS instead", "zone", zonename,
+			"state", cache.ValidationStateToString[vstate])
+		return nil
+	}
+	if vstate == cache.ValidationStateNone {
+		vstate = cache.ValidationStateIndeterminate
+	}
+	imr.Cache.Set(zonename, core.TypeDELEG, &cache.CachedRRset{
+		Name:       zonename,
+		RRtype:     core.TypeDELEG,
+		Rcode:      uint8(rcode),
+		RRset:      rrset,
+		Context:    cache.ContextReferral,
+		State:      vstate,
+		Expiration: time.Now().Add(cache.GetMinTTL(rrset.RRs)),
+		Transport:  transport,
+	})
+
+	serverMap := map[string]*cache.AuthServer{}
+	imr.collectDelegServers(ctx, rrset.RRs, serverMap, 0)
+
+	// Targets without hints: use cached addresses, and resolve a few of the
+	// rest, as the referral carries no glue for them.
+	lookups := 0
+	for name, server := range serverMap {
+		if len(server.Addrs) > 0 {
+			continue
+		}
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			if crrset := imr.Cache.Get(name, qtype); crrset != nil && crrset.RRset != nil {
+				addDelegAddrs(server, crrset.RRset.RRs)
+			}
+		}
+		if len(server.Addrs) > 0 || lookups >= maxDelegAddrLookups {
+			continue
+		}
+		lookups++
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			if addrs, err := imr.DefaultRRsetFetcher(ctx, name, qtype); err == nil {
+				addDelegAddrs(server, addrs.RRs)
+			}
+		}
+	}
+	for name, server := range serverMap {
+		if len(server.Addrs) == 0 {
+			delete(serverMap, name)
+		}
+	}
+	if len(serverMap) == 0 {
+		lgDns.Debug("DELEG RRset yielded no usable servers, following NS instead", "zone", zonename)
+		return nil
+	}
+
+	// The DELEG servers replace the NS-derived ones for the zone.
+	imr.Cache.ServerMap.Remove(zonename)
+	if err := imr.Cache.AddServers(zonename, serverMap); err != nil {
+		lgDns.Error("failed to add DELEG servers", "zone", zonename, "err", err)
+	}
+	if sm, ok := imr.Cache.ServerMap.Get(zonename); ok {
+		serverMap = sm
+	}
+	lgDns.Debug("following DELEG delegation", "zone", zonename, "servers", len(serverMap),
+		"state", cache.ValidationStateToString[vstate])
+	return serverMap
+}
+
+// collectDelegServers adds an AuthServer for each ServiceMode record in rrs
+// to serverMap, following AliasMode records to the DELEG RRset at their
+// target.
+func (imr *Imr) collectDelegServers(ctx context.Context, rrs []dns.RR, serverMap map[string]*cache.AuthServer, depth int) {
+	for _, rr := range rrs {
+		prr, ok := rr.(*dns.PrivateRR)
+		if !ok {
+			continue
+		}
+		deleg, ok := prr.Data.(*core.DELEG)
+		if !ok || deleg.Target == "" || deleg.Target == "." {
+			continue
+		}
+		target := dns.CanonicalName(deleg.Target)
+		if deleg.Priority == 0 {
+			if depth >= maxDelegAliasDepth {
+				lgDns.Warn("DELEG alias chain too long, ignoring", "target", target)
+				continue
+			}
+			aliased, err := imr.DefaultRRsetFetcher(ctx, target, core.TypeDELEG)
+			if err != nil {
+				lgDns.Debug("DELEG alias target not resolved", "target", target, "err", err)
+				continue
+			}
+			imr.collectDelegServers(ctx, aliased.RRs, serverMap, depth+1)
+			continue
+		}
+		if _, exists := serverMap[target]; exists {
+			continue
+		}
+		serverMap[target] = delegAuthServer(target, deleg)
+	}
+}
+
+// delegAuthServer builds an AuthServer from the parameters of a ServiceMode
+// DELEG record.
+func delegAuthServer(target string, deleg *core.DELEG) *cache.AuthServer {
+	server := cache.NewAuthServer(target)
+	server.Src = "deleg"
+	var alpns []string
+	defaultAlpn := true
+	for _, kv := range deleg.Value {
+		switch v := kv.(type) {
+		case *core.DELEGAlpn:
+			for _, id := range v.Alpn {
+				if t, ok := delegAlpnToTransport[strings.ToLower(id)]; ok && !slices.Contains(alpns, t) {
+					alpns = append(alpns, t)
+				}
+			}
+		case *core.DELEGNoDefaultAlpn:
+			defaultAlpn = false
+		case *core.DELEGPort:
+			server.Port = v.Port
+		case *core.DELEGIPv4Hint:
+			for _, ip := range v.Hint {
+				server.Addrs = append(server.Addrs, ip.String())
+			}
+		case *core.DELEGIPv6Hint:
+			for _, ip := range v.Hint {
+				server.Addrs = append(server.Addrs, ip.String())
+			}
+		}
+	}
+	if defaultAlpn && !slices.Contains(alpns, "do53") {
+		alpns = append(alpns, "do53")
+	}
+	if len(alpns) > 0 {
+		applyAlpnSignalToServer(server, strings.Join(alpns, ","))
+		if slices.ContainsFunc(server.Transports, core.IsEncryptedTransport) {
+			server.ConnMode = cache.ConnModeOpportunistic
+		}
+	}
+	return server
+}
+
+// addDelegAddrs adds the addresses in a set of A and AAAA records to server.
+func addDelegAddrs(server *cache.AuthServer, rrs []dns.RR) {
+	for _, rr := range rrs {
+		switch a := rr.(type) {
+		case *dns.A:
+			server.AddAddr(a.A.String())
+		case *dns.AAAA:
+			server.AddAddr(a.AAAA.String())
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package tdns
+
+import (
+	"io"
+	"log"
+	"slices"
+	"testing"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+func TestDelegServersFromReferral(t *testing.T) {
+	r := new(dns.Msg)
+	r.Ns = []dns.RR{
+		mustRR(t, "example. 3600 IN NS ns1.example."),
+		mustRR(t, `example. 3600 IN DELEG 1 ns1.example. alpn="doq,h2" port="8853" ipv4hint="192.0.2.1" ipv6hint="2001:db8::1"`),
+		mustRR(t, `example. 3600 IN DELEG 2 ns2.example. alpn="dot" no-default-alpn ipv4hint="192.0.2.2"`),
+		mustRR(t, "example. 3600 IN RRSIG DELEG 15 1 3600 20260805122917 20260722122719 16089 . AA=="),
+		mustRR(t, "example. 3600 IN RRSIG NS 15 1 3600 20260805122917 20260722122719 16089 . AA=="),
+	}
+	if extractDeleg(r, "other.") != nil {
+		t.Error("DELEG RRset found for the wrong owner")
+	}
+	rrset := extractDeleg(r, "example.")
+	if rrset == nil || len(rrset.RRs) != 2 || len(rrset.RRSIGs) != 1 {
+		t.Fatalf("extractDeleg = %+v", rrset)
+	}
+
+	imr := &Imr{}
+	sm := map[string]*cache.AuthServer{}
+	imr.collectDelegServers(t.Context(), rrset.RRs, sm, 0)
+	ns1, ns2 := sm["ns1.example."], sm["ns2.example."]
+	if ns1 == nil || ns2 == nil || len(sm) != 2 {
+		t.Fatalf("servers %v", sm)
+	}
+	if ns1.Port != 8853 || len(ns1.Addrs) != 2 || ns1.Src != "deleg" {
+		t.Errorf("ns1 = %+v", ns1)
+	}
+	if !slices.Equal(ns1.Transports, []core.Transport{core.TransportDoQ, core.TransportDoH, core.TransportDo53}) {
+		t.Errorf("ns1 transports %v, want doq, doh and the default do53", ns1.Transports)
+	}
+	if !slices.Equal(ns2.Transports, []core.Transport{core.TransportDoT}) || ns2.ConnMode != cache.ConnModeOpportunistic {
+		t.Errorf("ns2 transports %v (%v), want only dot", ns2.Transports, ns2.ConnMode)
+	}
+}
+
+func TestDelegPortStaysWithDelegation(t *testing.T) {
+	rrcache := cache.NewRRsetCache(log.New(io.Discard, "", 0), false, false)
+	plain := cache.NewAuthServer("ns1.example.")
+	plain.Addrs = []string{"192.0.2.1"}
+	if err := rrcache.AddServers("other.", map[string]*cache.AuthServer{"ns1.example.": plain}); err != nil {
+		t.Fatal(err)
+	}
+	deleg := cache.NewAuthServer("ns1.example.")
+	deleg.Addrs = []string{"192.0.2.1"}
+	deleg.Port = 8853
+	if err := rrcache.AddServers("example.", map[string]*cache.AuthServer{"ns1.example.": deleg}); err != nil {
+		t.Fatal(err)
+	}
+	shared, _ := rrcache.AuthServerMap.Get("ns1.example.")
+	if shared.GetPort() != 0 {
+		t.Errorf("shared server got port %d", shared.GetPort())
+	}
+	if sm, _ := rrcache.ServerMap.Get("other."); sm["ns1.example."].GetPort() != 0 {
+		t.Error("port leaked into another zone served by the same name")
+	}
+	if sm, _ := rrcache.ServerMap.Get("example."); sm["ns1.example."].GetPort() != 8853 {
+		t.Error("port lost for the DELEG delegation")
+	}
+}
+
+func TestDelegBelowSignedParent(t *testing.T) {
+	imr := &Imr{Cache: cache.NewRRsetCache(log.New(io.Discard, "", 0), false, false)}
+	r := new(dns.Msg)
+	r.Ns = []dns.RR{
+		mustRR(t, "example. 3600 IN NS ns1.example."),
+		mustRR(t, `example. 3600 IN DELEG 1 ns1.example. ipv4hint="192.0.2.1"`),
+	}
+	if imr.referralFromSignedParent(r, "example.") {
+		t.Error("unsigned referral from an unknown parent taken as signed")
+	}
+	imr.Cache.ZoneMap.Set(".", &cache.Zone{ZoneName: ".", State: cache.ValidationStateSecure})
+	if !imr.referralFromSignedParent(r, "example.") {
+		t.Error("referral below a secure parent not taken as signed")
+	}
+	rrset := extractDeleg(r, "example.")
+	if sm := imr.handleDelegReferral(t.Context(), "example.", rrset, true, dns.RcodeSuccess, core.TransportDo53); sm != nil {
+		t.Errorf("unsigned DELEG below a signed parent used: %v", sm)
+	}
+	if sm := imr.handleDelegReferral(t.Context(), "example.", rrset, false, dns.RcodeSuccess, core.TransportDo53); sm["ns1.example."] == nil {
+		t.Errorf("unsigned DELEG below an unsigned parent not used: %v", sm)
+	}
+}
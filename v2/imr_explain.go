@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	"github.com/miekg/dns"
+)
+
+// "imr explain": re-run resolution and validation of one name with a
+// validation trace attached, and report the chain of trust the validator
+// walked — zone cuts, DS digests, DNSKEYs, RRSIG validity windows and
+// denial proofs — with the step that broke it. The trace comes from the
+// validator itself (cache.ValidationTrace), so it explains exactly what the
+// IMR decided, from its own cache and servers.
+
+const explainTimeout = 30 * time.Second
+
+// ExplainReport is the result of Imr.Explain.
+type ExplainReport struct {
+	Qname   string
+	Qtype   string
+	Rcode   string
+	State   string            // validation state of the answer
+	Answer  []string          // the answer RRs, if any
+	Steps   []cache.TraceStep // in the order the validator took them
+	Failure int               // index in Steps of the failing link, -1 if none
+}
+
+// Explain resolves qname/qtype again, bypassing the cached answer and the
+// cached validation states, and returns the validation trace.
+func (imr *Imr) Explain(ctx context.Context, qname string, qtype uint16) (*ExplainReport, error) {
+	qname = dns.Fqdn(qname)
+	_, serverMap, err := imr.Cache.FindClosestKnownZone(qname)
+	if err != nil || len(serverMap) == 0 {
+		serverMap, _ = imr.Cache.ServerMap.Get(".")
+	}
+	if len(serverMap) == 0 {
+		return nil, fmt.Errorf("no known servers for %s", qname)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, explainTimeout)
+	defer cancel()
+	trace := cache.NewValidationTrace()
+	rrset, rcode, _, _, err := imr.IterativeDNSQuery(cache.WithValidationTrace(ctx, trace), qname, qtype, serverMap, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s %s: %v", qname, dns.TypeToString[qtype], err)
+	}
+
+	report := &ExplainReport{
+		Qname:   qname,
+		Qtype:   dns.TypeToString[qtype],
+		Rcode:   dns.RcodeToString[rcode],
+		Steps:   trace.Steps(),
+		Failure: trace.FirstFailure(),
+	}
+	if rrset != nil {
+		for _, rr := range rrset.RRs {
+			report.Answer = append(report.Answer, rr.String())
+		}
+	}
+	report.State = cache.ValidationStateToString[cache.ValidationStateNone]
+	if crrset := imr.Cache.Get(qname, qtype); crrset != nil && crrset.State > cache.ValidationStateNone {
+		report.State = cache.ValidationStateToString[crrset.State]
+	}
+	return report, nil
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// Forward zones (conditional forwarding).
+//
+// A query at or below a forward zone is sent with RD=1 to the zone's
+// forwarders (recursive resolvers) instead of being iterated from the root.
+// Queries go out with DO=1 and CD=1 so the forwarder hands over signatures
+// and does not filter bogus data; the answer then goes through the same
+// handleAnswer / handleNegative path as an authoritative response, which
+// validates it against our own trust anchors and caches it. Forwarders are
+// private AuthServer instances, so the per-(address, transport) backoff and
+// RTT tracking drive their selection exactly as for authoritative servers.
+//
+// A "forward-first" zone falls back to normal iteration when no forwarder
+// produces an answer; otherwise ("forward-only") the query fails.
+
+// ForwardZone is a configured forward zone.
+type ForwardZone struct {
+	Zone    string
+	First   bool
+	Servers map[string]*cache.AuthServer
+}
+
+// buildForwardZones converts the configuration into ForwardZones, most
+// specific zone first.
+func buildForwardZones(confs []ImrForwardConf) ([]*ForwardZone, error) {
+	var fzs []*ForwardZone
+	seen := map[string]bool{}
+	for _, fc := range confs {
+		zone := dns.Fqdn(strings.ToLower(fc.Zone))
+		if _, ok := dns.IsDomainName(zone); !ok || fc.Zone == "" {
+			return nil, fmt.Errorf("forward zone %q: invalid zone name", fc.Zone)
+		}
+		if seen[zone] {
+			return nil, fmt.Errorf("forward zone %s: configured more than once", zone)
+		}
+		seen[zone] = true
+		if len(fc.Forwarders) == 0 {
+			return nil, fmt.Errorf("forward zone %s: no forwarders", zone)
+		}
+		fz := &ForwardZone{Zone: zone, First: fc.First, Servers: map[string]*cache.AuthServer{}}
+		for i, f := range fc.Forwarders {
+			if len(f.Addrs) == 0 {
+				return nil, fmt.Errorf("forward zone %s: forwarder %d has no addresses", zone, i)
+			}
+			name := f.Name
+			if name == "" {
+				name = fmt.Sprintf("forwarder%d.%s", i+1, zone)
+			}
+			name = dns.Fqdn(name)
+			transports := f.Transports
+			if len(transports) == 0 {
+				transports = []string{"do53"}
+			}
+			var ts []core.Transport
+			weights := map[core.Transport]uint8{}
+			for _, s := range transports {
+				t, err := core.StringToTransport(s)
+				if err != nil || t == core.TransportDo53TCP {
+					return nil, fmt.Errorf("forward zone %s: forwarder %s: unsupported transport %q", zone, name, s)
+				}
+				ts = append(ts, t)
+				weights[t] = 100
+			}
+			as := cache.NewAuthServer(name)
+			if as == nil {
+				return nil, fmt.Errorf("forward zone %s: invalid forwarder name %q", zone, name)
+			}
+			as.SetAddrs(f.Addrs)
+			as.SetAlpn(transports)
+			as.SetTransports(ts)
+			as.SetTransportWeights(weights)
+			as.ForceSetSrc("forward")
+			fz.Servers[name] = as
+		}
+		fzs = append(fzs, fz)
+	}
+	sort.SliceStable(fzs, func(i, j int) bool {
+		return dns.CountLabel(fzs[i].Zone) > dns.CountLabel(fzs[j].Zone)
+	})
+	return fzs, nil
+}
+
+// forwardZoneFor returns the most specific forward zone containing qname,
+// or nil.
+func (imr *Imr) forwardZoneFor(qname string) *ForwardZone {
+	for _, fz := range imr.forwards {
+		if dns.IsSubDomain(fz.Zone, qname) {
+			return fz
+		}
+	}
+	return nil
+}
+
+// ForwardZones returns the configured forward zones.
+func (imr *Imr) ForwardZones() []*ForwardZone {
+	return imr.forwards
+}
+
+// forwardQuery sends qname/qtype to the forwarders of fz, in backoff and
+// RTT order, until one gives a usable answer.
+func (imr *Imr) forwardQuery(ctx context.Context, fz *ForwardZone, qname string, qtype uint16, force, requireEncrypted bool) (*core.RRset, int, cache.CacheContext, core.Transport, error) {
+	m, err := buildQuery(qname, qtype, false, false)
+	if err != nil {
+		return nil, dns.RcodeServerFailure, cache.ContextFailure, core.TransportDo53, err
+	}
+	m.RecursionDesired = true
+	m.CheckingDisabled = true
+
+	_, _, tuples := imr.prioritizeServers(qname, fz.Servers, requireEncrypted)
+	if len(tuples) == 0 {
+		return nil, dns.RcodeServerFailure, cache.ContextFailure, core.TransportDo53,
+			fmt.Errorf("forward zone %s: no usable forwarder for %s %s", fz.Zone, qname, dns.TypeToString[qtype])
+	}
+	var lastErr error
+	for _, tuple := range tuples {
+		r, _, wireTransport, err := imr.tryServer(ctx, tuple.Server, tuple.Addr, tuple.Transport, m, qname, qtype, false)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if r == nil {
+			continue
+		}
+		for _, hook := range getImrResponseHooks() {
+			hook(ctx, qname, qtype, tuple.Server.Name, tuple.Addr, wireTransport, r, r.Rcode)
+		}
+		switch r.Rcode {
+		case dns.RcodeSuccess, dns.RcodeNameError:
+		default:
+			tuple.Server.RecordAddressFailureForRcode(tuple.Addr, wireTransport, uint8(r.Rcode))
+			lastErr = fmt.Errorf("%s from forwarder %s@%s", dns.RcodeToString[r.Rcode], tuple.NSName, tuple.Addr)
+			continue
+		}
+		if len(r.Answer) != 0 {
+			rrset, rcode, cctx, transport, err, done := imr.handleAnswer(ctx, qname, qtype, r, force, wireTransport, requireEncrypted)
+			if err != nil || done {
+				return rrset, rcode, cctx, transport, err
+			}
+			lastErr = fmt.Errorf("unusable answer from forwarder %s@%s", tuple.NSName, tuple.Addr)
+			continue
+		}
+		if negCtx, rcode, handled := imr.handleNegative(ctx, qname, qtype, r, wireTransport); handled {
+			return nil, rcode, negCtx, wireTransport, nil
+		}
+		lastErr = fmt.Errorf("negative response without SOA from forwarder %s@%s", tuple.NSName, tuple.Addr)
+	}
+	return nil, dns.RcodeServerFailure, cache.ContextFailure, core.TransportDo53,
+		fmt.Errorf("forward zone %s: no forwarder answered %s %s: %v", fz.Zone, qname, dns.TypeToString[qtype], lastErr)
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// handlerDNSClient is a DNSClienter that answers from a function and keeps
+// the queries it saw.
+type handlerDNSClient struct {
+	mu      sync.Mutex
+	t       core.Transport
+	handler func(m *dns.Msg, server string) (*dns.Msg, error)
+	seen    []*dns.Msg
+	servers []string
+}
+
+func (h *handlerDNSClient) Exchange(m *dns.Msg, server string, debug bool) (*dns.Msg, time.Duration, error) {
+	h.mu.Lock()
+	h.seen = append(h.seen, m.Copy())
+	h.servers = append(h.servers, server)
+	h.mu.Unlock()
+	r, err := h.handler(m, server)
+	return r, time.Millisecond, err
+}
+
+func (h *handlerDNSClient) ExchangeWithResult(m *dns.Msg, server string, debug bool) (*dns.Msg, time.Duration, core.ExchangeResult, error) {
+	r, rtt, err := h.Exchange(m, server, debug)
+	return r, rtt, core.ExchangeResult{WireTransport: h.t}, err
+}
+
+func (h *handlerDNSClient) TransportKind() core.Transport { return h.t }
+
+func TestBuildForwardZones(t *testing.T) {
+	fzs, err := buildForwardZones([]ImrForwardConf{
+		{Zone: "corp.example", Forwarders: []ImrForwarderConf{{Addrs: []string{"192.0.2.53"}}}},
+		{Zone: "lab.corp.example.", First: true, Forwarders: []ImrForwarderConf{{Name: "dot1", Addrs: []string{"192.0.2.54"}, Transports: []string{"dot", "do53"}}}},
+	})
+	if err != nil {
+		t.Fatalf("buildForwardZones: %v", err)
+	}
+	if len(fzs) != 2 || fzs[0].Zone != "lab.corp.example." || fzs[1].Zone != "corp.example." {
+		t.Fatalf("zones not ordered most specific first: %v, %v", fzs[0].Zone, fzs[1].Zone)
+	}
+	as := fzs[0].Servers["dot1."]
+	if as == nil || len(as.GetTransports()) != 2 || as.GetTransports()[0] != core.TransportDoT {
+		t.Errorf("forwarder transports = %v", as)
+	}
+
+	imr := &Imr{forwards: fzs}
+	for qname, want := range map[string]string{
+		"www.lab.corp.example.": "lab.corp.example.",
+		"www.corp.example.":     "corp.example.",
+		"corp.example.":         "corp.example.",
+		"www.example.":          "",
+	} {
+		got := ""
+		if fz := imr.forwardZoneFor(qname); fz != nil {
+			got = fz.Zone
+		}
+		if got != want {
+			t.Errorf("forwardZoneFor(%s) = %q, want %q", qname, got, want)
+		}
+	}
+
+	bad := [][]ImrForwardConf{
+		{{Zone: "a.example.", Forwarders: nil}},
+		{{Zone: "a.example.", Forwarders: []ImrForwarderConf{{}}}},
+		{{Zone: "a.example.", Forwarders: []ImrForwarderConf{{Addrs: []string{"192.0.2.1"}, Transports: []string{"carrier-pigeon"}}}}},
+		{{Zone: "a.example.", Forwarders: []ImrForwarderConf{{Addrs: []string{"192.0.2.1"}}}}, {Zone: "A.example", Forwarders: []ImrForwarderConf{{Addrs: []string{"192.0.2.1"}}}}},
+	}
+	for i, confs := range bad {
+		if _, err := buildForwardZones(confs); err == nil {
+			t.Errorf("bad config %d accepted", i)
+		}
+	}
+}
+
+func forwardTestImr(t *testing.T, first bool, handler func(m *dns.Msg, server string) (*dns.Msg, error)) (*Imr, *handlerDNSClient) {
+	t.Helper()
+	imr := newTestImr(t)
+	LoadImrTuningDefaults(&imr.Tuning)
+	client := &handlerDNSClient{t: core.TransportDo53, handler: handler}
+	imr.Cache.DNSClient[core.TransportDo53] = client
+	fzs, err := buildForwardZones([]ImrForwardConf{{Zone: "corp.example.", First: first,
+		Forwarders: []ImrForwarderConf{{Addrs: []string{"192.0.2.53", "192.0.2.54"}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imr.forwards = fzs
+	return imr, client
+}
+
+func corpSOA() dns.RR {
+	rr, _ := dns.NewRR("corp.example. 300 IN SOA ns.corp.example. h.corp.example. 1 3600 600 86400 300")
+	return rr
+}
+
+func TestForwardQuery(t *testing.T) {
+	imr, client := forwardTestImr(t, false, func(m *dns.Msg, server string) (*dns.Msg, error) {
+		if server == "192.0.2.53" {
+			return nil, fmt.Errorf("timeout")
+		}
+		r := new(dns.Msg)
+		r.SetReply(m)
+		r.RecursionAvailable = true
+		q := m.Question[0]
+		switch {
+		case q.Name == "host.corp.example." && q.Qtype == dns.TypeA:
+			r.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.IPv4(10, 1, 2, 3)}}
+		case q.Name == "nope.corp.example.":
+			r.Rcode = dns.RcodeNameError
+			r.Ns = []dns.RR{corpSOA()}
+		default:
+			r.Ns = []dns.RR{corpSOA()}
+		}
+		return r, nil
+	})
+
+	ctx := context.Background()
+	rrset, rcode, cctx, _, err := imr.IterativeDNSQuery(ctx, "host.corp.example.", dns.TypeA, map[string]*cache.AuthServer{}, false, false)
+	if err != nil || rrset == nil || len(rrset.RRs) != 1 || cctx != cache.ContextAnswer || rcode != dns.RcodeSuccess {
+		t.Fatalf("forwarded A = %v, rcode %d, ctx %s, err %v", rrset, rcode, cache.CacheContextToString[cctx], err)
+	}
+	if c := imr.Cache.Get("host.corp.example.", dns.TypeA); c == nil || c.Context != cache.ContextAnswer {
+		t.Errorf("forwarded answer not cached: %+v", c)
+	}
+
+	client.mu.Lock()
+	for _, m := range client.seen {
+		if !m.RecursionDesired || !m.CheckingDisabled {
+			t.Errorf("query %s sent without RD/CD: rd=%v cd=%v", m.Question[0].Name, m.RecursionDesired, m.CheckingDisabled)
+		}
+	}
+	client.mu.Unlock()
+
+	// The failing forwarder is now in backoff, so later queries go straight
+	// to the working one.
+	fz := imr.forwards[0]
+	var failing *cache.AuthServer
+	for _, as := range fz.Servers {
+		failing = as
+	}
+	if failing.IsAddrXportAvailable("192.0.2.53", core.TransportDo53) {
+		t.Error("failing forwarder address not backed off")
+	}
+
+	_, rcode, cctx, _, err = imr.IterativeDNSQuery(ctx, "nope.corp.example.", dns.TypeA, map[string]*cache.AuthServer{}, false, false)
+	if err != nil || rcode != dns.RcodeNameError || cctx != cache.ContextNXDOMAIN {
+		t.Errorf("forwarded NXDOMAIN: rcode %d, ctx %s, err %v", rcode, cache.CacheContextToString[cctx], err)
+	}
+}
+
+func TestForwardOnlyFailure(t *testing.T) {
+	imr, _ := forwardTestImr(t, false, func(m *dns.Msg, server string) (*dns.Msg, error) {
+		r := new(dns.Msg)
+		r.SetRcode(m, dns.RcodeServerFailure)
+		return r, nil
+	})
+	_, _, _, _, err := imr.IterativeDNSQuery(context.Background(), "host.corp.example.", dns.TypeA, map[string]*cache.AuthServer{}, false, false)
+	if err == nil {
+		t.Fatal("forward-only zone with failing forwarders returned no error")
+	}
+}
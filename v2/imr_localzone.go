@@ -0,0 +1,422 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	edns0 "github.com/johanix/tdns/v2/edns0"
+	"github.com/miekg/dns"
+)
+
+// Local zones and local data (imrengine.local-zones, imrengine.local-data).
+//
+// Names in a local zone are answered by the resolver itself, from the
+// local data, before the cache is consulted. The zone type decides what
+// happens to names without local data, with the Unbound semantics:
+//
+//	static          NXDOMAIN (NODATA for names that have other data)
+//	transparent     resolved as usual
+//	redirect        answered from the data at the zone apex, for every name
+//	refuse          REFUSED
+//	always-nxdomain NXDOMAIN, even for names with local data
+//
+// redirect, refuse and always-nxdomain zones block names; for clients in a
+// group with blocking turned off (imrengine.access.client-groups) they are
+// transparent.
+//
+// Local data outside every local zone gets a transparent zone of its own,
+// at the owner name. Local answers never have the AD bit set; a zone marked
+// insecure also gets a permanent negative trust anchor, so that the names in
+// it that are resolved are not validated either.
+
+type LocalZoneType uint8
+
+const (
+	LocalZoneStatic LocalZoneType = iota + 1
+	LocalZoneTransparent
+	LocalZoneRedirect
+	LocalZoneRefuse
+	LocalZoneAlwaysNxdomain
+)
+
+var LocalZoneTypeToString = map[LocalZoneType]string{
+	LocalZoneStatic:         "static",
+	LocalZoneTransparent:    "transparent",
+	LocalZoneRedirect:       "redirect",
+	LocalZoneRefuse:         "refuse",
+	LocalZoneAlwaysNxdomain: "always-nxdomain",
+}
+
+var StringToLocalZoneType = map[string]LocalZoneType{
+	"static":          LocalZoneStatic,
+	"transparent":     LocalZoneTransparent,
+	"redirect":        LocalZoneRedirect,
+	"refuse":          LocalZoneRefuse,
+	"always-nxdomain": LocalZoneAlwaysNxdomain,
+}
+
+// blocks reports whether zones of this type block names, which client
+// groups with blocking turned off skip.
+func (t LocalZoneType) blocks() bool {
+	switch t {
+	case LocalZoneRedirect, LocalZoneRefuse, LocalZoneAlwaysNxdomain:
+		return true
+	}
+	return false
+}
+
+const localDataTTL = 3600 // TTL of local-data RRs given without one
+
+// localZoneNTAReason marks the NTAs installed for insecure local zones.
+const localZoneNTAReason = "local-zone"
+
+// LocalZone is a local zone and its data, as listed by the API.
+type LocalZone struct {
+	Zone     string
+	Type     string
+	Insecure bool
+	Implicit bool     // created for local data outside any local zone
+	Data     []string // the local data, in zone file format
+}
+
+type localZone struct {
+	zone     string
+	typ      LocalZoneType
+	insecure bool
+	implicit bool
+	data     map[string]map[uint16][]dns.RR // owner -> rrtype -> RRs
+}
+
+// localZones holds the local zones of an Imr.
+type localZones struct {
+	mu    sync.RWMutex
+	zones map[string]*localZone
+}
+
+func newLocalZones() *localZones {
+	return &localZones{zones: map[string]*localZone{}}
+}
+
+// covering returns the most specific local zone containing name, or nil.
+// Callers hold lz.mu.
+func (lz *localZones) covering(name string) *localZone {
+	for {
+		if z, ok := lz.zones[name]; ok {
+			return z
+		}
+		if name == "." {
+			return nil
+		}
+		off, end := dns.NextLabel(name, 0)
+		if end {
+			name = "."
+		} else {
+			name = name[off:]
+		}
+	}
+}
+
+// AddLocalZone adds a local zone, or changes the type of an existing one
+// (keeping its data).
+func (imr *Imr) AddLocalZone(zone, typ string, insecure bool) (*LocalZone, error) {
+	zone = dns.CanonicalName(zone)
+	if _, ok := dns.IsDomainName(zone); !ok {
+		return nil, fmt.Errorf("invalid local zone name %q", zone)
+	}
+	if typ == "" {
+		typ = "static"
+	}
+	t, ok := StringToLocalZoneType[strings.ToLower(typ)]
+	if !ok {
+		return nil, fmt.Errorf("local zone %s: unknown type %q", zone, typ)
+	}
+	// An insecure local zone installs its own NTA, unless the zone already
+	// has one (nta.domain-insecure or an operator NTA), which is kept.
+	if insecure {
+		if _, err := imr.Cache.NTAs.AddPermanentIfAbsent(zone, localZoneNTAReason, time.Now()); err != nil {
+			return nil, fmt.Errorf("local zone %s: %w", zone, err)
+		}
+	}
+
+	lz := imr.localZones
+	lz.mu.Lock()
+	z, ok := lz.zones[zone]
+	if !ok {
+		z = &localZone{zone: zone, data: map[string]map[uint16][]dns.RR{}}
+		lz.zones[zone] = z
+	}
+	if z.insecure && !insecure {
+		imr.Cache.NTAs.RemoveWithReason(zone, localZoneNTAReason)
+	}
+	z.typ, z.insecure, z.implicit = t, insecure, false
+	// Data from implicit zones below this one now belongs to it.
+	for name, sub := range lz.zones {
+		if sub.implicit && name != zone && dns.IsSubDomain(zone, name) {
+			mergeLocalData(z, sub)
+			delete(lz.zones, name)
+		}
+	}
+	out := z.export()
+	lz.mu.Unlock()
+
+	imr.Cache.FlushDomain(zone, false)
+	lgImr.Info("local zone added", "zone", zone, "type", out.Type, "insecure", insecure)
+	return &out, nil
+}
+
+// RemoveLocalZone removes a local zone and its data.
+func (imr *Imr) RemoveLocalZone(zone string) error {
+	zone = dns.CanonicalName(zone)
+	lz := imr.localZones
+	lz.mu.Lock()
+	z, ok := lz.zones[zone]
+	delete(lz.zones, zone)
+	lz.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no local zone %s", zone)
+	}
+	if z.insecure {
+		imr.Cache.NTAs.RemoveWithReason(zone, localZoneNTAReason)
+	}
+	imr.Cache.FlushDomain(zone, false)
+	lgImr.Info("local zone removed", "zone", zone)
+	return nil
+}
+
+// AddLocalData adds an RR, in zone file format, to the local zone that
+// contains its owner. Without a TTL the RR gets one hour.
+func (imr *Imr) AddLocalData(rrstr string) (dns.RR, error) {
+	rr, err := parseLocalData(rrstr)
+	if err != nil {
+		return nil, err
+	}
+	owner := rr.Header().Name
+	rrtype := rr.Header().Rrtype
+
+	lz := imr.localZones
+	lz.mu.Lock()
+	z := lz.covering(owner)
+	if z == nil {
+		z = &localZone{zone: owner, typ: LocalZoneTransparent, implicit: true, data: map[string]map[uint16][]dns.RR{}}
+		lz.zones[owner] = z
+	}
+	if z.data[owner] == nil {
+		z.data[owner] = map[uint16][]dns.RR{}
+	}
+	dup := false
+	for _, old := range z.data[owner][rrtype] {
+		if dns.IsDuplicate(old, rr) {
+			dup = true
+			break
+		}
+	}
+	if !dup {
+		z.data[owner][rrtype] = append(z.data[owner][rrtype], rr)
+	}
+	lz.mu.Unlock()
+
+	lgImr.Info("local data added", "rr", rr.String(), "zone", z.zone)
+	return rr, nil
+}
+
+// RemoveLocalData removes the local data for owner, only that of rrtype if
+// rrtype is not zero. An implicit zone left without data is removed too.
+func (imr *Imr) RemoveLocalData(owner string, rrtype uint16) error {
+	owner = dns.CanonicalName(owner)
+	lz := imr.localZones
+	lz.mu.Lock()
+	defer lz.mu.Unlock()
+	z := lz.covering(owner)
+	if z == nil || len(z.data[owner]) == 0 || (rrtype != 0 && len(z.data[owner][rrtype]) == 0) {
+		if rrtype != 0 {
+			return fmt.Errorf("no local data for %s %s", owner, dns.TypeToString[rrtype])
+		}
+		return fmt.Errorf("no local data for %s", owner)
+	}
+	if rrtype == 0 {
+		delete(z.data, owner)
+	} else {
+		delete(z.data[owner], rrtype)
+		if len(z.data[owner]) == 0 {
+			delete(z.data, owner)
+		}
+	}
+	if z.implicit && len(z.data) == 0 {
+		delete(lz.zones, z.zone)
+	}
+	lgImr.Info("local data removed", "owner", owner, "rrtype", dns.TypeToString[rrtype])
+	return nil
+}
+
+// LocalZones returns the local zones and their data, sorted by name.
+func (imr *Imr) LocalZones() []LocalZone {
+	lz := imr.localZones
+	lz.mu.RLock()
+	defer lz.mu.RUnlock()
+	out := make([]LocalZone, 0, len(lz.zones))
+	for _, z := range lz.zones {
+		out = append(out, z.export())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Zone < out[j].Zone })
+	return out
+}
+
+// initLocalZones applies the local-zones and local-data configuration.
+func (imr *Imr) initLocalZones(zones []ImrLocalZoneConf, data []string) error {
+	imr.localZones = newLocalZones()
+	for _, zc := range zones {
+		if zc.Zone == "" {
+			return fmt.Errorf("local-zones: zone is required")
+		}
+		if _, err := imr.AddLocalZone(zc.Zone, zc.Type, zc.Insecure); err != nil {
+			return fmt.Errorf("local-zones: %w", err)
+		}
+	}
+	for _, rrstr := range data {
+		if _, err := imr.AddLocalData(rrstr); err != nil {
+			return fmt.Errorf("local-data: %w", err)
+		}
+	}
+	return nil
+}
+
+// localResponse returns the answer to r from the local zones, or nil if
+// qname is to be resolved as usual. Without blocking, the blocking zone
+// types are treated as transparent.
+func (imr *Imr) localResponse(r *dns.Msg, qname string, qtype uint16, blocking bool) *dns.Msg {
+	lz := imr.localZones
+	if lz == nil || r.Question[0].Qclass != dns.ClassINET {
+		return nil
+	}
+	lz.mu.RLock()
+	defer lz.mu.RUnlock()
+	z := lz.covering(qname)
+	if z == nil {
+		return nil
+	}
+	typ := z.typ
+	if !blocking && typ.blocks() {
+		typ = LocalZoneTransparent
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	m.RecursionAvailable = true
+	blocked := func(rcode int) *dns.Msg {
+		m.Authoritative = false
+		m.Rcode = rcode
+		if opt := r.IsEdns0(); opt != nil {
+			edns0.AttachEDEToResponseWithText(m, dns.ExtendedErrorCodeBlocked, "local zone "+z.zone, opt.Do())
+		}
+		return m
+	}
+
+	if typ == LocalZoneAlwaysNxdomain {
+		m.Ns = z.soa()
+		return blocked(dns.RcodeNameError)
+	}
+
+	owner := qname
+	if typ == LocalZoneRedirect {
+		owner = z.zone
+	}
+	if rrtypes, ok := z.data[owner]; ok {
+		rrs := rrtypes[qtype]
+		if len(rrs) == 0 && qtype != dns.TypeCNAME {
+			rrs = rrtypes[dns.TypeCNAME]
+		}
+		for _, rr := range rrs {
+			rr = dns.Copy(rr)
+			rr.Header().Name = r.Question[0].Name
+			m.Answer = append(m.Answer, rr)
+		}
+		if len(m.Answer) == 0 {
+			m.Ns = z.soa()
+		}
+		return m
+	}
+
+	switch typ {
+	case LocalZoneTransparent:
+		return nil
+	case LocalZoneRefuse:
+		return blocked(dns.RcodeRefused)
+	}
+	// static, or redirect without apex data.
+	m.Ns = z.soa()
+	if !z.hasDescendants(qname) {
+		m.Rcode = dns.RcodeNameError
+	}
+	return m
+}
+
+// soa returns the SOA of the zone, if it has one in its local data.
+func (z *localZone) soa() []dns.RR {
+	return z.data[z.zone][dns.TypeSOA]
+}
+
+// hasDescendants reports whether there is local data below name, which
+// makes name an empty non-terminal rather than a non-existent name.
+func (z *localZone) hasDescendants(name string) bool {
+	for owner := range z.data {
+		if owner != name && dns.IsSubDomain(name, owner) {
+			return true
+		}
+	}
+	return false
+}
+
+func (z *localZone) export() LocalZone {
+	out := LocalZone{Zone: z.zone, Type: LocalZoneTypeToString[z.typ], Insecure: z.insecure, Implicit: z.implicit}
+	for _, rrtypes := range z.data {
+		for _, rrs := range rrtypes {
+			for _, rr := range rrs {
+				out.Data = append(out.Data, rr.String())
+			}
+		}
+	}
+	sort.Strings(out.Data)
+	return out
+}
+
+func mergeLocalData(dst, src *localZone) {
+	for owner, rrtypes := range src.data {
+		if dst.data[owner] == nil {
+			dst.data[owner] = map[uint16][]dns.RR{}
+		}
+		for rrtype, rrs := range rrtypes {
+			dst.data[owner][rrtype] = append(dst.data[owner][rrtype], rrs...)
+		}
+	}
+}
+
+// parseLocalData parses one local-data RR. The owner is made canonical, and
+// an RR given without a TTL gets localDataTTL.
+func parseLocalData(rrstr string) (dns.RR, error) {
+	zp := dns.NewZoneParser(strings.NewReader(rrstr), ".", "")
+	zp.SetDefaultTTL(localDataTTL)
+	rr, ok := zp.Next()
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("invalid local data %q: %v", rrstr, err)
+	}
+	if !ok || rr == nil {
+		return nil, fmt.Errorf("invalid local data %q: no RR", rrstr)
+	}
+	if rr.Header().Class != dns.ClassINET {
+		return nil, fmt.Errorf("invalid local data %q: only class IN is supported", rrstr)
+	}
+	switch rr.Header().Rrtype {
+	case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+		return nil, fmt.Errorf("invalid local data %q: DNSSEC records are not served from local zones", rrstr)
+	}
+	rr.Header().Name = dns.CanonicalName(rr.Header().Name)
+	return rr, nil
+}
@@ -0,0 +1,105 @@
+package tdns
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	"github.com/miekg/dns"
+)
+
+func TestLocalZones(t *testing.T) {
+	imr := &Imr{Cache: cache.NewRRsetCache(log.Default(), false, false)}
+	err := imr.initLocalZones([]ImrLocalZoneConf{
+		{Zone: "lan.", Type: "static", Insecure: true},
+		{Zone: "ads.example.", Type: "always-nxdomain"},
+		{Zone: "blocked.example.", Type: "refuse"},
+		{Zone: "portal.example.", Type: "redirect"},
+		{Zone: "corp.example.", Type: "transparent"},
+	}, []string{
+		"lan. IN SOA ns.lan. admin.lan. 1 3600 600 86400 300",
+		"printer.lan. 300 IN A 192.0.2.10",
+		"host.sub.lan. IN A 192.0.2.11",
+		"portal.example. IN A 192.0.2.80",
+		"intranet.corp.example. IN A 192.0.2.20",
+		"router.home.arpa. IN A 192.168.1.1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := imr.AddLocalZone("bad.example.", "nonsense", false); err == nil {
+		t.Error("unknown local zone type accepted")
+	}
+
+	for _, tc := range []struct {
+		qname   string
+		qtype   uint16
+		local   bool
+		rcode   int
+		answers int
+	}{
+		{"printer.lan.", dns.TypeA, true, dns.RcodeSuccess, 1},
+		{"printer.lan.", dns.TypeAAAA, true, dns.RcodeSuccess, 0},
+		{"sub.lan.", dns.TypeA, true, dns.RcodeSuccess, 0}, // empty non-terminal
+		{"nothere.lan.", dns.TypeA, true, dns.RcodeNameError, 0},
+		{"www.ads.example.", dns.TypeA, true, dns.RcodeNameError, 0},
+		{"www.blocked.example.", dns.TypeA, true, dns.RcodeRefused, 0},
+		{"any.thing.portal.example.", dns.TypeA, true, dns.RcodeSuccess, 1},
+		{"intranet.corp.example.", dns.TypeA, true, dns.RcodeSuccess, 1},
+		{"www.corp.example.", dns.TypeA, false, 0, 0},
+		{"router.home.arpa.", dns.TypeA, true, dns.RcodeSuccess, 1},
+		{"other.home.arpa.", dns.TypeA, false, 0, 0},
+		{"www.example.", dns.TypeA, false, 0, 0},
+	} {
+		q := new(dns.Msg)
+		q.SetQuestion(tc.qname, tc.qtype)
+		m := imr.localResponse(q, tc.qname, tc.qtype, true)
+		if (m != nil) != tc.local {
+			t.Errorf("%s %s: local answer %v, want %v", tc.qname, dns.TypeToString[tc.qtype], m != nil, tc.local)
+			continue
+		}
+		if m == nil {
+			continue
+		}
+		if m.Rcode != tc.rcode || len(m.Answer) != tc.answers || m.AuthenticatedData {
+			t.Errorf("%s %s: %s with %d answers (AD %v), want %s with %d",
+				tc.qname, dns.TypeToString[tc.qtype], dns.RcodeToString[m.Rcode], len(m.Answer),
+				m.AuthenticatedData, dns.RcodeToString[tc.rcode], tc.answers)
+		}
+		for _, rr := range m.Answer {
+			if rr.Header().Name != tc.qname {
+				t.Errorf("%s: answer owner %s", tc.qname, rr.Header().Name)
+			}
+		}
+	}
+
+	if imr.Cache.NTAs.Covering("www.lan.", time.Now()) != "lan." {
+		t.Error("insecure local zone has no negative trust anchor")
+	}
+	if err := imr.RemoveLocalZone("lan."); err != nil || imr.Cache.NTAs.Covering("www.lan.", time.Now()) != "" {
+		t.Errorf("RemoveLocalZone: %v, NTA left behind", err)
+	}
+	// An operator NTA for the same zone is neither replaced nor removed.
+	if _, err := imr.Cache.NTAs.AddPermanent("corp.", "domain-insecure", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := imr.AddLocalZone("corp.", "transparent", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := imr.RemoveLocalZone("corp."); err != nil {
+		t.Fatal(err)
+	}
+	if ntas := imr.Cache.NTAs.List(); len(ntas) != 1 || ntas[0].Zone != "corp." || ntas[0].Reason != "domain-insecure" {
+		t.Errorf("operator NTA after local zone add and remove: %+v", ntas)
+	}
+
+	if err := imr.RemoveLocalData("router.home.arpa.", 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, lz := range imr.LocalZones() {
+		if lz.Zone == "router.home.arpa." || lz.Zone == "lan." {
+			t.Errorf("local zone %s left after removal", lz.Zone)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	edns0 "github.com/johanix/tdns/v2/edns0"
+	"github.com/miekg/dns"
+)
+
+// Negative trust anchors (RFC 7646). The anchors themselves live in the
+// cache (cache.NTAStore), where the validator consults them; this file has
+// the IMR side: adding and removing them with the cache flushes that go
+// with it, the re-probe loop, and the EDE on answers below an NTA.
+
+const defaultNTAProbeInterval = 5 * time.Minute
+
+// AddNTA installs a negative trust anchor for zone. Data cached for names
+// below it is flushed, so that answers that failed validation are
+// re-fetched rather than served from the cache as bogus.
+func (imr *Imr) AddNTA(zone string, lifetime time.Duration, reason string) (*cache.NTA, error) {
+	if lifetime == 0 {
+		lifetime = imr.ntaLifetime
+	}
+	nta, err := imr.Cache.NTAs.Add(zone, lifetime, reason, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	imr.Cache.FlushDomain(nta.Zone, false)
+	lgImr.Info("negative trust anchor added", "zone", nta.Zone, "expires", nta.Expires, "reason", reason)
+	return nta, nil
+}
+
+// RemoveNTA removes the negative trust anchor for zone. Data cached while
+// it was in place was not validated and is flushed.
+func (imr *Imr) RemoveNTA(zone string) error {
+	zone = dns.CanonicalName(zone)
+	if !imr.Cache.NTAs.Remove(zone) {
+		return fmt.Errorf("no negative trust anchor for %s", zone)
+	}
+	imr.Cache.FlushDomain(zone, false)
+	lgImr.Info("negative trust anchor removed", "zone", zone)
+	return nil
+}
+
+// NTAs returns the negative trust anchors.
+func (imr *Imr) NTAs() []cache.NTA {
+	return imr.Cache.NTAs.List()
+}
+
+// probeNTA checks whether zone validates without its NTA, and removes the
+// NTA if it does. The probe must not see validation states cached under
+// the NTA, so the zone is flushed first. It is flushed again afterwards:
+// a failed probe may have cached bogus data, and clients may have cached
+// unvalidated data while the probe ran.
+func (imr *Imr) probeNTA(ctx context.Context, zone string) {
+	imr.Cache.FlushDomain(zone, false)
+	serverMap, ok := imr.Cache.ServerMap.Get(zone)
+	if !ok || len(serverMap) == 0 {
+		serverMap, _ = imr.Cache.ServerMap.Get(".")
+	}
+
+	outcome := ""
+	state := cache.ValidationStateNone
+	if len(serverMap) == 0 {
+		outcome = "no known servers"
+	} else {
+		_, _, _, _, err := imr.IterativeDNSQuery(cache.IgnoreNTAs(ctx), zone, dns.TypeSOA, serverMap, true, false)
+		if err != nil {
+			outcome = err.Error()
+		} else if crrset := imr.Cache.Get(zone, dns.TypeSOA); crrset == nil {
+			outcome = "no SOA"
+		} else {
+			state = crrset.State
+			outcome = cache.ValidationStateToString[state]
+		}
+	}
+
+	if state == cache.ValidationStateSecure && imr.Cache.NTAs.Remove(zone) {
+		lgImr.Info("negative trust anchor removed: zone validates again", "zone", zone)
+	}
+	imr.Cache.FlushDomain(zone, false)
+	if state == cache.ValidationStateSecure {
+		return
+	}
+	imr.Cache.NTAs.SetProbe(zone, outcome, time.Now())
+	lgImr.Debug("negative trust anchor kept", "zone", zone, "probe", outcome)
+}
+
+// runNTAProbes expires NTAs and re-probes the remaining ones every
+// probe interval. Permanent NTAs are left alone.
+func (imr *Imr) runNTAProbes(ctx context.Context) {
+	ticker := time.NewTicker(imr.ntaProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, zone := range imr.Cache.NTAs.Expire(time.Now()) {
+			imr.Cache.FlushDomain(zone, false)
+			lgImr.Info("negative trust anchor expired", "zone", zone)
+		}
+		for _, nta := range imr.Cache.NTAs.List() {
+			if !nta.Permanent {
+				imr.probeNTA(ctx, nta.Zone)
+			}
+		}
+	}
+}
+
+// initNTAs applies the nta configuration.
+func (imr *Imr) initNTAs(conf ImrNtaConf) error {
+	if conf.DefaultLifetime < 0 || conf.ProbeInterval < 0 {
+		return fmt.Errorf("nta: default-lifetime and probe-interval must not be negative")
+	}
+	imr.ntaLifetime = conf.DefaultLifetime
+	imr.ntaProbeInterval = conf.ProbeInterval
+	if imr.ntaProbeInterval == 0 {
+		imr.ntaProbeInterval = defaultNTAProbeInterval
+	}
+	for _, zone := range conf.DomainInsecure {
+		nta, err := imr.Cache.NTAs.AddPermanent(zone, "domain-insecure", time.Now())
+		if err != nil {
+			return fmt.Errorf("nta.domain-insecure: %w", err)
+		}
+		lgImr.Info("validation disabled for zone", "zone", nta.Zone)
+	}
+	return nil
+}
+
+// ntaResponseWriter attaches the NTA EDE to answers for names below a
+// negative trust anchor, so that clients can tell them from answers in
+// unsigned zones.
+type ntaResponseWriter struct {
+	dns.ResponseWriter
+	dnssecOK bool
+}
+
+func (w *ntaResponseWriter) WriteMsg(m *dns.Msg) error {
+	if m.Rcode == dns.RcodeSuccess || m.Rcode == dns.RcodeNameError {
+		if found, _, _ := edns0.ExtractEDEFromMsg(m); !found {
+			edns0.AttachEDEToResponseWithText(m, edns0.EDENegativeTrustAnchor,
+				edns0.EDECodeToString[edns0.EDENegativeTrustAnchor], w.dnssecOK)
+		}
+	}
+	return w.ResponseWriter.WriteMsg(m)
+}
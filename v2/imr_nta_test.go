@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"testing"
+
+	edns0 "github.com/johanix/tdns/v2/edns0"
+	"github.com/miekg/dns"
+)
+
+func TestNtaResponseWriter(t *testing.T) {
+	q := new(dns.Msg)
+	q.SetQuestion("www.broken.example.", dns.TypeA)
+	q.SetEdns0(1232, true)
+
+	for _, c := range []struct {
+		rcode int
+		want  bool
+	}{{dns.RcodeSuccess, true}, {dns.RcodeNameError, true}, {dns.RcodeServerFailure, false}} {
+		rw := newAccessRW("127.0.0.1:53", "127.0.0.1:4000")
+		m := new(dns.Msg)
+		m.SetRcode(q, c.rcode)
+		m.SetEdns0(1232, true)
+		(&ntaResponseWriter{ResponseWriter: rw, dnssecOK: true}).WriteMsg(m)
+		found, code, _ := edns0.ExtractEDEFromMsg(rw.reply)
+		if got := found && code == edns0.EDENegativeTrustAnchor; got != c.want {
+			t.Errorf("rcode %s: NTA EDE attached = %v, want %v", dns.RcodeToString[c.rcode], got, c.want)
+		}
+	}
+
+	// An EDE already in the response is kept.
+	rw := newAccessRW("127.0.0.1:53", "127.0.0.1:4000")
+	m := new(dns.Msg)
+	m.SetRcode(q, dns.RcodeSuccess)
+	edns0.AttachEDEToResponse(m, edns0.EDEDNSSECBogus)
+	(&ntaResponseWriter{ResponseWriter: rw}).WriteMsg(m)
+	if _, code, _ := edns0.ExtractEDEFromMsg(rw.reply); code != edns0.EDEDNSSECBogus {
+		t.Errorf("existing EDE replaced by %d", code)
+	}
+}
@@ -0,0 +1,704 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// Automated trust anchor maintenance (RFC 5011).
+//
+// Every trust anchor zone is a trust point. Its DNSKEY RRset is probed
+// periodically, and each SEP key goes through the RFC 5011 states:
+//
+//	new key seen, RRset valid     -> AddPend (add hold-down starts)
+//	AddPend, hold-down over       -> Valid   (now a trust anchor)
+//	AddPend, key gone             -> forgotten
+//	Valid, key gone               -> Missing (still trusted)
+//	Missing, key back             -> Valid
+//	REVOKE set and self-signed    -> Revoked (no longer trusted)
+//	Revoked, remove hold-down over -> removed
+//
+// New keys are only considered when the RRset is signed by a key that is
+// already trusted. The state is written to the state file after every
+// change. The state file is separate from the trust-anchor-file
+// (default <trust-anchor-file>.5011-state), which is never written.
+// Trusted keys are written as plain DNSKEY lines and untrusted ones as
+// comments, so the state file is itself a valid trust-anchor-file for any
+// reader (dog +sigchase, a restart with rfc5011 off).
+
+// TAKeyState is the RFC 5011 state of a trust point key.
+type TAKeyState uint8
+
+const (
+	TAKeyAddPend TAKeyState = iota + 1
+	TAKeyValid
+	TAKeyMissing
+	TAKeyRevoked
+)
+
+var TAKeyStateToString = map[TAKeyState]string{
+	TAKeyAddPend: "ADDPEND",
+	TAKeyValid:   "VALID",
+	TAKeyMissing: "MISSING",
+	TAKeyRevoked: "REVOKED",
+}
+
+var StringToTAKeyState = map[string]TAKeyState{
+	"ADDPEND": TAKeyAddPend,
+	"VALID":   TAKeyValid,
+	"MISSING": TAKeyMissing,
+	"REVOKED": TAKeyRevoked,
+}
+
+const (
+	defaultAddHoldDown    = 30 * 24 * time.Hour
+	defaultRemoveHoldDown = 30 * 24 * time.Hour
+)
+
+// trustPointKey is one tracked key. Dnskey is kept with the REVOKE bit
+// cleared, so the key is recognised after it has been revoked.
+type trustPointKey struct {
+	Dnskey     dns.DNSKEY
+	State      TAKeyState
+	FirstSeen  time.Time
+	LastChange time.Time
+	HoldDown   time.Time // end of the add (AddPend) or remove (Revoked) hold-down
+}
+
+func (k *trustPointKey) trusted() bool {
+	return k.State == TAKeyValid || k.State == TAKeyMissing
+}
+
+// trustPoint is the RFC 5011 state of one trust anchor zone.
+type trustPoint struct {
+	Zone        string
+	Keys        map[string]*trustPointKey
+	LastQueried time.Time
+	LastSuccess time.Time
+	NextProbe   time.Time
+	LastError   string
+}
+
+// taKeyID identifies a key independently of its REVOKE bit.
+func taKeyID(dk *dns.DNSKEY) string {
+	return fmt.Sprintf("%d/%d/%d/%s", dk.Flags&^dns.REVOKE, dk.Protocol, dk.Algorithm, dk.PublicKey)
+}
+
+// unrevoked returns a copy of dk with the REVOKE bit cleared.
+func unrevoked(dk *dns.DNSKEY) dns.DNSKEY {
+	c := *dk
+	c.Flags &^= dns.REVOKE
+	return c
+}
+
+func isSEPKey(dk *dns.DNSKEY) bool {
+	return dk.Flags&dns.ZONE != 0 && dk.Flags&dns.SEP != 0
+}
+
+// trustedKeys returns the keys currently acting as trust anchors.
+func (tp *trustPoint) trustedKeys() []dns.DNSKEY {
+	var keys []dns.DNSKEY
+	for _, id := range tp.sortedIDs() {
+		if k := tp.Keys[id]; k.trusted() {
+			keys = append(keys, k.Dnskey)
+		}
+	}
+	return keys
+}
+
+func (tp *trustPoint) sortedIDs() []string {
+	ids := make([]string, 0, len(tp.Keys))
+	for id := range tp.Keys {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		ki, kj := tp.Keys[ids[i]], tp.Keys[ids[j]]
+		if ki.Dnskey.KeyTag() != kj.Dnskey.KeyTag() {
+			return ki.Dnskey.KeyTag() < kj.Dnskey.KeyTag()
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// update runs the RFC 5011 state machine on a freshly fetched DNSKEY
+// RRset. It reports whether any key changed state. An error means the
+// RRset was not signed by a trusted key; revocations are still processed
+// in that case, as a revoked key proves itself by its own signature.
+func (tp *trustPoint) update(rrset *core.RRset, now time.Time, addHoldDown, removeHoldDown time.Duration) (bool, error) {
+	changed := false
+	setState := func(k *trustPointKey, s TAKeyState) {
+		lgImr.Info("RFC 5011 key state change", "zone", tp.Zone, "keytag", k.Dnskey.KeyTag(),
+			"from", TAKeyStateToString[k.State], "to", TAKeyStateToString[s])
+		k.State = s
+		k.LastChange = now
+		changed = true
+	}
+
+	// Revocations: a key with the REVOKE bit that signs the RRset itself.
+	seen := map[string]bool{}
+	for _, rr := range rrset.RRs {
+		dk, ok := rr.(*dns.DNSKEY)
+		if !ok || dk.Flags&dns.REVOKE == 0 {
+			continue
+		}
+		id := taKeyID(dk)
+		seen[id] = true
+		k := tp.Keys[id]
+		if k == nil || k.State == TAKeyRevoked {
+			continue
+		}
+		if valid, _ := cache.ValidateDNSKEYRRsetSignature(rrset, dk.KeyTag(), tp.Zone, dk, false); !valid {
+			lgImr.Warn("RFC 5011: revoked key does not sign the DNSKEY RRset, ignored", "zone", tp.Zone, "keytag", dk.KeyTag())
+			continue
+		}
+		setState(k, TAKeyRevoked)
+		k.HoldDown = now.Add(removeHoldDown)
+	}
+
+	signed := false
+	for _, id := range tp.sortedIDs() {
+		k := tp.Keys[id]
+		if !k.trusted() {
+			continue
+		}
+		if valid, _ := cache.ValidateDNSKEYRRsetSignature(rrset, k.Dnskey.KeyTag(), tp.Zone, &k.Dnskey, false); valid {
+			signed = true
+			break
+		}
+	}
+	if !signed {
+		if len(tp.trustedKeys()) == 0 {
+			lgImr.Error("RFC 5011: trust point has no trusted keys left", "zone", tp.Zone)
+		}
+		return changed, fmt.Errorf("DNSKEY RRset for %s is not signed by a trusted key", tp.Zone)
+	}
+
+	for _, rr := range rrset.RRs {
+		dk, ok := rr.(*dns.DNSKEY)
+		if !ok || dk.Flags&dns.REVOKE != 0 || !isSEPKey(dk) {
+			continue
+		}
+		id := taKeyID(dk)
+		seen[id] = true
+		k := tp.Keys[id]
+		switch {
+		case k == nil:
+			k = &trustPointKey{Dnskey: unrevoked(dk), State: TAKeyAddPend, FirstSeen: now, LastChange: now, HoldDown: now.Add(addHoldDown)}
+			tp.Keys[id] = k
+			lgImr.Info("RFC 5011: new key, add hold-down started", "zone", tp.Zone, "keytag", dk.KeyTag(), "until", k.HoldDown)
+			changed = true
+		case k.State == TAKeyAddPend && !now.Before(k.HoldDown):
+			setState(k, TAKeyValid)
+		case k.State == TAKeyMissing:
+			setState(k, TAKeyValid)
+		}
+		k.Dnskey.Hdr.Ttl = dk.Hdr.Ttl
+	}
+
+	for _, id := range tp.sortedIDs() {
+		k := tp.Keys[id]
+		if seen[id] && k.State != TAKeyRevoked {
+			continue
+		}
+		switch k.State {
+		case TAKeyAddPend:
+			lgImr.Info("RFC 5011: pending key disappeared, forgotten", "zone", tp.Zone, "keytag", k.Dnskey.KeyTag())
+			delete(tp.Keys, id)
+			changed = true
+		case TAKeyValid:
+			setState(k, TAKeyMissing)
+		case TAKeyRevoked:
+			if !now.Before(k.HoldDown) {
+				lgImr.Info("RFC 5011: revoked key removed", "zone", tp.Zone, "keytag", k.Dnskey.KeyTag())
+				delete(tp.Keys, id)
+				changed = true
+			}
+		}
+	}
+	return changed, nil
+}
+
+// probeInterval is the RFC 5011 section 2.3 active refresh time:
+// MAX(1 hour, MIN(15 days, TTL/2, time to RRSIG expiry/2)); after a
+// failure the retry time uses a tenth and a one day ceiling instead.
+func probeInterval(rrset *core.RRset, now time.Time, failed bool) time.Duration {
+	limit, div := 15*24*time.Hour, time.Duration(2)
+	if failed {
+		limit, div = 24*time.Hour, 10
+	}
+	d := limit
+	if rrset != nil {
+		if ttl := cache.GetMinTTL(rrset.RRs); ttl > 0 && ttl/div < d {
+			d = ttl / div
+		}
+		for _, rr := range rrset.RRSIGs {
+			if sig, ok := rr.(*dns.RRSIG); ok {
+				if left := time.Unix(int64(sig.Expiration), 0).Sub(now) / div; left < d {
+					d = left
+				}
+			}
+		}
+	}
+	if d < time.Hour {
+		d = time.Hour
+	}
+	return d
+}
+
+// trustAnchorTracker holds the trust points and their state file.
+type trustAnchorTracker struct {
+	mu             sync.Mutex
+	points         map[string]*trustPoint
+	stateFile      string
+	anchorFile     string // the configured trust-anchor-file, read only
+	addHoldDown    time.Duration
+	removeHoldDown time.Duration
+}
+
+func newTrustAnchorTracker(conf ImrRfc5011Conf, trustAnchorFile string) (*trustAnchorTracker, error) {
+	t := &trustAnchorTracker{
+		points:         map[string]*trustPoint{},
+		stateFile:      conf.StateFile,
+		addHoldDown:    conf.AddHoldDown,
+		removeHoldDown: conf.RemoveHoldDown,
+	}
+	if t.stateFile == "" && trustAnchorFile != "" {
+		t.stateFile = trustAnchorFile + ".5011-state"
+	}
+	if t.stateFile == "" {
+		return nil, fmt.Errorf("rfc5011: no state-file and no trust-anchor-file to derive one from")
+	}
+	if trustAnchorFile != "" && sameFile(t.stateFile, trustAnchorFile) {
+		return nil, fmt.Errorf("rfc5011: state-file %s is the trust-anchor-file; the configured anchors are never rewritten", t.stateFile)
+	}
+	t.anchorFile = trustAnchorFile
+	if t.addHoldDown == 0 {
+		t.addHoldDown = defaultAddHoldDown
+	}
+	if t.removeHoldDown == 0 {
+		t.removeHoldDown = defaultRemoveHoldDown
+	}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// load reads the state file. A file without RFC 5011 state (e.g. a plain
+// trust-anchor-file on the first run) yields no trust points.
+func (t *trustAnchorTracker) load() error {
+	data, err := os.ReadFile(t.stateFile)
+	if os.IsNotExist(err) {
+		return t.loadLegacy()
+	}
+	if err != nil {
+		return fmt.Errorf("rfc5011: %w", err)
+	}
+	points, err := parseRfc5011State(string(data))
+	if err != nil {
+		return fmt.Errorf("rfc5011: state file %s: %w", t.stateFile, err)
+	}
+	t.points = points
+	return nil
+}
+
+// loadLegacy picks up state that older versions kept in the
+// trust-anchor-file itself. The file is only read; the next save writes
+// the state file.
+func (t *trustAnchorTracker) loadLegacy() error {
+	if t.anchorFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(t.anchorFile)
+	if err != nil {
+		return nil
+	}
+	points, err := parseRfc5011State(string(data))
+	if err != nil || len(points) == 0 {
+		return nil
+	}
+	t.points = points
+	return nil
+}
+
+// sameFile reports whether a and b name the same file, either by path or,
+// when both exist, by identity.
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	fa, errA := os.Stat(a)
+	fb, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(fa, fb)
+}
+
+const rfc5011StateHeader = "; tdns-imr RFC 5011 trust anchor state. Rewritten by tdns-imr on every change.\n"
+
+// parseRfc5011State parses the state file format written by save:
+//
+//	;;trustpoint <zone> last_queried=<unix> last_success=<unix> next_probe=<unix>
+//	<DNSKEY RR> ;;state=VALID first_seen=<unix> last_change=<unix> hold_down=<unix>
+//	;;untrusted <DNSKEY RR> ;;state=ADDPEND ...
+//
+// Lines without ;;state= (plain trust anchors) are ignored.
+func parseRfc5011State(data string) (map[string]*trustPoint, error) {
+	points := map[string]*trustPoint{}
+	unixAttr := func(attrs map[string]string, key string) time.Time {
+		v, err := strconv.ParseInt(attrs[key], 10, 64)
+		if err != nil || v == 0 {
+			return time.Time{}
+		}
+		return time.Unix(v, 0)
+	}
+	sc := bufio.NewScanner(strings.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineno := 1; sc.Scan(); lineno++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, ";;trustpoint "):
+			fields := strings.Fields(strings.TrimPrefix(line, ";;trustpoint "))
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: trustpoint without zone", lineno)
+			}
+			attrs := parseStateAttrs(fields[1:])
+			zone := dns.Fqdn(strings.ToLower(fields[0]))
+			tp := points[zone]
+			if tp == nil {
+				tp = &trustPoint{Zone: zone, Keys: map[string]*trustPointKey{}}
+				points[zone] = tp
+			}
+			tp.LastQueried = unixAttr(attrs, "last_queried")
+			tp.LastSuccess = unixAttr(attrs, "last_success")
+			tp.NextProbe = unixAttr(attrs, "next_probe")
+			continue
+		case strings.Contains(line, ";;state="):
+		default:
+			continue
+		}
+		line = strings.TrimPrefix(line, ";;untrusted ")
+		rrtext, attrtext, _ := strings.Cut(line, ";;")
+		rr, err := dns.NewRR(rrtext)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+		dk, ok := rr.(*dns.DNSKEY)
+		if !ok {
+			return nil, fmt.Errorf("line %d: not a DNSKEY", lineno)
+		}
+		attrs := parseStateAttrs(strings.Fields(strings.ReplaceAll(attrtext, ";;", " ")))
+		state, ok := StringToTAKeyState[attrs["state"]]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown state %q", lineno, attrs["state"])
+		}
+		zone := dns.Fqdn(strings.ToLower(dk.Hdr.Name))
+		tp := points[zone]
+		if tp == nil {
+			tp = &trustPoint{Zone: zone, Keys: map[string]*trustPointKey{}}
+			points[zone] = tp
+		}
+		tp.Keys[taKeyID(dk)] = &trustPointKey{
+			Dnskey:     unrevoked(dk),
+			State:      state,
+			FirstSeen:  unixAttr(attrs, "first_seen"),
+			LastChange: unixAttr(attrs, "last_change"),
+			HoldDown:   unixAttr(attrs, "hold_down"),
+		}
+	}
+	return points, sc.Err()
+}
+
+func parseStateAttrs(fields []string) map[string]string {
+	attrs := map[string]string{}
+	for _, f := range fields {
+		if k, v, ok := strings.Cut(f, "="); ok {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// formatRfc5011State renders the trust points in the state file format.
+func formatRfc5011State(points map[string]*trustPoint) string {
+	var b strings.Builder
+	b.WriteString(rfc5011StateHeader)
+	zones := make([]string, 0, len(points))
+	for z := range points {
+		zones = append(zones, z)
+	}
+	sort.Strings(zones)
+	for _, z := range zones {
+		tp := points[z]
+		fmt.Fprintf(&b, ";;trustpoint %s last_queried=%d last_success=%d next_probe=%d\n",
+			tp.Zone, unixOrZero(tp.LastQueried), unixOrZero(tp.LastSuccess), unixOrZero(tp.NextProbe))
+		for _, id := range tp.sortedIDs() {
+			k := tp.Keys[id]
+			prefix := ""
+			if !k.trusted() {
+				prefix = ";;untrusted "
+			}
+			fmt.Fprintf(&b, "%s%s ;;state=%s first_seen=%d last_change=%d hold_down=%d ;;keytag=%d\n",
+				prefix, k.Dnskey.String(), TAKeyStateToString[k.State],
+				unixOrZero(k.FirstSeen), unixOrZero(k.LastChange), unixOrZero(k.HoldDown), k.Dnskey.KeyTag())
+		}
+	}
+	return b.String()
+}
+
+// save writes the state file atomically. Caller holds t.mu.
+func (t *trustAnchorTracker) save() error {
+	tmp, err := os.CreateTemp(filepath.Dir(t.stateFile), ".rfc5011-*")
+	if err != nil {
+		return fmt.Errorf("rfc5011: save state: %w", err)
+	}
+	if _, err := tmp.WriteString(formatRfc5011State(t.points)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("rfc5011: save state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("rfc5011: save state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), t.stateFile); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("rfc5011: save state: %w", err)
+	}
+	return nil
+}
+
+// applyToAnchors replaces the configured anchors of every zone that has
+// RFC 5011 state with its trusted keys: once a zone is tracked, the state
+// file rather than the configuration says which keys are trusted.
+func (t *trustAnchorTracker) applyToAnchors(dsByName map[string][]*dns.DS, dnskeysByName map[string][]*dns.DNSKEY) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for zone, tp := range t.points {
+		keys := tp.trustedKeys()
+		if len(keys) == 0 {
+			lgImr.Error("RFC 5011: trust point has no trusted keys; zone cannot be validated", "zone", zone)
+		}
+		delete(dsByName, zone)
+		dnskeysByName[zone] = nil
+		for i := range keys {
+			dnskeysByName[zone] = append(dnskeysByName[zone], &keys[i])
+		}
+	}
+}
+
+// bootstrap starts tracking zone with the keys that validated its DNSKEY
+// RRset at startup, all in state Valid. Zones already tracked are left
+// alone.
+func (t *trustAnchorTracker) bootstrap(zone string, keys []*dns.DNSKEY, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.points[zone]; ok || len(keys) == 0 {
+		return false
+	}
+	tp := &trustPoint{Zone: zone, Keys: map[string]*trustPointKey{}, NextProbe: now}
+	for _, dk := range keys {
+		tp.Keys[taKeyID(dk)] = &trustPointKey{Dnskey: unrevoked(dk), State: TAKeyValid, FirstSeen: now, LastChange: now}
+	}
+	t.points[zone] = tp
+	lgImr.Info("RFC 5011: tracking trust point", "zone", zone, "keys", len(keys))
+	return true
+}
+
+// syncTrustAnchors makes the DNSKEY cache's trust anchor flags for zone
+// follow the trust point. Caller holds t.mu.
+func (imr *Imr) syncTrustAnchors(tp *trustPoint) {
+	exp := time.Now().Add(365 * 24 * time.Hour)
+	for _, k := range tp.Keys {
+		keyid := k.Dnskey.KeyTag()
+		if k.trusted() {
+			imr.DnskeyCache.Set(tp.Zone, keyid, &cache.CachedDnskeyRRset{
+				Name:        tp.Zone,
+				Keyid:       keyid,
+				State:       cache.ValidationStateSecure,
+				TrustAnchor: true,
+				Dnskey:      k.Dnskey,
+				Expiration:  exp,
+			})
+		} else if cdr := imr.DnskeyCache.Get(tp.Zone, keyid); cdr != nil && cdr.TrustAnchor {
+			cdr.TrustAnchor = false
+			imr.DnskeyCache.Set(tp.Zone, keyid, cdr)
+		}
+	}
+}
+
+// probeTrustPoint fetches the DNSKEY RRset of one trust point and runs
+// the state machine on it.
+func (imr *Imr) probeTrustPoint(ctx context.Context, zone string) {
+	t := imr.taTracker
+	serverMap, ok := imr.Cache.ServerMap.Get(zone)
+	if !ok || len(serverMap) == 0 {
+		serverMap, _ = imr.Cache.ServerMap.Get(".")
+	}
+	var rrset *core.RRset
+	var err error
+	if len(serverMap) == 0 {
+		err = fmt.Errorf("no known servers for %s", zone)
+	} else {
+		rrset, _, _, _, err = imr.IterativeDNSQuery(ctx, zone, dns.TypeDNSKEY, serverMap, true, false)
+		if err == nil && (rrset == nil || len(rrset.RRs) == 0) {
+			err = fmt.Errorf("no DNSKEY RRset for %s", zone)
+		}
+	}
+
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tp := t.points[zone]
+	if tp == nil {
+		return
+	}
+	tp.LastQueried = now
+	changed := false
+	if err == nil {
+		changed, err = tp.update(rrset, now, t.addHoldDown, t.removeHoldDown)
+		if changed {
+			imr.syncTrustAnchors(tp)
+		}
+	}
+	if err != nil {
+		tp.LastError = err.Error()
+		tp.NextProbe = now.Add(probeInterval(rrset, now, true))
+		lgImr.Warn("RFC 5011 probe failed", "zone", zone, "err", err, "retry", tp.NextProbe)
+	} else {
+		tp.LastError = ""
+		tp.LastSuccess = now
+		tp.NextProbe = now.Add(probeInterval(rrset, now, false))
+		lgImr.Debug("RFC 5011 probe done", "zone", zone, "changed", changed, "next", tp.NextProbe)
+	}
+	if err := t.save(); err != nil {
+		lgImr.Error("RFC 5011: failed to save state", "file", t.stateFile, "err", err)
+	}
+}
+
+// runRfc5011 probes each trust point when its refresh time has come.
+func (imr *Imr) runRfc5011(ctx context.Context) {
+	t := imr.taTracker
+	if t == nil {
+		return
+	}
+	lgImr.Info("RFC 5011 trust anchor maintenance started", "stateFile", t.stateFile,
+		"addHoldDown", t.addHoldDown, "removeHoldDown", t.removeHoldDown)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		t.mu.Lock()
+		var due []string
+		now := time.Now()
+		for zone, tp := range t.points {
+			if !now.Before(tp.NextProbe) {
+				due = append(due, zone)
+			}
+		}
+		t.mu.Unlock()
+		sort.Strings(due)
+		for _, zone := range due {
+			imr.probeTrustPoint(ctx, zone)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// TrustAnchorStatus describes one trust anchor zone for "imr-show-ta".
+type TrustAnchorStatus struct {
+	Zone        string                 `json:"zone"`
+	Rfc5011     bool                   `json:"rfc5011"`
+	LastQueried time.Time              `json:"last_queried,omitempty"`
+	LastSuccess time.Time              `json:"last_success,omitempty"`
+	NextProbe   time.Time              `json:"next_probe,omitempty"`
+	LastError   string                 `json:"last_error,omitempty"`
+	Keys        []TrustAnchorKeyStatus `json:"keys"`
+}
+
+// TrustAnchorKeyStatus is one key of a TrustAnchorStatus. State is the
+// RFC 5011 state, or "STATIC" for an untracked trust anchor.
+type TrustAnchorKeyStatus struct {
+	KeyTag     uint16    `json:"keytag"`
+	Algorithm  string    `json:"algorithm"`
+	State      string    `json:"state"`
+	FirstSeen  time.Time `json:"first_seen,omitempty"`
+	LastChange time.Time `json:"last_change,omitempty"`
+	HoldDown   time.Time `json:"hold_down,omitempty"`
+}
+
+// TrustAnchors returns the trust anchors in use, with their RFC 5011
+// state for tracked zones.
+func (imr *Imr) TrustAnchors() []TrustAnchorStatus {
+	byZone := map[string]*TrustAnchorStatus{}
+	if t := imr.taTracker; t != nil {
+		t.mu.Lock()
+		for zone, tp := range t.points {
+			st := &TrustAnchorStatus{Zone: zone, Rfc5011: true, LastQueried: tp.LastQueried,
+				LastSuccess: tp.LastSuccess, NextProbe: tp.NextProbe, LastError: tp.LastError}
+			for _, id := range tp.sortedIDs() {
+				k := tp.Keys[id]
+				st.Keys = append(st.Keys, TrustAnchorKeyStatus{
+					KeyTag:     k.Dnskey.KeyTag(),
+					Algorithm:  dns.AlgorithmToString[k.Dnskey.Algorithm],
+					State:      TAKeyStateToString[k.State],
+					FirstSeen:  k.FirstSeen,
+					LastChange: k.LastChange,
+					HoldDown:   k.HoldDown,
+				})
+			}
+			byZone[zone] = st
+		}
+		t.mu.Unlock()
+	}
+	if imr.DnskeyCache != nil {
+		for item := range imr.DnskeyCache.Map.IterBuffered() {
+			cdr := item.Val
+			if !cdr.TrustAnchor || byZone[cdr.Name] != nil && byZone[cdr.Name].Rfc5011 {
+				continue
+			}
+			st := byZone[cdr.Name]
+			if st == nil {
+				st = &TrustAnchorStatus{Zone: cdr.Name}
+				byZone[cdr.Name] = st
+			}
+			st.Keys = append(st.Keys, TrustAnchorKeyStatus{
+				KeyTag:    cdr.Keyid,
+				Algorithm: dns.AlgorithmToString[cdr.Dnskey.Algorithm],
+				State:     "STATIC",
+			})
+		}
+	}
+	var out []TrustAnchorStatus
+	for _, st := range byZone {
+		sort.Slice(st.Keys, func(i, j int) bool { return st.Keys[i].KeyTag < st.Keys[j].KeyTag })
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Zone < out[j].Zone })
+	return out
+}
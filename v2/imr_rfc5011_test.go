@@ -0,0 +1,261 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+type testKSK struct {
+	dk   *dns.DNSKEY
+	priv crypto.PrivateKey
+}
+
+func newTestKSK(t *testing.T) *testKSK {
+	t.Helper()
+	dk := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "example.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := dk.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKSK{dk: dk, priv: priv}
+}
+
+// revoked returns the key as published with the REVOKE bit set.
+func (k *testKSK) revoked() *testKSK {
+	dk := *k.dk
+	dk.Flags |= dns.REVOKE
+	return &testKSK{dk: &dk, priv: k.priv}
+}
+
+// dnskeyRRset publishes keys, signed by signers.
+func dnskeyRRset(t *testing.T, keys []*testKSK, signers ...*testKSK) *core.RRset {
+	t.Helper()
+	rrset := &core.RRset{Name: "example.", Class: dns.ClassINET, RRtype: dns.TypeDNSKEY}
+	for _, k := range keys {
+		rrset.RRs = append(rrset.RRs, k.dk)
+	}
+	now := time.Now()
+	for _, s := range signers {
+		sig := &dns.RRSIG{
+			Hdr:        dns.RR_Header{Name: "example.", Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+			KeyTag:     s.dk.KeyTag(),
+			SignerName: "example.",
+			Algorithm:  s.dk.Algorithm,
+			Inception:  uint32(now.Add(-time.Hour).Unix()),
+			Expiration: uint32(now.Add(10 * 24 * time.Hour).Unix()),
+		}
+		if err := sig.Sign(s.priv.(crypto.Signer), rrset.RRs); err != nil {
+			t.Fatal(err)
+		}
+		rrset.RRSIGs = append(rrset.RRSIGs, sig)
+	}
+	return rrset
+}
+
+func keyState(tp *trustPoint, k *testKSK) TAKeyState {
+	if tk := tp.Keys[taKeyID(k.dk)]; tk != nil {
+		return tk.State
+	}
+	return 0
+}
+
+func TestRfc5011AddHoldDown(t *testing.T) {
+	k1, k2 := newTestKSK(t), newTestKSK(t)
+	tr := &trustAnchorTracker{points: map[string]*trustPoint{}}
+	start := time.Now()
+	tr.bootstrap("example.", []*dns.DNSKEY{k1.dk}, start)
+	tp := tr.points["example."]
+	hold := 30 * 24 * time.Hour
+
+	rrset := dnskeyRRset(t, []*testKSK{k1, k2}, k1)
+	if changed, err := tp.update(rrset, start, hold, hold); err != nil || !changed {
+		t.Fatalf("update: changed %v, err %v", changed, err)
+	}
+	if s := keyState(tp, k2); s != TAKeyAddPend {
+		t.Fatalf("new key state %s, want ADDPEND", TAKeyStateToString[s])
+	}
+	if len(tp.trustedKeys()) != 1 {
+		t.Error("pending key is trusted")
+	}
+	tp.update(rrset, start.Add(hold-time.Hour), hold, hold)
+	if s := keyState(tp, k2); s != TAKeyAddPend {
+		t.Fatalf("key state %s before the hold-down ended, want ADDPEND", TAKeyStateToString[s])
+	}
+	tp.update(rrset, start.Add(hold), hold, hold)
+	if s := keyState(tp, k2); s != TAKeyValid {
+		t.Fatalf("key state %s after the hold-down, want VALID", TAKeyStateToString[s])
+	}
+
+	// A pending key that goes away is forgotten.
+	k3 := newTestKSK(t)
+	tp.update(dnskeyRRset(t, []*testKSK{k1, k2, k3}, k1), start, hold, hold)
+	tp.update(dnskeyRRset(t, []*testKSK{k1, k2}, k2), start.Add(time.Hour), hold, hold)
+	if _, ok := tp.Keys[taKeyID(k3.dk)]; ok {
+		t.Error("vanished pending key still tracked")
+	}
+
+	// A Valid key that is missing stays trusted, and returns to Valid.
+	tp.update(dnskeyRRset(t, []*testKSK{k2}, k2), start.Add(hold+time.Hour), hold, hold)
+	if s := keyState(tp, k1); s != TAKeyMissing || len(tp.trustedKeys()) != 2 {
+		t.Errorf("absent key state %s, %d trusted keys; want MISSING and still trusted", TAKeyStateToString[s], len(tp.trustedKeys()))
+	}
+	tp.update(dnskeyRRset(t, []*testKSK{k1, k2}, k2), start.Add(hold+2*time.Hour), hold, hold)
+	if s := keyState(tp, k1); s != TAKeyValid {
+		t.Errorf("returning key state %s, want VALID", TAKeyStateToString[s])
+	}
+}
+
+func TestRfc5011UntrustedRRset(t *testing.T) {
+	k1, rogue, k2 := newTestKSK(t), newTestKSK(t), newTestKSK(t)
+	tr := &trustAnchorTracker{points: map[string]*trustPoint{}}
+	tr.bootstrap("example.", []*dns.DNSKEY{k1.dk}, time.Now())
+	tp := tr.points["example."]
+
+	// Signed only by a key we do not trust: nothing is added.
+	if _, err := tp.update(dnskeyRRset(t, []*testKSK{rogue, k2}, rogue), time.Now(), time.Hour, time.Hour); err == nil {
+		t.Fatal("RRset not signed by a trusted key accepted")
+	}
+	if len(tp.Keys) != 1 {
+		t.Errorf("%d keys tracked after an untrusted RRset, want 1", len(tp.Keys))
+	}
+}
+
+func TestRfc5011Revoke(t *testing.T) {
+	k1, k2 := newTestKSK(t), newTestKSK(t)
+	tr := &trustAnchorTracker{points: map[string]*trustPoint{}}
+	start := time.Now()
+	tr.bootstrap("example.", []*dns.DNSKEY{k1.dk, k2.dk}, start)
+	tp := tr.points["example."]
+	hold := 30 * 24 * time.Hour
+
+	// A revoked key must sign the RRset itself.
+	r1 := k1.revoked()
+	tp.update(dnskeyRRset(t, []*testKSK{r1, k2}, k2), start, hold, hold)
+	if s := keyState(tp, k1); s != TAKeyValid && s != TAKeyMissing {
+		t.Fatalf("revocation without self-signature accepted: %s", TAKeyStateToString[s])
+	}
+
+	tp.update(dnskeyRRset(t, []*testKSK{r1, k2}, r1, k2), start, hold, hold)
+	if s := keyState(tp, k1); s != TAKeyRevoked {
+		t.Fatalf("revoked key state %s, want REVOKED", TAKeyStateToString[s])
+	}
+	if keys := tp.trustedKeys(); len(keys) != 1 || keys[0].KeyTag() != k2.dk.KeyTag() {
+		t.Errorf("trusted keys after revocation: %v", keys)
+	}
+	// A revoked key never comes back, even if published without the bit.
+	tp.update(dnskeyRRset(t, []*testKSK{k1, k2}, k2), start.Add(time.Hour), hold, hold)
+	if s := keyState(tp, k1); s != TAKeyRevoked {
+		t.Errorf("revoked key state %s after republication, want REVOKED", TAKeyStateToString[s])
+	}
+	tp.update(dnskeyRRset(t, []*testKSK{k2}, k2), start.Add(hold), hold, hold)
+	if _, ok := tp.Keys[taKeyID(k1.dk)]; ok {
+		t.Error("revoked key not removed after the remove hold-down")
+	}
+}
+
+func TestRfc5011StateFile(t *testing.T) {
+	k1, k2, k3 := newTestKSK(t), newTestKSK(t), newTestKSK(t)
+	now := time.Now().Truncate(time.Second)
+	dir := t.TempDir()
+	anchors := filepath.Join(dir, "root.key")
+	anchorData := []byte(k1.dk.String() + "\n")
+	if err := os.WriteFile(anchors, anchorData, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The trust-anchor-file can not hold the state.
+	if _, err := newTrustAnchorTracker(ImrRfc5011Conf{Enabled: true, StateFile: anchors}, anchors); err == nil {
+		t.Error("state-file equal to the trust-anchor-file accepted")
+	}
+
+	// A plain trust-anchor-file has no state yet.
+	tr, err := newTrustAnchorTracker(ImrRfc5011Conf{Enabled: true}, anchors)
+	if err != nil || len(tr.points) != 0 {
+		t.Fatalf("plain trust-anchor-file: %d points, err %v", len(tr.points), err)
+	}
+	path := anchors + ".5011-state"
+	if tr.stateFile != path {
+		t.Errorf("state file %s, want %s", tr.stateFile, path)
+	}
+	tr.bootstrap("example.", []*dns.DNSKEY{k1.dk, k3.dk}, now)
+	tp := tr.points["example."]
+	tp.update(dnskeyRRset(t, []*testKSK{k1, k2, k3.revoked()}, k1, k3.revoked()), now, time.Hour, time.Hour)
+	tp.NextProbe = now.Add(time.Hour)
+	if err := tr.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := os.ReadFile(anchors); string(got) != string(anchorData) {
+		t.Errorf("trust-anchor-file rewritten:\n%s", got)
+	}
+
+	tr2, err := newTrustAnchorTracker(ImrRfc5011Conf{Enabled: true}, anchors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp2 := tr2.points["example."]
+	if tp2 == nil || len(tp2.Keys) != 3 || !tp2.NextProbe.Equal(now.Add(time.Hour)) {
+		t.Fatalf("reloaded trust point: %+v", tp2)
+	}
+	for _, c := range []struct {
+		k    *testKSK
+		want TAKeyState
+	}{{k1, TAKeyValid}, {k2, TAKeyAddPend}, {k3, TAKeyRevoked}} {
+		tk := tp2.Keys[taKeyID(c.k.dk)]
+		if tk == nil || tk.State != c.want || !tk.LastChange.Equal(now) {
+			t.Errorf("reloaded key %d: %+v, want state %s", c.k.dk.KeyTag(), tk, TAKeyStateToString[c.want])
+		}
+	}
+	if hd := tp2.Keys[taKeyID(k2.dk)].HoldDown; !hd.Equal(now.Add(time.Hour)) {
+		t.Errorf("add hold-down %v, want %v", hd, now.Add(time.Hour))
+	}
+
+	// To any other reader the file holds just the trusted keys.
+	_, keys, err := cache.LoadTrustAnchorsFromFile(path, nil)
+	if err != nil || len(keys) != 1 || keys[0].KeyTag() != k1.dk.KeyTag() {
+		t.Errorf("plain reader sees %d keys (err %v), want only the valid one", len(keys), err)
+	}
+
+	// Tracked keys replace the configured anchors.
+	ds := map[string][]*dns.DS{"example.": {k1.dk.ToDS(dns.SHA256)}}
+	dks := map[string][]*dns.DNSKEY{}
+	tr2.applyToAnchors(ds, dks)
+	if len(ds) != 0 || len(dks["example."]) != 1 {
+		t.Errorf("applyToAnchors: ds %v, dnskeys %v", ds, dks)
+	}
+}
+
+func TestProbeInterval(t *testing.T) {
+	now := time.Now()
+	k := newTestKSK(t)
+	rrset := dnskeyRRset(t, []*testKSK{k}, k) // TTL 1h, signatures expire in 10 days
+	if d := probeInterval(rrset, now, false); d != time.Hour {
+		t.Errorf("interval %v, want the 1h floor", d)
+	}
+	k.dk.Hdr.Ttl = 172800
+	if d := probeInterval(rrset, now, false); d != 24*time.Hour {
+		t.Errorf("interval %v, want half the TTL", d)
+	}
+	if d := probeInterval(rrset, now, true); d != 17280*time.Second {
+		t.Errorf("retry %v, want a tenth of the TTL", d)
+	}
+	if d := probeInterval(nil, now, false); d != 15*24*time.Hour {
+		t.Errorf("interval without RRset %v, want 15 days", d)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// The cache snapshot (imrengine.cache-snapshot) lets tdns-imr restart warm:
+// cached RRsets, validated DNSKEYs, and what was learned about each
+// nameserver (addresses, transports and weights, RTTs, backoffs, TLSA) are
+// written to disk and restored at startup. See cache/snapshot.go for the
+// format and the restore rules.
+
+const defaultCacheSnapshotInterval = 10 * time.Minute
+
+// loadCacheSnapshot restores the snapshot, if one is configured and exists.
+// Called once priming, the NTAs and local zones (InitImrEngine) and the
+// trust anchors (ImrEngine) are set up, so that what the configuration
+// says wins over the snapshot.
+func (imr *Imr) loadCacheSnapshot() {
+	file := imr.snapshotFile
+	if file == "" {
+		return
+	}
+	start := time.Now()
+	stats, err := imr.Cache.LoadSnapshot(file)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		lgImr.Info("no cache snapshot to restore", "file", file)
+	case err != nil:
+		lgImr.Warn("cache snapshot not restored", "file", file, "err", err)
+	default:
+		lgImr.Info("cache snapshot restored", "file", file, "rrsets", stats.RRsets, "dnskeys", stats.Dnskeys,
+			"servers", stats.Servers, "zones", stats.Zones, "tlsa", stats.TLSA, "skipped", stats.Skipped,
+			"unvalidated", stats.Demoted, "elapsed", time.Since(start))
+	}
+}
+
+// saveCacheSnapshot writes the snapshot, if one is configured.
+func (imr *Imr) saveCacheSnapshot() {
+	file := imr.snapshotFile
+	if file == "" {
+		return
+	}
+	start := time.Now()
+	stats, err := imr.Cache.SaveSnapshot(file)
+	if err != nil {
+		lgImr.Error("failed to write cache snapshot", "file", file, "err", err)
+		return
+	}
+	lgImr.Debug("cache snapshot written", "file", file, "rrsets", stats.RRsets, "servers", stats.Servers,
+		"elapsed", time.Since(start))
+}
+
+// runCacheSnapshots writes the snapshot every interval. The final snapshot
+// at shutdown is written by ImrEngine.
+func (imr *Imr) runCacheSnapshots(ctx context.Context) {
+	if imr.snapshotFile == "" || imr.snapshotInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(imr.snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			imr.saveCacheSnapshot()
+		}
+	}
+}
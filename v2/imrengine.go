@@ -13,6 +13,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/viper"
@@ -67,6 +68,32 @@ type Imr struct {
 	// via RefreshDnssecPolicy. The empty zero value behaves as
 	// DNSKEYTransportUseDSSignal.
 	dnskeyTransport DNSKEYTransportPolicy
+	// queryFlights and iterFlights coalesce concurrent identical lookups at
+	// the ImrQuery and IterativeDNSQuery level; prefetching holds the keys
+	// being refreshed ahead of expiry. See imr_coalesce.go.
+	queryFlights   flightGroup[*ImrResponse]
+	iterFlights    flightGroup[iterativeResult]
+	prefetching    sync.Map
+	prefetched     atomic.Uint64
+	prefetchFailed atomic.Uint64
+	// forwards are the forward zones, most specific first. See imr_forward.go.
+	forwards []*ForwardZone
+	// access is the client access control of the listeners; nil means no
+	// checks (an IMR used only internally). See imr_access.go.
+	access *imrAccess
+	// taTracker does RFC 5011 maintenance of the trust anchors; nil when
+	// imrengine.rfc5011 is off. See imr_rfc5011.go.
+	taTracker *trustAnchorTracker
+	// ntaLifetime and ntaProbeInterval configure the negative trust
+	// anchors, which are kept in Cache.NTAs. See imr_nta.go.
+	ntaLifetime      time.Duration
+	ntaProbeInterval time.Duration
+	// localZones are answered from local data. See imr_localzone.go.
+	localZones *localZones
+	// snapshotFile and snapshotInterval configure the cache snapshot;
+	// no file means no snapshot. See imr_snapshot.go.
+	snapshotFile     string
+	snapshotInterval time.Duration
 }
 
 func (imr *Imr) isLargeAlgorithm(alg uint8) bool {
@@ -156,6 +183,7 @@ func (conf *Config) InitImrEngine(ctx context.Context, quiet bool) error {
 		requireDnssec = *conf.Imr.RequireDnssecValidation
 	}
 	LoadImrTuningDefaults(&conf.Imr.Tuning)
+	rrcache.RRsets.SetBudget(conf.Imr.Tuning.Cache.Budget())
 	cache.SetBackoffPolicy(cache.BackoffPolicy{
 		FirstFailure:   conf.Imr.Tuning.Backoff.FirstFailure,
 		MaxFailure:     conf.Imr.Tuning.Backoff.MaxFailure,
@@ -241,6 +269,54 @@ func (conf *Config) InitImrEngine(ctx context.Context, quiet bool) error {
 		}
 	}
 
+	imr.snapshotFile = conf.Imr.CacheSnapshot.File
+	imr.snapshotInterval = conf.Imr.CacheSnapshot.Interval
+	if imr.snapshotInterval == 0 {
+		imr.snapshotInterval = defaultCacheSnapshotInterval
+	}
+
+	forwards, err := buildForwardZones(conf.Imr.Forwards)
+	if err != nil {
+		return fmt.Errorf("InitImrEngine: %w", err)
+	}
+	for _, fz := range forwards {
+		var servers []string
+		for name, as := range fz.Servers {
+			servers = append(servers, name+" ("+strings.Join(as.GetAddrs(), ", ")+")")
+		}
+		sort.Strings(servers)
+		lgImr.Info("adding forward zone", "zone", fz.Zone, "first", fz.First, "forwarders", strings.Join(servers, ", "))
+	}
+	imr.forwards = forwards
+
+	access, err := buildImrAccess(conf.Imr.Access)
+	if err != nil {
+		return fmt.Errorf("InitImrEngine: %w", err)
+	}
+	if len(conf.Imr.Access.AllowQuery) == 0 {
+		lgImr.Info("no access.allow-query configured, serving localhost and private networks only")
+	}
+	if access.limiter != nil {
+		lgImr.Info("client rate limiting enabled", "qps", access.limiter.rate, "burst", access.limiter.burst,
+			"v4prefix", access.limiter.v4, "v6prefix", access.limiter.v6)
+	}
+	imr.access = access
+
+	if err := imr.initNTAs(conf.Imr.Nta); err != nil {
+		return fmt.Errorf("InitImrEngine: %w", err)
+	}
+	if err := imr.initLocalZones(conf.Imr.LocalZones, conf.Imr.LocalData); err != nil {
+		return fmt.Errorf("InitImrEngine: %w", err)
+	}
+
+	if conf.Imr.Rfc5011.Enabled {
+		tracker, err := newTrustAnchorTracker(conf.Imr.Rfc5011, strings.TrimSpace(conf.Imr.TrustAnchorFile))
+		if err != nil {
+			return fmt.Errorf("InitImrEngine: %w", err)
+		}
+		imr.taTracker = tracker
+	}
+
 	conf.Internal.ImrEngine = imr
 	Globals.ImrEngine = imr
 	lgImr.Info("InitImrEngine: IMR initialized and available")
@@ -287,6 +363,11 @@ func (conf *Config) ImrEngine(ctx context.Context, quiet bool) error {
 	if err := imr.initializeImrTrustAnchors(ctx, conf); err != nil {
 		lgImr.Warn("trust anchor initialization failed", "err", err)
 	}
+	imr.loadCacheSnapshot()
+
+	go imr.runRfc5011(ctx)
+	go imr.runNTAProbes(ctx)
+	go imr.runCacheSnapshots(ctx)
 
 	// Start the ImrEngine (i.e. the recursive nameserver responding to queries with RD bit set)
 	go imr.StartImrEngineListeners(ctx, conf)
@@ -295,6 +376,7 @@ func (conf *Config) ImrEngine(ctx context.Context, quiet bool) error {
 		select {
 		case <-ctx.Done():
 			lgImr.Info("terminating (active mode, context cancelled)")
+			imr.saveCacheSnapshot()
 			return nil
 		case rrq, ok := <-recursorch:
 			if !ok {
@@ -465,30 +547,11 @@ func (imr *Imr) ImrQuery(ctx context.Context, qname string, qtype uint16, qclass
 		defer cancel()
 	}
 
-	maxiter := 12
-
 	resp := ImrResponse{
 		Validated: false,
 		Msg:       "ImrEngine: request to look up a RRset",
 	}
 
-	// validateResponse attempts DNSSEC validation of the response RRset.
-	validateResponse := func() {
-		if resp.RRset == nil || len(resp.RRset.RRs) == 0 {
-			return
-		}
-		vstate, err := imr.Cache.ValidateRRsetWithParentZone(ctx, resp.RRset,
-			imr.IterativeDNSQueryFetcher(), imr.ParentZone)
-		if err != nil {
-			lgImr.Debug("ImrQuery: DNSSEC validation failed", "qname", qname, "qtype", dns.TypeToString[qtype], "err", err)
-			return
-		}
-		if vstate == cache.ValidationStateSecure {
-			resp.Validated = true
-			lgImr.Debug("ImrQuery: DNSSEC validated", "qname", qname, "qtype", dns.TypeToString[qtype])
-		}
-	}
-
 	// If a response channel is provided, use it to send responses
 	if respch != nil {
 		defer func() {
@@ -516,7 +579,10 @@ func (imr *Imr) ImrQuery(ctx context.Context, qname string, qtype uint16, qclass
 			if crrset.State == cache.ValidationStateSecure {
 				resp.Validated = true
 			} else {
-				validateResponse()
+				imr.validateImrResponse(ctx, &resp, qname, qtype)
+			}
+			if crrset.Context == cache.ContextAnswer {
+				imr.maybePrefetch(qname, qtype, crrset)
 			}
 			return &resp, nil
 		case cache.ContextReferral, cache.ContextGlue, cache.ContextHint:
@@ -537,6 +603,50 @@ func (imr *Imr) ImrQuery(ctx context.Context, qname string, qtype uint16, qclass
 		}
 	}
 
+	// ImrQuery always validates, so concurrent callers share a walk keyed
+	// as a DO=1, CD=0 query.
+	r, _, err := imr.queryFlights.do(ctx, flightKey(qname, qtype, true, false, false), imr.Tuning.QueryBudget, func(ctx context.Context) (*ImrResponse, error) {
+		return imr.imrResolve(ctx, qname, qtype)
+	})
+	if r != nil {
+		resp = *r
+	} else if err != nil {
+		resp.Error = true
+		resp.ErrorMsg = err.Error()
+	}
+	return &resp, err
+}
+
+// validateImrResponse attempts DNSSEC validation of the response RRset.
+func (imr *Imr) validateImrResponse(ctx context.Context, resp *ImrResponse, qname string, qtype uint16) {
+	if resp.RRset == nil || len(resp.RRset.RRs) == 0 {
+		return
+	}
+	vstate, err := imr.Cache.ValidateRRsetWithParentZone(ctx, resp.RRset,
+		imr.IterativeDNSQueryFetcher(), imr.ParentZone)
+	if err != nil {
+		lgImr.Debug("ImrQuery: DNSSEC validation failed", "qname", qname, "qtype", dns.TypeToString[qtype], "err", err)
+		return
+	}
+	if vstate == cache.ValidationStateSecure {
+		resp.Validated = true
+		lgImr.Debug("ImrQuery: DNSSEC validated", "qname", qname, "qtype", dns.TypeToString[qtype])
+	}
+}
+
+// imrResolve is the cache-miss half of ImrQuery: it walks from the closest
+// known zone until it has an answer or a negative response.
+func (imr *Imr) imrResolve(ctx context.Context, qname string, qtype uint16) (*ImrResponse, error) {
+	maxiter := 12
+
+	resp := ImrResponse{
+		Validated: false,
+		Msg:       "ImrEngine: request to look up a RRset",
+	}
+	validateResponse := func() {
+		imr.validateImrResponse(ctx, &resp, qname, qtype)
+	}
+
 	for {
 		if maxiter <= 0 {
 			lgImr.Warn("ImrQuery: max iterations reached, giving up")
@@ -737,6 +847,9 @@ func (imr *Imr) resolveNSAddresses(ctx context.Context, bestmatch string, qname
 }
 
 func (imr *Imr) ImrResponder(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, qname string, qtype uint16, msgoptions *edns0.MsgOptions) {
+	if r.IsEdns0() != nil && imr.Cache.NTAs.Covering(qname, time.Now()) != "" {
+		w = &ntaResponseWriter{ResponseWriter: w, dnssecOK: msgoptions.DO}
+	}
 	m := new(dns.Msg)
 	m.RecursionAvailable = true
 
@@ -862,6 +975,7 @@ func (imr *Imr) ImrResponder(ctx context.Context, w dns.ResponseWriter, r *dns.M
 				w.WriteMsg(m)
 				return
 			}
+			imr.maybePrefetch(qname, qtype, crrset)
 			m.SetRcode(r, dns.RcodeSuccess)
 			m.Answer = crrset.RRset.RRs
 			if msgoptions.DO {
@@ -941,7 +1055,7 @@ func (imr *Imr) ImrResponder(ctx context.Context, w dns.ResponseWriter, r *dns.M
 			}
 
 			lgImr.Debug("ImrResponder: sending query to authservers", "qname", qname, "qtype", dns.TypeToString[qtype], "count", len(authservers), "zone", bestmatch)
-			rrset, rcode, context, transport, err := imr.IterativeDNSQuery(ctx, qname, qtype, authservers, false, msgoptions.PR)
+			rrset, rcode, context, transport, err := imr.sharedIterativeDNSQuery(ctx, qname, qtype, authservers, false, msgoptions.DO, msgoptions.CD, msgoptions.PR)
 			// log.Printf("Recursor: response from AuthDNSQuery: rcode: %d, err: %v", rrset, rcode, err)
 			if err != nil {
 				// If PR flag is set and we can't get encrypted transport, return SERVFAIL+EDE
@@ -1290,11 +1404,9 @@ func (imr *Imr) StartImrEngineListeners(ctx context.Context, conf *Config) error
 		return nil
 	}
 
-	ImrHandler := imr.createImrHandler(ctx, conf)
-
 	// Create a local ServeMux for ImrEngine to avoid conflicts with other engines
 	imrMux := dns.NewServeMux()
-	imrMux.HandleFunc(".", ImrHandler)
+	imrMux.HandleFunc(".", imr.createImrHandler(ctx, conf, "do53"))
 
 	if CaseFoldContains(conf.Imr.Transports, "do53") {
 		lgImr.Info("starting Do53 listeners", "addresses", addresses)
@@ -1386,7 +1498,7 @@ func (imr *Imr) StartImrEngineListeners(ctx context.Context, conf *Config) error
 		addresses = tmp
 
 		if CaseFoldContains(conf.Imr.Transports, "dot") {
-			err := DnsDoTEngine(ctx, conf, addresses, &cert, ImrHandler, false)
+			err := DnsDoTEngine(ctx, conf, addresses, &cert, imr.createImrHandler(ctx, conf, "dot"), false)
 			if err != nil {
 				lgImr.Error("failed to setup DoT server", "err", err)
 			}
@@ -1395,7 +1507,7 @@ func (imr *Imr) StartImrEngineListeners(ctx context.Context, conf *Config) error
 		}
 
 		if CaseFoldContains(conf.Imr.Transports, "doh") {
-			err := DnsDoHEngine(ctx, conf, addresses, certFile, keyFile, ImrHandler)
+			err := DnsDoHEngine(ctx, conf, addresses, certFile, keyFile, imr.createImrHandler(ctx, conf, "doh"))
 			if err != nil {
 				lgImr.Error("failed to setup DoH server", "err", err)
 			}
@@ -1404,7 +1516,7 @@ func (imr *Imr) StartImrEngineListeners(ctx context.Context, conf *Config) error
 		}
 
 		if CaseFoldContains(conf.Imr.Transports, "doq") {
-			err := DnsDoQEngine(ctx, conf, addresses, &cert, ImrHandler)
+			err := DnsDoQEngine(ctx, conf, addresses, &cert, imr.createImrHandler(ctx, conf, "doq"))
 			if err != nil {
 				lgImr.Error("failed to setup DoQ server", "err", err)
 			}
@@ -1847,7 +1959,7 @@ func (imr *Imr) initializeImrTrustAnchors(ctx context.Context, conf *Config) err
 	taDS := strings.TrimSpace(conf.Imr.TrustAnchorDS)
 	taDNSKEY := strings.TrimSpace(conf.Imr.TrustAnchorDNSKEY)
 	taFile := strings.TrimSpace(conf.Imr.TrustAnchorFile)
-	if taDS == "" && taDNSKEY == "" && taFile == "" {
+	if taDS == "" && taDNSKEY == "" && taFile == "" && imr.taTracker == nil {
 		return nil
 	}
 
@@ -1856,6 +1968,10 @@ func (imr *Imr) initializeImrTrustAnchors(ctx context.Context, conf *Config) err
 	if err != nil {
 		return err
 	}
+	// Zones with RFC 5011 state use their tracked keys instead
+	if imr.taTracker != nil {
+		imr.taTracker.applyToAnchors(dsByName, dnskeysByName)
+	}
 
 	// Add direct DNSKEY trust anchors to cache first
 	imr.addDirectDNSKEYTrustAnchors(dnskeysByName)
@@ -1892,10 +2008,46 @@ func (imr *Imr) initializeImrTrustAnchors(ctx context.Context, conf *Config) err
 		}
 	}
 
+	if imr.taTracker != nil {
+		imr.bootstrapRfc5011(anchorNames)
+	}
+
 	return nil
 }
 
-func (imr *Imr) createImrHandler(ctx context.Context, conf *Config) func(w dns.ResponseWriter, r *dns.Msg) {
+// bootstrapRfc5011 starts RFC 5011 tracking of the anchor zones that have
+// no saved state, trusting the keys that the configured anchors validated.
+func (imr *Imr) bootstrapRfc5011(anchorNames []string) {
+	t := imr.taTracker
+	now := time.Now()
+	added := false
+	for _, zone := range anchorNames {
+		var keys []*dns.DNSKEY
+		for item := range imr.DnskeyCache.Map.IterBuffered() {
+			if item.Val.Name == zone && item.Val.TrustAnchor {
+				dk := item.Val.Dnskey
+				keys = append(keys, &dk)
+			}
+		}
+		if t.bootstrap(zone, keys, now) {
+			added = true
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tp := range t.points {
+		imr.syncTrustAnchors(tp)
+	}
+	if added {
+		if err := t.save(); err != nil {
+			lgImr.Error("RFC 5011: failed to save state", "file", t.stateFile, "err", err)
+		}
+	}
+}
+
+// createImrHandler returns the handler for queries arriving over transport
+// ("do53", "dot", "doh" or "doq"), which selects the access rules.
+func (imr *Imr) createImrHandler(ctx context.Context, conf *Config, transport string) func(w dns.ResponseWriter, r *dns.Msg) {
 	//	dnsupdateq := conf.Internal.DnsUpdateQ
 	//	dnsnotifyq := conf.Internal.DnsNotifyQ
 	//	kdb := conf.Internal.KeyDB
@@ -1911,6 +2063,11 @@ func (imr *Imr) createImrHandler(ctx context.Context, conf *Config) func(w dns.R
 		qtype := r.Question[0].Qtype
 		lgImr.Debug("received query", "qname", qname, "qtype", dns.TypeToString[qtype], "from", w.RemoteAddr(), "opcode", dns.OpcodeToString[r.Opcode])
 
+		group, ok := imr.checkImrClientAccess(w, r, transport)
+		if !ok {
+			return
+		}
+
 		switch r.Opcode {
 		case dns.OpcodeNotify, dns.OpcodeUpdate:
 			m := new(dns.Msg)
@@ -1927,9 +2084,22 @@ func (imr *Imr) createImrHandler(ctx context.Context, conf *Config) func(w dns.R
 				return
 			}
 
+			if group != nil && group.RequireDnssec {
+				msgoptions.CD = false
+			}
+
+			// Local zones come first: their names are never resolved. A
+			// group with blocking turned off skips the blocking zones and
+			// the blocking query hooks below.
+			blocking := group == nil || group.Blocking
+			if m := imr.localResponse(r, qname, qtype, blocking); m != nil {
+				w.WriteMsg(m)
+				return
+			}
+
 			// Run IMR client query hooks (dependency analysis, RPZ, etc.)
 			hookCtx := ctx
-			for _, hook := range getImrClientQueryHooks() {
+			for _, hook := range getImrClientQueryHooks(blocking) {
 				newCtx, response := hook(hookCtx, w, r, qname, qtype, msgoptions)
 				if newCtx != nil {
 					hookCtx = newCtx
@@ -1939,6 +2109,9 @@ func (imr *Imr) createImrHandler(ctx context.Context, conf *Config) func(w dns.R
 					return
 				}
 			}
+			if group != nil && group.RequireDnssec {
+				w = &requireDnssecWriter{ResponseWriter: w, dnssecOK: msgoptions.DO}
+			}
 			imr.ImrResponder(hookCtx, w, r, qname, qtype, msgoptions)
 			return
 
@@ -25,42 +25,7 @@ const (
 // KeyStateWorker runs periodic checks on DNSSEC key states and performs
 // automatic transitions and standby key maintenance.
 func KeyStateWorker(ctx context.Context, conf *Config) error {
-	kasp := &conf.Dnssec.Kasp
-
-	propagationDelay := defaultPropagationDelay
-	if kasp.PropagationDelay != "" {
-		if d, err := time.ParseDuration(kasp.PropagationDelay); err == nil {
-			if d > 0 {
-				propagationDelay = d
-			} else {
-				lgSigner.Warn("kasp.propagation_delay must be positive, using default", "value", kasp.PropagationDelay, "default", defaultPropagationDelay)
-			}
-		} else {
-			lgSigner.Warn("invalid kasp.propagation_delay, using default", "value", kasp.PropagationDelay, "default", defaultPropagationDelay, "err", err)
-		}
-	}
-
-	checkInterval := defaultCheckInterval
-	if kasp.CheckInterval != "" {
-		if d, err := time.ParseDuration(kasp.CheckInterval); err == nil {
-			if d > 0 {
-				checkInterval = d
-			} else {
-				lgSigner.Warn("kasp.check_interval must be positive, using default", "value", kasp.CheckInterval, "default", defaultCheckInterval)
-			}
-		} else {
-			lgSigner.Warn("invalid kasp.check_interval, using default", "value", kasp.CheckInterval, "default", defaultCheckInterval, "err", err)
-		}
-	}
-
-	standbyZskCount := defaultStandbyZskCount
-	if kasp.StandbyZskCount > 0 {
-		standbyZskCount = kasp.StandbyZskCount
-	}
-	standbyKskCount := defaultStandbyKskCount
-	if kasp.StandbyKskCount > 0 {
-		standbyKskCount = kasp.StandbyKskCount
-	}
+	propagationDelay, checkInterval, standbyZskCount, standbyKskCount := kaspTimings(&conf.Dnssec.Kasp)
 
 	kdb := conf.Internal.KeyDB
 	if kdb == nil {
@@ -103,14 +68,61 @@ func KeyStateWorker(ctx context.Context, conf *Config) error {
 	}
 }
 
+// kaspTimings resolves the kasp block into the KeyStateWorker's
+// propagation delay, tick interval and standby key counts, applying the
+// defaults for unset or invalid values. Shared with the rollover
+// simulator so a simulation runs with the daemon's timings.
+func kaspTimings(kasp *KaspConf) (propagationDelay, checkInterval time.Duration, standbyZskCount, standbyKskCount int) {
+	propagationDelay = defaultPropagationDelay
+	if kasp.PropagationDelay != "" {
+		if d, err := time.ParseDuration(kasp.PropagationDelay); err == nil {
+			if d > 0 {
+				propagationDelay = d
+			} else {
+				lgSigner.Warn("kasp.propagation_delay must be positive, using default", "value", kasp.PropagationDelay, "default", defaultPropagationDelay)
+			}
+		} else {
+			lgSigner.Warn("invalid kasp.propagation_delay, using default", "value", kasp.PropagationDelay, "default", defaultPropagationDelay, "err", err)
+		}
+	}
+
+	checkInterval = defaultCheckInterval
+	if kasp.CheckInterval != "" {
+		if d, err := time.ParseDuration(kasp.CheckInterval); err == nil {
+			if d > 0 {
+				checkInterval = d
+			} else {
+				lgSigner.Warn("kasp.check_interval must be positive, using default", "value", kasp.CheckInterval, "default", defaultCheckInterval)
+			}
+		} else {
+			lgSigner.Warn("invalid kasp.check_interval, using default", "value", kasp.CheckInterval, "default", defaultCheckInterval, "err", err)
+		}
+	}
+
+	standbyZskCount = defaultStandbyZskCount
+	if kasp.StandbyZskCount > 0 {
+		standbyZskCount = kasp.StandbyZskCount
+	}
+	standbyKskCount = defaultStandbyKskCount
+	if kasp.StandbyKskCount > 0 {
+		standbyKskCount = kasp.StandbyKskCount
+	}
+	return propagationDelay, checkInterval, standbyZskCount, standbyKskCount
+}
+
 // checkAndTransitionKeys performs all periodic key state checks:
 // 1. published → standby (time-based)
 // 2. retired → removed (time-based)
 // 3. maintain standby key count (generate new keys as needed)
 func checkAndTransitionKeys(ctx context.Context, conf *Config, kdb *KeyDB, propagationDelay time.Duration, standbyZskCount, standbyKskCount int) {
-	now := time.Now()
+	keyStatePass(ctx, conf, kdb, time.Now(), propagationDelay, standbyZskCount, standbyKskCount, RolloverParentHooks{})
+}
 
-	rolloverAutomatedForAllZones(ctx, conf, kdb, propagationDelay, now)
+// keyStatePass is one KeyStateWorker pass at now. The rollover simulator
+// drives the same pass on its virtual clock, with parent standing in for
+// the parent zone.
+func keyStatePass(ctx context.Context, conf *Config, kdb *KeyDB, now time.Time, propagationDelay time.Duration, standbyZskCount, standbyKskCount int, parent RolloverParentHooks) {
+	rolloverAutomatedForAllZones(ctx, conf, kdb, propagationDelay, now, parent)
 	TransitionRolloverKskDsPublishedToPublished(ctx, conf, kdb, now, propagationDelay)
 	TransitionRolloverKskPublishedToStandby(ctx, conf, kdb, now, propagationDelay)
 	promoteStandbyKskBootstrapAll(conf, kdb)
@@ -299,7 +311,7 @@ func maintainStandbyKeys(conf *Config, kdb *KeyDB, standbyZskCount, standbyKskCo
 			capStandbyZsksByCount(kdb, zoneName, standbyZskCount)
 		}
 
-		if standbyKskCount > 0 && (zd.DnssecPolicy == nil || zd.DnssecPolicy.Rollover.Method == RolloverMethodNone) {
+		if standbyKskCount > 0 && !zd.offlineKSK() && (zd.DnssecPolicy == nil || zd.DnssecPolicy.Rollover.Method == RolloverMethodNone) {
 			// KSK is always per-(role,algorithm): relaxed mode's role-only
 			// discipline is a ZSK-roll property (the ZSK signs the whole zone);
 			// a KSK algorithm change is refused, not gradually rolled, here.
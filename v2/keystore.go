@@ -306,6 +306,9 @@ SELECT zonename, state, keyid, algorithm, creator, privatekey, keyrr FROM Sig0Ke
 		resp.Msg = fmt.Sprintf("Exported SIG(0) key %s keyid %d", zonename, keyid)
 
 	case "setstate":
+		// The old state is read only for the audit trail; a missing key is
+		// reported below from RowsAffected.
+		_ = tx.QueryRow("SELECT state FROM Sig0KeyStore WHERE zonename=? AND keyid=?", kp.Keyname, kp.Keyid).Scan(&resp.OldState)
 		res, err = tx.Exec(setStateSig0KeySql, kp.State, kp.Keyname, kp.Keyid)
 		if err != nil {
 			lgSigner.Error("failed to set SIG(0) key state", "err", err)
@@ -598,6 +601,7 @@ SELECT zonename, state, keyid, flags, algorithm, creator, privatekey, keyrr FROM
 			resp.ErrorMsg = fmt.Sprintf("invalid dnssec state %q", kp.State)
 			return &resp, fmt.Errorf("invalid dnssec state %q", kp.State)
 		}
+		_ = tx.QueryRow("SELECT state FROM DnssecKeyStore WHERE zonename=? AND keyid=?", kp.Keyname, kp.Keyid).Scan(&resp.OldState)
 		res, err = tx.Exec(setStateDnskeySql, kp.State, kp.Keyname, kp.Keyid)
 		if err != nil {
 			lgSigner.Error("failed to set DNSKEY state", "err", err)
@@ -606,6 +610,12 @@ SELECT zonename, state, keyid, flags, algorithm, creator, privatekey, keyrr FROM
 			rows, _ := res.RowsAffected()
 			if rows > 0 {
 				resp.Msg = fmt.Sprintf("Updated %d rows", rows)
+				zone, keyid, state := kp.Keyname, kp.Keyid, kp.State
+				tx.afterCommit(func() {
+					emitEvent(EventKeyState, zone, map[string]interface{}{
+						"keyid": keyid, "new_state": state, "detail": "setstate",
+					})
+				})
 			} else {
 				resp.Msg = fmt.Sprintf("Key with name \"%s\" and keyid %d not found.", kp.Keyname, kp.Keyid)
 			}
@@ -694,6 +704,12 @@ SELECT zonename, state, keyid, flags, algorithm, privatekey, keyrr FROM DnssecKe
 			return &resp, err
 		}
 		resp.Msg = fmt.Sprintf("Key %s (keyid %d) transitioned to %s", kp.Keyname, kp.Keyid, targetState)
+		oldstate, delKeyid := state, kp.Keyid
+		tx.afterCommit(func() {
+			emitEvent(EventKeyState, zone, map[string]interface{}{
+				"keyid": delKeyid, "old_state": oldstate, "new_state": targetState, "detail": "delete",
+			})
+		})
 		needsRepublish = true
 
 	case "clear":
@@ -734,15 +750,19 @@ SELECT zonename, state, keyid, flags, algorithm, privatekey, keyrr FROM DnssecKe
 		}
 		generated = append(generated, fmt.Sprintf("ZSK %d (active)", zskPkc.KeyId))
 
-		// Generate 1 active KSK (same abort-and-rollback on failure).
-		kskPkc, _, err := kdb.GenerateKeypair(kp.Zone, "clear-regen", DnskeyStateActive, dns.TypeDNSKEY, zd.DnssecPolicy.KSKAlgorithm, "KSK", tx)
-		if err != nil {
-			lgSigner.Error("clear: failed to generate active KSK", "zone", kp.Zone, "err", err)
-			resp.Error = true
-			resp.ErrorMsg = fmt.Sprintf("clear: failed to generate active KSK for zone %s: %v", kp.Zone, err)
-			return &resp, err
+		// Generate 1 active KSK (same abort-and-rollback on failure). An
+		// offline KSK is not ours to generate: the zone needs a new KSR.
+		var kskPkc *PrivateKeyCache
+		if !zd.offlineKSK() {
+			kskPkc, _, err = kdb.GenerateKeypair(kp.Zone, "clear-regen", DnskeyStateActive, dns.TypeDNSKEY, zd.DnssecPolicy.KSKAlgorithm, "KSK", tx)
+			if err != nil {
+				lgSigner.Error("clear: failed to generate active KSK", "zone", kp.Zone, "err", err)
+				resp.Error = true
+				resp.ErrorMsg = fmt.Sprintf("clear: failed to generate active KSK for zone %s: %v", kp.Zone, err)
+				return &resp, err
+			}
+			generated = append(generated, fmt.Sprintf("KSK %d (active)", kskPkc.KeyId))
 		}
-		generated = append(generated, fmt.Sprintf("KSK %d (active)", kskPkc.KeyId))
 
 		// Strip the served RRSIGs left behind by the just-deleted keys: any
 		// RRSIG whose keytag is not one of the regenerated keys is an orphan
@@ -823,6 +843,13 @@ SELECT zonename, state, keyid, flags, algorithm, privatekey, keyrr FROM DnssecKe
 			needsRepublish = true
 		}
 
+	case "ksr-export", "skr-import", "skr-list":
+		ksrResp, err := kdb.dnssecOfflineKsk(tx, kp)
+		if err != nil {
+			return ksrResp, err
+		}
+		resp = *ksrResp
+
 	case "purge":
 		purgeResp, err := kdb.dnssecKeyPurge(tx, kp)
 		if err != nil {
@@ -1244,7 +1271,7 @@ func (kdb *KeyDB) PromoteDnssecKey(zonename string, keyid uint16, oldstate, news
 		return fmt.Errorf("key with keyid %d in zone %s is not in state %s", keyid, zonename, oldstate)
 	}
 
-	now := time.Now().UTC().Format(time.RFC3339)
+	now := keyStateNow().UTC().Format(time.RFC3339)
 	var res sql.Result
 	if newstate == DnskeyStateActive {
 		res, err = tx.Exec(`UPDATE DnssecKeyStore SET state=?, active_at=? WHERE zonename=? AND keyid=? AND state=?`,
@@ -1263,6 +1290,7 @@ func (kdb *KeyDB) PromoteDnssecKey(zonename string, keyid uint16, oldstate, news
 	if rowsAffected == 0 {
 		return fmt.Errorf("no rows updated, key with keyid %d in zone %s might not be in state %s", keyid, zonename, oldstate)
 	}
+	recordKeyStateTx(tx, zonename, keyid, oldstate, newstate, "promote")
 
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("commit failed: %w", err)
@@ -1491,7 +1519,7 @@ func UpdateDnssecKeyStateTx(tx *Tx, kdb *KeyDB, zonename string, keyid uint16, n
 		return fmt.Errorf("error querying DnssecKeyStore: %v", err)
 	}
 
-	now := time.Now().UTC().Format(time.RFC3339)
+	now := keyStateNow().UTC().Format(time.RFC3339)
 
 	var res sql.Result
 	switch newstate {
@@ -1519,9 +1547,35 @@ func UpdateDnssecKeyStateTx(tx *Tx, kdb *KeyDB, zonename string, keyid uint16, n
 	}
 
 	lgSigner.Info("DNSKEY state updated", "zone", zonename, "keyid", keyid, "oldstate", oldstate, "newstate", newstate)
+	recordKeyStateTx(tx, zonename, keyid, oldstate, newstate, "")
 	return nil
 }
 
+// recordKeyStateTx journals an engine-driven DNSKEY state transition in the
+// transaction that performs it and queues a key.state event for after the
+// commit. A failed audit insert is logged, not returned: the journal must
+// never be the reason a key transition fails.
+func recordKeyStateTx(tx *Tx, zonename string, keyid uint16, oldstate, newstate, detail string) {
+	tx.afterCommit(func() {
+		emitEvent(EventKeyState, zonename, map[string]interface{}{
+			"keyid": keyid, "old_state": oldstate, "new_state": newstate, "detail": detail,
+		})
+	})
+	err := recordAuditTx(tx, AuditEntry{
+		Actor:    AuditActor{Type: AuditActorEngine, Name: "key-state"},
+		Source:   AuditSourceKeyState,
+		Zone:     zonename,
+		Action:   "dnskey-state",
+		KeyID:    keyid,
+		OldState: oldstate,
+		NewState: newstate,
+		Detail:   detail,
+	})
+	if err != nil {
+		lgAudit.Error("failed to record DNSKEY state transition", "zone", zonename, "keyid", keyid, "newstate", newstate, "err", err)
+	}
+}
+
 // RolloverKey performs a manual key rollover for the specified zone and key type.
 // It swaps the oldest standby key to active and the current active key to retired.
 // Returns the old active keyid and the new active keyid.
@@ -1583,7 +1637,7 @@ func (kdb *KeyDB) RolloverKey(zonename string, keytype string, tx *Tx) (uint16,
 		localtx = true
 	}
 
-	now := time.Now().UTC().Format(time.RFC3339)
+	now := keyStateNow().UTC().Format(time.RFC3339)
 	var txErr error
 	committed := false
 	defer func() {
@@ -1622,6 +1676,9 @@ func (kdb *KeyDB) RolloverKey(zonename string, keytype string, tx *Tx) (uint16,
 		return 0, 0, fmt.Errorf("active→retired transition failed: %w", txErr)
 	}
 
+	recordKeyStateTx(tx, zonename, standbyKey.KeyTag, DnskeyStateStandby, DnskeyStateActive, keytype+" rollover")
+	recordKeyStateTx(tx, zonename, activeKey.KeyTag, DnskeyStateActive, DnskeyStateRetired, keytype+" rollover")
+
 	if localtx {
 		if err := tx.Commit(); err != nil {
 			txErr = err
@@ -0,0 +1,591 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package tdns
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// KSK algorithm rollover (docs/2026-07-01-ksk-alg-rollover-parallel-fifo-design.md).
+//
+// change-policy binds a policy whose KSK algorithm differs from the active
+// KSK's. The auto-rollover engine then runs the RFC 6781 §4.1.4 double-signature
+// ordering on top of its normal DS push / observe phases:
+//
+//	new-rrsigs   a new-algorithm KSK is minted directly into active but kept
+//	             out of the DNSKEY RRset: the RRsets the KSK signs carry
+//	             signatures of both algorithms while only the old DNSKEY is
+//	             published. The engine waits kasp.propagation_delay + the
+//	             maximum zone TTL, so that no cache holds those RRsets without
+//	             a new-algorithm signature once the new DNSKEY appears.
+//	double-sign  the new KSK's DNSKEY is published: the DNSKEY RRset now
+//	             carries both KSKs and is signed by both. After
+//	             kasp.propagation_delay + DNSKEY TTL the engine pushes the
+//	             mixed DS RRset {DS(old), DS(new)} and observes it at the parent.
+//	ds-replace   the old KSK leaves the target DS RRset (it still signs): the
+//	             engine pushes {DS(new)} and observes it.
+//	withdraw     once the old DS RRset can no longer be cached (effective margin,
+//	             at least the observed parent DS TTL) the old KSK goes straight
+//	             to removed — its DNSKEY and RRSIG leave the zone together.
+//
+// A zone whose parent will not take a DS for the new algorithm (the push is
+// rejected, or accepted but never published, until the engine gives up and
+// enters parent-push-softfail) has the roll refused: the new KSK is removed
+// (no DS ever referenced it) and the zone stays on the old KSK. The refusal is
+// recorded so the engine does not retry the same target; a change-policy back
+// to the old algorithm, or to a different one, clears it.
+const (
+	kskAlgRollStageNewRRSIGs  = "new-rrsigs"
+	kskAlgRollStageDoubleSign = "double-sign"
+	kskAlgRollStageDSReplace  = "ds-replace"
+	kskAlgRollStageWithdraw   = "withdraw"
+	kskAlgRollStageRefused    = "refused"
+)
+
+// KskAlgRoll is the persisted state of one zone's KSK algorithm rollover
+// (KskAlgRollState).
+type KskAlgRoll struct {
+	Zone     string
+	Stage    string
+	StageAt  time.Time
+	FromAlg  uint8
+	ToAlg    uint8
+	OldKeyid uint16
+	NewKeyid uint16
+	Detail   string
+}
+
+// InFlight reports whether the roll is under way (not absent, not refused).
+func (r *KskAlgRoll) InFlight() bool {
+	return r != nil && r.Stage != "" && r.Stage != kskAlgRollStageRefused
+}
+
+// kskAlgRollMode is what the engine tick may do for a zone given its KSK
+// algorithm rollover state.
+type kskAlgRollMode int
+
+const (
+	// kskAlgRollNone: no algorithm roll; the engine runs normally.
+	kskAlgRollNone kskAlgRollMode = iota
+	// kskAlgRollHold: the active KSK is not on the policy algorithm but no
+	// roll is running (refused, or waiting for a same-algorithm roll to
+	// finish). Pipeline fill and scheduled rolls stand down — they would mint
+	// keys of the policy algorithm — but the phase machine keeps the parent
+	// DS RRset in sync.
+	kskAlgRollHold
+	// kskAlgRollActive: the algorithm roll owns the zone. The idle branch
+	// stands down as well; the roll arms the DS push itself.
+	kskAlgRollActive
+)
+
+// LoadKskAlgRoll returns the zone's KSK algorithm rollover state, or nil.
+func LoadKskAlgRoll(kdb *KeyDB, zone string) (*KskAlgRoll, error) {
+	var r KskAlgRoll
+	var stageAt, detail sql.NullString
+	var from, to, oldKid, newKid int
+	err := kdb.DB.QueryRow(`
+SELECT zone, stage, stage_at, from_alg, to_alg, old_keyid, new_keyid, detail
+FROM KskAlgRollState WHERE zone = ?`, dns.Fqdn(zone)).Scan(
+		&r.Zone, &r.Stage, &stageAt, &from, &to, &oldKid, &newKid, &detail)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LoadKskAlgRoll: %w", err)
+	}
+	if t, ok := parseOptionalTime(stageAt); ok {
+		r.StageAt = t
+	}
+	r.FromAlg, r.ToAlg = uint8(from), uint8(to)
+	r.OldKeyid, r.NewKeyid = uint16(oldKid), uint16(newKid)
+	r.Detail = detail.String
+	return &r, nil
+}
+
+func saveKskAlgRollTx(tx *Tx, r *KskAlgRoll) error {
+	_, err := tx.Exec(`
+INSERT OR REPLACE INTO KskAlgRollState (zone, stage, stage_at, from_alg, to_alg, old_keyid, new_keyid, detail)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Zone, r.Stage, r.StageAt.UTC().Format(time.RFC3339), int(r.FromAlg), int(r.ToAlg),
+		int(r.OldKeyid), int(r.NewKeyid), r.Detail)
+	return err
+}
+
+func setKskAlgRollStage(kdb *KeyDB, zone, stage string, now time.Time) error {
+	_, err := kdb.DB.Exec(`UPDATE KskAlgRollState SET stage = ?, stage_at = ? WHERE zone = ?`,
+		stage, now.UTC().Format(time.RFC3339), zone)
+	return err
+}
+
+func clearKskAlgRoll(kdb *KeyDB, zone string) error {
+	_, err := kdb.DB.Exec(`DELETE FROM KskAlgRollState WHERE zone = ?`, zone)
+	return err
+}
+
+// kskAlgRollUnsupported returns why the engine cannot carry a KSK algorithm
+// rollover under pol, or "" when it can. The roll needs the multi-ds DS push /
+// observe machinery and an online KSK.
+func kskAlgRollUnsupported(pol *DnssecPolicy) string {
+	switch {
+	case pol == nil:
+		return "zone has no DNSSEC policy"
+	case pol.Mode == DnssecPolicyModeCSK:
+		return "CSK mode (a CSK algorithm rollover is not implemented)"
+	case pol.OfflineKSK:
+		return "the KSK is offline; an offline KSK's algorithm cannot be rolled by the engine"
+	case pol.Rollover.Method != RolloverMethodMultiDS:
+		return fmt.Sprintf("rollover.method is %s; the DS push/observe engine runs only for multi-ds", pol.Rollover.Method)
+	case pol.Rollover.ParentAgent == "":
+		return "rollover.parent-agent is unset; the engine cannot observe the parent DS RRset"
+	}
+	return ""
+}
+
+// kskAlgRollPreflight is the change-policy entry check for a KSK algorithm
+// change toward target. Everything refused here would otherwise stall or
+// break the chain of trust part-way through the roll.
+func kskAlgRollPreflight(kdb *KeyDB, zone string, target *DnssecPolicy) error {
+	if why := kskAlgRollUnsupported(target); why != "" {
+		return fmt.Errorf("KSK algorithm rollover needs the auto-rollover engine: %s", why)
+	}
+	r, err := LoadKskAlgRoll(kdb, zone)
+	if err != nil {
+		return err
+	}
+	if r.InFlight() {
+		return fmt.Errorf("a KSK algorithm rollover is already in progress (%s→%s, stage %s); wait for it to complete",
+			dns.AlgorithmToString[r.FromAlg], dns.AlgorithmToString[r.ToAlg], r.Stage)
+	}
+	if r != nil && r.ToAlg == target.KSKAlgorithm {
+		return fmt.Errorf("the parent refused a DS for %s on the previous attempt (%s); fix the parent before retrying",
+			dns.AlgorithmToString[r.ToAlg], r.Detail)
+	}
+	row, err := LoadRolloverZoneRow(kdb, zone)
+	if err != nil {
+		return err
+	}
+	if row == nil {
+		return nil
+	}
+	if row.RolloverInProgress || (row.RolloverPhase != "" && row.RolloverPhase != rolloverPhaseIdle) {
+		return fmt.Errorf("a KSK rollover is in progress (phase %s); wait for it to complete", row.RolloverPhase)
+	}
+	if row.ParentAdvertisesUpdate.Valid && row.ParentAdvertisesNotify.Valid &&
+		!row.ParentAdvertisesUpdate.Bool && !row.ParentAdvertisesNotify.Bool {
+		return fmt.Errorf("the parent advertises no DSYNC scheme; a DS for the new algorithm could never be published")
+	}
+	return nil
+}
+
+// kskAlgRollTick advances the zone's KSK algorithm rollover by at most one
+// stage. Called from RolloverAutomatedTick under the rollover lock, before
+// pipeline fill; the returned mode tells the tick what else may run.
+func kskAlgRollTick(deps RolloverEngineDeps, zone string, now time.Time) (kskAlgRollMode, error) {
+	kdb, pol := deps.KDB, deps.Policy
+
+	r, err := LoadKskAlgRoll(kdb, zone)
+	if err != nil {
+		return kskAlgRollNone, err
+	}
+	row, err := LoadRolloverZoneRow(kdb, zone)
+	if err != nil || row == nil {
+		return kskAlgRollNone, err
+	}
+	phase := row.RolloverPhase
+	if phase == "" {
+		phase = rolloverPhaseIdle
+	}
+
+	if !r.InFlight() {
+		active, err := GetDnssecKeysByState(kdb, zone, DnskeyStateActive)
+		if err != nil {
+			return kskAlgRollNone, err
+		}
+		var old *DnssecKeyWithTimestamps
+		onTarget := false
+		for i := range active {
+			if active[i].Flags&dns.SEP == 0 {
+				continue
+			}
+			if active[i].Algorithm == pol.KSKAlgorithm {
+				onTarget = true
+			} else if old == nil {
+				old = &active[i]
+			}
+		}
+		if r != nil && (old == nil || r.ToAlg != pol.KSKAlgorithm) {
+			// The operator moved the zone off the refused target.
+			if err := clearKskAlgRoll(kdb, zone); err != nil {
+				return kskAlgRollNone, err
+			}
+			r = nil
+		}
+		if old == nil {
+			return kskAlgRollNone, nil
+		}
+		if r != nil {
+			return kskAlgRollHold, nil
+		}
+		if onTarget {
+			// Both algorithms active without a roll record: only a lost
+			// KskAlgRollState row gets here. Leave it to the operator.
+			lgSigner.Warn("rollover: KSKs of two algorithms are active but no algorithm rollover is recorded; not rolling",
+				"zone", zone, "keyid", old.KeyTag)
+			return kskAlgRollHold, nil
+		}
+		if why := kskAlgRollUnsupported(pol); why != "" {
+			lgSigner.Warn("rollover: active KSK algorithm differs from policy but the engine cannot roll it",
+				"zone", zone, "keyid", old.KeyTag, "reason", why)
+			return kskAlgRollHold, nil
+		}
+		if phase != rolloverPhaseIdle || row.RolloverInProgress {
+			// Let the same-algorithm roll in flight finish first.
+			return kskAlgRollHold, nil
+		}
+		if err := startKskAlgRoll(deps, zone, old, now); err != nil {
+			return kskAlgRollHold, err
+		}
+		return kskAlgRollActive, nil
+	}
+
+	switch r.Stage {
+	case kskAlgRollStageNewRRSIGs:
+		if phase != rolloverPhaseIdle {
+			return kskAlgRollActive, nil
+		}
+		// DNSKEY(new) must not appear while a cache may still hold an RRset
+		// the new KSK signs without the new algorithm's signature.
+		if now.Sub(r.StageAt) < deps.PropagationDelay+kskAlgRollMaxZoneTTL(kdb, zone, pol) {
+			return kskAlgRollActive, nil
+		}
+		if err := setKskAlgRollStage(kdb, zone, kskAlgRollStageDoubleSign, now); err != nil {
+			return kskAlgRollActive, err
+		}
+		if err := republishSigningKeysForZone(kdb, zone); err != nil {
+			return kskAlgRollActive, fmt.Errorf("republish signing keys: %w", err)
+		}
+		lgSigner.Info("rollover: KSK algorithm roll: new-algorithm signatures propagated; publishing the new DNSKEY",
+			"zone", zone, "old_keyid", r.OldKeyid, "new_keyid", r.NewKeyid)
+		triggerResign(deps.Conf, zone)
+		return kskAlgRollActive, nil
+
+	case kskAlgRollStageDoubleSign:
+		if phase == rolloverPhasePushSoftfail && parentRefusedNewAlg(row, r.NewKeyid) {
+			return kskAlgRollHold, refuseKskAlgRoll(deps, zone, r, row, now)
+		}
+		if phase != rolloverPhaseIdle {
+			return kskAlgRollActive, nil
+		}
+		// DS(new) must not reach the parent before DNSKEY(new) has reached
+		// every cache that could be asked to validate with it.
+		wait := deps.PropagationDelay
+		if ttl, ok := effectiveServedDnskeyTTL(kdb, zone, pol); ok {
+			wait += ttl
+		}
+		if now.Sub(r.StageAt) < wait {
+			return kskAlgRollActive, nil
+		}
+		confirmed, err := kskAlgRollTargetConfirmed(kdb, zone, pol, row)
+		if err != nil {
+			return kskAlgRollActive, err
+		}
+		if confirmed {
+			if err := setKskAlgRollStage(kdb, zone, kskAlgRollStageDSReplace, now); err != nil {
+				return kskAlgRollActive, err
+			}
+			lgSigner.Info("rollover: KSK algorithm roll: mixed DS RRset confirmed at parent; withdrawing old DS",
+				"zone", zone, "old_keyid", r.OldKeyid, "new_keyid", r.NewKeyid)
+		}
+		return kskAlgRollActive, armKskAlgRollPush(kdb, zone)
+
+	case kskAlgRollStageDSReplace:
+		if phase != rolloverPhaseIdle {
+			return kskAlgRollActive, nil
+		}
+		confirmed, err := kskAlgRollTargetConfirmed(kdb, zone, pol, row)
+		if err != nil {
+			return kskAlgRollActive, err
+		}
+		if !confirmed {
+			return kskAlgRollActive, armKskAlgRollPush(kdb, zone)
+		}
+		if err := setKskAlgRollStage(kdb, zone, kskAlgRollStageWithdraw, now); err != nil {
+			return kskAlgRollActive, err
+		}
+		lgSigner.Info("rollover: KSK algorithm roll: old DS withdrawn at parent; waiting for caches before removing the old KSK",
+			"zone", zone, "old_keyid", r.OldKeyid)
+		return kskAlgRollActive, nil
+
+	case kskAlgRollStageWithdraw:
+		if phase != rolloverPhaseIdle {
+			return kskAlgRollActive, nil
+		}
+		wait, err := effectiveMarginForZone(kdb, zone, pol)
+		if err != nil {
+			lgSigner.Warn("rollover: effective margin lookup failed", "zone", zone, "err", err)
+			return kskAlgRollActive, nil
+		}
+		if ttl := time.Duration(deps.Zone.ParentDSTTLObserved) * time.Second; ttl > wait {
+			wait = ttl
+		}
+		if now.Sub(r.StageAt) < wait {
+			return kskAlgRollActive, nil
+		}
+		return kskAlgRollNone, completeKskAlgRoll(deps, zone, r)
+	}
+
+	lgSigner.Warn("rollover: unknown KSK algorithm roll stage", "zone", zone, "stage", r.Stage)
+	return kskAlgRollHold, nil
+}
+
+// startKskAlgRoll enters the new-rrsigs stage: the old algorithm's pipeline
+// keys (which would otherwise stay in the target DS RRset) are removed and a
+// KSK of the policy algorithm is minted directly into active, signing but not
+// yet published (kskAlgRollWithheldKSK).
+func startKskAlgRoll(deps RolloverEngineDeps, zone string, old *DnssecKeyWithTimestamps, now time.Time) error {
+	kdb, pol := deps.KDB, deps.Policy
+
+	var pipeline []uint16
+	for _, state := range []string{DnskeyStateCreated, DnskeyStateDsPublished, DnskeyStatePublished, DnskeyStateStandby} {
+		keys, err := GetDnssecKeysByState(kdb, zone, state)
+		if err != nil {
+			return fmt.Errorf("list %s keys: %w", state, err)
+		}
+		for _, k := range keys {
+			if k.Flags&dns.SEP != 0 {
+				pipeline = append(pipeline, k.KeyTag)
+			}
+		}
+	}
+
+	tx, err := kdb.Begin("startKskAlgRoll")
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	commit := false
+	defer func() {
+		if !commit {
+			tx.Rollback()
+		}
+	}()
+
+	for _, kid := range pipeline {
+		if err := UpdateDnssecKeyStateTx(tx, kdb, zone, kid, DnskeyStateRemoved); err != nil {
+			return fmt.Errorf("remove pipeline KSK %d: %w", kid, err)
+		}
+	}
+	ri, err := nextRolloverIndexTx(tx, zone)
+	if err != nil {
+		return fmt.Errorf("next rollover index: %w", err)
+	}
+	pkc, _, err := kdb.GenerateKeypair(zone, "key-state-worker", DnskeyStateActive, dns.TypeDNSKEY, pol.KSKAlgorithm, "KSK", tx)
+	if err != nil {
+		return fmt.Errorf("generate %s KSK: %w", dns.AlgorithmToString[pol.KSKAlgorithm], err)
+	}
+	if err := insertRolloverKeyStateTx(tx, zone, pkc.KeyId, ri, pol.Rollover.Method); err != nil {
+		return fmt.Errorf("rollover state: %w", err)
+	}
+	if err := setRolloverKeyPublishedAtTx(tx, zone, pkc.KeyId, now); err != nil {
+		return err
+	}
+	if err := setRolloverKeyActiveAtTx(tx, zone, pkc.KeyId, now); err != nil {
+		return err
+	}
+	seq, err := nextActiveSeqTx(tx, zone)
+	if err != nil {
+		return err
+	}
+	if err := setRolloverKeyActiveSeqTx(tx, zone, pkc.KeyId, seq); err != nil {
+		return err
+	}
+	if err := setRolloverInProgressTx(tx, zone, true); err != nil {
+		return err
+	}
+	if err := saveKskAlgRollTx(tx, &KskAlgRoll{
+		Zone:     zone,
+		Stage:    kskAlgRollStageNewRRSIGs,
+		StageAt:  now,
+		FromAlg:  old.Algorithm,
+		ToAlg:    pol.KSKAlgorithm,
+		OldKeyid: old.KeyTag,
+		NewKeyid: pkc.KeyId,
+	}); err != nil {
+		return fmt.Errorf("save algorithm roll state: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	commit = true
+
+	if err := republishSigningKeysForZone(kdb, zone); err != nil {
+		return fmt.Errorf("republish signing keys: %w", err)
+	}
+	lgSigner.Info("rollover: KSK algorithm roll started; signing with the new KSK before publishing its DNSKEY",
+		"zone", zone, "old_keyid", old.KeyTag, "old_alg", dns.AlgorithmToString[old.Algorithm],
+		"new_keyid", pkc.KeyId, "new_alg", dns.AlgorithmToString[pol.KSKAlgorithm],
+		"removed_pipeline_keys", len(pipeline))
+	triggerResign(deps.Conf, zone)
+	return nil
+}
+
+// kskAlgRollMaxZoneTTL is the longest a cache may hold an RRset signed
+// before the new KSK started signing: the maximum TTL the zone serves, and
+// at least the DNSKEY TTL.
+func kskAlgRollMaxZoneTTL(kdb *KeyDB, zone string, pol *DnssecPolicy) time.Duration {
+	ttl, _ := effectiveServedDnskeyTTL(kdb, zone, pol)
+	if obs, err := LoadZoneSigningMaxTTL(kdb, zone); err == nil {
+		if d := time.Duration(obs) * time.Second; d > ttl {
+			ttl = d
+		}
+	}
+	return ttl
+}
+
+// kskAlgRollWithheldKSK returns the KSK that an algorithm rollover in the
+// new-rrsigs stage keeps out of the DNSKEY RRset, and false when there is
+// none.
+func kskAlgRollWithheldKSK(kdb *KeyDB, zone string) (uint16, bool) {
+	r, err := LoadKskAlgRoll(kdb, zone)
+	if err != nil || r == nil || r.Stage != kskAlgRollStageNewRRSIGs {
+		return 0, false
+	}
+	return r.NewKeyid, true
+}
+
+// parentRefusedNewAlg reports whether the engine gave up pushing a DS RRset
+// containing DS(newKid) because the parent rejected it or never published it.
+// Transport and DSYNC-advertisement failures say nothing about the algorithm
+// and keep the roll waiting instead.
+func parentRefusedNewAlg(row *RolloverZoneRow, newKid uint16) bool {
+	switch row.LastSoftfailCategory.String {
+	case SoftfailParentRejected, SoftfailParentPublishFailure:
+	default:
+		return false
+	}
+	return !slices.Contains(parseDsObservedKeyids(row.LastDsObservedKeyids.String), newKid)
+}
+
+// refuseKskAlgRoll backs the roll out: the new KSK never had a DS at the
+// parent, so it is removed at once and the old KSK carries on alone.
+func refuseKskAlgRoll(deps RolloverEngineDeps, zone string, r *KskAlgRoll, row *RolloverZoneRow, now time.Time) error {
+	kdb := deps.KDB
+	detail := fmt.Sprintf("%s: %s", row.LastSoftfailCategory.String, row.LastSoftfailDetail.String)
+
+	tx, err := kdb.Begin("refuseKskAlgRoll")
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	commit := false
+	defer func() {
+		if !commit {
+			tx.Rollback()
+		}
+	}()
+	if err := UpdateDnssecKeyStateTx(tx, kdb, zone, r.NewKeyid, DnskeyStateRemoved); err != nil {
+		return fmt.Errorf("remove KSK %d: %w", r.NewKeyid, err)
+	}
+	if err := setRolloverInProgressTx(tx, zone, false); err != nil {
+		return err
+	}
+	if err := setRolloverPhaseTx(tx, zone, rolloverPhaseIdle); err != nil {
+		return err
+	}
+	r.Stage, r.StageAt, r.Detail = kskAlgRollStageRefused, now, detail
+	if err := saveKskAlgRollTx(tx, r); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	commit = true
+
+	_ = clearObserveSchedule(kdb, zone)
+	_ = resetHardfailCount(kdb, zone)
+	if err := republishSigningKeysForZone(kdb, zone); err != nil {
+		return fmt.Errorf("republish signing keys: %w", err)
+	}
+	lgSigner.Error("rollover: KSK algorithm roll REFUSED: the parent does not publish a DS for the new algorithm; staying on the old KSK",
+		"zone", zone, "old_keyid", r.OldKeyid, "removed_keyid", r.NewKeyid,
+		"new_alg", dns.AlgorithmToString[r.ToAlg], "detail", detail)
+	triggerResign(deps.Conf, zone)
+	return nil
+}
+
+// completeKskAlgRoll removes the old KSK and ends the roll.
+func completeKskAlgRoll(deps RolloverEngineDeps, zone string, r *KskAlgRoll) error {
+	kdb := deps.KDB
+	tx, err := kdb.Begin("completeKskAlgRoll")
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	commit := false
+	defer func() {
+		if !commit {
+			tx.Rollback()
+		}
+	}()
+	if err := UpdateDnssecKeyStateTx(tx, kdb, zone, r.OldKeyid, DnskeyStateRemoved); err != nil {
+		return fmt.Errorf("remove KSK %d: %w", r.OldKeyid, err)
+	}
+	if err := setRolloverInProgressTx(tx, zone, false); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM KskAlgRollState WHERE zone = ?`, zone); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	commit = true
+
+	if err := republishSigningKeysForZone(kdb, zone); err != nil {
+		return fmt.Errorf("republish signing keys: %w", err)
+	}
+	lgSigner.Info("rollover: KSK algorithm roll complete; old KSK removed",
+		"zone", zone, "removed_keyid", r.OldKeyid, "alg", dns.AlgorithmToString[r.ToAlg], "keyid", r.NewKeyid)
+	triggerResign(deps.Conf, zone)
+	return nil
+}
+
+// kskAlgRollTargetConfirmed reports whether the parent has confirmed the
+// current target DS RRset (the same comparison the idle branch uses to decide
+// whether a push is needed).
+func kskAlgRollTargetConfirmed(kdb *KeyDB, zone string, pol *DnssecPolicy, row *RolloverZoneRow) (bool, error) {
+	ds, low, high, idxOK, err := ComputeTargetDSSetForZone(kdb, zone, uint8(dns.SHA256), pol)
+	if err != nil {
+		return false, err
+	}
+	if len(ds) == 0 || !idxOK {
+		return false, nil
+	}
+	return !kskIndexPushNeeded(row, low, high, idxOK, true), nil
+}
+
+func armKskAlgRollPush(kdb *KeyDB, zone string) error {
+	if err := SetRolloverPhase(kdb, zone, rolloverPhasePendingParentPush); err != nil {
+		return err
+	}
+	lgSigner.Info("rollover: KSK algorithm roll: arming DS push", "zone", zone)
+	return nil
+}
+
+// kskAlgRollStatus is the status-header view of an algorithm roll.
+func kskAlgRollStatus(kdb *KeyDB, zone string) *AlgTransitionInfo {
+	r, err := LoadKskAlgRoll(kdb, zone)
+	if err != nil || r == nil {
+		return nil
+	}
+	return &AlgTransitionInfo{
+		Role:    "KSK",
+		FromAlg: dns.AlgorithmToString[r.FromAlg],
+		ToAlg:   dns.AlgorithmToString[r.ToAlg],
+		Stage:   r.Stage,
+		Detail:  r.Detail,
+	}
+}
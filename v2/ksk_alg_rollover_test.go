@@ -0,0 +1,269 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package tdns
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const kskAlgZone = "ksk-alg.example."
+
+// kskAlgRollTestSetup returns a zone whose active KSK is ED25519 bound to a
+// multi-ds policy that wants ECDSAP256SHA256, and engine deps for it. The
+// parent side is simulated by writing the confirmed DS range directly.
+func kskAlgRollTestSetup(t *testing.T) (RolloverEngineDeps, uint16) {
+	t.Helper()
+	kdb := newTestKeyDB(t)
+	pol := &DnssecPolicy{
+		Mode:         DnssecPolicyModeKSKZSK,
+		KSKAlgorithm: dns.ECDSAP256SHA256,
+		ZSKAlgorithm: dns.ED25519,
+	}
+	pol.Rollover.Method = RolloverMethodMultiDS
+	pol.Rollover.ParentAgent = "192.0.2.1:53"
+	pol.TTLS.DNSKEY = 3600
+	pol.Clamping.Margin = 2 * time.Hour
+
+	pkc, _, err := kdb.GenerateKeypair(kskAlgZone, "test", DnskeyStateActive, dns.TypeDNSKEY, dns.ED25519, "KSK", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterBootstrapActiveKSK(kdb, kskAlgZone, pkc.KeyId, pol.Rollover.Method, dns.ED25519); err != nil {
+		t.Fatal(err)
+	}
+	zd := &ZoneData{
+		ZoneName:     kskAlgZone,
+		Options:      map[ZoneOption]bool{OptOnlineSigning: true},
+		DnssecPolicy: pol,
+		Logger:       log.New(os.Stderr, "", 0),
+	}
+	return RolloverEngineDeps{
+		Conf:             &Config{},
+		KDB:              kdb,
+		Zone:             zd,
+		Policy:           pol,
+		PropagationDelay: time.Hour,
+	}, pkc.KeyId
+}
+
+// confirmTargetDS plays the parent: the current target DS RRset is observed,
+// as confirmDSAndAdvanceCreatedKeysTx records it, and the zone idles.
+func confirmTargetDS(t *testing.T, deps RolloverEngineDeps) []dns.RR {
+	t.Helper()
+	ds, low, high, ok, err := ComputeTargetDSSetForZone(deps.KDB, kskAlgZone, uint8(dns.SHA256), deps.Policy)
+	if err != nil || !ok {
+		t.Fatalf("target DS: %v (index range known %v)", err, ok)
+	}
+	if err := saveLastDSConfirmedRange(deps.KDB, kskAlgZone, low, high); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetRolloverPhase(deps.KDB, kskAlgZone, rolloverPhaseIdle); err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+func kskAlgRollPhase(t *testing.T, kdb *KeyDB) string {
+	t.Helper()
+	row, err := LoadRolloverZoneRow(kdb, kskAlgZone)
+	if err != nil || row == nil {
+		t.Fatalf("rollover row: %v", err)
+	}
+	return row.RolloverPhase
+}
+
+func activeSEPKeys(t *testing.T, kdb *KeyDB) map[uint16]uint8 {
+	t.Helper()
+	keys, err := GetDnssecKeysByState(kdb, kskAlgZone, DnskeyStateActive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[uint16]uint8{}
+	for _, k := range keys {
+		if k.Flags&dns.SEP != 0 {
+			out[k.KeyTag] = k.Algorithm
+		}
+	}
+	return out
+}
+
+func TestKskAlgRollSequence(t *testing.T) {
+	deps, oldKid := kskAlgRollTestSetup(t)
+	kdb := deps.KDB
+	now := time.Now()
+
+	// A pipeline key of the old algorithm does not survive the start.
+	standby, _, err := kdb.GenerateKeypair(kskAlgZone, "test", DnskeyStateStandby, dns.TypeDNSKEY, dns.ED25519, "KSK", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mode, err := kskAlgRollTick(deps, kskAlgZone, now); err != nil || mode != kskAlgRollActive {
+		t.Fatalf("start: mode %v err %v", mode, err)
+	}
+	r, _ := LoadKskAlgRoll(kdb, kskAlgZone)
+	if r == nil || r.Stage != kskAlgRollStageNewRRSIGs || r.OldKeyid != oldKid {
+		t.Fatalf("after start: %+v", r)
+	}
+	active := activeSEPKeys(t, kdb)
+	if len(active) != 2 || active[r.NewKeyid] != dns.ECDSAP256SHA256 {
+		t.Fatalf("new-rrsigs: active KSKs %v", active)
+	}
+	if kid, ok := kskAlgRollWithheldKSK(kdb, kskAlgZone); !ok || kid != r.NewKeyid {
+		t.Fatalf("new KSK %d not withheld from the DNSKEY RRset", r.NewKeyid)
+	}
+	if ksks, _ := GetDnssecKeysByState(kdb, kskAlgZone, DnskeyStateStandby); len(ksks) != 0 {
+		t.Errorf("old-algorithm standby KSK %d kept", standby.KeyId)
+	}
+
+	// The new DNSKEY is published only once the new-algorithm signatures
+	// have outlived the maximum zone TTL.
+	if err := UpsertZoneSigningMaxTTL(kdb, kskAlgZone, 7200); err != nil {
+		t.Fatal(err)
+	}
+	kskAlgRollTick(deps, kskAlgZone, now.Add(2*time.Hour+time.Minute))
+	if r, _ := LoadKskAlgRoll(kdb, kskAlgZone); r.Stage != kskAlgRollStageNewRRSIGs {
+		t.Fatalf("DNSKEY published before propagation + max zone TTL: %+v", r)
+	}
+	now = now.Add(3*time.Hour + time.Minute)
+	kskAlgRollTick(deps, kskAlgZone, now)
+	if r, _ := LoadKskAlgRoll(kdb, kskAlgZone); r.Stage != kskAlgRollStageDoubleSign {
+		t.Fatalf("double-sign not entered: %+v", r)
+	}
+	if _, ok := kskAlgRollWithheldKSK(kdb, kskAlgZone); ok {
+		t.Fatal("new KSK still withheld in double-sign")
+	}
+
+	// No DS push before the new DNSKEY has propagated.
+	kskAlgRollTick(deps, kskAlgZone, now.Add(time.Hour))
+	if p := kskAlgRollPhase(t, kdb); p != rolloverPhaseIdle {
+		t.Fatalf("pushed before propagation + DNSKEY TTL: phase %s", p)
+	}
+	kskAlgRollTick(deps, kskAlgZone, now.Add(2*time.Hour+time.Minute))
+	if p := kskAlgRollPhase(t, kdb); p != rolloverPhasePendingParentPush {
+		t.Fatalf("mixed DS push not armed: phase %s", p)
+	}
+	if ds := confirmTargetDS(t, deps); len(ds) != 2 {
+		t.Fatalf("mixed DS RRset has %d records, want 2", len(ds))
+	}
+
+	// The old KSK leaves the DS RRset while still signing.
+	kskAlgRollTick(deps, kskAlgZone, now.Add(3*time.Hour))
+	r, _ = LoadKskAlgRoll(kdb, kskAlgZone)
+	if r.Stage != kskAlgRollStageDSReplace || kskAlgRollPhase(t, kdb) != rolloverPhasePendingParentPush {
+		t.Fatalf("ds-replace not armed: %+v phase %s", r, kskAlgRollPhase(t, kdb))
+	}
+	ds := confirmTargetDS(t, deps)
+	if len(ds) != 1 || ds[0].(*dns.DS).KeyTag != r.NewKeyid {
+		t.Fatalf("replacement DS RRset %v, want DS(%d) only", ds, r.NewKeyid)
+	}
+	if len(activeSEPKeys(t, kdb)) != 2 {
+		t.Fatalf("old KSK stopped signing before its DS left the parent")
+	}
+
+	kskAlgRollTick(deps, kskAlgZone, now.Add(3*time.Hour))
+	r, _ = LoadKskAlgRoll(kdb, kskAlgZone)
+	if r.Stage != kskAlgRollStageWithdraw {
+		t.Fatalf("withdraw not entered: %+v", r)
+	}
+	// The old KSK stays until the old DS RRset can no longer be cached.
+	kskAlgRollTick(deps, kskAlgZone, now.Add(4*time.Hour))
+	if len(activeSEPKeys(t, kdb)) != 2 {
+		t.Fatalf("old KSK removed before the effective margin")
+	}
+	if mode, err := kskAlgRollTick(deps, kskAlgZone, now.Add(6*time.Hour)); err != nil || mode != kskAlgRollNone {
+		t.Fatalf("complete: mode %v err %v", mode, err)
+	}
+	active = activeSEPKeys(t, kdb)
+	if len(active) != 1 || active[r.NewKeyid] != dns.ECDSAP256SHA256 {
+		t.Fatalf("after roll: active KSKs %v", active)
+	}
+	if r, _ := LoadKskAlgRoll(kdb, kskAlgZone); r != nil {
+		t.Errorf("roll state left behind: %+v", r)
+	}
+	if row, _ := LoadRolloverZoneRow(kdb, kskAlgZone); row.RolloverInProgress {
+		t.Errorf("rollover_in_progress still set")
+	}
+}
+
+func TestKskAlgRollRefusedByParent(t *testing.T) {
+	deps, oldKid := kskAlgRollTestSetup(t)
+	kdb := deps.KDB
+	now := time.Now()
+
+	kskAlgRollTick(deps, kskAlgZone, now)
+	now = now.Add(2*time.Hour + time.Minute)
+	kskAlgRollTick(deps, kskAlgZone, now)
+	r, _ := LoadKskAlgRoll(kdb, kskAlgZone)
+
+	// The engine gave up pushing: the parent keeps answering with DS(old).
+	if err := setSoftfail(kdb, kskAlgZone, SoftfailParentRejected, "rcode=REFUSED", now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := setLastDsObserved(kdb, kskAlgZone, []uint16{oldKid}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetRolloverPhase(kdb, kskAlgZone, rolloverPhasePushSoftfail); err != nil {
+		t.Fatal(err)
+	}
+
+	if mode, err := kskAlgRollTick(deps, kskAlgZone, now.Add(time.Hour)); err != nil || mode != kskAlgRollHold {
+		t.Fatalf("refusal: mode %v err %v", mode, err)
+	}
+	active := activeSEPKeys(t, kdb)
+	if len(active) != 1 || active[oldKid] != dns.ED25519 {
+		t.Fatalf("after refusal: active KSKs %v, want the old KSK only", active)
+	}
+	refused, _ := LoadKskAlgRoll(kdb, kskAlgZone)
+	if refused == nil || refused.Stage != kskAlgRollStageRefused {
+		t.Fatalf("refusal not recorded: %+v", refused)
+	}
+
+	// Not retried, and change-policy to the same target is refused.
+	if mode, _ := kskAlgRollTick(deps, kskAlgZone, now.Add(2*time.Hour)); mode != kskAlgRollHold {
+		t.Fatalf("refused roll restarted: mode %v", mode)
+	}
+	if keys, _ := GetDnssecKeysByState(kdb, kskAlgZone, DnskeyStateActive); len(keys) != 1 {
+		t.Fatalf("refused roll minted a key")
+	}
+	if err := kskAlgRollPreflight(kdb, kskAlgZone, deps.Policy); err == nil {
+		t.Errorf("preflight accepted the refused target")
+	}
+	if ksks, _ := GetDnssecKeysByState(kdb, kskAlgZone, DnskeyStateRemoved); len(ksks) != 1 || ksks[0].KeyTag != r.NewKeyid {
+		t.Errorf("new KSK %d not removed: %v", r.NewKeyid, ksks)
+	}
+
+	// Rebinding the old algorithm clears the refusal.
+	deps.Policy.KSKAlgorithm = dns.ED25519
+	if mode, _ := kskAlgRollTick(deps, kskAlgZone, now.Add(3*time.Hour)); mode != kskAlgRollNone {
+		t.Fatalf("mode %v after reverting the policy", mode)
+	}
+	if r, _ := LoadKskAlgRoll(kdb, kskAlgZone); r != nil {
+		t.Errorf("refusal not cleared: %+v", r)
+	}
+}
+
+func TestKskAlgRollReconcileGate(t *testing.T) {
+	deps, _ := kskAlgRollTestSetup(t)
+	zd := deps.Zone
+	dak, err := deps.KDB.GetDnssecKeys(kskAlgZone, DnskeyStateActive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zd.reconcileActiveKeyAlgorithms(deps.KDB, dak); err != nil {
+		t.Errorf("engine-capable policy: reconcile refused: %v", err)
+	}
+	zd.DnssecPolicy.Rollover.ParentAgent = ""
+	if _, err := zd.reconcileActiveKeyAlgorithms(deps.KDB, dak); err == nil {
+		t.Errorf("policy without parent agent: reconcile did not refuse")
+	}
+	if err := kskAlgRollPreflight(deps.KDB, kskAlgZone, zd.DnssecPolicy); err == nil {
+		t.Errorf("policy without parent agent: preflight did not refuse")
+	}
+}
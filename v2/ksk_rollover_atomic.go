@@ -55,7 +55,7 @@ func AtomicRollover(conf *Config, kdb *KeyDB, zone string) (oldKid, newKid uint1
 		}
 	}()
 
-	now := time.Now().UTC()
+	now := keyStateNow().UTC()
 
 	// Re-check rollover_in_progress inside the TX so two concurrent callers
 	// can't both promote: the second one will see TRUE here and bail.
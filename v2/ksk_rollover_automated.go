@@ -58,7 +58,8 @@ func kskIndexPushNeeded(row *RolloverZoneRow, low, high int, indexOK bool, haveD
 }
 
 // RolloverAutomatedTick runs one slice of automated KSK rollover for multi-ds (pipeline fill,
-// DS push / observe phase machine). double-signature is not implemented yet.
+// DS push / observe phase machine, KSK algorithm rollover). double-signature is not
+// implemented yet.
 //
 // All dependencies are passed via RolloverEngineDeps so the engine has no
 // implicit globals. The orchestrator (KeyStateWorker) iterates its zones,
@@ -94,7 +95,7 @@ func RolloverAutomatedTick(ctx context.Context, deps RolloverEngineDeps) error {
 	kdb := deps.KDB
 	imr := deps.Imr
 	propagationDelay := deps.PropagationDelay
-	now := time.Now()
+	now := keyStateNow()
 	if deps.Now != nil {
 		now = deps.Now()
 	}
@@ -137,6 +138,15 @@ func RolloverAutomatedTick(ctx context.Context, deps RolloverEngineDeps) error {
 	// with clamping.enabled: false.
 	kStepScheduler(zd, kdb, pol, now)
 
+	// KSK algorithm rollover (ksk_alg_rollover.go): when the policy's KSK
+	// algorithm differs from the active KSK's, the algorithm roll decides
+	// what else runs. Pipeline fill and scheduled rolls would mint keys of
+	// the new algorithm outside the roll's ordering, so they stand down.
+	algRoll, err := kskAlgRollTick(deps, zone, now)
+	if err != nil {
+		return err
+	}
+
 	// Pipeline-fill: maintain num_ds DS records at the parent, plus one
 	// 'created' key in flight (total cap num_ds + 1). The two checks gate
 	// generation independently:
@@ -153,7 +163,7 @@ func RolloverAutomatedTick(ctx context.Context, deps RolloverEngineDeps) error {
 	num := pol.Rollover.NumDS
 	maxPipeline := num + 1
 	circuitBreakerCeiling := 2 * maxPipeline
-	for {
+	for algRoll == kskAlgRollNone {
 		total, err := CountKskInPipeline(kdb, zone)
 		if err != nil {
 			return err
@@ -217,7 +227,7 @@ func RolloverAutomatedTick(ctx context.Context, deps RolloverEngineDeps) error {
 	// its computed earliest time, fire AtomicRollover. The tick then
 	// re-loads phase and continues; the new pending-child-publish phase
 	// will be handled below if reached this pass, or on the next tick.
-	if phase == rolloverPhaseIdle && !row.RolloverInProgress {
+	if phase == rolloverPhaseIdle && !row.RolloverInProgress && algRoll == kskAlgRollNone {
 		due, manual, err := rolloverDue(kdb, zone, pol, row, now)
 		if err != nil {
 			lgSigner.Warn("rollover: rollover_due check failed", "zone", zone, "err", err)
@@ -247,6 +257,10 @@ func RolloverAutomatedTick(ctx context.Context, deps RolloverEngineDeps) error {
 		// push phase if the target DS RRset differs from what we have
 		// submitted. Arming is the single advance on this tick — the
 		// actual push happens on the next tick under pending-parent-push.
+		// A running KSK algorithm roll arms its own pushes.
+		if algRoll == kskAlgRollActive {
+			return nil
+		}
 		ds, low, high, idxOK, err := ComputeTargetDSSetForZone(kdb, zone, uint8(dns.SHA256), pol)
 		if err != nil {
 			return err
@@ -276,14 +290,14 @@ func RolloverAutomatedTick(ctx context.Context, deps RolloverEngineDeps) error {
 		// §8.8: send DS UPDATE. Arming the observe phase counts as the
 		// advance; the actual parent DS query happens on the next tick
 		// under pending-parent-observe.
-		if imr == nil {
+		if imr == nil && !deps.Parent.simulated() {
 			lgSigner.Warn("rollover: ImrEngine nil, cannot DS push", "zone", zone)
 			handleAttemptFailed(kdb, zone, pol, SoftfailChildConfigLocalError, "ImrEngine nil, cannot DS push", now)
 			return nil
 		}
 		_ = setLastAttemptStarted(kdb, zone, now)
 		pushCtx, cancel := context.WithTimeout(ctx, 45*time.Second)
-		res, err := PushDSRRsetForRollover(pushCtx, deps)
+		res, err := deps.pushDS(pushCtx)
 		cancel()
 		if err != nil {
 			cat := res.Category
@@ -368,7 +382,7 @@ func RolloverAutomatedTick(ctx context.Context, deps RolloverEngineDeps) error {
 			}
 		}
 
-		obs, err := QueryParentAgentDS(ctx, zone, agent)
+		obs, err := deps.queryParentDS(ctx, zone, agent)
 		_ = setLastPoll(kdb, zone, now)
 		if err != nil {
 			lgSigner.Debug("rollover: parent-agent DS query failed", "zone", zone, "err", err)
@@ -459,7 +473,7 @@ func RolloverAutomatedTick(ctx context.Context, deps RolloverEngineDeps) error {
 		}
 		agent := pol.Rollover.ParentAgent
 		if pollDue && agent != "" {
-			obs, qerr := QueryParentAgentDS(ctx, zone, agent)
+			obs, qerr := deps.queryParentDS(ctx, zone, agent)
 			_ = setLastPoll(kdb, zone, now)
 			if qerr == nil {
 				// W2: refresh observed parent DS TTL + re-evaluate
@@ -509,14 +523,14 @@ func RolloverAutomatedTick(ctx context.Context, deps RolloverEngineDeps) error {
 			softfailDelay = derivedSoftfailDelay(pol.Rollover.DsPublishDelay)
 		}
 		nextPush := now.Add(softfailDelay).Add(jitterUpTo(5 * time.Minute))
-		if imr == nil {
+		if imr == nil && !deps.Parent.simulated() {
 			lgSigner.Warn("rollover: ImrEngine nil, cannot softfail probe", "zone", zone)
 			_ = setSoftfail(kdb, zone, SoftfailChildConfigLocalError, "ImrEngine nil, cannot softfail probe", now, nextPush)
 			return nil
 		}
 		_ = setLastAttemptStarted(kdb, zone, now)
 		pushCtx, cancel := context.WithTimeout(ctx, 45*time.Second)
-		res, perr := PushDSRRsetForRollover(pushCtx, deps)
+		res, perr := deps.pushDS(pushCtx)
 		cancel()
 		if perr != nil {
 			cat := res.Category
@@ -740,11 +754,14 @@ WHERE zone = ?`, zone); err != nil {
 // after restart picks up where this left off (at the cost of being
 // bounded by check_interval — same as the pre-fix behavior). No state
 // is lost; just slower recovery.
+//
+// Not used with a simulated parent: the goroutine would sleep on the wall
+// clock, while the simulator polls its model parent on virtual ticks.
 func scheduleFastObservePoll(ctx context.Context, deps RolloverEngineDeps, initial time.Duration) {
 	if initial <= 0 {
 		initial = defaultConfirmInitialWait
 	}
-	if deps.Zone == nil {
+	if deps.Zone == nil || deps.Parent.simulated() {
 		return
 	}
 	zone := deps.Zone.ZoneName
@@ -1461,7 +1478,7 @@ func PromoteStandbyKskIfNoActive(conf *Config, kdb *KeyDB, zone string) {
 		lgSigner.Warn("rollover: bootstrap promote stamp tx begin failed", "zone", zone, "err", err)
 	} else {
 		commit := false
-		if err := setRolloverKeyActiveAtTx(tx, zone, best.KeyTag, time.Now().UTC()); err != nil {
+		if err := setRolloverKeyActiveAtTx(tx, zone, best.KeyTag, keyStateNow().UTC()); err != nil {
 			lgSigner.Warn("rollover: active_at stamp failed (bootstrap)", "zone", zone, "keyid", best.KeyTag, "err", err)
 		} else if seq, err := nextActiveSeqTx(tx, zone); err != nil {
 			lgSigner.Warn("rollover: next active_seq failed (bootstrap)", "zone", zone, "err", err)
@@ -1480,7 +1497,7 @@ func PromoteStandbyKskIfNoActive(conf *Config, kdb *KeyDB, zone string) {
 	triggerResign(conf, zone)
 }
 
-func rolloverAutomatedForAllZones(ctx context.Context, conf *Config, kdb *KeyDB, propagationDelay time.Duration, now time.Time) {
+func rolloverAutomatedForAllZones(ctx context.Context, conf *Config, kdb *KeyDB, propagationDelay time.Duration, now time.Time, parent RolloverParentHooks) {
 	imr := conf.Internal.ImrEngine
 	nowFn := func() time.Time { return now }
 	for _, zd := range Zones.Items() {
@@ -1513,6 +1530,7 @@ func rolloverAutomatedForAllZones(ctx context.Context, conf *Config, kdb *KeyDB,
 			Logger:           lgSigner,
 			PropagationDelay: propagationDelay,
 			Now:              nowFn,
+			Parent:           parent,
 		}
 		if err := RolloverAutomatedTick(ctx, deps); err != nil {
 			lgSigner.Error("rollover: tick error", "zone", zd.ZoneName, "err", err)
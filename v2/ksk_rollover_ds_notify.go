@@ -199,7 +199,7 @@ func pushDSRRsetViaNotify(ctx context.Context, deps RolloverEngineDeps, target *
 	// reflects the most recent publication after the rollover has
 	// completed and the ownership marker is cleared.
 	keyids := cdsKeyids(cdsSet)
-	if err := setCdsPublication(kdb, child, keyids, time.Now().UTC()); err != nil {
+	if err := setCdsPublication(kdb, child, keyids, keyStateNow().UTC()); err != nil {
 		// Best-effort: a write failure here doesn't undo the on-wire
 		// publication. Log and continue.
 		lgRollover.Warn("pushDSRRsetViaNotify: setCdsPublication failed",
@@ -80,6 +80,10 @@ type kskForDSRow struct {
 // indexLow/indexHigh are min/max rollover_index when every contributing
 // key has a RolloverKeyState row; otherwise indexRangeKnown is false
 // and callers must not treat the indices as authoritative.
+//
+// During the ds-replace and withdraw stages of a KSK algorithm rollover
+// (ksk_alg_rollover.go) the outgoing active KSK is excluded: it keeps signing
+// the DNSKEY RRset, but its DS must leave the parent before its DNSKEY does.
 func loadTargetKSKsForRollover(kdb *KeyDB, childZone string) (rows []kskForDSRow, indexLow, indexHigh int, indexRangeKnown bool, err error) {
 	childZone = dns.Fqdn(childZone)
 	const q = `
@@ -88,6 +92,7 @@ FROM DnssecKeyStore k
 LEFT JOIN RolloverKeyState r ON k.zonename = r.zone AND k.keyid = r.keyid
 WHERE k.zonename = ? AND k.state IN ('created','ds-published','standby','published','active','retired')
   AND (CAST(k.flags AS INTEGER) & ?) != 0
+  AND k.keyid NOT IN (SELECT old_keyid FROM KskAlgRollState WHERE zone = k.zonename AND stage IN ('ds-replace','withdraw'))
 ORDER BY COALESCE(r.rollover_index, 2147483646) ASC, k.keyid ASC`
 
 	sqlRows, err := kdb.Query(q, childZone, int(dns.SEP))
@@ -661,7 +666,7 @@ ON CONFLICT(zone) DO UPDATE SET
   last_ds_submitted_index_low = excluded.last_ds_submitted_index_low,
   last_ds_submitted_index_high = excluded.last_ds_submitted_index_high,
   last_ds_submitted_at = excluded.last_ds_submitted_at`
-	now := time.Now().UTC().Format(time.RFC3339)
+	now := keyStateNow().UTC().Format(time.RFC3339)
 	_, err := kdb.DB.Exec(q, zone, low, high, now)
 	return err
 }
@@ -671,7 +676,7 @@ ON CONFLICT(zone) DO UPDATE SET
 // so subsequent range-based decisions and operator status output don't
 // keep showing values from an older submission.
 func clearLastDSSubmittedRange(kdb *KeyDB, zone string) error {
-	now := time.Now().UTC().Format(time.RFC3339)
+	now := keyStateNow().UTC().Format(time.RFC3339)
 	const q = `UPDATE RolloverZoneState
 SET last_ds_submitted_index_low = NULL,
     last_ds_submitted_index_high = NULL,
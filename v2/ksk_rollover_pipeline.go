@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/miekg/dns"
 )
@@ -89,7 +88,7 @@ func RegisterBootstrapActiveKSK(kdb *KeyDB, zone string, keyid uint16, method Ro
 		}
 	}
 
-	now := time.Now().UTC()
+	now := keyStateNow().UTC()
 
 	// Stamp active_at only if currently NULL. Each restart re-runs the
 	// heal pass, but the active_at timestamp must reflect the actual
@@ -13,13 +13,13 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-// parseExtendedDuration parses a Go duration string with one extension:
+// ParseExtendedDuration parses a Go duration string with one extension:
 // a single trailing "d" (days) or "w" (weeks) suffix on a plain integer,
 // e.g. "14d" or "2w". Everything else falls through to time.ParseDuration,
 // so "168h", "30m", "1h30m" keep working. Days = 24h, weeks = 168h. Operators
 // express key lifetimes and signature validity in days/weeks; the stdlib
 // parser stops at hours.
-func parseExtendedDuration(s string) (time.Duration, error) {
+func ParseExtendedDuration(s string) (time.Duration, error) {
 	s = strings.TrimSpace(s)
 	if len(s) >= 2 {
 		unit := s[len(s)-1]
@@ -113,6 +113,22 @@ type ClampingPolicy struct {
 	Margin  time.Duration
 }
 
+// Signing modes (signing.mode). merkle-ladder is experimental: sign passes
+// batch the zone's RRsets into Merkle trees and put inclusion proofs in
+// the RRSIGs (sign_ladder.go); only ladder-aware validators accept them.
+const (
+	SigningModePerRRset     = "per-rrset"
+	SigningModeMerkleLadder = "merkle-ladder"
+)
+
+// SigningPolicy is the resolved `signing:` subtree.
+type SigningPolicy struct {
+	Mode string
+	// LadderMinLeaves is the smallest batch, per signing key, that is
+	// signed as a tree; smaller batches get ordinary signatures.
+	LadderMinLeaves int
+}
+
 const (
 	defaultConfirmInitialWait       = 2 * time.Second
 	defaultConfirmPollMax           = 60 * time.Second
@@ -124,6 +140,7 @@ const (
 	defaultSoftfailDelayMinimum     = time.Hour
 	defaultParentCdsPollEstimate    = time.Minute
 	defaultStandbyTime              = time.Minute
+	defaultLadderMinLeaves          = 8
 )
 
 // derivedPollMax returns clamp(dsDelay/10, 30s, 5m). Used as the
@@ -188,6 +205,18 @@ func FinishDnssecPolicy(policyName string, conf *DnssecPolicyConf, out *DnssecPo
 	}
 	out.Rollover.Method = m
 
+	if conf.KSK.Offline {
+		// The KSK rollover engine needs the KSK private keys, and a CSK
+		// would put them in this keystore by definition.
+		if out.Mode != DnssecPolicyModeKSKZSK {
+			return fmt.Errorf("dnssec policy %q: ksk.offline requires mode %q", policyName, DnssecPolicyModeKSKZSK)
+		}
+		if m != RolloverMethodNone {
+			return fmt.Errorf("dnssec policy %q: ksk.offline requires rollover.method none (KSK rollovers are done in the offline keystore)", policyName)
+		}
+		out.OfflineKSK = true
+	}
+
 	switch m {
 	case RolloverMethodNone:
 		out.Rollover.NumDS = 0
@@ -247,7 +276,7 @@ func FinishDnssecPolicy(policyName string, conf *DnssecPolicyConf, out *DnssecPo
 	}
 
 	if strings.TrimSpace(conf.Ttls.DNSKEY) != "" {
-		d, err := parseExtendedDuration(strings.TrimSpace(conf.Ttls.DNSKEY))
+		d, err := ParseExtendedDuration(strings.TrimSpace(conf.Ttls.DNSKEY))
 		if err != nil {
 			return fmt.Errorf("dnssec policy %q: ttls.dnskey: %w", policyName, err)
 		}
@@ -267,7 +296,7 @@ func FinishDnssecPolicy(policyName string, conf *DnssecPolicyConf, out *DnssecPo
 		if marginStr == "" {
 			return fmt.Errorf("dnssec policy %q: clamping.margin is required when clamping.enabled: true", policyName)
 		}
-		d, err := parseExtendedDuration(marginStr)
+		d, err := ParseExtendedDuration(marginStr)
 		if err != nil {
 			return fmt.Errorf("dnssec policy %q: clamping.margin: %w", policyName, err)
 		}
@@ -283,8 +312,25 @@ func FinishDnssecPolicy(policyName string, conf *DnssecPolicyConf, out *DnssecPo
 		out.Clamping.Margin = 0
 	}
 
+	switch smode := strings.TrimSpace(strings.ToLower(conf.Signing.Mode)); smode {
+	case "", SigningModePerRRset:
+		out.Signing.Mode = SigningModePerRRset
+	case SigningModeMerkleLadder:
+		out.Signing.Mode = smode
+	default:
+		return fmt.Errorf("dnssec policy %q: invalid signing.mode %q (want %q or %q)", policyName, conf.Signing.Mode, SigningModePerRRset, SigningModeMerkleLadder)
+	}
+	switch n := conf.Signing.LadderMinLeaves; {
+	case n < 0:
+		return fmt.Errorf("dnssec policy %q: signing.ladder-min-leaves must be non-negative", policyName)
+	case n == 0:
+		out.Signing.LadderMinLeaves = defaultLadderMinLeaves
+	default:
+		out.Signing.LadderMinLeaves = n
+	}
+
 	if s := strings.TrimSpace(conf.Ttls.ParentDS); s != "" {
-		d, err := parseExtendedDuration(s)
+		d, err := ParseExtendedDuration(s)
 		if err != nil {
 			return fmt.Errorf("dnssec policy %q: ttls.parent-ds: %w", policyName, err)
 		}
@@ -295,7 +341,7 @@ func FinishDnssecPolicy(policyName string, conf *DnssecPolicyConf, out *DnssecPo
 	}
 
 	if s := strings.TrimSpace(conf.Ttls.DS); s != "" {
-		d, err := parseExtendedDuration(s)
+		d, err := ParseExtendedDuration(s)
 		if err != nil {
 			return fmt.Errorf("dnssec policy %q: ttls.ds: %w", policyName, err)
 		}
@@ -314,7 +360,7 @@ func FinishDnssecPolicy(policyName string, conf *DnssecPolicyConf, out *DnssecPo
 	// max_served must be parsed AFTER clamping so the cross-check against
 	// clamping.margin sees the resolved margin value.
 	if s := strings.TrimSpace(conf.Ttls.MaxServed); s != "" {
-		d, err := parseExtendedDuration(s)
+		d, err := ParseExtendedDuration(s)
 		if err != nil {
 			return fmt.Errorf("dnssec policy %q: ttls.max_served: %w", policyName, err)
 		}
@@ -348,7 +394,7 @@ func parsePolicySigValidity(policyName string, conf DnssecPolicySigValidityConf)
 	if defaultStr == "" {
 		return PolicySigValidity{}, fmt.Errorf("dnssec policy %q: sigvalidity.default is required", policyName)
 	}
-	defaultDur, err := parseExtendedDuration(defaultStr)
+	defaultDur, err := ParseExtendedDuration(defaultStr)
 	if err != nil {
 		return PolicySigValidity{}, fmt.Errorf("dnssec policy %q: sigvalidity.default: %w", policyName, err)
 	}
@@ -361,7 +407,7 @@ func parsePolicySigValidity(policyName string, conf DnssecPolicySigValidityConf)
 	if dnskeyStr == "" {
 		out.DNSKEY = out.Default
 	} else {
-		d, err := parseExtendedDuration(dnskeyStr)
+		d, err := ParseExtendedDuration(dnskeyStr)
 		if err != nil {
 			return PolicySigValidity{}, fmt.Errorf("dnssec policy %q: sigvalidity.dnskey: %w", policyName, err)
 		}
@@ -375,7 +421,7 @@ func parsePolicySigValidity(policyName string, conf DnssecPolicySigValidityConf)
 	if dsStr == "" {
 		out.DS = out.Default
 	} else {
-		d, err := parseExtendedDuration(dsStr)
+		d, err := ParseExtendedDuration(dsStr)
 		if err != nil {
 			return PolicySigValidity{}, fmt.Errorf("dnssec policy %q: sigvalidity.ds: %w", policyName, err)
 		}
@@ -384,6 +430,28 @@ func parsePolicySigValidity(policyName string, conf DnssecPolicySigValidityConf)
 		}
 		out.DS = uint32(d.Seconds())
 	}
+
+	// jitter spreads expirations so that signatures made in one pass fall
+	// due for re-signing over a window rather than all at once. Default is
+	// a tenth of the default validity; "0" leaves only the one-minute
+	// minimum spread.
+	jitterStr := strings.TrimSpace(conf.Jitter)
+	if jitterStr == "" {
+		out.Jitter = out.Default / 10
+	} else {
+		d, err := ParseExtendedDuration(jitterStr)
+		if err != nil {
+			return PolicySigValidity{}, fmt.Errorf("dnssec policy %q: sigvalidity.jitter: %w", policyName, err)
+		}
+		if d < 0 {
+			return PolicySigValidity{}, fmt.Errorf("dnssec policy %q: sigvalidity.jitter must not be negative", policyName)
+		}
+		if d >= defaultDur {
+			return PolicySigValidity{}, fmt.Errorf("dnssec policy %q: sigvalidity.jitter (%s) must be shorter than sigvalidity.default (%s)",
+				policyName, d, defaultDur)
+		}
+		out.Jitter = uint32(d.Seconds())
+	}
 	return out, nil
 }
 
@@ -417,7 +485,7 @@ func fillRolloverDurations(policyName string, conf *DnssecPolicyConf, out *Dnsse
 		if val == "" {
 			return def, nil
 		}
-		d, err := parseExtendedDuration(val)
+		d, err := ParseExtendedDuration(val)
 		if err != nil {
 			return 0, fmt.Errorf("rollover.%s: %w", field, err)
 		}
@@ -155,11 +155,20 @@ func SetRolloverPhase(kdb *KeyDB, zone, phase string) error {
 	if err := EnsureRolloverZoneRow(kdb, zone); err != nil {
 		return err
 	}
-	now := time.Now().UTC().Format(time.RFC3339)
+	var old string
+	_ = kdb.DB.QueryRow(`SELECT rollover_phase FROM RolloverZoneState WHERE zone = ?`, zone).Scan(&old)
+	now := keyStateNow().UTC().Format(time.RFC3339)
 	_, err := kdb.DB.Exec(`UPDATE RolloverZoneState SET rollover_phase = ?, rollover_phase_at = ? WHERE zone = ?`, phase, now, zone)
+	if err == nil && old != phase {
+		emitRolloverPhase(zone, old, phase)
+	}
 	return err
 }
 
+func emitRolloverPhase(zone, old, phase string) {
+	emitEvent(EventRolloverPhase, zone, map[string]interface{}{"old_phase": old, "new_phase": phase})
+}
+
 func saveLastDSConfirmedRange(kdb *KeyDB, zone string, low, high int) error {
 	const q = `
 INSERT INTO RolloverZoneState (zone, last_ds_confirmed_index_low, last_ds_confirmed_index_high, last_ds_confirmed_at, rollover_phase, rollover_in_progress, next_rollover_index)
@@ -168,7 +177,7 @@ ON CONFLICT(zone) DO UPDATE SET
   last_ds_confirmed_index_low = excluded.last_ds_confirmed_index_low,
   last_ds_confirmed_index_high = excluded.last_ds_confirmed_index_high,
   last_ds_confirmed_at = excluded.last_ds_confirmed_at`
-	now := time.Now().UTC().Format(time.RFC3339)
+	now := keyStateNow().UTC().Format(time.RFC3339)
 	_, err := kdb.DB.Exec(q, zone, low, high, now)
 	return err
 }
@@ -198,7 +207,7 @@ func insertRolloverKeyStateTx(tx *Tx, zone string, keyid uint16, rolloverIndex i
 	default:
 		meth = "none"
 	}
-	now := time.Now().UTC().Format(time.RFC3339)
+	now := keyStateNow().UTC().Format(time.RFC3339)
 	const q = `
 INSERT INTO RolloverKeyState (zone, keyid, rollover_index, rollover_method, rollover_state_at)
 VALUES (?, ?, ?, ?, ?)`
@@ -226,15 +235,21 @@ func setRolloverKeyDsObservedAt(kdb *KeyDB, zone string, keyid uint16, at time.T
 
 // setRolloverPhaseTx updates the zone's rollover_phase on an existing TX.
 // The caller is responsible for row existence (EnsureRolloverZoneRow).
+// The phase-change event is emitted only once the TX commits.
 func setRolloverPhaseTx(tx *Tx, zone, phase string) error {
-	now := time.Now().UTC().Format(time.RFC3339)
+	var old string
+	_ = tx.QueryRow(`SELECT rollover_phase FROM RolloverZoneState WHERE zone = ?`, zone).Scan(&old)
+	now := keyStateNow().UTC().Format(time.RFC3339)
 	_, err := tx.Exec(`UPDATE RolloverZoneState SET rollover_phase = ?, rollover_phase_at = ? WHERE zone = ?`, phase, now, zone)
+	if err == nil && old != phase {
+		tx.afterCommit(func() { emitRolloverPhase(zone, old, phase) })
+	}
 	return err
 }
 
 // saveLastDSConfirmedRangeTx persists the confirmed DS index range on an existing TX.
 func saveLastDSConfirmedRangeTx(tx *Tx, zone string, low, high int) error {
-	now := time.Now().UTC().Format(time.RFC3339)
+	now := keyStateNow().UTC().Format(time.RFC3339)
 	_, err := tx.Exec(`UPDATE RolloverZoneState
 SET last_ds_confirmed_index_low = ?,
     last_ds_confirmed_index_high = ?,
@@ -525,7 +540,7 @@ func UpsertZoneSigningMaxTTL(kdb *KeyDB, zone string, maxTTL uint32) error {
 	if zone == "" {
 		return fmt.Errorf("UpsertZoneSigningMaxTTL: empty zone")
 	}
-	now := time.Now().UTC().Format(time.RFC3339)
+	now := keyStateNow().UTC().Format(time.RFC3339)
 	const q = `
 INSERT INTO ZoneSigningState (zone, max_observed_ttl, updated_at)
 VALUES (?, ?, ?)
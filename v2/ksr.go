@@ -0,0 +1,836 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// Offline KSK operation (policy ksk.offline). The KSK private keys live in
+// a separate keystore on an air-gapped machine, as with the root zone's
+// KSR/SKR process:
+//
+//  1. tdns-auth exports a Key Signing Request: the ZSKs the zone will
+//     publish in each of a series of signing periods (ksr-export).
+//  2. "tdns-cli auth keystore ksk-sign" signs the request with the offline
+//     KSKs and produces a Signed Key Response: per period, the complete
+//     DNSKEY RRset (KSKs + ZSKs), the CDS/CDNSKEY RRsets and their RRSIGs.
+//  3. tdns-auth verifies and imports the response (skr-import) and serves,
+//     for each period, the pre-signed DNSKEY RRset of its bundle. The ZSK
+//     rollover engine only rolls to a ZSK that the current bundle carries.
+//
+// The KSKs are never in tdns-auth's keystore: an offline zone has ZSKs only,
+// and its DNSKEY, CDS and CDNSKEY RRsets come from the SKR.
+
+// skrInceptionSkew backdates bundle signatures to allow for clock skew.
+const skrInceptionSkew = time.Hour
+
+// KeySigningRequest is the request tdns-auth exports for the offline KSK.
+type KeySigningRequest struct {
+	Zone        string      `json:"zone"`
+	RequestId   string      `json:"request_id"`
+	Created     time.Time   `json:"created"`
+	Algorithm   string      `json:"algorithm"`    // policy KSK algorithm
+	DnskeyTTL   uint32      `json:"dnskey_ttl"`   // TTL of the signed RRsets
+	SigValidity uint32      `json:"sig_validity"` // seconds, from the start of each period
+	Periods     []KsrPeriod `json:"periods"`
+}
+
+// KsrPeriod is one signing period of a KSR and the ZSKs published during it.
+type KsrPeriod struct {
+	Inception  time.Time `json:"inception"`
+	Expiration time.Time `json:"expiration"`
+	ZSKs       []string  `json:"zsks"` // DNSKEY RRs, zone file format
+}
+
+// SignedKeyResponse is what the offline KSK signer returns for a KSR.
+type SignedKeyResponse struct {
+	Zone      string      `json:"zone"`
+	RequestId string      `json:"request_id"`
+	Created   time.Time   `json:"created"`
+	Bundles   []SkrBundle `json:"bundles"`
+}
+
+// SkrBundle is the signed key material for one period.
+type SkrBundle struct {
+	Inception  time.Time `json:"inception"`
+	Expiration time.Time `json:"expiration"`
+	DNSKEY     []string  `json:"dnskey"`
+	CDS        []string  `json:"cds,omitempty"`
+	CDNSKEY    []string  `json:"cdnskey,omitempty"`
+	RRSIGs     []string  `json:"rrsigs"` // over DNSKEY, CDS and CDNSKEY
+}
+
+// SkrBundleInfo describes an imported bundle, for "skr-list".
+type SkrBundleInfo struct {
+	Inception  time.Time
+	Expiration time.Time
+	RequestId  string
+	KSKs       []uint16
+	ZSKs       []uint16
+	CDS        bool
+	Current    bool // covers now
+}
+
+// offlineKSK reports whether the zone's KSK is kept offline.
+func (zd *ZoneData) offlineKSK() bool {
+	return zd.DnssecPolicy != nil && zd.DnssecPolicy.OfflineKSK
+}
+
+// skrSigned reports whether the RRset name/rrtype is signed by the offline
+// KSK, i.e. is served with the signatures of the SKR bundle and must be
+// left alone by the online signer.
+func (zd *ZoneData) skrSigned(name string, rrtype uint16) bool {
+	if !zd.offlineKSK() || name != zd.ZoneName {
+		return false
+	}
+	return rrtype == dns.TypeDNSKEY || rrtype == dns.TypeCDS || rrtype == dns.TypeCDNSKEY
+}
+
+// ksrZsk is a ZSK in the zone's pipeline, as seen by BuildKSR.
+type ksrZsk struct {
+	keyid     uint16
+	state     string
+	keyrr     string
+	activeAt  time.Time
+	retiredAt time.Time
+}
+
+// BuildKSR builds a request covering periods signing periods of periodLen
+// from start, within tx. The ZSKs of each period are predicted from the
+// ZSK pipeline: the active ZSK, then the standby and published ZSKs in the
+// order the rollover engine promotes them, each active for the policy's
+// ZSK lifetime and published until its removal margin has passed after
+// retirement. ZSKs the pipeline lacks for the rolls due within the request
+// are generated, in the published state, and returned in generated.
+func (kdb *KeyDB) BuildKSR(tx *Tx, zd *ZoneData, start time.Time, periods int, periodLen time.Duration) (ksr *KeySigningRequest, generated []uint16, err error) {
+	pol := zd.DnssecPolicy
+	if !zd.offlineKSK() {
+		return nil, nil, fmt.Errorf("zone %s does not use an offline KSK (policy ksk.offline)", zd.ZoneName)
+	}
+	if periods < 1 || periodLen < time.Hour {
+		return nil, nil, fmt.Errorf("a KSR needs at least one period of at least an hour")
+	}
+	dnskeyTTL := pol.TTLS.DNSKEY
+	if dnskeyTTL == 0 {
+		dnskeyTTL = 3600
+	}
+	// The last signatures of a period must outlive copies of the RRset
+	// cached just before the period ends, wherever they are.
+	minValidity := periodLen + time.Duration(dnskeyTTL)*time.Second + Conf.KaspPropagationDelay()
+	if validity := time.Duration(pol.SigValidity.DNSKEY) * time.Second; validity < minValidity {
+		return nil, nil, fmt.Errorf("sigvalidity.dnskey (%s) is shorter than the signing period plus the DNSKEY TTL and propagation delay (%s)", validity, minValidity)
+	}
+	maxTTL, err := LoadZoneSigningMaxTTL(kdb, zd.ZoneName)
+	if err != nil {
+		maxTTL = 0
+	}
+	margin := zskRemovalMargin(Conf.KaspPropagationDelay(), maxTTL)
+
+	rows, err := tx.Query(`SELECT keyid, state, COALESCE(keyrr, ''), COALESCE(active_at, ''), COALESCE(retired_at, '')
+FROM DnssecKeyStore WHERE zonename=? AND flags=256 AND state IN (?, ?, ?, ?) ORDER BY published_at ASC, keyid ASC`,
+		zd.ZoneName, DnskeyStateActive, DnskeyStateStandby, DnskeyStatePublished, DnskeyStateRetired)
+	if err != nil {
+		return nil, nil, err
+	}
+	var active *ksrZsk
+	var standby, published, retired []ksrZsk
+	for rows.Next() {
+		var k ksrZsk
+		var activeAt, retiredAt string
+		if err := rows.Scan(&k.keyid, &k.state, &k.keyrr, &activeAt, &retiredAt); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		k.activeAt, _ = time.Parse(time.RFC3339, activeAt)
+		k.retiredAt, _ = time.Parse(time.RFC3339, retiredAt)
+		switch k.state {
+		case DnskeyStateActive:
+			if active == nil {
+				active = &k
+			}
+		case DnskeyStateStandby:
+			standby = append(standby, k)
+		case DnskeyStatePublished:
+			published = append(published, k)
+		case DnskeyStateRetired:
+			retired = append(retired, k)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if active == nil {
+		return nil, nil, fmt.Errorf("zone %s has no active ZSK", zd.ZoneName)
+	}
+	if active.activeAt.IsZero() {
+		active.activeAt = start
+	}
+
+	// The roll order: the active ZSK, then standbys, then published keys.
+	pipeline := append([]ksrZsk{*active}, append(standby, published...)...)
+	end := start.Add(time.Duration(periods) * periodLen)
+	lifetime := time.Duration(pol.ZSK.Lifetime) * time.Second
+	if lifetime > 0 {
+		rolls := 0
+		for t := active.activeAt.Add(lifetime); t.Before(end); t = t.Add(lifetime) {
+			rolls++
+		}
+		for len(pipeline) < rolls+1 {
+			pkc, _, err := kdb.GenerateKeypair(zd.ZoneName, "ksr-export", DnskeyStatePublished, dns.TypeDNSKEY, pol.ZSKAlgorithm, "ZSK", tx)
+			if err != nil {
+				return nil, nil, fmt.Errorf("generating ZSK for the KSR: %w", err)
+			}
+			generated = append(generated, pkc.KeyId)
+			pipeline = append(pipeline, ksrZsk{keyid: pkc.KeyId, state: DnskeyStatePublished, keyrr: pkc.DnskeyRR.String()})
+		}
+	}
+
+	ksr = &KeySigningRequest{
+		Zone:        zd.ZoneName,
+		RequestId:   fmt.Sprintf("%s-%s", strings.TrimSuffix(zd.ZoneName, "."), start.UTC().Format("20060102T150405Z")),
+		Created:     time.Now().UTC(),
+		Algorithm:   dns.AlgorithmToString[pol.KSKAlgorithm],
+		DnskeyTTL:   dnskeyTTL,
+		SigValidity: pol.SigValidity.DNSKEY,
+	}
+	for p := 0; p < periods; p++ {
+		period := KsrPeriod{
+			Inception:  start.Add(time.Duration(p) * periodLen).UTC(),
+			Expiration: start.Add(time.Duration(p+1) * periodLen).UTC(),
+		}
+		for _, k := range retired {
+			if !k.retiredAt.IsZero() && period.Inception.Before(k.retiredAt.Add(margin)) {
+				period.ZSKs = append(period.ZSKs, k.keyrr)
+			}
+		}
+		for j, k := range pipeline {
+			if lifetime == 0 || period.Inception.Before(active.activeAt.Add(time.Duration(j+1)*lifetime+margin)) {
+				period.ZSKs = append(period.ZSKs, k.keyrr)
+			}
+		}
+		ksr.Periods = append(ksr.Periods, period)
+	}
+	return ksr, generated, nil
+}
+
+// SignKSR answers ksr: for each period it builds the DNSKEY RRset from the
+// requested ZSKs and the KSKs in ksks and extra, and signs it (and, if cds
+// is set, CDS and CDNSKEY RRsets for ksks) with every key in ksks. extra
+// holds KSKs to publish without signing, e.g. the next KSK of a rollover.
+func SignKSR(ksr *KeySigningRequest, ksks []*PrivateKeyCache, extra []dns.DNSKEY, cds bool) (*SignedKeyResponse, error) {
+	zone := dns.Fqdn(ksr.Zone)
+	if len(ksks) == 0 {
+		return nil, fmt.Errorf("no active KSKs for zone %s in the offline keystore", zone)
+	}
+	if len(ksr.Periods) == 0 {
+		return nil, fmt.Errorf("the KSR has no periods")
+	}
+	hdr := func(rrtype uint16) dns.RR_Header {
+		return dns.RR_Header{Name: zone, Rrtype: rrtype, Class: dns.ClassINET, Ttl: ksr.DnskeyTTL}
+	}
+
+	skr := &SignedKeyResponse{Zone: zone, RequestId: ksr.RequestId, Created: time.Now().UTC()}
+	for _, p := range ksr.Periods {
+		if !p.Expiration.After(p.Inception) {
+			return nil, fmt.Errorf("period %s: expiration is not after inception", p.Inception)
+		}
+		expiration := p.Inception.Add(time.Duration(ksr.SigValidity) * time.Second)
+		if expiration.Before(p.Expiration) {
+			return nil, fmt.Errorf("period %s: sig_validity ends before the period does", p.Inception)
+		}
+
+		var dnskeys, cdss, cdnskeys []dns.RR
+		for _, k := range ksks {
+			key := k.DnskeyRR
+			key.Hdr = hdr(dns.TypeDNSKEY)
+			dnskeys = append(dnskeys, &key)
+			if cds {
+				ds := key.ToDS(dns.SHA256)
+				if ds == nil {
+					return nil, fmt.Errorf("cannot compute DS for KSK %d", key.KeyTag())
+				}
+				cds := &dns.CDS{DS: *ds}
+				cds.Hdr = hdr(dns.TypeCDS)
+				cdss = append(cdss, cds)
+				cdnskey := &dns.CDNSKEY{DNSKEY: key}
+				cdnskey.Hdr = hdr(dns.TypeCDNSKEY)
+				cdnskeys = append(cdnskeys, cdnskey)
+			}
+		}
+		for i := range extra {
+			key := extra[i]
+			key.Hdr = hdr(dns.TypeDNSKEY)
+			dnskeys = append(dnskeys, &key)
+		}
+		for _, s := range p.ZSKs {
+			rr, err := dns.NewRR(s)
+			if err != nil {
+				return nil, fmt.Errorf("period %s: ZSK %q: %v", p.Inception, s, err)
+			}
+			key, ok := rr.(*dns.DNSKEY)
+			if !ok || dns.Fqdn(key.Hdr.Name) != zone {
+				return nil, fmt.Errorf("period %s: %q is not a DNSKEY for %s", p.Inception, s, zone)
+			}
+			if key.Flags&dns.SEP != 0 {
+				return nil, fmt.Errorf("period %s: the KSR asks to sign a key with the SEP flag (key %d)", p.Inception, key.KeyTag())
+			}
+			key.Hdr = hdr(dns.TypeDNSKEY)
+			dnskeys = append(dnskeys, key)
+		}
+
+		b := SkrBundle{Inception: p.Inception.UTC(), Expiration: p.Expiration.UTC()}
+		for _, set := range [][]dns.RR{dnskeys, cdss, cdnskeys} {
+			if len(set) == 0 {
+				continue
+			}
+			for _, k := range ksks {
+				sig := &dns.RRSIG{
+					Hdr:        dns.RR_Header{Name: zone, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: ksr.DnskeyTTL},
+					KeyTag:     k.DnskeyRR.KeyTag(),
+					Algorithm:  k.DnskeyRR.Algorithm,
+					SignerName: zone,
+					Inception:  uint32(p.Inception.Add(-skrInceptionSkew).Unix()),
+					Expiration: uint32(expiration.Unix()),
+				}
+				if err := sig.Sign(k.CS, set); err != nil {
+					return nil, fmt.Errorf("period %s: signing with KSK %d: %v", p.Inception, sig.KeyTag, err)
+				}
+				b.RRSIGs = append(b.RRSIGs, sig.String())
+			}
+		}
+		b.DNSKEY = rrStrings(dnskeys)
+		b.CDS = rrStrings(cdss)
+		b.CDNSKEY = rrStrings(cdnskeys)
+		skr.Bundles = append(skr.Bundles, b)
+	}
+	return skr, nil
+}
+
+func rrStrings(rrs []dns.RR) []string {
+	var out []string
+	for _, rr := range rrs {
+		out = append(out, rr.String())
+	}
+	return out
+}
+
+// skrRRsets is a bundle parsed into RRsets, with the RRSIGs attached.
+type skrRRsets struct {
+	DNSKEY, CDS, CDNSKEY core.RRset
+}
+
+// parse parses b for zone and checks that it is internally consistent: it
+// has at least one SEP DNSKEY, every RRset is signed by one, and every
+// signature verifies and is valid for the whole period.
+func (b *SkrBundle) parse(zone string) (*skrRRsets, error) {
+	out := &skrRRsets{
+		DNSKEY:  core.RRset{Name: zone, Class: dns.ClassINET, RRtype: dns.TypeDNSKEY},
+		CDS:     core.RRset{Name: zone, Class: dns.ClassINET, RRtype: dns.TypeCDS},
+		CDNSKEY: core.RRset{Name: zone, Class: dns.ClassINET, RRtype: dns.TypeCDNSKEY},
+	}
+	sets := map[uint16]*core.RRset{dns.TypeDNSKEY: &out.DNSKEY, dns.TypeCDS: &out.CDS, dns.TypeCDNSKEY: &out.CDNSKEY}
+	for _, s := range append(append(append([]string{}, b.DNSKEY...), b.CDS...), b.CDNSKEY...) {
+		rr, err := dns.NewRR(s)
+		if err != nil || rr == nil {
+			return nil, fmt.Errorf("bad RR %q: %v", s, err)
+		}
+		set := sets[rr.Header().Rrtype]
+		if set == nil || dns.Fqdn(rr.Header().Name) != zone {
+			return nil, fmt.Errorf("unexpected RR %q", s)
+		}
+		set.RRs = append(set.RRs, rr)
+	}
+	ksks := map[uint16]*dns.DNSKEY{}
+	for _, rr := range out.DNSKEY.RRs {
+		if key := rr.(*dns.DNSKEY); key.Flags&dns.SEP != 0 {
+			ksks[key.KeyTag()] = key
+		}
+	}
+	if len(ksks) == 0 {
+		return nil, fmt.Errorf("no KSK in the DNSKEY RRset")
+	}
+	for _, s := range b.RRSIGs {
+		rr, err := dns.NewRR(s)
+		if err != nil || rr == nil {
+			return nil, fmt.Errorf("bad RRSIG %q: %v", s, err)
+		}
+		sig, ok := rr.(*dns.RRSIG)
+		if !ok {
+			return nil, fmt.Errorf("%q is not an RRSIG", s)
+		}
+		set := sets[sig.TypeCovered]
+		key := ksks[sig.KeyTag]
+		if set == nil || key == nil || len(set.RRs) == 0 {
+			return nil, fmt.Errorf("RRSIG %s/%d covers no bundle RRset or is not by a KSK", dns.TypeToString[sig.TypeCovered], sig.KeyTag)
+		}
+		if err := sig.Verify(key, set.RRs); err != nil {
+			return nil, fmt.Errorf("RRSIG %s by KSK %d does not verify: %v", dns.TypeToString[sig.TypeCovered], sig.KeyTag, err)
+		}
+		if !sig.ValidityPeriod(b.Inception) || !sig.ValidityPeriod(b.Expiration.Add(-time.Second)) {
+			return nil, fmt.Errorf("RRSIG %s by KSK %d is not valid for the whole period", dns.TypeToString[sig.TypeCovered], sig.KeyTag)
+		}
+		set.RRSIGs = append(set.RRSIGs, sig)
+	}
+	for _, set := range []*core.RRset{&out.DNSKEY, &out.CDS, &out.CDNSKEY} {
+		if len(set.RRs) > 0 && len(set.RRSIGs) == 0 {
+			return nil, fmt.Errorf("the %s RRset is not signed", dns.TypeToString[set.RRtype])
+		}
+	}
+	return out, nil
+}
+
+// signedBy returns the KSKs in the bundle whose signature over the DNSKEY
+// RRset verified in parse.
+func (r *skrRRsets) signedBy() []*dns.DNSKEY {
+	var out []*dns.DNSKEY
+	for _, rr := range r.DNSKEY.RRs {
+		key := rr.(*dns.DNSKEY)
+		if key.Flags&dns.SEP == 0 {
+			continue
+		}
+		for _, sig := range r.DNSKEY.RRSIGs {
+			if s := sig.(*dns.RRSIG); s.KeyTag == key.KeyTag() && s.Algorithm == key.Algorithm {
+				out = append(out, key)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// skrKeyId identifies a KSK by more than its key tag.
+func skrKeyId(key *dns.DNSKEY) string {
+	return fmt.Sprintf("%d/%d/%s", key.Algorithm, key.KeyTag(), key.PublicKey)
+}
+
+// skrTrustedKsks returns the KSKs an SKR for zone may be signed by without
+// a look at the parent: the zone's KSKs in the keystore and the KSKs of the
+// bundles imported before, which were trusted when they were imported.
+func skrTrustedKsks(tx *Tx, zone string) (map[string]bool, error) {
+	trusted := map[string]bool{}
+	rows, err := tx.Query(`SELECT COALESCE(keyrr, '') FROM DnssecKeyStore WHERE zonename=? AND (flags & 1)=1`, zone)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var keyrr string
+		if err := rows.Scan(&keyrr); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if rr, err := dns.NewRR(keyrr); err == nil && rr != nil {
+			if key, ok := rr.(*dns.DNSKEY); ok {
+				trusted[skrKeyId(key)] = true
+			}
+		}
+	}
+	rows.Close()
+	bundles, err := LoadSkrBundles(tx, zone)
+	if err != nil {
+		return nil, err
+	}
+	for _, sb := range bundles {
+		for _, s := range sb.Bundle.DNSKEY {
+			if rr, err := dns.NewRR(s); err == nil && rr != nil {
+				if key, ok := rr.(*dns.DNSKEY); ok && key.Flags&dns.SEP != 0 {
+					trusted[skrKeyId(key)] = true
+				}
+			}
+		}
+	}
+	return trusted, nil
+}
+
+// zskTags returns the key tags of the non-SEP keys in the bundle.
+func (r *skrRRsets) zskTags() map[uint16]bool {
+	tags := map[uint16]bool{}
+	for _, rr := range r.DNSKEY.RRs {
+		if key := rr.(*dns.DNSKEY); key.Flags&dns.SEP == 0 {
+			tags[key.KeyTag()] = true
+		}
+	}
+	return tags
+}
+
+// ImportSKR verifies skr and stores its bundles for the zone, within tx,
+// replacing the stored bundles from the first new period on. Every ZSK in
+// the response must be one of the zone's keys, and every bundle must be
+// signed by a trusted KSK: one in the keystore, one of a bundle imported
+// before or published in an earlier bundle of this response, or one that
+// a DS record of the zone at the parent points at. lookupDS fetches that
+// DS RRset; it is only called when needed and may be nil. Returns the
+// number of bundles imported.
+func (kdb *KeyDB) ImportSKR(tx *Tx, zone string, skr *SignedKeyResponse, lookupDS func(zone string) ([]dns.RR, error)) (int, error) {
+	zone = dns.Fqdn(zone)
+	if dns.Fqdn(skr.Zone) != zone {
+		return 0, fmt.Errorf("the SKR is for zone %s, not %s", skr.Zone, zone)
+	}
+	if len(skr.Bundles) == 0 {
+		return 0, fmt.Errorf("the SKR has no bundles")
+	}
+	known := map[uint16]bool{}
+	rows, err := tx.Query(`SELECT keyid FROM DnssecKeyStore WHERE zonename=? AND flags=256`, zone)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var keyid uint16
+		if err := rows.Scan(&keyid); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		known[keyid] = true
+	}
+	rows.Close()
+
+	trusted, err := skrTrustedKsks(tx, zone)
+	if err != nil {
+		return 0, err
+	}
+	var parentDS []dns.RR
+	var dsErr error
+	dsLooked := false
+	// trustedByDS reports whether a DS record at the parent points at key.
+	trustedByDS := func(key *dns.DNSKEY) bool {
+		if !dsLooked {
+			dsLooked = true
+			if lookupDS == nil {
+				dsErr = fmt.Errorf("no DS lookup available")
+			} else {
+				parentDS, dsErr = lookupDS(zone)
+			}
+		}
+		for _, rr := range parentDS {
+			ds, ok := rr.(*dns.DS)
+			if !ok {
+				continue
+			}
+			if kds := key.ToDS(ds.DigestType); kds != nil && ds.KeyTag == kds.KeyTag &&
+				ds.Algorithm == kds.Algorithm && strings.EqualFold(ds.Digest, kds.Digest) {
+				return true
+			}
+		}
+		return false
+	}
+
+	bundles := append([]SkrBundle(nil), skr.Bundles...)
+	sort.Slice(bundles, func(i, j int) bool { return bundles[i].Inception.Before(bundles[j].Inception) })
+	for i := range bundles {
+		b := &bundles[i]
+		parsed, err := b.parse(zone)
+		if err != nil {
+			return 0, fmt.Errorf("bundle %s: %w", b.Inception.Format(time.RFC3339), err)
+		}
+		vouched := false
+		for _, key := range parsed.signedBy() {
+			if trusted[skrKeyId(key)] || trustedByDS(key) {
+				vouched = true
+				break
+			}
+		}
+		if !vouched {
+			msg := fmt.Sprintf("bundle %s: not signed by a KSK of zone %s in the keystore, in an imported bundle or with a DS at the parent",
+				b.Inception.Format(time.RFC3339), zone)
+			if dsErr != nil {
+				msg += fmt.Sprintf(" (DS lookup: %v)", dsErr)
+			}
+			return 0, fmt.Errorf("%s", msg)
+		}
+		// A trusted DNSKEY RRset vouches for the KSKs it publishes, which
+		// carries the trust across a KSK rollover inside the response.
+		for _, rr := range parsed.DNSKEY.RRs {
+			if key := rr.(*dns.DNSKEY); key.Flags&dns.SEP != 0 {
+				trusted[skrKeyId(key)] = true
+			}
+		}
+		for tag := range parsed.zskTags() {
+			if !known[tag] {
+				return 0, fmt.Errorf("bundle %s: ZSK %d is not a key of zone %s", b.Inception.Format(time.RFC3339), tag, zone)
+			}
+		}
+		if i > 0 && b.Inception.Before(bundles[i-1].Expiration) {
+			return 0, fmt.Errorf("bundle %s overlaps the previous period", b.Inception.Format(time.RFC3339))
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM SkrBundles WHERE zone=? AND (inception>=? OR expiration<?)`,
+		zone, bundles[0].Inception.Unix(), time.Now().Add(-24*time.Hour).Unix()); err != nil {
+		return 0, err
+	}
+	for _, b := range bundles {
+		data, err := json.Marshal(b)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO SkrBundles (zone, inception, expiration, request_id, bundle, imported_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			zone, b.Inception.Unix(), b.Expiration.Unix(), skr.RequestId, string(data), time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return 0, err
+		}
+	}
+	return len(bundles), nil
+}
+
+// storedSkrBundle is a bundle as stored in SkrBundles.
+type storedSkrBundle struct {
+	RequestId string
+	Bundle    SkrBundle
+}
+
+// LoadSkrBundles returns the zone's imported bundles, oldest period first.
+// q is the KeyDB or, inside a transaction, the Tx.
+func LoadSkrBundles(q rowsQuerier, zone string) ([]storedSkrBundle, error) {
+	rows, err := q.Query(`SELECT request_id, bundle FROM SkrBundles WHERE zone=? ORDER BY inception ASC`, dns.Fqdn(zone))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []storedSkrBundle
+	for rows.Next() {
+		var sb storedSkrBundle
+		var data string
+		if err := rows.Scan(&sb.RequestId, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &sb.Bundle); err != nil {
+			return nil, fmt.Errorf("stored SKR bundle for %s: %v", zone, err)
+		}
+		out = append(out, sb)
+	}
+	return out, rows.Err()
+}
+
+// selectSkrBundle returns the bundle to serve at now: the one whose period
+// covers now and carries every ZSK in need. Among bundles for the same
+// period the latest import wins (it is stored last).
+func selectSkrBundle(zone string, bundles []storedSkrBundle, now time.Time, need []uint16) (*SkrBundle, *skrRRsets, error) {
+	for i := len(bundles) - 1; i >= 0; i-- {
+		b := &bundles[i].Bundle
+		if now.Before(b.Inception) || !now.Before(b.Expiration) {
+			continue
+		}
+		parsed, err := b.parse(zone)
+		if err != nil {
+			return nil, nil, fmt.Errorf("SKR bundle %s: %w", b.Inception.Format(time.RFC3339), err)
+		}
+		tags := parsed.zskTags()
+		covers := true
+		for _, tag := range need {
+			if !tags[tag] {
+				covers = false
+				break
+			}
+		}
+		if covers {
+			return b, parsed, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("no imported SKR bundle for zone %s covers %s with ZSK(s) %v; export a new KSR", zone, now.UTC().Format(time.RFC3339), need)
+}
+
+// skrCoversZSK reports whether the bundle for now carries ZSK keyid.
+func skrCoversZSK(kdb *KeyDB, zone string, now time.Time, keyid uint16) bool {
+	bundles, err := LoadSkrBundles(kdb, zone)
+	if err != nil {
+		return false
+	}
+	_, _, err = selectSkrBundle(zone, bundles, now, []uint16{keyid})
+	return err == nil
+}
+
+// SkrBundleInfos describes the zone's imported bundles.
+func SkrBundleInfos(q rowsQuerier, zone string, now time.Time) ([]SkrBundleInfo, error) {
+	bundles, err := LoadSkrBundles(q, zone)
+	if err != nil {
+		return nil, err
+	}
+	var out []SkrBundleInfo
+	for _, sb := range bundles {
+		info := SkrBundleInfo{
+			Inception:  sb.Bundle.Inception,
+			Expiration: sb.Bundle.Expiration,
+			RequestId:  sb.RequestId,
+			CDS:        len(sb.Bundle.CDS) > 0,
+			Current:    !now.Before(sb.Bundle.Inception) && now.Before(sb.Bundle.Expiration),
+		}
+		for _, s := range sb.Bundle.DNSKEY {
+			if rr, err := dns.NewRR(s); err == nil {
+				if key, ok := rr.(*dns.DNSKEY); ok {
+					if key.Flags&dns.SEP != 0 {
+						info.KSKs = append(info.KSKs, key.KeyTag())
+					} else {
+						info.ZSKs = append(info.ZSKs, key.KeyTag())
+					}
+				}
+			}
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// publishSkrDnskeysLocked stages the DNSKEY, CDS and CDNSKEY RRsets of the
+// SKR bundle for now, with their KSK signatures. dak holds the active ZSKs,
+// which the bundle must carry. Caller holds zd.mu.
+func (zd *ZoneData) publishSkrDnskeysLocked(dak *DnssecKeys) error {
+	var need []uint16
+	for _, zsk := range dak.ZSKs {
+		need = append(need, zsk.KeyId)
+	}
+	bundles, err := LoadSkrBundles(zd.KeyDB, zd.ZoneName)
+	if err != nil {
+		return err
+	}
+	b, parsed, err := selectSkrBundle(zd.ZoneName, bundles, time.Now(), need)
+	if err != nil {
+		return err
+	}
+	zd.stageRRsetLocked(zd.ZoneName, parsed.DNSKEY)
+	// The SKR is the only source of CDS/CDNSKEY for an offline zone: a
+	// bundle without them withdraws those of the previous period.
+	for _, set := range []core.RRset{parsed.CDS, parsed.CDNSKEY} {
+		if len(set.RRs) > 0 {
+			zd.stageRRsetLocked(zd.ZoneName, set)
+		} else {
+			zd.stageDeleteLocked(zd.ZoneName, set.RRtype)
+		}
+	}
+	zd.skrPeriod = b.Inception
+	return nil
+}
+
+// RefreshSkrDnskeys republishes the DNSKEY RRset of an offline-KSK zone
+// when a new SKR period has begun since it was last staged. Called from the
+// resigner on every tick.
+func (zd *ZoneData) RefreshSkrDnskeys() error {
+	if !zd.offlineKSK() {
+		return nil
+	}
+	bundles, err := LoadSkrBundles(zd.KeyDB, zd.ZoneName)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	zd.mu.Lock()
+	served := zd.skrPeriod
+	zd.mu.Unlock()
+	for _, sb := range bundles {
+		b := sb.Bundle
+		if !now.Before(b.Inception) && now.Before(b.Expiration) && b.Inception.Equal(served) {
+			return nil
+		}
+	}
+	dak := zd.ActiveDnssecKeys()
+	zd.mu.Lock()
+	defer zd.mu.Unlock()
+	if err := zd.publishSkrDnskeysLocked(dak); err != nil {
+		return err
+	}
+	zd.publishLocked(zd.generation.Load())
+	lgSigner.Info("offline KSK: serving the DNSKEY RRset of a new SKR period", "zone", zd.ZoneName, "period", zd.skrPeriod)
+	return nil
+}
+
+// Defaults for "ksr-export": nine ten-day periods, a quarter's worth, as in
+// the root zone's KSR process.
+const (
+	defaultKsrPeriods = 9
+	defaultKsrPeriod  = 10 * 24 * time.Hour
+)
+
+// dnssecOfflineKsk implements the offline-KSK keystore subcommands
+// ksr-export, skr-import and skr-list, within tx.
+func (kdb *KeyDB) dnssecOfflineKsk(tx *Tx, kp KeystorePost) (*KeystoreResponse, error) {
+	resp := &KeystoreResponse{Time: time.Now(), Zone: kp.Zone}
+	fail := func(err error) (*KeystoreResponse, error) {
+		resp.Error = true
+		resp.ErrorMsg = err.Error()
+		return resp, err
+	}
+	if kp.Zone == "" {
+		return fail(fmt.Errorf("zone is required for %s", kp.SubCommand))
+	}
+	zone := dns.Fqdn(kp.Zone)
+
+	switch kp.SubCommand {
+	case "skr-list":
+		infos, err := SkrBundleInfos(tx, zone, time.Now())
+		if err != nil {
+			return fail(err)
+		}
+		resp.SkrBundles = infos
+		resp.Msg = fmt.Sprintf("%d SKR bundles imported for zone %s", len(infos), zone)
+		return resp, nil
+	}
+
+	zd, exist := Zones.Get(zone)
+	if !exist {
+		return fail(fmt.Errorf("zone %s is unknown", zone))
+	}
+	if !zd.offlineKSK() {
+		return fail(fmt.Errorf("zone %s does not use an offline KSK (policy ksk.offline)", zone))
+	}
+
+	switch kp.SubCommand {
+	case "ksr-export":
+		periods := kp.KsrPeriods
+		if periods == 0 {
+			periods = defaultKsrPeriods
+		}
+		periodLen := defaultKsrPeriod
+		if kp.KsrPeriod != "" {
+			d, err := ParseExtendedDuration(kp.KsrPeriod)
+			if err != nil {
+				return fail(fmt.Errorf("period: %w", err))
+			}
+			periodLen = d
+		}
+		// The request continues where the imported bundles end, so that
+		// consecutive requests tile the timeline.
+		start := time.Now().UTC().Truncate(time.Hour)
+		bundles, err := LoadSkrBundles(tx, zone)
+		if err != nil {
+			return fail(err)
+		}
+		if n := len(bundles); n > 0 && bundles[n-1].Bundle.Expiration.After(start) {
+			start = bundles[n-1].Bundle.Expiration.UTC()
+		}
+		ksr, generated, err := kdb.BuildKSR(tx, zd, start, periods, periodLen)
+		if err != nil {
+			return fail(err)
+		}
+		resp.Ksr = ksr
+		resp.Msg = fmt.Sprintf("KSR %s: %d periods from %s", ksr.RequestId, periods, start.Format(time.RFC3339))
+		if len(generated) > 0 {
+			resp.Msg += fmt.Sprintf("; generated ZSK(s) %v for the rolls it covers", generated)
+		}
+
+	case "skr-import":
+		if kp.Skr == nil {
+			return fail(fmt.Errorf("no SKR in the request"))
+		}
+		lookupDS := func(zone string) ([]dns.RR, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return queryParentDS(ctx, zone)
+		}
+		n, err := kdb.ImportSKR(tx, zone, kp.Skr, lookupDS)
+		if err != nil {
+			return fail(err)
+		}
+		resp.Msg = fmt.Sprintf("SKR %s: imported %d bundles for zone %s", kp.Skr.RequestId, n, zone)
+		lgSigner.Info("offline KSK: SKR imported", "zone", zone, "request", kp.Skr.RequestId, "bundles", n)
+	}
+	return resp, nil
+}
@@ -0,0 +1,231 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package tdns
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// offlineKskTestZone turns signingTestZone into an offline-KSK zone: the
+// online KSK is removed and a KSK is generated in a separate keystore,
+// whose active KSKs are returned. The offline keystore is used with the
+// zone unregistered, as in the CLI: active keys of a registered zone come
+// from its signing-keys snapshot, not from the keystore asked.
+func offlineKskTestZone(t *testing.T) (*ZoneData, *KeyDB, []*PrivateKeyCache) {
+	t.Helper()
+	zd, kdb := signingTestZone(t, 3)
+	if _, err := kdb.Exec(`DELETE FROM DnssecKeyStore WHERE zonename=? AND flags=257`, zd.ZoneName); err != nil {
+		t.Fatal(err)
+	}
+	zd.DnssecPolicy.OfflineKSK = true
+	zd.DnssecPolicy.ZSK.Lifetime = uint32((30 * 24 * time.Hour).Seconds())
+	if err := zd.republishSigningKeys(kdb); err != nil {
+		t.Fatalf("republish: %v", err)
+	}
+
+	Zones.Remove(zd.ZoneName)
+	offline := newTestKeyDB(t)
+	if _, _, err := offline.GenerateKeypair(zd.ZoneName, "test", DnskeyStateActive, dns.TypeDNSKEY, dns.ED25519, "KSK", nil); err != nil {
+		t.Fatalf("offline KSK: %v", err)
+	}
+	ksks, err := offline.GetDnssecKeys(zd.ZoneName, DnskeyStateActive)
+	if err != nil || len(ksks.KSKs) != 1 {
+		t.Fatalf("offline KSKs: %v %v", ksks, err)
+	}
+	Zones.Set(zd.ZoneName, zd)
+	if err := zd.republishSigningKeys(kdb); err != nil {
+		t.Fatalf("republish: %v", err)
+	}
+	return zd, kdb, ksks.KSKs
+}
+
+func TestOfflineKskRoundTrip(t *testing.T) {
+	zd, kdb, ksks := offlineKskTestZone(t)
+	period := 10 * 24 * time.Hour
+
+	// Four ten-day periods span the 30-day ZSK roll: the request needs the
+	// successor ZSK, which BuildKSR generates.
+	tx, err := kdb.Begin("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().UTC().Truncate(time.Hour)
+	ksr, generated, err := kdb.BuildKSR(tx, zd, start, 4, period)
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf("BuildKSR: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(generated) != 1 {
+		t.Fatalf("generated %v, want one successor ZSK", generated)
+	}
+	for _, p := range ksr.Periods {
+		if len(p.ZSKs) != 2 {
+			t.Errorf("period %s has %d ZSKs, want 2", p.Inception, len(p.ZSKs))
+		}
+	}
+
+	ksk := ksks[0].DnskeyRR.KeyTag()
+	skr, err := SignKSR(ksr, ksks, nil, true)
+	if err != nil {
+		t.Fatalf("SignKSR: %v", err)
+	}
+	if len(skr.Bundles) != 4 {
+		t.Fatalf("%d bundles, want 4", len(skr.Bundles))
+	}
+
+	// The first SKR is anchored by the DS at the parent.
+	lookupDS := func(string) ([]dns.RR, error) {
+		return []dns.RR{ksks[0].DnskeyRR.ToDS(dns.SHA256)}, nil
+	}
+
+	// A bundle whose DNSKEY RRset has been altered no longer verifies.
+	bad := *skr
+	bad.Bundles = append([]SkrBundle(nil), skr.Bundles...)
+	bad.Bundles[0].DNSKEY = bad.Bundles[0].DNSKEY[:len(bad.Bundles[0].DNSKEY)-1]
+	tx, _ = kdb.Begin("test")
+	if _, err := kdb.ImportSKR(tx, zd.ZoneName, &bad, lookupDS); err == nil {
+		t.Errorf("ImportSKR accepted a bundle with an altered DNSKEY RRset")
+	}
+	tx.Rollback()
+
+	// A self-consistent SKR signed by some other KSK is refused.
+	Zones.Remove(zd.ZoneName)
+	rogue := newTestKeyDB(t)
+	if _, _, err := rogue.GenerateKeypair(zd.ZoneName, "test", DnskeyStateActive, dns.TypeDNSKEY, dns.ED25519, "KSK", nil); err != nil {
+		t.Fatal(err)
+	}
+	rogueKsks, err := rogue.GetDnssecKeys(zd.ZoneName, DnskeyStateActive)
+	Zones.Set(zd.ZoneName, zd)
+	if err != nil || len(rogueKsks.KSKs) != 1 {
+		t.Fatalf("rogue KSKs: %v %v", rogueKsks, err)
+	}
+	rogueSkr, err := SignKSR(ksr, rogueKsks.KSKs, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, _ = kdb.Begin("test")
+	if _, err := kdb.ImportSKR(tx, zd.ZoneName, rogueSkr, lookupDS); err == nil {
+		t.Errorf("ImportSKR accepted an SKR signed by a KSK without a DS")
+	}
+	tx.Rollback()
+
+	tx, _ = kdb.Begin("test")
+	if n, err := kdb.ImportSKR(tx, zd.ZoneName, skr, lookupDS); err != nil || n != 4 {
+		tx.Rollback()
+		t.Fatalf("ImportSKR: %d %v", n, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Later SKRs by the same KSK need no DS lookup.
+	tx, _ = kdb.Begin("test")
+	if _, err := kdb.ImportSKR(tx, zd.ZoneName, skr, nil); err != nil {
+		t.Errorf("re-import by an already trusted KSK: %v", err)
+	}
+	tx.Rollback()
+
+	if _, err := zd.SignZone(kdb, false); err != nil {
+		t.Fatalf("SignZone: %v", err)
+	}
+	apex := zd.snapshot.Load().Data[zd.ZoneName]
+	dnskeys, _ := apex.RRtypes.Get(dns.TypeDNSKEY)
+	if len(dnskeys.RRs) != 3 {
+		t.Errorf("served DNSKEY RRset has %d keys, want KSK + 2 ZSKs", len(dnskeys.RRs))
+	}
+	if len(dnskeys.RRSIGs) != 1 || dnskeys.RRSIGs[0].(*dns.RRSIG).KeyTag != ksk {
+		t.Errorf("served DNSKEY RRset is not signed by the offline KSK only: %v", dnskeys.RRSIGs)
+	}
+	if cds, _ := apex.RRtypes.Get(dns.TypeCDS); len(cds.RRs) != 1 || len(cds.RRSIGs) != 1 {
+		t.Errorf("served CDS RRset: %d RRs, %d RRSIGs", len(cds.RRs), len(cds.RRSIGs))
+	}
+	soa, _ := apex.RRtypes.Get(dns.TypeSOA)
+	if len(soa.RRSIGs) != 1 || soa.RRSIGs[0].(*dns.RRSIG).KeyTag == ksk {
+		t.Errorf("SOA should be signed by the ZSK: %v", soa.RRSIGs)
+	}
+
+	// The ZSK roll may proceed to the successor while a bundle carries it,
+	// and not past the end of the SKR.
+	if !skrCoversZSK(kdb, zd.ZoneName, time.Now(), generated[0]) {
+		t.Errorf("successor ZSK %d not covered by the current bundle", generated[0])
+	}
+	if skrCoversZSK(kdb, zd.ZoneName, start.Add(41*24*time.Hour), generated[0]) {
+		t.Errorf("ZSK covered beyond the end of the SKR")
+	}
+
+	infos, err := SkrBundleInfos(kdb, zd.ZoneName, time.Now())
+	if err != nil || len(infos) != 4 || !infos[0].Current || !infos[0].CDS {
+		t.Errorf("SkrBundleInfos: %+v %v", infos, err)
+	}
+}
+
+func TestSignKSRRejectsSepKey(t *testing.T) {
+	offline := newTestKeyDB(t)
+	pkc, _, err := offline.GenerateKeypair("offline.example.", "test", DnskeyStateActive, dns.TypeDNSKEY, dns.ED25519, "KSK", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	ksr := &KeySigningRequest{
+		Zone:        "offline.example.",
+		DnskeyTTL:   3600,
+		SigValidity: uint32((14 * 24 * time.Hour).Seconds()),
+		Periods:     []KsrPeriod{{Inception: now, Expiration: now.Add(24 * time.Hour), ZSKs: []string{pkc.DnskeyRR.String()}}},
+	}
+	if _, err := SignKSR(ksr, []*PrivateKeyCache{pkc}, nil, false); err == nil || !strings.Contains(err.Error(), "SEP") {
+		t.Errorf("SignKSR signed a request containing a KSK: %v", err)
+	}
+}
+
+func TestBuildKSRNeedsValidityBeyondPeriod(t *testing.T) {
+	zd, kdb, _ := offlineKskTestZone(t)
+	period := 10 * 24 * time.Hour
+	zd.DnssecPolicy.TTLS.DNSKEY = 3600
+	// Signatures that end with the period expire in caches that fetched
+	// the RRset just before the end.
+	zd.DnssecPolicy.SigValidity.DNSKEY = uint32((period + time.Hour).Seconds())
+	tx, err := kdb.Begin("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, _, err := kdb.BuildKSR(tx, zd, time.Now().UTC(), 1, period); err == nil {
+		t.Error("BuildKSR accepted sigvalidity.dnskey without room for the propagation delay")
+	}
+	zd.DnssecPolicy.SigValidity.DNSKEY = uint32((period + time.Hour + Conf.KaspPropagationDelay()).Seconds())
+	if _, _, err := kdb.BuildKSR(tx, zd, time.Now().UTC(), 1, period); err != nil {
+		t.Errorf("BuildKSR: %v", err)
+	}
+}
+
+func TestFinishDnssecPolicyOfflineKsk(t *testing.T) {
+	for _, tc := range []struct {
+		mode, method string
+		ok           bool
+	}{
+		{"", "", true},
+		{DnssecPolicyModeCSK, "", false},
+		{"", "multi-ds", false},
+	} {
+		conf := &DnssecPolicyConf{Mode: tc.mode}
+		conf.SigValidity.Default = "14d"
+		conf.KSK.Offline = true
+		conf.Rollover.Method = tc.method
+		var pol DnssecPolicy
+		err := FinishDnssecPolicy("offline", conf, &pol)
+		if (err == nil) != tc.ok {
+			t.Errorf("mode %q method %q: err %v, want ok=%v", tc.mode, tc.method, err, tc.ok)
+		}
+		if tc.ok && !pol.OfflineKSK {
+			t.Errorf("mode %q: OfflineKSK not set", tc.mode)
+		}
+	}
+}
@@ -204,17 +204,17 @@ func TestParseExtendedDuration(t *testing.T) {
 		" 7d ":  7 * 24 * time.Hour, // trimmed
 	}
 	for in, want := range ok {
-		got, err := parseExtendedDuration(in)
+		got, err := ParseExtendedDuration(in)
 		if err != nil {
-			t.Fatalf("parseExtendedDuration(%q) err = %v", in, err)
+			t.Fatalf("ParseExtendedDuration(%q) err = %v", in, err)
 		}
 		if got != want {
-			t.Fatalf("parseExtendedDuration(%q) = %v, want %v", in, got, want)
+			t.Fatalf("ParseExtendedDuration(%q) = %v, want %v", in, got, want)
 		}
 	}
 	for _, bad := range []string{"1.5d", "xd", "d", "", "1y", "-7d", "-2w"} {
-		if _, err := parseExtendedDuration(bad); err == nil {
-			t.Fatalf("parseExtendedDuration(%q) should have errored", bad)
+		if _, err := ParseExtendedDuration(bad); err == nil {
+			t.Fatalf("ParseExtendedDuration(%q) should have errored", bad)
 		}
 	}
 }
@@ -275,6 +275,15 @@ func (conf *Config) StartScanner(ctx context.Context, apirouter *mux.Router) err
 
 // StartAuth starts subsystems for tdns-auth
 func (conf *Config) StartAuth(ctx context.Context, apirouter *mux.Router) error {
+	// Release change set claims left by a crash before the API or the
+	// scheduler can claim anything.
+	if kdb := conf.Internal.KeyDB; kdb != nil {
+		if n, err := kdb.RecoverChangeSets(); err != nil {
+			lgConfig.Error("failed to recover interrupted change sets", "err", err)
+		} else if n > 0 {
+			lgConfig.Warn("released change sets left applying by a previous run", "count", n)
+		}
+	}
 	StartEngine(&Globals.App, "APIdispatcher", func() error { return APIdispatcher(conf, apirouter, conf.Internal.APIStopCh) })
 	StartEngineNoError(&Globals.App, "ValidatorEngine", func() { ValidatorEngine(ctx, conf) })
 	// In tdns-auth, IMR is active by default unless explicitly set to false
@@ -300,6 +309,8 @@ func (conf *Config) StartAuth(ctx context.Context, apirouter *mux.Router) error
 	StartEngine(&Globals.App, "DnsEngine", func() error { return DnsEngine(ctx, conf) })
 	StartEngineNoError(&Globals.App, "ResignerEngine", func() { ResignerEngine(ctx, conf.Internal.ResignQ) })
 	StartEngine(&Globals.App, "KeyStateWorker", func() error { return KeyStateWorker(ctx, conf) })
+	StartEngine(&Globals.App, "MultiSignerEngine", func() error { return MultiSignerEngine(ctx, conf) })
+	StartEngine(&Globals.App, "ChangeSetScheduler", func() error { return ChangeSetScheduler(ctx, conf) })
 
 	// RefreshEngine is now running and draining RefreshZoneCh, so persisted
 	// dynamic zones can be loaded with a blocking enqueue (no drop).
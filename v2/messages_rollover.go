@@ -223,16 +223,20 @@ type PolicySummary struct {
 	ClampingMargin           string `json:"clampingMargin,omitempty"`
 }
 
-// AlgTransitionInfo describes an in-flight ZSK algorithm rollover for the
+// AlgTransitionInfo describes an in-flight algorithm rollover for the
 // status header line: e.g. "ZSK alg rollover: ED25519 → MAYO5 (in progress)".
 // FromAlg/ToAlg are algorithm names; Done/Total are a coarse progress count
-// (target-alg ZSKs / all live ZSK pipeline members).
+// (target-alg ZSKs / all live ZSK pipeline members). A KSK roll reports its
+// Stage instead (double-sign, ds-replace, withdraw, or refused with Detail
+// carrying the parent's failure).
 type AlgTransitionInfo struct {
-	Role    string `json:"role"` // currently always "ZSK"
+	Role    string `json:"role"` // "ZSK" or "KSK"
 	FromAlg string `json:"fromAlg"`
 	ToAlg   string `json:"toAlg"`
 	Done    int    `json:"done"`
 	Total   int    `json:"total"`
+	Stage   string `json:"stage,omitempty"`
+	Detail  string `json:"detail,omitempty"`
 }
 
 // RolloverWhenResponse is returned by GET /api/v1/rollover/when.
@@ -0,0 +1,460 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ *
+ * Multi-signer key exchange without a controller (RFC 8901 model 2). Each
+ * provider signs the zone with its own keys and must publish the other
+ * providers' ZSKs in its DNSKEY RRset, so that a validator can verify an
+ * RRSIG from either provider. The ZSKs of the peers listed in a zone's
+ * multisigner config are kept in the keystore in state "foreign": published
+ * in the DNSKEY RRset, never used for signing. They arrive either by
+ * fetching the peer's DNSKEY RRset and validating it against the zone's DS
+ * RRset at the parent, or by the peer pushing a SIG(0)-signed UPDATE.
+ */
+package tdns
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	cache "github.com/johanix/tdns/v2/cache"
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultMultiSignerRefresh = 1 * time.Hour
+	multiSignerCheckInterval  = 1 * time.Minute
+
+	// foreignDnskeyTTL matches the TTL GenerateKeyMaterial gives our own
+	// DNSKEYs, so that imported keys do not split the RRset TTL.
+	foreignDnskeyTTL = 3600
+)
+
+// foreignCreator is the keystore creator of a foreign key, which records the
+// peer it belongs to.
+func foreignCreator(peer string) string {
+	return "multisigner:" + peer
+}
+
+// multiSignerPeers returns the peers of the zone's multisigner config.
+func (zd *ZoneData) multiSignerPeers() []MultiSignerPeer {
+	if zd.MultiSigner == nil {
+		return nil
+	}
+	return zd.MultiSigner.Peers
+}
+
+// multiSignerRefresh is how often the peers' DNSKEY RRsets are fetched.
+func (zd *ZoneData) multiSignerRefresh() time.Duration {
+	if zd.MultiSigner == nil || zd.MultiSigner.Refresh == "" {
+		return defaultMultiSignerRefresh
+	}
+	d, err := time.ParseDuration(zd.MultiSigner.Refresh)
+	if err != nil || d <= 0 {
+		lgSigner.Warn("multisigner: invalid refresh, using default", "zone", zd.ZoneName, "refresh", zd.MultiSigner.Refresh, "default", defaultMultiSignerRefresh)
+		return defaultMultiSignerRefresh
+	}
+	return d
+}
+
+// multiSignerPeerForUpdate returns the peer whose trusted SIG(0) key
+// validated the UPDATE, or nil.
+func (zd *ZoneData) multiSignerPeerForUpdate(us *UpdateStatus) *MultiSignerPeer {
+	peers := zd.multiSignerPeers()
+	for _, s := range us.Signers {
+		if !s.Validated || s.Sig0Key == nil || !s.Sig0Key.Trusted {
+			continue
+		}
+		for i := range peers {
+			if peers[i].Sig0Signer != "" && strings.EqualFold(dns.Fqdn(peers[i].Sig0Signer), s.Name) {
+				return &peers[i]
+			}
+		}
+	}
+	return nil
+}
+
+// isMultiSignerDnskeyUpdate reports whether an UPDATE to the zone apex only
+// touches the DNSKEY RRset of a zone with multi-signer peers. Such an UPDATE
+// is a peer pushing its ZSKs, not a change to the zone data.
+func (zd *ZoneData) isMultiSignerDnskeyUpdate(actions []dns.RR) bool {
+	if len(zd.multiSignerPeers()) == 0 || len(actions) == 0 {
+		return false
+	}
+	for _, rr := range actions {
+		if rr.Header().Rrtype != dns.TypeDNSKEY || !strings.EqualFold(rr.Header().Name, zd.ZoneName) {
+			return false
+		}
+	}
+	return true
+}
+
+// isForeignZsk reports whether k can be imported as a foreign key: a zone
+// key without the SEP bit. KSKs of other providers are anchored by their own
+// DS at the parent and are not published by us.
+func isForeignZsk(k *dns.DNSKEY) bool {
+	return k.Flags&dns.ZONE != 0 && k.Flags&dns.SEP == 0 && k.Flags&dns.REVOKE == 0 && k.Protocol == 3
+}
+
+// zoneKeyAlgorithms returns the algorithms of the zone's active keys. A
+// foreign ZSK of any other algorithm would put an algorithm in our DNSKEY
+// RRset that our signatures do not cover (RFC 4035 §2.2), so it is not
+// imported.
+func zoneKeyAlgorithms(kdb *KeyDB, zone string) (map[uint8]bool, error) {
+	keys, err := GetDnssecKeysByState(kdb, zone, DnskeyStateActive)
+	if err != nil {
+		return nil, err
+	}
+	algs := map[uint8]bool{}
+	for _, k := range keys {
+		algs[k.Algorithm] = true
+	}
+	return algs, nil
+}
+
+// validatePeerDnskeys checks a peer's DNSKEY RRset against the zone's DS
+// RRset at the parent and returns the ZSKs in it whose algorithm is in algs.
+// The RRset must be signed by a KSK that one of the DS records points at.
+func validatePeerDnskeys(zone string, rrset *core.RRset, parentDS []dns.RR, algs map[uint8]bool) ([]*dns.DNSKEY, error) {
+	if rrset == nil || len(rrset.RRs) == 0 {
+		return nil, fmt.Errorf("empty DNSKEY RRset")
+	}
+	validated := false
+	for _, rr := range parentDS {
+		ds, ok := rr.(*dns.DS)
+		if !ok {
+			continue
+		}
+		if ok, _ := cache.ValidateDNSKEYRRsetUsingDS(rrset, ds, zone, false); ok {
+			validated = true
+			break
+		}
+	}
+	if !validated {
+		return nil, fmt.Errorf("DNSKEY RRset is not signed by a KSK with a DS at the parent")
+	}
+	var zsks []*dns.DNSKEY
+	for _, rr := range rrset.RRs {
+		k, ok := rr.(*dns.DNSKEY)
+		if !ok || !isForeignZsk(k) {
+			continue
+		}
+		if !algs[k.Algorithm] {
+			lgSigner.Warn("multisigner: peer ZSK algorithm is not one the zone signs with, not importing",
+				"zone", zone, "keyid", k.KeyTag(), "algorithm", dns.AlgorithmToString[k.Algorithm])
+			continue
+		}
+		zsks = append(zsks, k)
+	}
+	return zsks, nil
+}
+
+// updateForeignDnskeys adds and removes ZSKs of peer in the keystore. With
+// replace, every key of the peer that is not in adds is removed as well. A
+// key tag that the keystore already holds for one of our own keys, or for
+// another peer, is skipped: in model 2 every provider publishes all ZSKs, so
+// a peer's RRset also carries ours. It reports whether anything changed.
+func updateForeignDnskeys(kdb *KeyDB, zone, peer string, adds, removes []*dns.DNSKEY, replace bool, actor AuditActor, via string) (bool, error) {
+	const (
+		getKeySql = `SELECT state, creator, keyrr FROM DnssecKeyStore WHERE zonename=? AND keyid=?`
+		addKeySql = `
+INSERT OR REPLACE INTO DnssecKeyStore (zonename, state, keyid, flags, algorithm, creator, privatekey, keyrr, published_at)
+VALUES (?, ?, ?, ?, ?, ?, '', ?, ?)`
+		delKeySql     = `DELETE FROM DnssecKeyStore WHERE zonename=? AND keyid=? AND state=? AND creator=?`
+		peerKeyidsSql = `SELECT keyid FROM DnssecKeyStore WHERE zonename=? AND state=? AND creator=?`
+	)
+	creator := foreignCreator(peer)
+
+	tx, err := kdb.Begin("updateForeignDnskeys")
+	if err != nil {
+		return false, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	var added, removed []uint16
+	keep := map[uint16]bool{}
+	for _, k := range adds {
+		keyid := k.KeyTag()
+		keep[keyid] = true
+
+		cp := dns.Copy(k).(*dns.DNSKEY)
+		cp.Hdr.Name, cp.Hdr.Class, cp.Hdr.Ttl = zone, dns.ClassINET, foreignDnskeyTTL
+
+		var state, owner, keyrr string
+		err := tx.QueryRow(getKeySql, zone, keyid).Scan(&state, &owner, &keyrr)
+		switch {
+		case err == nil && (state != DnskeyStateForeign || owner != creator):
+			lgSigner.Debug("multisigner: key tag already in keystore, not importing", "zone", zone, "peer", peer, "keyid", keyid, "state", state, "creator", owner)
+			continue
+		case err == nil && keyrr == cp.String():
+			continue
+		case err != nil && err != sql.ErrNoRows:
+			return false, fmt.Errorf("updateForeignDnskeys: %w", err)
+		}
+		_, err = tx.Exec(addKeySql, zone, DnskeyStateForeign, keyid, cp.Flags, dns.AlgorithmToString[cp.Algorithm],
+			creator, cp.String(), time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			return false, fmt.Errorf("updateForeignDnskeys: %w", err)
+		}
+		added = append(added, keyid)
+	}
+
+	drop := map[uint16]bool{}
+	for _, k := range removes {
+		drop[k.KeyTag()] = true
+	}
+	if replace {
+		rows, err := tx.Query(peerKeyidsSql, zone, DnskeyStateForeign, creator)
+		if err != nil {
+			return false, fmt.Errorf("updateForeignDnskeys: %w", err)
+		}
+		for rows.Next() {
+			var keyid int
+			if err := rows.Scan(&keyid); err != nil {
+				rows.Close()
+				return false, fmt.Errorf("updateForeignDnskeys: %w", err)
+			}
+			if !keep[uint16(keyid)] {
+				drop[uint16(keyid)] = true
+			}
+		}
+		rows.Close()
+	}
+	for keyid := range drop {
+		res, err := tx.Exec(delKeySql, zone, keyid, DnskeyStateForeign, creator)
+		if err != nil {
+			return false, fmt.Errorf("updateForeignDnskeys: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			removed = append(removed, keyid)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("updateForeignDnskeys: %w", err)
+	}
+	committed = true
+
+	slices.Sort(removed)
+	for _, keyid := range added {
+		auditRecord(kdb, AuditEntry{Actor: actor, Source: AuditSourceMultiSigner, Zone: zone, Action: "foreign-dnskey-add",
+			KeyID: keyid, NewState: DnskeyStateForeign, Detail: fmt.Sprintf("peer %s, %s", peer, via)})
+	}
+	for _, keyid := range removed {
+		auditRecord(kdb, AuditEntry{Actor: actor, Source: AuditSourceMultiSigner, Zone: zone, Action: "foreign-dnskey-remove",
+			KeyID: keyid, OldState: DnskeyStateForeign, Detail: fmt.Sprintf("peer %s, %s", peer, via)})
+	}
+	if len(added)+len(removed) > 0 {
+		lgSigner.Info("multisigner: foreign DNSKEYs updated", "zone", zone, "peer", peer, "via", via, "added", added, "removed", removed)
+	}
+	return len(added)+len(removed) > 0, nil
+}
+
+// applyMultiSignerUpdate applies a peer's pushed DNSKEY UPDATE to its
+// foreign keys. A class ANY delete replaces the peer's keys with the ones
+// added in the same UPDATE.
+func (kdb *KeyDB) applyMultiSignerUpdate(zd *ZoneData, ur UpdateRequest) (bool, error) {
+	peer := zd.multiSignerPeerForUpdate(ur.Status)
+	if peer == nil {
+		return false, fmt.Errorf("update is not signed by a multi-signer peer")
+	}
+	var adds, removes []*dns.DNSKEY
+	replace := false
+	for _, rr := range ur.Actions {
+		switch rr.Header().Class {
+		case dns.ClassANY:
+			replace = true
+		case dns.ClassINET:
+			if k, ok := rr.(*dns.DNSKEY); ok {
+				adds = append(adds, k)
+			}
+		case dns.ClassNONE:
+			if k, ok := rr.(*dns.DNSKEY); ok {
+				removes = append(removes, k)
+			}
+		}
+	}
+	return updateForeignDnskeys(kdb, zd.ZoneName, peer.Name, adds, removes, replace, updateRequestActor(ur), "update")
+}
+
+// multiSignerImporter fetches the DNSKEY RRsets of multi-signer peers and
+// imports their ZSKs. fetch and parentDS are the network lookups; resign
+// asks for the zone to be re-signed with the new DNSKEY RRset.
+type multiSignerImporter struct {
+	kdb      *KeyDB
+	fetch    func(ctx context.Context, zone string, peer MultiSignerPeer) (*core.RRset, error)
+	parentDS func(ctx context.Context, zone string) ([]dns.RR, error)
+	resign   func(zone string)
+
+	mu   sync.Mutex
+	last map[string]time.Time // zone + peer -> last fetch
+}
+
+// MultiSignerEngine periodically imports the ZSKs of every multi-signer peer
+// with configured nameservers, for every zone that we sign.
+func MultiSignerEngine(ctx context.Context, conf *Config) error {
+	if conf.Internal.KeyDB == nil {
+		lgSigner.Warn("MultiSignerEngine: no KeyDB available, exiting")
+		return nil
+	}
+	imp := &multiSignerImporter{
+		kdb:      conf.Internal.KeyDB,
+		fetch:    queryPeerDnskeys,
+		parentDS: queryParentDS,
+		resign:   func(zone string) { triggerResign(conf, zone) },
+	}
+
+	ticker := time.NewTicker(multiSignerCheckInterval)
+	defer ticker.Stop()
+	lgSigner.Info("MultiSignerEngine started", "check_interval", multiSignerCheckInterval)
+
+	for {
+		imp.syncDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			lgSigner.Info("MultiSignerEngine: context cancelled")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncDue fetches every peer whose last fetch is at least one refresh
+// interval old.
+func (imp *multiSignerImporter) syncDue(ctx context.Context, now time.Time) {
+	for item := range Zones.IterBuffered() {
+		zd := item.Val
+		if !zd.Options[OptOnlineSigning] && !zd.Options[OptInlineSigning] {
+			continue
+		}
+		for _, peer := range zd.multiSignerPeers() {
+			if len(peer.Nameservers) == 0 {
+				continue
+			}
+			key := zd.ZoneName + "|" + peer.Name
+			imp.mu.Lock()
+			if imp.last == nil {
+				imp.last = map[string]time.Time{}
+			}
+			last, ok := imp.last[key]
+			due := !ok || now.Sub(last) >= zd.multiSignerRefresh()
+			if due {
+				imp.last[key] = now
+			}
+			imp.mu.Unlock()
+			if !due {
+				continue
+			}
+			if err := imp.syncPeer(ctx, zd, peer); err != nil {
+				lgSigner.Warn("multisigner: peer DNSKEYs not imported", "zone", zd.ZoneName, "peer", peer.Name, "err", err)
+			}
+		}
+	}
+}
+
+// syncPeer fetches, validates and imports the ZSKs of one peer. On any
+// error the foreign keys already in the keystore are left alone.
+func (imp *multiSignerImporter) syncPeer(ctx context.Context, zd *ZoneData, peer MultiSignerPeer) error {
+	rrset, err := imp.fetch(ctx, zd.ZoneName, peer)
+	if err != nil {
+		return fmt.Errorf("fetching DNSKEY: %w", err)
+	}
+	ds, err := imp.parentDS(ctx, zd.ZoneName)
+	if err != nil {
+		return fmt.Errorf("looking up DS at the parent: %w", err)
+	}
+	algs, err := zoneKeyAlgorithms(imp.kdb, zd.ZoneName)
+	if err != nil {
+		return fmt.Errorf("listing the zone's key algorithms: %w", err)
+	}
+	zsks, err := validatePeerDnskeys(zd.ZoneName, rrset, ds, algs)
+	if err != nil {
+		return err
+	}
+	changed, err := updateForeignDnskeys(imp.kdb, zd.ZoneName, peer.Name, zsks, nil, true,
+		AuditActor{Type: AuditActorEngine, Name: "multisigner"}, "fetch")
+	if err != nil {
+		return err
+	}
+	if changed && imp.resign != nil {
+		imp.resign(zd.ZoneName)
+	}
+	return nil
+}
+
+// queryPeerDnskeys asks the peer's nameservers, in order, for the zone's
+// DNSKEY RRset and its RRSIGs.
+func queryPeerDnskeys(ctx context.Context, zone string, peer MultiSignerPeer) (*core.RRset, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(zone, dns.TypeDNSKEY)
+	m.SetEdns0(4096, true)
+	c := &dns.Client{Net: "tcp", Timeout: 10 * time.Second}
+
+	var lastErr error
+	for _, ns := range peer.Nameservers {
+		if _, _, err := net.SplitHostPort(ns); err != nil {
+			ns = net.JoinHostPort(ns, "53")
+		}
+		res, _, err := c.ExchangeContext(ctx, m, ns)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if res.Rcode != dns.RcodeSuccess {
+			lastErr = fmt.Errorf("%s: rcode %s", ns, dns.RcodeToString[res.Rcode])
+			continue
+		}
+		rrset := &core.RRset{Name: zone, RRtype: dns.TypeDNSKEY}
+		for _, rr := range res.Answer {
+			switch rr.Header().Rrtype {
+			case dns.TypeDNSKEY:
+				rrset.RRs = append(rrset.RRs, rr)
+			case dns.TypeRRSIG:
+				rrset.RRSIGs = append(rrset.RRSIGs, rr)
+			}
+		}
+		if len(rrset.RRs) == 0 {
+			lastErr = fmt.Errorf("%s: no DNSKEY RRset", ns)
+			continue
+		}
+		return rrset, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no nameservers")
+	}
+	return nil, lastErr
+}
+
+// queryParentDS looks up the zone's DS RRset through the IMR and requires it
+// to validate, which anchors the peer's keys in the parent chain.
+func queryParentDS(ctx context.Context, zone string) ([]dns.RR, error) {
+	imr := Globals.ImrEngine
+	if imr == nil {
+		return nil, fmt.Errorf("IMR not available")
+	}
+	resp, err := imr.ImrQuery(ctx, zone, dns.TypeDS, dns.ClassINET, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error {
+		return nil, fmt.Errorf("%s", resp.ErrorMsg)
+	}
+	if resp.RRset == nil || len(resp.RRset.RRs) == 0 {
+		return nil, fmt.Errorf("no DS RRset for %s", zone)
+	}
+	if !resp.Validated {
+		return nil, fmt.Errorf("DS RRset for %s did not validate", zone)
+	}
+	return resp.RRset.RRs, nil
+}
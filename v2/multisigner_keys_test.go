@@ -0,0 +1,267 @@
+package tdns
+
+import (
+	"context"
+	"crypto"
+	"testing"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// msTestKey generates a DNSKEY for zone and returns it with its signer.
+func msTestKey(t *testing.T, zone string, flags uint16) (*dns.DNSKEY, crypto.Signer) {
+	t.Helper()
+	k := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 300},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := k.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k, priv.(crypto.Signer)
+}
+
+// msPeerRRset is a peer's DNSKEY RRset, signed by ksk.
+func msPeerRRset(t *testing.T, zone string, ksk *dns.DNSKEY, kskPriv crypto.Signer, keys ...*dns.DNSKEY) *core.RRset {
+	t.Helper()
+	rrset := &core.RRset{Name: zone, RRtype: dns.TypeDNSKEY, RRs: []dns.RR{ksk}}
+	for _, k := range keys {
+		rrset.RRs = append(rrset.RRs, k)
+	}
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: zone, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 300},
+		Algorithm:  ksk.Algorithm,
+		SignerName: zone,
+		KeyTag:     ksk.KeyTag(),
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+	}
+	if err := sig.Sign(kskPriv, rrset.RRs); err != nil {
+		t.Fatal(err)
+	}
+	rrset.RRSIGs = []dns.RR{sig}
+	return rrset
+}
+
+func foreignKeyids(t *testing.T, kdb *KeyDB, zone string) map[uint16]string {
+	t.Helper()
+	rows, err := kdb.Query(`SELECT keyid, creator FROM DnssecKeyStore WHERE zonename=? AND state=?`, zone, DnskeyStateForeign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	out := map[uint16]string{}
+	for rows.Next() {
+		var keyid int
+		var creator string
+		if err := rows.Scan(&keyid, &creator); err != nil {
+			t.Fatal(err)
+		}
+		out[uint16(keyid)] = creator
+	}
+	return out
+}
+
+func TestValidatePeerDnskeys(t *testing.T) {
+	zone := "example.com."
+	ksk, kskPriv := msTestKey(t, zone, 257)
+	zsk, _ := msTestKey(t, zone, 256)
+	rrset := msPeerRRset(t, zone, ksk, kskPriv, zsk)
+
+	algs := map[uint8]bool{dns.ECDSAP256SHA256: true}
+	zsks, err := validatePeerDnskeys(zone, rrset, []dns.RR{ksk.ToDS(dns.SHA256)}, algs)
+	if err != nil || len(zsks) != 1 || zsks[0].KeyTag() != zsk.KeyTag() {
+		t.Fatalf("validatePeerDnskeys = %v, %v", zsks, err)
+	}
+
+	// A ZSK of an algorithm the zone does not sign with is left out.
+	zsks, err = validatePeerDnskeys(zone, rrset, []dns.RR{ksk.ToDS(dns.SHA256)}, map[uint8]bool{dns.ED25519: true})
+	if err != nil || len(zsks) != 0 {
+		t.Errorf("ZSK of another algorithm: validatePeerDnskeys = %v, %v", zsks, err)
+	}
+
+	// A KSK without a DS at the parent does not anchor the RRset.
+	other, _ := msTestKey(t, zone, 257)
+	if _, err := validatePeerDnskeys(zone, rrset, []dns.RR{other.ToDS(dns.SHA256)}, algs); err == nil {
+		t.Error("RRset validated against an unrelated DS")
+	}
+}
+
+func TestMultiSignerImporterSyncPeer(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	zone := "example.com."
+	ksk, kskPriv := msTestKey(t, zone, 257)
+	zsk1, _ := msTestKey(t, zone, 256)
+	zsk2, _ := msTestKey(t, zone, 256)
+
+	// Our own ZSK is also in the peer's RRset and must not become foreign.
+	own, _, err := kdb.GenerateKeypair(zone, "test", DnskeyStateActive, dns.TypeDNSKEY, dns.ECDSAP256SHA256, "ZSK", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := msPeerRRset(t, zone, ksk, kskPriv, zsk1, &own.DnskeyRR)
+	var resigned int
+	imp := &multiSignerImporter{
+		kdb:      kdb,
+		fetch:    func(ctx context.Context, zone string, peer MultiSignerPeer) (*core.RRset, error) { return served, nil },
+		parentDS: func(ctx context.Context, zone string) ([]dns.RR, error) { return []dns.RR{ksk.ToDS(dns.SHA256)}, nil },
+		resign:   func(zone string) { resigned++ },
+	}
+	zd := &ZoneData{ZoneName: zone}
+	peer := MultiSignerPeer{Name: "provider-b"}
+
+	if err := imp.syncPeer(context.Background(), zd, peer); err != nil {
+		t.Fatal(err)
+	}
+	got := foreignKeyids(t, kdb, zone)
+	if len(got) != 1 || got[zsk1.KeyTag()] != "multisigner:provider-b" || resigned != 1 {
+		t.Fatalf("after first sync: %v, resigned %d", got, resigned)
+	}
+
+	// Unchanged: no re-sign.
+	if err := imp.syncPeer(context.Background(), zd, peer); err != nil || resigned != 1 {
+		t.Fatalf("unchanged sync: %v, resigned %d", err, resigned)
+	}
+
+	// The peer rolls its ZSK.
+	served = msPeerRRset(t, zone, ksk, kskPriv, zsk2)
+	if err := imp.syncPeer(context.Background(), zd, peer); err != nil {
+		t.Fatal(err)
+	}
+	got = foreignKeyids(t, kdb, zone)
+	if len(got) != 1 || got[zsk2.KeyTag()] == "" || resigned != 2 {
+		t.Fatalf("after rollover: %v, resigned %d", got, resigned)
+	}
+
+	// An RRset that does not validate leaves the keystore alone.
+	bogus, bogusPriv := msTestKey(t, zone, 257)
+	served = msPeerRRset(t, zone, bogus, bogusPriv, zsk1)
+	if err := imp.syncPeer(context.Background(), zd, peer); err == nil {
+		t.Fatal("unvalidated RRset was accepted")
+	}
+	if got := foreignKeyids(t, kdb, zone); len(got) != 1 || got[zsk2.KeyTag()] == "" {
+		t.Fatalf("after bogus fetch: %v", got)
+	}
+
+	// Foreign keys are part of the published DNSKEY set.
+	rows, err := kdb.Query(FetchZoneDnskeysSql, zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	found := false
+	for rows.Next() {
+		var keyid, flags, algorithm, keyrr string
+		if err := rows.Scan(&keyid, &flags, &algorithm, &keyrr); err != nil {
+			t.Fatal(err)
+		}
+		if rr, err := dns.NewRR(keyrr); err == nil && rr.(*dns.DNSKEY).KeyTag() == zsk2.KeyTag() {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("foreign ZSK not in FetchZoneDnskeysSql result")
+	}
+}
+
+func TestApplyMultiSignerUpdate(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	zone := "example.com."
+	zsk1, _ := msTestKey(t, zone, 256)
+	zsk2, _ := msTestKey(t, zone, 256)
+	ksk, _ := msTestKey(t, zone, 257)
+
+	edZsk := &dns.DNSKEY{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 300},
+		Flags: 256, Protocol: 3, Algorithm: dns.ED25519}
+	if _, err := edZsk.Generate(256); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := kdb.GenerateKeypair(zone, "test", DnskeyStateActive, dns.TypeDNSKEY, dns.ECDSAP256SHA256, "ZSK", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	zd := &ZoneData{
+		ZoneName:    zone,
+		KeyDB:       kdb,
+		MultiSigner: &MultiSignerConf{Peers: []MultiSignerPeer{{Name: "provider-b", Sig0Signer: "msigner.provider-b.net"}}},
+	}
+	status := func(signer string, trusted bool) *UpdateStatus {
+		return &UpdateStatus{
+			Validated:             true,
+			ValidatedByTrustedKey: trusted,
+			SignerName:            signer,
+			Signers: []Sig0UpdateSigner{{
+				Name: signer, KeyId: 4711, Validated: true,
+				Sig0Key: &Sig0Key{Name: signer, Keyid: 4711, Trusted: trusted},
+			}},
+		}
+	}
+	msg := func(rrs ...dns.RR) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetUpdate(zone)
+		m.Ns = rrs
+		return m
+	}
+	del := func(k *dns.DNSKEY) dns.RR {
+		cp := dns.Copy(k)
+		cp.Header().Class = dns.ClassNONE
+		return cp
+	}
+
+	if !zd.isMultiSignerDnskeyUpdate([]dns.RR{zsk1}) {
+		t.Error("DNSKEY update at apex not classified as multi-signer")
+	}
+	if (&ZoneData{ZoneName: zone}).isMultiSignerDnskeyUpdate([]dns.RR{zsk1}) {
+		t.Error("multi-signer update classified in a zone without peers")
+	}
+
+	for _, tc := range []struct {
+		name string
+		us   *UpdateStatus
+		rrs  []dns.RR
+		want bool
+	}{
+		{"peer", status("msigner.provider-b.net.", true), []dns.RR{zsk1}, true},
+		{"untrusted", status("msigner.provider-b.net.", false), []dns.RR{zsk1}, false},
+		{"not a peer", status("other.example.net.", true), []dns.RR{zsk1}, false},
+		{"ksk", status("msigner.provider-b.net.", true), []dns.RR{ksk}, false},
+		{"other algorithm", status("msigner.provider-b.net.", true), []dns.RR{edZsk}, false},
+	} {
+		ok, updatezone, err := zd.ApproveMultiSignerUpdate(zone, tc.us, msg(tc.rrs...))
+		if err != nil || ok != tc.want || (ok && !updatezone) {
+			t.Errorf("%s: approved %v, updatezone %v, err %v; want %v", tc.name, ok, updatezone, err, tc.want)
+		}
+	}
+
+	us := status("msigner.provider-b.net.", true)
+	apply := func(rrs ...dns.RR) {
+		t.Helper()
+		if _, err := kdb.applyMultiSignerUpdate(zd, UpdateRequest{Cmd: "MULTISIGNER-UPDATE", ZoneName: zone, Actions: rrs, Status: us}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	apply(zsk1, zsk2)
+	if got := foreignKeyids(t, kdb, zone); len(got) != 2 {
+		t.Fatalf("after add: %v", got)
+	}
+	apply(del(zsk1))
+	if got := foreignKeyids(t, kdb, zone); len(got) != 1 || got[zsk2.KeyTag()] == "" {
+		t.Fatalf("after delete: %v", got)
+	}
+	anyDel := &dns.ANY{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassANY}}
+	apply(anyDel, zsk1)
+	if got := foreignKeyids(t, kdb, zone); len(got) != 1 || got[zsk1.KeyTag()] == "" {
+		t.Fatalf("after replace: %v", got)
+	}
+
+	entries, err := kdb.QueryAuditLog(AuditFilter{Zone: zone})
+	if err != nil || len(entries) != 5 {
+		t.Errorf("audit entries: %d, %v", len(entries), err)
+	}
+}
@@ -34,6 +34,17 @@ type MusicSyncStatus struct {
 type MultiSignerConf struct {
 	Name       string
 	Controller MultiSignerController
+	// Peers are the other providers signing the zone. Their ZSKs are imported
+	// by tdns-auth itself (RFC 8901 model 2), without a controller.
+	Peers   []MultiSignerPeer
+	Refresh string // how often the peers' DNSKEY RRsets are fetched (default 1h)
+}
+
+// MultiSignerPeer is another provider signing the same zone with its own keys.
+type MultiSignerPeer struct {
+	Name        string   `validate:"required"`
+	Nameservers []string // addr[:port] serving the peer's signed zone; empty: keys are only pushed
+	Sig0Signer  string   `yaml:"sig0-signer" mapstructure:"sig0-signer"` // owner of the SIG(0) key the peer signs pushed UPDATEs with
 }
 
 type MultiSignerController struct {
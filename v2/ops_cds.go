@@ -58,6 +58,11 @@ func (zd *ZoneData) SynthesizeCdsRRs() ([]dns.RR, error) {
 }
 
 func (zd *ZoneData) PublishCdsRRs() error {
+	if zd.offlineKSK() {
+		// CDS and CDNSKEY are pre-signed by the offline KSK and come with
+		// the SKR bundle (ksr-export with CDS requested, see ksr.go).
+		return fmt.Errorf("zone %s has an offline KSK: CDS/CDNSKEY are published from the imported SKR", zd.ZoneName)
+	}
 	cdsRRs, err := zd.SynthesizeCdsRRs()
 	if err != nil {
 		return err
@@ -18,10 +18,11 @@ import (
 // brief window after refresh where standby DNSKEYs disappear from
 // the served RRset until the next SignZone call.
 //
-// The set is `published` ∪ `standby` ∪ `retired`. Active keys are
-// fetched separately via GetDnssecKeys(..., DnskeyStateActive).
+// The set is `published` ∪ `standby` ∪ `retired` ∪ `foreign`, the last
+// being the ZSKs imported from other multi-signer providers. Active keys
+// are fetched separately via GetDnssecKeys(..., DnskeyStateActive).
 const FetchZoneDnskeysSql = `
-SELECT keyid, flags, algorithm, keyrr FROM DnssecKeyStore WHERE zonename=? AND (state='published' OR state='standby' OR state='retired')`
+SELECT keyid, flags, algorithm, keyrr FROM DnssecKeyStore WHERE zonename=? AND (state='published' OR state='standby' OR state='retired' OR state='foreign')`
 
 func (zd *ZoneData) PublishDnskeyRRs(dak *DnssecKeys) error {
 	if !zd.Options[OptAllowUpdates] && !zd.Options[OptOnlineSigning] && !zd.Options[OptInlineSigning] {
@@ -38,12 +39,22 @@ func (zd *ZoneData) publishDnskeyRRsLocked(dak *DnssecKeys) error {
 	if apex == nil {
 		return fmt.Errorf("PublishDnskeyRRs: zone apex %q not found", zd.ZoneName)
 	}
+	if zd.offlineKSK() {
+		return zd.publishSkrDnskeysLocked(dak)
+	}
 
 	// Ensure that all active DNSKEYs are included in the DNSKEY RRset
 	// XXX: Note that here we do not judge whether some other DNSKEY shouldn't
 	// be part of the DNSKEY RRset. We just include all active DNSKEYs.
 	var publishkeys []dns.RR
+	// A KSK in the new-rrsigs stage of an algorithm rollover signs before its
+	// DNSKEY is published (ksk_alg_rollover.go).
+	withheld, withholding := kskAlgRollWithheldKSK(zd.KeyDB, zd.ZoneName)
 	for _, ksk := range dak.KSKs {
+		if withholding && ksk.KeyId == withheld {
+			zd.Logger.Printf("PublishDnskeyRRs: ksk %d: signing, not yet published", ksk.KeyId)
+			continue
+		}
 		zd.Logger.Printf("PublishDnskeyRRs: ksk: %v", ksk.DnskeyRR.String())
 		publishkeys = append(publishkeys, dns.RR(&ksk.DnskeyRR))
 	}
@@ -92,7 +103,8 @@ func (zd *ZoneData) publishDnskeyRRsLocked(dak *DnssecKeys) error {
 	}
 
 	// Remote DNSKEY merge for multi-signer (mode 4) is handled by
-	// mpzd.PublishDnskeyRRs() in tdns-mp. This version is mode 1 only.
+	// mpzd.PublishDnskeyRRs() in tdns-mp. Here, the ZSKs of the peers in a
+	// zone's multisigner config arrive as foreign keystore rows above.
 
 	zd.Logger.Printf("PublishDnskeyRRs: publishkeys (all): %v", publishkeys)
 
@@ -1037,15 +1037,27 @@ func (conf *Config) ParseZones(ctx context.Context, reload bool) ([]string, []st
 				broken_zones = append(broken_zones, zname)
 				continue
 			}
+			var cdsPolicy *CdsPolicy
+			if zconf.CdsPolicy != "" {
+				cdsPolicy, err = LookupCdsPolicy(zconf.CdsPolicy)
+				if err != nil {
+					lgConfig.Error("failed to resolve cds policy, zone in error state", "zone", zname, "cdspolicy", zconf.CdsPolicy, "error", err)
+					zd.SetError(ConfigError, "cdspolicy %q: %v", zconf.CdsPolicy, err)
+					broken_zones = append(broken_zones, zname)
+					continue
+				}
+			}
 			zdp.mu.Lock()
 			zdp.DelegationBackend = backend
+			zdp.CdsPolicy = cdsPolicy
 			zdp.mu.Unlock()
-			lgConfig.Info("delegation backend wired", "zone", zname, "backend", backend.Name())
+			lgConfig.Info("delegation backend wired", "zone", zname, "backend", backend.Name(), "cdspolicy", zconf.CdsPolicy)
 		} else {
 			// Reload may have cleared OptAllowChildUpdates; drop any
 			// previously-wired backend so the live state matches config.
 			zdp.mu.Lock()
 			zdp.DelegationBackend = nil
+			zdp.CdsPolicy = nil
 			zdp.mu.Unlock()
 		}
 
@@ -1912,6 +1924,7 @@ func BuiltinDefaultDnssecPolicy() DnssecPolicy {
 			Default: uint32((14 * day).Seconds()),
 			DNSKEY:  uint32((30 * day).Seconds()),
 			DS:      uint32((14 * day).Seconds()),
+			Jitter:  uint32((14 * day).Seconds()) / 10,
 		},
 	}
 }
@@ -1928,7 +1941,7 @@ func GenKeyLifetime(lifetime string) (KeyLifetime, error) {
 		lifetime_secs = time.Duration(0)
 
 	default:
-		lifetime_secs, err = parseExtendedDuration(lifetime)
+		lifetime_secs, err = ParseExtendedDuration(lifetime)
 		if err != nil {
 			return KeyLifetime{}, fmt.Errorf("invalid key lifetime %q: %w", lifetime, err)
 		}
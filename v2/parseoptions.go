@@ -37,7 +37,8 @@ func (conf *Config) parseImrOptions() {
 		}
 
 		switch imrOpt {
-		case ImrOptRevalidateNS, ImrOptQueryForTransport, ImrOptAlwaysQueryForTransport, ImrOptQueryForTransportTLSA:
+		case ImrOptRevalidateNS, ImrOptQueryForTransport, ImrOptAlwaysQueryForTransport, ImrOptQueryForTransportTLSA,
+			ImrOptUseDeleg:
 			if optval != "" {
 				lg.Warn("IMR option does not accept a value, ignoring provided value", "option", key, "value", optval)
 			}
@@ -377,6 +377,23 @@ func (zd *ZoneData) sendReferral(m *dns.Msg, w dns.ResponseWriter, cdd *ChildDel
 	m.Extra = append(m.Extra, cdd.A_glue...)
 	m.Extra = append(m.Extra, cdd.AAAA_glue...)
 
+	if msgoptions.DE && cdd.DELEG_rrset != nil && len(cdd.DELEG_rrset.RRs) > 0 {
+		// The client understands DELEG: include the DELEG RRset, which the
+		// parent is authoritative for and therefore signs, like the DS. As
+		// for the DS, a signing failure drops the DELEG rather than the
+		// referral; the resolver then follows the NS RRset.
+		deleg := *cdd.DELEG_rrset
+		if !msgoptions.DO {
+			m.Ns = append(m.Ns, deleg.RRs...)
+		} else if signed, err := signFunc(deleg, cdd.ChildName); err != nil {
+			lgHandler.Error("referral: failed to sign DELEG RRset; omitting DELEG from referral",
+				"child", cdd.ChildName, "err", err)
+		} else {
+			m.Ns = append(m.Ns, signed.RRs...)
+			m.Ns = append(m.Ns, signed.RRSIGs...)
+		}
+	}
+
 	if msgoptions.DO {
 		if cdd.DS_rrset != nil && len(cdd.DS_rrset.RRs) > 0 {
 			// Secure delegation (RFC 4035 §3.1.4.1): include the DS RRset and
@@ -188,3 +188,52 @@ func TestSendReferral_InsecureDelegationHasNSEC(t *testing.T) {
 		t.Fatal("insecure delegation must not carry a DS")
 	}
 }
+
+// TestSendReferral_DelegOnlyWithDE confirms the DELEG RRset, signed when DO
+// is set, is added to a referral only for clients that set the DE bit.
+func TestSendReferral_DelegOnlyWithDE(t *testing.T) {
+	zd := &ZoneData{ZoneName: "pq.axfr.net."}
+	const child = "falcon512-mayo2.pq.axfr.net."
+	nsRR := mustRR(t, child+" 3600 IN NS ns.pq.axfr.net.")
+	dsRR := mustRR(t, child+" 3600 IN DS 23388 208 2 87C97FD4C8748E1507B76F098C398B47EBBC2145DBCEB45D004D650D537EF841")
+	delegRR := mustRR(t, child+` 3600 IN DELEG 1 ns.pq.axfr.net. alpn="dot" ipv4hint="192.0.2.53"`)
+	cdd := &ChildDelegationData{
+		ChildName:   child,
+		NS_rrset:    &core.RRset{Name: child, RRtype: dns.TypeNS, Class: dns.ClassINET, RRs: []dns.RR{nsRR}},
+		DS_rrset:    &core.RRset{Name: child, RRtype: dns.TypeDS, Class: dns.ClassINET, RRs: []dns.RR{dsRR}},
+		DELEG_rrset: &core.RRset{Name: child, RRtype: core.TypeDELEG, Class: dns.ClassINET, RRs: []dns.RR{delegRR}},
+	}
+	signFunc := func(rrset core.RRset, name string) (core.RRset, error) {
+		rrset.RRSIGs = []dns.RR{mustRR(t, fmt.Sprintf("%s 3600 IN RRSIG %s 15 4 3600 20260805122917 20260722122719 16089 pq.axfr.net. AA==",
+			name, dns.TypeToString[rrset.RRtype]))}
+		return rrset, nil
+	}
+
+	for _, tc := range []struct {
+		opts            edns0.MsgOptions
+		deleg, delegSig bool
+	}{
+		{edns0.MsgOptions{DO: true}, false, false},
+		{edns0.MsgOptions{DE: true}, true, false},
+		{edns0.MsgOptions{DO: true, DE: true}, true, true},
+	} {
+		w := &fakeRW{remote: udpAddr("127.0.0.1")}
+		opts := tc.opts
+		zd.sendReferral(new(dns.Msg), w, cdd, nil, &opts, signFunc)
+		if w.written == nil {
+			t.Fatal("sendReferral wrote no response")
+		}
+		var sawDeleg, sawDelegSig bool
+		for _, rr := range w.written.Ns {
+			if sig, ok := rr.(*dns.RRSIG); ok {
+				sawDelegSig = sawDelegSig || sig.TypeCovered == core.TypeDELEG
+			} else if rr.Header().Rrtype == core.TypeDELEG {
+				sawDeleg = true
+			}
+		}
+		if sawDeleg != tc.deleg || sawDelegSig != tc.delegSig {
+			t.Errorf("DO=%v DE=%v: DELEG %v, RRSIG(DELEG) %v; want %v, %v",
+				tc.opts.DO, tc.opts.DE, sawDeleg, sawDelegSig, tc.deleg, tc.delegSig)
+		}
+	}
+}
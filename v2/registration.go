@@ -443,8 +443,17 @@ type ImrResponseHookFunc func(ctx context.Context, qname string, qtype uint16,
 	serverName string, serverAddr string, transport core.Transport,
 	response *dns.Msg, rcode int)
 
+// imrClientQueryHook is a registered client query hook. blocking hooks
+// filter (RPZ and the like) and are skipped for client groups with blocking
+// turned off; the others (logging, rate limiting, dependency analysis) run
+// for every client.
+type imrClientQueryHook struct {
+	fn       ImrClientQueryHookFunc
+	blocking bool
+}
+
 var (
-	globalImrClientQueryHooks      []ImrClientQueryHookFunc
+	globalImrClientQueryHooks      []imrClientQueryHook
 	globalImrClientQueryHooksMutex sync.RWMutex
 
 	globalImrOutboundQueryHooks      []ImrOutboundQueryHookFunc
@@ -462,12 +471,27 @@ func RegisterImrClientQueryHook(hook ImrClientQueryHookFunc) error {
 		return fmt.Errorf("hook cannot be nil")
 	}
 	globalImrClientQueryHooksMutex.Lock()
-	globalImrClientQueryHooks = append(globalImrClientQueryHooks, hook)
+	globalImrClientQueryHooks = append(globalImrClientQueryHooks, imrClientQueryHook{fn: hook})
 	globalImrClientQueryHooksMutex.Unlock()
 	lg.Debug("RegisterImrClientQueryHook: registered hook")
 	return nil
 }
 
+// RegisterImrBlockingQueryHook registers a client query hook that filters
+// queries (RPZ-like blocking). It is called in registration order together
+// with the hooks from RegisterImrClientQueryHook, but not for clients in a
+// group with blocking turned off (imrengine.access.client-groups).
+func RegisterImrBlockingQueryHook(hook ImrClientQueryHookFunc) error {
+	if hook == nil {
+		return fmt.Errorf("hook cannot be nil")
+	}
+	globalImrClientQueryHooksMutex.Lock()
+	globalImrClientQueryHooks = append(globalImrClientQueryHooks, imrClientQueryHook{fn: hook, blocking: true})
+	globalImrClientQueryHooksMutex.Unlock()
+	lg.Debug("RegisterImrBlockingQueryHook: registered hook")
+	return nil
+}
+
 // RegisterImrOutboundQueryHook registers a hook that is called before the IMR
 // sends an iterative query to an authoritative server. Multiple hooks can be
 // registered and are called in registration order.
@@ -496,11 +520,18 @@ func RegisterImrResponseHook(hook ImrResponseHookFunc) error {
 	return nil
 }
 
-// getImrClientQueryHooks returns all registered client query hooks.
-func getImrClientQueryHooks() []ImrClientQueryHookFunc {
+// getImrClientQueryHooks returns the registered client query hooks, without
+// the blocking ones unless blocking is true.
+func getImrClientQueryHooks(blocking bool) []ImrClientQueryHookFunc {
 	globalImrClientQueryHooksMutex.RLock()
 	defer globalImrClientQueryHooksMutex.RUnlock()
-	return globalImrClientQueryHooks
+	hooks := make([]ImrClientQueryHookFunc, 0, len(globalImrClientQueryHooks))
+	for _, h := range globalImrClientQueryHooks {
+		if blocking || !h.blocking {
+			hooks = append(hooks, h.fn)
+		}
+	}
+	return hooks
 }
 
 // getImrOutboundQueryHooks returns all registered outbound query hooks.
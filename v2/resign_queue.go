@@ -0,0 +1,360 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// The re-sign schedule. Every SignZone pass records, for each RRset it
+// signed, when the RRset's signatures next fall due: resignThreshold before
+// the earliest expiration of an RRSIG made by a current signing key, which
+// is exactly when NeedsResigning starts returning true. The periodic
+// resigner then pops only the RRsets that are due, instead of walking the
+// whole zone on every tick.
+//
+// The schedule follows the published zone. Everything that stages an
+// owner (a dynamic update, an applied change set, a strip) marks it in the
+// schedule, and the next tick reschedules the RRsets the published owner
+// now has, dropping those it no longer has; the rest of the schedule is
+// kept. Entries for a rescheduled RRset stay in the heap until they are
+// popped or compacted away, and are recognised as superseded by their due
+// time. Only a new set of signing keys, or a refresh that replaces the zone
+// wholesale, makes the next tick do a full SignZone pass, which builds a
+// new schedule.
+
+// resignEntry is one RRset in the schedule.
+type resignEntry struct {
+	due    time.Time
+	name   string
+	rrtype uint16
+}
+
+// resignHeap is a min-heap of resignEntries ordered by due time.
+type resignHeap []resignEntry
+
+func (h resignHeap) Len() int           { return len(h) }
+func (h resignHeap) Less(i, j int) bool { return h[i].due.Before(h[j].due) }
+func (h resignHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *resignHeap) Push(x any)        { *h = append(*h, x.(resignEntry)) }
+func (h *resignHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// resignQueue is a zone's re-sign schedule.
+type resignQueue struct {
+	entries resignHeap
+	due     map[string]map[uint16]time.Time // current due time of each scheduled RRset
+	stale   int                             // superseded entries still in the heap
+	dirty   map[string]bool                 // owners staged since the last tick
+	keys    string                          // signingKeysTag of the keys it was built for
+}
+
+// schedule records that name's rrtype RRset falls due at due, superseding
+// any earlier entry for it.
+func (q *resignQueue) schedule(name string, rrtype uint16, due time.Time) {
+	m := q.due[name]
+	if m == nil {
+		m = map[uint16]time.Time{}
+		q.due[name] = m
+	}
+	if _, ok := m[rrtype]; ok {
+		q.stale++
+	}
+	m[rrtype] = due
+	heap.Push(&q.entries, resignEntry{due: due, name: name, rrtype: rrtype})
+}
+
+// unschedule drops name's rrtype RRset from the schedule.
+func (q *resignQueue) unschedule(name string, rrtype uint16) {
+	if _, ok := q.due[name][rrtype]; !ok {
+		return
+	}
+	delete(q.due[name], rrtype)
+	if len(q.due[name]) == 0 {
+		delete(q.due, name)
+	}
+	q.stale++
+}
+
+// current reports whether e is the live entry for its RRset rather than
+// one superseded by a later schedule or unschedule.
+func (q *resignQueue) current(e resignEntry) bool {
+	due, ok := q.due[e.name][e.rrtype]
+	return ok && due.Equal(e.due)
+}
+
+// compact drops the superseded entries once they outnumber the live ones.
+func (q *resignQueue) compact() {
+	if q.stale <= len(q.entries)/2 {
+		return
+	}
+	live := q.entries[:0]
+	for _, e := range q.entries {
+		if q.current(e) {
+			live = append(live, e)
+		}
+	}
+	q.entries = live
+	heap.Init(&q.entries)
+	q.stale = 0
+}
+
+// Next returns when the first RRset in the schedule falls due, and false if
+// the schedule is empty.
+func (q *resignQueue) Next() (time.Time, bool) {
+	if q == nil || len(q.entries) == 0 {
+		return time.Time{}, false
+	}
+	return q.entries[0].due, true
+}
+
+// signingKeysTag identifies a set of signing keys, so that a schedule built
+// for other keys is recognised as stale.
+func signingKeysTag(dak *DnssecKeys) string {
+	var sb strings.Builder
+	for _, k := range dak.KSKs {
+		fmt.Fprintf(&sb, "k%d/%d ", k.KeyId, k.Algorithm)
+	}
+	for _, k := range dak.ZSKs {
+		fmt.Fprintf(&sb, "z%d/%d ", k.KeyId, k.Algorithm)
+	}
+	return sb.String()
+}
+
+// resignTouchLocked marks name as staged, so that the next ResignDue
+// reschedules its RRsets from the published zone. Caller holds zd.mu.
+func (zd *ZoneData) resignTouchLocked(name string) {
+	if zd.resignQ != nil {
+		zd.resignQ.dirty[name] = true
+	}
+}
+
+// resignEligible reports whether a sign pass signs name's rrtype RRset in
+// data: not RRSIGs, delegation NS or glue, nor an RRset pre-signed by an
+// offline KSK. It mirrors the selection in SignZone.
+func (zd *ZoneData) resignEligible(data map[string]*OwnerData, name string, rrtype uint16) bool {
+	switch {
+	case rrtype == dns.TypeRRSIG:
+		return false
+	case rrtype == dns.TypeNS && name != zd.ZoneName:
+		return false
+	case zd.skrSigned(name, rrtype):
+		return false
+	case rrtype != dns.TypeA && rrtype != dns.TypeAAAA:
+		return true
+	}
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		cut := name[off:]
+		if cut == zd.ZoneName {
+			break
+		}
+		if od := data[cut]; od != nil {
+			if _, ok := od.RRtypes.Get(dns.TypeNS); ok {
+				return false // glue
+			}
+		}
+	}
+	return true
+}
+
+// rescheduleOwnerLocked brings the schedule for a staged owner up to date
+// with the published zone. Caller holds zd.mu.
+func (zd *ZoneData) rescheduleOwnerLocked(q *resignQueue, data map[string]*OwnerData, name string, dak *DnssecKeys) {
+	for rrtype := range q.due[name] {
+		q.unschedule(name, rrtype)
+	}
+	owner := data[name]
+	if owner == nil {
+		return
+	}
+	for _, rrtype := range owner.RRtypes.Keys() {
+		rrset, _ := owner.RRtypes.Get(rrtype)
+		if len(rrset.RRs) == 0 || !zd.resignEligible(data, name, rrtype) {
+			continue
+		}
+		q.schedule(name, rrtype, rrsetResignDue(&rrset, dak))
+	}
+}
+
+// rrsetResignDue returns when the signatures over rrset by the current
+// signing keys fall due for re-signing. A missing signature is due now
+// (the zero time).
+func rrsetResignDue(rrset *core.RRset, dak *DnssecKeys) time.Time {
+	if len(rrset.RRs) == 0 {
+		return time.Time{}
+	}
+	keys := dak.ZSKs
+	if rrset.RRs[0].Header().Rrtype == dns.TypeDNSKEY {
+		keys = dak.KSKs
+	}
+	threshold := resignThreshold(rrset.RRs[0].Header().Ttl)
+	var due time.Time
+	for _, key := range keys {
+		var keydue time.Time
+		for _, rr := range rrset.RRSIGs {
+			if sig, ok := rr.(*dns.RRSIG); ok && sig.KeyTag == key.DnskeyRR.KeyTag() {
+				keydue = time.Unix(int64(sig.Expiration), 0).Add(-threshold)
+				break
+			}
+		}
+		if keydue.IsZero() {
+			return time.Time{}
+		}
+		if due.IsZero() || keydue.Before(due) {
+			due = keydue
+		}
+	}
+	return due
+}
+
+// scheduleResignsLocked builds the zone's re-sign schedule from the RRsets
+// of a sign pass that has just been published. Caller holds zd.mu.
+func (zd *ZoneData) scheduleResignsLocked(jobs []signJob, dak *DnssecKeys) {
+	q := &resignQueue{
+		entries: make(resignHeap, 0, len(jobs)),
+		due:     make(map[string]map[uint16]time.Time),
+		dirty:   make(map[string]bool),
+		keys:    signingKeysTag(dak),
+	}
+	for i := range jobs {
+		if len(jobs[i].rrset.RRs) == 0 {
+			continue
+		}
+		e := resignEntry{
+			due:    rrsetResignDue(&jobs[i].rrset, dak),
+			name:   jobs[i].name,
+			rrtype: jobs[i].rrset.RRs[0].Header().Rrtype,
+		}
+		if q.due[e.name] == nil {
+			q.due[e.name] = map[uint16]time.Time{}
+		}
+		q.due[e.name][e.rrtype] = e.due
+		q.entries = append(q.entries, e)
+	}
+	heap.Init(&q.entries)
+	zd.resignQ = q
+}
+
+// ResignDue re-signs the RRsets whose signatures are due at now, according
+// to the zone's re-sign schedule, and publishes the result. When there is
+// no valid schedule it falls back to a full SignZone pass, which builds
+// one. Returns the number of RRsets re-signed.
+func (zd *ZoneData) ResignDue(kdb *KeyDB, now time.Time) (int, error) {
+	if !zd.Options[OptOnlineSigning] && !zd.Options[OptInlineSigning] {
+		return 0, fmt.Errorf("ResignDue: zone %s should not be signed here (neither online-signing nor inline-signing)", zd.ZoneName)
+	}
+	if zd.HasError(DnssecError) {
+		return 0, fmt.Errorf("ResignDue: zone %s has DNSSEC error: %s", zd.ZoneName, zd.ErrorMsg)
+	}
+
+	dak, err := zd.EnsureActiveDnssecKeys(kdb, false)
+	if err != nil {
+		lgSigner.Error("ResignDue: failed to ensure active DNSSEC keys", "zone", zd.ZoneName, "err", err)
+		return 0, err
+	}
+	var clamp *ClampParams
+	if zd.DnssecPolicy != nil {
+		clamp, err = ClampParamsForZone(kdb, zd.ZoneName, zd.DnssecPolicy, now)
+		if err != nil {
+			lgSigner.Error("ResignDue: ClampParamsForZone failed; refusing to sign", "zone", zd.ZoneName, "err", err)
+			return 0, fmt.Errorf("ResignDue: ClampParamsForZone for zone %s: %w", zd.ZoneName, err)
+		}
+	}
+
+	zd.mu.Lock()
+	q := zd.resignQ
+	if q == nil || q.keys != signingKeysTag(dak) {
+		zd.mu.Unlock()
+		lgSigner.Debug("ResignDue: no re-sign schedule for the current keys, doing a full pass", "zone", zd.ZoneName)
+		return zd.SignZone(kdb, false)
+	}
+	defer zd.mu.Unlock()
+	// A pending working set is someone else's unpublished change:
+	// publishing it here would pre-empt its writer. The schedule is kept
+	// and the due RRsets wait for the next tick.
+	if zd.workingSet != nil {
+		lgSigner.Debug("ResignDue: zone has unpublished changes, waiting for the next tick", "zone", zd.ZoneName)
+		return 0, nil
+	}
+
+	snap := zd.snapshot.Load()
+	if snap == nil {
+		return 0, nil
+	}
+	for name := range q.dirty {
+		zd.rescheduleOwnerLocked(q, snap.Data, name, dak)
+	}
+	clear(q.dirty)
+	q.compact()
+
+	// The due RRsets are read from the published snapshot and signed on
+	// private copies; only re-signed RRsets are staged.
+	var jobs []signJob
+	for len(q.entries) > 0 && !q.entries[0].due.After(now) {
+		e := heap.Pop(&q.entries).(resignEntry)
+		if !q.current(e) {
+			q.stale--
+			continue
+		}
+		q.unschedule(e.name, e.rrtype)
+		q.stale--
+		owner := snap.Data[e.name]
+		if owner == nil || !zd.resignEligible(snap.Data, e.name, e.rrtype) {
+			continue
+		}
+		rrset, exist := owner.RRtypes.Get(e.rrtype)
+		if !exist || len(rrset.RRs) == 0 {
+			continue
+		}
+		rrset = cloneRRset(rrset)
+		rrset.RRtype = e.rrtype
+		jobs = append(jobs, signJob{name: e.name, rrset: rrset})
+	}
+	if len(jobs) == 0 {
+		return 0, nil
+	}
+
+	jobs = zd.signPassLocked(jobs, dak, false, clamp)
+
+	// A signature that is still due after this pass (the signing failed)
+	// is retried on the next tick rather than immediately.
+	retry := now.Add(resignScanInterval())
+	resigned := 0
+	for i := range jobs {
+		if jobs[i].err != nil {
+			lgSigner.Error("ResignDue: SignRRset failed", "zone", zd.ZoneName, "name", jobs[i].name,
+				"rrtype", dns.TypeToString[jobs[i].rrset.RRtype], "err", jobs[i].err)
+		}
+		if jobs[i].resigned {
+			zd.stageRRsetLocked(jobs[i].name, jobs[i].rrset)
+			resigned++
+		}
+		if jobs[i].added {
+			continue // not popped from the schedule: its entry is still there
+		}
+		due := rrsetResignDue(&jobs[i].rrset, dak)
+		if !due.After(now) {
+			due = retry
+		}
+		q.schedule(jobs[i].name, jobs[i].rrset.RRtype, due)
+	}
+
+	if resigned > 0 {
+		zd.publishLocked(zd.generation.Load())
+		// What was staged above is already in the schedule.
+		clear(q.dirty)
+	}
+	return resigned, nil
+}
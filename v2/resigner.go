@@ -101,12 +101,22 @@ func ResignerEngine(ctx context.Context, zoneresignch chan *ZoneData) {
 				if !zd.Options[OptInlineSigning] && !zd.Options[OptOnlineSigning] {
 					continue
 				}
+				// An offline-KSK zone switches to the pre-signed DNSKEY
+				// RRset of each new SKR period as it begins (ksr.go).
+				if err := zd.RefreshSkrDnskeys(); err != nil {
+					lgSigner.Error("offline KSK: cannot serve a DNSKEY RRset for the current period", "zone", zd.ZoneName, "err", err)
+				}
+				// Only the RRsets whose signatures are due are re-signed
+				// (see resign_queue.go); a zone without a current re-sign
+				// schedule gets a full pass.
 				lgSigner.Debug("re-signing zone (periodic)", "zone", zd.ZoneName)
-				newrrsigs, err := zd.SignZone(zd.KeyDB, false)
+				newrrsigs, err := zd.ResignDue(zd.KeyDB, time.Now())
 				if err != nil {
 					lgSigner.Error("failed to re-sign zone", "zone", zd.ZoneName, "err", err)
 				}
-				lgSigner.Info("zone re-signed (periodic)", "zone", zd.ZoneName, "new_rrsigs", newrrsigs)
+				if newrrsigs > 0 {
+					lgSigner.Info("zone re-signed (periodic)", "zone", zd.ZoneName, "new_rrsigs", newrrsigs)
+				}
 			}
 		}
 	}
@@ -95,6 +95,9 @@ func ComputeRolloverStatus(kdb *KeyDB, zone string, pol *DnssecPolicy, checkInte
 				}
 			}
 		}
+		if out.AlgTransition == nil {
+			out.AlgTransition = kskAlgRollStatus(kdb, zone)
+		}
 	}
 
 	var hiddenRemoved int
@@ -1,8 +1,11 @@
 package tdns
 
 import (
+	"context"
 	"log/slog"
 	"time"
+
+	"github.com/miekg/dns"
 )
 
 // RolloverEngineDeps bundles every dependency the rollover engine needs to
@@ -39,8 +42,48 @@ type RolloverEngineDeps struct {
 	// dispatch race by removing the "each goroutine reloads
 	// independently" window.
 	TargetKeySnapshot *RolloverTargetKeySnapshot
+
+	// Parent replaces the engine's two conversations with the parent.
+	// The zero value uses the network; the rollover simulator
+	// (rollover_simulate.go) answers from its model parent instead.
+	Parent RolloverParentHooks
+}
+
+// RolloverParentHooks stand in for the parent side of a rollover tick.
+// PushDS replaces PushDSRRsetForRollover and QueryDS replaces
+// QueryParentAgentDS; either may be nil independently.
+type RolloverParentHooks struct {
+	PushDS  func(ctx context.Context, deps RolloverEngineDeps) (KSKDSPushResult, error)
+	QueryDS func(ctx context.Context, zone, agent string) ([]dns.RR, error)
+}
+
+// simulated reports whether the parent is a model rather than the
+// network. The engine then needs no Imr and skips the wall-clock fast
+// observe poll: the model is polled on the caller's own ticks.
+func (h RolloverParentHooks) simulated() bool {
+	return h.PushDS != nil
 }
 
+func (deps RolloverEngineDeps) pushDS(ctx context.Context) (KSKDSPushResult, error) {
+	if deps.Parent.PushDS != nil {
+		return deps.Parent.PushDS(ctx, deps)
+	}
+	return PushDSRRsetForRollover(ctx, deps)
+}
+
+func (deps RolloverEngineDeps) queryParentDS(ctx context.Context, zone, agent string) ([]dns.RR, error) {
+	if deps.Parent.QueryDS != nil {
+		return deps.Parent.QueryDS(ctx, zone, agent)
+	}
+	return QueryParentAgentDS(ctx, zone, agent)
+}
+
+// keyStateNow is the clock behind the timestamps the key-state and
+// rollover code writes to the keystore (published_at, retired_at,
+// rollover_phase_at, ...). It is time.Now except during a rollover
+// simulation, which swaps in its virtual clock for the length of the run.
+var keyStateNow = time.Now
+
 // RolloverTargetKeySnapshot is the keystore-load result used by both
 // the UPDATE and NOTIFY DS-push paths. Captured once by the dispatcher,
 // shared by both goroutines — read-only after construction.
@@ -0,0 +1,754 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ *
+ * Rollover simulation: drive the real KeyStateWorker pass (KSK and ZSK
+ * rollover engines included) against a virtual clock, a scratch keystore
+ * and a model parent, and report what the next months would look like.
+ */
+
+package tdns
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Severities of a RolloverSimFinding.
+const (
+	RolloverSimUnsafe = "UNSAFE" // a validator with cached data could see the zone as bogus
+	RolloverSimStall  = "STALL"  // a roll that is due does not happen, or the engine stops advancing
+	RolloverSimWarn   = "WARN"   // the engine proceeds with reduced margin
+)
+
+// Kinds of a RolloverSimEvent.
+const (
+	RolloverSimEventKey    = "key"    // keystore state change
+	RolloverSimEventDnskey = "dnskey" // the published DNSKEY RRset changed
+	RolloverSimEventDS     = "ds"     // DS UPDATE sent to the parent
+	RolloverSimEventCDS    = "cds"    // CDS published or withdrawn (NOTIFY scheme)
+	RolloverSimEventParent = "parent" // the parent's DS RRset changed
+	RolloverSimEventPhase  = "phase"  // KSK rollover phase change or failed attempt
+	RolloverSimEventClamp  = "clamp"  // served TTLs changed (K-step clamp or max_served)
+	RolloverSimEventError  = "error"  // zone error set or cleared
+)
+
+// RolloverSimConfig is the input to SimulateRollover. Zero durations
+// take the defaults noted per field.
+type RolloverSimConfig struct {
+	Zone   string
+	Policy *DnssecPolicy
+	Kasp   KaspConf
+
+	Start   time.Time     // zero: now
+	Horizon time.Duration // required
+	// Step is the tick while a DS push is in flight (pending-child-publish
+	// through pending-parent-observe). Zero: kasp.check_interval.
+	Step time.Duration
+	// IdleStep is the tick otherwise. Zero: 1h. Timeline entries are
+	// accurate to the step in force when they happened.
+	IdleStep time.Duration
+
+	// DSDelay is how long the model parent takes from an accepted push to
+	// a DS RRset visible on all its servers. Zero: rollover.ds-publish-delay.
+	DSDelay time.Duration
+	// ParentDSTTL is the TTL of the parent's DS RRset. Zero:
+	// ttls.parent-ds, else 24h.
+	ParentDSTTL time.Duration
+	// MaxZoneTTL is the largest unclamped TTL in the zone. Zero, or less
+	// than the DNSKEY TTL: the DNSKEY TTL.
+	MaxZoneTTL time.Duration
+	// Scheme is the push scheme the model parent accepts: "UPDATE"
+	// (default) or "NOTIFY" (CDS at the child apex).
+	Scheme string
+}
+
+// RolloverSimEvent is one timeline entry.
+type RolloverSimEvent struct {
+	At     time.Time
+	Kind   string
+	KeyID  uint16
+	Detail string
+}
+
+// RolloverSimFinding flags a policy outcome the operator should look at.
+type RolloverSimFinding struct {
+	At       time.Time
+	Severity string
+	KeyID    uint16
+	Message  string
+}
+
+// RolloverSimKey is the life of one key in the simulation. Zero times
+// were not reached within the horizon.
+type RolloverSimKey struct {
+	KeyID     uint16
+	Role      string // "KSK" or "ZSK"
+	Bootstrap bool   // active (and delegated) when the simulation started
+
+	Created     time.Time
+	Published   time.Time // DNSKEY entered the zone
+	DSVisible   time.Time // KSK: DS on all parent servers
+	Active      time.Time
+	Retired     time.Time
+	Removed     time.Time // DNSKEY left the zone
+	DSWithdrawn time.Time // KSK: DS gone from the parent
+
+	// SafeFrom is the earliest validator-safe activation: every cached
+	// DNSKEY RRset holds the key (E3) and, for a KSK, every cached DS
+	// RRset holds its DS (E1). SafeRemoval is the earliest moment the
+	// DNSKEY can leave the zone without stranding cached signatures.
+	SafeFrom    time.Time
+	SafeRemoval time.Time
+
+	publishTTL time.Duration // served DNSKEY TTL when published
+	retireTTL  time.Duration // served TTL of what the key signed, when retired
+	state      string
+}
+
+// RolloverSimResult is the outcome of SimulateRollover.
+type RolloverSimResult struct {
+	Zone       string
+	Policy     string
+	Start, End time.Time
+	Ticks      int
+
+	PropagationDelay time.Duration
+	DSDelay          time.Duration
+	ParentDSTTL      time.Duration
+	Scheme           string
+
+	KSKRolls int
+	ZSKRolls int
+
+	Events   []RolloverSimEvent
+	Keys     []*RolloverSimKey
+	Findings []RolloverSimFinding
+}
+
+// rolloverSimMu serialises simulations: each one swaps keyStateNow and
+// registers its zone in Zones for the length of the run.
+var rolloverSimMu sync.Mutex
+
+// SimulateRollover runs the key-state and rollover engines for cfg.Zone
+// under cfg.Policy from cfg.Start to cfg.Start+cfg.Horizon. The zone
+// starts signed and delegated: an active KSK whose DS the parent
+// already serves and an active ZSK. Every KeyStateWorker pass runs
+// unchanged; only the clock and the parent are simulated.
+//
+// Must not run in a process that serves zones: the virtual clock
+// replaces the keystore's timestamp source while the simulation runs.
+func SimulateRollover(ctx context.Context, cfg RolloverSimConfig) (*RolloverSimResult, error) {
+	pol := cfg.Policy
+	if pol == nil {
+		return nil, fmt.Errorf("SimulateRollover: no policy")
+	}
+	if pol.Error != "" {
+		return nil, fmt.Errorf("SimulateRollover: policy %s is broken: %s", pol.Name, pol.Error)
+	}
+	if pol.OfflineKSK {
+		return nil, fmt.Errorf("SimulateRollover: policy %s has an offline KSK; its rolls follow SKR imports", pol.Name)
+	}
+	if cfg.Horizon <= 0 {
+		return nil, fmt.Errorf("SimulateRollover: horizon must be positive")
+	}
+	zone := dns.Fqdn(strings.ToLower(strings.TrimSpace(cfg.Zone)))
+	if zone == "." {
+		return nil, fmt.Errorf("SimulateRollover: no zone")
+	}
+
+	propagationDelay, checkInterval, standbyZskCount, standbyKskCount := kaspTimings(&cfg.Kasp)
+	start := cfg.Start
+	if start.IsZero() {
+		start = time.Now()
+	}
+	start = start.UTC().Truncate(time.Second)
+	step := cfg.Step
+	if step <= 0 {
+		step = checkInterval
+	}
+	idleStep := cfg.IdleStep
+	if idleStep <= 0 {
+		idleStep = time.Hour
+	}
+	if idleStep < step {
+		idleStep = step
+	}
+	dsDelay := cfg.DSDelay
+	if dsDelay <= 0 {
+		dsDelay = pol.Rollover.DsPublishDelay
+	}
+	dsTTL := cfg.ParentDSTTL
+	if dsTTL <= 0 {
+		dsTTL = 24 * time.Hour
+		if pol.TTLS.ParentDS > 0 {
+			dsTTL = time.Duration(pol.TTLS.ParentDS) * time.Second
+		}
+	}
+	dnskeyTTL := DefaultDnskeyTTL
+	if pol.TTLS.DNSKEY > 0 {
+		dnskeyTTL = time.Duration(pol.TTLS.DNSKEY) * time.Second
+	}
+	maxTTL := cfg.MaxZoneTTL
+	if maxTTL < dnskeyTTL {
+		maxTTL = dnskeyTTL
+	}
+	scheme := strings.ToUpper(cfg.Scheme)
+	switch scheme {
+	case "":
+		scheme = "UPDATE"
+	case "UPDATE", "NOTIFY":
+	default:
+		return nil, fmt.Errorf("SimulateRollover: unknown scheme %q (want UPDATE or NOTIFY)", cfg.Scheme)
+	}
+
+	rolloverSimMu.Lock()
+	defer rolloverSimMu.Unlock()
+	if _, exists := Zones.Get(zone); exists {
+		return nil, fmt.Errorf("SimulateRollover: zone %s is loaded in this process", zone)
+	}
+
+	dir, err := os.MkdirTemp("", "tdns-rollover-sim-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	dbfile := filepath.Join(dir, "keystore.db")
+	if err := os.WriteFile(dbfile, nil, 0664); err != nil {
+		return nil, err
+	}
+	kdb, err := NewKeyDB(dbfile, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer kdb.DB.Close()
+
+	s := &rolloverSim{
+		kdb:              kdb,
+		zone:             zone,
+		pol:              pol,
+		now:              start,
+		propagationDelay: propagationDelay,
+		dnskeyTTL:        dnskeyTTL,
+		maxTTL:           maxTTL,
+		keys:             map[uint16]*RolloverSimKey{},
+		stalls:           map[string]bool{},
+		errors:           map[ErrorType]string{},
+		parent:           simParent{delay: dsDelay, ttl: uint32(dsTTL.Seconds()), scheme: scheme},
+		res: &RolloverSimResult{
+			Zone:             zone,
+			Policy:           pol.Name,
+			Start:            start,
+			PropagationDelay: propagationDelay,
+			DSDelay:          dsDelay,
+			ParentDSTTL:      dsTTL,
+			Scheme:           scheme,
+		},
+	}
+
+	keyStateNow = func() time.Time { return s.now }
+	defer func() { keyStateNow = time.Now }()
+	clampLastK.Delete(zone)
+	defer clampLastK.Delete(zone)
+
+	s.zd = &ZoneData{
+		ZoneName:         zone,
+		Options:          map[ZoneOption]bool{OptOnlineSigning: true},
+		DnssecPolicy:     pol,
+		DnssecPolicyName: pol.Name,
+		KeyDB:            kdb,
+	}
+	Zones.Set(zone, s.zd)
+	defer Zones.Remove(zone)
+
+	if err := s.bootstrap(); err != nil {
+		return nil, err
+	}
+
+	conf := &Config{}
+	hooks := RolloverParentHooks{PushDS: s.pushDS, QueryDS: s.queryDS}
+	end := start.Add(cfg.Horizon)
+	for !s.now.After(end) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		s.parent.advance(s)
+		s.sign()
+		keyStatePass(ctx, conf, kdb, s.now, propagationDelay, standbyZskCount, standbyKskCount, hooks)
+		s.observe()
+		s.checkStalls()
+		s.res.Ticks++
+		if s.inFlight() {
+			s.now = s.now.Add(step)
+		} else {
+			s.now = s.now.Add(idleStep)
+		}
+	}
+	s.res.End = end
+	s.finish()
+	return s.res, nil
+}
+
+type rolloverSim struct {
+	kdb  *KeyDB
+	zd   *ZoneData
+	zone string
+	pol  *DnssecPolicy
+	now  time.Time
+
+	propagationDelay time.Duration
+	dnskeyTTL        time.Duration // unclamped
+	maxTTL           time.Duration // unclamped
+
+	servedDnskey time.Duration
+	servedMax    time.Duration
+
+	keys   map[uint16]*RolloverSimKey
+	dnskey string // rendered DNSKEY RRset, for change detection
+	phase  string
+	fails  int
+	errors map[ErrorType]string
+	stalls map[string]bool
+	parent simParent
+
+	res *RolloverSimResult
+}
+
+func (s *rolloverSim) event(at time.Time, kind string, keyid uint16, format string, args ...any) {
+	s.res.Events = append(s.res.Events, RolloverSimEvent{At: at, Kind: kind, KeyID: keyid, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (s *rolloverSim) finding(at time.Time, severity string, keyid uint16, format string, args ...any) {
+	s.res.Findings = append(s.res.Findings, RolloverSimFinding{At: at, Severity: severity, KeyID: keyid, Message: fmt.Sprintf(format, args...)})
+}
+
+// bootstrap seeds the steady state the simulation starts from: the keys
+// EnsureActiveDnssecKeys would mint, the bootstrap KSK registered with the
+// rollover engine, and its DS already served and confirmed at the parent.
+func (s *rolloverSim) bootstrap() error {
+	ksk, _, err := s.kdb.GenerateKeypair(s.zone, "rollover-simulation", DnskeyStateActive, dns.TypeDNSKEY, s.pol.KSKAlgorithm, "KSK", nil)
+	if err != nil {
+		return fmt.Errorf("SimulateRollover: generate KSK: %w", err)
+	}
+	if err := RegisterBootstrapActiveKSK(s.kdb, s.zone, ksk.KeyId, s.pol.Rollover.Method, s.pol.KSKAlgorithm); err != nil {
+		return fmt.Errorf("SimulateRollover: register KSK: %w", err)
+	}
+	if _, _, err := s.kdb.GenerateKeypair(s.zone, "rollover-simulation", DnskeyStateActive, dns.TypeDNSKEY, s.pol.ZSKAlgorithm, "ZSK", nil); err != nil {
+		return fmt.Errorf("SimulateRollover: generate ZSK: %w", err)
+	}
+
+	ds, low, high, idxOK, err := ComputeTargetDSSetForZone(s.kdb, s.zone, uint8(dns.SHA256), s.pol)
+	if err != nil {
+		return fmt.Errorf("SimulateRollover: initial DS set: %w", err)
+	}
+	if idxOK {
+		if err := saveLastDSSubmittedRange(s.kdb, s.zone, low, high); err != nil {
+			return err
+		}
+		if err := saveLastDSConfirmedRange(s.kdb, s.zone, low, high); err != nil {
+			return err
+		}
+	}
+	s.parent.visible = ds
+
+	// The daemon learns the parent's DS TTL from its first observation
+	// and evaluates the E5/E10/E11 gates against it.
+	s.zd.ParentDSTTLObserved = s.parent.ttl
+	EvaluateRolloverPolicyInvariants(s.zd, s.pol)
+
+	s.sign()
+	s.observe()
+	for _, k := range s.keys {
+		k.Bootstrap = true
+		k.Published = s.now
+		k.Active = s.now
+		if k.Role == "KSK" {
+			k.DSVisible = s.now
+		}
+	}
+	s.event(s.now, RolloverSimEventParent, 0, "parent serves DS %s (TTL %s)", dsKeyidList(ds), time.Duration(s.parent.ttl)*time.Second)
+	return nil
+}
+
+// sign stands in for the signer's pass: the TTLs it would serve under the
+// current clamp, and the max TTL it records for the engine's margins.
+func (s *rolloverSim) sign() {
+	cp, err := ClampParamsForZone(s.kdb, s.zone, s.pol, s.now)
+	if err != nil {
+		lgSigner.Warn("rollover simulation: clamp params", "zone", s.zone, "err", err)
+	}
+	dnskey := servedTTL(s.dnskeyTTL, cp)
+	max := servedTTL(s.maxTTL, cp)
+	if dnskey == s.servedDnskey && max == s.servedMax {
+		return
+	}
+	s.servedDnskey, s.servedMax = dnskey, max
+	if err := UpsertZoneSigningMaxTTL(s.kdb, s.zone, uint32(max.Seconds())); err != nil {
+		lgSigner.Warn("rollover simulation: record max TTL", "zone", s.zone, "err", err)
+	}
+	k := 0
+	if cp != nil {
+		k = cp.K
+	}
+	s.event(s.now, RolloverSimEventClamp, 0, "served TTLs: DNSKEY %s, zone max %s (K=%d)", dnskey, max, k)
+}
+
+// servedTTL is the TTL SignRRset serves for an RRset configured with ttl
+// (see applyClampToRRset).
+func servedTTL(ttl time.Duration, cp *ClampParams) time.Duration {
+	if cp == nil {
+		return ttl
+	}
+	if c := time.Duration(cp.CeilingTTL()) * time.Second; c > 0 && c < ttl {
+		ttl = c
+	}
+	if m := time.Duration(cp.MaxServedTTL) * time.Second; m > 0 && m < ttl {
+		ttl = m
+	}
+	return ttl
+}
+
+// dnskeyInZone reports whether a key in state is part of the served
+// DNSKEY RRset (FetchZoneDnskeysSql plus the active keys).
+func dnskeyInZone(state string) bool {
+	switch state {
+	case DnskeyStatePublished, DnskeyStateStandby, DnskeyStateActive, DnskeyStateRetired:
+		return true
+	}
+	return false
+}
+
+// observe diffs the keystore and the rollover state against the previous
+// tick and turns the differences into timeline events.
+func (s *rolloverSim) observe() {
+	rows, err := s.kdb.Query(`SELECT keyid, flags, state FROM DnssecKeyStore WHERE zonename=?`, s.zone)
+	if err != nil {
+		lgSigner.Warn("rollover simulation: list keys", "zone", s.zone, "err", err)
+		return
+	}
+	seen := map[uint16]bool{}
+	for rows.Next() {
+		var keyid, flags int
+		var state string
+		if err := rows.Scan(&keyid, &flags, &state); err != nil {
+			continue
+		}
+		seen[uint16(keyid)] = true
+		s.keyState(uint16(keyid), uint16(flags), state)
+	}
+	rows.Close()
+	for id, k := range s.keys {
+		if !seen[id] && k.state != DnskeyStateRemoved {
+			s.keyState(id, 0, DnskeyStateRemoved)
+		}
+	}
+
+	var inZone []string
+	ids := make([]int, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if k := s.keys[uint16(id)]; dnskeyInZone(k.state) {
+			inZone = append(inZone, fmt.Sprintf("%d(%s)", id, k.Role))
+		}
+	}
+	if rrset := strings.Join(inZone, " "); rrset != s.dnskey {
+		s.dnskey = rrset
+		s.event(s.now, RolloverSimEventDnskey, 0, "DNSKEY RRset: %s", rrset)
+	}
+
+	if row, err := LoadRolloverZoneRow(s.kdb, s.zone); err == nil && row != nil {
+		phase := row.RolloverPhase
+		if phase == "" {
+			phase = rolloverPhaseIdle
+		}
+		if phase != s.phase {
+			if s.phase != "" {
+				s.event(s.now, RolloverSimEventPhase, 0, "rollover phase %s → %s", s.phase, phase)
+				if s.parent.scheme == "NOTIFY" && (s.phase == rolloverPhasePendingParentObserve || s.phase == rolloverPhasePushSoftfail) &&
+					(phase == rolloverPhaseIdle || phase == rolloverPhasePendingChildWithdraw) {
+					s.event(s.now, RolloverSimEventCDS, 0, "DS confirmed at parent; CDS RRset withdrawn")
+				}
+			}
+			if phase == rolloverPhasePushSoftfail {
+				s.finding(s.now, RolloverSimStall, 0, "DS push entered softfail (%s): %s",
+					row.LastSoftfailCategory.String, row.LastSoftfailDetail.String)
+			}
+			s.phase = phase
+		}
+		if row.HardfailCount > s.fails {
+			s.event(s.now, RolloverSimEventPhase, 0, "attempt %d failed: %s: %s",
+				row.HardfailCount, row.LastSoftfailCategory.String, row.LastSoftfailDetail.String)
+		}
+		s.fails = row.HardfailCount
+	}
+
+	current := map[ErrorType]string{}
+	for _, e := range s.zd.ErrorList() {
+		current[e.Type] = e.Msg
+		if prev, ok := s.errors[e.Type]; ok && prev == e.Msg {
+			continue
+		}
+		s.event(s.now, RolloverSimEventError, 0, "zone error set: %s", e.Msg)
+		sev := RolloverSimWarn
+		if e.Type == RolloverPolicyViolation || e.Type == RolloverParentBlocker {
+			sev = RolloverSimStall
+		}
+		s.finding(s.now, sev, 0, "%s", e.Msg)
+	}
+	for t := range s.errors {
+		if _, ok := current[t]; !ok {
+			s.event(s.now, RolloverSimEventError, 0, "zone error cleared: %s", s.errors[t])
+		}
+	}
+	s.errors = current
+}
+
+func (s *rolloverSim) keyState(keyid, flags uint16, state string) {
+	k, ok := s.keys[keyid]
+	if !ok {
+		role := "ZSK"
+		if flags&dns.SEP != 0 {
+			role = "KSK"
+		}
+		k = &RolloverSimKey{KeyID: keyid, Role: role, Created: s.now}
+		s.keys[keyid] = k
+		s.res.Keys = append(s.res.Keys, k)
+	}
+	if state == k.state {
+		return
+	}
+	if k.state == "" {
+		s.event(s.now, RolloverSimEventKey, keyid, "%s %d created (%s)", k.Role, keyid, state)
+	} else {
+		s.event(s.now, RolloverSimEventKey, keyid, "%s %d %s → %s", k.Role, keyid, k.state, state)
+	}
+	if dnskeyInZone(state) && k.Published.IsZero() {
+		k.Published = s.now
+		k.publishTTL = s.servedDnskey
+	}
+	switch state {
+	case DnskeyStateActive:
+		if k.Active.IsZero() {
+			k.Active = s.now
+			if k.state != "" {
+				if k.Role == "KSK" {
+					s.res.KSKRolls++
+				} else {
+					s.res.ZSKRolls++
+				}
+			}
+		}
+	case DnskeyStateRetired:
+		k.Retired = s.now
+		k.retireTTL = s.servedMax
+		if k.Role == "KSK" {
+			k.retireTTL = s.servedDnskey
+		}
+	case DnskeyStateRemoved:
+		if !k.Published.IsZero() && k.Removed.IsZero() {
+			k.Removed = s.now
+		}
+	}
+	k.state = state
+}
+
+// inFlight reports whether the KSK engine is mid-push, where the engine's
+// own poll schedule matters and the simulation ticks at Step.
+func (s *rolloverSim) inFlight() bool {
+	switch s.phase {
+	case rolloverPhasePendingChildPublish, rolloverPhasePendingParentPush, rolloverPhasePendingParentObserve:
+		return true
+	}
+	return s.parent.hasPending
+}
+
+// stallGrace is how long past its due time a scheduled roll may lag
+// before it counts as stalled: the DS round trip and the idle tick are
+// expected, a tenth of the key lifetime is not.
+func stallGrace(lifetime uint32) time.Duration {
+	g := time.Duration(lifetime) * time.Second / 10
+	if g < 24*time.Hour {
+		g = 24 * time.Hour
+	}
+	return g
+}
+
+func (s *rolloverSim) checkStalls() {
+	if s.pol.Rollover.Method != RolloverMethodNone && s.pol.KSK.Lifetime > 0 {
+		if due, ok, err := tNextRoll(s.kdb, s.zone, s.pol); err == nil && ok && s.now.Sub(due) > stallGrace(s.pol.KSK.Lifetime) {
+			if id := "ksk@" + due.String(); !s.stalls[id] {
+				s.stalls[id] = true
+				s.finding(s.now, RolloverSimStall, 0, "KSK roll due %s has not happened (rollover phase %s)",
+					due.Format(time.RFC3339), s.phase)
+			}
+		}
+	}
+	if s.pol.Mode == DnssecPolicyModeKSKZSK && s.pol.ZSK.Lifetime > 0 {
+		active, err := GetDnssecKeysByState(s.kdb, s.zone, DnskeyStateActive)
+		if err != nil {
+			return
+		}
+		for _, k := range active {
+			if k.Flags != 256 || k.ActiveAt == nil {
+				continue
+			}
+			due := k.ActiveAt.Add(time.Duration(s.pol.ZSK.Lifetime) * time.Second)
+			if s.now.Sub(due) <= stallGrace(s.pol.ZSK.Lifetime) {
+				continue
+			}
+			if id := fmt.Sprintf("zsk@%d", k.KeyTag); !s.stalls[id] {
+				s.stalls[id] = true
+				s.finding(s.now, RolloverSimStall, k.KeyTag, "ZSK roll due %s has not happened", due.Format(time.RFC3339))
+			}
+		}
+	}
+}
+
+// finish derives each key's validator-safe window and flags the keys
+// that were used or removed outside it.
+func (s *rolloverSim) finish() {
+	dsTTL := time.Duration(s.parent.ttl) * time.Second
+	for _, k := range s.res.Keys {
+		if !k.Published.IsZero() && !k.Bootstrap {
+			k.SafeFrom = k.Published.Add(s.propagationDelay + k.publishTTL)
+			if k.Role == "KSK" {
+				if k.DSVisible.IsZero() {
+					k.SafeFrom = time.Time{}
+				} else if t := k.DSVisible.Add(dsTTL); t.After(k.SafeFrom) {
+					k.SafeFrom = t
+				}
+			}
+		}
+		if !k.Retired.IsZero() {
+			k.SafeRemoval = k.Retired.Add(k.retireTTL)
+		}
+
+		if !k.Active.IsZero() && !k.Bootstrap {
+			switch {
+			case k.Role == "KSK" && k.DSVisible.IsZero():
+				s.finding(k.Active, RolloverSimUnsafe, k.KeyID, "KSK %d became active with no DS at the parent", k.KeyID)
+			case k.Role == "KSK" && k.Active.Before(k.DSVisible.Add(dsTTL)):
+				s.finding(k.Active, RolloverSimUnsafe, k.KeyID, "KSK %d became active %s after its DS appeared at the parent; cached DS RRsets (TTL %s) may lack it (E1)",
+					k.KeyID, k.Active.Sub(k.DSVisible), dsTTL)
+			}
+			if !k.Published.IsZero() && k.Active.Before(k.Published.Add(s.propagationDelay+k.publishTTL)) {
+				s.finding(k.Active, RolloverSimUnsafe, k.KeyID, "%s %d became active %s after its DNSKEY was published; cached DNSKEY RRsets (TTL %s, propagation %s) may lack it (E3)",
+					k.Role, k.KeyID, k.Active.Sub(k.Published), k.publishTTL, s.propagationDelay)
+			}
+		}
+		if !k.Removed.IsZero() && !k.Retired.IsZero() && k.Removed.Before(k.SafeRemoval) {
+			s.finding(k.Removed, RolloverSimUnsafe, k.KeyID, "%s %d removed %s after retirement; signatures it made are cached for up to %s (E5)",
+				k.Role, k.KeyID, k.Removed.Sub(k.Retired), k.retireTTL)
+		}
+		if k.Role == "KSK" && !k.DSWithdrawn.IsZero() && !k.Active.IsZero() && (k.Retired.IsZero() || k.DSWithdrawn.Before(k.Retired)) {
+			s.finding(k.DSWithdrawn, RolloverSimUnsafe, k.KeyID, "DS for KSK %d withdrawn from the parent while the key was active", k.KeyID)
+		}
+	}
+	sort.SliceStable(s.res.Events, func(i, j int) bool { return s.res.Events[i].At.Before(s.res.Events[j].At) })
+	sort.SliceStable(s.res.Findings, func(i, j int) bool { return s.res.Findings[i].At.Before(s.res.Findings[j].At) })
+}
+
+// simParent is the model parent: it accepts every push and serves the
+// pushed DS RRset delay later.
+type simParent struct {
+	delay  time.Duration
+	ttl    uint32
+	scheme string
+
+	visible    []dns.RR
+	pending    []dns.RR
+	pendingAt  time.Time
+	hasPending bool
+}
+
+// advance publishes a pending DS RRset once its delay has passed.
+func (p *simParent) advance(s *rolloverSim) {
+	if !p.hasPending || s.now.Before(p.pendingAt) {
+		return
+	}
+	old := map[uint16]bool{}
+	for _, rr := range p.visible {
+		old[rr.(*dns.DS).KeyTag] = true
+	}
+	now := map[uint16]bool{}
+	for _, rr := range p.pending {
+		id := rr.(*dns.DS).KeyTag
+		now[id] = true
+		if k, ok := s.keys[id]; ok && !old[id] && k.DSVisible.IsZero() {
+			k.DSVisible = p.pendingAt
+		}
+	}
+	for id := range old {
+		if k, ok := s.keys[id]; ok && !now[id] && k.DSWithdrawn.IsZero() {
+			k.DSWithdrawn = p.pendingAt
+		}
+	}
+	p.visible, p.pending, p.hasPending = p.pending, nil, false
+	s.event(p.pendingAt, RolloverSimEventParent, 0, "parent serves DS %s", dsKeyidList(p.visible))
+}
+
+// pushDS is the simulation's RolloverParentHooks.PushDS. Like a
+// successful pushDSRRsetViaUpdate it records the submitted index range.
+func (s *rolloverSim) pushDS(ctx context.Context, deps RolloverEngineDeps) (KSKDSPushResult, error) {
+	ds, low, high, idxOK, err := ComputeTargetDSSetForZone(s.kdb, s.zone, uint8(dns.SHA256), s.pol)
+	if err != nil {
+		return KSKDSPushResult{Category: SoftfailChildConfigLocalError, Detail: err.Error()}, err
+	}
+	if idxOK {
+		err = saveLastDSSubmittedRange(s.kdb, s.zone, low, high)
+	} else {
+		err = clearLastDSSubmittedRange(s.kdb, s.zone)
+	}
+	if err != nil {
+		return KSKDSPushResult{Category: SoftfailChildConfigLocalError, Detail: err.Error()}, err
+	}
+	// A re-sent RRset the parent already holds (softfail probes, retries)
+	// does not restart its publication clock.
+	p := &s.parent
+	outcome := "parent already serves it"
+	switch {
+	case p.hasPending && dsKeyidList(ds) == dsKeyidList(p.pending):
+		outcome = "parent serves it by " + p.pendingAt.Format(time.RFC3339)
+	case !p.hasPending && dsKeyidList(ds) == dsKeyidList(p.visible):
+	default:
+		p.pending, p.pendingAt, p.hasPending = ds, s.now.Add(p.delay), true
+		outcome = "parent serves it by " + p.pendingAt.Format(time.RFC3339)
+	}
+	if p.scheme == "NOTIFY" {
+		s.event(s.now, RolloverSimEventCDS, 0, "CDS RRset %s published, NOTIFY sent; %s", dsKeyidList(ds), outcome)
+	} else {
+		s.event(s.now, RolloverSimEventDS, 0, "DS UPDATE %s sent; %s", dsKeyidList(ds), outcome)
+	}
+	return KSKDSPushResult{Rcode: dns.RcodeSuccess, Scheme: p.scheme}, nil
+}
+
+// queryDS is the simulation's RolloverParentHooks.QueryDS.
+func (s *rolloverSim) queryDS(ctx context.Context, zone, agent string) ([]dns.RR, error) {
+	out := make([]dns.RR, 0, len(s.parent.visible))
+	for _, rr := range s.parent.visible {
+		cp := dns.Copy(rr)
+		cp.Header().Ttl = s.parent.ttl
+		out = append(out, cp)
+	}
+	return out, nil
+}
+
+func dsKeyidList(rrs []dns.RR) string {
+	var ids []string
+	for _, rr := range rrs {
+		if ds, ok := rr.(*dns.DS); ok {
+			ids = append(ids, fmt.Sprintf("%d", ds.KeyTag))
+		}
+	}
+	return "{" + strings.Join(ids, ",") + "}"
+}
@@ -0,0 +1,90 @@
+package tdns
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func simTestPolicy(t *testing.T, dsDelay string) *DnssecPolicy {
+	t.Helper()
+	conf := &DnssecPolicyConf{Algorithm: "ECDSAP256SHA256"}
+	conf.KSK.Lifetime = "10d"
+	conf.ZSK.Lifetime = "5d"
+	conf.CSK.Lifetime = "10d"
+	conf.SigValidity.Default = "2d"
+	conf.Rollover.Method = "multi-ds"
+	conf.Rollover.NumDS = 2
+	conf.Rollover.ParentAgent = "127.0.0.1:53"
+	conf.Rollover.DsPublishDelay = dsDelay
+	conf.Ttls.DNSKEY = "1h"
+	pol, err := ParseDnssecPolicyConfQuiet("sim", conf)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return pol
+}
+
+func TestSimulateRolloverMultiDS(t *testing.T) {
+	pol := simTestPolicy(t, "30m")
+	res, err := SimulateRollover(context.Background(), RolloverSimConfig{
+		Zone:     "sim.example.",
+		Policy:   pol,
+		Start:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Horizon:  40 * 24 * time.Hour,
+		Step:     5 * time.Minute,
+		IdleStep: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.KSKRolls == 0 {
+		t.Errorf("no KSK roll in 40 days under a 10d KSK lifetime")
+	}
+	if res.ZSKRolls == 0 {
+		t.Errorf("no ZSK roll in 40 days under a 5d ZSK lifetime")
+	}
+	for _, f := range res.Findings {
+		if f.Severity == RolloverSimUnsafe {
+			t.Errorf("unexpected finding at %s: %s %s", f.At, f.Severity, f.Message)
+		}
+	}
+	var sawDS, sawParent bool
+	for _, e := range res.Events {
+		sawDS = sawDS || e.Kind == RolloverSimEventDS
+		sawParent = sawParent || (e.Kind == RolloverSimEventParent && !e.At.Equal(res.Start))
+	}
+	if !sawDS || !sawParent {
+		t.Errorf("timeline lacks DS push (%v) or parent publication (%v)", sawDS, sawParent)
+	}
+	if _, ok := Zones.Get("sim.example."); ok {
+		t.Errorf("simulation zone left in Zones")
+	}
+}
+
+// A parent slower than the confirm timeout stalls the KSK roll.
+func TestSimulateRolloverSlowParentStalls(t *testing.T) {
+	pol := simTestPolicy(t, "30m")
+	res, err := SimulateRollover(context.Background(), RolloverSimConfig{
+		Zone:     "sim.example.",
+		Policy:   pol,
+		Start:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Horizon:  20 * 24 * time.Hour,
+		Step:     5 * time.Minute,
+		IdleStep: time.Hour,
+		DSDelay:  pol.Rollover.ConfirmTimeout * 200,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stalled := false
+	for _, f := range res.Findings {
+		if f.Severity == RolloverSimStall && strings.Contains(f.Message, "softfail") {
+			stalled = true
+		}
+	}
+	if !stalled {
+		t.Errorf("no softfail stall with DS delay %s > confirm timeout %s", pol.Rollover.ConfirmTimeout*200, pol.Rollover.ConfirmTimeout)
+	}
+}
@@ -30,8 +30,11 @@ type RuntimeConfig struct {
 	MaxRefresh       int  // service.maxrefresh
 	MinRefresh       int  // service.minrefresh
 	ResignerInterval int  // resignerengine.interval
+	SignerWorkers    int  // resignerengine.workers
 	PeriodicResign   bool // service.resign
 	ServiceDebug     bool // service.debug
+
+	ZoneLint string // dnsengine.zone-lint
 }
 
 // liveConfig holds the current published snapshot. Seeded with an empty snapshot
@@ -64,8 +67,10 @@ func (conf *Config) buildRuntimeConfig() *RuntimeConfig {
 		MaxRefresh:       viper.GetInt("service.maxrefresh"),
 		MinRefresh:       viper.GetInt("service.minrefresh"),
 		ResignerInterval: viper.GetInt("resignerengine.interval"),
+		SignerWorkers:    viper.GetInt("resignerengine.workers"),
 		PeriodicResign:   viper.GetBool("service.resign"),
 		ServiceDebug:     viper.GetBool("service.debug"),
+		ZoneLint:         conf.DnsEngine.ZoneLint,
 	}
 }
 
@@ -57,6 +57,7 @@ type Scanner struct {
 	AtApexChecks       int
 	AtApexInterval     time.Duration
 	OnDelegationChange func(parentZone string, zd *ZoneData, resp ScanTupleResponse)
+	RescanQ            chan ScanRequest // for CDS rechecks scheduled by a cds policy
 	LogFile            string
 	LogTemplate        string
 	Log                map[string]*log.Logger
@@ -64,6 +65,15 @@ type Scanner struct {
 	Debug              bool
 	Jobs               map[string]*ScanJobStatus
 	JobsMutex          sync.RWMutex
+	HistoryJobs        int       // jobs kept in memory and in the keystore (scanner_history.go)
+	Sweep              SweepConf // periodic scan of all children (scanner_sweep.go)
+	rechecks           map[string]*cdsRecheck
+	recheckMu          sync.Mutex
+	kdb                *KeyDB
+	sweepRunning       map[string]bool      // parent zones with a sweep in progress
+	sweepLast          map[string]time.Time // start of the latest sweep per parent zone
+	sweepMu            sync.Mutex
+	sweepScan          func(ctx context.Context, parentZD *ZoneData, child string, scanType ScanType) ScanTupleResponse
 }
 
 func (scanner *Scanner) HasOption(name string) bool {
@@ -127,6 +137,17 @@ func ScannerEngine(ctx context.Context, conf *Config) error {
 		atApexIntervalSec = 300
 	}
 	scanner.AtApexInterval = time.Duration(atApexIntervalSec) * time.Second
+	scanner.RescanQ = scannerq
+	scanner.kdb = conf.Internal.KeyDB
+	scanner.HistoryJobs = viper.GetInt("scanner.history.jobs")
+	sweep, err := parseSweepConf()
+	if err != nil {
+		return err
+	}
+	scanner.Sweep = sweep
+	if err := scanner.loadJobHistory(); err != nil {
+		lg.Error("ScannerEngine: loading job history failed", "error", err)
+	}
 	scanner.AddLogger("CDS")
 	scanner.AddLogger("CSYNC")
 	scanner.AddLogger("DNSKEY")
@@ -206,6 +227,8 @@ func ScannerEngine(ctx context.Context, conf *Config) error {
 			lg.Info("ScannerEngine: context cancelled")
 			return nil
 		case <-ticker.C:
+			scanner.ImrEngine = conf.Internal.ImrEngine
+			scanner.startDueSweeps(ctx)
 
 		case sr, ok := <-scannerq:
 			if !ok {
@@ -232,40 +255,10 @@ func ScannerEngine(ctx context.Context, conf *Config) error {
 						Zone: sr.ChildZone,
 					}
 
-					// Fetch current DS from delegation backend for comparison.
-					// A parent zone that accepts child updates MUST have a
-					// DelegationBackend (enforced at config-validation time).
-					// Without it the scanner has no way to know the current
-					// DS state, so every CDS-NOTIFY would look like a fresh
-					// delegation and DS records would accumulate without
-					// ever being removed.
 					if sr.ZoneData == nil {
 						lg.Error("ScannerEngine: no ZoneData on scan request, cannot compute current delegation state", "child", sr.ChildZone)
-					} else if sr.ZoneData.DelegationBackend == nil {
-						if sr.ZoneData.Options[OptAllowChildUpdates] {
-							lg.Error("ScannerEngine: zone allows child updates but has no DelegationBackend; diff against empty current state will produce spurious adds (invariant violation)", "child", sr.ChildZone, "parent", sr.ZoneData.ZoneName)
-						} else {
-							lg.Warn("ScannerEngine: parent zone has no DelegationBackend, cannot read current DS for diff", "child", sr.ChildZone, "parent", sr.ZoneData.ZoneName)
-						}
 					} else {
-						delegData, err := sr.ZoneData.DelegationBackend.GetDelegationData(sr.ZoneData.ZoneName, sr.ChildZone)
-						if err != nil {
-							lg.Warn("ScannerEngine: error fetching delegation data", "child", sr.ChildZone, "error", err)
-						} else if delegData != nil {
-							var dsRRs []dns.RR
-							for _, rrsByType := range delegData {
-								if dsRecords, ok := rrsByType[dns.TypeDS]; ok {
-									dsRRs = append(dsRRs, dsRecords...)
-								}
-							}
-							if len(dsRRs) > 0 {
-								tuple.CurrentData.DS = &core.RRset{
-									Name:   sr.ChildZone,
-									RRtype: dns.TypeDS,
-									RRs:    dsRRs,
-								}
-							}
-						}
+						tuple.CurrentData.DS = currentDelegationDS(sr.ZoneData, sr.ChildZone)
 					}
 
 					sr.ScanTuples = []ScanTuple{tuple}
@@ -287,6 +280,7 @@ func ScannerEngine(ctx context.Context, conf *Config) error {
 
 				job := &ScanJobStatus{
 					JobID:           jobID,
+					Kind:            "request",
 					Status:          "processing",
 					CreatedAt:       time.Now(),
 					TotalTuples:     len(sr.ScanTuples),
@@ -296,10 +290,18 @@ func ScannerEngine(ctx context.Context, conf *Config) error {
 				}
 				startedAt := time.Now()
 				job.StartedAt = &startedAt
-
-				scanner.JobsMutex.Lock()
-				scanner.Jobs[jobID] = job
-				scanner.JobsMutex.Unlock()
+				if sr.ZoneData != nil {
+					job.Parent = sr.ZoneData.ZoneName
+					if sr.ChildZone != "" {
+						job.Kind = "notify"
+					}
+				}
+				for _, tuple := range sr.ScanTuples {
+					if tuple.Zone == "" {
+						job.IgnoredTuples++
+					}
+				}
+				scanner.addJob(job)
 
 				// Create response channel for collecting all scan results
 				responseCh := make(chan ScanTupleResponse, len(sr.ScanTuples))
@@ -308,7 +310,6 @@ func ScannerEngine(ctx context.Context, conf *Config) error {
 				for _, tuple := range sr.ScanTuples {
 					if tuple.Zone == "" {
 						lg.Warn("ScannerEngine: zone unspecified, ignoring")
-						job.IgnoredTuples++
 						continue
 					}
 
@@ -391,26 +392,24 @@ func ScannerEngine(ctx context.Context, conf *Config) error {
 					}
 
 					// Update job status
-					scanner.JobsMutex.Lock()
-					job, exists := scanner.Jobs[jobID]
-					if exists {
+					scanner.updateJob(jobID, func(job *ScanJobStatus) {
 						job.Responses = responses
 						job.ProcessedTuples = len(responses)
+						for _, resp := range responses {
+							if resp.Error {
+								job.ErrorTuples++
+							}
+						}
 						job.Status = "completed"
 						completedAt := time.Now()
 						job.CompletedAt = &completedAt
-					}
-					scanner.JobsMutex.Unlock()
+					})
+					scanner.pruneJobs()
 
 					// Notify caller of delegation changes (DS from CDS, NS/glue from CSYNC)
-					if scanner.OnDelegationChange != nil && parentZD != nil {
+					if parentZD != nil {
 						for _, resp := range responses {
-							hasDSChanges := len(resp.DSAdds) > 0 || len(resp.DSRemoves) > 0
-							hasNSChanges := len(resp.NSAdds) > 0 || len(resp.NSRemoves) > 0
-							hasGlueChanges := len(resp.GlueAdds) > 0 || len(resp.GlueRemoves) > 0
-							if resp.DataChanged && (hasDSChanges || hasNSChanges || hasGlueChanges) {
-								scanner.OnDelegationChange(parentZD.ZoneName, parentZD, resp)
-							}
+							scanner.actOnResponse(parentZD, resp)
 						}
 					}
 
@@ -423,6 +422,55 @@ func ScannerEngine(ctx context.Context, conf *Config) error {
 	}
 }
 
+// currentDelegationDS returns the DS RRset for child held by the parent
+// zone's DelegationBackend, or nil. A parent zone that accepts child updates
+// MUST have a DelegationBackend (enforced at config-validation time).
+// Without it the scanner has no way to know the current DS state, so every
+// CDS scan would look like a fresh delegation and DS records would
+// accumulate without ever being removed.
+func currentDelegationDS(zd *ZoneData, child string) *core.RRset {
+	if zd.DelegationBackend == nil {
+		if zd.Options[OptAllowChildUpdates] {
+			lg.Error("ScannerEngine: zone allows child updates but has no DelegationBackend; diff against empty current state will produce spurious adds (invariant violation)", "child", child, "parent", zd.ZoneName)
+		} else {
+			lg.Warn("ScannerEngine: parent zone has no DelegationBackend, cannot read current DS for diff", "child", child, "parent", zd.ZoneName)
+		}
+		return nil
+	}
+	delegData, err := zd.DelegationBackend.GetDelegationData(zd.ZoneName, child)
+	if err != nil {
+		lg.Warn("ScannerEngine: error fetching delegation data", "child", child, "error", err)
+		return nil
+	}
+	var dsRRs []dns.RR
+	for _, rrsByType := range delegData {
+		if dsRecords, ok := rrsByType[dns.TypeDS]; ok {
+			dsRRs = append(dsRRs, dsRecords...)
+		}
+	}
+	if len(dsRRs) == 0 {
+		return nil
+	}
+	return &core.RRset{Name: child, RRtype: dns.TypeDS, RRs: dsRRs}
+}
+
+// actOnResponse hands a changed delegation (DS from CDS, NS/glue from CSYNC)
+// to OnDelegationChange and records the child's scan outcome.
+func (scanner *Scanner) actOnResponse(parentZD *ZoneData, resp ScanTupleResponse) {
+	hasDSChanges := len(resp.DSAdds) > 0 || len(resp.DSRemoves) > 0
+	hasNSChanges := len(resp.NSAdds) > 0 || len(resp.NSRemoves) > 0
+	hasGlueChanges := len(resp.GlueAdds) > 0 || len(resp.GlueRemoves) > 0
+	accepted := resp.DataChanged && (hasDSChanges || hasNSChanges || hasGlueChanges)
+	if accepted && scanner.OnDelegationChange != nil {
+		scanner.OnDelegationChange(parentZD.ZoneName, parentZD, resp)
+	}
+	if scanner.kdb != nil && resp.Qname != "" {
+		if err := recordScanChildResult(scanner.kdb, parentZD.ZoneName, resp, accepted, time.Now()); err != nil {
+			lg.Error("ScannerEngine: recording child scan state failed", "child", resp.Qname, "error", err)
+		}
+	}
+}
+
 // findEnclosingZoneNS determines the enclosing zone for a given name and returns
 // the zone name and its NS RRset. If the name is a zone (has SOA), it returns that zone's NS.
 // Otherwise, it finds the parent zone and returns the parent's NS.
@@ -477,10 +525,20 @@ func (imr *Imr) findEnclosingZoneNS(ctx context.Context, qname string, lg *log.L
 	return zoneName, nsRRset, nil
 }
 
-// queryAllNSAndCompare queries all nameservers in an NS RRset for a given qname/qtype,
-// compares the responses, and returns a representative RRset and whether all NS were in sync.
-// Returns: (responseRRset, allInSync, error)
-func (scanner *Scanner) queryAllNSAndCompare(ctx context.Context, qname string, qtype uint16, nsRRset *core.RRset, imr *Imr, lg *log.Logger) (*core.RRset, bool, error) {
+// nsAnswers is what queryAllNS learned from the nameservers in an NS RRset.
+type nsAnswers struct {
+	RRset    *core.RRset // the first answer; no RRs for NODATA, nil when none answered
+	InSync   bool        // every answer is the same RRset
+	Answered int         // nameservers that answered, NODATA included
+	Total    int
+	Missing  []string // nameservers that did not answer
+}
+
+// queryAllNS queries every nameserver in an NS RRset for qname/qtype over
+// TCP and compares the answers. A NODATA answer counts as an answer (with
+// no RRs) and takes part in the comparison. It only fails when there is no
+// IMR or no nameserver to ask.
+func (scanner *Scanner) queryAllNS(ctx context.Context, qname string, qtype uint16, nsRRset *core.RRset, imr *Imr, lg *log.Logger) (*nsAnswers, error) {
 	// IMR may be disabled or the generalized-NOTIFY path may have
 	// reached the scanner before the IMR singleton was initialized;
 	// without this guard the subsequent imr.ImrQuery(...) call
@@ -488,7 +546,7 @@ func (scanner *Scanner) queryAllNSAndCompare(ctx context.Context, qname string,
 	// from inside a server handler goroutine, killing the daemon on
 	// otherwise-accepted NOTIFY(CDS/CSYNC) traffic.
 	if imr == nil {
-		return nil, false, fmt.Errorf("queryAllNSAndCompare: IMR is not initialized; cannot compare child NS data")
+		return nil, fmt.Errorf("queryAllNSAndCompare: IMR is not initialized; cannot compare child NS data")
 	}
 	// Extract nameserver names from NS RRset
 	var nsNames []string
@@ -499,7 +557,7 @@ func (scanner *Scanner) queryAllNSAndCompare(ctx context.Context, qname string,
 	}
 
 	if len(nsNames) == 0 {
-		return nil, false, fmt.Errorf("no nameservers found in NS RRset")
+		return nil, fmt.Errorf("no nameservers found in NS RRset")
 	}
 
 	if lg != nil {
@@ -507,8 +565,8 @@ func (scanner *Scanner) queryAllNSAndCompare(ctx context.Context, qname string,
 	}
 
 	// Query from each nameserver and collect responses
+	a := &nsAnswers{Total: len(nsNames), InSync: true}
 	var responseRRsets []*core.RRset
-	var queryErrors []error
 
 	for _, nsName := range nsNames {
 		// Get A/AAAA records for the nameserver
@@ -537,6 +595,7 @@ func (scanner *Scanner) queryAllNSAndCompare(ctx context.Context, qname string,
 			if lg != nil {
 				lg.Printf("queryAllNSAndCompare: no addresses found for NS %s, skipping", nsName)
 			}
+			a.Missing = append(a.Missing, nsName)
 			continue
 		}
 
@@ -547,49 +606,58 @@ func (scanner *Scanner) queryAllNSAndCompare(ctx context.Context, qname string,
 			if lg != nil {
 				lg.Printf("queryAllNSAndCompare: error querying %s %s from %s (%s): %v", qname, dns.TypeToString[qtype], nsName, nsAddrs[0], err)
 			}
-			_ = append(queryErrors, fmt.Errorf("NS %s: %v", nsName, err))
+			a.Missing = append(a.Missing, nsName)
 			continue
 		}
-		if rrset == nil || len(rrset.RRs) == 0 {
-			if lg != nil {
-				lg.Printf("queryAllNSAndCompare: no %s RRset found from %s", dns.TypeToString[qtype], nsName)
-			}
-			continue
+		if rrset == nil {
+			rrset = &core.RRset{Name: qname}
+		}
+		if len(rrset.RRs) == 0 && lg != nil {
+			lg.Printf("queryAllNSAndCompare: no %s RRset found from %s", dns.TypeToString[qtype], nsName)
 		}
+		rrset.Name, rrset.Class, rrset.RRtype = qname, dns.ClassINET, qtype
 		responseRRsets = append(responseRRsets, rrset)
 	}
-
-	// Check if we got any responses
-	if len(responseRRsets) == 0 {
-		return nil, false, fmt.Errorf("no %s RRsets retrieved from any nameserver", dns.TypeToString[qtype])
-	}
-
-	// If only one response, we can't compare but return it
-	if len(responseRRsets) == 1 {
-		if lg != nil {
-			lg.Printf("queryAllNSAndCompare: only one %s RRset retrieved (cannot compare)", dns.TypeToString[qtype])
-		}
-		return responseRRsets[0], true, nil // Consider it "in sync" since there's only one
+	a.Answered = len(responseRRsets)
+	if a.Answered == 0 {
+		return a, nil
 	}
 
 	// Compare all responses to see if they're in sync
-	baseRRset := responseRRsets[0]
-	allInSync := true
+	a.RRset = responseRRsets[0]
 	for i := 1; i < len(responseRRsets); i++ {
-		changed, adds, removes := core.RRsetDiffer(qname, baseRRset.RRs, responseRRsets[i].RRs, qtype, lg, scanner.Verbose, scanner.Debug)
+		changed, adds, removes := core.RRsetDiffer(qname, a.RRset.RRs, responseRRsets[i].RRs, qtype, lg, scanner.Verbose, scanner.Debug)
 		if changed {
 			if lg != nil {
 				lg.Printf("queryAllNSAndCompare: %s RRsets differ between nameservers. Adds: %d, Removes: %d", dns.TypeToString[qtype], len(adds), len(removes))
 			}
-			allInSync = false
+			a.InSync = false
 		}
 	}
 
-	if allInSync && lg != nil {
-		lg.Printf("queryAllNSAndCompare: all %d nameservers have identical %s RRsets", len(responseRRsets), dns.TypeToString[qtype])
+	if lg != nil {
+		switch {
+		case a.Answered == 1:
+			lg.Printf("queryAllNSAndCompare: only one %s RRset retrieved (cannot compare)", dns.TypeToString[qtype])
+		case a.InSync:
+			lg.Printf("queryAllNSAndCompare: all %d nameservers have identical %s RRsets", a.Answered, dns.TypeToString[qtype])
+		}
 	}
+	return a, nil
+}
 
-	return baseRRset, allInSync, nil
+// queryAllNSAndCompare queries all nameservers in an NS RRset for a given qname/qtype,
+// compares the responses, and returns a representative RRset and whether all NS were in sync.
+// Returns: (responseRRset, allInSync, error)
+func (scanner *Scanner) queryAllNSAndCompare(ctx context.Context, qname string, qtype uint16, nsRRset *core.RRset, imr *Imr, lg *log.Logger) (*core.RRset, bool, error) {
+	a, err := scanner.queryAllNS(ctx, qname, qtype, nsRRset, imr, lg)
+	if err != nil {
+		return nil, false, err
+	}
+	if a.RRset == nil || len(a.RRset.RRs) == 0 {
+		return nil, false, fmt.Errorf("no %s RRsets retrieved from any nameserver", dns.TypeToString[qtype])
+	}
+	return a.RRset, a.InSync, nil
 }
 
 func (scanner *Scanner) CheckCDS(ctx context.Context, tuple ScanTuple, scanType ScanType, options *edns0.MsgOptions, responseCh chan<- ScanTupleResponse) {
@@ -1144,6 +1212,24 @@ func (scanner *Scanner) ProcessCDSNotify(ctx context.Context, tuple ScanTuple, p
 		return
 	}
 
+	// A parent zone with a CDS acceptance policy decides in applyCdsPolicy
+	// (cds_acceptance.go) rather than acting on the first consistent CDS.
+	// It needs to know which nameservers answered, so it gets all answers.
+	if parentZD.CdsPolicy != nil {
+		cds, err := scanner.queryAllNS(ctx, childZone, dns.TypeCDS, nsRRset, scanner.ImrEngine, scanLog)
+		if err != nil {
+			scanLog.Printf("ProcessCDSNotify: %s: error querying CDS from child NS: %v", childZone, err)
+			response.Error = true
+			response.ErrorMsg = fmt.Sprintf("error querying CDS: %v", err)
+			responseCh <- response
+			return
+		}
+		response.AllNSInSync = cds.InSync && cds.Answered == cds.Total
+		scanner.applyCdsPolicy(ctx, parentZD.CdsPolicy, tuple, parentZD, nsRRset, cds, &response, scanLog)
+		responseCh <- response
+		return
+	}
+
 	// 2. Query CDS from all child NS via AuthQueryNG/TCP
 	cdsRRset, allInSync, err := scanner.queryAllNSAndCompare(ctx, childZone, dns.TypeCDS, nsRRset, scanner.ImrEngine, scanLog)
 	if err != nil {
@@ -1197,7 +1283,7 @@ func (scanner *Scanner) ProcessCDSNotify(ctx context.Context, tuple ScanTuple, p
 			// RFC 8078 recommends repeated checks over time before
 			// accepting. Config: at-apex.checks and at-apex.interval.
 			if scanner.AtApexChecks > 1 {
-				scanLog.Printf("ProcessCDSNotify: %s: RFC 8078 bootstrapping: config requires %d checks at %v intervals, but only performing 1 check (time-delay not yet implemented)", childZone, scanner.AtApexChecks, scanner.AtApexInterval)
+				scanLog.Printf("ProcessCDSNotify: %s: RFC 8078 bootstrapping: config requires %d checks at %v intervals, but only performing 1 check (repeated checks need a cdspolicy on the parent zone)", childZone, scanner.AtApexChecks, scanner.AtApexInterval)
 			}
 			scanLog.Printf("ProcessCDSNotify: %s: RFC 8078 bootstrapping (no existing DS), accepting CDS without DNSSEC validation", childZone)
 		} else {
@@ -1237,24 +1323,7 @@ func (scanner *Scanner) ProcessCDSNotify(ctx context.Context, tuple ScanTuple, p
 	}
 
 	// 4. Convert CDS → DS
-	var newDSRRs []dns.RR
-	for _, rr := range cdsRRset.RRs {
-		if cds, ok := rr.(*dns.CDS); ok {
-			ds := &dns.DS{
-				Hdr: dns.RR_Header{
-					Name:   cds.Hdr.Name,
-					Rrtype: dns.TypeDS,
-					Class:  dns.ClassINET,
-					Ttl:    cds.Hdr.Ttl,
-				},
-				KeyTag:     cds.KeyTag,
-				Algorithm:  cds.Algorithm,
-				DigestType: cds.DigestType,
-				Digest:     cds.Digest,
-			}
-			newDSRRs = append(newDSRRs, ds)
-		}
-	}
+	newDSRRs := cdsToDS(cdsRRset.RRs)
 
 	// 5. Compare new DS vs current DS from delegation backend
 	var currentDSRRs []dns.RR
@@ -0,0 +1,300 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ *
+ * Persistent scanner state: the job history behind 'scanner status' and the
+ * per-child outcome of the latest scan, including how long the last change
+ * took from first being seen at the child to being accepted.
+ */
+package tdns
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const defaultScanHistoryJobs = 1000
+
+func saveScanJob(kdb *KeyDB, job *ScanJobStatus) error {
+	buf, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("saveScanJob: %w", err)
+	}
+	_, err = kdb.DB.Exec(`
+INSERT OR REPLACE INTO ScanJob (job_id, kind, parent, status, created_at, job)
+VALUES (?, ?, ?, ?, ?, ?)`, job.JobID, job.Kind, job.Parent, job.Status, job.CreatedAt.UTC().Format(time.RFC3339Nano), string(buf))
+	if err != nil {
+		return fmt.Errorf("saveScanJob: %w", err)
+	}
+	return nil
+}
+
+// loadScanJobs returns the newest limit jobs, newest first.
+func loadScanJobs(kdb *KeyDB, limit int) ([]*ScanJobStatus, error) {
+	rows, err := kdb.DB.Query(`SELECT job FROM ScanJob ORDER BY created_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("loadScanJobs: %w", err)
+	}
+	defer rows.Close()
+	var jobs []*ScanJobStatus
+	for rows.Next() {
+		var buf string
+		if err := rows.Scan(&buf); err != nil {
+			return nil, fmt.Errorf("loadScanJobs: %w", err)
+		}
+		var job ScanJobStatus
+		if err := json.Unmarshal([]byte(buf), &job); err != nil {
+			lg.Warn("loadScanJobs: skipping unreadable job", "err", err)
+			continue
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, rows.Err()
+}
+
+// deleteScanJob deletes one job, or all of them if jobID is empty.
+func deleteScanJob(kdb *KeyDB, jobID string) error {
+	var err error
+	if jobID == "" {
+		_, err = kdb.DB.Exec(`DELETE FROM ScanJob`)
+	} else {
+		_, err = kdb.DB.Exec(`DELETE FROM ScanJob WHERE job_id = ?`, jobID)
+	}
+	if err != nil {
+		return fmt.Errorf("deleteScanJob: %w", err)
+	}
+	return nil
+}
+
+// lastSweepStart returns when the newest sweep job for parent was created.
+func lastSweepStart(kdb *KeyDB, parent string) (time.Time, bool) {
+	var created string
+	err := kdb.DB.QueryRow(`SELECT created_at FROM ScanJob WHERE kind = 'sweep' AND parent = ? ORDER BY created_at DESC LIMIT 1`, parent).Scan(&created)
+	if err != nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, created)
+	return t, err == nil
+}
+
+// addJob registers a new job and persists it.
+func (scanner *Scanner) addJob(job *ScanJobStatus) {
+	scanner.JobsMutex.Lock()
+	scanner.Jobs[job.JobID] = job
+	cp := deepCopyScanJobStatus(job)
+	scanner.JobsMutex.Unlock()
+	scanner.persistJob(cp)
+}
+
+// updateJob applies fn to a job under the lock and persists the result.
+func (scanner *Scanner) updateJob(jobID string, fn func(*ScanJobStatus)) {
+	scanner.JobsMutex.Lock()
+	job, ok := scanner.Jobs[jobID]
+	if !ok {
+		scanner.JobsMutex.Unlock()
+		return
+	}
+	fn(job)
+	cp := deepCopyScanJobStatus(job)
+	scanner.JobsMutex.Unlock()
+	scanner.persistJob(cp)
+}
+
+func (scanner *Scanner) persistJob(job *ScanJobStatus) {
+	if scanner.kdb == nil {
+		return
+	}
+	if err := saveScanJob(scanner.kdb, job); err != nil {
+		lg.Error("scanner: persisting job failed", "jobID", job.JobID, "err", err)
+	}
+}
+
+// historyLimit is the number of jobs kept, in memory and in the keystore.
+func (scanner *Scanner) historyLimit() int {
+	if scanner.HistoryJobs > 0 {
+		return scanner.HistoryJobs
+	}
+	return defaultScanHistoryJobs
+}
+
+// loadJobHistory fills Jobs from the keystore at startup. A job that was
+// still running when the previous process stopped is marked failed.
+func (scanner *Scanner) loadJobHistory() error {
+	if scanner.kdb == nil {
+		return nil
+	}
+	jobs, err := loadScanJobs(scanner.kdb, scanner.historyLimit())
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.Status == "processing" || job.Status == "queued" {
+			job.Status = "failed"
+			job.Error = true
+			job.ErrorMsg = "interrupted by restart"
+			scanner.persistJob(job)
+		}
+		scanner.JobsMutex.Lock()
+		scanner.Jobs[job.JobID] = job
+		scanner.JobsMutex.Unlock()
+	}
+	lg.Info("scanner: loaded job history", "jobs", len(jobs))
+	return nil
+}
+
+// pruneJobs drops the oldest completed jobs beyond the history limit.
+func (scanner *Scanner) pruneJobs() {
+	limit := scanner.historyLimit()
+	scanner.JobsMutex.Lock()
+	if len(scanner.Jobs) <= limit {
+		scanner.JobsMutex.Unlock()
+		return
+	}
+	jobs := make([]*ScanJobStatus, 0, len(scanner.Jobs))
+	for _, job := range scanner.Jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	var drop []string
+	for _, job := range jobs[limit:] {
+		if job.Status == "processing" || job.Status == "queued" {
+			continue
+		}
+		delete(scanner.Jobs, job.JobID)
+		drop = append(drop, job.JobID)
+	}
+	scanner.JobsMutex.Unlock()
+
+	if scanner.kdb == nil {
+		return
+	}
+	for _, id := range drop {
+		if err := deleteScanJob(scanner.kdb, id); err != nil {
+			lg.Error("scanner: pruning job history failed", "err", err)
+			return
+		}
+	}
+}
+
+// recordScanChildResult updates the child's ScanChildState from one scan
+// response. accepted is true when the response was handed on as a
+// delegation change.
+func recordScanChildResult(kdb *KeyDB, parent string, resp ScanTupleResponse, accepted bool, now time.Time) error {
+	scanType := ScanTypeToString[resp.ScanType]
+	st, err := loadScanChildState(kdb, parent, resp.Qname, scanType)
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		st = &ScanChildState{Parent: parent, Child: resp.Qname, ScanType: scanType}
+	}
+	st.LastScanned = now
+	markPending := func() {
+		if st.PendingSince == nil {
+			t := now
+			st.PendingSince = &t
+		}
+	}
+	switch {
+	case resp.Error:
+		// Says nothing about whether a change is outstanding.
+		st.State = "error"
+		st.LastError = resp.ErrorMsg
+	case accepted:
+		since := now
+		if st.PendingSince != nil {
+			since = *st.PendingSince
+		}
+		t := now
+		st.State, st.LastAccepted, st.AcceptDelay = "accepted", &t, now.Sub(since)
+		st.PendingSince, st.LastError = nil, ""
+	case resp.Decision == CdsVerdictDeferred:
+		st.State, st.LastError = "pending", ""
+		markPending()
+	case resp.Decision == CdsVerdictRejected:
+		st.State, st.LastError = "rejected", resp.DecisionReason
+		markPending()
+	default:
+		st.State, st.PendingSince, st.LastError = "in-sync", nil, ""
+	}
+	return saveScanChildState(kdb, st)
+}
+
+const selectScanChildStateSql = `
+SELECT parent, child, scan_type, state, last_scanned, pending_since, last_accepted, accept_delay, last_error
+FROM ScanChildState`
+
+func scanScanChildState(sc interface{ Scan(...any) error }) (*ScanChildState, error) {
+	var st ScanChildState
+	var scanned string
+	var pending, accepted sql.NullString
+	var delay int64
+	if err := sc.Scan(&st.Parent, &st.Child, &st.ScanType, &st.State, &scanned, &pending, &accepted, &delay, &st.LastError); err != nil {
+		return nil, err
+	}
+	st.LastScanned, _ = time.Parse(time.RFC3339, scanned)
+	if t, ok := parseOptionalTime(pending); ok {
+		st.PendingSince = &t
+	}
+	if t, ok := parseOptionalTime(accepted); ok {
+		st.LastAccepted = &t
+	}
+	st.AcceptDelay = time.Duration(delay) * time.Second
+	return &st, nil
+}
+
+func loadScanChildState(kdb *KeyDB, parent, child, scanType string) (*ScanChildState, error) {
+	row := kdb.DB.QueryRow(selectScanChildStateSql+` WHERE parent = ? AND child = ? AND scan_type = ?`, parent, child, scanType)
+	st, err := scanScanChildState(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loadScanChildState: %w", err)
+	}
+	return st, nil
+}
+
+func saveScanChildState(kdb *KeyDB, st *ScanChildState) error {
+	optional := func(t *time.Time) any {
+		if t == nil {
+			return nil
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	_, err := kdb.DB.Exec(`
+INSERT OR REPLACE INTO ScanChildState
+  (parent, child, scan_type, state, last_scanned, pending_since, last_accepted, accept_delay, last_error)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		st.Parent, st.Child, st.ScanType, st.State, st.LastScanned.UTC().Format(time.RFC3339),
+		optional(st.PendingSince), optional(st.LastAccepted), int64(st.AcceptDelay/time.Second), st.LastError)
+	if err != nil {
+		return fmt.Errorf("saveScanChildState: %w", err)
+	}
+	return nil
+}
+
+// listScanChildStates returns the state of every scanned child of parent,
+// or of all parents if parent is empty.
+func listScanChildStates(kdb *KeyDB, parent string) ([]ScanChildState, error) {
+	q, args := selectScanChildStateSql+` ORDER BY parent, child, scan_type`, []any{}
+	if parent != "" {
+		q, args = selectScanChildStateSql+` WHERE parent = ? ORDER BY child, scan_type`, []any{parent}
+	}
+	rows, err := kdb.DB.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listScanChildStates: %w", err)
+	}
+	defer rows.Close()
+	var out []ScanChildState
+	for rows.Next() {
+		st, err := scanScanChildState(rows)
+		if err != nil {
+			return nil, fmt.Errorf("listScanChildStates: %w", err)
+		}
+		out = append(out, *st)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ *
+ * Parental polling (RFC 7344 section 6.1, RFC 7477 section 3.1): besides
+ * acting on NOTIFY, the scanner periodically sweeps every child delegation
+ * known to a parent zone's DelegationBackend for CDS and CSYNC changes.
+ * The children of a parent are scanned one at a time, spread across the
+ * sweep interval and never faster than the configured rate. Each sweep is
+ * a scan job of kind "sweep" in the persistent job history.
+ */
+package tdns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/viper"
+)
+
+const defaultSweepRate = 10.0 // children per second per parent zone
+
+// SweepConf is the "scanner.sweep" config block. A zero Interval disables
+// sweeping.
+type SweepConf struct {
+	Interval time.Duration
+	Rate     float64    // max children scanned per second, per parent zone
+	Types    []ScanType // ScanCDS and/or ScanCSYNC
+	Zones    []string   // parent zones to sweep; empty: all with a delegation backend
+}
+
+func parseSweepConf() (SweepConf, error) {
+	c := SweepConf{
+		Interval: viper.GetDuration("scanner.sweep.interval"),
+		Rate:     viper.GetFloat64("scanner.sweep.rate"),
+	}
+	if c.Interval < 0 || (c.Interval > 0 && c.Interval < time.Minute) {
+		return c, fmt.Errorf("scanner.sweep.interval %v: must be at least 1m (or 0 to disable)", c.Interval)
+	}
+	if c.Rate < 0 {
+		return c, fmt.Errorf("scanner.sweep.rate must not be negative")
+	}
+	if c.Rate == 0 {
+		c.Rate = defaultSweepRate
+	}
+	types := viper.GetStringSlice("scanner.sweep.types")
+	if len(types) == 0 {
+		types = []string{"cds", "csync"}
+	}
+	for _, t := range types {
+		st, ok := StringToScanType[t]
+		if !ok || (st != ScanCDS && st != ScanCSYNC) {
+			return c, fmt.Errorf("scanner.sweep.types: %q is not cds or csync", t)
+		}
+		c.Types = append(c.Types, st)
+	}
+	for _, z := range viper.GetStringSlice("scanner.sweep.zones") {
+		c.Zones = append(c.Zones, dns.Fqdn(z))
+	}
+	return c, nil
+}
+
+// spacing is the pause between two children of a sweep over n children.
+func (c SweepConf) spacing(n int) time.Duration {
+	if n == 0 {
+		return 0
+	}
+	d := c.Interval / time.Duration(n)
+	if min := time.Duration(float64(time.Second) / c.Rate); d < min {
+		d = min
+	}
+	return d
+}
+
+// sweepZones returns the parent zones to sweep.
+func (scanner *Scanner) sweepZones() []*ZoneData {
+	var zds []*ZoneData
+	if len(scanner.Sweep.Zones) > 0 {
+		for _, name := range scanner.Sweep.Zones {
+			zd, ok := Zones.Get(name)
+			if !ok || zd.DelegationBackend == nil {
+				lg.Debug("scanner sweep: zone not loaded or without delegation backend, skipping", "zone", name)
+				continue
+			}
+			zds = append(zds, zd)
+		}
+		return zds
+	}
+	for item := range Zones.IterBuffered() {
+		if item.Val.DelegationBackend != nil {
+			zds = append(zds, item.Val)
+		}
+	}
+	return zds
+}
+
+// startDueSweeps starts a sweep of each parent zone whose previous sweep
+// started at least one interval ago and is not still running. Called from
+// the scanner's ticker. The start of the last sweep is read back from the job
+// history after a restart, so a restart does not trigger an extra sweep.
+func (scanner *Scanner) startDueSweeps(ctx context.Context) {
+	if scanner.Sweep.Interval == 0 {
+		return
+	}
+	now := time.Now()
+	for _, zd := range scanner.sweepZones() {
+		parent := zd.ZoneName
+		scanner.sweepMu.Lock()
+		if scanner.sweepRunning == nil {
+			scanner.sweepRunning = map[string]bool{}
+			scanner.sweepLast = map[string]time.Time{}
+		}
+		last, ok := scanner.sweepLast[parent]
+		if !ok && scanner.kdb != nil {
+			last, ok = lastSweepStart(scanner.kdb, parent)
+		}
+		if scanner.sweepRunning[parent] || (ok && now.Sub(last) < scanner.Sweep.Interval) {
+			scanner.sweepMu.Unlock()
+			continue
+		}
+		scanner.sweepRunning[parent] = true
+		scanner.sweepLast[parent] = now
+		scanner.sweepMu.Unlock()
+
+		go func(zd *ZoneData) {
+			defer func() {
+				scanner.sweepMu.Lock()
+				delete(scanner.sweepRunning, zd.ZoneName)
+				scanner.sweepMu.Unlock()
+			}()
+			scanner.sweepParent(ctx, zd)
+		}(zd)
+	}
+}
+
+// sweepParent scans every child of parentZD once and returns the job ID.
+// Only responses that report a change, a policy decision or an error are
+// kept in the job; every response updates the child's ScanChildState.
+func (scanner *Scanner) sweepParent(ctx context.Context, parentZD *ZoneData) string {
+	jobID, err := GenerateJobID()
+	if err != nil {
+		lg.Error("scanner sweep: failed to generate job ID", "error", err)
+		return ""
+	}
+	now := time.Now()
+	job := &ScanJobStatus{
+		JobID:     jobID,
+		Kind:      "sweep",
+		Parent:    parentZD.ZoneName,
+		Status:    "processing",
+		CreatedAt: now,
+		StartedAt: &now,
+	}
+	finish := func(status, errMsg string) {
+		scanner.updateJob(jobID, func(job *ScanJobStatus) {
+			job.Status = status
+			if errMsg != "" {
+				job.Error, job.ErrorMsg = true, errMsg
+			}
+			completedAt := time.Now()
+			job.CompletedAt = &completedAt
+		})
+		scanner.pruneJobs()
+	}
+
+	children, err := parentZD.DelegationBackend.ListChildren(parentZD.ZoneName)
+	job.TotalTuples = len(children) * len(scanner.Sweep.Types)
+	scanner.addJob(job)
+	if err != nil {
+		lg.Error("scanner sweep: listing children failed", "parent", parentZD.ZoneName, "error", err)
+		finish("failed", fmt.Sprintf("listing children: %v", err))
+		return jobID
+	}
+
+	scan := scanner.sweepScan
+	if scan == nil {
+		scan = scanner.scanChild
+	}
+	spacing := scanner.Sweep.spacing(len(children))
+	lg.Info("scanner sweep: starting", "parent", parentZD.ZoneName, "children", len(children), "spacing", spacing, "jobID", jobID)
+
+	for i, child := range children {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				finish("failed", "cancelled")
+				return jobID
+			case <-time.After(spacing):
+			}
+		}
+		for _, st := range scanner.Sweep.Types {
+			resp := scan(ctx, parentZD, child, st)
+			scanner.actOnResponse(parentZD, resp)
+			scanner.updateJob(jobID, func(job *ScanJobStatus) {
+				job.ProcessedTuples++
+				if resp.Error {
+					job.ErrorTuples++
+				}
+				if resp.Error || resp.DataChanged || resp.Decision != "" {
+					job.Responses = append(job.Responses, resp)
+				}
+			})
+		}
+	}
+	finish("completed", "")
+	lg.Info("scanner sweep: completed", "parent", parentZD.ZoneName, "children", len(children), "jobID", jobID)
+	return jobID
+}
+
+// scanChild runs one scan of child as if it had sent a NOTIFY of the
+// corresponding type.
+func (scanner *Scanner) scanChild(ctx context.Context, parentZD *ZoneData, child string, scanType ScanType) ScanTupleResponse {
+	tuple := ScanTuple{Zone: child}
+	ch := make(chan ScanTupleResponse, 1)
+	switch scanType {
+	case ScanCDS:
+		tuple.CurrentData.DS = currentDelegationDS(parentZD, child)
+		scanner.ProcessCDSNotify(ctx, tuple, parentZD, scanType, nil, ch)
+	case ScanCSYNC:
+		scanner.ProcessCSYNCNotify(ctx, tuple, parentZD, scanType, nil, ch)
+	default:
+		return ScanTupleResponse{Qname: child, ScanType: scanType, Error: true, ErrorMsg: "scan type not supported in a sweep"}
+	}
+	return <-ch
+}
@@ -0,0 +1,149 @@
+package tdns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func newTestSweepScanner(t *testing.T) (*Scanner, *KeyDB) {
+	t.Helper()
+	kdb := newTestKeyDB(t)
+	s := NewScanner(nil, false, false)
+	s.kdb = kdb
+	return s, kdb
+}
+
+func TestSweepSpacing(t *testing.T) {
+	c := SweepConf{Interval: time.Hour, Rate: 10}
+	if got := c.spacing(60); got != time.Minute {
+		t.Errorf("spacing(60) = %v, want 1m", got)
+	}
+	// Never faster than the rate, even if the sweep overruns the interval.
+	if got := c.spacing(100000); got != 100*time.Millisecond {
+		t.Errorf("spacing(100000) = %v, want 100ms", got)
+	}
+	if got := c.spacing(0); got != 0 {
+		t.Errorf("spacing(0) = %v", got)
+	}
+}
+
+func TestScanChildStateTimeToAccept(t *testing.T) {
+	kdb := newTestKeyDB(t)
+	t0 := time.Now().Truncate(time.Second)
+	resp := ScanTupleResponse{Qname: "child.example.", ScanType: ScanCDS, Decision: CdsVerdictDeferred}
+
+	for i, step := range []struct {
+		at       time.Duration
+		resp     ScanTupleResponse
+		accepted bool
+		state    string
+	}{
+		{0, resp, false, "pending"},
+		{time.Hour, resp, false, "pending"},
+		{2 * time.Hour, ScanTupleResponse{Qname: "child.example.", ScanType: ScanCDS, Error: true, ErrorMsg: "timeout"}, false, "error"},
+		{3 * time.Hour, ScanTupleResponse{Qname: "child.example.", ScanType: ScanCDS, DataChanged: true, Decision: CdsVerdictAccepted}, true, "accepted"},
+	} {
+		if err := recordScanChildResult(kdb, "example.", step.resp, step.accepted, t0.Add(step.at)); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		st, err := loadScanChildState(kdb, "example.", "child.example.", "cds")
+		if err != nil || st == nil || st.State != step.state {
+			t.Fatalf("step %d: state %+v, %v; want %s", i, st, err, step.state)
+		}
+	}
+	st, _ := loadScanChildState(kdb, "example.", "child.example.", "cds")
+	if st.AcceptDelay != 3*time.Hour || st.PendingSince != nil || st.LastAccepted == nil {
+		t.Errorf("after acceptance: %+v", st)
+	}
+
+	if err := recordScanChildResult(kdb, "example.", ScanTupleResponse{Qname: "child.example.", ScanType: ScanCDS}, false, t0.Add(4*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	states, err := listScanChildStates(kdb, "example.")
+	if err != nil || len(states) != 1 || states[0].State != "in-sync" || states[0].AcceptDelay != 3*time.Hour {
+		t.Errorf("list: %+v %v", states, err)
+	}
+}
+
+func TestScanJobHistoryPersists(t *testing.T) {
+	s, kdb := newTestSweepScanner(t)
+	now := time.Now()
+	s.addJob(&ScanJobStatus{JobID: "old", Kind: "request", Status: "processing", CreatedAt: now.Add(-time.Hour)})
+	s.updateJob("old", func(j *ScanJobStatus) { j.Status = "completed"; j.ProcessedTuples = 1 })
+	s.addJob(&ScanJobStatus{JobID: "running", Kind: "sweep", Parent: "example.", Status: "processing", CreatedAt: now})
+
+	// A new process sees both; the one that was running is marked failed.
+	s2 := NewScanner(nil, false, false)
+	s2.kdb = kdb
+	if err := s2.loadJobHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if j := s2.Jobs["old"]; j == nil || j.Status != "completed" || j.ProcessedTuples != 1 {
+		t.Errorf("old job = %+v", j)
+	}
+	if j := s2.Jobs["running"]; j == nil || j.Status != "failed" || j.Parent != "example." {
+		t.Errorf("interrupted job = %+v", j)
+	}
+	if last, ok := lastSweepStart(kdb, "example."); !ok || !last.Equal(now) {
+		t.Errorf("lastSweepStart = %v, %v; want %v", last, ok, now)
+	}
+
+	s2.HistoryJobs = 1
+	s2.pruneJobs()
+	if jobs, _ := loadScanJobs(kdb, 10); len(jobs) != 1 || jobs[0].JobID != "running" || len(s2.Jobs) != 1 {
+		t.Errorf("after prune: %d in keystore, %d in memory", len(jobs), len(s2.Jobs))
+	}
+}
+
+func TestSweepParent(t *testing.T) {
+	s, kdb := newTestSweepScanner(t)
+	backend := &DBDelegationBackend{kdb: kdb}
+	if err := backend.ApplyChildUpdate("example.", UpdateRequest{Actions: eppTestRRs(t, dns.ClassINET,
+		"a.example. 3600 IN NS ns.other.net.",
+		"b.example. 3600 IN NS ns.other.net.",
+	)}); err != nil {
+		t.Fatal(err)
+	}
+	parent := &ZoneData{ZoneName: "example.", DelegationBackend: backend}
+	s.Sweep = SweepConf{Interval: 10 * time.Millisecond, Rate: 1000, Types: []ScanType{ScanCDS, ScanCSYNC}}
+
+	var scanned []string
+	s.sweepScan = func(ctx context.Context, zd *ZoneData, child string, st ScanType) ScanTupleResponse {
+		scanned = append(scanned, child+"/"+ScanTypeToString[st])
+		resp := ScanTupleResponse{Qname: child, ScanType: st}
+		if child == "a.example." && st == ScanCDS {
+			resp.DataChanged = true
+			resp.DSAdds = eppTestRRs(t, dns.ClassINET, "a.example. 3600 IN DS 12345 13 2 0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF")
+		}
+		return resp
+	}
+	var changed []string
+	s.OnDelegationChange = func(parentZone string, zd *ZoneData, resp ScanTupleResponse) {
+		changed = append(changed, resp.Qname)
+	}
+
+	jobID := s.sweepParent(context.Background(), parent)
+	if len(scanned) != 4 || len(changed) != 1 || changed[0] != "a.example." {
+		t.Fatalf("scanned %v, changed %v", scanned, changed)
+	}
+	job := s.Jobs[jobID]
+	if job.Kind != "sweep" || job.Status != "completed" || job.TotalTuples != 4 || job.ProcessedTuples != 4 || len(job.Responses) != 1 {
+		t.Errorf("job = %+v", job)
+	}
+	states, err := listScanChildStates(kdb, "example.")
+	if err != nil || len(states) != 4 {
+		t.Fatalf("child states: %+v %v", states, err)
+	}
+	for _, st := range states {
+		want := "in-sync"
+		if st.Child == "a.example." && st.ScanType == "cds" {
+			want = "accepted"
+		}
+		if st.State != want {
+			t.Errorf("%s/%s: state %s, want %s", st.Child, st.ScanType, st.State, want)
+		}
+	}
+}
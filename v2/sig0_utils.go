@@ -303,7 +303,7 @@ INSERT OR REPLACE INTO DnssecKeyStore (zonename, state, keyid, algorithm, flags,
 		_, err = tx.Exec(addDnssecKeySql, owner, state, pkc.KeyId,
 			dns.AlgorithmToString[pkc.Algorithm], flags, creator, pkc.PrivateKey, pkc.DnskeyRR.String())
 		if err == nil && state == DnskeyStateActive {
-			now := time.Now().UTC().Format(time.RFC3339)
+			now := keyStateNow().UTC().Format(time.RFC3339)
 			_, err = tx.Exec(`UPDATE DnssecKeyStore SET active_at=? WHERE zonename=? AND keyid=?`,
 				now, owner, pkc.KeyId)
 		}
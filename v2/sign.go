@@ -8,6 +8,7 @@ import (
 	"crypto/rand"
 	"fmt"
 	"math/big"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -32,14 +33,24 @@ func cryptoRandIntn(n int) int {
 	return int(val.Int64())
 }
 
-func sigLifetime(t time.Time, lifetime uint32) (uint32, uint32) {
+// sigLifetime returns the inception and expiration for a signature made at
+// t. The inception is backdated 60s plus up to a minute of jitter to allow
+// for clock skew. The expiration is lifetime (5 minutes if zero) plus a
+// random spread of up to spread seconds (at least 60), so that signatures
+// made in one pass do not all expire, and fall due for re-signing, at the
+// same moment.
+func sigLifetime(t time.Time, lifetime, spread uint32) (uint32, uint32) {
 	sigJitter := time.Duration(time.Duration(cryptoRandIntn(61)) * time.Second)
 	sigValidity := time.Duration(lifetime) * time.Second
 	if lifetime == 0 {
 		sigValidity = time.Duration(5 * time.Minute)
 	}
+	if spread < 60 {
+		spread = 60
+	}
+	expJitter := time.Duration(cryptoRandIntn(int(spread)+1)) * time.Second
 	incep := uint32(t.Add(-sigJitter).Add(-60 * time.Second).Unix()) // inception == now -60s -jitter to allow for 60s clock skew
-	expir := uint32(t.Add(sigValidity).Add(sigJitter).Unix())
+	expir := uint32(t.Add(sigValidity).Add(expJitter).Unix())
 	return incep, expir
 }
 
@@ -65,7 +76,7 @@ func SignMsg(m dns.Msg, signer string, sak *Sig0ActiveKeys) (*dns.Msg, error) {
 		}
 		sigrr.RRSIG.KeyTag = key.KeyRR.DNSKEY.KeyTag()
 		sigrr.RRSIG.Algorithm = key.KeyRR.DNSKEY.Algorithm
-		sigrr.RRSIG.Inception, sigrr.RRSIG.Expiration = sigLifetime(time.Now().UTC(), 60*5) // 5 minutes
+		sigrr.RRSIG.Inception, sigrr.RRSIG.Expiration = sigLifetime(time.Now().UTC(), 60*5, 60) // 5 minutes
 		sigrr.RRSIG.SignerName = signer
 
 		signedBuf, err := sigrr.Sign(key.CS, &m)
@@ -112,7 +123,24 @@ func sigValiditySeconds(pol *DnssecPolicy, rrtype uint16) uint32 {
 	}
 }
 
+// sigJitterSeconds returns the policy's signature expiry spread
+// (sigvalidity.jitter). Without a policy, sigLifetime's one-minute minimum
+// applies.
+func sigJitterSeconds(pol *DnssecPolicy) uint32 {
+	if pol == nil {
+		return 0
+	}
+	return pol.SigValidity.Jitter
+}
+
 func (zd *ZoneData) SignRRset(rrset *core.RRset, name string, dak *DnssecKeys, force bool, clamp *ClampParams) (bool, error) {
+	return zd.signRRset(rrset, name, dak, force, clamp, nil)
+}
+
+// signRRset is SignRRset. With a ladder batch (a merkle-ladder sign pass)
+// the ZSK signatures it makes are left for the batch to complete with an
+// inclusion proof; see sign_ladder.go.
+func (zd *ZoneData) signRRset(rrset *core.RRset, name string, dak *DnssecKeys, force bool, clamp *ClampParams, lb *ladderBatch) (bool, error) {
 
 	if !zd.Options[OptOnlineSigning] && !zd.Options[OptInlineSigning] {
 		return false, fmt.Errorf("SignRRset: zone %s does not allow signing (neither online-signing nor inline-signing)", zd.ZoneName)
@@ -131,13 +159,18 @@ func (zd *ZoneData) SignRRset(rrset *core.RRset, name string, dak *DnssecKeys, f
 		}
 	}
 
-	if dak == nil || len(dak.KSKs) == 0 || len(dak.ZSKs) == 0 {
+	// An offline-KSK zone has no KSKs here: its DNSKEY RRset is signed from
+	// the imported SKR (ksr.go).
+	if dak == nil || (len(dak.KSKs) == 0 && !zd.offlineKSK()) || len(dak.ZSKs) == 0 {
 		return false, fmt.Errorf("SignRRset: no active DNSSEC keys available")
 	}
 
 	if len(rrset.RRs) == 0 {
 		return false, fmt.Errorf("SignRRsetNG: rrset has no RRs")
 	}
+	if zd.skrSigned(name, rrset.RRs[0].Header().Rrtype) {
+		return false, nil
+	}
 
 	// Snapshot TTLs and the RRSIGs slice before any in-place mutation,
 	// so we can roll back on error. Without this, an error path (clamp
@@ -211,13 +244,21 @@ func (zd *ZoneData) SignRRset(rrset *core.RRset, name string, dak *DnssecKeys, f
 			rrsig.KeyTag = key.DnskeyRR.KeyTag()
 			rrsig.Algorithm = key.DnskeyRR.Algorithm
 			lifetime := sigValiditySeconds(zd.DnssecPolicy, rrset.RRs[0].Header().Rrtype)
-			rrsig.Inception, rrsig.Expiration = sigLifetime(now, lifetime)
 			rrsig.SignerName = zd.ZoneName // name
 
-			err := rrsig.Sign(key.CS, rrset.RRs)
-			if err != nil {
-				lgSigner.Error("rrsig.Sign failed", "name", name, "err", err)
-				return false, err
+			if lb.covers(rrset.RRs[0].Header().Rrtype) {
+				rrsig.Inception, rrsig.Expiration = lb.lifetime(lifetime)
+				if err := lb.add(key, rrsig, rrset.RRs); err != nil {
+					lgSigner.Error("ladder leaf failed", "name", name, "err", err)
+					return false, err
+				}
+			} else {
+				rrsig.Inception, rrsig.Expiration = sigLifetime(now, lifetime, sigJitterSeconds(zd.DnssecPolicy))
+				err := rrsig.Sign(key.CS, rrset.RRs)
+				if err != nil {
+					lgSigner.Error("rrsig.Sign failed", "name", name, "err", err)
+					return false, err
+				}
 			}
 
 			// 4D clamp invariant: warn if validity would expire before the
@@ -233,13 +274,17 @@ func (zd *ZoneData) SignRRset(rrset *core.RRset, name string, dak *DnssecKeys, f
 	return resigned, nil
 }
 
-// XXX: Perhaps a working algorithm woul be to test for the remaining signature lifetime to be something like
-//
-//	less than 3 x resigning interval?
-func NeedsResigning(rrsig *dns.RRSIG, servedTTL uint32) bool {
-	expirationTime := time.Unix(int64(rrsig.Expiration), 0)
-	remaining := time.Until(expirationTime)
+// resignThreshold is how long before expiry an RRSIG over an RRset served
+// with servedTTL must be replaced: the TTL (caches may hold the old
+// signature that long), the KASP propagation delay and one resigner scan
+// interval (the signature must not expire between two scans).
+func resignThreshold(servedTTL uint32) time.Duration {
+	return time.Duration(servedTTL)*time.Second + Conf.KaspPropagationDelay() + resignScanInterval()
+}
 
+// resignScanInterval returns resignerengine.interval clamped to [60s, 1h],
+// the range ResignerEngine ticks at.
+func resignScanInterval() time.Duration {
 	// resignerengine.interval comes from the immutable RuntimeConfig snapshot
 	// (ConfLive), not the non-thread-safe global viper — this runs in the signing
 	// hot path concurrent with config reload. A zero value clamps to the 60s
@@ -251,8 +296,17 @@ func NeedsResigning(rrsig *dns.RRSIG, servedTTL uint32) bool {
 	if scanInterval > 3600*time.Second {
 		scanInterval = 3600 * time.Second
 	}
+	return scanInterval
+}
 
-	threshold := time.Duration(servedTTL)*time.Second + Conf.KaspPropagationDelay() + scanInterval
+// XXX: Perhaps a working algorithm woul be to test for the remaining signature lifetime to be something like
+//
+//	less than 3 x resigning interval?
+func NeedsResigning(rrsig *dns.RRSIG, servedTTL uint32) bool {
+	expirationTime := time.Unix(int64(rrsig.Expiration), 0)
+	remaining := time.Until(expirationTime)
+
+	threshold := resignThreshold(servedTTL)
 	if remaining < threshold {
 		lgSigner.Info("RRSIG needs resigning, remaining validity below served TTL headroom",
 			"name", rrsig.Header().Name,
@@ -286,10 +340,11 @@ func (zd *ZoneData) refreshActiveDnssecKeys(kdb *KeyDB, context string) (*Dnssec
 //   - ZSK mismatch, STRICT mode: REFUSE. Strict-mode algorithm rollover
 //     (maintained whole-zone double-signature) is not implemented; running the
 //     legacy synchronous retire would produce an unsafe zone.
-//   - KSK mismatch, EITHER mode: REFUSE. A KSK algorithm rollover must go
-//     through the parent-coordinated engine (standby DS gate); the legacy
-//     immediate retire here bypasses the gate and bogus-zones the parent DS
-//     chain. Not yet built — refuse rather than run the unsafe swap.
+//   - KSK mismatch, EITHER mode: no-op when the policy lets the auto-rollover
+//     engine carry the roll (ksk_alg_rollover.go: multi-ds with a parent
+//     agent, online KSK) — the engine double-signs and coordinates the parent
+//     DS. Otherwise REFUSE: the legacy immediate retire here bypasses the DS
+//     gate and bogus-zones the parent DS chain.
 //
 // CSK mode (Mode==csk) early-returns: it never reaches the key loops, so a CSK
 // algorithm change is refused at the ENTRY layer (change-policy/set-policy), not
@@ -313,14 +368,21 @@ func (zd *ZoneData) reconcileActiveKeyAlgorithms(kdb *KeyDB, dak *DnssecKeys) (b
 		rolloverInProgress = row.RolloverInProgress
 	}
 
-	// KSK algorithm mismatch is REFUSED in both modes — a KSK alg rollover is
-	// parent-coordinated engine work (not yet built), and the legacy immediate
-	// retire below would bypass the standby DS gate and bogus the parent chain.
+	// KSK algorithm mismatch is the auto-rollover engine's to carry (it keeps
+	// the old KSK active and signing until its DS has left the parent). A
+	// policy the engine cannot roll under is REFUSED in both modes — the legacy
+	// immediate retire would bypass the DS gate and bogus the parent chain.
 	for _, ksk := range dak.KSKs {
-		if ksk.DnskeyRR.Algorithm != zd.DnssecPolicy.KSKAlgorithm {
-			return false, fmt.Errorf("KSK algorithm rollover not implemented for zone %s (active KSK %d is %s, policy wants %s); route via the auto-rollover engine — not yet built",
-				zd.ZoneName, ksk.KeyId, dns.AlgorithmToString[ksk.DnskeyRR.Algorithm], dns.AlgorithmToString[zd.DnssecPolicy.KSKAlgorithm])
+		if ksk.DnskeyRR.Algorithm == zd.DnssecPolicy.KSKAlgorithm {
+			continue
 		}
+		if why := kskAlgRollUnsupported(zd.DnssecPolicy); why != "" {
+			return false, fmt.Errorf("KSK algorithm rollover not possible for zone %s (active KSK %d is %s, policy wants %s): %s",
+				zd.ZoneName, ksk.KeyId, dns.AlgorithmToString[ksk.DnskeyRR.Algorithm], dns.AlgorithmToString[zd.DnssecPolicy.KSKAlgorithm], why)
+		}
+		lgSigner.Debug("active KSK algorithm differs from policy; leaving it to the auto-rollover engine",
+			"zone", zd.ZoneName, "keyid", ksk.KeyId,
+			"have", dns.AlgorithmToString[ksk.DnskeyRR.Algorithm], "want", dns.AlgorithmToString[zd.DnssecPolicy.KSKAlgorithm])
 	}
 
 	// ZSK algorithm mismatch: refuse in strict mode; no-op in relaxed mode (the
@@ -415,10 +477,10 @@ func (zd *ZoneData) EnsureActiveDnssecKeys(kdb *KeyDB, zdLocked bool) (*DnssecKe
 	}
 
 	// Reconcile the active key algorithms against the policy. An active-key
-	// algorithm mismatch is REFUSED with an error (a KSK mismatch in either
-	// mode, a ZSK mismatch under strict completeness) — never the legacy
+	// algorithm mismatch is REFUSED with an error (a KSK mismatch the engine
+	// cannot roll, a ZSK mismatch under strict completeness) — never the legacy
 	// synchronous retire, which is the unsafe path for an algorithm change. A
-	// relaxed-mode ZSK mismatch is a no-op (the gradual roll carries it). The
+	// relaxed-mode ZSK mismatch and an engine-carried KSK mismatch are no-ops. The
 	// boolean return reports only whether non-active leftover keys
 	// (standby/published of a wrong algorithm) were removed, in which case we
 	// re-fetch the active set. On a same-algorithm zone this is an idempotent
@@ -432,8 +494,9 @@ func (zd *ZoneData) EnsureActiveDnssecKeys(kdb *KeyDB, zdLocked bool) (*DnssecKe
 		}
 	}
 
-	// If we already have active keys (including a real ZSK, not just KSK reused as CSK), return them
-	if len(dak.KSKs) > 0 && len(dak.ZSKs) > 0 {
+	// If we already have active keys (including a real ZSK, not just KSK reused as CSK), return them.
+	// An offline-KSK zone has no KSKs in this keystore and needs only the ZSK.
+	if (len(dak.KSKs) > 0 || zd.offlineKSK()) && len(dak.ZSKs) > 0 {
 		// Check if we have a real ZSK (flags=256) or just KSK reused as CSK (flags=257)
 		hasRealZSK := false
 		for _, zsk := range dak.ZSKs {
@@ -471,7 +534,7 @@ func (zd *ZoneData) EnsureActiveDnssecKeys(kdb *KeyDB, zdLocked bool) (*DnssecKe
 		var promotedKskKeyId uint16
 
 		// Promote the first KSK from published to active
-		if len(dpk.KSKs) > 0 {
+		if len(dpk.KSKs) > 0 && !zd.offlineKSK() {
 			promotedKskKeyId = dpk.KSKs[0].KeyId
 			err = kdb.PromoteDnssecKey(zd.ZoneName, promotedKskKeyId, DnskeyStatePublished, DnskeyStateActive)
 			if err != nil {
@@ -520,8 +583,8 @@ func (zd *ZoneData) EnsureActiveDnssecKeys(kdb *KeyDB, zdLocked bool) (*DnssecKe
 		return nil, fmt.Errorf("EnsureActiveDnssecKeys: zone %s has no DNSSEC policy bound yet; cannot generate active keys", zd.ZoneName)
 	}
 
-	// Generate KSK if still missing
-	if len(dak.KSKs) == 0 {
+	// Generate KSK if still missing (never for an offline KSK)
+	if len(dak.KSKs) == 0 && !zd.offlineKSK() {
 		pkc, msg, err := kdb.GenerateKeypair(zd.ZoneName, "ensure-active-keys", DnskeyStateActive, dns.TypeDNSKEY, zd.DnssecPolicy.KSKAlgorithm, "KSK", nil)
 		if err != nil {
 			return nil, fmt.Errorf("EnsureActiveDnssecKeys: failed to generate KSK for zone %s: %v", zd.ZoneName, err)
@@ -563,7 +626,7 @@ func (zd *ZoneData) EnsureActiveDnssecKeys(kdb *KeyDB, zdLocked bool) (*DnssecKe
 		}
 	}
 
-	if len(dak.KSKs) == 0 {
+	if len(dak.KSKs) == 0 && !zd.offlineKSK() {
 		return nil, fmt.Errorf("EnsureActiveDnssecKeys: failed to generate active KSK for zone %s", zd.ZoneName)
 	}
 
@@ -667,6 +730,7 @@ func (zd *ZoneData) ResignZone(kdb *KeyDB) (int, error) {
 	}
 
 	newrrsigs := 0
+	var jobs []signJob
 	for _, name := range names {
 		owner := zd.stagedOwner(name)
 		if owner == nil {
@@ -679,6 +743,9 @@ func (zd *ZoneData) ResignZone(kdb *KeyDB) (int, error) {
 			if rrt == dns.TypeNS && name != zd.ZoneName {
 				continue // delegation NS — not signed
 			}
+			if zd.skrSigned(name, rrt) {
+				continue // pre-signed by the offline KSK
+			}
 			if rrt == dns.TypeA || rrt == dns.TypeAAAA {
 				var isglue bool
 				for _, del := range delegations {
@@ -696,18 +763,23 @@ func (zd *ZoneData) ResignZone(kdb *KeyDB) (int, error) {
 			// until we Set the new one back in a single atomic store, so
 			// readers never observe an unsigned intermediate state.
 			rrset := owner.RRtypes.GetOnlyRRSet(rrt)
+			rrset.RRtype = rrt
 			rrset.RRSIGs = nil
-			resigned, err := zd.SignRRset(&rrset, zd.ZoneName, dak, true, clamp)
-			if err != nil {
-				lgSigner.Error("ResignZone: SignRRset failed",
-					"zone", zd.ZoneName, "name", name,
-					"rrtype", dns.TypeToString[rrt], "err", err)
-				return newrrsigs, err
-			}
-			zd.stageRRsetLocked(name, rrset)
-			if resigned {
-				newrrsigs++
-			}
+			jobs = append(jobs, signJob{name: name, rrset: rrset})
+		}
+	}
+
+	jobs = zd.signPassLocked(jobs, dak, true, clamp)
+	for i := range jobs {
+		if err := jobs[i].err; err != nil {
+			lgSigner.Error("ResignZone: SignRRset failed",
+				"zone", zd.ZoneName, "name", jobs[i].name,
+				"rrtype", dns.TypeToString[jobs[i].rrset.RRtype], "err", err)
+			return newrrsigs, err
+		}
+		zd.stageRRsetLocked(jobs[i].name, jobs[i].rrset)
+		if jobs[i].resigned {
+			newrrsigs++
 		}
 	}
 
@@ -824,17 +896,6 @@ func (zd *ZoneData) SignZone(kdb *KeyDB, force bool) (int, error) {
 		}
 	}
 
-	MaybeSignRRset := func(rrset core.RRset, zone string) (core.RRset, bool) {
-		resigned, err := zd.SignRRset(&rrset, zone, dak, force, clamp)
-		if err != nil {
-			lgSigner.Error("failed to sign RRset", "name", rrset.RRs[0].Header().Name, "rrtype", dns.TypeToString[uint16(rrset.RRs[0].Header().Rrtype)], "zone", zd.ZoneName)
-		}
-		if resigned {
-			newrrsigs++
-		}
-		return rrset, resigned
-	}
-
 	zd.mu.Lock()
 	defer zd.mu.Unlock()
 	zd.ensureWorkingSet()
@@ -867,7 +928,7 @@ func (zd *ZoneData) SignZone(kdb *KeyDB, force bool) (int, error) {
 
 	lgSigner.Debug("zone delegations", "zone", zd.ZoneName, "delegations", delegations)
 
-	var maxObservedTTL uint32
+	var jobs []signJob
 	for _, name := range names {
 		// log.Printf("SignZone: signing RRsets under name %s", name)
 		owner := zd.stagedOwner(name)
@@ -883,6 +944,9 @@ func (zd *ZoneData) SignZone(kdb *KeyDB, force bool) (int, error) {
 			if rrt == dns.TypeNS && name != zd.ZoneName {
 				continue // dont' sign delegations
 			}
+			if zd.skrSigned(name, rrt) {
+				continue // pre-signed by the offline KSK
+			}
 			// XXX: What is the best way to identify that an RR is a glue record?
 			var wasglue bool
 			if rrt == dns.TypeA || rrt == dns.TypeAAAA {
@@ -898,23 +962,39 @@ func (zd *ZoneData) SignZone(kdb *KeyDB, force bool) (int, error) {
 			if wasglue {
 				continue
 			}
-			rrset, _ = MaybeSignRRset(rrset, zd.ZoneName)
-			zd.stageRRsetLocked(name, rrset)
-
-			// Record TTL after clamping. applyClampToRRset (called from
-			// SignRRset) rewrites headers to min(UnclampedTTL, K*margin,
-			// MaxServedTTL); capturing here makes max_observed_ttl reflect
-			// what's actually served, so effective_margin converges on the
-			// first sign pass after a policy change instead of the second.
-			if len(rrset.RRs) > 0 {
-				if t := rrset.RRs[0].Header().Ttl; t > maxObservedTTL {
-					maxObservedTTL = t
-				}
+			rrset.RRtype = rrt
+			jobs = append(jobs, signJob{name: name, rrset: rrset})
+		}
+	}
+
+	// Sign on the worker pool, then stage in walk order.
+	jobs = zd.signPassLocked(jobs, dak, force, clamp)
+
+	var maxObservedTTL uint32
+	for i := range jobs {
+		rrset := jobs[i].rrset
+		if jobs[i].err != nil {
+			lgSigner.Error("failed to sign RRset", "name", rrset.RRs[0].Header().Name, "rrtype", dns.TypeToString[uint16(rrset.RRs[0].Header().Rrtype)], "zone", zd.ZoneName)
+		}
+		if jobs[i].resigned {
+			newrrsigs++
+		}
+		zd.stageRRsetLocked(jobs[i].name, rrset)
+
+		// Record TTL after clamping. applyClampToRRset (called from
+		// SignRRset) rewrites headers to min(UnclampedTTL, K*margin,
+		// MaxServedTTL); capturing here makes max_observed_ttl reflect
+		// what's actually served, so effective_margin converges on the
+		// first sign pass after a policy change instead of the second.
+		if len(rrset.RRs) > 0 {
+			if t := rrset.RRs[0].Header().Ttl; t > maxObservedTTL {
+				maxObservedTTL = t
 			}
 		}
 	}
 
 	zd.publishLocked(zd.generation.Load())
+	zd.scheduleResignsLocked(jobs, dak)
 
 	if err := UpsertZoneSigningMaxTTL(kdb, zd.ZoneName, maxObservedTTL); err != nil {
 		lgSigner.Warn("SignZone: persist max_observed_ttl", "zone", zd.ZoneName, "err", err)
@@ -997,6 +1077,10 @@ func (zd *ZoneData) GenerateNsecChainWithDak(dak *DnssecKeys) error {
 		if hasRRSIG || ((zd.Options[OptOnlineSigning] || zd.Options[OptInlineSigning]) && len(dak.KSKs) > 0) {
 			tmap = append(tmap, int(dns.TypeRRSIG))
 		}
+		// The sign pass that follows adds the apex LADDER RRset (sign_ladder.go).
+		if name == zd.ZoneName && zd.ladderSigning() && !slices.Contains(tmap, int(core.TypeLADDER)) {
+			tmap = append(tmap, int(core.TypeLADDER))
+		}
 
 		// log.Printf("GenerateNsecChain: name: %s tmap: %v", name, tmap)
 
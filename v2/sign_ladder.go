@@ -0,0 +1,340 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"bytes"
+	"encoding/base64"
+	"sort"
+	"sync"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// Merkle tree signature ladders (signing.mode: merkle-ladder; experimental).
+//
+// In a ladder sign pass (SignZone, ResignZone, ResignDue) every ZSK
+// signature the pass makes becomes a leaf instead of being signed: the
+// RRSIG gets all its fields except Signature and the leaf hash is
+// collected in a ladderBatch. When all jobs are signed, the leaves of each
+// ZSK are built into one tree (core/merkle_ladder.go), the tree root is
+// published as a LADDER rung at the apex, the LADDER RRset is signed with
+// an ordinary signature and each leaf RRSIG gets its inclusion proof. The
+// large signature is thus made once per key and pass instead of once per
+// RRset.
+//
+// The leaves of one pass share inception and expiration jitter, so a tree
+// falls due for re-signing as a whole and ResignDue re-ladders it in one
+// batch. A rung carries the latest expiration of its leaves and is pruned
+// once that has passed: by then every RRSIG that points at it has expired
+// (or been replaced, since they are re-signed well before expiry).
+//
+// DNSKEY and LADDER itself are always signed normally, as are RRsets signed
+// outside a pass (dynamic updates, the SOA after an update) and batches of
+// fewer than signing.ladder-min-leaves leaves per key.
+
+// ladderBatch collects the leaves of one ladder sign pass. add is called
+// from the signing workers.
+type ladderBatch struct {
+	now    time.Time
+	incep  uint32
+	jitter uint32
+
+	mu     sync.Mutex
+	leaves []*ladderLeaf
+}
+
+// ladderLeaf is one RRSIG awaiting its inclusion proof.
+type ladderLeaf struct {
+	key  *PrivateKeyCache
+	sig  *dns.RRSIG
+	rrs  []dns.RR
+	hash []byte
+}
+
+// ladderTree is one built tree: the leaves of one key in a pass.
+type ladderTree struct {
+	key        *PrivateKeyCache
+	tree       *core.LadderTree
+	leaves     []*ladderLeaf
+	expiration uint32
+}
+
+func newLadderBatch(pol *DnssecPolicy, now time.Time) *ladderBatch {
+	spread := sigJitterSeconds(pol)
+	if spread < 60 {
+		spread = 60
+	}
+	return &ladderBatch{
+		now:    now,
+		incep:  uint32(now.Add(-60*time.Second - time.Duration(cryptoRandIntn(61))*time.Second).Unix()),
+		jitter: uint32(cryptoRandIntn(int(spread) + 1)),
+	}
+}
+
+// covers reports whether signatures over rrtype become leaves.
+func (lb *ladderBatch) covers(rrtype uint16) bool {
+	return lb != nil && rrtype != dns.TypeDNSKEY && rrtype != core.TypeLADDER
+}
+
+// lifetime is sigLifetime with the pass's shared inception and jitter.
+func (lb *ladderBatch) lifetime(lifetime uint32) (uint32, uint32) {
+	validity := time.Duration(lifetime) * time.Second
+	if lifetime == 0 {
+		validity = 5 * time.Minute
+	}
+	return lb.incep, uint32(lb.now.Add(validity).Unix()) + lb.jitter
+}
+
+// add completes rrsig's header fields as RRSIG.Sign would and records it
+// as a leaf.
+func (lb *ladderBatch) add(key *PrivateKeyCache, rrsig *dns.RRSIG, rrs []dns.RR) error {
+	h0 := rrs[0].Header()
+	if rrsig.OrigTtl == 0 {
+		rrsig.OrigTtl = h0.Ttl
+	}
+	rrsig.TypeCovered = h0.Rrtype
+	rrsig.Labels = uint8(dns.CountLabel(h0.Name))
+	if len(h0.Name) > 0 && h0.Name[0] == '*' {
+		rrsig.Labels--
+	}
+	hash, err := core.LadderLeafHash(rrsig, rrs)
+	if err != nil {
+		return err
+	}
+	lb.mu.Lock()
+	lb.leaves = append(lb.leaves, &ladderLeaf{key: key, sig: rrsig, rrs: rrs, hash: hash})
+	lb.mu.Unlock()
+	return nil
+}
+
+// ladderSigning reports whether the zone's sign passes build ladders.
+func (zd *ZoneData) ladderSigning() bool {
+	return zd.DnssecPolicy != nil && zd.DnssecPolicy.Signing.Mode == SigningModeMerkleLadder
+}
+
+// ladderMinLeaves returns signing.ladder-min-leaves.
+func (zd *ZoneData) ladderMinLeaves() int {
+	if zd.DnssecPolicy == nil || zd.DnssecPolicy.Signing.LadderMinLeaves <= 0 {
+		return defaultLadderMinLeaves
+	}
+	return zd.DnssecPolicy.Signing.LadderMinLeaves
+}
+
+// signPassLocked signs the jobs of a sign pass. Without ladder mode that is
+// signRRsetsParallel. In ladder mode it also rebuilds the apex LADDER RRset
+// and returns the jobs with it: in place if it was one of the jobs, else
+// appended and marked added. Caller holds zd.mu.
+func (zd *ZoneData) signPassLocked(jobs []signJob, dak *DnssecKeys, force bool, clamp *ClampParams) []signJob {
+	if !zd.ladderSigning() {
+		// A LADDER RRset left from ladder mode has nothing to prove.
+		for i := range jobs {
+			if jobs[i].name == zd.ZoneName && jobs[i].rrset.RRtype == core.TypeLADDER {
+				zd.stageDeleteLocked(zd.ZoneName, core.TypeLADDER)
+				jobs = append(jobs[:i], jobs[i+1:]...)
+				break
+			}
+		}
+		zd.signRRsetsParallel(jobs, dak, force, clamp, signerWorkers())
+		return jobs
+	}
+
+	lb := newLadderBatch(zd.DnssecPolicy, time.Now().UTC())
+	li := -1
+	for i := range jobs {
+		if jobs[i].name == zd.ZoneName && jobs[i].rrset.RRtype == core.TypeLADDER {
+			li = i
+			break
+		}
+	}
+	runSignJobs(jobs, signerWorkers(), func(j *signJob) {
+		if li >= 0 && j == &jobs[li] {
+			return // rebuilt below, once the new rungs are known
+		}
+		j.resigned, j.err = zd.signRRset(&j.rrset, zd.ZoneName, dak, force, clamp, lb)
+	})
+
+	trees := zd.buildLadderTrees(lb, jobs)
+	if li < 0 && len(trees) == 0 {
+		return jobs
+	}
+
+	var lrs core.RRset
+	if li >= 0 {
+		lrs = jobs[li].rrset
+	} else if owner := zd.apexOwnerLocked(); owner != nil {
+		if rs, exist := owner.RRtypes.Get(core.TypeLADDER); exist {
+			lrs = cloneRRset(rs)
+		}
+	}
+	orig := cloneRRset(lrs)
+	lrs.RRtype = core.TypeLADDER
+
+	// Prune expired rungs, add the new ones.
+	expiry := uint32(lb.now.Unix())
+	kept := lrs.RRs[:0:0]
+	changed := false
+	for _, rr := range lrs.RRs {
+		if prr, ok := rr.(*dns.PrivateRR); ok {
+			if rung, ok := prr.Data.(*core.LADDER); ok && rung.Expiration > expiry {
+				kept = append(kept, rr)
+				continue
+			}
+		}
+		changed = true
+	}
+	ttl := zd.ladderTTLLocked(orig)
+	for _, t := range trees {
+		kept = append(kept, core.NewLadderRR(zd.ZoneName, ttl, t.tree.Rung(t.key.DnskeyRR.KeyTag(), t.expiration)))
+		changed = true
+	}
+	lrs.RRs = kept
+
+	if len(lrs.RRs) == 0 {
+		// Only reachable without new trees: every rung has expired.
+		zd.stageDeleteLocked(zd.ZoneName, core.TypeLADDER)
+		if li >= 0 {
+			jobs = append(jobs[:li], jobs[li+1:]...)
+		}
+		return jobs
+	}
+
+	job := signJob{name: zd.ZoneName}
+	if changed {
+		lrs.RRSIGs = nil
+	}
+	job.resigned, job.err = zd.signRRset(&lrs, zd.ZoneName, dak, force || changed, clamp, nil)
+	if job.err != nil {
+		lgSigner.Error("ladder: signing the LADDER RRset failed, signing the leaves individually",
+			"zone", zd.ZoneName, "err", job.err)
+		job.rrset = orig
+		job.rrset.RRtype = core.TypeLADDER
+		for _, t := range trees {
+			zd.signLadderLeaves(t.leaves, jobs)
+		}
+	} else {
+		job.rrset = lrs
+		zd.completeLadderTrees(trees, &lrs)
+	}
+
+	if li >= 0 {
+		jobs[li] = job
+		return jobs
+	}
+	job.added = true
+	return append(jobs, job)
+}
+
+// buildLadderTrees builds one tree per key from the batch. The leaves of a
+// key with fewer than ladderMinLeaves leaves are signed individually.
+func (zd *ZoneData) buildLadderTrees(lb *ladderBatch, jobs []signJob) []*ladderTree {
+	bykey := map[*PrivateKeyCache][]*ladderLeaf{}
+	var keys []*PrivateKeyCache
+	for _, leaf := range lb.leaves {
+		if _, seen := bykey[leaf.key]; !seen {
+			keys = append(keys, leaf.key)
+		}
+		bykey[leaf.key] = append(bykey[leaf.key], leaf)
+	}
+
+	var trees []*ladderTree
+	for _, key := range keys {
+		leaves := bykey[key]
+		if len(leaves) < zd.ladderMinLeaves() {
+			zd.signLadderLeaves(leaves, jobs)
+			continue
+		}
+		sort.Slice(leaves, func(i, j int) bool { return bytes.Compare(leaves[i].hash, leaves[j].hash) < 0 })
+		hashes := make([][]byte, len(leaves))
+		var expiration uint32
+		for i, leaf := range leaves {
+			hashes[i] = leaf.hash
+			expiration = max(expiration, leaf.sig.Expiration)
+		}
+		tree, err := core.BuildLadderTree(hashes)
+		if err != nil {
+			zd.signLadderLeaves(leaves, jobs)
+			continue
+		}
+		trees = append(trees, &ladderTree{key: key, tree: tree, leaves: leaves, expiration: expiration})
+	}
+	return trees
+}
+
+// signLadderLeaves gives leaves ordinary signatures. A leaf that cannot be
+// signed is dropped from its job's RRset, and the job gets the error.
+func (zd *ZoneData) signLadderLeaves(leaves []*ladderLeaf, jobs []signJob) {
+	for _, leaf := range leaves {
+		err := leaf.sig.Sign(leaf.key.CS, leaf.rrs)
+		if err == nil {
+			continue
+		}
+		lgSigner.Error("rrsig.Sign failed", "name", leaf.sig.Hdr.Name, "err", err)
+		for i := range jobs {
+			for k, rr := range jobs[i].rrset.RRSIGs {
+				if rr == dns.RR(leaf.sig) {
+					jobs[i].rrset.RRSIGs = append(jobs[i].rrset.RRSIGs[:k], jobs[i].rrset.RRSIGs[k+1:]...)
+					jobs[i].err = err
+					break
+				}
+			}
+		}
+	}
+}
+
+// completeLadderTrees puts the inclusion proofs in the leaves, now that the
+// LADDER RRset lrs carrying the roots is signed, and logs the signature
+// bytes saved against one signature per RRset.
+func (zd *ZoneData) completeLadderTrees(trees []*ladderTree, lrs *core.RRset) {
+	for _, t := range trees {
+		proofBytes := 0
+		for i, leaf := range t.leaves {
+			proof := t.tree.Proof(i)
+			leaf.sig.Signature = proof.Signature()
+			proofBytes += len(proof.Pack())
+		}
+		sigBytes := 0
+		for _, rr := range lrs.RRSIGs {
+			if sig, ok := rr.(*dns.RRSIG); ok && sig.KeyTag == t.key.DnskeyRR.KeyTag() {
+				sigBytes = base64.StdEncoding.DecodedLen(len(sig.Signature))
+			}
+		}
+		lgSigner.Info("ladder: tree signed",
+			"zone", zd.ZoneName, "keyid", t.key.DnskeyRR.KeyTag(), "tree", t.tree.TreeID(),
+			"leaves", t.tree.Leaves(), "avg_proof_bytes", proofBytes/t.tree.Leaves(),
+			"signature_bytes", sigBytes, "bytes_saved", t.tree.Leaves()*sigBytes-proofBytes)
+	}
+}
+
+// apexOwnerLocked returns the apex as the pass sees it: staged if there is
+// a working set, else published.
+func (zd *ZoneData) apexOwnerLocked() *OwnerData {
+	if zd.workingSet != nil {
+		return zd.workingSet[zd.ZoneName]
+	}
+	if snap := zd.snapshot.Load(); snap != nil {
+		return snap.Data[zd.ZoneName]
+	}
+	return nil
+}
+
+// ladderTTLLocked returns the TTL for new rungs: that of the existing
+// LADDER RRset, else the SOA's.
+func (zd *ZoneData) ladderTTLLocked(lrs core.RRset) uint32 {
+	if len(lrs.RRs) > 0 {
+		if lrs.UnclampedTTL != 0 {
+			return lrs.UnclampedTTL
+		}
+		return lrs.RRs[0].Header().Ttl
+	}
+	if owner := zd.apexOwnerLocked(); owner != nil {
+		if soa, exist := owner.RRtypes.Get(dns.TypeSOA); exist && len(soa.RRs) > 0 {
+			return soa.RRs[0].Header().Ttl
+		}
+	}
+	return 3600
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package tdns
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// publishedLadder returns the rungs of the published apex LADDER RRset,
+// after checking its ordinary signature.
+func publishedLadder(t *testing.T, zd *ZoneData) []*core.LADDER {
+	t.Helper()
+	rrset, exist := zd.snapshot.Load().Data[zd.ZoneName].RRtypes.Get(core.TypeLADDER)
+	if !exist || len(rrset.RRs) == 0 {
+		t.Fatal("no LADDER RRset at the apex")
+	}
+	key := zd.ActiveDnssecKeys().ZSKs[0].DnskeyRR
+	if len(rrset.RRSIGs) != 1 {
+		t.Fatalf("LADDER RRset has %d RRSIGs, want 1", len(rrset.RRSIGs))
+	}
+	if err := rrset.RRSIGs[0].(*dns.RRSIG).Verify(&key, rrset.RRs); err != nil {
+		t.Fatalf("LADDER RRSIG does not verify: %v", err)
+	}
+	var rungs []*core.LADDER
+	for _, rr := range rrset.RRs {
+		rungs = append(rungs, rr.(*dns.PrivateRR).Data.(*core.LADDER))
+	}
+	return rungs
+}
+
+func verifyLadderA(t *testing.T, zd *ZoneData, name string, rungs []*core.LADDER) *core.LADDER {
+	t.Helper()
+	sig := publishedRRSIG(t, zd, name)
+	rrset, _ := zd.snapshot.Load().Data[name].RRtypes.Get(dns.TypeA)
+	rung, err := core.VerifyLadderRRSIG(sig, rrset.RRs, rungs)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return rung
+}
+
+func TestSignZoneLadder(t *testing.T) {
+	zd, kdb := signingTestZone(t, 100)
+	zd.DnssecPolicy.Signing = SigningPolicy{Mode: SigningModeMerkleLadder, LadderMinLeaves: 8}
+	if _, err := zd.SignZone(kdb, true); err != nil {
+		t.Fatalf("SignZone: %v", err)
+	}
+
+	rungs := publishedLadder(t, zd)
+	if len(rungs) != 1 {
+		t.Fatalf("%d rungs after one pass, want 1", len(rungs))
+	}
+	for i := 0; i < 100; i++ {
+		verifyLadderA(t, zd, fmt.Sprintf("host%d.zsk-alg.example.", i), rungs)
+	}
+	// 100 A, the apex SOA and NS, the glue-less ns A and 103 NSEC.
+	if rungs[0].Leaves < 200 {
+		t.Errorf("tree has %d leaves, want every signed RRset but DNSKEY and LADDER", rungs[0].Leaves)
+	}
+
+	apex := zd.snapshot.Load().Data[zd.ZoneName]
+	dnskeys, _ := apex.RRtypes.Get(dns.TypeDNSKEY)
+	if _, ok := core.LadderProofFromRRSIG(dnskeys.RRSIGs[0].(*dns.RRSIG)); ok {
+		t.Error("DNSKEY RRset signed with a ladder proof")
+	}
+	nsec, _ := apex.RRtypes.Get(dns.TypeNSEC)
+	var listed bool
+	for _, rrt := range nsec.RRs[0].(*dns.NSEC).TypeBitMap {
+		listed = listed || rrt == core.TypeLADDER
+	}
+	if !listed {
+		t.Error("apex NSEC does not list LADDER")
+	}
+
+	// Back to per-RRset signing: the ladder goes away.
+	zd.DnssecPolicy.Signing = SigningPolicy{Mode: SigningModePerRRset}
+	if _, err := zd.SignZone(kdb, true); err != nil {
+		t.Fatalf("SignZone: %v", err)
+	}
+	if _, exist := zd.snapshot.Load().Data[zd.ZoneName].RRtypes.Get(core.TypeLADDER); exist {
+		t.Error("LADDER RRset left after leaving ladder mode")
+	}
+	sig := publishedRRSIG(t, zd, "host1.zsk-alg.example.")
+	rrset, _ := zd.snapshot.Load().Data["host1.zsk-alg.example."].RRtypes.Get(dns.TypeA)
+	key := zd.ActiveDnssecKeys().ZSKs[0].DnskeyRR
+	if err := sig.Verify(&key, rrset.RRs); err != nil {
+		t.Errorf("RRSIG after leaving ladder mode does not verify: %v", err)
+	}
+}
+
+// ResignDue re-ladders the RRsets that fall due in one batch into a new
+// tree; the RRsets that are not due keep proving into the old one.
+func TestResignDueLadder(t *testing.T) {
+	zd, kdb := signingTestZone(t, 50)
+	zd.DnssecPolicy.Signing = SigningPolicy{Mode: SigningModeMerkleLadder, LadderMinLeaves: 8}
+	if _, err := zd.SignZone(kdb, true); err != nil {
+		t.Fatalf("SignZone: %v", err)
+	}
+	old := publishedLadder(t, zd)[0]
+	q := zd.resignQ
+
+	zd.mu.Lock()
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("host%d.zsk-alg.example.", i)
+		rrset := cloneRRset(zd.snapshot.Load().Data[name].RRtypes.GetOnlyRRSet(dns.TypeA))
+		rrset.RRtype = dns.TypeA
+		rrset.RRSIGs[0].(*dns.RRSIG).Expiration = uint32(time.Now().Add(10 * time.Minute).Unix())
+		zd.stageRRsetLocked(name, rrset)
+	}
+	zd.publishLocked(zd.generation.Load())
+	scheduled := resignScheduled(q)
+	zd.mu.Unlock()
+
+	n, err := zd.ResignDue(kdb, time.Now())
+	if err != nil || n != 11 {
+		t.Fatalf("ResignDue = %d, %v; want 10 RRsets and the LADDER RRset re-signed", n, err)
+	}
+	rungs := publishedLadder(t, zd)
+	if len(rungs) != 2 {
+		t.Fatalf("%d rungs, want the old tree and the new one", len(rungs))
+	}
+	for i := 0; i < 10; i++ {
+		if rung := verifyLadderA(t, zd, fmt.Sprintf("host%d.zsk-alg.example.", i), rungs); rung.TreeID == old.TreeID {
+			t.Errorf("host%d still proves into the old tree", i)
+		}
+	}
+	if rung := verifyLadderA(t, zd, "host20.zsk-alg.example.", rungs); rung.TreeID != old.TreeID {
+		t.Error("an RRset that was not due moved to the new tree")
+	}
+	if n := resignScheduled(q); n != scheduled {
+		t.Errorf("schedule has %d RRsets after ResignDue, want %d", n, scheduled)
+	}
+}
+
+// resignScheduled returns the number of RRsets in a re-sign schedule.
+func resignScheduled(q *resignQueue) int {
+	n := 0
+	for _, m := range q.due {
+		n += len(m)
+	}
+	return n
+}
+
+// Below signing.ladder-min-leaves a batch gets ordinary signatures.
+func TestLadderMinLeaves(t *testing.T) {
+	zd, kdb := signingTestZone(t, 3)
+	zd.DnssecPolicy.Signing = SigningPolicy{Mode: SigningModeMerkleLadder, LadderMinLeaves: 1000}
+	if _, err := zd.SignZone(kdb, true); err != nil {
+		t.Fatalf("SignZone: %v", err)
+	}
+	sig := publishedRRSIG(t, zd, "host1.zsk-alg.example.")
+	rrset, _ := zd.snapshot.Load().Data["host1.zsk-alg.example."].RRtypes.Get(dns.TypeA)
+	key := zd.ActiveDnssecKeys().ZSKs[0].DnskeyRR
+	if err := sig.Verify(&key, rrset.RRs); err != nil {
+		t.Fatalf("RRSIG does not verify: %v", err)
+	}
+	if _, exist := zd.snapshot.Load().Data[zd.ZoneName].RRtypes.Get(core.TypeLADDER); exist {
+		t.Error("LADDER RRset published without a tree")
+	}
+}
+
+func TestParsePolicySigningMode(t *testing.T) {
+	for _, tc := range []struct {
+		mode      string
+		minLeaves int
+		want      SigningPolicy
+		wantErr   bool
+	}{
+		{"", 0, SigningPolicy{Mode: SigningModePerRRset, LadderMinLeaves: defaultLadderMinLeaves}, false},
+		{"Merkle-Ladder", 32, SigningPolicy{Mode: SigningModeMerkleLadder, LadderMinLeaves: 32}, false},
+		{"per-rrset", 0, SigningPolicy{Mode: SigningModePerRRset, LadderMinLeaves: defaultLadderMinLeaves}, false},
+		{"merkle", 0, SigningPolicy{}, true},
+		{"merkle-ladder", -1, SigningPolicy{}, true},
+	} {
+		conf := &DnssecPolicyConf{Algorithm: "ED25519"}
+		conf.SigValidity.Default = "14d"
+		conf.Signing = DnssecPolicySigningConf{Mode: tc.mode, LadderMinLeaves: tc.minLeaves}
+		pol, err := ParseDnssecPolicyConfQuiet("p", conf)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("mode %q min %d: err = %v, wantErr %v", tc.mode, tc.minLeaves, err, tc.wantErr)
+			continue
+		}
+		if err == nil && pol.Signing != tc.want {
+			t.Errorf("mode %q min %d = %+v, want %+v", tc.mode, tc.minLeaves, pol.Signing, tc.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+
+package tdns
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	core "github.com/johanix/tdns/v2/core"
+)
+
+// Parallel RRset signing. A sign pass collects the RRsets to sign as
+// signJobs, signs them on a pool of workers and then stages the results
+// serially, in order, from the goroutine holding zd.mu. Only the signing
+// itself runs concurrently: SignRRset works on the job's private copy of
+// the RRset, the signing keys are crypto.Signers that are safe for
+// concurrent use, and the clamp and validity checks it makes only touch
+// atomics.
+
+// signJob is one RRset to sign in a pass.
+type signJob struct {
+	name     string
+	rrset    core.RRset
+	resigned bool
+	err      error
+	added    bool // added by the pass itself (the apex LADDER RRset)
+}
+
+// Below this many jobs a pass is signed serially: the pool is not worth
+// starting for a handful of RRsets (the typical dynamic update).
+const signParallelMinJobs = 64
+
+// signBatch is the number of jobs a worker claims at a time.
+const signBatch = 16
+
+// signerWorkers returns the number of signing workers to use, from
+// resignerengine.workers. Zero or less means one per usable CPU.
+func signerWorkers() int {
+	if n := ConfLive().SignerWorkers; n > 0 {
+		return n
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// signRRsetsParallel signs every job's RRset with SignRRset on up to workers
+// goroutines, recording the outcome in the job. It returns when all jobs
+// are done.
+func (zd *ZoneData) signRRsetsParallel(jobs []signJob, dak *DnssecKeys, force bool, clamp *ClampParams, workers int) {
+	runSignJobs(jobs, workers, func(j *signJob) {
+		j.resigned, j.err = zd.SignRRset(&j.rrset, zd.ZoneName, dak, force, clamp)
+	})
+}
+
+// runSignJobs calls sign for every job on up to workers goroutines and
+// returns when all are done.
+func runSignJobs(jobs []signJob, workers int, sign func(*signJob)) {
+	if workers > len(jobs)/signBatch {
+		workers = len(jobs) / signBatch
+	}
+	if workers <= 1 || len(jobs) < signParallelMinJobs {
+		for i := range jobs {
+			sign(&jobs[i])
+		}
+		return
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				start := int(next.Add(signBatch)) - signBatch
+				if start >= len(jobs) {
+					return
+				}
+				end := min(start+signBatch, len(jobs))
+				for i := start; i < end; i++ {
+					sign(&jobs[i])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,263 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ */
+package tdns
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/johanix/tdns/v2/core"
+	"github.com/miekg/dns"
+)
+
+// signingTestZone builds a signing zone with n A RRsets under algZone and an
+// active ED25519 KSK and ZSK.
+func signingTestZone(tb testing.TB, n int) (*ZoneData, *KeyDB) {
+	tb.Helper()
+	kdb := newTestKeyDB(tb)
+	var sb strings.Builder
+	sb.WriteString("zsk-alg.example. 3600 IN SOA ns.zsk-alg.example. hostmaster.zsk-alg.example. 1 7200 1800 604800 7200\n")
+	sb.WriteString("zsk-alg.example. 3600 IN NS ns.zsk-alg.example.\n")
+	sb.WriteString("ns.zsk-alg.example. 3600 IN A 192.0.2.1\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "host%d.zsk-alg.example. 3600 IN A 192.0.2.%d\n", i, i%250+1)
+	}
+	day := 24 * time.Hour
+	zd := &ZoneData{
+		ZoneName:  algZone,
+		ZoneStore: MapZone,
+		Options:   map[ZoneOption]bool{OptOnlineSigning: true},
+		DnssecPolicy: &DnssecPolicy{
+			Mode:         DnssecPolicyModeKSKZSK,
+			KSKAlgorithm: dns.ED25519,
+			ZSKAlgorithm: dns.ED25519,
+			SigValidity: PolicySigValidity{
+				Default: uint32((14 * day).Seconds()),
+				DNSKEY:  uint32((14 * day).Seconds()),
+				DS:      uint32((14 * day).Seconds()),
+				Jitter:  uint32(day.Seconds()),
+			},
+		},
+		DnssecPolicyName: "base",
+		KeyDB:            kdb,
+		Logger:           log.New(os.Stderr, "", 0),
+	}
+	if _, _, err := zd.ReadZoneData(sb.String(), true); err != nil {
+		tb.Fatalf("ReadZoneData: %v", err)
+	}
+	zd.Ready = true
+	zd.InstallInitialSnapshot()
+	tb.Cleanup(zd.stopPublisher)
+	Zones.Set(zd.ZoneName, zd)
+	tb.Cleanup(func() { Zones.Remove(zd.ZoneName) })
+
+	for _, role := range []string{"KSK", "ZSK"} {
+		if _, _, err := kdb.GenerateKeypair(algZone, "test", DnskeyStateActive, dns.TypeDNSKEY, dns.ED25519, role, nil); err != nil {
+			tb.Fatalf("%s: %v", role, err)
+		}
+	}
+	if err := zd.republishSigningKeys(kdb); err != nil {
+		tb.Fatalf("republish: %v", err)
+	}
+	return zd, kdb
+}
+
+func publishedRRSIG(t *testing.T, zd *ZoneData, name string) *dns.RRSIG {
+	t.Helper()
+	owner := zd.snapshot.Load().Data[name]
+	if owner == nil {
+		t.Fatalf("%s not published", name)
+	}
+	rrset, _ := owner.RRtypes.Get(dns.TypeA)
+	if len(rrset.RRSIGs) != 1 {
+		t.Fatalf("%s A has %d RRSIGs, want 1", name, len(rrset.RRSIGs))
+	}
+	return rrset.RRSIGs[0].(*dns.RRSIG)
+}
+
+func TestSignZoneParallel(t *testing.T) {
+	zd, kdb := signingTestZone(t, 500)
+	if _, err := zd.SignZone(kdb, true); err != nil {
+		t.Fatalf("SignZone: %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		sig := publishedRRSIG(t, zd, fmt.Sprintf("host%d.zsk-alg.example.", i))
+		owner := zd.snapshot.Load().Data[sig.Header().Name]
+		rrset, _ := owner.RRtypes.Get(dns.TypeA)
+		key := zd.ActiveDnssecKeys().ZSKs[0].DnskeyRR
+		if err := sig.Verify(&key, rrset.RRs); err != nil {
+			t.Fatalf("%s: RRSIG does not verify: %v", sig.Header().Name, err)
+		}
+	}
+	if _, ok := zd.resignQ.Next(); !ok {
+		t.Fatal("SignZone did not schedule re-signing")
+	}
+}
+
+func TestResignDue(t *testing.T) {
+	zd, kdb := signingTestZone(t, 100)
+	if _, err := zd.SignZone(kdb, true); err != nil {
+		t.Fatalf("SignZone: %v", err)
+	}
+	q := zd.resignQ
+	next, ok := q.Next()
+	if !ok || !next.After(time.Now().Add(24*time.Hour)) {
+		t.Fatalf("first re-sign due %v, want days from now", next)
+	}
+
+	// Nothing is due: no re-signing and the schedule stays current.
+	if n, err := zd.ResignDue(kdb, time.Now()); err != nil || n != 0 {
+		t.Fatalf("ResignDue with nothing due = %d, %v", n, err)
+	}
+	if zd.resignQ != q {
+		t.Fatal("ResignDue replaced a current schedule")
+	}
+
+	// Make one signature about to expire, as if published long ago. The
+	// staged owner is rescheduled on the next tick.
+	const due = "host7.zsk-alg.example."
+	other := publishedRRSIG(t, zd, "host8.zsk-alg.example.")
+	zd.mu.Lock()
+	rrset := cloneRRset(zd.snapshot.Load().Data[due].RRtypes.GetOnlyRRSet(dns.TypeA))
+	rrset.RRtype = dns.TypeA
+	rrset.RRSIGs[0].(*dns.RRSIG).Expiration = uint32(time.Now().Add(10 * time.Minute).Unix())
+	zd.stageRRsetLocked(due, rrset)
+	zd.publishLocked(zd.generation.Load())
+	zd.mu.Unlock()
+
+	n, err := zd.ResignDue(kdb, time.Now())
+	if err != nil || n != 1 {
+		t.Fatalf("ResignDue = %d, %v; want 1 RRset re-signed", n, err)
+	}
+	if exp := time.Unix(int64(publishedRRSIG(t, zd, due).Expiration), 0); exp.Before(time.Now().Add(13 * 24 * time.Hour)) {
+		t.Errorf("re-signed RRSIG expires %v", exp)
+	}
+	if publishedRRSIG(t, zd, "host8.zsk-alg.example.") != other {
+		t.Error("ResignDue re-signed an RRset that was not due")
+	}
+	if zd.resignQ != q || len(q.dirty) != 0 {
+		t.Fatal("ResignDue's own publish made its schedule stale")
+	}
+
+	// An update adds one RRset and removes another: the next tick signs
+	// the new one and drops the removed one, and keeps the schedule.
+	const gone = "host9.zsk-alg.example."
+	a, _ := dns.NewRR("new.zsk-alg.example. 3600 IN A 192.0.2.99")
+	zd.mu.Lock()
+	zd.stageRRsetLocked("new.zsk-alg.example.", core.RRset{Name: "new.zsk-alg.example.", RRtype: dns.TypeA, RRs: []dns.RR{a}})
+	zd.stageDeleteLocked(gone, dns.TypeA)
+	zd.publishLocked(zd.generation.Load())
+	zd.mu.Unlock()
+	n, err = zd.ResignDue(kdb, time.Now())
+	if err != nil || n != 1 {
+		t.Fatalf("ResignDue after an update = %d, %v; want the new RRset signed", n, err)
+	}
+	if zd.resignQ != q {
+		t.Fatal("an update threw the schedule away")
+	}
+	publishedRRSIG(t, zd, "new.zsk-alg.example.")
+	if _, ok := q.due[gone][dns.TypeA]; ok {
+		t.Error("removed RRset is still scheduled")
+	}
+	if _, ok := q.due["new.zsk-alg.example."][dns.TypeA]; !ok {
+		t.Error("added RRset is not scheduled")
+	}
+	if publishedRRSIG(t, zd, "host8.zsk-alg.example.") != other {
+		t.Error("an update re-signed an RRset it did not touch")
+	}
+
+	// A pending working set waits for its publisher.
+	zd.mu.Lock()
+	zd.stageRRsetLocked("new.zsk-alg.example.", core.RRset{Name: "new.zsk-alg.example.", RRtype: dns.TypeA, RRs: []dns.RR{a}})
+	zd.mu.Unlock()
+	if n, err := zd.ResignDue(kdb, time.Now()); err != nil || n != 0 || zd.resignQ != q {
+		t.Fatalf("ResignDue with unpublished changes = %d, %v", n, err)
+	}
+}
+
+func TestSigLifetimeJitter(t *testing.T) {
+	now := time.Now().UTC()
+	seen := map[uint32]bool{}
+	for i := 0; i < 200; i++ {
+		incep, expir := sigLifetime(now, 3600, 600)
+		if d := now.Unix() - int64(incep); d < 60 || d > 120 {
+			t.Fatalf("inception backdated %ds, want 60-120s", d)
+		}
+		if d := int64(expir) - now.Unix(); d < 3600 || d > 4200 {
+			t.Fatalf("expiration %ds from now, want 3600-4200s", d)
+		}
+		seen[expir] = true
+	}
+	if len(seen) < 10 {
+		t.Errorf("only %d distinct expirations in 200 signatures", len(seen))
+	}
+}
+
+func TestParsePolicySigValidityJitter(t *testing.T) {
+	for _, tc := range []struct {
+		jitter  string
+		want    uint32
+		wantErr bool
+	}{
+		{"", 1209600 / 10, false},
+		{"0", 0, false},
+		{"2d", 2 * 86400, false},
+		{"-1h", 0, true},
+		{"14d", 0, true},
+		{"bogus", 0, true},
+	} {
+		sv, err := parsePolicySigValidity("p", DnssecPolicySigValidityConf{Default: "14d", Jitter: tc.jitter})
+		if (err != nil) != tc.wantErr {
+			t.Errorf("jitter %q: err = %v, wantErr %v", tc.jitter, err, tc.wantErr)
+			continue
+		}
+		if err == nil && sv.Jitter != tc.want {
+			t.Errorf("jitter %q = %d, want %d", tc.jitter, sv.Jitter, tc.want)
+		}
+	}
+}
+
+// BenchmarkSignRRsetsParallel measures signing throughput of a full zone pass
+// against the number of signing workers.
+func BenchmarkSignRRsetsParallel(b *testing.B) {
+	const rrsets = 2000
+	zd, _ := signingTestZone(b, rrsets)
+	dak := zd.ActiveDnssecKeys()
+	snap := zd.snapshot.Load()
+	var template []signJob
+	for name, owner := range snap.Data {
+		for _, rrt := range owner.RRtypes.Keys() {
+			if rrt == dns.TypeNS || rrt == dns.TypeRRSIG {
+				continue
+			}
+			rrset := owner.RRtypes.GetOnlyRRSet(rrt)
+			rrset.RRtype = rrt
+			template = append(template, signJob{name: name, rrset: rrset})
+		}
+	}
+
+	for workers := 1; ; workers *= 2 {
+		if workers > runtime.NumCPU() {
+			workers = runtime.NumCPU()
+		}
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			jobs := make([]signJob, len(template))
+			for i := 0; i < b.N; i++ {
+				for j := range template {
+					jobs[j] = signJob{name: template[j].name, rrset: cloneRRset(template[j].rrset)}
+				}
+				zd.signRRsetsParallel(jobs, dak, true, nil, workers)
+			}
+			b.ReportMetric(float64(b.N*len(jobs))/b.Elapsed().Seconds(), "rrsets/s")
+		})
+		if workers == runtime.NumCPU() {
+			break
+		}
+	}
+}
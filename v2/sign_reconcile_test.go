@@ -90,7 +90,7 @@ func (zd *ZoneData) mustRRSIGKeytags(t *testing.T, name string, rrtype uint16) [
 	return tags
 }
 
-func newTestKeyDB(t *testing.T) *KeyDB {
+func newTestKeyDB(t testing.TB) *KeyDB {
 	t.Helper()
 	f := filepath.Join(t.TempDir(), "test.db")
 	if err := os.WriteFile(f, nil, 0664); err != nil {
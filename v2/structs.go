@@ -71,6 +71,7 @@ const (
 	DnskeyStateActive      string = "active"
 	DnskeyStateRetired     string = "retired"
 	DnskeyStateRemoved     string = "removed"
+	DnskeyStateForeign     string = "foreign" // another signer's ZSK, published but never used for signing
 )
 
 // MPdata caches multi-provider membership and signing state for a zone.
@@ -166,6 +167,7 @@ type ZoneData struct {
 	ParentServers     []string // addresses of parent nameservers
 	Children          map[string]*ChildDelegationData
 	DelegationBackend DelegationBackend // parent-side: backend for storing child delegation data
+	CdsPolicy         *CdsPolicy        // parent-side: acceptance rules for child CDS (nil: act on the first consistent CDS)
 	Options           map[ZoneOption]bool
 	// SuppressedOptions records the origination options that were configured
 	// for this zone but stripped by normalizeOptionsForRole (a tdns-auth
@@ -223,6 +225,12 @@ type ZoneData struct {
 	// zone replacement): updateIxfrChainLocked clears the delta history
 	// instead of diffing. Set under zd.mu by applyRefreshReplacementLocked.
 	wsIxfrEpochReset bool
+	// resignQ is the re-sign schedule built by the last SignZone pass and
+	// consumed by the periodic resigner (resign_queue.go). Guarded by zd.mu.
+	resignQ *resignQueue
+	// skrPeriod is the inception of the SKR bundle whose DNSKEY RRset is
+	// staged, for zones with an offline KSK (ksr.go). Guarded by zd.mu.
+	skrPeriod time.Time
 	// ixfrChainMaxBytes bounds the retained IXFR delta history (estimated
 	// wire bytes). 0 => DefaultIxfrChainMaxBytes; negative => retention
 	// disabled (IXFR queries are answered with full transfers). From zone
@@ -319,6 +327,7 @@ type ZoneConf struct {
 	Dirty             bool         // true if zone has been modified; not a config param
 	UpdatePolicy      UpdatePolicyConf
 	DelegationBackend string `yaml:"delegationbackend" mapstructure:"delegationbackend"` // named backend for child delegation data
+	CdsPolicy         string `yaml:"cdspolicy" mapstructure:"cdspolicy"`                 // named CDS acceptance policy (cdspolicies:)
 	DnssecPolicy      string `yaml:"dnssecpolicy" mapstructure:"dnssecpolicy"`
 	// OutboundSoaSerial is the per-zone override of the server-global
 	// dnsengine.outbound_soa_serial. Empty (the default) inherits the global.
@@ -553,6 +562,7 @@ type DnssecPolicySigValidityConf struct {
 	Default string `yaml:"default" mapstructure:"default"`
 	Dnskey  string `yaml:"dnskey" mapstructure:"dnskey"`
 	Ds      string `yaml:"ds" mapstructure:"ds"`
+	Jitter  string `yaml:"jitter" mapstructure:"jitter"`
 }
 
 // DnssecPolicyClampingConf is the YAML `clamping:` subtree under a DNSSEC policy.
@@ -561,6 +571,12 @@ type DnssecPolicyClampingConf struct {
 	Margin  string `yaml:"margin" mapstructure:"margin"`
 }
 
+// DnssecPolicySigningConf is the YAML `signing:` subtree under a DNSSEC policy.
+type DnssecPolicySigningConf struct {
+	Mode            string `yaml:"mode" mapstructure:"mode"`
+	LadderMinLeaves int    `yaml:"ladder-min-leaves" mapstructure:"ladder-min-leaves"`
+}
+
 // DnssecPolicyConf should match the configuration
 type DnssecPolicyConf struct {
 	Name string
@@ -574,6 +590,9 @@ type DnssecPolicyConf struct {
 	KSK struct {
 		Lifetime  string
 		Algorithm string `yaml:"algorithm" mapstructure:"algorithm"`
+		// Offline keeps the KSK private keys out of this keystore: the
+		// DNSKEY RRset is pre-signed via KSR/SKR exchange (ksr.go).
+		Offline bool `yaml:"offline" mapstructure:"offline"`
 	}
 	ZSK struct {
 		Lifetime  string
@@ -588,6 +607,7 @@ type DnssecPolicyConf struct {
 	Rollover DnssecPolicyRolloverConf `yaml:"rollover" mapstructure:"rollover"`
 	Ttls     DnssecPolicyTtlsConf     `yaml:"ttls" mapstructure:"ttls"`
 	Clamping DnssecPolicyClampingConf `yaml:"clamping" mapstructure:"clamping"`
+	Signing  DnssecPolicySigningConf  `yaml:"signing" mapstructure:"signing"`
 }
 
 type KeyLifetime struct {
@@ -599,6 +619,7 @@ type PolicySigValidity struct {
 	Default uint32
 	DNSKEY  uint32
 	DS      uint32
+	Jitter  uint32 // random spread added to each expiration
 }
 
 // DnssecPolicy is what is actually used; it is created from the corresponding DnssecPolicyConf
@@ -618,6 +639,7 @@ type DnssecPolicy struct {
 	KSKAlgorithm uint8
 	ZSKAlgorithm uint8
 	Mode         string
+	OfflineKSK   bool // ksk.offline: DNSKEY RRset signed from an imported SKR
 
 	KSK KeyLifetime
 	ZSK KeyLifetime
@@ -628,6 +650,7 @@ type DnssecPolicy struct {
 	Rollover RolloverPolicy
 	TTLS     DnssecPolicyTTLS
 	Clamping ClampingPolicy
+	Signing  SigningPolicy
 
 	// suppressLoadWarnings is set by ParseDnssecPolicyConfQuiet so
 	// CLI tools that re-parse a daemon's policy don't duplicate the
@@ -690,6 +713,7 @@ type ChildDelegationData struct {
 	AAAA_glue_rrsigs []dns.RR
 	NS_rrset         *core.RRset
 	DS_rrset         *core.RRset
+	DELEG_rrset      *core.RRset
 	A_rrsets         []*core.RRset
 	AAAA_rrsets      []*core.RRset
 }
@@ -1043,8 +1067,9 @@ func (kdb *KeyDB) AuthOption(key AuthOption) (string, bool) {
 
 type Tx struct {
 	*sql.Tx
-	KeyDB   *KeyDB
-	context string
+	KeyDB    *KeyDB
+	context  string
+	onCommit []func()
 }
 
 // String-based versions of RRset for JSON marshaling
@@ -253,7 +253,14 @@ func UpdateResponder(dur *DnsUpdateRequest, updateq chan UpdateRequest) error {
 			}
 		}
 
-		if isChildUpdate && childDel != "" {
+		if zd.isMultiSignerDnskeyUpdate(r.Ns) {
+			// A multi-signer peer pushing its ZSKs. This writes foreign
+			// keys to the keystore rather than zone data, so it is not
+			// gated on allow-updates; ApproveMultiSignerUpdate requires the
+			// peer's trusted SIG(0) key instead.
+			lgHandler.Info("update targets the multi-signer DNSKEY RRset", "zone", zd.ZoneName)
+			dur.Status.Type = "MULTISIGNER-UPDATE"
+		} else if isChildUpdate && childDel != "" {
 			lgHandler.Info("update targets child delegation", "child", childDel)
 			dur.Status.Type = "CHILD-UPDATE"
 			if !zd.Options[OptAllowChildUpdates] {
@@ -439,6 +446,7 @@ func UpdateResponder(dur *DnsUpdateRequest, updateq chan UpdateRequest) error {
 		Validated: dur.Status.Validated,
 		Trusted:   dur.Status.ValidatedByTrustedKey,
 		Status:    dur.Status,
+		Actor:     dnsUpdateActor(w, r, dur.Status),
 	}
 	return nil
 }
@@ -451,6 +459,8 @@ func (zd *ZoneData) ApproveUpdate(zone string, us *UpdateStatus, r *dns.Msg) (bo
 		return zd.ApproveChildUpdate(zone, us, r)
 	case "ZONE-UPDATE":
 		return zd.ApproveAuthUpdate(zone, us, r)
+	case "MULTISIGNER-UPDATE":
+		return zd.ApproveMultiSignerUpdate(zone, us, r)
 	case "TRUSTSTORE-UPDATE":
 		// XXX: Perhaps there should be a separate function for approval of truststore updates?
 		// XXX: Then the ApproveChildUpdate() could be simplified.
@@ -670,6 +680,57 @@ func (zd *ZoneData) ApproveAuthUpdate(zone string, us *UpdateStatus, r *dns.Msg)
 	return true, true, nil
 }
 
+// A multi-signer update is a peer pushing its ZSKs. It must be signed by the
+// trusted SIG(0) key of a configured peer and may only add or remove ZSKs of
+// an algorithm the zone signs with; the peer's KSKs are never published by us.
+// Returns approved, updatezone, error
+func (zd *ZoneData) ApproveMultiSignerUpdate(zone string, us *UpdateStatus, r *dns.Msg) (bool, bool, error) {
+	if us.ValidationRcode != dns.RcodeSuccess || !us.Validated {
+		us.Approved = false
+		if us.RejectionEDE == 0 {
+			us.RejectionEDE = edns0.EDESig0BadSignature
+		}
+		lgHandler.Warn("multi-signer update rejected: signature did not validate")
+		return false, false, nil
+	}
+
+	peer := zd.multiSignerPeerForUpdate(us)
+	if peer == nil {
+		us.Approved = false
+		us.RejectionEDE = edns0.EDESig0KeyKnownButNotTrusted
+		lgHandler.Warn("multi-signer update rejected: not signed by the trusted key of a multi-signer peer", "zone", zone, "signer", us.SignerName)
+		return false, false, nil
+	}
+
+	algs, err := zoneKeyAlgorithms(zd.KeyDB, zone)
+	if err != nil {
+		return false, false, fmt.Errorf("ApproveMultiSignerUpdate: %w", err)
+	}
+	for _, rr := range r.Ns {
+		if rr.Header().Class == dns.ClassANY {
+			continue
+		}
+		k, ok := rr.(*dns.DNSKEY)
+		if !ok || !isForeignZsk(k) {
+			us.Approved = false
+			us.RejectionEDE = edns0.EDEZoneUpdateRRtypeNotAllowed
+			lgHandler.Warn("multi-signer update rejected: only ZSKs may be pushed", "zone", zone, "peer", peer.Name, "rr", rr.String())
+			return false, false, nil
+		}
+		if rr.Header().Class == dns.ClassINET && !algs[k.Algorithm] {
+			us.Approved = false
+			us.RejectionEDE = dns.ExtendedErrorCodeUnsupportedDNSKEYAlgorithm
+			lgHandler.Warn("multi-signer update rejected: ZSK algorithm is not one the zone signs with", "zone", zone, "peer", peer.Name,
+				"keyid", k.KeyTag(), "algorithm", dns.AlgorithmToString[k.Algorithm])
+			return false, false, nil
+		}
+	}
+
+	lgHandler.Info("multi-signer update approved", "zone", zone, "peer", peer.Name)
+	us.Approved = true
+	return true, true, nil
+}
+
 // Trust updates are either validated updates (signed by already trusted key) or unvalidated
 // (selfsigned initial uploads of key). In both cases the update section must only contain a
 // single KEY RR.
@@ -0,0 +1,676 @@
+/*
+ * Copyright (c) 2026 Johan Stenstam, johan.stenstam@internetstiftelsen.se
+ *
+ * Zone linter: semantic checks on zone content that the zone file parser
+ * cannot make. Used before a loaded or transferred zone is published, before
+ * a DNS UPDATE or change set is published (dnsengine.zone-lint: off | warn |
+ * refuse) and on demand by "zone lint".
+ */
+
+package tdns
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Zone lint modes (dnsengine.zone-lint).
+const (
+	ZoneLintOff    = "off"
+	ZoneLintWarn   = "warn"   // default: log and flag the zone, publish anyway
+	ZoneLintRefuse = "refuse" // lint errors make the load or transfer fail
+)
+
+// Lint issue severities. Only errors make a load fail in refuse mode.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+	LintInfo    = "info"
+)
+
+// LintIssue is one finding. Check is a stable short name for the rule.
+type LintIssue struct {
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Owner    string `json:"owner,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Msg      string `json:"msg"`
+}
+
+func (li LintIssue) String() string {
+	where := li.Owner
+	if li.Type != "" {
+		where += " " + li.Type
+	}
+	return fmt.Sprintf("%s [%s] %s: %s", li.Severity, li.Check, where, li.Msg)
+}
+
+// LintCounts returns the number of errors and warnings in issues.
+func LintCounts(issues []LintIssue) (errs, warns int) {
+	for _, li := range issues {
+		switch li.Severity {
+		case LintError:
+			errs++
+		case LintWarning:
+			warns++
+		}
+	}
+	return
+}
+
+// zoneLintMode returns the configured lint mode, defaulting to warn.
+func zoneLintMode() string {
+	switch m := ConfLive().ZoneLint; m {
+	case ZoneLintOff, ZoneLintRefuse:
+		return m
+	}
+	return ZoneLintWarn
+}
+
+// zoneLinter holds the zone being linted. hosted looks up other zones served
+// by this server (nil when linting a file outside the server). referenced
+// holds the NS targets of the delegations seen, for the orphaned-glue check.
+type zoneLinter struct {
+	zone       string
+	data       map[string]*OwnerData
+	referenced map[string]bool
+	hosted     func(name string) *zoneSnapshot
+	issues     []LintIssue
+}
+
+func newZoneLinter(zone string, data map[string]*OwnerData, hosted func(name string) *zoneSnapshot) *zoneLinter {
+	return &zoneLinter{zone: dns.CanonicalName(zone), data: data, referenced: map[string]bool{}, hosted: hosted}
+}
+
+func (l *zoneLinter) add(sev, check, owner string, rrtype uint16, format string, args ...interface{}) {
+	li := LintIssue{Severity: sev, Check: check, Owner: owner, Msg: fmt.Sprintf(format, args...)}
+	if rrtype != 0 {
+		li.Type = dns.TypeToString[rrtype]
+	}
+	l.issues = append(l.issues, li)
+}
+
+func (l *zoneLinter) rrs(owner string, rrtype uint16) []dns.RR {
+	if od := l.data[owner]; od != nil && od.RRtypes != nil {
+		return od.RRtypes.GetOnlyRRSet(rrtype).RRs
+	}
+	return nil
+}
+
+func (l *zoneLinter) hasAddress(owner string) bool {
+	return len(l.rrs(owner, dns.TypeA)) > 0 || len(l.rrs(owner, dns.TypeAAAA)) > 0
+}
+
+// isCut reports whether name is a delegation point below the apex.
+func (l *zoneLinter) isCut(name string) bool {
+	return name != l.zone && dns.IsSubDomain(l.zone, name) && len(l.rrs(name, dns.TypeNS)) > 0
+}
+
+// reference marks the NS targets of the delegation at cut as referenced.
+func (l *zoneLinter) reference(cut string) {
+	for target := range csNSTargets(l.rrs(cut, dns.TypeNS)) {
+		l.referenced[target] = true
+	}
+}
+
+// cutAbove returns the closest delegation point strictly above owner, or "".
+func (l *zoneLinter) cutAbove(owner string) string {
+	for name := owner; name != l.zone; {
+		off, end := dns.NextLabel(name, 0)
+		if end {
+			return ""
+		}
+		name = name[off:]
+		if name == l.zone || !dns.IsSubDomain(l.zone, name) {
+			return ""
+		}
+		if l.isCut(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// LintZoneData runs all checks over the owners of a zone. hosted may be nil.
+func LintZoneData(zone string, data map[string]*OwnerData, hosted func(name string) *zoneSnapshot) []LintIssue {
+	l := newZoneLinter(zone, data, hosted)
+	owners := make([]string, 0, len(data))
+	for name, od := range data {
+		if od == nil || od.RRtypes == nil {
+			continue
+		}
+		owners = append(owners, name)
+		if l.isCut(name) {
+			l.reference(name)
+		}
+	}
+	sort.Strings(owners)
+
+	l.checkApex()
+	for _, owner := range owners {
+		l.checkOwner(owner)
+	}
+	return l.issues
+}
+
+// lintZoneOwners runs the zone-wide apex checks and the per-owner checks for
+// the given owners only. Glue only counts as referenced by the delegations
+// among or directly above those owners.
+func lintZoneOwners(zone string, data map[string]*OwnerData, hosted func(name string) *zoneSnapshot, owners []string) []LintIssue {
+	l := newZoneLinter(zone, data, hosted)
+	for _, owner := range owners {
+		if l.isCut(owner) {
+			l.reference(owner)
+		}
+		if cut := l.cutAbove(owner); cut != "" {
+			l.reference(cut)
+		}
+	}
+	l.checkApex()
+	for _, owner := range owners {
+		if od := data[owner]; od != nil && od.RRtypes != nil {
+			l.checkOwner(owner)
+		}
+	}
+	return l.issues
+}
+
+// lintScope returns the owners whose findings a change to the touched owners
+// can alter, in each of the given versions of the zone: the owners
+// themselves, the apex, the delegation above each of them and the NS targets
+// of each delegation among them. When a change adds or removes a delegation,
+// everything below it is included as well. Findings elsewhere that depend
+// on a touched owner (an MX elsewhere pointing at a removed address) are
+// left to the next full lint.
+func lintScope(zone string, touched []string, versions ...map[string]*OwnerData) []string {
+	zone = dns.CanonicalName(zone)
+	scope := map[string]bool{zone: true}
+	for _, owner := range touched {
+		owner = dns.CanonicalName(owner)
+		scope[owner] = true
+		cuts := 0
+		for _, data := range versions {
+			l := newZoneLinter(zone, data, nil)
+			if cut := l.cutAbove(owner); cut != "" {
+				scope[cut] = true
+			}
+			if l.isCut(owner) {
+				cuts++
+				for target := range csNSTargets(l.rrs(owner, dns.TypeNS)) {
+					scope[target] = true
+				}
+			}
+		}
+		if cuts == 0 || cuts == len(versions) {
+			continue
+		}
+		for _, data := range versions {
+			for name := range data {
+				if dns.IsSubDomain(owner, name) {
+					scope[name] = true
+				}
+			}
+		}
+	}
+	owners := make([]string, 0, len(scope))
+	for name := range scope {
+		owners = append(owners, name)
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+func (l *zoneLinter) checkApex() {
+	if len(l.rrs(l.zone, dns.TypeSOA)) == 0 {
+		l.add(LintError, "soa", l.zone, dns.TypeSOA, "zone has no SOA record at the apex")
+	} else {
+		l.checkSOA(l.rrs(l.zone, dns.TypeSOA)[0].(*dns.SOA))
+	}
+	if len(l.rrs(l.zone, dns.TypeNS)) == 0 {
+		l.add(LintError, "apex-ns", l.zone, dns.TypeNS, "zone has no NS RRset at the apex")
+	}
+}
+
+func (l *zoneLinter) checkOwner(owner string) {
+	if !dns.IsSubDomain(l.zone, owner) {
+		l.add(LintError, "out-of-zone", owner, 0, "owner is outside zone %s", l.zone)
+		return
+	}
+	od := l.data[owner]
+	l.checkCNAME(owner, od)
+	l.checkTTLs(owner, od)
+
+	cut := l.cutAbove(owner)
+	for _, rrtype := range od.RRtypes.Keys() {
+		if len(od.RRtypes.GetOnlyRRSet(rrtype).RRs) == 0 {
+			continue
+		}
+		switch {
+		case cut != "":
+			if rrtype != dns.TypeA && rrtype != dns.TypeAAAA {
+				l.add(LintWarning, "occluded", owner, rrtype, "data below the delegation at %s is occluded", cut)
+			} else if !l.referenced[owner] {
+				l.add(LintWarning, "glue-orphan", owner, rrtype, "address record below the delegation at %s is not referenced by any NS RRset", cut)
+			}
+		case l.isCut(owner):
+			switch rrtype {
+			case dns.TypeNS, dns.TypeDS, dns.TypeNSEC, dns.TypeRRSIG:
+			default:
+				l.add(LintWarning, "occluded", owner, rrtype, "data at the delegation point %s is occluded", owner)
+			}
+		}
+	}
+	if cut != "" {
+		return
+	}
+
+	if ns := l.rrs(owner, dns.TypeNS); len(ns) > 0 {
+		l.checkNS(owner, ns)
+	}
+	if ds := l.rrs(owner, dns.TypeDS); len(ds) > 0 && owner != l.zone {
+		l.checkDS(owner, ds)
+	}
+	for _, rr := range l.rrs(owner, dns.TypeMX) {
+		if mx := rr.(*dns.MX); mx.Mx != "." {
+			l.checkTarget(owner, dns.TypeMX, mx.Mx)
+		}
+	}
+	for _, rr := range l.rrs(owner, dns.TypeSRV) {
+		if srv := rr.(*dns.SRV); srv.Target != "." {
+			l.checkTarget(owner, dns.TypeSRV, srv.Target)
+		}
+	}
+	for _, rr := range l.rrs(owner, dns.TypeSVCB) {
+		l.checkSVCB(owner, dns.TypeSVCB, rr.(*dns.SVCB))
+	}
+	for _, rr := range l.rrs(owner, dns.TypeHTTPS) {
+		l.checkSVCB(owner, dns.TypeHTTPS, &rr.(*dns.HTTPS).SVCB)
+	}
+}
+
+// checkSOA applies the RFC 1912 §2.2 and RFC 2308 §5 recommendations.
+func (l *zoneLinter) checkSOA(soa *dns.SOA) {
+	warn := func(format string, args ...interface{}) {
+		l.add(LintWarning, "soa-timers", l.zone, dns.TypeSOA, format, args...)
+	}
+	if soa.Refresh == 0 || soa.Retry == 0 || soa.Expire == 0 {
+		l.add(LintError, "soa-timers", l.zone, dns.TypeSOA, "refresh, retry and expire must be non-zero (%d/%d/%d)", soa.Refresh, soa.Retry, soa.Expire)
+		return
+	}
+	if soa.Retry > soa.Refresh {
+		warn("retry (%d) is longer than refresh (%d)", soa.Retry, soa.Refresh)
+	}
+	if soa.Expire <= soa.Refresh+soa.Retry {
+		l.add(LintError, "soa-timers", l.zone, dns.TypeSOA, "expire (%d) is not longer than refresh + retry (%d); secondaries expire the zone before they can retry", soa.Expire, soa.Refresh+soa.Retry)
+	} else if soa.Expire < 7*86400 {
+		warn("expire (%d) is shorter than one week", soa.Expire)
+	}
+	if soa.Minttl > 86400 {
+		warn("negative caching TTL (%d) is longer than one day", soa.Minttl)
+	}
+}
+
+func (l *zoneLinter) checkCNAME(owner string, od *OwnerData) {
+	cname := od.RRtypes.GetOnlyRRSet(dns.TypeCNAME).RRs
+	if len(cname) == 0 {
+		return
+	}
+	if len(cname) > 1 {
+		l.add(LintError, "cname-other-data", owner, dns.TypeCNAME, "owner has %d CNAME records; only one is allowed", len(cname))
+	}
+	if owner == l.zone {
+		l.add(LintError, "cname-other-data", owner, dns.TypeCNAME, "CNAME at the zone apex")
+	}
+	var others []string
+	for _, rrtype := range od.RRtypes.Keys() {
+		switch rrtype {
+		case dns.TypeCNAME, dns.TypeRRSIG, dns.TypeNSEC:
+		default:
+			if len(od.RRtypes.GetOnlyRRSet(rrtype).RRs) > 0 {
+				others = append(others, dns.TypeToString[rrtype])
+			}
+		}
+	}
+	if len(others) > 0 {
+		sort.Strings(others)
+		l.add(LintError, "cname-other-data", owner, dns.TypeCNAME, "CNAME coexists with other data (%s)", strings.Join(others, ", "))
+	}
+}
+
+// checkTTLs flags RRsets whose RRs disagree on TTL (RFC 2181 §5.2).
+func (l *zoneLinter) checkTTLs(owner string, od *OwnerData) {
+	for _, rrtype := range od.RRtypes.Keys() {
+		rrs := od.RRtypes.GetOnlyRRSet(rrtype).RRs
+		for _, rr := range rrs[min(1, len(rrs)):] {
+			if rr.Header().Ttl != rrs[0].Header().Ttl {
+				l.add(LintWarning, "ttl-mismatch", owner, rrtype, "RRs in the RRset have different TTLs (%d and %d)", rrs[0].Header().Ttl, rr.Header().Ttl)
+				break
+			}
+		}
+	}
+}
+
+// checkNS checks the targets of an apex or delegation NS RRset.
+func (l *zoneLinter) checkNS(owner string, ns []dns.RR) {
+	delegation := owner != l.zone
+	var child *zoneSnapshot
+	if delegation && l.hosted != nil {
+		child = l.hosted(owner)
+	}
+	targets := csNSTargets(ns)
+	names := make([]string, 0, len(targets))
+	for t := range targets {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	for _, target := range names {
+		if !dns.IsSubDomain(l.zone, target) {
+			continue
+		}
+		if len(l.rrs(target, dns.TypeCNAME)) > 0 {
+			l.add(LintError, "ns-cname", owner, dns.TypeNS, "NS target %s is an alias (CNAME)", target)
+			continue
+		}
+		belowCut := delegation && dns.IsSubDomain(owner, target)
+		if !belowCut && l.cutAbove(target) != "" {
+			continue // glue for another delegation; checked there
+		}
+		if !l.hasAddress(target) {
+			switch {
+			case belowCut:
+				l.add(LintError, "glue-missing", owner, dns.TypeNS, "NS target %s is below the delegation but has no glue", target)
+			case delegation:
+				l.add(LintWarning, "glue-missing", owner, dns.TypeNS, "in-zone NS target %s has no address records", target)
+			default:
+				l.add(LintError, "glue-missing", owner, dns.TypeNS, "apex NS target %s has no address records", target)
+			}
+			continue
+		}
+		if belowCut && child != nil {
+			l.checkGlueConsistency(owner, target, child)
+		}
+	}
+}
+
+// checkGlueConsistency compares glue with the address records in the child
+// zone, when this server also serves the child.
+func (l *zoneLinter) checkGlueConsistency(cut, target string, child *zoneSnapshot) {
+	cod := getOwnerFrom(child, target)
+	for _, rrtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		var auth []dns.RR
+		if cod != nil {
+			auth = cod.RRtypes.GetOnlyRRSet(rrtype).RRs
+		}
+		if !csSameStrings(lintRdata(l.rrs(target, rrtype)), lintRdata(auth)) {
+			l.add(LintWarning, "glue-inconsistent", target, rrtype, "glue differs from the address records in the child zone %s", cut)
+		}
+	}
+}
+
+func lintRdata(rrs []dns.RR) []string {
+	out := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		hdr := rr.Header().String()
+		out = append(out, strings.TrimPrefix(rr.String(), hdr))
+	}
+	return out
+}
+
+// checkTarget checks an in-zone MX or SRV target.
+func (l *zoneLinter) checkTarget(owner string, rrtype uint16, target string) {
+	target = dns.CanonicalName(target)
+	if !dns.IsSubDomain(l.zone, target) || l.cutAbove(target) != "" || l.isCut(target) {
+		return
+	}
+	switch {
+	case len(l.rrs(target, dns.TypeCNAME)) > 0:
+		l.add(LintWarning, "target-cname", owner, rrtype, "target %s is an alias (CNAME); RFC 2181 §10.3", target)
+	case !l.hasAddress(target):
+		l.add(LintWarning, "target-no-address", owner, rrtype, "target %s has no address records", target)
+	}
+}
+
+// checkDS matches the DS RRset of a delegation against the DNSKEY RRset of
+// the child, when this server also serves the child.
+func (l *zoneLinter) checkDS(owner string, dsrrs []dns.RR) {
+	if l.hosted == nil {
+		return
+	}
+	child := l.hosted(owner)
+	if child == nil || child.Apex == nil {
+		return
+	}
+	keys := child.Apex.RRtypes.GetOnlyRRSet(dns.TypeDNSKEY).RRs
+	if len(keys) == 0 {
+		l.add(LintError, "ds-mismatch", owner, dns.TypeDS, "child zone %s has DS records here but publishes no DNSKEY", owner)
+		return
+	}
+	matched := 0
+	for _, rr := range dsrrs {
+		ds := rr.(*dns.DS)
+		found := false
+		for _, krr := range keys {
+			key := krr.(*dns.DNSKEY)
+			if key.KeyTag() != ds.KeyTag || key.Algorithm != ds.Algorithm {
+				continue
+			}
+			if kds := key.ToDS(ds.DigestType); kds != nil && strings.EqualFold(kds.Digest, ds.Digest) {
+				found = true
+				break
+			}
+		}
+		if found {
+			matched++
+		} else {
+			l.add(LintWarning, "ds-mismatch", owner, dns.TypeDS, "DS %d/%d/%d matches no DNSKEY in the child zone", ds.KeyTag, ds.Algorithm, ds.DigestType)
+		}
+	}
+	if matched == 0 {
+		l.add(LintError, "ds-mismatch", owner, dns.TypeDS, "no DS record matches a DNSKEY in the child zone; the delegation will not validate")
+	}
+}
+
+// checkSVCB validates SvcParams (RFC 9460 §7-8) and the private-use keys
+// defined in svcb_defs.go.
+func (l *zoneLinter) checkSVCB(owner string, rrtype uint16, rr *dns.SVCB) {
+	bad := func(format string, args ...interface{}) {
+		l.add(LintError, "svcb-params", owner, rrtype, format, args...)
+	}
+	present := map[dns.SVCBKey]bool{}
+	for _, kv := range rr.Value {
+		present[kv.Key()] = true
+	}
+	if rr.Priority == 0 {
+		for _, kv := range rr.Value {
+			// The delegation-mgmt bootstrap record is AliasMode by design.
+			if uint16(kv.Key()) != SvcbBootstrapKey {
+				l.add(LintWarning, "svcb-params", owner, rrtype, "AliasMode record carries SvcParam %s, which clients ignore", kv.Key())
+			}
+		}
+	}
+	for _, kv := range rr.Value {
+		switch v := kv.(type) {
+		case *dns.SVCBMandatory:
+			for _, k := range v.Code {
+				if k == dns.SVCB_MANDATORY {
+					bad("mandatory must not list itself")
+				} else if !present[k] {
+					bad("mandatory key %s is not present", k)
+				}
+			}
+		case *dns.SVCBNoDefaultAlpn:
+			if !present[dns.SVCB_ALPN] {
+				bad("no-default-alpn requires alpn")
+			}
+		case *dns.SVCBLocal:
+			switch uint16(v.KeyCode) {
+			case SvcbTLSAKey:
+				if _, err := ParseTLSAString(string(v.Data)); err != nil {
+					bad("private TLSA key %d: %v", SvcbTLSAKey, err)
+				}
+			case SvcbBootstrapKey:
+				if len(v.Data) == 0 {
+					bad("bootstrap key %d has no methods", SvcbBootstrapKey)
+				}
+			case 65535:
+				bad("key65535 is reserved")
+			default:
+				if v.KeyCode >= 65280 {
+					l.add(LintInfo, "svcb-params", owner, rrtype, "unknown private-use SvcParamKey %d", v.KeyCode)
+				}
+			}
+		}
+	}
+}
+
+// Lint runs the linter over the published zone.
+func (zd *ZoneData) Lint() ([]LintIssue, error) {
+	snap := zd.publishedSnapshot()
+	if snap == nil {
+		return nil, fmt.Errorf("zone %s is not loaded", zd.ZoneName)
+	}
+	return LintZoneData(zd.ZoneName, snap.Data, hostedZoneSnapshot), nil
+}
+
+func hostedZoneSnapshot(name string) *zoneSnapshot {
+	if zd, ok := Zones.Get(name); ok {
+		return zd.publishedSnapshot()
+	}
+	return nil
+}
+
+// LintZoneFile parses and lints a zone file without loading it. If zone is
+// empty the name of the first SOA in the file is used.
+func LintZoneFile(zone, filename string) (string, []LintIssue, error) {
+	if zone == "" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return "", nil, err
+		}
+		zp := dns.NewZoneParser(f, "", filename)
+		zp.SetIncludeAllowed(true)
+		for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+			if rr.Header().Rrtype == dns.TypeSOA {
+				zone = rr.Header().Name
+				break
+			}
+		}
+		f.Close()
+		if zone == "" {
+			return "", nil, fmt.Errorf("%s: no SOA record found; specify the zone name", filename)
+		}
+	}
+	zone = dns.Fqdn(zone)
+	zd := &ZoneData{ZoneName: zone, ZoneStore: MapZone, Logger: log.New(io.Discard, "", 0), Options: map[ZoneOption]bool{}}
+	if _, _, err := zd.ReadZoneFile(filename, true); err != nil {
+		return zone, nil, err
+	}
+	return zone, LintZoneData(zone, snapshotMapFromData(zd.Data), nil), nil
+}
+
+// lintIncoming lints a freshly loaded or transferred zone before it replaces
+// the published one, as configured by dnsengine.zone-lint. Findings are
+// logged and flagged as a ZoneLintWarning; in refuse mode lint errors fail
+// the load.
+func (zd *ZoneData) lintIncoming(new_zd *ZoneData) error {
+	mode := zoneLintMode()
+	if mode == ZoneLintOff {
+		zd.ClearError(ZoneLintWarning)
+		return nil
+	}
+	issues := LintZoneData(zd.ZoneName, snapshotMapFromData(new_zd.Data), hostedZoneSnapshot)
+	errs, warns := LintCounts(issues)
+	if errs+warns == 0 {
+		zd.ClearError(ZoneLintWarning)
+		return nil
+	}
+	for _, li := range issues {
+		lgDns.Debug("zone lint", "zone", zd.ZoneName, "issue", li.String())
+	}
+	summary := fmt.Sprintf("%d lint error(s), %d warning(s)", errs, warns)
+	if errs > 0 {
+		for _, li := range issues {
+			if li.Severity == LintError {
+				summary += "; first: " + li.String()
+				break
+			}
+		}
+	}
+	if mode == ZoneLintRefuse && errs > 0 {
+		lgDns.Error("zone lint failed, not publishing", "zone", zd.ZoneName, "serial", new_zd.IncomingSerial, "errors", errs, "warnings", warns)
+		return fmt.Errorf("zone %s serial %d refused by zone-lint: %s", zd.ZoneName, new_zd.IncomingSerial, summary)
+	}
+	lgDns.Warn("zone lint found problems", "zone", zd.ZoneName, "serial", new_zd.IncomingSerial, "errors", errs, "warnings", warns)
+	zd.SetError(ZoneLintWarning, "%s", summary)
+	return nil
+}
+
+// lintStagedLocked lints the working set before an incremental change (DNS
+// UPDATE, change set) to the touched owners is published. Only the scope
+// the change can affect is linted (see lintScope), in the working set and in
+// the published zone, and only findings the change introduces count: a zone
+// that was loaded with findings in warn mode can still be updated. In refuse
+// mode new lint errors fail the change, otherwise new findings are logged
+// and flagged as for a load. Caller holds zd.mu and discards the working set
+// on error.
+func (zd *ZoneData) lintStagedLocked(what string, touched []string) error {
+	mode := zoneLintMode()
+	if mode == ZoneLintOff || zd.workingSet == nil || len(touched) == 0 {
+		return nil
+	}
+	var published map[string]*OwnerData
+	if snap := zd.publishedSnapshot(); snap != nil {
+		published = snap.Data
+	}
+	scope := lintScope(zd.ZoneName, touched, zd.workingSet, published)
+	issues := lintZoneOwners(zd.ZoneName, zd.workingSet, hostedZoneSnapshot, scope)
+	if errs, warns := LintCounts(issues); errs+warns == 0 {
+		return nil
+	}
+	known := map[string]bool{}
+	for _, li := range lintZoneOwners(zd.ZoneName, published, hostedZoneSnapshot, scope) {
+		known[li.String()] = true
+	}
+	var added []LintIssue
+	for _, li := range issues {
+		if !known[li.String()] {
+			added = append(added, li)
+		}
+	}
+	errs, warns := LintCounts(added)
+	if errs+warns == 0 {
+		return nil
+	}
+	for _, li := range added {
+		lgDns.Debug("zone lint", "zone", zd.ZoneName, "change", what, "issue", li.String())
+	}
+	summary := fmt.Sprintf("%s adds %d lint error(s), %d warning(s)", what, errs, warns)
+	for _, li := range added {
+		if li.Severity == LintError {
+			summary += "; first: " + li.String()
+			break
+		}
+	}
+	if mode == ZoneLintRefuse && errs > 0 {
+		lgDns.Error("zone lint failed, not publishing", "zone", zd.ZoneName, "change", what, "errors", errs, "warnings", warns)
+		return fmt.Errorf("zone %s: %s refused by zone-lint: %s", zd.ZoneName, what, summary)
+	}
+	lgDns.Warn("zone lint found problems", "zone", zd.ZoneName, "change", what, "errors", errs, "warnings", warns)
+	zd.setErrorLocked(ZoneLintWarning, "%s", summary)
+	return nil
+}
+
+// updateOwners returns the owner names an UPDATE touches.
+func updateOwners(actions []dns.RR) []string {
+	owners := make([]string, 0, len(actions))
+	for _, rr := range actions {
+		owners = append(owners, rr.Header().Name)
+	}
+	return owners
+}
@@ -0,0 +1,267 @@
+package tdns
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const lintTestZone = `lint.example.		3600	IN	SOA	ns1.lint.example. hostmaster.lint.example. 1 3600 7200 3600 172800
+lint.example.		3600	IN	NS	ns1.lint.example.
+lint.example.		3600	IN	NS	ns2.lint.example.
+lint.example.		3600	IN	MX	10 mail.lint.example.
+lint.example.		3600	IN	MX	20 alias.lint.example.
+ns1.lint.example.	3600	IN	A	192.0.2.1
+ns2.lint.example.	3600	IN	CNAME	ns1.lint.example.
+www.lint.example.	3600	IN	A	192.0.2.10
+www.lint.example.	300	IN	A	192.0.2.11
+alias.lint.example.	3600	IN	CNAME	www.lint.example.
+alias.lint.example.	3600	IN	TXT	"oops"
+_sip._tcp.lint.example.	3600	IN	SRV	0 0 5060 sip.lint.example.
+child.lint.example.	3600	IN	NS	ns.child.lint.example.
+child.lint.example.	3600	IN	NS	ns.other.child.lint.example.
+child.lint.example.	3600	IN	TXT	"occluded"
+ns.child.lint.example.	3600	IN	A	192.0.2.53
+stray.child.lint.example.	3600	IN	A	192.0.2.99
+svc.lint.example.	3600	IN	HTTPS	1 . mandatory=port no-default-alpn
+boot.lint.example.	3600	IN	SVCB	0 . key65282="udp"
+`
+
+func lintHas(issues []LintIssue, sev, check, owner string) bool {
+	for _, li := range issues {
+		if li.Severity == sev && li.Check == check && li.Owner == owner {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLintZoneData(t *testing.T) {
+	zd := testZone(t, "lint.example.", lintTestZone)
+	issues, err := zd.Lint()
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	want := []struct{ sev, check, owner string }{
+		{LintError, "soa-timers", "lint.example."},
+		{LintWarning, "soa-timers", "lint.example."},
+		{LintError, "ns-cname", "lint.example."},
+		{LintWarning, "target-no-address", "lint.example."},
+		{LintWarning, "target-cname", "lint.example."},
+		{LintWarning, "ttl-mismatch", "www.lint.example."},
+		{LintError, "cname-other-data", "alias.lint.example."},
+		{LintWarning, "target-no-address", "_sip._tcp.lint.example."},
+		{LintError, "glue-missing", "child.lint.example."},
+		{LintWarning, "occluded", "child.lint.example."},
+		{LintWarning, "glue-orphan", "stray.child.lint.example."},
+		{LintError, "svcb-params", "svc.lint.example."},
+	}
+	for _, w := range want {
+		if !lintHas(issues, w.sev, w.check, w.owner) {
+			t.Errorf("missing %s %s at %s", w.sev, w.check, w.owner)
+		}
+	}
+	for _, li := range issues {
+		if li.Owner == "boot.lint.example." || li.Owner == "ns.child.lint.example." {
+			t.Errorf("unexpected issue: %s", li)
+		}
+	}
+	if testing.Verbose() {
+		for _, li := range issues {
+			t.Log(li)
+		}
+	}
+}
+
+// DS records of a delegation are checked against the DNSKEYs of the child
+// when the child is served by the same server.
+func TestLintDSAgainstHostedChild(t *testing.T) {
+	key := &dns.DNSKEY{Hdr: dns.RR_Header{Name: "sec.lintds.example.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags: 257, Protocol: 3, Algorithm: dns.ED25519}
+	if _, err := key.Generate(256); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	ds := key.ToDS(dns.SHA256)
+	bogus := *ds
+	bogus.Digest = strings.Repeat("00", 32)
+
+	child := testZone(t, "sec.lintds.example.", `sec.lintds.example.	3600	IN	SOA	ns.lintds.example. h.lintds.example. 1 3600 600 1209600 3600
+sec.lintds.example.	3600	IN	NS	ns.lintds.example.
+`+key.String()+"\n")
+	registerZones(t, child)
+
+	parent := func(dsrrs ...dns.RR) *ZoneData {
+		var b strings.Builder
+		b.WriteString(`lintds.example.	3600	IN	SOA	ns.lintds.example. h.lintds.example. 1 3600 600 1209600 3600
+lintds.example.	3600	IN	NS	ns.lintds.example.
+ns.lintds.example.	3600	IN	A	192.0.2.1
+sec.lintds.example.	3600	IN	NS	ns.lintds.example.
+`)
+		for _, rr := range dsrrs {
+			b.WriteString(rr.String() + "\n")
+		}
+		return testZone(t, "lintds.example.", b.String())
+	}
+
+	issues, _ := parent(ds).Lint()
+	if lintHas(issues, LintError, "ds-mismatch", "sec.lintds.example.") || lintHas(issues, LintWarning, "ds-mismatch", "sec.lintds.example.") {
+		t.Errorf("matching DS flagged: %v", issues)
+	}
+	issues, _ = parent(ds, &bogus).Lint()
+	if !lintHas(issues, LintWarning, "ds-mismatch", "sec.lintds.example.") || lintHas(issues, LintError, "ds-mismatch", "sec.lintds.example.") {
+		t.Errorf("stale extra DS: want a warning only, got %v", issues)
+	}
+	issues, _ = parent(&bogus).Lint()
+	if !lintHas(issues, LintError, "ds-mismatch", "sec.lintds.example.") {
+		t.Errorf("no matching DS: want an error, got %v", issues)
+	}
+}
+
+// In refuse mode a zone file with lint errors is not published; in warn mode
+// it is, with a zone-lint warning.
+func TestLintIncomingModes(t *testing.T) {
+	const good = `mode.example.	3600	IN	SOA	ns1.mode.example. h.mode.example. %d 3600 600 1209600 3600
+mode.example.	3600	IN	NS	ns1.mode.example.
+ns1.mode.example.	3600	IN	A	192.0.2.1
+`
+	zd := testZone(t, "mode.example.", strings.Replace(good, "%d", "1", 1))
+	zd.ZoneType = Primary
+	zd.Options = map[ZoneOption]bool{}
+	registerZones(t, zd)
+	zd.Zonefile = filepath.Join(t.TempDir(), "mode.example.zone")
+	bad := strings.Replace(good, "%d", "2", 1) + "ns1.mode.example.	3600	IN	CNAME	www.mode.example.\n"
+	if err := os.WriteFile(zd.Zonefile, []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	prev := liveConfig.Load()
+	t.Cleanup(func() { liveConfig.Store(prev) })
+
+	liveConfig.Store(&RuntimeConfig{ZoneLint: ZoneLintRefuse})
+	if _, err := zd.FetchFromFile(false, false, false, nil); err == nil || !strings.Contains(err.Error(), "zone-lint") {
+		t.Fatalf("FetchFromFile in refuse mode = %v, want a zone-lint refusal", err)
+	}
+	if zd.publishedSerial() != 1 {
+		t.Errorf("refused zone was published (serial %d)", zd.publishedSerial())
+	}
+
+	liveConfig.Store(&RuntimeConfig{ZoneLint: ZoneLintWarn})
+	if _, err := zd.FetchFromFile(false, false, false, nil); err != nil {
+		t.Fatalf("FetchFromFile in warn mode: %v", err)
+	}
+	if zd.publishedSerial() != 2 || !zd.HasError(ZoneLintWarning) {
+		t.Errorf("warn mode: serial %d, lint warning %v; want 2, true", zd.publishedSerial(), zd.HasError(ZoneLintWarning))
+	}
+}
+
+func TestLintZoneFile(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "file.zone")
+	if err := os.WriteFile(f, []byte(strings.ReplaceAll(lintTestZone, "lint.example.", "file.example.")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	zone, issues, err := LintZoneFile("", f)
+	if err != nil {
+		t.Fatalf("LintZoneFile: %v", err)
+	}
+	if zone != "file.example." {
+		t.Errorf("zone = %q, want it taken from the SOA", zone)
+	}
+	if errs, _ := LintCounts(issues); errs == 0 {
+		t.Error("no errors found in a broken zone file")
+	}
+}
+
+// UPDATEs and change sets are linted too; only the findings a change adds
+// count.
+func TestLintStagedChanges(t *testing.T) {
+	zd := changeSetTestZoneData(t)
+	kdb := newTestKeyDB(t)
+	prev := liveConfig.Load()
+	t.Cleanup(func() { liveConfig.Store(prev) })
+	liveConfig.Store(&RuntimeConfig{ZoneLint: ZoneLintRefuse})
+
+	ops, err := normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{
+		{Op: ChangeSetOpAdd, RRs: []string{"www 3600 IN TXT \"fine\""}},
+		{Op: ChangeSetOpAdd, RRs: []string{"mail 3600 IN TXT \"fine\""}},
+	})
+	if err != nil {
+		t.Fatalf("normalizeChangeSetOps: %v", err)
+	}
+	if _, err := zd.ApplyChangeSet(ops); err != nil {
+		t.Fatalf("clean change set refused: %v", err)
+	}
+	serial := zd.publishedSerial()
+
+	// ns1 is the target of the apex NS RRset and has an address; a CNAME
+	// there is a lint error.
+	zd.UpdatePolicy.Zone = UpdatePolicyDetail{RRtypes: map[uint16]bool{dns.TypeCNAME: true}, TTL: 3600}
+	bad := mustRR(t, "ns1.cs.example. 3600 IN CNAME www.cs.example.")
+	_, err = zd.ApplyZoneUpdateToZoneData(UpdateRequest{Cmd: "ZONE-UPDATE", Actions: []dns.RR{bad}}, kdb)
+	if err == nil || !strings.Contains(err.Error(), "zone-lint") {
+		t.Fatalf("UPDATE in refuse mode = %v, want a zone-lint refusal", err)
+	}
+	if zd.publishedSerial() != serial {
+		t.Error("refused UPDATE was published")
+	}
+	zd.mu.Lock()
+	staged := zd.workingSet != nil
+	zd.mu.Unlock()
+	if staged {
+		t.Error("refused UPDATE left a working set behind")
+	}
+
+	// Internal updates are never refused.
+	if _, err := zd.ApplyZoneUpdateToZoneData(UpdateRequest{Cmd: "ZONE-UPDATE", InternalUpdate: true, Actions: []dns.RR{bad}}, kdb); err != nil {
+		t.Fatalf("internal UPDATE refused: %v", err)
+	}
+	if zd.publishedSerial() == serial {
+		t.Fatal("internal UPDATE was not published")
+	}
+
+	// The zone now has a lint error; an unrelated change is still accepted.
+	ops, _ = normalizeChangeSetOps(zd.ZoneName, []ChangeSetOp{{Op: ChangeSetOpReplace, RRs: []string{"www 300 IN A 192.0.2.7"}}})
+	if _, err := zd.ApplyChangeSet(ops); err != nil {
+		t.Errorf("change set without new findings refused: %v", err)
+	}
+}
+
+func TestLintScope(t *testing.T) {
+	zd := testZone(t, "lint.example.", lintTestZone)
+	data := zd.publishedSnapshot().Data
+	has := func(scope []string, name string) bool {
+		for _, s := range scope {
+			if s == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Glue below a delegation brings in the delegation and the apex;
+	// unrelated owners stay out.
+	scope := lintScope("lint.example.", []string{"ns.child.lint.example."}, data)
+	for _, name := range []string{"lint.example.", "ns.child.lint.example.", "child.lint.example."} {
+		if !has(scope, name) {
+			t.Errorf("scope %v lacks %s", scope, name)
+		}
+	}
+	if has(scope, "www.lint.example.") {
+		t.Errorf("scope %v includes an untouched owner", scope)
+	}
+
+	// Removing the delegation brings in everything below it.
+	staged := maps.Clone(data)
+	delete(staged, "child.lint.example.")
+	scope = lintScope("lint.example.", []string{"child.lint.example."}, staged, data)
+	if !has(scope, "stray.child.lint.example.") {
+		t.Errorf("scope %v lacks the data below the removed delegation", scope)
+	}
+	issues := lintZoneOwners("lint.example.", staged, nil, scope)
+	if lintHas(issues, LintWarning, "glue-orphan", "stray.child.lint.example.") || lintHas(issues, LintWarning, "occluded", "stray.child.lint.example.") {
+		t.Errorf("stale delegation findings: %v", issues)
+	}
+}
@@ -2,6 +2,7 @@ package tdns
 
 import (
 	"fmt"
+	"maps"
 	"sort"
 	"time"
 
@@ -27,6 +28,21 @@ func (zd *ZoneData) ensureWorkingSet() {
 	}
 }
 
+// stagedCheckpointLocked returns a function that puts the working set back
+// the way it is now, for a writer that may have to abandon what it stages.
+// Staged owners are copy-on-write (cloneOwner), so a shallow copy of the map
+// is enough. Caller holds zd.mu.
+func (zd *ZoneData) stagedCheckpointLocked() func() {
+	if zd.workingSet == nil {
+		return func() {
+			zd.workingSet = nil
+			zd.wsSignalSynth = nil
+		}
+	}
+	saved := maps.Clone(zd.workingSet)
+	return func() { zd.workingSet = saved }
+}
+
 func (zd *ZoneData) cloneOwner(name string) *OwnerData {
 	src := zd.workingSet[name]
 	nod := &OwnerData{Name: name, RRtypes: NewRRTypeStore()}
@@ -37,6 +53,7 @@ func (zd *ZoneData) cloneOwner(name string) *OwnerData {
 		}
 	}
 	zd.workingSet[name] = nod
+	zd.resignTouchLocked(name)
 	return nod
 }
 
@@ -53,6 +70,7 @@ func (zd *ZoneData) stageDelete(name string, rrtype uint16) {
 func (zd *ZoneData) stageOwnerReplace(name string, od *OwnerData) {
 	zd.ensureWorkingSet()
 	zd.workingSet[name] = od
+	zd.resignTouchLocked(name)
 }
 
 func (zd *ZoneData) pendingChanges() *PendingChanges {
@@ -271,11 +289,13 @@ func (zd *ZoneData) stageDeleteLocked(name string, rrtype uint16) {
 func (zd *ZoneData) stageOwnerReplaceLocked(name string, od *OwnerData) {
 	zd.ensureWorkingSet()
 	zd.workingSet[name] = od
+	zd.resignTouchLocked(name)
 }
 
 func (zd *ZoneData) stageOwnerDeleteLocked(name string) {
 	zd.ensureWorkingSet()
 	delete(zd.workingSet, name)
+	zd.resignTouchLocked(name)
 }
 
 func (zd *ZoneData) publishLocked(gen uint64) {
@@ -333,9 +353,10 @@ func (zd *ZoneData) publishWorkingSetLocked(gen uint64, bumpSerial bool) {
 	zd.resignWorkingSetSOAIfSigned()
 
 	data := zd.workingSet
+	prev := zd.snapshot.Load()
 	// Maintain the IXFR delta history BEFORE building the snapshot so the
 	// chain copied into it ends exactly at this publish's serial (Project C).
-	zd.updateIxfrChainLocked(zd.snapshot.Load(), serial, data)
+	zd.updateIxfrChainLocked(prev, serial, data)
 	snap := zd.buildSnapshotLocked(serial, data, zd.wsSignalSynth)
 	zd.snapshot.Store(snap)
 
@@ -358,6 +379,12 @@ func (zd *ZoneData) publishWorkingSetLocked(gen uint64, bumpSerial bool) {
 		lg.Error("publish: serial mirror drift", "zone", zd.ZoneName, "current", zd.CurrentSerial, "snapshot", loaded.Serial)
 	}
 
+	ev := map[string]interface{}{"serial": serial}
+	if prev != nil {
+		ev["previous_serial"] = prev.Serial
+	}
+	emitEvent(EventZonePublished, zd.ZoneName, ev)
+
 	_ = zd.NotifyDownstreams()
 }
 
@@ -406,6 +433,9 @@ func (zd *ZoneData) applyRefreshReplacementLocked(new_zd *ZoneData, dynamicRRs [
 	zd.ZoneType = new_zd.ZoneType
 
 	zd.workingSet = snapshotMapFromData(new_zd.Data)
+	// Nothing tracks what the new data changed: the next re-sign tick does
+	// a full pass.
+	zd.resignQ = nil
 	// A refresh replaces zone data wholesale; carry the synthesized-signal
 	// fallback over from the current snapshot so it survives until the transport
 	// postpass recomputes it. The stored _dns.<ns> owner RRsets are preserved
@@ -251,6 +251,21 @@ func (zd *ZoneData) publishedSnapshot() *zoneSnapshot {
 	return zd.snapshot.Load()
 }
 
+// publishedSerial is the serial of the snapshot readers currently see, falling
+// back to CurrentSerial before the first publish. This is the serial the
+// /api/v2 ETags are derived from.
+func (zd *ZoneData) publishedSerial() uint32 {
+	if snap := zd.publishedSnapshot(); snap != nil {
+		return snap.Serial
+	}
+	if zd == nil {
+		return 0
+	}
+	zd.mu.Lock()
+	defer zd.mu.Unlock()
+	return zd.CurrentSerial
+}
+
 // soaForResponse returns a response-only SOA RRset from the published snapshot.
 func (zd *ZoneData) soaForResponse(apex *OwnerData) core.RRset {
 	return zd.soaForResponseFrom(zd.publishedSnapshot(), apex)
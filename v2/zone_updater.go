@@ -25,9 +25,33 @@ type UpdateRequest struct {
 	Trusted        bool     // Content of update is trusted (via validation or policy)
 	InternalUpdate bool     // Internal update, not a DNS UPDATE from the outside
 	Status         *UpdateStatus
+	Actor          AuditActor // who caused the update; zero for internal updates (see updateRequestActor)
 	Description    string
-	PreCondition   func() bool
+	PreCondition   func() bool // ZONE-UPDATE: evaluated in the updater just before apply; false drops the update
 	Action         func() error
+	Response       chan ZoneUpdateResult // optional; ZONE-UPDATE reports its outcome here (buffer it, the send never blocks)
+}
+
+// ZoneUpdateResult is the outcome of a ZONE-UPDATE, sent on
+// UpdateRequest.Response for callers (the /api/v2 RRset endpoints) that must
+// answer synchronously.
+type ZoneUpdateResult struct {
+	Updated            bool
+	Serial             uint32 // published serial after the update
+	PreconditionFailed bool
+	Error              string
+}
+
+// respond delivers res to the requester, if it asked for a result. Never
+// blocks the updater: a requester that gave up (timeout) just misses it.
+func (ur *UpdateRequest) respond(res ZoneUpdateResult) {
+	if ur.Response == nil {
+		return
+	}
+	select {
+	case ur.Response <- res:
+	default:
+	}
 }
 
 // updaterCmdMutatesZoneContent reports whether a ZoneUpdater command writes
@@ -103,6 +127,7 @@ func (kdb *KeyDB) ZoneUpdaterEngine(ctx context.Context) error {
 			if !ok {
 				lg.Warn("ZoneUpdater: unknown zone in update request, ignoring", "cmd", ur.Cmd, "zone", ur.ZoneName)
 				lg.Debug("ZoneUpdater: known zones", "zones", Zones.Keys())
+				ur.respond(ZoneUpdateResult{Error: fmt.Sprintf("zone %s is unknown", ur.ZoneName)})
 				continue
 			}
 
@@ -133,6 +158,7 @@ func (kdb *KeyDB) ZoneUpdaterEngine(ctx context.Context) error {
 				lg.Error("ZoneUpdater: refusing zone mutation on a secondary that may not originate content (invariant violation)",
 					"cmd", ur.Cmd, "zone", ur.ZoneName, "internal", ur.InternalUpdate,
 					"description", ur.Description, "actions", len(ur.Actions))
+				ur.respond(ZoneUpdateResult{Error: fmt.Sprintf("zone %s may not originate content", ur.ZoneName)})
 				continue
 			}
 
@@ -180,6 +206,15 @@ func (kdb *KeyDB) ZoneUpdaterEngine(ctx context.Context) error {
 					// and zonefile-backends don't touch in-memory zone
 					// data so OptDirty stays as it was.
 					logUpdateActions("CHILD-UPDATE", ur.Actions)
+					auditRecord(kdb, AuditEntry{
+						Actor:  updateRequestActor(ur),
+						Source: AuditSourceZoneUpdater,
+						Zone:   ur.ZoneName,
+						Action: ur.Cmd,
+						New:    auditUpdateActions(ur.Actions),
+						Serial: auditZoneSerial(zd),
+						Detail: "backend=" + backend.Name(),
+					})
 				}
 
 			case "ZONE-UPDATE":
@@ -187,6 +222,12 @@ func (kdb *KeyDB) ZoneUpdaterEngine(ctx context.Context) error {
 				// (i.e. not child delegation information).
 				lg.Info("ZoneUpdater: ZONE-UPDATE request", "zone", ur.ZoneName, "actions", len(ur.Actions))
 				lg.Debug("ZoneUpdater: ZONE-UPDATE actions detail", "actions", SprintUpdates(ur.Actions))
+				if ur.PreCondition != nil && !ur.PreCondition() {
+					lg.Info("ZoneUpdater: ZONE-UPDATE precondition failed, dropping update", "zone", zd.ZoneName, "description", ur.Description)
+					ur.respond(ZoneUpdateResult{PreconditionFailed: true, Serial: zd.publishedSerial(),
+						Error: "precondition failed"})
+					continue
+				}
 				if zd.Options[OptAllowUpdates] || ur.InternalUpdate {
 					// Compute delegation sync status before apply (needs pre-state),
 					// but only enqueue after successful apply.
@@ -202,6 +243,7 @@ func (kdb *KeyDB) ZoneUpdaterEngine(ctx context.Context) error {
 
 					var updated bool
 					var err error
+					before := zd.auditRRsetImage(ur.Actions)
 
 					switch zd.ZoneType {
 					case Primary:
@@ -223,42 +265,26 @@ func (kdb *KeyDB) ZoneUpdaterEngine(ctx context.Context) error {
 						zd.SetOption(OptDirty, true)
 						logUpdateActions("ZONE-UPDATE", ur.Actions)
 					}
+					res := ZoneUpdateResult{Updated: updated, Serial: zd.publishedSerial()}
+					if err != nil {
+						res.Error = err.Error()
+					}
+					ur.respond(res)
+					if updated {
+						auditRecord(kdb, AuditEntry{
+							Actor:  updateRequestActor(ur),
+							Source: AuditSourceZoneUpdater,
+							Zone:   zd.ZoneName,
+							Action: ur.Cmd,
+							Old:    before,
+							New:    zd.auditRRsetImage(ur.Actions),
+							Serial: auditZoneSerial(zd),
+							Detail: ur.Description,
+						})
+					}
 
-					// API-managed primaries persist updated content
-					// immediately (the mirror of the CHILD-UPDATE 'direct'
-					// backend persist): without this, updated content lives
-					// only in RAM until a freeze/manual write and is lost on
-					// restart. Internal updates (CSYNC/KEY publication etc.)
-					// are included — they change zone data too but never set
-					// OptDirty, so they need force. WriteZone clears OptDirty
-					// on success, which also un-blocks the dirty-primary
-					// reload refusal. The persistence decision reads a
-					// zd.mu-protected snapshot (RefreshEngine mutates these
-					// fields under that lock on reload); the lock is NOT held
-					// across WriteZone, which reacquires it.
 					if updated {
-						zd.mu.Lock()
-						apiPrimary := zd.ZoneType == Primary && zd.Options[OptApiManagedZone]
-						zonefile := zd.Zonefile
-						zd.mu.Unlock()
-						if apiPrimary && zonefile != "" {
-							if _, werr := zd.WriteZone(true, ur.InternalUpdate); werr != nil {
-								// The client response is long gone (async queue),
-								// so surface the persistence failure durably:
-								// visible in zone list, deliberately NOT
-								// service-impacting (memory state is good).
-								lg.Warn("ZoneUpdater: failed to persist API-managed primary after ZONE-UPDATE (updated content is in memory only until the next successful write)", "zone", zd.ZoneName, "file", zonefile, "error", werr)
-								zd.SetError(RefreshError, "failed to persist zone after update: %v", werr)
-								zd.LatestError = time.Now()
-							} else {
-								// A successful persist is the primary-zone
-								// analogue of a successful refresh (both are
-								// file I/O): clear RefreshError, same as the
-								// refresh paths do.
-								zd.ClearError(RefreshError)
-								lg.Debug("ZoneUpdater: persisted API-managed primary after ZONE-UPDATE", "zone", zd.ZoneName, "file", zonefile, "internal", ur.InternalUpdate)
-							}
-						}
+						zd.persistApiManagedPrimary(ur.InternalUpdate)
 					}
 
 					// Enqueue delegation sync after successful apply
@@ -278,6 +304,7 @@ func (kdb *KeyDB) ZoneUpdaterEngine(ctx context.Context) error {
 					}
 				} else {
 					lg.Warn("ZoneUpdater: updates disallowed for zone, dropping ZONE-UPDATE", "zone", zd.ZoneName)
+					ur.respond(ZoneUpdateResult{Error: fmt.Sprintf("zone %s does not allow updates", zd.ZoneName)})
 				}
 				lg.Debug("ZoneUpdater: ZONE-UPDATE done")
 
@@ -340,6 +367,14 @@ func (kdb *KeyDB) ZoneUpdaterEngine(ctx context.Context) error {
 				err = tx.Commit()
 				if err != nil {
 					lg.Error("tx.Commit failed", "error", err)
+				} else {
+					auditRecord(kdb, AuditEntry{
+						Actor:  updateRequestActor(ur),
+						Source: AuditSourceZoneUpdater,
+						Zone:   ur.ZoneName,
+						Action: ur.Cmd,
+						New:    auditUpdateActions(ur.Actions),
+					})
 				}
 				logUpdateActions("TRUSTSTORE-UPDATE", ur.Actions)
 
@@ -349,6 +384,19 @@ func (kdb *KeyDB) ZoneUpdaterEngine(ctx context.Context) error {
 						"zone", pv.childZone, "keyid", pv.keyid)
 					kdb.TriggerChildKeyVerification(pv.childZone, pv.keyid, pv.keyRR)
 				}
+
+			case "MULTISIGNER-UPDATE":
+				// A peer's ZSKs go to the keystore as foreign keys; the
+				// re-sign then publishes them in the DNSKEY RRset.
+				lg.Debug("ZoneUpdater: MULTISIGNER-UPDATE request", "zone", ur.ZoneName, "actions", len(ur.Actions))
+				changed, err := kdb.applyMultiSignerUpdate(zd, ur)
+				if err != nil {
+					lg.Error("ZoneUpdater: MULTISIGNER-UPDATE failed", "zone", ur.ZoneName, "err", err)
+				} else if changed {
+					triggerResign(&Conf, ur.ZoneName)
+				}
+				logUpdateActions("MULTISIGNER-UPDATE", ur.Actions)
+
 			default:
 				lg.Error("ZoneUpdater: unknown command, ignoring", "cmd", ur.Cmd)
 			}
@@ -494,6 +542,10 @@ func (zd *ZoneData) ApplyChildUpdateToZoneData(ur UpdateRequest, kdb *KeyDB) (bo
 		}
 		zd.mu.Unlock()
 	}()
+	var rollback func()
+	if zoneLintMode() == ZoneLintRefuse {
+		rollback = zd.stagedCheckpointLocked()
+	}
 	zd.ensureWorkingSet()
 
 	for _, rr := range ur.Actions {
@@ -607,6 +659,16 @@ func (zd *ZoneData) ApplyChildUpdateToZoneData(ur UpdateRequest, kdb *KeyDB) (bo
 
 	lg.Debug("ApplyChildUpdateToZoneData done", "updated", updated)
 
+	if updated {
+		if err := zd.lintStagedLocked("child update", updateOwners(ur.Actions)); err != nil {
+			if rollback != nil {
+				rollback()
+			}
+			updated = false
+			return false, err
+		}
+	}
+
 	return updated, nil
 }
 
@@ -643,6 +705,12 @@ func (zd *ZoneData) ApplyZoneUpdateToZoneData(ur UpdateRequest, kdb *KeyDB) (boo
 		}
 		zd.mu.Unlock()
 	}()
+	// Internal updates (CDS, DNSKEY, ... from the engines) are not linted:
+	// a lint finding must never stall a rollover.
+	var rollback func()
+	if !ur.InternalUpdate && zoneLintMode() == ZoneLintRefuse {
+		rollback = zd.stagedCheckpointLocked()
+	}
 	zd.ensureWorkingSet()
 
 	lg.Debug("ApplyZoneUpdateToZoneData: processing actions", "zone", zd.ZoneName, "count", len(ur.Actions))
@@ -780,6 +848,16 @@ func (zd *ZoneData) ApplyZoneUpdateToZoneData(ur UpdateRequest, kdb *KeyDB) (boo
 
 	lg.Debug("ApplyZoneUpdateToZoneData done", "updated", updated)
 
+	if updated && !ur.InternalUpdate {
+		if err := zd.lintStagedLocked("update", updateOwners(ur.Actions)); err != nil {
+			if rollback != nil {
+				rollback()
+			}
+			updated = false
+			return false, err
+		}
+	}
+
 	return updated, nil
 }
 
@@ -1315,3 +1393,37 @@ func computeNewDS(dss *DelegationSyncStatus, zd *ZoneData) {
 	}
 	dss.NewDS = newDS
 }
+
+// persistApiManagedPrimary writes an API-managed primary to disk right after
+// its content changed (the mirror of the CHILD-UPDATE 'direct' backend
+// persist): without this, updated content lives only in RAM until a
+// freeze/manual write and is lost on restart. Internal updates (CSYNC/KEY
+// publication etc.) are included — they change zone data too but never set
+// OptDirty, so they need force. WriteZone clears OptDirty on success, which
+// also un-blocks the dirty-primary reload refusal. The persistence decision
+// reads a zd.mu-protected snapshot (RefreshEngine mutates these fields under
+// that lock on reload); the lock is NOT held across WriteZone, which
+// reacquires it. No-op for any other zone.
+func (zd *ZoneData) persistApiManagedPrimary(internal bool) {
+	zd.mu.Lock()
+	apiPrimary := zd.ZoneType == Primary && zd.Options[OptApiManagedZone]
+	zonefile := zd.Zonefile
+	zd.mu.Unlock()
+	if !apiPrimary || zonefile == "" {
+		return
+	}
+	if _, werr := zd.WriteZone(true, internal); werr != nil {
+		// The client response is typically long gone (async queue), so
+		// surface the persistence failure durably: visible in zone list,
+		// deliberately NOT service-impacting (memory state is good).
+		lg.Warn("failed to persist API-managed primary after update (updated content is in memory only until the next successful write)", "zone", zd.ZoneName, "file", zonefile, "error", werr)
+		zd.SetError(RefreshError, "failed to persist zone after update: %v", werr)
+		zd.LatestError = time.Now()
+		return
+	}
+	// A successful persist is the primary-zone analogue of a successful
+	// refresh (both are file I/O): clear RefreshError, same as the refresh
+	// paths do.
+	zd.ClearError(RefreshError)
+	lg.Debug("persisted API-managed primary after update", "zone", zd.ZoneName, "file", zonefile, "internal", internal)
+}
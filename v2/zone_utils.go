@@ -231,6 +231,11 @@ func (zd *ZoneData) FetchFromFile(verbose, debug, force bool, dynamicRRs []*core
 		return false, nil // new zone not loaded, but not returning any error
 	}
 
+	if err := zd.lintIncoming(&new_zd); err != nil {
+		zd.SetStatus(prevStatus)
+		return false, err
+	}
+
 	new_zd.Ready = true
 
 	// Pre-refresh callbacks: analysis of old vs new zone data + modification of new_zd.
@@ -351,6 +356,11 @@ func (zd *ZoneData) FetchFromUpstream(verbose, debug, force bool, dynamicRRs []*
 			"zone", zd.ZoneName, "serial", zd.IncomingSerial)
 	}
 
+	if err := zd.lintIncoming(&new_zd); err != nil {
+		zd.SetStatus(prevStatus)
+		return false, err
+	}
+
 	new_zd.Ready = true
 
 	// Pre-refresh callbacks: analysis of old vs new zone data + modification of new_zd.
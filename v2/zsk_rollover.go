@@ -71,7 +71,7 @@ func healZskActiveAt(kdb *KeyDB, zone string, activeZSK *DnssecKeyWithTimestamps
 		}
 	}()
 	if needAt {
-		now := time.Now().UTC().Format(time.RFC3339)
+		now := keyStateNow().UTC().Format(time.RFC3339)
 		if _, err := tx.Exec(`UPDATE DnssecKeyStore SET active_at=? WHERE zonename=? AND keyid=? AND (active_at IS NULL OR active_at='')`,
 			now, zone, int(activeZSK.KeyTag)); err != nil {
 			lgSigner.Warn("zsk rollover: heal active_at failed", "zone", zone, "keyid", activeZSK.KeyTag, "err", err)
@@ -452,9 +452,11 @@ func rolloverZskForZone(ctx context.Context, conf *Config, kdb *KeyDB, zd *ZoneD
 		return fmt.Errorf("list standby keys: %w", err)
 	}
 	haveStandby := false
+	var nextZSK uint16
 	for i := range standbyKeys {
 		if standbyKeys[i].Flags == 256 {
 			haveStandby = true
+			nextZSK = standbyKeys[i].KeyTag
 			break
 		}
 	}
@@ -467,6 +469,14 @@ func rolloverZskForZone(ctx context.Context, conf *Config, kdb *KeyDB, zd *ZoneD
 		return nil
 	}
 
+	// With an offline KSK the DNSKEY RRset after the roll must also come
+	// from the SKR: the current bundle has to carry the incoming ZSK, which
+	// it does once the roll has been requested in a KSR and signed.
+	if zd.offlineKSK() && !skrCoversZSK(kdb, zone, now, nextZSK) {
+		lgSigner.Warn("zsk rollover: roll due but the current SKR bundle lacks the standby ZSK; export a new KSR", "zone", zone, "standby_keyid", nextZSK, "manual", isManual)
+		return nil
+	}
+
 	oldActive, newActive, err := kdb.RolloverKey(zone, "ZSK", nil)
 	if err != nil {
 		return fmt.Errorf("RolloverKey: %w", err)